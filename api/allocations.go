@@ -1,7 +1,17 @@
 package api
 
 import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
 	"sort"
+	"strconv"
+	"sync"
 	"time"
 )
 
@@ -40,6 +50,227 @@ func (a *Allocations) Info(allocID string, q *QueryOptions) (*Allocation, *Query
 	return &resp, qm, nil
 }
 
+// Signal sends a signal to the named task of the allocation, or to all of
+// its running tasks if the task name is empty.
+func (a *Allocations) Signal(alloc *Allocation, task, signal string) error {
+	v := url.Values{}
+	v.Set("signal", signal)
+	if task != "" {
+		v.Set("task", task)
+	}
+	return a.clientAllocRequest(alloc, "signal", v)
+}
+
+// Restart restarts the named task of the allocation, or all of its running
+// tasks if the task name is empty.
+func (a *Allocations) Restart(alloc *Allocation, task string) error {
+	v := url.Values{}
+	if task != "" {
+		v.Set("task", task)
+	}
+	return a.clientAllocRequest(alloc, "restart", v)
+}
+
+// ExecOptions describes a command to run inside the isolation context of a
+// task and the streams it is attached to.
+type ExecOptions struct {
+	Command []string
+
+	// Tty allocates a terminal for the command. Its output is then written to
+	// Stdout only.
+	Tty bool
+
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// ResizeCh is used to send the size of the terminal when it changes.
+	ResizeCh <-chan TerminalSize
+}
+
+// TerminalSize is the size of a terminal in characters.
+type TerminalSize struct {
+	Height uint16
+	Width  uint16
+}
+
+// execFrame is a message of an exec session.
+type execFrame struct {
+	Stdin       []byte        `json:",omitempty"`
+	StdinClosed bool          `json:",omitempty"`
+	TTYSize     *TerminalSize `json:",omitempty"`
+	Stdout      []byte        `json:",omitempty"`
+	Stderr      []byte        `json:",omitempty"`
+	Exited      bool          `json:",omitempty"`
+	ExitCode    int           `json:",omitempty"`
+	Error       string        `json:",omitempty"`
+}
+
+// Exec runs a command inside the isolation context of the task of the
+// allocation and returns its exit code once it exits.
+func (a *Allocations) Exec(alloc *Allocation, task string, opts *ExecOptions) (int, error) {
+	addr, err := a.nodeHTTPAddr(alloc)
+	if err != nil {
+		return -1, err
+	}
+	command, err := json.Marshal(opts.Command)
+	if err != nil {
+		return -1, err
+	}
+
+	v := url.Values{}
+	v.Set("task", task)
+	v.Set("command", string(command))
+	v.Set("tty", strconv.FormatBool(opts.Tty))
+	u := &url.URL{
+		Scheme:   "http",
+		Host:     addr,
+		Path:     fmt.Sprintf("/v1/client/allocation/%s/exec", alloc.ID),
+		RawQuery: v.Encode(),
+	}
+	req, err := http.NewRequest("POST", u.String(), nil)
+	if err != nil {
+		return -1, err
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "nomad-exec")
+
+	// Upgrade the connection to the exec stream
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return -1, err
+	}
+	defer conn.Close()
+	if err := req.Write(conn); err != nil {
+		return -1, err
+	}
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		return -1, err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		errMsg, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		return -1, fmt.Errorf("failed to exec in alloc %q: %s", alloc.ID, errMsg)
+	}
+
+	var lock sync.Mutex
+	enc := json.NewEncoder(conn)
+	send := func(frame *execFrame) error {
+		lock.Lock()
+		defer lock.Unlock()
+		return enc.Encode(frame)
+	}
+	doneCh := make(chan struct{})
+	defer close(doneCh)
+
+	// Relay the input and the terminal size changes
+	if opts.Stdin != nil {
+		go func() {
+			buf := make([]byte, 32*1024)
+			for {
+				n, err := opts.Stdin.Read(buf)
+				if n > 0 {
+					data := make([]byte, n)
+					copy(data, buf[:n])
+					if send(&execFrame{Stdin: data}) != nil {
+						return
+					}
+				}
+				if err != nil {
+					send(&execFrame{StdinClosed: true})
+					return
+				}
+			}
+		}()
+	}
+	if opts.ResizeCh != nil {
+		go func() {
+			for {
+				select {
+				case size, ok := <-opts.ResizeCh:
+					if !ok {
+						return
+					}
+					if send(&execFrame{TTYSize: &size}) != nil {
+						return
+					}
+				case <-doneCh:
+					return
+				}
+			}
+		}()
+	}
+
+	// Relay the output until the command exits
+	dec := json.NewDecoder(reader)
+	for {
+		var frame execFrame
+		if err := dec.Decode(&frame); err != nil {
+			return -1, fmt.Errorf("exec session ended unexpectedly: %v", err)
+		}
+		if len(frame.Stdout) != 0 && opts.Stdout != nil {
+			opts.Stdout.Write(frame.Stdout)
+		}
+		if len(frame.Stderr) != 0 && opts.Stderr != nil {
+			opts.Stderr.Write(frame.Stderr)
+		}
+		if frame.Exited {
+			if frame.Error != "" {
+				return frame.ExitCode, fmt.Errorf(frame.Error)
+			}
+			return frame.ExitCode, nil
+		}
+	}
+}
+
+// nodeHTTPAddr returns the HTTP address of the client the allocation is
+// running on.
+func (a *Allocations) nodeHTTPAddr(alloc *Allocation) (string, error) {
+	node, _, err := a.client.Nodes().Info(alloc.NodeID, &QueryOptions{})
+	if err != nil {
+		return "", err
+	}
+	if node.HTTPAddr == "" {
+		return "", fmt.Errorf("http addr of the node where alloc %q is running is not advertised", alloc.ID)
+	}
+	return node.HTTPAddr, nil
+}
+
+// clientAllocRequest makes a request to an allocation endpoint of the client
+// the allocation is running on.
+func (a *Allocations) clientAllocRequest(alloc *Allocation, action string, v url.Values) error {
+	addr, err := a.nodeHTTPAddr(alloc)
+	if err != nil {
+		return err
+	}
+	u := &url.URL{
+		Scheme:   "http",
+		Host:     addr,
+		Path:     fmt.Sprintf("/v1/client/allocation/%s/%s", alloc.ID, action),
+		RawQuery: v.Encode(),
+	}
+	req := &http.Request{
+		Method: "PUT",
+		URL:    u,
+	}
+	c := http.Client{}
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		errMsg, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("failed to %s alloc %q: %s", action, alloc.ID, errMsg)
+	}
+	return nil
+}
+
 // Allocation is used for serialization of allocations.
 type Allocation struct {
 	ID                 string
@@ -58,6 +289,7 @@ type Allocation struct {
 	ClientStatus       string
 	ClientDescription  string
 	TaskStates         map[string]*TaskState
+	PreviousAllocation string
 	CreateIndex        uint64
 	ModifyIndex        uint64
 	CreateTime         int64
@@ -116,23 +116,97 @@ func (j *Jobs) PeriodicForce(jobID string, q *WriteOptions) (string, *WriteMeta,
 	return resp.EvalID, wm, nil
 }
 
+// PeriodicHistory is used to query the launch history of a periodic job,
+// newest first.
+func (j *Jobs) PeriodicHistory(jobID string, q *QueryOptions) ([]*PeriodicLaunchRecord, *QueryMeta, error) {
+	var resp []*PeriodicLaunchRecord
+	qm, err := j.client.query("/v1/job/"+jobID+"/periodic/history", &resp, q)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, qm, nil
+}
+
+// Scale is used to change the count of a task group of the job and returns
+// the ID of the evaluation created, if any.
+func (j *Jobs) Scale(jobID string, req *ScaleRequest, q *WriteOptions) (string, *WriteMeta, error) {
+	var resp registerJobResponse
+	wm, err := j.client.write("/v1/job/"+jobID+"/scale", req, &resp, q)
+	if err != nil {
+		return "", nil, err
+	}
+	return resp.EvalID, wm, nil
+}
+
+// ScalingEvents is used to query the scaling events of a job.
+func (j *Jobs) ScalingEvents(jobID string, q *QueryOptions) ([]*ScalingEvent, *QueryMeta, error) {
+	var resp []*ScalingEvent
+	qm, err := j.client.query("/v1/job/"+jobID+"/scale", &resp, q)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, qm, nil
+}
+
 // periodicForceResponse is used to deserialize a force response
 type periodicForceResponse struct {
 	EvalID string
 }
 
+// ScaleRequest is used to scale a task group of a job.
+type ScaleRequest struct {
+	TaskGroup string
+	Count     int
+
+	// If EnforceIndex is set, the job is only scaled if its JobModifyIndex
+	// matches.
+	EnforceIndex   bool
+	JobModifyIndex uint64
+
+	// Message and Meta are recorded in the scaling event.
+	Message string
+	Meta    map[string]string
+}
+
+// ScalingEvent is a change to the count of a task group.
+type ScalingEvent struct {
+	ID            string
+	JobID         string
+	TaskGroup     string
+	PreviousCount int
+	Count         int
+	Message       string
+	Meta          map[string]string
+	EvalID        string
+	Time          time.Time
+	CreateIndex   uint64
+}
+
 // UpdateStrategy is for serializing update strategy for a job.
 type UpdateStrategy struct {
 	Stagger     time.Duration
 	MaxParallel int
 }
 
+// PeriodicLaunchRecord is a single launch of a periodic job.
+type PeriodicLaunchRecord struct {
+	ParentID    string
+	ChildID     string
+	EvalID      string
+	Launch      time.Time
+	Status      string
+	CreateIndex uint64
+	ModifyIndex uint64
+}
+
 // PeriodicConfig is for serializing periodic config for a job.
 type PeriodicConfig struct {
 	Enabled         bool
 	Spec            string
 	SpecType        string
 	ProhibitOverlap bool
+	TimeZone        string
+	CatchUp         string
 }
 
 // Job is used to serialize a job.
@@ -153,6 +227,7 @@ type Job struct {
 	StatusDescription string
 	CreateIndex       uint64
 	ModifyIndex       uint64
+	JobModifyIndex    uint64
 }
 
 // JobListStub is used to return a subset of information about
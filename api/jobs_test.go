@@ -297,6 +297,56 @@ func TestJobs_ForceEvaluate(t *testing.T) {
 	t.Fatalf("evaluation %q missing", evalID)
 }
 
+func TestJobs_Scale(t *testing.T) {
+	c, s := makeClient(t, nil, nil)
+	defer s.Stop()
+	jobs := c.Jobs()
+
+	// Create a new job
+	_, wm, err := jobs.Register(testJob(), nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertWriteMeta(t, wm)
+
+	job, _, err := jobs.Info("job1", nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Scale the task group
+	req := &ScaleRequest{
+		TaskGroup:      "group1",
+		Count:          3,
+		EnforceIndex:   true,
+		JobModifyIndex: job.JobModifyIndex,
+		Message:        "scale up",
+	}
+	evalID, wm, err := jobs.Scale("job1", req, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertWriteMeta(t, wm)
+	if evalID == "" {
+		t.Fatalf("missing eval ID")
+	}
+
+	// Scaling again with the stale index fails
+	if _, _, err := jobs.Scale("job1", req, nil); err == nil {
+		t.Fatalf("expected error with stale index")
+	}
+
+	// Query the scaling events
+	events, qm, err := jobs.ScalingEvents("job1", nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertQueryMeta(t, qm)
+	if len(events) != 1 || events[0].Count != 3 || events[0].PreviousCount != 1 {
+		t.Fatalf("bad: %#v", events)
+	}
+}
+
 func TestJobs_PeriodicForce(t *testing.T) {
 	c, s := makeClient(t, nil, nil)
 	defer s.Stop()
@@ -86,10 +86,18 @@ type Node struct {
 	Drain             bool
 	Status            string
 	StatusDescription string
+	HostVolumes       map[string]*HostVolume
 	CreateIndex       uint64
 	ModifyIndex       uint64
 }
 
+// HostVolume is a host directory a node exposes to tasks.
+type HostVolume struct {
+	Name     string
+	Path     string
+	ReadOnly bool
+}
+
 // NodeListStub is a subset of information returned during
 // node list operations.
 type NodeListStub struct {
@@ -0,0 +1,39 @@
+package api
+
+// Operator is used to query the internals of the cluster.
+type Operator struct {
+	client *Client
+}
+
+// Operator returns a handle on the operator endpoints.
+func (c *Client) Operator() *Operator {
+	return &Operator{client: c}
+}
+
+// EvalQueueStats is the depth of a queue of evaluations.
+type EvalQueueStats struct {
+	Ready   int
+	Unacked int
+	Weight  int
+}
+
+// SchedulerQueue is the depth of the evaluation queues of the leader.
+type SchedulerQueue struct {
+	TotalReady   int
+	TotalUnacked int
+	TotalBlocked int
+	TotalWaiting int
+	ByScheduler  map[string]*EvalQueueStats
+	ByTenant     map[string]*EvalQueueStats
+}
+
+// SchedulerQueue is used to query the depth of the evaluation queues by
+// scheduler type and by tenant.
+func (o *Operator) SchedulerQueue(q *QueryOptions) (*SchedulerQueue, *QueryMeta, error) {
+	var resp SchedulerQueue
+	qm, err := o.client.query("/v1/operator/scheduler/queue", &resp, q)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &resp, qm, nil
+}
@@ -0,0 +1,20 @@
+package api
+
+import (
+	"testing"
+)
+
+func TestOperator_SchedulerQueue(t *testing.T) {
+	c, s := makeClient(t, nil, nil)
+	defer s.Stop()
+	o := c.Operator()
+
+	queue, qm, err := o.SchedulerQueue(nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertQueryMeta(t, qm)
+	if queue.ByScheduler == nil {
+		t.Fatalf("bad: %#v", queue)
+	}
+}
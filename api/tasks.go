@@ -35,14 +35,34 @@ type Service struct {
 	Checks    []ServiceCheck
 }
 
+// AllocAffinity places the allocations of a task group relative to the
+// allocations of other jobs.
+type AllocAffinity struct {
+	Type  string
+	Mode  string
+	Job   string
+	Group string
+	Meta  map[string]string
+}
+
+// ScalingPolicy bounds the count a task group can be scaled to.
+type ScalingPolicy struct {
+	Min int
+	Max int
+}
+
 // TaskGroup is the unit of scheduling.
 type TaskGroup struct {
 	Name          string
 	Count         int
 	Constraints   []*Constraint
+	Affinities    []*AllocAffinity
+	Scaling       *ScalingPolicy
 	Tasks         []*Task
 	RestartPolicy *RestartPolicy
 	Meta          map[string]string
+	Volumes       map[string]*VolumeRequest
+	EphemeralDisk *EphemeralDisk
 }
 
 // NewTaskGroup creates a new TaskGroup.
@@ -59,6 +79,12 @@ func (g *TaskGroup) Constrain(c *Constraint) *TaskGroup {
 	return g
 }
 
+// Affinity is used to add an allocation affinity to a task group.
+func (g *TaskGroup) Affinity(a *AllocAffinity) *TaskGroup {
+	g.Affinities = append(g.Affinities, a)
+	return g
+}
+
 // AddMeta is used to add a meta k/v pair to a task group
 func (g *TaskGroup) SetMeta(key, val string) *TaskGroup {
 	if g.Meta == nil {
@@ -68,6 +94,21 @@ func (g *TaskGroup) SetMeta(key, val string) *TaskGroup {
 	return g
 }
 
+// AddVolume adds a volume the tasks of the group can mount.
+func (g *TaskGroup) AddVolume(v *VolumeRequest) *TaskGroup {
+	if g.Volumes == nil {
+		g.Volumes = make(map[string]*VolumeRequest)
+	}
+	g.Volumes[v.Name] = v
+	return g
+}
+
+// RequireDisk sets the ephemeral disk of the task group.
+func (g *TaskGroup) RequireDisk(disk *EphemeralDisk) *TaskGroup {
+	g.EphemeralDisk = disk
+	return g
+}
+
 // AddTask is used to add a new task to a task group.
 func (g *TaskGroup) AddTask(t *Task) *TaskGroup {
 	g.Tasks = append(g.Tasks, t)
@@ -82,16 +123,80 @@ type LogConfig struct {
 
 // Task is a single process in a task group.
 type Task struct {
-	Name        string
-	Driver      string
-	Config      map[string]interface{}
-	Constraints []*Constraint
-	Env         map[string]string
-	Services    []Service
-	Resources   *Resources
-	Meta        map[string]string
-	KillTimeout time.Duration
-	LogConfig   *LogConfig
+	Name          string
+	Driver        string
+	Config        map[string]interface{}
+	Constraints   []*Constraint
+	Env           map[string]string
+	Services      []Service
+	Resources     *Resources
+	Meta          map[string]string
+	KillTimeout   time.Duration
+	KillSignal    string
+	ShutdownDelay time.Duration
+	LogConfig     *LogConfig
+	Artifacts     []*TaskArtifact
+	Templates     []*Template
+	VolumeMounts  []*VolumeMount
+	Lifecycle     *TaskLifecycle
+	Leader        bool
+}
+
+// TaskArtifact is used to download artifacts before running a task.
+type TaskArtifact struct {
+	GetterSource  string
+	GetterOptions map[string]string
+	GetterHeaders map[string]string
+	GetterMode    string
+	RelativeDest  string
+}
+
+// Template is used to render a file into the task directory.
+type Template struct {
+	SourcePath   string
+	DestPath     string
+	EmbeddedTmpl string
+	ChangeMode   string
+	ChangeSignal string
+	Splay        time.Duration
+	Perms        string
+}
+
+// EphemeralDisk is the disk shared by the tasks of a task group. A sticky
+// disk is kept on the same node across updates of the group and a migrating
+// disk is moved to the node of the replacement allocation.
+type EphemeralDisk struct {
+	Sticky  bool
+	SizeMB  int
+	Migrate bool
+}
+
+// VolumeRequest is a volume requested by a task group.
+type VolumeRequest struct {
+	Name     string
+	Type     string
+	Source   string
+	ReadOnly bool
+}
+
+// VolumeMount mounts a task group volume into a task.
+type VolumeMount struct {
+	Volume      string
+	Destination string
+	ReadOnly    bool
+}
+
+const (
+	TaskLifecycleHookPrestart  = "prestart"
+	TaskLifecycleHookPoststart = "poststart"
+	TaskLifecycleHookPoststop  = "poststop"
+)
+
+// TaskLifecycle determines when a task is run relative to the main tasks of
+// its task group.
+type TaskLifecycle struct {
+	Hook    string
+	Sidecar bool
 }
 
 // NewTask creates and initializes a new Task.
@@ -139,6 +244,31 @@ func (t *Task) SetLogConfig(l *LogConfig) *Task {
 	return t
 }
 
+// AddArtifact adds an artifact to download before running the task.
+func (t *Task) AddArtifact(a *TaskArtifact) *Task {
+	t.Artifacts = append(t.Artifacts, a)
+	return t
+}
+
+// AddTemplate adds a template to render before running the task.
+func (t *Task) AddTemplate(tmpl *Template) *Task {
+	t.Templates = append(t.Templates, tmpl)
+	return t
+}
+
+// AddVolumeMount mounts a volume of the task group into the task.
+func (t *Task) AddVolumeMount(vm *VolumeMount) *Task {
+	t.VolumeMounts = append(t.VolumeMounts, vm)
+	return t
+}
+
+// SetLifecycle sets when the task is run relative to the main tasks of its
+// task group.
+func (t *Task) SetLifecycle(l *TaskLifecycle) *Task {
+	t.Lifecycle = l
+	return t
+}
+
 // TaskState tracks the current state of a task and events that caused state
 // transistions.
 type TaskState struct {
@@ -147,20 +277,54 @@ type TaskState struct {
 }
 
 const (
-	TaskDriverFailure = "Driver Failure"
-	TaskStarted       = "Started"
-	TaskTerminated    = "Terminated"
-	TaskKilled        = "Killed"
+	TaskDriverFailure          = "Driver Failure"
+	TaskStarted                = "Started"
+	TaskTerminated             = "Terminated"
+	TaskKilled                 = "Killed"
+	TaskDownloadingArtifacts   = "Downloading Artifacts"
+	TaskArtifactDownloadFailed = "Failed Artifact Download"
+	TaskSetupFailure           = "Setup Failure"
+	TaskRestartSignal          = "Restart Signaled"
+	TaskSignaling              = "Signaling"
+	TaskSiblingFailed          = "Sibling Task Failed"
+	TaskSiblingExited          = "Sibling Task Exited"
+	TaskRestarting             = "Restarting"
+	TaskNotRestarting          = "Not Restarting"
+	TaskKilling                = "Killing"
+	TaskDiskExceeded           = "Disk Resources Exceeded"
+	TaskMemoryExceeded         = "Memory Resources Exceeded"
+	TaskPipelineStep           = "Pipeline Step"
+	TaskPipelineArtifact       = "Pipeline Artifact"
+	TaskPullingImage           = "Pulling Image"
+	TaskImagePullStalled       = "Image Pull Stalled"
 )
 
 // TaskEvent is an event that effects the state of a task and contains meta-data
 // appropriate to the events type.
 type TaskEvent struct {
-	Type        string
-	Time        int64
-	DriverError string
-	ExitCode    int
-	Signal      int
-	Message     string
-	KillError   string
+	Type             string
+	Time             int64
+	DriverError      string
+	ExitCode         int
+	Signal           int
+	Message          string
+	KillError        string
+	DownloadError    string
+	SetupError       string
+	RestartReason    string
+	TaskSignal       string
+	TaskSignalReason string
+	FailedSibling    string
+	ExitedSibling    string
+	StartDelay       int64
+	KillTimeout      time.Duration
+	DiskLimit        int64
+	DiskSize         int64
+	MemoryLimit      int64
+	StepName         string
+	StepDuration     time.Duration
+	Artifact         string
+	Image            string
+	PullProgress     string
+	PullTimeout      time.Duration
 }
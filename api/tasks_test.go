@@ -49,6 +49,22 @@ func TestTaskGroup_Constrain(t *testing.T) {
 	}
 }
 
+func TestTaskGroup_Affinity(t *testing.T) {
+	grp := NewTaskGroup("grp1", 1)
+
+	// Add an affinity to the group
+	aff := &AllocAffinity{Type: "colocate", Job: "app"}
+	out := grp.Affinity(aff)
+	if out != grp {
+		t.Fatalf("expected: %#v, got: %#v", grp, out)
+	}
+
+	expect := []*AllocAffinity{aff}
+	if !reflect.DeepEqual(grp.Affinities, expect) {
+		t.Fatalf("expect: %#v, got: %#v", expect, grp.Affinities)
+	}
+}
+
 func TestTaskGroup_SetMeta(t *testing.T) {
 	grp := NewTaskGroup("grp1", 1)
 
@@ -217,3 +233,124 @@ func TestTask_Constrain(t *testing.T) {
 		t.Fatalf("expect: %#v, got: %#v", expect, task.Constraints)
 	}
 }
+
+func TestTask_AddArtifact(t *testing.T) {
+	task := NewTask("task1", "exec")
+
+	// Add an artifact to the task
+	artifact := &TaskArtifact{
+		GetterSource: "http://foo.com/bar",
+		RelativeDest: "local",
+	}
+	out := task.AddArtifact(artifact)
+	if n := len(task.Artifacts); n != 1 {
+		t.Fatalf("expected 1 artifact, got: %d", n)
+	}
+
+	// Check that the task was returned
+	if out != task {
+		t.Fatalf("expected: %#v, got: %#v", task, out)
+	}
+
+	if !reflect.DeepEqual(task.Artifacts, []*TaskArtifact{artifact}) {
+		t.Fatalf("bad: %#v", task.Artifacts)
+	}
+}
+
+func TestTask_AddTemplate(t *testing.T) {
+	task := NewTask("task1", "exec")
+
+	// Add a template to the task
+	tmpl := &Template{
+		EmbeddedTmpl: `{{ key "foo" }}`,
+		DestPath:     "local/foo.conf",
+		ChangeMode:   "restart",
+	}
+	out := task.AddTemplate(tmpl)
+	if n := len(task.Templates); n != 1 {
+		t.Fatalf("expected 1 template, got: %d", n)
+	}
+
+	// Check that the task was returned
+	if out != task {
+		t.Fatalf("expected: %#v, got: %#v", task, out)
+	}
+
+	if !reflect.DeepEqual(task.Templates, []*Template{tmpl}) {
+		t.Fatalf("bad: %#v", task.Templates)
+	}
+}
+
+func TestTaskGroup_AddVolume(t *testing.T) {
+	grp := NewTaskGroup("grp1", 1)
+
+	// Add a volume to the group
+	v := &VolumeRequest{
+		Name:   "certs",
+		Type:   "host",
+		Source: "ca-certificates",
+	}
+	out := grp.AddVolume(v)
+	if out != grp {
+		t.Fatalf("expected: %#v, got: %#v", grp, out)
+	}
+
+	expect := map[string]*VolumeRequest{"certs": v}
+	if !reflect.DeepEqual(grp.Volumes, expect) {
+		t.Fatalf("expect: %#v, got: %#v", expect, grp.Volumes)
+	}
+}
+
+func TestTaskGroup_RequireDisk(t *testing.T) {
+	grp := NewTaskGroup("grp1", 1)
+
+	// Set the ephemeral disk of the group
+	disk := &EphemeralDisk{
+		SizeMB: 150,
+		Sticky: true,
+	}
+	out := grp.RequireDisk(disk)
+	if out != grp {
+		t.Fatalf("expected: %#v, got: %#v", grp, out)
+	}
+	if !reflect.DeepEqual(grp.EphemeralDisk, disk) {
+		t.Fatalf("expect: %#v, got: %#v", disk, grp.EphemeralDisk)
+	}
+}
+
+func TestTask_AddVolumeMount(t *testing.T) {
+	task := NewTask("task1", "exec")
+
+	// Mount a volume into the task
+	vm := &VolumeMount{
+		Volume:      "certs",
+		Destination: "/etc/ssl/certs",
+		ReadOnly:    true,
+	}
+	out := task.AddVolumeMount(vm)
+	if out != task {
+		t.Fatalf("expected: %#v, got: %#v", task, out)
+	}
+
+	if !reflect.DeepEqual(task.VolumeMounts, []*VolumeMount{vm}) {
+		t.Fatalf("bad: %#v", task.VolumeMounts)
+	}
+}
+
+func TestTask_SetLifecycle(t *testing.T) {
+	task := NewTask("task1", "exec")
+
+	// Run the task before the main tasks
+	l := &TaskLifecycle{
+		Hook:    TaskLifecycleHookPrestart,
+		Sidecar: true,
+	}
+	out := task.SetLifecycle(l)
+	if out != task {
+		t.Fatalf("expected: %#v, got: %#v", task, out)
+	}
+
+	if !reflect.DeepEqual(task.Lifecycle, l) {
+		t.Fatalf("bad: %#v", task.Lifecycle)
+	}
+}
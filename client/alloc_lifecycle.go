@@ -0,0 +1,269 @@
+package client
+
+import (
+	"github.com/hashicorp/nomad/nomad/structs"
+)
+
+// runTaskLifecycle starts the tasks of the task group in lifecycle order:
+// prestart tasks first, the main tasks once the prestart tasks that aren't
+// sidecars have completed, poststart tasks once the main tasks are running
+// and poststop tasks once the main tasks have exited. If the group has a
+// leader task, the other tasks are stopped once the leader exits. Closing
+// stopCh stops the running tasks, after which the poststop tasks are run.
+// doneCh is closed once all the tasks have stopped.
+func (r *AllocRunner) runTaskLifecycle(tg *structs.TaskGroup, stopCh, doneCh chan struct{}) {
+	defer close(doneCh)
+
+	var prestart, main, poststart, poststop []*structs.Task
+	for _, task := range tg.Tasks {
+		switch task.LifecycleHook() {
+		case structs.TaskLifecycleHookPrestart:
+			prestart = append(prestart, task)
+		case structs.TaskLifecycleHookPoststart:
+			poststart = append(poststart, task)
+		case structs.TaskLifecycleHookPoststop:
+			poststop = append(poststop, task)
+		default:
+			main = append(main, task)
+		}
+	}
+
+	// running tracks the started runners that are stopped when the main
+	// tasks exit or the allocation is stopped.
+	var running, sidecars []*TaskRunner
+	names := make(map[*TaskRunner]string)
+	start := func(tasks []*structs.Task) []*TaskRunner {
+		runners := make([]*TaskRunner, 0, len(tasks))
+		for _, task := range tasks {
+			tr := r.startTaskRunner(task)
+			runners = append(runners, tr)
+			running = append(running, tr)
+			names[tr] = task.Name
+			if task.IsSidecar() {
+				sidecars = append(sidecars, tr)
+			}
+		}
+		return runners
+	}
+
+	// Run the prestart tasks and wait for the ones that aren't sidecars to
+	// complete.
+	var initRunners []*TaskRunner
+	for i, tr := range start(prestart) {
+		if !prestart[i].IsSidecar() {
+			initRunners = append(initRunners, tr)
+		}
+	}
+	if !r.waitTaskRunners(initRunners, stopCh) {
+		r.stopTaskRunners(running)
+		return
+	}
+
+	// Don't start the main tasks if a prestart task failed
+	for _, task := range prestart {
+		if task.IsSidecar() || !r.taskFailed(task.Name) {
+			continue
+		}
+		r.logger.Printf("[ERR] client: prestart task %q of alloc %q failed, not starting the remaining tasks",
+			task.Name, r.alloc.ID)
+		r.stopTaskRunners(running)
+		r.failUnstartedTasks(tg, task.Name)
+		return
+	}
+
+	// Start the main tasks and the poststart tasks once the main tasks are
+	// running.
+	mainRunners := start(main)
+	if len(poststart) != 0 {
+		if !r.waitTasksStarted(main, stopCh) {
+			r.stopTaskRunners(running)
+			r.runPoststop(poststop)
+			return
+		}
+		start(poststart)
+	}
+
+	// Wait for the main tasks to exit, or only for the leader if the group
+	// has one, and stop the sidecars
+	waitFor := mainRunners
+	leader := tg.LookupLeader()
+	if leader != nil {
+		waitFor = nil
+		for i, task := range main {
+			if task.Name == leader.Name {
+				waitFor = append(waitFor, mainRunners[i])
+			}
+		}
+	}
+	if !r.waitTaskRunners(waitFor, stopCh) {
+		r.stopTaskRunners(running)
+		r.runPoststop(poststop)
+		return
+	}
+	if leader != nil {
+		r.stopSiblings(leader.Name, running, names)
+	}
+	r.stopTaskRunners(sidecars)
+	if !r.waitTaskRunners(running, stopCh) {
+		r.stopTaskRunners(running)
+	}
+	r.runPoststop(poststop)
+}
+
+// runPoststop runs the poststop tasks to completion. They are killed if the
+// allocation is destroyed while they are running and not started at all if it
+// already is.
+func (r *AllocRunner) runPoststop(tasks []*structs.Task) {
+	if len(tasks) == 0 {
+		return
+	}
+
+	r.destroyLock.Lock()
+	destroyed := r.destroy
+	r.destroyLock.Unlock()
+	if destroyed {
+		return
+	}
+
+	runners := make([]*TaskRunner, 0, len(tasks))
+	for _, task := range tasks {
+		runners = append(runners, r.startTaskRunner(task))
+	}
+	if !r.waitTaskRunners(runners, r.destroyCh) {
+		r.stopTaskRunners(runners)
+	}
+}
+
+// stopSiblings stops the runners of the tasks that are still running after
+// the leader task exited.
+func (r *AllocRunner) stopSiblings(leader string, runners []*TaskRunner, names map[*TaskRunner]string) {
+	var siblings []*TaskRunner
+	for _, tr := range runners {
+		if tr == nil || names[tr] == leader {
+			continue
+		}
+		select {
+		case <-tr.WaitCh():
+			continue
+		default:
+		}
+
+		event := structs.NewTaskEvent(structs.TaskSiblingExited).SetExitedSibling(leader)
+		r.addTaskEvent(names[tr], event)
+		siblings = append(siblings, tr)
+	}
+
+	if len(siblings) != 0 {
+		r.logger.Printf("[DEBUG] client: leader task %q of alloc %q exited, stopping %d sibling tasks",
+			leader, r.alloc.ID, len(siblings))
+	}
+	r.stopTaskRunners(siblings)
+}
+
+// startTaskRunner starts the runner of a task and returns it. The runners of
+// restored tasks are already running and are returned as is, which is nil if
+// the task had already finished.
+func (r *AllocRunner) startTaskRunner(task *structs.Task) *TaskRunner {
+	r.taskLock.Lock()
+	defer r.taskLock.Unlock()
+
+	if _, ok := r.restored[task.Name]; ok {
+		return r.tasks[task.Name]
+	}
+
+	tr := NewTaskRunner(r.logger, r.config, r.setTaskState, r.ctx, r.Alloc(),
+		task.Copy(), r.consulService)
+	r.tasks[task.Name] = tr
+	go tr.Run()
+	return tr
+}
+
+// waitTaskRunners waits for the runners to exit. It returns false if stopCh
+// is closed first.
+func (r *AllocRunner) waitTaskRunners(runners []*TaskRunner, stopCh <-chan struct{}) bool {
+	for _, tr := range runners {
+		if tr == nil {
+			continue
+		}
+		select {
+		case <-tr.WaitCh():
+		case <-stopCh:
+			return false
+		}
+	}
+	return true
+}
+
+// stopTaskRunners destroys the runners and waits for them to exit.
+func (r *AllocRunner) stopTaskRunners(runners []*TaskRunner) {
+	for _, tr := range runners {
+		if tr != nil {
+			tr.Destroy()
+		}
+	}
+	for _, tr := range runners {
+		if tr != nil {
+			<-tr.WaitCh()
+		}
+	}
+}
+
+// waitTasksStarted waits for the tasks to be running or to have exited. It
+// returns false if stopCh is closed first.
+func (r *AllocRunner) waitTasksStarted(tasks []*structs.Task, stopCh <-chan struct{}) bool {
+	for {
+		started := true
+		r.taskStatusLock.RLock()
+		for _, task := range tasks {
+			state, ok := r.taskStates[task.Name]
+			if !ok || state.State == structs.TaskStatePending {
+				started = false
+				break
+			}
+		}
+		r.taskStatusLock.RUnlock()
+		if started {
+			return true
+		}
+
+		select {
+		case <-r.taskStateUpdateCh:
+		case <-stopCh:
+			return false
+		}
+	}
+}
+
+// taskFailed returns whether the task has exited unsuccessfully.
+func (r *AllocRunner) taskFailed(name string) bool {
+	r.taskStatusLock.RLock()
+	defer r.taskStatusLock.RUnlock()
+
+	state, ok := r.taskStates[name]
+	if !ok || state.State != structs.TaskStateDead || len(state.Events) == 0 {
+		return false
+	}
+	// The restart decision is recorded after the task terminated
+	last := state.Events[len(state.Events)-1]
+	if last.Type == structs.TaskNotRestarting && len(state.Events) > 1 {
+		last = state.Events[len(state.Events)-2]
+	}
+	return last.Type != structs.TaskTerminated || last.ExitCode != 0
+}
+
+// failUnstartedTasks marks the tasks that were never started as dead because
+// the sibling task failed.
+func (r *AllocRunner) failUnstartedTasks(tg *structs.TaskGroup, sibling string) {
+	for _, task := range tg.Tasks {
+		r.taskLock.RLock()
+		_, started := r.tasks[task.Name]
+		_, restored := r.restored[task.Name]
+		r.taskLock.RUnlock()
+		if started || restored {
+			continue
+		}
+
+		event := structs.NewTaskEvent(structs.TaskSiblingFailed).SetFailedSibling(sibling)
+		r.setTaskState(task.Name, structs.TaskStateDead, event)
+	}
+}
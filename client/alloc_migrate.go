@@ -0,0 +1,163 @@
+package client
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/nomad/client/allocdir"
+	"github.com/hashicorp/nomad/client/config"
+	"github.com/hashicorp/nomad/nomad/structs"
+)
+
+const (
+	// migrateRetryIntv is the interval on which we retry the RPCs made to
+	// find the previous allocation of a migrating ephemeral disk.
+	migrateRetryIntv = 5 * time.Second
+)
+
+// prevAllocMigrator moves the ephemeral disk of a previous allocation into
+// the alloc dir of its replacement.
+type prevAllocMigrator interface {
+	// Migrate blocks until the previous allocation has stopped and then
+	// moves its data dir into dest. It returns early if abortCh is closed.
+	Migrate(dest *allocdir.AllocDir, abortCh <-chan struct{}) error
+}
+
+// localPrevAlloc migrates the data dir of a previous allocation that ran on
+// this client.
+type localPrevAlloc struct {
+	runner *AllocRunner
+}
+
+func (p *localPrevAlloc) Migrate(dest *allocdir.AllocDir, abortCh <-chan struct{}) error {
+	select {
+	case <-p.runner.tasksStoppedCh:
+	case <-abortCh:
+		return fmt.Errorf("aborted waiting for previous alloc %q to stop", p.runner.alloc.ID)
+	}
+
+	p.runner.ctxLock.Lock()
+	defer p.runner.ctxLock.Unlock()
+	if p.runner.ctx == nil || p.runner.ctx.AllocDir == nil {
+		return fmt.Errorf("previous alloc %q has no alloc dir", p.runner.alloc.ID)
+	}
+	return dest.Move(p.runner.ctx.AllocDir)
+}
+
+// remotePrevAlloc migrates the data dir of a previous allocation that ran on
+// another client by downloading a snapshot of it from that client.
+type remotePrevAlloc struct {
+	allocID string
+	region  string
+	rpc     config.RPCHandler
+	logger  *log.Logger
+}
+
+func (p *remotePrevAlloc) Migrate(dest *allocdir.AllocDir, abortCh <-chan struct{}) error {
+	node, err := p.waitForStop(abortCh)
+	if err != nil {
+		return err
+	}
+	if node.Status == structs.NodeStatusDown {
+		return fmt.Errorf("node %q of previous alloc %q is down", node.ID, p.allocID)
+	}
+	if node.HTTPAddr == "" {
+		return fmt.Errorf("node %q of previous alloc %q has no http address", node.ID, p.allocID)
+	}
+
+	url := fmt.Sprintf("http://%s/v1/client/allocation/%s/snapshot", node.HTTPAddr, p.allocID)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	req.Cancel = abortCh
+
+	p.logger.Printf("[DEBUG] client: migrating data of previous alloc %q from node %q", p.allocID, node.ID)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch snapshot of previous alloc %q: %v", p.allocID, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch snapshot of previous alloc %q: unexpected status %d",
+			p.allocID, resp.StatusCode)
+	}
+	return dest.RestoreSnapshot(resp.Body)
+}
+
+// waitForStop blocks until the previous allocation is no longer running and
+// returns the node it was running on.
+func (p *remotePrevAlloc) waitForStop(abortCh <-chan struct{}) (*structs.Node, error) {
+	req := structs.AllocSpecificRequest{
+		AllocID: p.allocID,
+		QueryOptions: structs.QueryOptions{
+			Region:     p.region,
+			AllowStale: true,
+		},
+	}
+
+	var nodeID string
+	for {
+		var resp structs.SingleAllocResponse
+		if err := p.rpc.RPC("Alloc.GetAlloc", &req, &resp); err != nil {
+			p.logger.Printf("[ERR] client: failed to query previous alloc %q: %v", p.allocID, err)
+			if err := p.wait(abortCh); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if resp.Alloc == nil {
+			return nil, fmt.Errorf("previous alloc %q not found", p.allocID)
+		}
+
+		status := resp.Alloc.ClientStatus
+		if status == structs.AllocClientStatusDead || status == structs.AllocClientStatusFailed {
+			nodeID = resp.Alloc.NodeID
+			break
+		}
+
+		if resp.Index > req.MinQueryIndex {
+			req.MinQueryIndex = resp.Index
+		}
+		select {
+		case <-abortCh:
+			return nil, fmt.Errorf("aborted waiting for previous alloc %q to stop", p.allocID)
+		default:
+		}
+	}
+
+	nodeReq := structs.NodeSpecificRequest{
+		NodeID: nodeID,
+		QueryOptions: structs.QueryOptions{
+			Region:     p.region,
+			AllowStale: true,
+		},
+	}
+	for {
+		var resp structs.SingleNodeResponse
+		if err := p.rpc.RPC("Node.GetNode", &nodeReq, &resp); err != nil {
+			p.logger.Printf("[ERR] client: failed to query node %q of previous alloc %q: %v",
+				nodeID, p.allocID, err)
+			if err := p.wait(abortCh); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if resp.Node == nil {
+			return nil, fmt.Errorf("node %q of previous alloc %q not found", nodeID, p.allocID)
+		}
+		return resp.Node, nil
+	}
+}
+
+// wait waits for the retry interval unless abortCh is closed first.
+func (p *remotePrevAlloc) wait(abortCh <-chan struct{}) error {
+	select {
+	case <-time.After(migrateRetryIntv):
+		return nil
+	case <-abortCh:
+		return fmt.Errorf("aborted migrating previous alloc %q", p.allocID)
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/hashicorp/nomad/client/allocdir"
 	"github.com/hashicorp/nomad/client/config"
 	"github.com/hashicorp/nomad/client/driver"
+	cstructs "github.com/hashicorp/nomad/client/driver/structs"
 	"github.com/hashicorp/nomad/nomad/structs"
 )
 
@@ -23,6 +24,10 @@ const (
 	// update will transfer all past state information. If not other transistion
 	// has occured up to this limit, we will send to the server.
 	taskReceivedSyncLimit = 30 * time.Second
+
+	// allocDiskWatchInterval is how often the disk usage of an allocation is
+	// compared to the size of its ephemeral disk.
+	allocDiskWatchInterval = 30 * time.Second
 )
 
 // AllocStateUpdater is used to update the status of an allocation
@@ -56,8 +61,23 @@ type AllocRunner struct {
 	taskReceivedTimer *time.Timer
 	taskStatusLock    sync.RWMutex
 
+	// taskStateUpdateCh is notified when the state of a task changes
+	taskStateUpdateCh chan struct{}
+
 	updateCh chan *structs.Allocation
 
+	// prevAlloc is used to migrate the ephemeral disk of the previous
+	// allocation before starting the tasks. It may be nil.
+	prevAlloc prevAllocMigrator
+
+	// tasksStoppedCh is closed once the tasks of the allocation have stopped
+	// and its alloc dir is no longer in use by them.
+	tasksStoppedCh chan struct{}
+
+	// diskWatchInterval is how often the disk usage of the allocation is
+	// checked.
+	diskWatchInterval time.Duration
+
 	destroy     bool
 	destroyCh   chan struct{}
 	destroyLock sync.Mutex
@@ -78,18 +98,21 @@ type allocRunnerState struct {
 func NewAllocRunner(logger *log.Logger, config *config.Config, updater AllocStateUpdater,
 	alloc *structs.Allocation, consulService *ConsulService) *AllocRunner {
 	ar := &AllocRunner{
-		config:        config,
-		updater:       updater,
-		logger:        logger,
-		alloc:         alloc,
-		consulService: consulService,
-		dirtyCh:       make(chan struct{}, 1),
-		tasks:         make(map[string]*TaskRunner),
-		taskStates:    copyTaskStates(alloc.TaskStates),
-		restored:      make(map[string]struct{}),
-		updateCh:      make(chan *structs.Allocation, 64),
-		destroyCh:     make(chan struct{}),
-		waitCh:        make(chan struct{}),
+		config:            config,
+		updater:           updater,
+		logger:            logger,
+		alloc:             alloc,
+		consulService:     consulService,
+		dirtyCh:           make(chan struct{}, 1),
+		tasks:             make(map[string]*TaskRunner),
+		taskStates:        copyTaskStates(alloc.TaskStates),
+		restored:          make(map[string]struct{}),
+		updateCh:          make(chan *structs.Allocation, 64),
+		taskStateUpdateCh: make(chan struct{}, 1),
+		tasksStoppedCh:    make(chan struct{}),
+		diskWatchInterval: allocDiskWatchInterval,
+		destroyCh:         make(chan struct{}),
+		waitCh:            make(chan struct{}),
 	}
 	return ar
 }
@@ -120,19 +143,28 @@ func (r *AllocRunner) RestoreState() error {
 	// Restore the task runners
 	var mErr multierror.Error
 	for name, state := range r.taskStates {
+		// Tasks that were never started, e.g. because they were waiting on
+		// the lifecycle of other tasks, are started by Run.
+		statePath := taskRunnerStateFilePath(r.config.StateDir, r.alloc.ID, name)
+		if state.State == structs.TaskStatePending {
+			if _, err := os.Stat(statePath); os.IsNotExist(err) {
+				continue
+			}
+		}
+
 		// Mark the task as restored.
 		r.restored[name] = struct{}{}
 
-		task := &structs.Task{Name: name}
-		tr := NewTaskRunner(r.logger, r.config, r.setTaskState, r.ctx, r.Alloc(),
-			task, r.consulService)
-		r.tasks[name] = tr
-
 		// Skip tasks in terminal states.
 		if state.State == structs.TaskStateDead {
 			continue
 		}
 
+		task := &structs.Task{Name: name}
+		tr := NewTaskRunner(r.logger, r.config, r.setTaskState, r.ctx, r.Alloc(),
+			task, r.consulService)
+		r.tasks[name] = tr
+
 		if err := tr.RestoreState(); err != nil {
 			r.logger.Printf("[ERR] client: failed to restore state for alloc %s task '%s': %v", r.alloc.ID, name, err)
 			mErr.Errors = append(mErr.Errors, err)
@@ -208,9 +240,40 @@ func (r *AllocRunner) DestroyState() error {
 
 // DestroyContext is used to destroy the context
 func (r *AllocRunner) DestroyContext() error {
+	r.ctxLock.Lock()
+	defer r.ctxLock.Unlock()
+	r.cleanupDrivers()
 	return r.ctx.AllocDir.Destroy()
 }
 
+// cleanupDrivers lets the drivers of the tasks remove the resources they
+// created outside of the alloc dir. Must be called with the ctxLock held.
+func (r *AllocRunner) cleanupDrivers() {
+	r.allocLock.Lock()
+	alloc := r.alloc
+	r.allocLock.Unlock()
+	tg := alloc.Job.LookupTaskGroup(alloc.TaskGroup)
+	if tg == nil {
+		return
+	}
+
+	for _, task := range tg.Tasks {
+		driverCtx := driver.NewDriverContext(task.Name, r.config, r.config.Node, r.logger, nil, nil, nil)
+		d, err := driver.NewDriver(task.Driver, driverCtx)
+		if err != nil {
+			continue
+		}
+		cleaner, ok := d.(driver.CleanupDriver)
+		if !ok {
+			continue
+		}
+		if err := cleaner.Cleanup(r.ctx, task); err != nil {
+			r.logger.Printf("[ERR] client: failed to clean up task %q of alloc %q: %v",
+				task.Name, alloc.ID, err)
+		}
+	}
+}
+
 // copyTaskStates returns a copy of the passed task states.
 func copyTaskStates(states map[string]*structs.TaskState) map[string]*structs.TaskState {
 	copy := make(map[string]*structs.TaskState, len(states))
@@ -230,6 +293,10 @@ func (r *AllocRunner) Alloc() *structs.Allocation {
 		alloc.ClientStatus = r.allocClientStatus
 		alloc.ClientDescription = r.allocClientDescription
 		r.allocLock.Unlock()
+
+		r.taskStatusLock.RLock()
+		alloc.TaskStates = copyTaskStates(r.taskStates)
+		r.taskStatusLock.RUnlock()
 		return alloc
 	}
 	r.allocLock.Unlock()
@@ -238,7 +305,20 @@ func (r *AllocRunner) Alloc() *structs.Allocation {
 	var pending, running, dead, failed bool
 	r.taskStatusLock.RLock()
 	alloc.TaskStates = copyTaskStates(r.taskStates)
-	for _, state := range r.taskStates {
+
+	// The status of an allocation whose task group has a leader task is
+	// determined by the leader alone.
+	states := r.taskStates
+	if alloc.Job != nil {
+		if tg := alloc.Job.LookupTaskGroup(alloc.TaskGroup); tg != nil {
+			if leader := tg.LookupLeader(); leader != nil {
+				if state, ok := r.taskStates[leader.Name]; ok {
+					states = map[string]*structs.TaskState{leader.Name: state}
+				}
+			}
+		}
+	}
+	for _, state := range states {
 		switch state.State {
 		case structs.TaskStateRunning:
 			running = true
@@ -246,9 +326,11 @@ func (r *AllocRunner) Alloc() *structs.Allocation {
 			pending = true
 		case structs.TaskStateDead:
 			last := len(state.Events) - 1
-			if state.Events[last].Type == structs.TaskDriverFailure {
+			switch state.Events[last].Type {
+			case structs.TaskDriverFailure, structs.TaskSiblingFailed,
+				structs.TaskArtifactDownloadFailed, structs.TaskSetupFailure:
 				failed = true
-			} else {
+			default:
 				dead = true
 			}
 		}
@@ -315,6 +397,11 @@ func (r *AllocRunner) setTaskState(taskName, state string, event *structs.TaskEv
 	taskState.State = state
 	r.appendTaskEvent(taskState, event)
 
+	select {
+	case r.taskStateUpdateCh <- struct{}{}:
+	default:
+	}
+
 	// We don't immediately mark ourselves as dirty, since in most cases there
 	// will immediately be another state transistion. This reduces traffic to
 	// the server.
@@ -342,6 +429,23 @@ func (r *AllocRunner) setTaskState(taskName, state string, event *structs.TaskEv
 	}
 }
 
+// addTaskEvent records an event of a task without changing its state.
+func (r *AllocRunner) addTaskEvent(taskName string, event *structs.TaskEvent) {
+	r.taskStatusLock.Lock()
+	defer r.taskStatusLock.Unlock()
+	taskState, ok := r.taskStates[taskName]
+	if !ok {
+		r.logger.Printf("[ERR] client: adding event for unknown task %q", taskName)
+		return
+	}
+	r.appendTaskEvent(taskState, event)
+
+	select {
+	case r.dirtyCh <- struct{}{}:
+	default:
+	}
+}
+
 // appendTaskEvent updates the task status by appending the new event.
 func (r *AllocRunner) appendTaskEvent(state *structs.TaskState, event *structs.TaskEvent) {
 	capacity := 10
@@ -370,10 +474,12 @@ func (r *AllocRunner) Run() {
 	if tg == nil {
 		r.logger.Printf("[ERR] client: alloc '%s' for missing task group '%s'", alloc.ID, alloc.TaskGroup)
 		r.setStatus(structs.AllocClientStatusFailed, fmt.Sprintf("missing task group '%s'", alloc.TaskGroup))
+		close(r.tasksStoppedCh)
 		return
 	}
 
 	// Create the execution context
+	var newAllocDir *allocdir.AllocDir
 	r.ctxLock.Lock()
 	if r.ctx == nil {
 		allocDir := allocdir.NewAllocDir(filepath.Join(r.config.AllocDir, r.alloc.ID))
@@ -381,36 +487,42 @@ func (r *AllocRunner) Run() {
 			r.logger.Printf("[WARN] client: failed to build task directories: %v", err)
 			r.setStatus(structs.AllocClientStatusFailed, fmt.Sprintf("failed to build task dirs for '%s'", alloc.TaskGroup))
 			r.ctxLock.Unlock()
+			close(r.tasksStoppedCh)
 			return
 		}
 		r.ctx = driver.NewExecContext(allocDir, r.alloc.ID)
+		newAllocDir = allocDir
 	}
 	r.ctxLock.Unlock()
 
+	// Migrate the ephemeral disk of the previous allocation into a freshly
+	// built alloc dir before any task starts using it.
+	if newAllocDir != nil && r.prevAlloc != nil && !alloc.TerminalStatus() {
+		r.logger.Printf("[DEBUG] client: migrating data of previous alloc %q into alloc %q",
+			alloc.PreviousAllocation, alloc.ID)
+		if err := r.prevAlloc.Migrate(newAllocDir, r.destroyCh); err != nil {
+			r.logger.Printf("[WARN] client: failed to migrate data of previous alloc %q into alloc %q: %v",
+				alloc.PreviousAllocation, alloc.ID, err)
+		}
+	}
+
 	// Check if the allocation is in a terminal status. In this case, we don't
 	// start any of the task runners and directly wait for the destroy signal to
 	// clean up the allocation.
 	if alloc.TerminalStatus() {
 		r.logger.Printf("[DEBUG] client: alloc %q in terminal status, waiting for destroy", r.alloc.ID)
+		close(r.tasksStoppedCh)
 		r.handleDestroy()
 		r.logger.Printf("[DEBUG] client: terminating runner for alloc '%s'", r.alloc.ID)
 		return
 	}
 
-	// Start the task runners
+	// Start the task runners in lifecycle order
 	r.logger.Printf("[DEBUG] client: starting task runners for alloc '%s'", r.alloc.ID)
-	r.taskLock.Lock()
-	for _, task := range tg.Tasks {
-		if _, ok := r.restored[task.Name]; ok {
-			continue
-		}
-
-		tr := NewTaskRunner(r.logger, r.config, r.setTaskState, r.ctx, r.Alloc(),
-			task.Copy(), r.consulService)
-		r.tasks[task.Name] = tr
-		go tr.Run()
-	}
-	r.taskLock.Unlock()
+	lifecycleStopCh := make(chan struct{})
+	lifecycleDoneCh := make(chan struct{})
+	go r.runTaskLifecycle(tg, lifecycleStopCh, lifecycleDoneCh)
+	go r.watchDisk(tg, lifecycleStopCh)
 
 OUTER:
 	// Wait for updates
@@ -430,8 +542,9 @@ OUTER:
 			// Update the task groups
 			r.taskLock.RLock()
 			for _, task := range tg.Tasks {
-				tr := r.tasks[task.Name]
-				tr.Update(update)
+				if tr, ok := r.tasks[task.Name]; ok {
+					tr.Update(update)
+				}
 			}
 			r.taskLock.RUnlock()
 
@@ -440,6 +553,10 @@ OUTER:
 		}
 	}
 
+	// Stop the tasks and run the poststop tasks
+	close(lifecycleStopCh)
+	<-lifecycleDoneCh
+
 	// Destroy each sub-task
 	r.taskLock.Lock()
 	for _, tr := range r.tasks {
@@ -451,6 +568,7 @@ OUTER:
 		<-tr.WaitCh()
 	}
 	r.taskLock.Unlock()
+	close(r.tasksStoppedCh)
 
 	// Final state sync
 	r.syncStatus()
@@ -460,6 +578,59 @@ OUTER:
 	r.logger.Printf("[DEBUG] client: terminating runner for alloc '%s'", r.alloc.ID)
 }
 
+// watchDisk periodically compares the disk usage of the allocation to the size
+// of its ephemeral disk and kills the running tasks if it is exceeded.
+func (r *AllocRunner) watchDisk(tg *structs.TaskGroup, stopCh <-chan struct{}) {
+	if tg.EphemeralDisk == nil || tg.EphemeralDisk.SizeMB <= 0 {
+		return
+	}
+	limit := int64(tg.EphemeralDisk.SizeMB)
+
+	ticker := time.NewTicker(r.diskWatchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-stopCh:
+			return
+		}
+
+		r.ctxLock.Lock()
+		allocDir := r.ctx.AllocDir
+		r.ctxLock.Unlock()
+		size, err := allocDir.Size()
+		if err != nil {
+			r.logger.Printf("[WARN] client: failed to compute the disk usage of alloc %q: %v", r.alloc.ID, err)
+			continue
+		}
+		sizeMB := size / (1024 * 1024)
+		if sizeMB <= limit {
+			continue
+		}
+
+		r.logger.Printf("[ERR] client: alloc %q uses %d MB of disk, more than its %d MB ephemeral disk; killing its tasks",
+			r.alloc.ID, sizeMB, limit)
+		r.setStatus(structs.AllocClientStatusFailed,
+			fmt.Sprintf("ephemeral disk usage of %d MB exceeded the size of %d MB", sizeMB, limit))
+
+		var running []*TaskRunner
+		r.taskLock.RLock()
+		for name, tr := range r.tasks {
+			select {
+			case <-tr.WaitCh():
+				continue
+			default:
+			}
+			event := structs.NewTaskEvent(structs.TaskDiskExceeded).SetDiskLimit(limit).SetDiskSize(sizeMB)
+			r.addTaskEvent(name, event)
+			running = append(running, tr)
+		}
+		r.taskLock.RUnlock()
+		r.stopTaskRunners(running)
+		return
+	}
+}
+
 // handleDestroy blocks till the AllocRunner should be destroyed and does the
 // necessary cleanup.
 func (r *AllocRunner) handleDestroy() {
@@ -505,6 +676,87 @@ func (r *AllocRunner) Destroy() {
 	close(r.destroyCh)
 }
 
+// Signal sends a signal to the named task, or to all the running tasks of the
+// allocation if the task name is empty.
+func (r *AllocRunner) Signal(source, reason, taskName string, s os.Signal) error {
+	runners, err := r.lookupTaskRunners(taskName)
+	if err != nil {
+		return err
+	}
+
+	var mErr multierror.Error
+	for _, tr := range runners {
+		if err := tr.Signal(source, reason, s); err != nil {
+			mErr.Errors = append(mErr.Errors, err)
+		}
+	}
+	return mErr.ErrorOrNil()
+}
+
+// Restart restarts the named task, or all the running tasks of the
+// allocation if the task name is empty. The restarts are not counted against
+// the restart policy of the tasks.
+func (r *AllocRunner) Restart(source, reason, taskName string) error {
+	runners, err := r.lookupTaskRunners(taskName)
+	if err != nil {
+		return err
+	}
+	for _, tr := range runners {
+		tr.Restart(source, reason)
+	}
+	return nil
+}
+
+// Exec runs a command inside the isolation context of the named task and
+// returns its exit code.
+func (r *AllocRunner) Exec(taskName string, opts *cstructs.ExecOptions) (int, error) {
+	if taskName == "" {
+		return -1, fmt.Errorf("missing task name")
+	}
+	runners, err := r.lookupTaskRunners(taskName)
+	if err != nil {
+		return -1, err
+	}
+	return runners[0].Exec(opts)
+}
+
+// lookupTaskRunners returns the runner of the named task, or the runners of
+// all the running tasks if the task name is empty.
+func (r *AllocRunner) lookupTaskRunners(taskName string) ([]*TaskRunner, error) {
+	r.taskLock.RLock()
+	defer r.taskLock.RUnlock()
+
+	if taskName != "" {
+		tr, ok := r.tasks[taskName]
+		if !ok || tr == nil {
+			return nil, fmt.Errorf("task %q is not running", taskName)
+		}
+		return []*TaskRunner{tr}, nil
+	}
+
+	var runners []*TaskRunner
+	for _, tr := range r.tasks {
+		if tr == nil {
+			continue
+		}
+		select {
+		case <-tr.WaitCh():
+		default:
+			runners = append(runners, tr)
+		}
+	}
+	if len(runners) == 0 {
+		return nil, fmt.Errorf("alloc %q has no running tasks", r.alloc.ID)
+	}
+	return runners, nil
+}
+
+// setPrevAlloc sets the migrator used to move the ephemeral disk of the
+// previous allocation into this one. It must be called before Run.
+func (r *AllocRunner) setPrevAlloc(prev prevAllocMigrator) {
+	r.prevAlloc = prev
+}
+
 // WaitCh returns a channel to wait for termination
 func (r *AllocRunner) WaitCh() <-chan struct{} {
 	return r.waitCh
@@ -2,10 +2,16 @@ package client
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/hashicorp/nomad/client/allocdir"
+	"github.com/hashicorp/nomad/client/config"
+	"github.com/hashicorp/nomad/client/driver"
+	"github.com/hashicorp/nomad/client/fingerprint"
 	"github.com/hashicorp/nomad/nomad/mock"
 	"github.com/hashicorp/nomad/nomad/structs"
 	"github.com/hashicorp/nomad/testutil"
@@ -380,3 +386,327 @@ func TestAllocRunner_SaveRestoreState_TerminalAlloc(t *testing.T) {
 		t.Fatalf("err: %v", err)
 	})
 }
+
+func TestAllocRunner_MigratePrevAlloc(t *testing.T) {
+	ctestutil.ExecCompatible(t)
+	upd, ar := testAllocRunner(false)
+	go ar.Run()
+	defer ar.Destroy()
+
+	testutil.WaitForResult(func() (bool, error) {
+		if upd.Count == 0 {
+			return false, fmt.Errorf("No updates")
+		}
+		last := upd.Allocs[upd.Count-1]
+		if !last.TerminalStatus() {
+			return false, fmt.Errorf("got status %v; want terminal status", last.ClientStatus)
+		}
+		return true, nil
+	}, func(err error) {
+		t.Fatalf("err: %v", err)
+	})
+
+	// Write some data into the ephemeral disk and stop the allocation
+	dataFile := filepath.Join(ar.ctx.AllocDir.DataDir(), "foo")
+	if err := ioutil.WriteFile(dataFile, []byte("bar"), 0644); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	update := ar.alloc.Copy()
+	update.DesiredStatus = structs.AllocDesiredStatusStop
+	ar.Update(update)
+
+	// Start the replacement allocation migrating the previous one
+	_, ar2 := testAllocRunner(false)
+	ar2.alloc.PreviousAllocation = ar.alloc.ID
+	ar2.alloc.Job.TaskGroups[0].EphemeralDisk.Sticky = true
+	ar2.setPrevAlloc(&localPrevAlloc{runner: ar})
+	go ar2.Run()
+	defer ar2.Destroy()
+
+	testutil.WaitForResult(func() (bool, error) {
+		ar2.ctxLock.Lock()
+		defer ar2.ctxLock.Unlock()
+		if ar2.ctx == nil {
+			return false, fmt.Errorf("alloc dir not built")
+		}
+		out, err := ioutil.ReadFile(filepath.Join(ar2.ctx.AllocDir.DataDir(), "foo"))
+		if err != nil {
+			return false, err
+		}
+		if string(out) != "bar" {
+			return false, fmt.Errorf("got %q; want %q", out, "bar")
+		}
+		return true, nil
+	}, func(err error) {
+		t.Fatalf("err: %v", err)
+	})
+}
+
+// addLifecycleTask adds a task running the command with the lifecycle hook to
+// the allocation of the alloc runner.
+func addLifecycleTask(ar *AllocRunner, name, hook, command string, args []string) {
+	tg := ar.alloc.Job.TaskGroups[0]
+	task := tg.Tasks[0].Copy()
+	task.Name = name
+	task.Services = nil
+	task.Config = map[string]interface{}{
+		"command": command,
+		"args":    args,
+	}
+	task.Lifecycle = &structs.TaskLifecycleConfig{Hook: hook}
+	tg.Tasks = append(tg.Tasks, task)
+
+	ar.alloc.TaskResources[name] = &structs.Resources{CPU: 100, MemoryMB: 64}
+	ar.taskStates[name] = &structs.TaskState{State: structs.TaskStatePending}
+}
+
+// eventTime returns the time of the first event of the type in the state.
+func eventTime(t *testing.T, state *structs.TaskState, eventType string) int64 {
+	for _, e := range state.Events {
+		if e.Type == eventType {
+			return e.Time
+		}
+	}
+	t.Fatalf("no %q event in %#v", eventType, state.Events)
+	return 0
+}
+
+func TestAllocRunner_Lifecycle(t *testing.T) {
+	ctestutil.ExecCompatible(t)
+	upd, ar := testAllocRunner(false)
+	addLifecycleTask(ar, "init", structs.TaskLifecycleHookPrestart, "/bin/sleep", []string{"1"})
+	addLifecycleTask(ar, "cleanup", structs.TaskLifecycleHookPoststop, "/bin/date", nil)
+	go ar.Run()
+	defer ar.Destroy()
+
+	var last *structs.Allocation
+	testutil.WaitForResult(func() (bool, error) {
+		if upd.Count == 0 {
+			return false, fmt.Errorf("No updates")
+		}
+		last = upd.Allocs[upd.Count-1]
+		if last.ClientStatus != structs.AllocClientStatusDead {
+			return false, fmt.Errorf("got status %v; want %v", last.ClientStatus, structs.AllocClientStatusDead)
+		}
+		for name, state := range last.TaskStates {
+			if state.State != structs.TaskStateDead {
+				return false, fmt.Errorf("task %q is %v", name, state.State)
+			}
+		}
+		return true, nil
+	}, func(err error) {
+		t.Fatalf("err: %v", err)
+	})
+
+	// The main task started once the prestart task completed and the
+	// poststop task once the main task exited
+	initDone := eventTime(t, last.TaskStates["init"], structs.TaskTerminated)
+	webStart := eventTime(t, last.TaskStates["web"], structs.TaskStarted)
+	webDone := eventTime(t, last.TaskStates["web"], structs.TaskTerminated)
+	cleanupStart := eventTime(t, last.TaskStates["cleanup"], structs.TaskStarted)
+	if webStart < initDone {
+		t.Fatalf("main task started before the prestart task completed")
+	}
+	if cleanupStart < webDone {
+		t.Fatalf("poststop task started before the main task exited")
+	}
+}
+
+func TestAllocRunner_Lifecycle_PrestartFailed(t *testing.T) {
+	ctestutil.ExecCompatible(t)
+	upd, ar := testAllocRunner(false)
+	addLifecycleTask(ar, "init", structs.TaskLifecycleHookPrestart, "/bin/false", nil)
+	go ar.Run()
+	defer ar.Destroy()
+
+	testutil.WaitForResult(func() (bool, error) {
+		if upd.Count == 0 {
+			return false, fmt.Errorf("No updates")
+		}
+		last := upd.Allocs[upd.Count-1]
+		if last.ClientStatus != structs.AllocClientStatusFailed {
+			return false, fmt.Errorf("got status %v; want %v", last.ClientStatus, structs.AllocClientStatusFailed)
+		}
+
+		// The main task was never started
+		state := last.TaskStates["web"]
+		if state.State != structs.TaskStateDead {
+			return false, fmt.Errorf("main task is %v", state.State)
+		}
+		event := state.Events[len(state.Events)-1]
+		if event.Type != structs.TaskSiblingFailed || event.FailedSibling != "init" {
+			return false, fmt.Errorf("bad event: %#v", event)
+		}
+		return true, nil
+	}, func(err error) {
+		t.Fatalf("err: %v", err)
+	})
+}
+
+func TestAllocRunner_Alloc_TaskFailed(t *testing.T) {
+	_, ar := testAllocRunner(false)
+
+	// Tasks which couldn't be started fail the alloc
+	for _, typ := range []string{
+		structs.TaskDriverFailure,
+		structs.TaskArtifactDownloadFailed,
+		structs.TaskSetupFailure,
+	} {
+		ar.taskStatusLock.Lock()
+		ar.taskStates["web"] = &structs.TaskState{
+			State:  structs.TaskStateDead,
+			Events: []*structs.TaskEvent{structs.NewTaskEvent(structs.TaskReceived), structs.NewTaskEvent(typ)},
+		}
+		ar.taskStatusLock.Unlock()
+
+		if status := ar.Alloc().ClientStatus; status != structs.AllocClientStatusFailed {
+			t.Fatalf("%s: got status %v; want %v", typ, status, structs.AllocClientStatusFailed)
+		}
+	}
+
+	// Tasks which ran and exited don't
+	ar.taskStatusLock.Lock()
+	ar.taskStates["web"] = &structs.TaskState{
+		State:  structs.TaskStateDead,
+		Events: []*structs.TaskEvent{structs.NewTaskEvent(structs.TaskStarted), structs.NewTaskEvent(structs.TaskTerminated)},
+	}
+	ar.taskStatusLock.Unlock()
+	if status := ar.Alloc().ClientStatus; status != structs.AllocClientStatusDead {
+		t.Fatalf("got status %v; want %v", status, structs.AllocClientStatusDead)
+	}
+}
+
+func TestAllocRunner_Leader(t *testing.T) {
+	ctestutil.ExecCompatible(t)
+	upd, ar := testAllocRunner(false)
+
+	// The leader exits right away while the sibling keeps running
+	tg := ar.alloc.Job.TaskGroups[0]
+	tg.Tasks[0].Leader = true
+	addLifecycleTask(ar, "logs", "", "/bin/sleep", []string{"1000"})
+	tg.Tasks[1].Lifecycle = nil
+	go ar.Run()
+	defer ar.Destroy()
+
+	testutil.WaitForResult(func() (bool, error) {
+		if upd.Count == 0 {
+			return false, fmt.Errorf("No updates")
+		}
+		last := upd.Allocs[upd.Count-1]
+		if last.ClientStatus != structs.AllocClientStatusDead {
+			return false, fmt.Errorf("got status %v; want %v", last.ClientStatus, structs.AllocClientStatusDead)
+		}
+
+		// The sibling was stopped because the leader exited
+		state := last.TaskStates["logs"]
+		if state.State != structs.TaskStateDead {
+			return false, fmt.Errorf("sibling task is %v", state.State)
+		}
+		var exited bool
+		for _, e := range state.Events {
+			if e.Type == structs.TaskSiblingExited && e.ExitedSibling == "web" {
+				exited = true
+			}
+		}
+		if !exited {
+			return false, fmt.Errorf("no sibling exited event: %#v", state.Events)
+		}
+		return true, nil
+	}, func(err error) {
+		t.Fatalf("err: %v", err)
+	})
+}
+
+func TestAllocRunner_DiskExceeded(t *testing.T) {
+	ctestutil.ExecCompatible(t)
+	upd, ar := testAllocRunner(false)
+	ar.diskWatchInterval = 100 * time.Millisecond
+
+	// The task writes more than the ephemeral disk allows
+	tg := ar.alloc.Job.TaskGroups[0]
+	tg.EphemeralDisk.SizeMB = 1
+	task := tg.Tasks[0]
+	task.Config["command"] = "/bin/sh"
+	task.Config["args"] = []string{"-c", "dd if=/dev/zero of=$NOMAD_TASK_DIR/big bs=1M count=2 && sleep 10"}
+	go ar.Run()
+	defer ar.Destroy()
+
+	testutil.WaitForResult(func() (bool, error) {
+		if upd.Count == 0 {
+			return false, fmt.Errorf("No updates")
+		}
+		last := upd.Allocs[upd.Count-1]
+		if last.ClientStatus != structs.AllocClientStatusFailed {
+			return false, fmt.Errorf("got status %v; want %v", last.ClientStatus, structs.AllocClientStatusFailed)
+		}
+		state := last.TaskStates[task.Name]
+		if state.State != structs.TaskStateDead {
+			return false, fmt.Errorf("task is %v", state.State)
+		}
+		for _, e := range state.Events {
+			if e.Type == structs.TaskDiskExceeded {
+				if e.DiskLimit != 1 || e.DiskSize < 2 {
+					return false, fmt.Errorf("bad event: %#v", e)
+				}
+				return true, nil
+			}
+		}
+		return false, fmt.Errorf("no disk exceeded event: %#v", state.Events)
+	}, func(err error) {
+		t.Fatalf("err: %v", err)
+	})
+}
+
+// cleanupDriver records the tasks it cleans up.
+type cleanupDriver struct {
+	fingerprint.StaticFingerprinter
+	cleaned chan string
+}
+
+func (d *cleanupDriver) Fingerprint(*config.Config, *structs.Node) (bool, error) {
+	return true, nil
+}
+
+func (d *cleanupDriver) Start(*driver.ExecContext, *structs.Task) (driver.DriverHandle, error) {
+	return nil, fmt.Errorf("not supported")
+}
+
+func (d *cleanupDriver) Open(*driver.ExecContext, string) (driver.DriverHandle, error) {
+	return nil, fmt.Errorf("not supported")
+}
+
+func (d *cleanupDriver) Cleanup(ctx *driver.ExecContext, task *structs.Task) error {
+	d.cleaned <- task.Name
+	return nil
+}
+
+func TestAllocRunner_DestroyContext_CleanupDrivers(t *testing.T) {
+	_, ar := testAllocRunner(false)
+	cleaner := &cleanupDriver{cleaned: make(chan string, 1)}
+	driver.BuiltinDrivers["cleanup_test"] = func(*driver.DriverContext) driver.Driver { return cleaner }
+	defer delete(driver.BuiltinDrivers, "cleanup_test")
+
+	task := ar.alloc.Job.TaskGroups[0].Tasks[0]
+	task.Driver = "cleanup_test"
+	allocDir := allocdir.NewAllocDir(filepath.Join(ar.config.AllocDir, ar.alloc.ID))
+	if err := allocDir.Build([]*structs.Task{task}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	ar.ctx = driver.NewExecContext(allocDir, ar.alloc.ID)
+
+	// The drivers clean up the tasks when the alloc dir is destroyed
+	if err := ar.DestroyContext(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	select {
+	case name := <-cleaner.cleaned:
+		if name != task.Name {
+			t.Fatalf("cleaned up task %q; want %q", name, task.Name)
+		}
+	default:
+		t.Fatalf("task wasn't cleaned up")
+	}
+	if _, err := os.Stat(allocDir.AllocDir); !os.IsNotExist(err) {
+		t.Fatalf("alloc dir not destroyed: %v", err)
+	}
+}
@@ -1,17 +1,23 @@
 package allocdir
 
 import (
+	"archive/tar"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/nomad/nomad/structs"
 )
 
+// maxSymlinks is the maximum number of symlinks followed resolving a path
+// within a directory.
+const maxSymlinks = 255
+
 var (
 	// The name of the directory that is shared across tasks in a task group.
 	SharedAllocName = "alloc"
@@ -19,8 +25,12 @@ var (
 	// Name of the directory where logs of Tasks are written
 	LogDirName = "logs"
 
+	// Name of the directory where Tasks write the data that is migrated to
+	// the allocation replacing this one
+	DataDirName = "data"
+
 	// The set of directories that exist inside eache shared alloc directory.
-	SharedAllocDirs = []string{LogDirName, "tmp", "data"}
+	SharedAllocDirs = []string{LogDirName, "tmp", DataDirName}
 
 	// The name of the directory that exists inside each task directory
 	// regardless of driver.
@@ -57,6 +67,7 @@ type AllocDirFS interface {
 	List(path string) ([]*AllocFileInfo, error)
 	Stat(path string) (*AllocFileInfo, error)
 	ReadAt(path string, offset int64, limit int64) (io.ReadCloser, error)
+	Snapshot(w io.Writer) error
 }
 
 func NewAllocDir(allocDir string) *AllocDir {
@@ -83,6 +94,11 @@ func (d *AllocDir) Destroy() error {
 func (d *AllocDir) UnmountAll() error {
 	var mErr multierror.Error
 	for _, dir := range d.TaskDirs {
+		// Unmount the host volumes first so that they are never deleted
+		if err := d.unmountHostVolumes(dir); err != nil {
+			mErr.Errors = append(mErr.Errors, err)
+		}
+
 		// Check if the directory has the shared alloc mounted.
 		taskAlloc := filepath.Join(dir, SharedAllocName)
 		if d.pathExists(taskAlloc) {
@@ -281,11 +297,271 @@ func (d *AllocDir) MountSharedDir(task string) error {
 	return nil
 }
 
+// MountHostVolume bind mounts a host directory into the specified task's
+// directory at the given path, which is relative to the task directory.
+func (d *AllocDir) MountHostVolume(task, hostPath, taskPath string, readOnly bool) error {
+	taskDir, ok := d.TaskDirs[task]
+	if !ok {
+		return fmt.Errorf("No task directory exists for %v", task)
+	}
+
+	dest := filepath.Join(taskDir, taskPath)
+	if rel, err := filepath.Rel(taskDir, dest); err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return fmt.Errorf("Volume destination %q must be within the task directory", taskPath)
+	}
+
+	if err := d.mountHostVolume(hostPath, dest, readOnly); err != nil {
+		return fmt.Errorf("Failed to mount host volume %q for task %v: %v", hostPath, task, err)
+	}
+
+	return nil
+}
+
 // LogDir returns the log dir in the current allocation directory
 func (d *AllocDir) LogDir() string {
 	return filepath.Join(d.AllocDir, SharedAllocName, LogDirName)
 }
 
+// DataDir returns the data dir in the current allocation directory
+func (d *AllocDir) DataDir() string {
+	return filepath.Join(d.AllocDir, SharedAllocName, DataDirName)
+}
+
+// Move moves the data dir of a previous allocation on this node into the
+// current allocation directory.
+func (d *AllocDir) Move(prev *AllocDir) error {
+	if !d.pathExists(prev.DataDir()) {
+		return fmt.Errorf("data dir of previous allocation %q doesn't exist", prev.AllocDir)
+	}
+	if err := os.RemoveAll(d.DataDir()); err != nil {
+		return fmt.Errorf("failed to remove data dir %q: %v", d.DataDir(), err)
+	}
+	if err := os.Rename(prev.DataDir(), d.DataDir()); err != nil {
+		return fmt.Errorf("failed to move data dir %q: %v", prev.DataDir(), err)
+	}
+	return nil
+}
+
+// Size returns the disk usage in bytes of the directories the tasks write to:
+// the shared alloc dir and the local and tmp dirs of each task. File systems
+// mounted into them, such as host volumes, aren't counted.
+func (d *AllocDir) Size() (int64, error) {
+	mounts, err := mountsUnder(d.AllocDir)
+	if err != nil {
+		return 0, err
+	}
+	skip := make(map[string]struct{}, len(mounts))
+	for _, mount := range mounts {
+		skip[mount] = struct{}{}
+	}
+
+	var size int64
+	walkFn := func(path string, fileInfo os.FileInfo, err error) error {
+		if err != nil {
+			// Files may be removed by the tasks while walking
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if _, ok := skip[path]; ok && fileInfo.IsDir() {
+			return filepath.SkipDir
+		}
+		if fileInfo.Mode().IsRegular() {
+			size += fileInfo.Size()
+		}
+		return nil
+	}
+
+	dirs := []string{d.SharedDir}
+	for _, taskDir := range d.TaskDirs {
+		dirs = append(dirs, filepath.Join(taskDir, TaskLocal))
+		for _, dir := range TaskDirs {
+			dirs = append(dirs, filepath.Join(taskDir, dir))
+		}
+	}
+	for _, dir := range dirs {
+		if err := filepath.Walk(dir, walkFn); err != nil {
+			return 0, fmt.Errorf("failed to compute the size of %q: %v", dir, err)
+		}
+	}
+	return size, nil
+}
+
+// Snapshot writes a tar archive of the data dir to the writer.
+func (d *AllocDir) Snapshot(w io.Writer) error {
+	dataDir := d.DataDir()
+	tw := tar.NewWriter(w)
+	walkFn := func(path string, fileInfo os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dataDir, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+
+		link := ""
+		if fileInfo.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(fileInfo, link)
+		if err != nil {
+			return fmt.Errorf("failed to create tar header for %q: %v", path, err)
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(hdr); err != nil {
+			return fmt.Errorf("failed to write tar header for %q: %v", path, err)
+		}
+
+		if !fileInfo.Mode().IsRegular() {
+			return nil
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		if _, err := io.Copy(tw, file); err != nil {
+			return fmt.Errorf("failed to write %q to the snapshot: %v", path, err)
+		}
+		return nil
+	}
+
+	if err := filepath.Walk(dataDir, walkFn); err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// RestoreSnapshot extracts a tar archive written by Snapshot into the data
+// dir. Symlinks are resolved within the data dir and neither the entries nor
+// the targets of the links may escape it.
+func (d *AllocDir) RestoreSnapshot(r io.Reader) error {
+	dataDir := d.DataDir()
+	var links []string
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read the snapshot: %v", err)
+		}
+
+		// Don't allow the snapshot to write outside of the data dir, even
+		// through the links it restored
+		name := filepath.FromSlash(hdr.Name)
+		mode := os.FileMode(hdr.Mode).Perm()
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			path, err := SecurePath(dataDir, name)
+			if err != nil {
+				return fmt.Errorf("snapshot entry %q escapes the data dir", hdr.Name)
+			}
+			if err := os.MkdirAll(path, mode); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			dir, err := SecurePath(dataDir, filepath.Dir(name))
+			if err != nil || filepath.Base(name) == ".." {
+				return fmt.Errorf("snapshot entry %q escapes the data dir", hdr.Name)
+			}
+			if filepath.IsAbs(hdr.Linkname) {
+				return fmt.Errorf("snapshot link %q escapes the data dir", hdr.Name)
+			}
+			path := filepath.Join(dir, filepath.Base(name))
+			if err := os.Symlink(hdr.Linkname, path); err != nil {
+				return err
+			}
+			links = append(links, path)
+		case tar.TypeReg, tar.TypeRegA:
+			path, err := SecurePath(dataDir, name)
+			if err != nil {
+				return fmt.Errorf("snapshot entry %q escapes the data dir", hdr.Name)
+			}
+			file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(file, tr); err != nil {
+				file.Close()
+				return fmt.Errorf("failed to restore %q from the snapshot: %v", hdr.Name, err)
+			}
+			if err := file.Close(); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Links may only point within the data dir. They are checked once all of
+	// them are restored since a link can escape through links restored after
+	// it.
+	for _, path := range links {
+		rel, err := filepath.Rel(dataDir, path)
+		if err != nil {
+			return err
+		}
+		if _, err := SecurePath(dataDir, rel); err != nil {
+			os.Remove(path)
+			return fmt.Errorf("snapshot link %q escapes the data dir", filepath.ToSlash(rel))
+		}
+	}
+	return nil
+}
+
+// SecurePath returns the host path of the path relative to the dir. Symlinks
+// are resolved within the dir, absolute ones relative to the dir as if it
+// were the root, and an error is returned if the path escapes it through "..",
+// either directly or through a link.
+func SecurePath(dir, path string) (string, error) {
+	var resolved []string
+	remaining := strings.Split(filepath.ToSlash(path), "/")
+	for links := 0; len(remaining) != 0; {
+		part := remaining[0]
+		remaining = remaining[1:]
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			if len(resolved) == 0 {
+				return "", fmt.Errorf("path %q escapes %s", path, dir)
+			}
+			resolved = resolved[:len(resolved)-1]
+			continue
+		}
+
+		next := filepath.Join(dir, filepath.Join(resolved...), part)
+		fi, err := os.Lstat(next)
+		if err != nil || fi.Mode()&os.ModeSymlink == 0 {
+			resolved = append(resolved, part)
+			continue
+		}
+
+		links++
+		if links > maxSymlinks {
+			return "", fmt.Errorf("too many symlinks resolving %s", path)
+		}
+		target, err := os.Readlink(next)
+		if err != nil {
+			return "", err
+		}
+		// The target is resolved relative to the directory of the link, or
+		// to the dir if absolute
+		if filepath.IsAbs(target) {
+			resolved = nil
+		}
+		remaining = append(strings.Split(filepath.ToSlash(target), "/"), remaining...)
+	}
+	return filepath.Join(dir, filepath.Join(resolved...)), nil
+}
+
 // List returns the list of files at a path relative to the alloc dir
 func (d *AllocDir) List(path string) ([]*AllocFileInfo, error) {
 	p := filepath.Join(d.AllocDir, path)
@@ -1,6 +1,7 @@
 package allocdir
 
 import (
+	"errors"
 	"syscall"
 )
 
@@ -24,3 +25,21 @@ func (d *AllocDir) MountSpecialDirs(taskDir string) error {
 func (d *AllocDir) unmountSpecialDirs(taskDir string) error {
 	return nil
 }
+
+// mountHostVolume bind mounts a host directory at the destination. Host
+// volumes aren't supported on darwin.
+func (d *AllocDir) mountHostVolume(hostPath, dest string, readOnly bool) error {
+	return errors.New("Host volumes are not supported on darwin")
+}
+
+// unmountHostVolumes unmounts the host volumes mounted in the task directory
+func (d *AllocDir) unmountHostVolumes(taskDir string) error {
+	return nil
+}
+
+// mountsUnder returns the mount points below the directory. Only host volumes
+// are mounted into the alloc dir apart from the shared dir, and they aren't
+// supported on darwin.
+func mountsUnder(dir string) ([]string, error) {
+	return nil, nil
+}
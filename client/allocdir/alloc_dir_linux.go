@@ -1,9 +1,12 @@
 package allocdir
 
 import (
+	"bufio"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"syscall"
 
 	"github.com/hashicorp/go-multierror"
@@ -81,3 +84,86 @@ func (d *AllocDir) unmountSpecialDirs(taskDir string) error {
 
 	return errs.ErrorOrNil()
 }
+
+// mountHostVolume bind mounts a host directory at the destination, remounting
+// it read only if requested. Must be root to run.
+func (d *AllocDir) mountHostVolume(hostPath, dest string, readOnly bool) error {
+	if err := os.MkdirAll(dest, 0777); err != nil {
+		return err
+	}
+
+	if err := syscall.Mount(hostPath, dest, "", syscall.MS_BIND, ""); err != nil {
+		return err
+	}
+
+	// A bind mount ignores the read only flag, so it has to be remounted
+	if readOnly {
+		flags := uintptr(syscall.MS_BIND | syscall.MS_REMOUNT | syscall.MS_RDONLY)
+		if err := syscall.Mount("", dest, "", flags, ""); err != nil {
+			syscall.Unmount(dest, syscall.MNT_DETACH)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// unmountHostVolumes unmounts the host volumes mounted in the task directory.
+// The mounts are found in the mount table so they are unmounted even if the
+// client restarted since mounting them.
+func (d *AllocDir) unmountHostVolumes(taskDir string) error {
+	all, err := mountsUnder(taskDir)
+	if err != nil {
+		return err
+	}
+
+	// Only the volumes are left mounted in the task directory besides the
+	// shared alloc dir and the special dirs, which are unmounted separately.
+	special := map[string]struct{}{
+		filepath.Join(taskDir, SharedAllocName): struct{}{},
+		filepath.Join(taskDir, "dev"):           struct{}{},
+		filepath.Join(taskDir, "proc"):          struct{}{},
+	}
+	var mounts []string
+	for _, mount := range all {
+		if _, ok := special[mount]; !ok {
+			mounts = append(mounts, mount)
+		}
+	}
+
+	// Unmount the deepest mounts first
+	sort.Sort(sort.Reverse(sort.StringSlice(mounts)))
+	errs := new(multierror.Error)
+	for _, mount := range mounts {
+		if err := syscall.Unmount(mount, syscall.MNT_DETACH); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("Failed to unmount host volume (%v): %v", mount, err))
+		}
+	}
+	return errs.ErrorOrNil()
+}
+
+// mountsUnder returns the mount points below the directory, as found in the
+// mount table.
+func mountsUnder(dir string) ([]string, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read the mount table: %v", err)
+	}
+	defer f.Close()
+
+	var mounts []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 {
+			continue
+		}
+		if mount := fields[4]; strings.HasPrefix(mount, dir+"/") {
+			mounts = append(mounts, mount)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Failed to read the mount table: %v", err)
+	}
+	return mounts, nil
+}
@@ -1,10 +1,14 @@
 package allocdir
 
 import (
+	"archive/tar"
+	"bytes"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/hashicorp/nomad/client/testutil"
@@ -212,3 +216,319 @@ func TestAllocDir_MountSharedAlloc(t *testing.T) {
 		}
 	}
 }
+
+func TestAllocDir_MountHostVolume(t *testing.T) {
+	testutil.MountCompatible(t)
+	tmp, err := ioutil.TempDir("", "AllocDir")
+	if err != nil {
+		t.Fatalf("Couldn't create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	host, err := ioutil.TempDir("", "HostVolume")
+	if err != nil {
+		t.Fatalf("Couldn't create temp dir: %v", err)
+	}
+	defer os.RemoveAll(host)
+	if err := ioutil.WriteFile(filepath.Join(host, "foo"), []byte("bar"), 0644); err != nil {
+		t.Fatalf("Couldn't write file to host volume: %v", err)
+	}
+
+	d := NewAllocDir(filepath.Join(tmp, "alloc"))
+	tasks := []*structs.Task{t1}
+	if err := d.Build(tasks); err != nil {
+		t.Fatalf("Build(%v) failed: %v", tasks, err)
+	}
+
+	// Destinations outside of the task directory are rejected
+	if err := d.MountHostVolume(t1.Name, host, "../escape", false); err == nil {
+		t.Fatalf("MountHostVolume should have failed for a destination outside the task directory")
+	}
+
+	if err := d.MountHostVolume(t1.Name, host, "/srv/data", true); err != nil {
+		t.Fatalf("MountHostVolume failed: %v", err)
+	}
+
+	// The host files are visible in the task directory but can't be written
+	taskFile := filepath.Join(d.TaskDirs[t1.Name], "srv", "data", "foo")
+	act, err := ioutil.ReadFile(taskFile)
+	if err != nil {
+		t.Fatalf("Failed to read host volume file from task dir: %v", err)
+	}
+	if string(act) != "bar" {
+		t.Fatalf("Incorrect data read from task dir: want %q; got %q", "bar", act)
+	}
+	if err := ioutil.WriteFile(taskFile, []byte("baz"), 0644); err == nil {
+		t.Fatalf("Writing to a read only host volume should fail")
+	}
+
+	// Destroying the alloc dir leaves the host volume intact
+	if err := d.Destroy(); err != nil {
+		t.Fatalf("Destroy failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(host, "foo")); err != nil {
+		t.Fatalf("Host volume file was removed: %v", err)
+	}
+}
+
+func TestAllocDir_Size(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "AllocDir")
+	if err != nil {
+		t.Fatalf("Couldn't create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	d := NewAllocDir(tmp)
+	defer d.Destroy()
+	tasks := []*structs.Task{t1, t2}
+	if err := d.Build(tasks); err != nil {
+		t.Fatalf("Build(%v) failed: %v", tasks, err)
+	}
+
+	files := map[string]int{
+		filepath.Join(d.SharedDir, "data", "shared"):           100,
+		filepath.Join(d.TaskDirs[t1.Name], TaskLocal, "local"): 200,
+		filepath.Join(d.TaskDirs[t2.Name], "tmp", "tmp"):       300,
+
+		// Files outside the directories the tasks write to aren't counted
+		filepath.Join(d.TaskDirs[t2.Name], "embedded"): 400,
+	}
+	for path, size := range files {
+		if err := ioutil.WriteFile(path, make([]byte, size), 0666); err != nil {
+			t.Fatalf("Couldn't write file %q: %v", path, err)
+		}
+	}
+
+	size, err := d.Size()
+	if err != nil {
+		t.Fatalf("Size() failed: %v", err)
+	}
+	if size != 600 {
+		t.Fatalf("Size() returned %d; want %d", size, 600)
+	}
+}
+
+func TestAllocDir_SnapshotRestore(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "AllocDir")
+	if err != nil {
+		t.Fatalf("Couldn't create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	prev := NewAllocDir(filepath.Join(tmp, "prev"))
+	if err := prev.Build([]*structs.Task{t1}); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(prev.DataDir(), "db"), 0755); err != nil {
+		t.Fatalf("Couldn't create dir: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(prev.DataDir(), "db", "foo"), []byte("bar"), 0600); err != nil {
+		t.Fatalf("Couldn't write file: %v", err)
+	}
+	if err := os.Symlink("db/foo", filepath.Join(prev.DataDir(), "link")); err != nil {
+		t.Fatalf("Couldn't create link: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := prev.Snapshot(&buf); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+
+	d := NewAllocDir(filepath.Join(tmp, "next"))
+	if err := d.Build([]*structs.Task{t1}); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	if err := d.RestoreSnapshot(&buf); err != nil {
+		t.Fatalf("RestoreSnapshot failed: %v", err)
+	}
+
+	for _, name := range []string{"db/foo", "link"} {
+		act, err := ioutil.ReadFile(filepath.Join(d.DataDir(), name))
+		if err != nil {
+			t.Fatalf("Couldn't read restored file %q: %v", name, err)
+		}
+		if string(act) != "bar" {
+			t.Fatalf("Incorrect data in %q: want %q; got %q", name, "bar", act)
+		}
+	}
+	fi, err := os.Stat(filepath.Join(d.DataDir(), "db", "foo"))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Fatalf("Incorrect mode: %v", fi.Mode())
+	}
+}
+
+func TestAllocDir_RestoreSnapshot_Escape(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "AllocDir")
+	if err != nil {
+		t.Fatalf("Couldn't create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	for i, hdrs := range [][]*tar.Header{
+		{{Name: "../../escape", Typeflag: tar.TypeReg, Mode: 0644}},
+		{{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "/etc"}},
+		{{Name: "up", Typeflag: tar.TypeSymlink, Linkname: ".."}},
+
+		// Writing through a link escaping the data dir
+		{
+			{Name: "parent", Typeflag: tar.TypeSymlink, Linkname: "../"},
+			{Name: "parent/escape", Typeflag: tar.TypeReg, Mode: 0644},
+		},
+
+		// Chains of links which only escape once resolved
+		{
+			{Name: "a", Typeflag: tar.TypeSymlink, Linkname: "."},
+			{Name: "a/b", Typeflag: tar.TypeSymlink, Linkname: "../.."},
+		},
+		{
+			{Name: "d/e", Typeflag: tar.TypeDir, Mode: 0755},
+			{Name: "d/e/link", Typeflag: tar.TypeSymlink, Linkname: "x/../.."},
+			{Name: "d/e/x", Typeflag: tar.TypeSymlink, Linkname: ".."},
+		},
+	} {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		for _, hdr := range hdrs {
+			if err := tw.WriteHeader(hdr); err != nil {
+				t.Fatalf("err: %v", err)
+			}
+		}
+		tw.Close()
+
+		dir := filepath.Join(tmp, fmt.Sprintf("alloc%d", i))
+		d := NewAllocDir(dir)
+		if err := d.Build([]*structs.Task{t1}); err != nil {
+			t.Fatalf("Build failed: %v", err)
+		}
+		if err := d.RestoreSnapshot(&buf); err == nil || !strings.Contains(err.Error(), "escapes") {
+			t.Fatalf("expected escape error for snapshot %d, got: %v", i, err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, SharedAllocName, "escape")); err == nil {
+			t.Fatalf("snapshot %d wrote outside of the data dir", i)
+		}
+	}
+}
+
+func TestAllocDir_RestoreSnapshot_Links(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "AllocDir")
+	if err != nil {
+		t.Fatalf("Couldn't create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	d := NewAllocDir(filepath.Join(tmp, "alloc"))
+	if err := d.Build([]*structs.Task{t1}); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	// Links within the data dir are restored and followed
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, hdr := range []*tar.Header{
+		{Name: "d/e", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "d/e/up", Typeflag: tar.TypeSymlink, Linkname: "../.."},
+		{Name: "d/e/up/foo", Typeflag: tar.TypeReg, Mode: 0644, Size: 3},
+	} {
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if hdr.Size != 0 {
+			tw.Write([]byte("bar"))
+		}
+	}
+	tw.Close()
+
+	if err := d.RestoreSnapshot(&buf); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	act, err := ioutil.ReadFile(filepath.Join(d.DataDir(), "foo"))
+	if err != nil {
+		t.Fatalf("Couldn't read restored file: %v", err)
+	}
+	if string(act) != "bar" {
+		t.Fatalf("Incorrect data: want %q; got %q", "bar", act)
+	}
+}
+
+func TestAllocDir_SecurePath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "AllocDir")
+	if err != nil {
+		t.Fatalf("Couldn't create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := os.MkdirAll(filepath.Join(dir, "usr", "lib"), 0755); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	links := map[string]string{
+		"lib":    "usr/lib",
+		"abs":    "/usr/lib",
+		"etc":    "/../../etc",
+		"up":     "../..",
+		"parent": "usr/lib/../..",
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(dir, name)); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	// Links are resolved within the dir, absolute ones relative to it
+	cases := map[string]string{
+		"/lib/libc.so":      "usr/lib/libc.so",
+		"abs/libc.so":       "usr/lib/libc.so",
+		"usr/lib/../../lib": "usr/lib",
+		"./usr/./lib/":      "usr/lib",
+	}
+	for path, expected := range cases {
+		actual, err := SecurePath(dir, path)
+		if err != nil {
+			t.Fatalf("SecurePath(%q) failed: %v", path, err)
+		}
+		if actual != filepath.Join(dir, expected) {
+			t.Fatalf("SecurePath(%q) = %q; want %q", path, actual, filepath.Join(dir, expected))
+		}
+	}
+
+	// Paths escaping the dir, directly or through links, are rejected
+	for _, path := range []string{"..", "../etc/passwd", "usr/../../etc", "etc/passwd", "up/etc", "parent/etc"} {
+		if actual, err := SecurePath(dir, path); err == nil || !strings.Contains(err.Error(), "escapes") {
+			t.Fatalf("expected SecurePath(%q) to escape, got %q: %v", path, actual, err)
+		}
+	}
+}
+
+func TestAllocDir_Move(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "AllocDir")
+	if err != nil {
+		t.Fatalf("Couldn't create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	prev := NewAllocDir(filepath.Join(tmp, "prev"))
+	if err := prev.Build([]*structs.Task{t1}); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(prev.DataDir(), "foo"), []byte("bar"), 0644); err != nil {
+		t.Fatalf("Couldn't write file: %v", err)
+	}
+
+	d := NewAllocDir(filepath.Join(tmp, "next"))
+	if err := d.Build([]*structs.Task{t1}); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	if err := d.Move(prev); err != nil {
+		t.Fatalf("Move failed: %v", err)
+	}
+
+	act, err := ioutil.ReadFile(filepath.Join(d.DataDir(), "foo"))
+	if err != nil {
+		t.Fatalf("Couldn't read moved file: %v", err)
+	}
+	if string(act) != "bar" {
+		t.Fatalf("Incorrect data: want %q; got %q", "bar", act)
+	}
+}
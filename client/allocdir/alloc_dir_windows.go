@@ -34,3 +34,21 @@ func (d *AllocDir) MountSpecialDirs(taskDir string) error {
 func (d *AllocDir) unmountSpecialDirs(taskDir string) error {
 	return nil
 }
+
+// mountHostVolume bind mounts a host directory at the destination. Host
+// volumes aren't supported on windows.
+func (d *AllocDir) mountHostVolume(hostPath, dest string, readOnly bool) error {
+	return errors.New("Host volumes are not supported on windows")
+}
+
+// unmountHostVolumes unmounts the host volumes mounted in the task directory
+func (d *AllocDir) unmountHostVolumes(taskDir string) error {
+	return nil
+}
+
+// mountsUnder returns the mount points below the directory. Only host volumes
+// are mounted into the alloc dir apart from the shared dir, and they aren't
+// supported on windows.
+func mountsUnder(dir string) ([]string, error) {
+	return nil, nil
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/hashicorp/nomad/client/allocdir"
 	"github.com/hashicorp/nomad/client/config"
 	"github.com/hashicorp/nomad/client/driver"
+	cstructs "github.com/hashicorp/nomad/client/driver/structs"
 	"github.com/hashicorp/nomad/client/fingerprint"
 	"github.com/hashicorp/nomad/nomad"
 	"github.com/hashicorp/nomad/nomad/structs"
@@ -422,6 +423,42 @@ func (c *Client) GetAllocFS(allocID string) (allocdir.AllocDirFS, error) {
 	return ar.ctx.AllocDir, nil
 }
 
+// SignalAlloc sends a signal to the named task of the allocation, or to all
+// of its running tasks if the task name is empty.
+func (c *Client) SignalAlloc(allocID, taskName string, s os.Signal) error {
+	c.allocLock.RLock()
+	ar, ok := c.allocs[allocID]
+	c.allocLock.RUnlock()
+	if !ok {
+		return fmt.Errorf("alloc not found")
+	}
+	return ar.Signal("user", "signal requested through the API", taskName, s)
+}
+
+// RestartAlloc restarts the named task of the allocation, or all of its
+// running tasks if the task name is empty.
+func (c *Client) RestartAlloc(allocID, taskName string) error {
+	c.allocLock.RLock()
+	ar, ok := c.allocs[allocID]
+	c.allocLock.RUnlock()
+	if !ok {
+		return fmt.Errorf("alloc not found")
+	}
+	return ar.Restart("user", "restart requested through the API", taskName)
+}
+
+// ExecAlloc runs a command inside the isolation context of the named task of
+// the allocation and returns its exit code.
+func (c *Client) ExecAlloc(allocID, taskName string, opts *cstructs.ExecOptions) (int, error) {
+	c.allocLock.RLock()
+	ar, ok := c.allocs[allocID]
+	c.allocLock.RUnlock()
+	if !ok {
+		return -1, fmt.Errorf("alloc not found")
+	}
+	return ar.Exec(taskName, opts)
+}
+
 // restoreState is used to restore our state from the data dir
 func (c *Client) restoreState() error {
 	if c.config.DevMode {
@@ -546,6 +583,21 @@ func (c *Client) setupNode() error {
 	if node.Name == "" {
 		node.Name = node.ID
 	}
+
+	// Expose the host volumes whose directories exist
+	if len(c.config.HostVolumes) > 0 {
+		node.HostVolumes = make(map[string]*structs.ClientHostVolumeConfig, len(c.config.HostVolumes))
+		for name, hv := range c.config.HostVolumes {
+			fi, err := os.Stat(hv.Path)
+			if err != nil {
+				return fmt.Errorf("host volume %q: %v", name, err)
+			}
+			if !fi.IsDir() {
+				return fmt.Errorf("host volume %q: path %q is not a directory", name, hv.Path)
+			}
+			node.HostVolumes[name] = hv.Copy()
+		}
+	}
 	node.Status = structs.NodeStatusInit
 	return nil
 }
@@ -617,7 +669,7 @@ func (c *Client) setupDrivers() error {
 
 	var avail []string
 	var skipped []string
-	driverCtx := driver.NewDriverContext("", c.config, c.config.Node, c.logger, nil)
+	driverCtx := driver.NewDriverContext("", c.config, c.config.Node, c.logger, nil, nil, nil)
 	for name := range driver.BuiltinDrivers {
 		// Skip fingerprinting drivers that are not in the whitelist if it is
 		// enabled.
@@ -638,6 +690,12 @@ func (c *Client) setupDrivers() error {
 		}
 		if applies {
 			avail = append(avail, name)
+
+			// Drivers advertising state that changes, like the lxc base
+			// containers cache, are fingerprinted periodically
+			if p, period := d.Periodic(); p {
+				go c.fingerprintPeriodic(name, d, period)
+			}
 		}
 	}
 
@@ -1127,7 +1185,28 @@ func (c *Client) updateAlloc(exist, update *structs.Allocation) error {
 func (c *Client) addAlloc(alloc *structs.Allocation) error {
 	c.configLock.RLock()
 	ar := NewAllocRunner(c.logger, c.configCopy, c.updateAllocStatus, alloc, c.consulService)
+	region := c.configCopy.Region
 	c.configLock.RUnlock()
+
+	// Migrate the ephemeral disk of the previous allocation if requested
+	if tg := alloc.Job.LookupTaskGroup(alloc.TaskGroup); tg != nil &&
+		tg.EphemeralDisk != nil && alloc.PreviousAllocation != "" {
+		c.allocLock.RLock()
+		prev, local := c.allocs[alloc.PreviousAllocation]
+		c.allocLock.RUnlock()
+
+		disk := tg.EphemeralDisk
+		if local && (disk.Sticky || disk.Migrate) {
+			ar.setPrevAlloc(&localPrevAlloc{runner: prev})
+		} else if disk.Migrate {
+			ar.setPrevAlloc(&remotePrevAlloc{
+				allocID: alloc.PreviousAllocation,
+				region:  region,
+				rpc:     c,
+				logger:  c.logger,
+			})
+		}
+	}
 	go ar.Run()
 
 	// Store the alloc runner.
@@ -71,6 +71,10 @@ type Config struct {
 	//	namespace.option = value
 	Options map[string]string
 
+	// HostVolumes is the set of host directories the client exposes to tasks,
+	// keyed by the volume name.
+	HostVolumes map[string]*structs.ClientHostVolumeConfig
+
 	// Version is the version of the Nomad client
 	Version string
 }
@@ -81,6 +85,12 @@ func (c *Config) Copy() *Config {
 	nc.Node = nc.Node.Copy()
 	nc.Servers = structs.CopySliceString(nc.Servers)
 	nc.Options = structs.CopyMapStringString(nc.Options)
+	if c.HostVolumes != nil {
+		nc.HostVolumes = make(map[string]*structs.ClientHostVolumeConfig, len(c.HostVolumes))
+		for name, hv := range c.HostVolumes {
+			nc.HostVolumes[name] = hv.Copy()
+		}
+	}
 	return nc
 }
 
@@ -17,6 +17,10 @@ import (
 
 const (
 	syncInterval = 5 * time.Second
+
+	// kvWaitTime is the maximum time a blocking query on a key waits for the
+	// key to change.
+	kvWaitTime = 5 * time.Minute
 )
 
 // consulApi is the interface which wraps the actual consul api client
@@ -27,6 +31,7 @@ type consulApi interface {
 	ServiceDeregister(ServiceID string) error
 	Services() (map[string]*consul.AgentService, error)
 	Checks() (map[string]*consul.AgentCheck, error)
+	KVGet(key string, q *consul.QueryOptions) (*consul.KVPair, *consul.QueryMeta, error)
 }
 
 // consulApiClient is the actual implementation of the consulApi which
@@ -59,6 +64,10 @@ func (a *consulApiClient) Checks() (map[string]*consul.AgentCheck, error) {
 	return a.client.Agent().Checks()
 }
 
+func (a *consulApiClient) KVGet(key string, q *consul.QueryOptions) (*consul.KVPair, *consul.QueryMeta, error) {
+	return a.client.KV().Get(key, q)
+}
+
 // trackedTask is a Task that we are tracking for changes in service and check
 // definitions and keep them sycned with Consul Agent
 type trackedTask struct {
@@ -193,6 +202,26 @@ func (c *ConsulService) Deregister(task *structs.Task, alloc *structs.Allocation
 	return mErr.ErrorOrNil()
 }
 
+// KVGet reads a key from the Consul KV store and returns its value, whether it
+// exists and the index it was read at. If waitIndex is non-zero a blocking
+// query is made that returns once the index moves past waitIndex or the wait
+// time elapses.
+func (c *ConsulService) KVGet(key string, waitIndex uint64) (string, bool, uint64, error) {
+	q := &consul.QueryOptions{WaitIndex: waitIndex}
+	if waitIndex != 0 {
+		q.WaitTime = kvWaitTime
+	}
+
+	pair, meta, err := c.client.KVGet(key, q)
+	if err != nil {
+		return "", false, 0, err
+	}
+	if pair == nil {
+		return "", false, meta.LastIndex, nil
+	}
+	return string(pair.Value), true, meta.LastIndex, nil
+}
+
 func (c *ConsulService) ShutDown() {
 	close(c.shutdownCh)
 }
@@ -47,6 +47,10 @@ func (a *mockConsulApiClient) Checks() (map[string]*consul.AgentCheck, error) {
 	return make(map[string]*consul.AgentCheck), nil
 }
 
+func (a *mockConsulApiClient) KVGet(key string, q *consul.QueryOptions) (*consul.KVPair, *consul.QueryMeta, error) {
+	return nil, &consul.QueryMeta{}, nil
+}
+
 func newConsulService() *ConsulService {
 	logger := log.New(os.Stdout, "logger: ", log.Lshortfile)
 	c, _ := NewConsulService(&consulServiceConfig{logger, "", "", "", false, false, &structs.Node{}})
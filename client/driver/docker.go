@@ -8,9 +8,11 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	docker "github.com/fsouza/go-dockerclient"
@@ -60,6 +62,36 @@ type DockerDriverConfig struct {
 	LabelsRaw        []map[string]string `mapstructure:"labels"`             //
 	Labels           map[string]string   `mapstructure:"-"`                  // Labels to set when the container starts up
 	Auth             []DockerDriverAuth  `mapstructure:"auth"`               // Authentication credentials for a private Docker registry
+	Volumes          []string            `mapstructure:"volumes"`            // Host paths or task dir paths to bind mount as "source:destination[:mode]"
+	Mounts           []DockerMount       `mapstructure:"mounts"`             // Bind mounts of the container
+	CapAdd           []string            `mapstructure:"cap_add"`            // Linux capabilities to add to the container
+	CapDrop          []string            `mapstructure:"cap_drop"`           // Linux capabilities to drop from the container
+	UlimitRaw        []map[string]string `mapstructure:"ulimit"`             //
+	Ulimits          map[string]string   `mapstructure:"-"`                  // Ulimits of the container, as "soft:hard" or a single limit for both
+	SecurityOpt      []string            `mapstructure:"security_opt"`       // Security options of the container, e.g. for SELinux or AppArmor
+	SysctlRaw        []map[string]string `mapstructure:"sysctl"`             //
+	Sysctls          map[string]string   `mapstructure:"-"`                  // Namespaced kernel parameters to set in the container
+	Devices          []DockerDevice      `mapstructure:"devices"`            // Host devices to expose in the container
+	ShmSize          int64               `mapstructure:"shm_size"`           // Size of /dev/shm in bytes
+	WorkDir          string              `mapstructure:"work_dir"`           // The working directory of the command in the container
+	Entrypoint       []string            `mapstructure:"entrypoint"`         // Overrides the entrypoint of the image
+	ExtraHosts       []string            `mapstructure:"extra_hosts"`        // Entries to add to /etc/hosts as "hostname:ip"
+	MacAddress       string              `mapstructure:"mac_address"`        // MAC address of the container
+	ForcePull        bool                `mapstructure:"force_pull"`         // Always pull the image, even if it is present locally
+}
+
+// DockerMount is a bind mount of the container.
+type DockerMount struct {
+	Source   string `mapstructure:"source"`   // Host path or path relative to the task dir
+	Target   string `mapstructure:"target"`   // Path in the container
+	ReadOnly bool   `mapstructure:"readonly"` // Whether the mount is read only
+}
+
+// DockerDevice is a host device exposed in the container.
+type DockerDevice struct {
+	HostPath          string `mapstructure:"host_path"`          // Path of the device on the host
+	ContainerPath     string `mapstructure:"container_path"`     // Path of the device in the container, defaults to the host path
+	CgroupPermissions string `mapstructure:"cgroup_permissions"` // Any of "r", "w" and "m", defaults to "rwm"
 }
 
 func (c *DockerDriverConfig) Validate() error {
@@ -69,6 +101,36 @@ func (c *DockerDriverConfig) Validate() error {
 
 	c.PortMap = mapMergeStrInt(c.PortMapRaw...)
 	c.Labels = mapMergeStrStr(c.LabelsRaw...)
+	c.Ulimits = mapMergeStrStr(c.UlimitRaw...)
+	c.Sysctls = mapMergeStrStr(c.SysctlRaw...)
+
+	for _, m := range c.Mounts {
+		if m.Source == "" || m.Target == "" {
+			return fmt.Errorf("Docker mounts need a source and a target")
+		}
+	}
+	for _, dev := range c.Devices {
+		if dev.HostPath == "" {
+			return fmt.Errorf("Docker devices need a host path")
+		}
+		if strings.Trim(dev.CgroupPermissions, "rwm") != "" {
+			return fmt.Errorf("Invalid cgroup permissions %q for device %s", dev.CgroupPermissions, dev.HostPath)
+		}
+	}
+	if c.ShmSize < 0 {
+		return fmt.Errorf("Docker shm_size cannot be negative")
+	}
+	for _, host := range c.ExtraHosts {
+		parts := strings.SplitN(host, ":", 2)
+		if len(parts) != 2 || parts[0] == "" || net.ParseIP(parts[1]) == nil {
+			return fmt.Errorf("Invalid extra host %q, expected hostname:ip", host)
+		}
+	}
+	if c.MacAddress != "" {
+		if _, err := net.ParseMAC(c.MacAddress); err != nil {
+			return fmt.Errorf("Invalid mac address %q: %v", c.MacAddress, err)
+		}
+	}
 
 	return nil
 }
@@ -86,9 +148,10 @@ type DockerHandle struct {
 	logCollector     logging.LogCollector
 	client           *docker.Client
 	logger           *log.Logger
+	coordinator      *dockerCoordinator
 	cleanupContainer bool
-	cleanupImage     bool
 	imageID          string
+	imageRef         string
 	containerID      string
 	version          string
 	killTimeout      time.Duration
@@ -153,6 +216,11 @@ func (d *DockerDriver) Fingerprint(cfg *config.Config, node *structs.Node) (bool
 		d.logger.Println("[DEBUG] driver.docker: privileged containers are disabled")
 	}
 
+	if d.config.ReadBoolDefault("docker.volumes.enabled", false) {
+		d.logger.Println("[DEBUG] driver.docker: volumes with host paths are enabled")
+		node.Attributes["docker.volumes.enabled"] = "1"
+	}
+
 	// This is the first operation taken on the client so we'll try to
 	// establish a connection to the Docker daemon. If this fails it means
 	// Docker isn't available so we'll simply disable the docker driver.
@@ -174,12 +242,275 @@ func (d *DockerDriver) containerBinds(alloc *allocdir.AllocDir, task *structs.Ta
 		return nil, fmt.Errorf("Failed to find task local directory: %v", task.Name)
 	}
 
-	return []string{
+	binds := []string{
 		// "z" and "Z" option is to allocate directory with SELinux label.
 		fmt.Sprintf("%s:/%s:rw,z", shared, allocdir.SharedAllocName),
 		// capital "Z" will label with Multi-Category Security (MCS) labels
 		fmt.Sprintf("%s:/%s:rw,Z", local, allocdir.TaskLocal),
-	}, nil
+	}
+
+	// Bind the host volumes
+	for _, m := range d.mounts {
+		mode := "rw"
+		if m.ReadOnly {
+			mode = "ro"
+		}
+		binds = append(binds, fmt.Sprintf("%s:%s:%s", m.HostPath, filepath.Join("/", m.TaskPath), mode))
+	}
+	return binds, nil
+}
+
+// containerVolumes returns the binds of the volumes and mounts of the task.
+// Relative source paths are relative to the task directory, which they can't
+// escape even through symlinks, and host paths are only allowed if
+// docker.volumes.enabled is set on the agent.
+func (d *DockerDriver) containerVolumes(alloc *allocdir.AllocDir, task *structs.Task,
+	driverConfig *DockerDriverConfig) ([]string, error) {
+	taskDir, ok := alloc.TaskDirs[task.Name]
+	if !ok {
+		return nil, fmt.Errorf("Failed to find task local directory: %v", task.Name)
+	}
+
+	specs := append([]string{}, driverConfig.Volumes...)
+	for _, m := range driverConfig.Mounts {
+		mode := "rw"
+		if m.ReadOnly {
+			mode = "ro"
+		}
+		specs = append(specs, fmt.Sprintf("%s:%s:%s", m.Source, m.Target, mode))
+	}
+
+	root, err := filepath.EvalSymlinks(taskDir)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to resolve task directory: %v", err)
+	}
+	volumesEnabled := d.config.ReadBoolDefault("docker.volumes.enabled", false)
+	var binds []string
+	for _, spec := range specs {
+		parts := strings.Split(spec, ":")
+		if len(parts) < 2 || len(parts) > 3 || parts[0] == "" {
+			return nil, fmt.Errorf("Invalid docker volume %q, expected source:destination[:mode]", spec)
+		}
+		if !filepath.IsAbs(parts[1]) {
+			return nil, fmt.Errorf("Invalid docker volume %q, destination must be an absolute path", spec)
+		}
+
+		if filepath.IsAbs(parts[0]) {
+			if !volumesEnabled {
+				return nil, fmt.Errorf("Docker volumes with host paths are disabled on this Nomad agent: %q", spec)
+			}
+		} else {
+			// The source is checked with its symlinks resolved, as they are
+			// followed by the daemon
+			source, err := resolveExistingPath(filepath.Join(taskDir, parts[0]))
+			if err != nil {
+				return nil, fmt.Errorf("Invalid docker volume %q: %v", spec, err)
+			}
+			if source != root && !strings.HasPrefix(source, root+string(filepath.Separator)) {
+				return nil, fmt.Errorf("Invalid docker volume %q, source escapes the task directory", spec)
+			}
+			parts[0] = source
+		}
+		binds = append(binds, strings.Join(parts, ":"))
+	}
+	return binds, nil
+}
+
+// resolveExistingPath returns the path with the symlinks of its longest
+// existing prefix resolved. Dangling symlinks are an error.
+func resolveExistingPath(path string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err == nil {
+		return resolved, nil
+	}
+	if _, lerr := os.Lstat(path); !os.IsNotExist(lerr) {
+		return "", err
+	}
+	parent := filepath.Dir(path)
+	if parent == path {
+		return path, nil
+	}
+	if resolved, err = resolveExistingPath(parent); err != nil {
+		return "", err
+	}
+	return filepath.Join(resolved, filepath.Base(path)), nil
+}
+
+// dockerDefaultCaps are the capabilities Docker grants to containers by
+// default, which may be added back after being dropped.
+const dockerDefaultCaps = "CHOWN,DAC_OVERRIDE,FSETID,FOWNER,MKNOD,NET_RAW,SETGID,SETUID,SETFCAP,SETPCAP,NET_BIND_SERVICE,SYS_CHROOT,KILL,AUDIT_WRITE"
+
+// checkCapabilities returns an error if any of the capabilities to add isn't
+// in the docker.caps.whitelist of the agent. A whitelist of "ALL" allows any
+// capability.
+func (d *DockerDriver) checkCapabilities(caps []string) error {
+	if len(caps) == 0 {
+		return nil
+	}
+	whitelist := make(map[string]struct{})
+	for _, capability := range strings.Split(d.config.ReadDefault("docker.caps.whitelist", dockerDefaultCaps), ",") {
+		whitelist[normalizeCapability(capability)] = struct{}{}
+	}
+	if _, ok := whitelist["ALL"]; ok {
+		return nil
+	}
+
+	var denied []string
+	for _, capability := range caps {
+		if _, ok := whitelist[normalizeCapability(capability)]; !ok {
+			denied = append(denied, capability)
+		}
+	}
+	if len(denied) > 0 {
+		return fmt.Errorf("Docker capabilities not allowed by the docker.caps.whitelist of this Nomad agent: %s", strings.Join(denied, ", "))
+	}
+	return nil
+}
+
+// dockerDefaultSysctls are the sysctls Docker allows to set, which are
+// namespaced.
+const dockerDefaultSysctls = "kernel.msgmax,kernel.msgmnb,kernel.msgmni,kernel.sem,kernel.shmall,kernel.shmmax,kernel.shmmni,kernel.shm_rmid_forced,fs.mqueue.*,net.*"
+
+// checkDevices returns an error if any of the host paths of the devices,
+// with their symlinks resolved, isn't matched by the docker.devices.whitelist
+// of the agent. No devices are allowed by default.
+func (d *DockerDriver) checkDevices(devices []DockerDevice) error {
+	var paths []string
+	for _, dev := range devices {
+		path := filepath.Clean(dev.HostPath)
+		if resolved, err := filepath.EvalSymlinks(path); err == nil {
+			path = resolved
+		}
+		paths = append(paths, path)
+	}
+	return d.checkWhitelist("docker.devices.whitelist", "", "devices", paths)
+}
+
+// checkSysctls returns an error if any of the sysctls isn't matched by the
+// docker.sysctls.whitelist of the agent.
+func (d *DockerDriver) checkSysctls(sysctls map[string]string) error {
+	var keys []string
+	for key := range sysctls {
+		keys = append(keys, strings.Replace(key, "/", ".", -1))
+	}
+	sort.Strings(keys)
+	return d.checkWhitelist("docker.sysctls.whitelist", dockerDefaultSysctls, "sysctls", keys)
+}
+
+// checkWhitelist returns an error if any of the values isn't matched by one
+// of the comma separated glob patterns of the whitelist option of the agent.
+func (d *DockerDriver) checkWhitelist(option, defaultWhitelist, kind string, values []string) error {
+	if len(values) == 0 {
+		return nil
+	}
+	var patterns []string
+	for _, pattern := range strings.Split(d.config.ReadDefault(option, defaultWhitelist), ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+
+	var denied []string
+	for _, value := range values {
+		allowed := false
+		for _, pattern := range patterns {
+			if ok, _ := filepath.Match(pattern, value); ok {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			denied = append(denied, value)
+		}
+	}
+	if len(denied) > 0 {
+		return fmt.Errorf("Docker %s not allowed by the %s of this Nomad agent: %s", kind, option, strings.Join(denied, ", "))
+	}
+	return nil
+}
+
+// checkSecurityOpts returns an error if any of the security options disables
+// the seccomp, AppArmor or SELinux confinement of the container, which needs
+// privileged mode.
+func checkSecurityOpts(opts []string) error {
+	for _, opt := range opts {
+		// Options are either key=value or, for older daemons, key:value
+		key, value := opt, ""
+		if i := strings.IndexAny(opt, "=:"); i >= 0 {
+			key, value = opt[:i], opt[i+1:]
+		}
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.ToLower(strings.TrimSpace(value))
+
+		unconfined := false
+		switch key {
+		case "seccomp", "apparmor", "systempaths":
+			unconfined = value == "unconfined"
+		case "label":
+			unconfined = value == "disable" || value == "type:spc_t" || value == "type:unconfined_t"
+		}
+		if unconfined {
+			return fmt.Errorf("Docker privileged mode is disabled on this Nomad agent, security option %q not allowed", opt)
+		}
+	}
+	return nil
+}
+
+// normalizeCapability returns the capability in upper case without the CAP_
+// prefix.
+func normalizeCapability(capability string) string {
+	return strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(capability)), "CAP_")
+}
+
+// dockerUlimits parses the ulimits of the task, given either as a single
+// limit for both the soft and hard limits or as "soft:hard".
+func dockerUlimits(raw map[string]string) ([]docker.ULimit, error) {
+	names := make([]string, 0, len(raw))
+	for name := range raw {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var ulimits []docker.ULimit
+	for _, name := range names {
+		value := raw[name]
+		parts := strings.SplitN(value, ":", 2)
+		soft, err := strconv.ParseInt(strings.TrimSpace(parts[0]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid ulimit %s %q: %v", name, value, err)
+		}
+		hard := soft
+		if len(parts) == 2 {
+			if hard, err = strconv.ParseInt(strings.TrimSpace(parts[1]), 10, 64); err != nil {
+				return nil, fmt.Errorf("Invalid ulimit %s %q: %v", name, value, err)
+			}
+		}
+		if soft < 0 || soft > hard {
+			return nil, fmt.Errorf("Invalid ulimit %s %q: soft limit must be between 0 and the hard limit", name, value)
+		}
+		ulimits = append(ulimits, docker.ULimit{Name: name, Soft: soft, Hard: hard})
+	}
+	return ulimits, nil
+}
+
+// dockerDevices returns the devices of the task, defaulting the container
+// path to the host path and the permissions to "rwm".
+func dockerDevices(devices []DockerDevice) []docker.Device {
+	var result []docker.Device
+	for _, dev := range devices {
+		d := docker.Device{
+			PathOnHost:        dev.HostPath,
+			PathInContainer:   dev.ContainerPath,
+			CgroupPermissions: dev.CgroupPermissions,
+		}
+		if d.PathInContainer == "" {
+			d.PathInContainer = dev.HostPath
+		}
+		if d.CgroupPermissions == "" {
+			d.CgroupPermissions = "rwm"
+		}
+		result = append(result, d)
+	}
+	return result
 }
 
 // createContainer initializes a struct needed to call docker.client.CreateContainer()
@@ -197,14 +528,21 @@ func (d *DockerDriver) createContainer(ctx *ExecContext, task *structs.Task,
 	if err != nil {
 		return c, err
 	}
+	volumes, err := d.containerVolumes(ctx.AllocDir, task, driverConfig)
+	if err != nil {
+		return c, err
+	}
+	binds = append(binds, volumes...)
 
 	// Set environment variables.
 	d.taskEnv.SetAllocDir(filepath.Join("/", allocdir.SharedAllocName))
 	d.taskEnv.SetTaskLocalDir(filepath.Join("/", allocdir.TaskLocal))
 
 	config := &docker.Config{
-		Image:    driverConfig.ImageName,
-		Hostname: driverConfig.Hostname,
+		Image:      driverConfig.ImageName,
+		Hostname:   driverConfig.Hostname,
+		WorkingDir: driverConfig.WorkDir,
+		MacAddress: driverConfig.MacAddress,
 	}
 
 	hostConfig := &docker.HostConfig{
@@ -260,6 +598,46 @@ func (d *DockerDriver) createContainer(ctx *ExecContext, task *structs.Task,
 	}
 	hostConfig.Privileged = hostPrivileged
 
+	// set capabilities, only the whitelisted ones may be added unless the
+	// container is privileged
+	if !driverConfig.Privileged {
+		if err := d.checkCapabilities(driverConfig.CapAdd); err != nil {
+			return c, err
+		}
+	}
+	hostConfig.CapAdd = driverConfig.CapAdd
+	hostConfig.CapDrop = driverConfig.CapDrop
+
+	ulimits, err := dockerUlimits(driverConfig.Ulimits)
+	if err != nil {
+		return c, err
+	}
+	hostConfig.Ulimits = ulimits
+
+	// security options disabling the confinement of the container need
+	// privileged mode, and only the whitelisted devices and sysctls may be
+	// set unless the container is privileged
+	if !hostPrivileged {
+		if err := checkSecurityOpts(driverConfig.SecurityOpt); err != nil {
+			return c, err
+		}
+	}
+	hostConfig.SecurityOpt = driverConfig.SecurityOpt
+	if !driverConfig.Privileged {
+		if err := d.checkDevices(driverConfig.Devices); err != nil {
+			return c, err
+		}
+		if err := d.checkSysctls(driverConfig.Sysctls); err != nil {
+			return c, err
+		}
+	}
+	if len(driverConfig.Sysctls) > 0 {
+		hostConfig.Sysctls = driverConfig.Sysctls
+	}
+	hostConfig.Devices = dockerDevices(driverConfig.Devices)
+	hostConfig.ShmSize = driverConfig.ShmSize
+	hostConfig.ExtraHosts = driverConfig.ExtraHosts
+
 	// set DNS servers
 	for _, ip := range driverConfig.DNSServers {
 		if net.ParseIP(ip) != nil {
@@ -386,6 +764,11 @@ func (d *DockerDriver) createContainer(ctx *ExecContext, task *structs.Task,
 		d.logger.Println("[DEBUG] driver.docker: ignoring command arguments because command is not specified")
 	}
 
+	if len(driverConfig.Entrypoint) != 0 {
+		d.logger.Printf("[DEBUG] driver.docker: setting container entrypoint to: %s", strings.Join(driverConfig.Entrypoint, " "))
+		config.Entrypoint = driverConfig.Entrypoint
+	}
+
 	if len(driverConfig.Labels) > 0 {
 		config.Labels = driverConfig.Labels
 		d.logger.Printf("[DEBUG] driver.docker: applied labels on the container: %+v", config.Labels)
@@ -424,13 +807,13 @@ func (d *DockerDriver) Start(ctx *ExecContext, task *structs.Task) (DriverHandle
 	}
 
 	cleanupContainer := d.config.ReadBoolDefault("docker.cleanup.container", true)
-	cleanupImage := d.config.ReadBoolDefault("docker.cleanup.image", true)
 
 	// Initialize docker API client
 	client, err := d.dockerClient()
 	if err != nil {
 		return nil, fmt.Errorf("Failed to connect to docker daemon: %s", err)
 	}
+	coordinator := getDockerCoordinator(client, d.config, d.logger)
 
 	repo, tag := docker.ParseRepositoryTag(image)
 	// Make sure tag is always explicitly set. We'll default to "latest" if it
@@ -441,9 +824,10 @@ func (d *DockerDriver) Start(ctx *ExecContext, task *structs.Task) (DriverHandle
 
 	var dockerImage *docker.Image
 	// We're going to check whether the image is already downloaded. If the tag
-	// is "latest" we have to check for a new version every time so we don't
-	// bother to check and cache the id here. We'll download first, then cache.
-	if tag != "latest" {
+	// is "latest" or the pull is forced we have to check for a new version
+	// every time so we don't bother to check and cache the id here. We'll
+	// download first, then cache.
+	if tag != "latest" && !driverConfig.ForcePull {
 		dockerImage, err = client.InspectImage(image)
 	}
 
@@ -454,45 +838,32 @@ func (d *DockerDriver) Start(ctx *ExecContext, task *structs.Task) (DriverHandle
 			Tag:        tag,
 		}
 
-		authOptions := docker.AuthConfiguration{}
-		if len(driverConfig.Auth) != 0 {
-			authOptions = docker.AuthConfiguration{
-				Username:      driverConfig.Auth[0].Username,
-				Password:      driverConfig.Auth[0].Password,
-				Email:         driverConfig.Auth[0].Email,
-				ServerAddress: driverConfig.Auth[0].ServerAddress,
-			}
-		}
-
-		if authConfigFile := d.config.Read("docker.auth.config"); authConfigFile != "" {
-			if f, err := os.Open(authConfigFile); err == nil {
-				defer f.Close()
-				var authConfigurations *docker.AuthConfigurations
-				if authConfigurations, err = docker.NewAuthConfigurations(f); err != nil {
-					return nil, fmt.Errorf("Failed to create docker auth object: %v", err)
-				}
-				if authConfiguration, ok := authConfigurations.Configs[repo]; ok {
-					authOptions = authConfiguration
-				}
-			} else {
-				return nil, fmt.Errorf("Failed to open auth config file: %v, error: %v", authConfigFile, err)
-			}
+		authOptions, err := d.resolveRegistryAuth(repo, driverConfig.Auth)
+		if err != nil {
+			d.logger.Printf("[ERR] driver.docker: failed to resolve auth for %s: %s", repo, err)
+			return nil, fmt.Errorf("Failed to resolve registry auth for `%s`: %s", image, err)
 		}
 
-		err = client.PullImage(pullOptions, authOptions)
+		// Concurrent pulls of the image by other tasks are coalesced
+		imageID, err := coordinator.PullImage(image, pullOptions, authOptions, d.emitEvent)
 		if err != nil {
 			d.logger.Printf("[ERR] driver.docker: failed pulling container %s:%s: %s", repo, tag, err)
 			return nil, fmt.Errorf("Failed to pull `%s`: %s", image, err)
 		}
 		d.logger.Printf("[DEBUG] driver.docker: docker pull %s:%s succeeded", repo, tag)
+		dockerImage = &docker.Image{ID: imageID}
+	}
 
-		// Now that we have the image we can get the image id
-		dockerImage, err = client.InspectImage(image)
-		if err != nil {
-			d.logger.Printf("[ERR] driver.docker: failed getting image id for %s: %s", image, err)
-			return nil, fmt.Errorf("Failed to determine image id for `%s`: %s", image, err)
+	// Reference the image so it isn't removed while the task uses it. The
+	// reference is dropped if the task fails to start.
+	imageRef := dockerImageRef(ctx.AllocID, task.Name)
+	coordinator.IncrementImageReference(dockerImage.ID, imageRef)
+	started := false
+	defer func() {
+		if !started {
+			coordinator.RemoveImage(dockerImage.ID, imageRef)
 		}
-	}
+	}()
 
 	taskDir, ok := ctx.AllocDir.TaskDirs[d.DriverContext.taskName]
 	if !ok {
@@ -602,23 +973,24 @@ func (d *DockerDriver) Start(ctx *ExecContext, task *structs.Task) (DriverHandle
 		client:           client,
 		logCollector:     logCollector,
 		pluginClient:     pluginClient,
+		coordinator:      coordinator,
 		cleanupContainer: cleanupContainer,
-		cleanupImage:     cleanupImage,
 		logger:           d.logger,
 		imageID:          dockerImage.ID,
+		imageRef:         imageRef,
 		containerID:      container.ID,
 		version:          d.config.Version,
 		killTimeout:      d.DriverContext.KillTimeout(task),
 		doneCh:           make(chan struct{}),
 		waitCh:           make(chan *cstructs.WaitResult, 1),
 	}
+	started = true
 	go h.run()
 	return h, nil
 }
 
 func (d *DockerDriver) Open(ctx *ExecContext, handleID string) (DriverHandle, error) {
 	cleanupContainer := d.config.ReadBoolDefault("docker.cleanup.container", true)
-	cleanupImage := d.config.ReadBoolDefault("docker.cleanup.image", true)
 
 	// Split the handle
 	pidBytes := []byte(strings.TrimPrefix(handleID, "DOCKER:"))
@@ -665,15 +1037,21 @@ func (d *DockerDriver) Open(ctx *ExecContext, handleID string) (DriverHandle, er
 		return nil, err
 	}
 
+	// Reference the image again, in case the references were lost
+	coordinator := getDockerCoordinator(client, d.config, d.logger)
+	imageRef := dockerImageRef(ctx.AllocID, d.taskName)
+	coordinator.IncrementImageReference(pid.ImageID, imageRef)
+
 	// Return a driver handle
 	h := &DockerHandle{
 		client:           client,
 		logCollector:     logCollector,
 		pluginClient:     pluginClient,
+		coordinator:      coordinator,
 		cleanupContainer: cleanupContainer,
-		cleanupImage:     cleanupImage,
 		logger:           d.logger,
 		imageID:          pid.ImageID,
+		imageRef:         imageRef,
 		containerID:      pid.ContainerID,
 		version:          pid.Version,
 		killTimeout:      pid.KillTimeout,
@@ -684,6 +1062,11 @@ func (d *DockerDriver) Open(ctx *ExecContext, handleID string) (DriverHandle, er
 	return h, nil
 }
 
+// dockerImageRef returns the reference of the task to its image.
+func dockerImageRef(allocID, task string) string {
+	return fmt.Sprintf("%s/%s", allocID, task)
+}
+
 func (h *DockerHandle) ID() string {
 	// Return a handle to the PID
 	pid := dockerPID{
@@ -719,6 +1102,62 @@ func (h *DockerHandle) Update(task *structs.Task) error {
 	return nil
 }
 
+func (h *DockerHandle) Exec(opts *cstructs.ExecOptions) (int, error) {
+	exec, err := h.client.CreateExec(docker.CreateExecOptions{
+		Container:    h.containerID,
+		Cmd:          opts.Command,
+		AttachStdin:  opts.Stdin != nil,
+		AttachStdout: true,
+		AttachStderr: !opts.Tty,
+		Tty:          opts.Tty,
+	})
+	if err != nil {
+		return -1, fmt.Errorf("Failed to create exec in container %s: %s", h.containerID, err)
+	}
+
+	cw, err := h.client.StartExecNonBlocking(exec.ID, docker.StartExecOptions{
+		InputStream:  opts.Stdin,
+		OutputStream: opts.Stdout,
+		ErrorStream:  opts.Stderr,
+		Tty:          opts.Tty,
+		RawTerminal:  opts.Tty,
+	})
+	if err != nil {
+		return -1, fmt.Errorf("Failed to start exec in container %s: %s", h.containerID, err)
+	}
+	defer cw.Close()
+
+	// Relay the terminal size changes until the exec finishes
+	doneCh := make(chan struct{})
+	defer close(doneCh)
+	if opts.Tty {
+		go func() {
+			for {
+				select {
+				case size, ok := <-opts.ResizeCh:
+					if !ok {
+						return
+					}
+					if err := h.client.ResizeExecTTY(exec.ID, int(size.Height), int(size.Width)); err != nil {
+						h.logger.Printf("[DEBUG] driver.docker: failed to resize exec %s: %v", exec.ID, err)
+					}
+				case <-doneCh:
+					return
+				}
+			}
+		}()
+	}
+
+	if err := cw.Wait(); err != nil {
+		return -1, fmt.Errorf("Failed to run exec in container %s: %s", h.containerID, err)
+	}
+	inspect, err := h.client.InspectExec(exec.ID)
+	if err != nil {
+		return -1, fmt.Errorf("Failed to inspect exec in container %s: %s", h.containerID, err)
+	}
+	return inspect.ExitCode, nil
+}
+
 // Kill is used to terminate the task. This uses `docker stop -t killTimeout`
 func (h *DockerHandle) Kill() error {
 	// Stop the container
@@ -742,31 +1181,24 @@ func (h *DockerHandle) Kill() error {
 		h.logger.Printf("[INFO] driver.docker: removed container %s", h.containerID)
 	}
 
-	// Cleanup image. This operation may fail if the image is in use by another
-	// job. That is OK. Will we log a message but continue.
-	if h.cleanupImage {
-		err = h.client.RemoveImage(h.imageID)
-		if err != nil {
-			containers, err := h.client.ListContainers(docker.ListContainersOptions{
-				// The image might be in use by a stopped container, so check everything
-				All: true,
-				Filters: map[string][]string{
-					"image": []string{h.imageID},
-				},
-			})
-			if err != nil {
-				h.logger.Printf("[ERR] driver.docker: failed to query list of containers matching image:%s", h.imageID)
-				return fmt.Errorf("Failed to query list of containers: %s", err)
-			}
-			inUse := len(containers)
-			if inUse > 0 {
-				h.logger.Printf("[INFO] driver.docker: image %s is still in use by %d container(s)", h.imageID, inUse)
-			} else {
-				return fmt.Errorf("Failed to remove image %s", h.imageID)
-			}
-		} else {
-			h.logger.Printf("[INFO] driver.docker: removed image %s", h.imageID)
-		}
+	// Drop the reference of the task to its image, which is removed once no
+	// task has used it for a while
+	h.coordinator.RemoveImage(h.imageID, h.imageRef)
+	return nil
+}
+
+// Signal sends the signal to the container.
+func (h *DockerHandle) Signal(s os.Signal) error {
+	sig, ok := s.(syscall.Signal)
+	if !ok {
+		return fmt.Errorf("Failed to signal container %s: unsupported signal %v", h.containerID, s)
+	}
+	opts := docker.KillContainerOptions{
+		ID:     h.containerID,
+		Signal: docker.Signal(sig),
+	}
+	if err := h.client.KillContainer(opts); err != nil {
+		return fmt.Errorf("Failed to signal container %s: %s", h.containerID, err)
 	}
 	return nil
 }
@@ -782,10 +1214,19 @@ func (h *DockerHandle) run() {
 		err = fmt.Errorf("Docker container exited with non-zero exit code: %d", exitCode)
 	}
 
+	// Check whether the container was killed by the OOM killer
+	res := cstructs.NewWaitResult(exitCode, 0, err)
+	if container, ierr := h.client.InspectContainer(h.containerID); ierr == nil {
+		res.OOMKilled = container.State.OOMKilled
+	}
+
 	close(h.doneCh)
-	h.waitCh <- cstructs.NewWaitResult(exitCode, 0, err)
+	h.waitCh <- res
 	close(h.waitCh)
 
+	// The task no longer uses its image, unless it is restarted
+	h.coordinator.RemoveImage(h.imageID, h.imageRef)
+
 	// Shutdown the syslog collector
 	if err := h.logCollector.Exit(); err != nil {
 		h.logger.Printf("[ERR] driver.docker: failed to kill the syslog collector: %v", err)
@@ -0,0 +1,232 @@
+package driver
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"strings"
+	"time"
+
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+const (
+	// dockerHubRegistry is the registry of the images without a registry.
+	dockerHubRegistry = "docker.io"
+
+	// dockerHubServerAddress is the server address the auth of Docker Hub is
+	// recorded under.
+	dockerHubServerAddress = "https://index.docker.io/v1/"
+
+	// dockerAuthHelperPrefix is the prefix of the credential helper binaries.
+	dockerAuthHelperPrefix = "docker-credential-"
+
+	// dockerAuthHelperTimeout is how long a credential helper may run.
+	dockerAuthHelperTimeout = 30 * time.Second
+)
+
+// dockerAuthFile is a docker config file with the auth of registries, in the
+// format of ~/.docker/config.json.
+type dockerAuthFile struct {
+	Auths       map[string]dockerAuthEntry `json:"auths"`
+	CredHelpers map[string]string          `json:"credHelpers"`
+	CredsStore  string                     `json:"credsStore"`
+}
+
+// dockerAuthEntry is the auth of a registry in a docker config file.
+type dockerAuthEntry struct {
+	Auth     string `json:"auth"` // base64 encoded "username:password"
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Email    string `json:"email"`
+}
+
+// dockerAuthHelperOutput is the output of the get command of a credential
+// helper.
+type dockerAuthHelperOutput struct {
+	ServerURL string
+	Username  string
+	Secret    string
+}
+
+// resolveRegistryAuth returns the auth to pull the image of the repository.
+// The auth of the job takes precedence, then the auth of the registry in the
+// docker.auth.config file and last the credentials of its credential helper.
+// The credential helper of the registry is the one of the credHelpers of the
+// config file, else the docker.auth.helper of the client or the credsStore of
+// the config file.
+func (d *DockerDriver) resolveRegistryAuth(repo string, jobAuth []DockerDriverAuth) (docker.AuthConfiguration, error) {
+	if len(jobAuth) != 0 {
+		return docker.AuthConfiguration{
+			Username:      jobAuth[0].Username,
+			Password:      jobAuth[0].Password,
+			Email:         jobAuth[0].Email,
+			ServerAddress: jobAuth[0].ServerAddress,
+		}, nil
+	}
+
+	registry := dockerRegistry(repo)
+	helper := d.config.Read("docker.auth.helper")
+	if path := d.config.Read("docker.auth.config"); path != "" {
+		file, err := loadDockerAuthFile(path)
+		if err != nil {
+			return docker.AuthConfiguration{}, err
+		}
+		if auth, ok, err := file.lookup(registry); err != nil || ok {
+			return auth, err
+		}
+		if h := file.helper(registry); h != "" {
+			helper = h
+		} else if helper == "" {
+			helper = file.CredsStore
+		}
+	}
+	if helper == "" {
+		return docker.AuthConfiguration{}, nil
+	}
+	d.logger.Printf("[DEBUG] driver.docker: getting auth for registry %s from credential helper %s", registry, helper)
+	return dockerHelperAuth(helper, registry)
+}
+
+// loadDockerAuthFile reads the docker config file. Files in the legacy
+// .dockercfg format, without the auths key, are supported too.
+func loadDockerAuthFile(path string) (*dockerAuthFile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open auth config file: %v, error: %v", path, err)
+	}
+	var file dockerAuthFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("Failed to parse auth config file %v: %v", path, err)
+	}
+	if file.Auths == nil && file.CredHelpers == nil && file.CredsStore == "" {
+		if err := json.Unmarshal(data, &file.Auths); err != nil {
+			return nil, fmt.Errorf("Failed to parse auth config file %v: %v", path, err)
+		}
+	}
+	return &file, nil
+}
+
+// lookup returns the auth of the registry in the file, if any. Entries
+// without credentials, which docker writes for the registries stored in a
+// credential helper, are not a match.
+func (f *dockerAuthFile) lookup(registry string) (docker.AuthConfiguration, bool, error) {
+	for server, entry := range f.Auths {
+		if normalizeDockerRegistry(server) != registry {
+			continue
+		}
+		if entry.Auth == "" && entry.Username == "" && entry.Password == "" {
+			continue
+		}
+		auth := docker.AuthConfiguration{
+			Username:      entry.Username,
+			Password:      entry.Password,
+			Email:         entry.Email,
+			ServerAddress: dockerServerAddress(registry),
+		}
+		if entry.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+			if err != nil {
+				return auth, false, fmt.Errorf("Invalid auth of registry %s: %v", server, err)
+			}
+			parts := strings.SplitN(string(decoded), ":", 2)
+			if len(parts) != 2 {
+				return auth, false, fmt.Errorf("Invalid auth of registry %s: expected username:password", server)
+			}
+			auth.Username, auth.Password = parts[0], parts[1]
+		}
+		return auth, true, nil
+	}
+	return docker.AuthConfiguration{}, false, nil
+}
+
+// helper returns the credential helper configured for the registry, if any.
+func (f *dockerAuthFile) helper(registry string) string {
+	for server, helper := range f.CredHelpers {
+		if normalizeDockerRegistry(server) == registry {
+			return helper
+		}
+	}
+	return ""
+}
+
+// dockerHelperAuth returns the credentials of the registry stored by the
+// credential helper. No credentials are returned if the helper has none for
+// the registry.
+func dockerHelperAuth(helper, registry string) (docker.AuthConfiguration, error) {
+	bin, err := exec.LookPath(dockerAuthHelperPrefix + helper)
+	if err != nil {
+		return docker.AuthConfiguration{}, fmt.Errorf("Failed to find credential helper %s: %v", helper, err)
+	}
+
+	serverAddress := dockerServerAddress(registry)
+	cmd := exec.Command(bin, "get")
+	cmd.Stdin = strings.NewReader(serverAddress)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return docker.AuthConfiguration{}, fmt.Errorf("Failed to run credential helper %s: %v", helper, err)
+	}
+	timer := time.AfterFunc(dockerAuthHelperTimeout, func() {
+		cmd.Process.Kill()
+	})
+	err = cmd.Wait()
+	timer.Stop()
+	if err != nil {
+		// Helpers report missing credentials on stdout
+		output := strings.TrimSpace(stdout.String() + stderr.String())
+		if strings.Contains(output, "credentials not found") {
+			return docker.AuthConfiguration{}, nil
+		}
+		return docker.AuthConfiguration{}, fmt.Errorf("Credential helper %s failed: %v: %s", helper, err, output)
+	}
+
+	var out dockerAuthHelperOutput
+	if err := json.Unmarshal(stdout.Bytes(), &out); err != nil {
+		return docker.AuthConfiguration{}, fmt.Errorf("Failed to parse the output of credential helper %s: %v", helper, err)
+	}
+	return docker.AuthConfiguration{
+		Username:      out.Username,
+		Password:      out.Secret,
+		ServerAddress: serverAddress,
+	}, nil
+}
+
+// dockerRegistry returns the registry of the repository, which is its first
+// component if it is a host name, and Docker Hub otherwise.
+func dockerRegistry(repo string) string {
+	parts := strings.SplitN(repo, "/", 2)
+	if len(parts) == 1 {
+		return dockerHubRegistry
+	}
+	if !strings.ContainsAny(parts[0], ".:") && parts[0] != "localhost" {
+		return dockerHubRegistry
+	}
+	return normalizeDockerRegistry(parts[0])
+}
+
+// normalizeDockerRegistry returns the host of the registry of the server
+// address, as recorded in docker config files.
+func normalizeDockerRegistry(server string) string {
+	server = strings.TrimPrefix(server, "https://")
+	server = strings.TrimPrefix(server, "http://")
+	server = strings.SplitN(server, "/", 2)[0]
+	switch server {
+	case "index.docker.io", "registry-1.docker.io":
+		return dockerHubRegistry
+	}
+	return server
+}
+
+// dockerServerAddress returns the server address the auth of the registry is
+// recorded under.
+func dockerServerAddress(registry string) string {
+	if registry == dockerHubRegistry {
+		return dockerHubServerAddress
+	}
+	return registry
+}
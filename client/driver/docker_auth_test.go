@@ -0,0 +1,346 @@
+package driver
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/hashicorp/nomad/nomad/structs"
+)
+
+// fakeCredentialHelper is a credential helper which has credentials for
+// registry.example.com and records the server addresses it was asked for.
+const fakeCredentialHelper = `#!/bin/sh
+read server
+echo "$server" >> "$(dirname "$0")/requests"
+case "$server" in
+registry.example.com)
+	echo '{"ServerURL":"registry.example.com","Username":"helper-user","Secret":"helper-secret"}'
+	;;
+broken.example.com)
+	echo "helper is broken" >&2
+	exit 1
+	;;
+*)
+	echo "credentials not found in native keychain"
+	exit 1
+	;;
+esac
+`
+
+// testCredentialHelper installs the fake credential helper as
+// docker-credential-fake on the PATH and returns its directory.
+func testCredentialHelper(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "docker-auth")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, dockerAuthHelperPrefix+"fake"), []byte(fakeCredentialHelper), 0755); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	path := os.Getenv("PATH")
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+path)
+	return dir, func() {
+		os.Setenv("PATH", path)
+		os.RemoveAll(dir)
+	}
+}
+
+// testAuthDriver returns a docker driver of a client with the options.
+func testAuthDriver(options map[string]string) *DockerDriver {
+	task := &structs.Task{Name: "redis-demo"}
+	driverCtx, execCtx := testDriverContexts(task)
+	execCtx.AllocDir.Destroy()
+	driverCtx.config.Options = options
+	return NewDockerDriver(driverCtx).(*DockerDriver)
+}
+
+// writeAuthFile writes the docker config file in the directory.
+func writeAuthFile(t *testing.T, dir string, config interface{}) string {
+	data, err := json.Marshal(config)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	path := filepath.Join(dir, "config.json")
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return path
+}
+
+func basicAuth(username, password string) string {
+	return base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+}
+
+func TestDockerAuth_Registry(t *testing.T) {
+	cases := map[string]string{
+		"redis":                          "docker.io",
+		"library/redis":                  "docker.io",
+		"docker.io/library/redis":        "docker.io",
+		"registry.example.com/team/app":  "registry.example.com",
+		"localhost/app":                  "localhost",
+		"127.0.0.1:5000/app":             "127.0.0.1:5000",
+		"https://index.docker.io/v1/":    "docker.io",
+		"http://registry.example.com/v2": "registry.example.com",
+	}
+	for repo, expected := range cases {
+		registry := dockerRegistry(repo)
+		if strings.Contains(repo, "://") {
+			registry = normalizeDockerRegistry(repo)
+		}
+		if registry != expected {
+			t.Errorf("registry of %q is %q; want %q", repo, registry, expected)
+		}
+	}
+}
+
+func TestDockerAuth_ConfigFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "docker-auth")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := writeAuthFile(t, dir, map[string]interface{}{
+		"auths": map[string]interface{}{
+			"https://index.docker.io/v1/": map[string]string{"auth": basicAuth("hub-user", "hub:pass")},
+			"registry.example.com":        map[string]string{"auth": basicAuth("user", "pass"), "email": "user@example.com"},
+		},
+	})
+	d := testAuthDriver(map[string]string{"docker.auth.config": path})
+
+	auth, err := d.resolveRegistryAuth("registry.example.com/team/app", nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	expected := docker.AuthConfiguration{
+		Username:      "user",
+		Password:      "pass",
+		Email:         "user@example.com",
+		ServerAddress: "registry.example.com",
+	}
+	if auth != expected {
+		t.Fatalf("got %#v; want %#v", auth, expected)
+	}
+
+	auth, err = d.resolveRegistryAuth("redis", nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if auth.Username != "hub-user" || auth.Password != "hub:pass" || auth.ServerAddress != dockerHubServerAddress {
+		t.Fatalf("bad docker hub auth: %#v", auth)
+	}
+
+	// Registries without auth pull anonymously
+	auth, err = d.resolveRegistryAuth("other.example.com/app", nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if auth != (docker.AuthConfiguration{}) {
+		t.Fatalf("expected no auth, got %#v", auth)
+	}
+
+	// The auth of the job takes precedence
+	jobAuth := []DockerDriverAuth{{Username: "job-user", Password: "job-pass"}}
+	auth, err = d.resolveRegistryAuth("registry.example.com/team/app", jobAuth)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if auth.Username != "job-user" || auth.Password != "job-pass" {
+		t.Fatalf("job auth didn't take precedence: %#v", auth)
+	}
+}
+
+func TestDockerAuth_LegacyConfigFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "docker-auth")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := writeAuthFile(t, dir, map[string]interface{}{
+		"registry.example.com": map[string]string{"auth": basicAuth("user", "pass")},
+	})
+	d := testAuthDriver(map[string]string{"docker.auth.config": path})
+
+	auth, err := d.resolveRegistryAuth("registry.example.com/app", nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if auth.Username != "user" || auth.Password != "pass" {
+		t.Fatalf("bad auth: %#v", auth)
+	}
+
+	// Missing and invalid files fail the pull
+	d = testAuthDriver(map[string]string{"docker.auth.config": filepath.Join(dir, "missing.json")})
+	if _, err := d.resolveRegistryAuth("redis", nil); err == nil {
+		t.Fatalf("expected missing auth config file to fail")
+	}
+	path = writeAuthFile(t, dir, map[string]interface{}{
+		"auths": map[string]interface{}{"redis.example.com": map[string]string{"auth": "not base64"}},
+	})
+	d = testAuthDriver(map[string]string{"docker.auth.config": path})
+	if _, err := d.resolveRegistryAuth("redis.example.com/app", nil); err == nil {
+		t.Fatalf("expected invalid auth to fail")
+	}
+}
+
+func TestDockerAuth_Helper(t *testing.T) {
+	dir, cleanup := testCredentialHelper(t)
+	defer cleanup()
+
+	// The helper configured on the client
+	d := testAuthDriver(map[string]string{"docker.auth.helper": "fake"})
+	auth, err := d.resolveRegistryAuth("registry.example.com/team/app", nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	expected := docker.AuthConfiguration{
+		Username:      "helper-user",
+		Password:      "helper-secret",
+		ServerAddress: "registry.example.com",
+	}
+	if auth != expected {
+		t.Fatalf("got %#v; want %#v", auth, expected)
+	}
+
+	// Registries the helper has no credentials for pull anonymously
+	auth, err = d.resolveRegistryAuth("redis", nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if auth != (docker.AuthConfiguration{}) {
+		t.Fatalf("expected no auth, got %#v", auth)
+	}
+
+	// Failures of the helper fail the pull
+	if _, err := d.resolveRegistryAuth("broken.example.com/app", nil); err == nil || !strings.Contains(err.Error(), "helper is broken") {
+		t.Fatalf("expected the helper to fail, got: %v", err)
+	}
+
+	requests, err := ioutil.ReadFile(filepath.Join(dir, "requests"))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	expectedRequests := "registry.example.com\nhttps://index.docker.io/v1/\nbroken.example.com\n"
+	if string(requests) != expectedRequests {
+		t.Fatalf("helper got requests %q; want %q", requests, expectedRequests)
+	}
+
+	// Unknown helpers fail the pull
+	d = testAuthDriver(map[string]string{"docker.auth.helper": "missing"})
+	if _, err := d.resolveRegistryAuth("registry.example.com/app", nil); err == nil {
+		t.Fatalf("expected missing helper to fail")
+	}
+}
+
+func TestDockerAuth_ConfigFileHelpers(t *testing.T) {
+	dir, cleanup := testCredentialHelper(t)
+	defer cleanup()
+
+	// The helpers of the registries take precedence over the default ones,
+	// and the auths of the file over the helpers
+	path := writeAuthFile(t, dir, map[string]interface{}{
+		"auths": map[string]interface{}{
+			"other.example.com": map[string]string{"auth": basicAuth("file-user", "file-pass")},
+		},
+		"credHelpers": map[string]string{"registry.example.com": "fake"},
+		"credsStore":  "missing",
+	})
+	d := testAuthDriver(map[string]string{"docker.auth.config": path, "docker.auth.helper": "missing"})
+
+	auth, err := d.resolveRegistryAuth("registry.example.com/app", nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if auth.Username != "helper-user" {
+		t.Fatalf("bad auth: %#v", auth)
+	}
+	auth, err = d.resolveRegistryAuth("other.example.com/app", nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if auth.Username != "file-user" {
+		t.Fatalf("bad auth: %#v", auth)
+	}
+
+	// The credsStore of the file is used when the client has no helper
+	d = testAuthDriver(map[string]string{"docker.auth.config": path})
+	if _, err := d.resolveRegistryAuth("third.example.com/app", nil); err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Fatalf("expected the credsStore to be used, got: %v", err)
+	}
+}
+
+func TestDockerAuth_ConfigFileEmptyAuth(t *testing.T) {
+	dir, cleanup := testCredentialHelper(t)
+	defer cleanup()
+
+	// docker login writes an empty auth for the registries it stores in a
+	// credential helper, which must not hide the helper
+	path := writeAuthFile(t, dir, map[string]interface{}{
+		"auths": map[string]interface{}{
+			"registry.example.com": map[string]string{},
+		},
+		"credsStore": "fake",
+	})
+	d := testAuthDriver(map[string]string{"docker.auth.config": path})
+
+	auth, err := d.resolveRegistryAuth("registry.example.com/app", nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if auth.Username != "helper-user" || auth.Password != "helper-secret" {
+		t.Fatalf("bad auth: %#v", auth)
+	}
+}
+
+// This test should always pass, even if docker daemon is not available
+func TestDockerAuth_Pull(t *testing.T) {
+	_, cleanup := testCredentialHelper(t)
+	defer cleanup()
+
+	// The stand-in of the daemon records the auth it would use to pull from
+	// the registry
+	authCh := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/images/create") {
+			http.NotFound(w, r)
+			return
+		}
+		authCh <- r.Header.Get("X-Registry-Auth")
+		fmt.Fprintln(w, `{"status":"Pull complete","id":"a"}`)
+	}))
+	defer server.Close()
+
+	client, err := docker.NewClient(server.URL)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	d := testAuthDriver(map[string]string{"docker.auth.helper": "fake"})
+	repo := "registry.example.com/team/app"
+	auth, err := d.resolveRegistryAuth(repo, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := client.PullImage(docker.PullImageOptions{Repository: repo, Tag: "1.0"}, auth); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	data, err := base64.URLEncoding.DecodeString(<-authCh)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var sent docker.AuthConfiguration
+	if err := json.Unmarshal(data, &sent); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if sent.Username != "helper-user" || sent.Password != "helper-secret" || sent.ServerAddress != "registry.example.com" {
+		t.Fatalf("bad auth sent to the daemon: %#v", sent)
+	}
+}
@@ -0,0 +1,519 @@
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/hashicorp/nomad/client/config"
+	"github.com/hashicorp/nomad/nomad/structs"
+	"golang.org/x/net/context"
+)
+
+const (
+	// dockerImageRefsFile is the file in the state directory of the client
+	// recording the images used by the docker tasks, so they are kept across
+	// client restarts.
+	dockerImageRefsFile = "docker-image-refs.json"
+
+	// dockerImageRemoveDelayOption is the client option setting how long an
+	// image is kept once no task uses it.
+	dockerImageRemoveDelayOption = "docker.cleanup.image.delay"
+
+	// dockerImageRemoveDelayDefault is how long an unused image is kept by
+	// default.
+	dockerImageRemoveDelayDefault = 3 * time.Minute
+
+	// dockerPullTimeoutOption is the client option setting how long a pull
+	// may make no progress before it is abandoned.
+	dockerPullTimeoutOption = "docker.pull.activity_timeout"
+
+	// dockerPullTimeoutDefault is how long a pull may make no progress by
+	// default.
+	dockerPullTimeoutDefault = 2 * time.Minute
+
+	// dockerPullProgressInterval is how often the progress of pulls is
+	// reported.
+	dockerPullProgressInterval = 10 * time.Second
+)
+
+var (
+	// sharedDockerCoordinator is the image coordinator of the client, which is
+	// shared by the docker tasks.
+	sharedDockerCoordinator     *dockerCoordinator
+	sharedDockerCoordinatorLock sync.Mutex
+)
+
+// dockerImageClient is the part of the docker client used to pull and remove
+// images.
+type dockerImageClient interface {
+	PullImage(opts docker.PullImageOptions, auth docker.AuthConfiguration) error
+	InspectImage(name string) (*docker.Image, error)
+	RemoveImage(name string) error
+}
+
+// dockerImage is an image used by docker tasks.
+type dockerImage struct {
+	ID string
+
+	// Refs are the tasks using the image. The image is removed once it has no
+	// references for the remove delay.
+	Refs map[string]struct{}
+
+	// removeTimer removes the image once the remove delay passes.
+	removeTimer *time.Timer
+}
+
+// dockerPull is a pull of an image shared by the tasks starting with it.
+type dockerPull struct {
+	// emitters report the progress of the pull to the tasks waiting for it.
+	emitters []EventEmitter
+
+	// done is closed once the pull finishes, with the id of the image or the
+	// error of the pull.
+	done    chan struct{}
+	imageID string
+	err     error
+}
+
+// dockerCoordinator coordinates the images used by the docker tasks of the
+// client. Concurrent pulls of an image are coalesced into one, and images are
+// reference counted by the tasks using them so they are only removed once no
+// task has used them for the remove delay.
+type dockerCoordinator struct {
+	client dockerImageClient
+	logger *log.Logger
+
+	// path is the file the references are persisted to.
+	path string
+
+	// cleanup is whether unused images are removed, after removeDelay.
+	cleanup     bool
+	removeDelay time.Duration
+
+	// pullTimeout is how long a pull may make no progress before it is
+	// abandoned, and progressInterval how often its progress is reported.
+	pullTimeout      time.Duration
+	progressInterval time.Duration
+
+	images map[string]*dockerImage
+	pulls  map[string]*dockerPull
+	loaded bool
+	lock   sync.Mutex
+}
+
+// newDockerCoordinator returns a coordinator persisted to the path, removing
+// unused images after the delay if cleanup is set.
+func newDockerCoordinator(client dockerImageClient, path string, cleanup bool, removeDelay,
+	pullTimeout time.Duration, logger *log.Logger) *dockerCoordinator {
+	return &dockerCoordinator{
+		client:           client,
+		logger:           logger,
+		path:             path,
+		cleanup:          cleanup,
+		removeDelay:      removeDelay,
+		pullTimeout:      pullTimeout,
+		progressInterval: dockerPullProgressInterval,
+		images:           make(map[string]*dockerImage),
+		pulls:            make(map[string]*dockerPull),
+	}
+}
+
+// getDockerCoordinator returns the image coordinator of the client.
+func getDockerCoordinator(client dockerImageClient, cfg *config.Config, logger *log.Logger) *dockerCoordinator {
+	sharedDockerCoordinatorLock.Lock()
+	defer sharedDockerCoordinatorLock.Unlock()
+	if sharedDockerCoordinator == nil {
+		var path string
+		if cfg.StateDir != "" {
+			path = filepath.Join(cfg.StateDir, dockerImageRefsFile)
+		}
+		removeDelay := readDurationDefault(cfg, dockerImageRemoveDelayOption, dockerImageRemoveDelayDefault, logger)
+		pullTimeout := readDurationDefault(cfg, dockerPullTimeoutOption, dockerPullTimeoutDefault, logger)
+		cleanup := cfg.ReadBoolDefault("docker.cleanup.image", true)
+		sharedDockerCoordinator = newDockerCoordinator(client, path, cleanup, removeDelay, pullTimeout, logger)
+	}
+	return sharedDockerCoordinator
+}
+
+// readDurationDefault reads the client option as a duration, returning the
+// default if it isn't set or is invalid.
+func readDurationDefault(cfg *config.Config, option string, defaultValue time.Duration, logger *log.Logger) time.Duration {
+	raw := cfg.Read(option)
+	if raw == "" {
+		return defaultValue
+	}
+	parsed, err := time.ParseDuration(raw)
+	if err != nil || parsed < 0 {
+		logger.Printf("[WARN] driver.docker: invalid %s %q, using %v", option, raw, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
+// PullImage pulls the image and returns its id. If the image is already being
+// pulled the pull is joined instead. The progress of the pull is reported to
+// the emitter.
+func (c *dockerCoordinator) PullImage(image string, opts docker.PullImageOptions,
+	auth docker.AuthConfiguration, emitter EventEmitter) (string, error) {
+	key := fmt.Sprintf("%s:%s", opts.Repository, opts.Tag)
+
+	c.lock.Lock()
+	p, ok := c.pulls[key]
+	if ok {
+		c.logger.Printf("[DEBUG] driver.docker: waiting for the pull of image %s in progress", key)
+		p.emitters = append(p.emitters, emitter)
+		c.lock.Unlock()
+		<-p.done
+		return p.imageID, p.err
+	}
+	p = &dockerPull{
+		emitters: []EventEmitter{emitter},
+		done:     make(chan struct{}),
+	}
+	c.pulls[key] = p
+	c.lock.Unlock()
+
+	err := c.pull(key, opts, auth, p)
+	var imageID string
+	if err == nil {
+		var dockerImage *docker.Image
+		if dockerImage, err = c.client.InspectImage(image); err == nil {
+			imageID = dockerImage.ID
+		}
+	}
+
+	c.lock.Lock()
+	delete(c.pulls, key)
+	p.imageID, p.err = imageID, err
+	close(p.done)
+	c.lock.Unlock()
+	return imageID, err
+}
+
+// pull pulls the image, reporting its progress to the emitters of the pull
+// every progress interval. The pull is cancelled if it makes no progress for
+// the pull timeout.
+func (c *dockerCoordinator) pull(key string, opts docker.PullImageOptions,
+	auth docker.AuthConfiguration, p *dockerPull) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	pr, pw := io.Pipe()
+	opts.OutputStream = pw
+	opts.RawJSONStream = true
+	opts.Context = ctx
+
+	errCh := make(chan error, 1)
+	go func() {
+		err := c.client.PullImage(opts, auth)
+		pw.CloseWithError(err)
+		errCh <- err
+	}()
+
+	progress := newDockerPullProgress()
+	streamCh := make(chan error, 1)
+	go func() {
+		// Closing the stream once it can't be read fails the pull instead of
+		// blocking it
+		err := progress.read(pr)
+		pr.CloseWithError(err)
+		streamCh <- err
+	}()
+
+	ticker := time.NewTicker(c.progressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-errCh:
+			if err == nil {
+				// The daemon reports failed pulls in the stream
+				err = <-streamCh
+			}
+			return err
+		case <-ticker.C:
+			if stalled := progress.idle(); c.pullTimeout > 0 && stalled > c.pullTimeout {
+				c.emit(p, structs.NewTaskEvent(structs.TaskImagePullStalled).
+					SetImage(key).SetPullTimeout(c.pullTimeout))
+				err := fmt.Errorf("pull of image %s made no progress for %v", key, stalled)
+
+				// Cancelling the pull closes the connection to the daemon,
+				// which stops downloading the image
+				cancel()
+				pr.CloseWithError(err)
+				<-errCh
+				return err
+			}
+			c.emit(p, structs.NewTaskEvent(structs.TaskPullingImage).
+				SetImage(key).SetPullProgress(progress.String()))
+		}
+	}
+}
+
+// emit reports the event to the tasks waiting for the pull.
+func (c *dockerCoordinator) emit(p *dockerPull, event *structs.TaskEvent) {
+	c.lock.Lock()
+	emitters := p.emitters
+	c.lock.Unlock()
+	for _, emitter := range emitters {
+		if emitter != nil {
+			emitter(event.Copy())
+		}
+	}
+}
+
+// IncrementImageReference references the image by the task, so it isn't
+// removed while the task uses it.
+func (c *dockerCoordinator) IncrementImageReference(imageID, ref string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.load()
+
+	image, ok := c.images[imageID]
+	if !ok {
+		image = &dockerImage{ID: imageID, Refs: make(map[string]struct{})}
+		c.images[imageID] = image
+	}
+	if image.removeTimer != nil {
+		image.removeTimer.Stop()
+		image.removeTimer = nil
+	}
+	image.Refs[ref] = struct{}{}
+	c.persist()
+}
+
+// RemoveImage drops the reference of the task to the image. The image is
+// removed once it has no references for the remove delay, if cleanup is
+// enabled.
+func (c *dockerCoordinator) RemoveImage(imageID, ref string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.load()
+
+	image, ok := c.images[imageID]
+	if !ok {
+		return
+	}
+	delete(image.Refs, ref)
+	if len(image.Refs) == 0 {
+		if c.cleanup {
+			c.scheduleRemoval(image)
+		} else {
+			delete(c.images, imageID)
+		}
+	}
+	c.persist()
+}
+
+// scheduleRemoval removes the unreferenced image after the remove delay. It
+// must be called with the lock held.
+func (c *dockerCoordinator) scheduleRemoval(image *dockerImage) {
+	if image.removeTimer != nil {
+		return
+	}
+	c.logger.Printf("[DEBUG] driver.docker: image %s is unused, removing it in %v", image.ID, c.removeDelay)
+	image.removeTimer = time.AfterFunc(c.removeDelay, func() {
+		c.removeImage(image)
+	})
+}
+
+// removeImage removes the image if it is still unreferenced.
+func (c *dockerCoordinator) removeImage(image *dockerImage) {
+	c.lock.Lock()
+	if c.images[image.ID] != image || len(image.Refs) != 0 {
+		c.lock.Unlock()
+		return
+	}
+	image.removeTimer = nil
+	c.lock.Unlock()
+
+	// The removal may fail if the image is in use by containers not started
+	// by the client. That is OK, the image is no longer tracked either way.
+	if err := c.client.RemoveImage(image.ID); err != nil {
+		c.logger.Printf("[INFO] driver.docker: failed to remove unused image %s: %v", image.ID, err)
+	} else {
+		c.logger.Printf("[INFO] driver.docker: removed unused image %s", image.ID)
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.images[image.ID] == image && len(image.Refs) == 0 {
+		delete(c.images, image.ID)
+		c.persist()
+	}
+}
+
+// load reads the images from the file of the coordinator the first time it
+// is called, scheduling the removal of the unreferenced ones. It must be
+// called with the lock held.
+func (c *dockerCoordinator) load() {
+	if c.loaded || c.path == "" {
+		return
+	}
+	c.loaded = true
+
+	data, err := ioutil.ReadFile(c.path)
+	if os.IsNotExist(err) {
+		return
+	} else if err != nil {
+		c.logger.Printf("[ERROR] driver.docker: failed to read image references %q: %v", c.path, err)
+		return
+	}
+	var images []*dockerImage
+	if err := json.Unmarshal(data, &images); err != nil {
+		c.logger.Printf("[ERROR] driver.docker: failed to parse image references %q: %v", c.path, err)
+		return
+	}
+	for _, image := range images {
+		if image.Refs == nil {
+			image.Refs = make(map[string]struct{})
+		}
+		c.images[image.ID] = image
+		if len(image.Refs) == 0 && c.cleanup {
+			c.scheduleRemoval(image)
+		}
+	}
+}
+
+// persist writes the images to the file of the coordinator. It must be
+// called with the lock held.
+func (c *dockerCoordinator) persist() {
+	if c.path == "" {
+		return
+	}
+	images := make([]*dockerImage, 0, len(c.images))
+	for _, image := range c.images {
+		images = append(images, image)
+	}
+	data, err := json.Marshal(images)
+	if err != nil {
+		c.logger.Printf("[ERROR] driver.docker: failed to encode image references: %v", err)
+		return
+	}
+	if err := ioutil.WriteFile(c.path, data, 0600); err != nil {
+		c.logger.Printf("[ERROR] driver.docker: failed to write image references %q: %v", c.path, err)
+	}
+}
+
+// dockerPullProgress tracks the progress of a pull from the JSON messages
+// streamed by the daemon.
+type dockerPullProgress struct {
+	layers       map[string]*dockerLayerProgress
+	lastActivity time.Time
+	lock         sync.Mutex
+}
+
+// dockerLayerProgress is the progress of the download of a layer.
+type dockerLayerProgress struct {
+	current  int64
+	total    int64
+	complete bool
+}
+
+// dockerLayerStatuses are the statuses of the layers reported while pulling
+// an image, and whether the layer is downloaded.
+var dockerLayerStatuses = map[string]bool{
+	"Pulling fs layer":   false,
+	"Waiting":            false,
+	"Downloading":        false,
+	"Verifying Checksum": false,
+	"Download complete":  true,
+	"Extracting":         true,
+	"Pull complete":      true,
+	"Already exists":     true,
+}
+
+// dockerPullMessage is a JSON message of the stream of a pull.
+type dockerPullMessage struct {
+	ID             string `json:"id"`
+	Status         string `json:"status"`
+	Error          string `json:"error"`
+	ProgressDetail struct {
+		Current int64 `json:"current"`
+		Total   int64 `json:"total"`
+	} `json:"progressDetail"`
+}
+
+func newDockerPullProgress() *dockerPullProgress {
+	return &dockerPullProgress{
+		layers:       make(map[string]*dockerLayerProgress),
+		lastActivity: time.Now(),
+	}
+}
+
+// read records the messages of the stream until it ends, returning the error
+// reported by the daemon if the pull failed.
+func (p *dockerPullProgress) read(r io.Reader) error {
+	dec := json.NewDecoder(r)
+	for {
+		var msg dockerPullMessage
+		if err := dec.Decode(&msg); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if msg.Error != "" {
+			return fmt.Errorf("%s", msg.Error)
+		}
+		p.record(&msg)
+	}
+}
+
+// record updates the progress of the layer of the message.
+func (p *dockerPullProgress) record(msg *dockerPullMessage) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.lastActivity = time.Now()
+
+	// Messages about the image rather than one of its layers, such as the
+	// digest of the image, are ignored
+	complete, ok := dockerLayerStatuses[msg.Status]
+	if !ok || msg.ID == "" {
+		return
+	}
+	layer, ok := p.layers[msg.ID]
+	if !ok {
+		layer = &dockerLayerProgress{}
+		p.layers[msg.ID] = layer
+	}
+	if msg.Status == "Downloading" {
+		layer.current = msg.ProgressDetail.Current
+		layer.total = msg.ProgressDetail.Total
+	}
+	if complete {
+		layer.complete = true
+		layer.current = layer.total
+	}
+}
+
+// idle returns how long the pull has made no progress.
+func (p *dockerPullProgress) idle() time.Duration {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return time.Since(p.lastActivity)
+}
+
+// String describes the progress of the pull.
+func (p *dockerPullProgress) String() string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	var current, total int64
+	complete := 0
+	for _, layer := range p.layers {
+		current += layer.current
+		total += layer.total
+		if layer.complete {
+			complete++
+		}
+	}
+	return fmt.Sprintf("Pulled %d/%d layers, %s of %s downloaded",
+		complete, len(p.layers), humanize.Bytes(uint64(current)), humanize.Bytes(uint64(total)))
+}
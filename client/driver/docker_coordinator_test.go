@@ -0,0 +1,311 @@
+package driver
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/hashicorp/nomad/nomad/structs"
+	"github.com/hashicorp/nomad/testutil"
+)
+
+// fakeDockerImageClient is an image client whose pulls write the messages to
+// the output stream, then wait to be unblocked or cancelled if block is set.
+type fakeDockerImageClient struct {
+	messages []string
+	block    chan struct{}
+
+	pulls     int
+	cancelled int
+	removed   []string
+	lock      sync.Mutex
+}
+
+func (c *fakeDockerImageClient) PullImage(opts docker.PullImageOptions, auth docker.AuthConfiguration) error {
+	c.lock.Lock()
+	c.pulls++
+	c.lock.Unlock()
+	for _, msg := range c.messages {
+		if _, err := fmt.Fprintln(opts.OutputStream, msg); err != nil {
+			return err
+		}
+	}
+	if c.block != nil {
+		select {
+		case <-c.block:
+		case <-opts.Context.Done():
+			c.lock.Lock()
+			c.cancelled++
+			c.lock.Unlock()
+			return opts.Context.Err()
+		}
+	}
+	return nil
+}
+
+func (c *fakeDockerImageClient) InspectImage(name string) (*docker.Image, error) {
+	return &docker.Image{ID: "id-" + name}, nil
+}
+
+func (c *fakeDockerImageClient) RemoveImage(name string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.removed = append(c.removed, name)
+	return nil
+}
+
+func (c *fakeDockerImageClient) Removed() []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return append([]string{}, c.removed...)
+}
+
+// testEvents records the events emitted to it.
+type testEvents struct {
+	events []*structs.TaskEvent
+	lock   sync.Mutex
+}
+
+func (e *testEvents) Emit(event *structs.TaskEvent) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.events = append(e.events, event)
+}
+
+func (e *testEvents) OfType(typ string) []*structs.TaskEvent {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	var events []*structs.TaskEvent
+	for _, event := range e.events {
+		if event.Type == typ {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+func testDockerCoordinator(client dockerImageClient, path string, removeDelay time.Duration) *dockerCoordinator {
+	return newDockerCoordinator(client, path, true, removeDelay, time.Minute, testLogger())
+}
+
+func TestDockerCoordinator_ConcurrentPulls(t *testing.T) {
+	client := &fakeDockerImageClient{block: make(chan struct{})}
+	c := testDockerCoordinator(client, "", time.Minute)
+
+	opts := docker.PullImageOptions{Repository: "redis", Tag: "3.0"}
+	var wg sync.WaitGroup
+	ids := make([]string, 5)
+	errs := make([]error, 5)
+	for i := range ids {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ids[i], errs[i] = c.PullImage("redis:3.0", opts, docker.AuthConfiguration{}, nil)
+		}(i)
+	}
+
+	// Wait for all the pulls to be waiting for the first one
+	testutil.WaitForResult(func() (bool, error) {
+		c.lock.Lock()
+		defer c.lock.Unlock()
+		p, ok := c.pulls["redis:3.0"]
+		if !ok || len(p.emitters) != 5 {
+			return false, fmt.Errorf("pulls not coalesced yet")
+		}
+		return true, nil
+	}, func(err error) {
+		t.Fatalf("err: %v", err)
+	})
+	close(client.block)
+	wg.Wait()
+
+	if client.pulls != 1 {
+		t.Fatalf("got %d pulls; want 1", client.pulls)
+	}
+	for i := range ids {
+		if errs[i] != nil || ids[i] != "id-redis:3.0" {
+			t.Fatalf("pull %d: got %q, %v", i, ids[i], errs[i])
+		}
+	}
+
+	// A new pull is made once the previous one is done
+	if _, err := c.PullImage("redis:3.0", opts, docker.AuthConfiguration{}, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if client.pulls != 2 {
+		t.Fatalf("got %d pulls; want 2", client.pulls)
+	}
+}
+
+func TestDockerCoordinator_Pull_Progress(t *testing.T) {
+	client := &fakeDockerImageClient{
+		messages: []string{
+			`{"status":"Pulling from library/redis","id":"3.0"}`,
+			`{"status":"Already exists","id":"a"}`,
+			`{"status":"Downloading","progressDetail":{"current":1024,"total":4096},"id":"b"}`,
+		},
+		block: make(chan struct{}),
+	}
+	c := testDockerCoordinator(client, "", time.Minute)
+	c.progressInterval = 10 * time.Millisecond
+
+	// Finish the pull once its progress was reported
+	events := &testEvents{}
+	go func() {
+		defer close(client.block)
+		testutil.WaitForResult(func() (bool, error) {
+			for _, event := range events.OfType(structs.TaskPullingImage) {
+				if event.Image == "redis:3.0" && event.PullProgress == "Pulled 1/2 layers, 1.0 kB of 4.1 kB downloaded" {
+					return true, nil
+				}
+			}
+			return false, fmt.Errorf("progress not reported")
+		}, func(err error) {
+			t.Errorf("err: %v", err)
+		})
+	}()
+
+	opts := docker.PullImageOptions{Repository: "redis", Tag: "3.0"}
+	if _, err := c.PullImage("redis:3.0", opts, docker.AuthConfiguration{}, events.Emit); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestDockerCoordinator_Pull_Error(t *testing.T) {
+	client := &fakeDockerImageClient{
+		messages: []string{
+			`{"status":"Pulling repository redis"}`,
+			`{"errorDetail":{"message":"not found"},"error":"image redis:nope not found"}`,
+		},
+	}
+	c := testDockerCoordinator(client, "", time.Minute)
+
+	opts := docker.PullImageOptions{Repository: "redis", Tag: "nope"}
+	_, err := c.PullImage("redis:nope", opts, docker.AuthConfiguration{}, nil)
+	if err == nil || !strings.Contains(err.Error(), "image redis:nope not found") {
+		t.Fatalf("expected the error of the pull, got: %v", err)
+	}
+}
+
+func TestDockerCoordinator_Pull_Stalled(t *testing.T) {
+	client := &fakeDockerImageClient{
+		messages: []string{`{"status":"Downloading","id":"a"}`},
+		block:    make(chan struct{}),
+	}
+	defer close(client.block)
+	c := testDockerCoordinator(client, "", time.Minute)
+	c.progressInterval = 10 * time.Millisecond
+	c.pullTimeout = 50 * time.Millisecond
+
+	events := &testEvents{}
+	opts := docker.PullImageOptions{Repository: "redis", Tag: "3.0"}
+	_, err := c.PullImage("redis:3.0", opts, docker.AuthConfiguration{}, events.Emit)
+	if err == nil || !strings.Contains(err.Error(), "made no progress") {
+		t.Fatalf("expected the pull to stall, got: %v", err)
+	}
+
+	stalled := events.OfType(structs.TaskImagePullStalled)
+	if len(stalled) != 1 {
+		t.Fatalf("got %d stalled events; want 1", len(stalled))
+	}
+	if stalled[0].Image != "redis:3.0" || stalled[0].PullTimeout != c.pullTimeout {
+		t.Fatalf("bad stalled event: %#v", stalled[0])
+	}
+
+	// The stalled pull itself is cancelled
+	client.lock.Lock()
+	defer client.lock.Unlock()
+	if client.cancelled != 1 {
+		t.Fatalf("pull cancelled %d times; want 1", client.cancelled)
+	}
+}
+
+func TestDockerCoordinator_ImageReferences(t *testing.T) {
+	client := &fakeDockerImageClient{}
+	c := testDockerCoordinator(client, "", 50*time.Millisecond)
+
+	c.IncrementImageReference("image", "alloc1/web")
+	c.IncrementImageReference("image", "alloc2/web")
+	c.IncrementImageReference("image", "alloc2/web")
+	c.RemoveImage("image", "alloc1/web")
+	time.Sleep(100 * time.Millisecond)
+	if removed := client.Removed(); len(removed) != 0 {
+		t.Fatalf("image removed while referenced: %v", removed)
+	}
+
+	// Referencing the image again before the delay passes keeps it
+	c.RemoveImage("image", "alloc2/web")
+	c.IncrementImageReference("image", "alloc3/web")
+	time.Sleep(100 * time.Millisecond)
+	if removed := client.Removed(); len(removed) != 0 {
+		t.Fatalf("image removed while referenced: %v", removed)
+	}
+
+	c.RemoveImage("image", "alloc3/web")
+	c.RemoveImage("image", "alloc3/web")
+	testutil.WaitForResult(func() (bool, error) {
+		removed := client.Removed()
+		if len(removed) != 1 || removed[0] != "image" {
+			return false, fmt.Errorf("got removed images %v", removed)
+		}
+		return true, nil
+	}, func(err error) {
+		t.Fatalf("err: %v", err)
+	})
+}
+
+func TestDockerCoordinator_NoCleanup(t *testing.T) {
+	client := &fakeDockerImageClient{}
+	c := newDockerCoordinator(client, "", false, 0, time.Minute, testLogger())
+
+	c.IncrementImageReference("image", "alloc1/web")
+	c.RemoveImage("image", "alloc1/web")
+	time.Sleep(50 * time.Millisecond)
+	if removed := client.Removed(); len(removed) != 0 {
+		t.Fatalf("image removed with cleanup disabled: %v", removed)
+	}
+	if len(c.images) != 0 {
+		t.Fatalf("unused image still tracked: %v", c.images)
+	}
+}
+
+func TestDockerCoordinator_Persist(t *testing.T) {
+	dir, err := ioutil.TempDir("", "docker-coordinator")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, dockerImageRefsFile)
+
+	client := &fakeDockerImageClient{}
+	c := testDockerCoordinator(client, path, time.Hour)
+	c.IncrementImageReference("used", "alloc1/web")
+	c.IncrementImageReference("unused", "alloc2/web")
+	c.RemoveImage("unused", "alloc2/web")
+
+	// The references are restored by a new client, which removes the images
+	// that were unused
+	restored := testDockerCoordinator(client, path, 10*time.Millisecond)
+	restored.RemoveImage("used", "alloc3/web")
+	restored.lock.Lock()
+	_, ok := restored.images["used"].Refs["alloc1/web"]
+	restored.lock.Unlock()
+	if !ok {
+		t.Fatalf("references to image used not restored")
+	}
+	testutil.WaitForResult(func() (bool, error) {
+		removed := client.Removed()
+		if len(removed) != 1 || removed[0] != "unused" {
+			return false, fmt.Errorf("got removed images %v", removed)
+		}
+		return true, nil
+	}, func(err error) {
+		t.Fatalf("err: %v", err)
+	})
+}
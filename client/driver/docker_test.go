@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"reflect"
 	"runtime/debug"
+	"strings"
 	"testing"
 	"time"
 
@@ -20,6 +21,7 @@ import (
 	"github.com/hashicorp/nomad/helper/discover"
 	"github.com/hashicorp/nomad/nomad/structs"
 	"github.com/hashicorp/nomad/testutil"
+	"github.com/mitchellh/mapstructure"
 )
 
 // dockerIsConnected checks to see if a docker daemon is available (local or remote)
@@ -668,3 +670,377 @@ func TestDockerPortsMapping(t *testing.T) {
 		}
 	}
 }
+
+// This test should always pass, even if docker daemon is not available
+func TestDockerDriver_ContainerBinds_HostVolumes(t *testing.T) {
+	task := &structs.Task{Name: "redis-demo"}
+	driverCtx, execCtx := testDriverContexts(task)
+	defer execCtx.AllocDir.Destroy()
+	driverCtx.mounts = []*cstructs.MountConfig{
+		{HostPath: "/etc/ssl/certs", TaskPath: "/etc/ssl/certs", ReadOnly: true},
+		{HostPath: "/srv/data", TaskPath: "data"},
+	}
+	driver := NewDockerDriver(driverCtx).(*DockerDriver)
+
+	binds, err := driver.containerBinds(execCtx.AllocDir, task)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	expected := []string{"/etc/ssl/certs:/etc/ssl/certs:ro", "/srv/data:/data:rw"}
+	if !reflect.DeepEqual(binds[2:], expected) {
+		t.Fatalf("got %v; want %v", binds[2:], expected)
+	}
+}
+
+// dockerCreateOptions returns the options the driver creates the container of
+// the task with, on an agent with the given options. It doesn't need a docker
+// daemon.
+func dockerCreateOptions(t *testing.T, task *structs.Task, options map[string]string) (docker.CreateContainerOptions, error) {
+	driverCtx, execCtx := testDriverContexts(task)
+	defer execCtx.AllocDir.Destroy()
+	driverCtx.config.Options = options
+	driver := NewDockerDriver(driverCtx).(*DockerDriver)
+
+	var driverConfig DockerDriverConfig
+	if err := mapstructure.WeakDecode(task.Config, &driverConfig); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := driverConfig.Validate(); err != nil {
+		return docker.CreateContainerOptions{}, err
+	}
+	return driver.createContainer(execCtx, task, &driverConfig, "127.0.0.1:1")
+}
+
+// This test should always pass, even if docker daemon is not available
+func TestDockerDriver_CreateContainer_Volumes(t *testing.T) {
+	task, _, _ := dockerTask()
+	task.Config["volumes"] = []string{"data:/data", "cache:/var/cache:ro"}
+	task.Config["mounts"] = []map[string]interface{}{
+		{"source": "conf", "target": "/etc/app", "readonly": true},
+	}
+
+	opts, err := dockerCreateOptions(t, task, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	binds := opts.HostConfig.Binds[2:]
+	if len(binds) != 3 {
+		t.Fatalf("got %v; want 3 binds", binds)
+	}
+	for i, suffix := range []string{"/data:/data", "/cache:/var/cache:ro", "/conf:/etc/app:ro"} {
+		if !filepath.IsAbs(binds[i]) || !strings.HasSuffix(binds[i], task.Name+suffix) {
+			t.Fatalf("bind %d is %q; want task dir path ending in %q", i, binds[i], suffix)
+		}
+	}
+
+	// Host paths need to be enabled on the agent
+	task.Config["volumes"] = []string{"/srv/data:/data"}
+	delete(task.Config, "mounts")
+	if _, err := dockerCreateOptions(t, task, nil); err == nil {
+		t.Fatalf("expected host path volume to be rejected")
+	}
+	opts, err = dockerCreateOptions(t, task, map[string]string{"docker.volumes.enabled": "true"})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if binds := opts.HostConfig.Binds[2:]; !reflect.DeepEqual(binds, []string{"/srv/data:/data"}) {
+		t.Fatalf("got %v", binds)
+	}
+
+	// Relative paths can't escape the task dir and destinations are absolute
+	for _, volume := range []string{"../../etc:/etc", "data:data", "data", ":/data"} {
+		task.Config["volumes"] = []string{volume}
+		if _, err := dockerCreateOptions(t, task, nil); err == nil {
+			t.Fatalf("expected volume %q to be rejected", volume)
+		}
+	}
+}
+
+// This test should always pass, even if docker daemon is not available
+func TestDockerDriver_ContainerVolumes_Symlinks(t *testing.T) {
+	task := &structs.Task{Name: "redis-demo"}
+	driverCtx, execCtx := testDriverContexts(task)
+	defer execCtx.AllocDir.Destroy()
+	driver := NewDockerDriver(driverCtx).(*DockerDriver)
+
+	taskDir := execCtx.AllocDir.TaskDirs[task.Name]
+	links := map[string]string{
+		"etc":      "/etc",
+		"up":       "../..",
+		"data":     "local",
+		"dangling": "/nonexistent/dir",
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(taskDir, name)); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	// Symlinks escaping the task dir are rejected
+	for _, volume := range []string{"etc:/etc", "etc/ssl:/etc/ssl", "up:/up", "up/other/new:/data", "dangling:/data"} {
+		config := &DockerDriverConfig{Volumes: []string{volume}}
+		if _, err := driver.containerVolumes(execCtx.AllocDir, task, config); err == nil {
+			t.Fatalf("expected volume %q to be rejected", volume)
+		}
+	}
+
+	// Symlinks within the task dir and missing sources are resolved
+	config := &DockerDriverConfig{Volumes: []string{"data/sub:/data", "new/dir:/new"}}
+	binds, err := driver.containerVolumes(execCtx.AllocDir, task, config)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	root, err := filepath.EvalSymlinks(taskDir)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	expected := []string{
+		filepath.Join(root, "local", "sub") + ":/data",
+		filepath.Join(root, "new", "dir") + ":/new",
+	}
+	if !reflect.DeepEqual(binds, expected) {
+		t.Fatalf("got %v; want %v", binds, expected)
+	}
+}
+
+// This test should always pass, even if docker daemon is not available
+func TestDockerDriver_CreateContainer_Capabilities(t *testing.T) {
+	task, _, _ := dockerTask()
+	task.Config["cap_add"] = []string{"net_bind_service", "CAP_KILL"}
+	task.Config["cap_drop"] = []string{"ALL"}
+
+	opts, err := dockerCreateOptions(t, task, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(opts.HostConfig.CapAdd, []string{"net_bind_service", "CAP_KILL"}) {
+		t.Fatalf("bad cap_add: %v", opts.HostConfig.CapAdd)
+	}
+	if !reflect.DeepEqual(opts.HostConfig.CapDrop, []string{"ALL"}) {
+		t.Fatalf("bad cap_drop: %v", opts.HostConfig.CapDrop)
+	}
+
+	// Capabilities outside of the default whitelist are rejected
+	task.Config["cap_add"] = []string{"NET_ADMIN", "SYS_ADMIN"}
+	if _, err := dockerCreateOptions(t, task, nil); err == nil || !strings.Contains(err.Error(), "NET_ADMIN, SYS_ADMIN") {
+		t.Fatalf("expected capabilities to be rejected, got: %v", err)
+	}
+	if _, err := dockerCreateOptions(t, task, map[string]string{"docker.caps.whitelist": "CHOWN, NET_ADMIN"}); err == nil {
+		t.Fatalf("expected SYS_ADMIN to be rejected")
+	}
+	if _, err := dockerCreateOptions(t, task, map[string]string{"docker.caps.whitelist": "NET_ADMIN,SYS_ADMIN"}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := dockerCreateOptions(t, task, map[string]string{"docker.caps.whitelist": "ALL"}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+// This test should always pass, even if docker daemon is not available
+func TestDockerDriver_CreateContainer_Ulimits(t *testing.T) {
+	task, _, _ := dockerTask()
+	task.Config["ulimit"] = []map[string]string{
+		{"nofile": "1024:2048", "nproc": "512"},
+	}
+
+	opts, err := dockerCreateOptions(t, task, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	expected := []docker.ULimit{
+		{Name: "nofile", Soft: 1024, Hard: 2048},
+		{Name: "nproc", Soft: 512, Hard: 512},
+	}
+	if !reflect.DeepEqual(opts.HostConfig.Ulimits, expected) {
+		t.Fatalf("got %v; want %v", opts.HostConfig.Ulimits, expected)
+	}
+
+	for _, value := range []string{"2048:1024", "-1", "many", "1:many"} {
+		task.Config["ulimit"] = []map[string]string{{"nofile": value}}
+		if _, err := dockerCreateOptions(t, task, nil); err == nil {
+			t.Fatalf("expected ulimit %q to be rejected", value)
+		}
+	}
+}
+
+// This test should always pass, even if docker daemon is not available
+func TestDockerDriver_CreateContainer_Options(t *testing.T) {
+	task, _, _ := dockerTask()
+	task.Config["security_opt"] = []string{"no-new-privileges", "apparmor=docker-default"}
+	task.Config["sysctl"] = []map[string]string{{"net.core.somaxconn": "1024"}}
+	task.Config["devices"] = []map[string]interface{}{
+		{"host_path": "/dev/fuse"},
+		{"host_path": "/dev/sda", "container_path": "/dev/xvda", "cgroup_permissions": "r"},
+	}
+	task.Config["shm_size"] = 134217728
+	task.Config["work_dir"] = "/srv"
+	task.Config["entrypoint"] = []string{"/bin/sh", "-c"}
+	task.Config["extra_hosts"] = []string{"db:10.0.0.2", "ipv6:::1"}
+	task.Config["mac_address"] = "02:42:ac:11:00:02"
+
+	opts, err := dockerCreateOptions(t, task, map[string]string{"docker.devices.whitelist": "/dev/fuse,/dev/sda"})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	hostConfig := opts.HostConfig
+	if !reflect.DeepEqual(hostConfig.SecurityOpt, task.Config["security_opt"]) {
+		t.Fatalf("bad security_opt: %v", hostConfig.SecurityOpt)
+	}
+	if !reflect.DeepEqual(hostConfig.Sysctls, map[string]string{"net.core.somaxconn": "1024"}) {
+		t.Fatalf("bad sysctls: %v", hostConfig.Sysctls)
+	}
+	devices := []docker.Device{
+		{PathOnHost: "/dev/fuse", PathInContainer: "/dev/fuse", CgroupPermissions: "rwm"},
+		{PathOnHost: "/dev/sda", PathInContainer: "/dev/xvda", CgroupPermissions: "r"},
+	}
+	if !reflect.DeepEqual(hostConfig.Devices, devices) {
+		t.Fatalf("got devices %v; want %v", hostConfig.Devices, devices)
+	}
+	if hostConfig.ShmSize != 134217728 {
+		t.Fatalf("bad shm_size: %d", hostConfig.ShmSize)
+	}
+	if !reflect.DeepEqual(hostConfig.ExtraHosts, task.Config["extra_hosts"]) {
+		t.Fatalf("bad extra_hosts: %v", hostConfig.ExtraHosts)
+	}
+	if opts.Config.WorkingDir != "/srv" {
+		t.Fatalf("bad work_dir: %q", opts.Config.WorkingDir)
+	}
+	if !reflect.DeepEqual(opts.Config.Entrypoint, task.Config["entrypoint"]) {
+		t.Fatalf("bad entrypoint: %v", opts.Config.Entrypoint)
+	}
+	if opts.Config.MacAddress != "02:42:ac:11:00:02" {
+		t.Fatalf("bad mac_address: %q", opts.Config.MacAddress)
+	}
+}
+
+// This test should always pass, even if docker daemon is not available
+func TestDockerDriver_CreateContainer_Devices(t *testing.T) {
+	dir, err := ioutil.TempDir("", "docker-devices")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	link := filepath.Join(dir, "fuse")
+	if err := os.Symlink("/dev/null", link); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// No devices are allowed by default
+	task, _, _ := dockerTask()
+	task.Config["devices"] = []map[string]interface{}{{"host_path": "/dev/fuse"}}
+	if _, err := dockerCreateOptions(t, task, nil); err == nil || !strings.Contains(err.Error(), "docker.devices.whitelist") {
+		t.Fatalf("expected the device to be rejected, got: %v", err)
+	}
+	whitelist := map[string]string{"docker.devices.whitelist": "/dev/fuse, /dev/nvidia*"}
+	task.Config["devices"] = []map[string]interface{}{{"host_path": "/dev/fuse"}, {"host_path": "/dev/nvidia0"}}
+	if _, err := dockerCreateOptions(t, task, whitelist); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Devices are matched by their cleaned path with symlinks resolved
+	for _, path := range []string{"/dev/null", "/dev/fuse/../null", link} {
+		task.Config["devices"] = []map[string]interface{}{{"host_path": path}}
+		if _, err := dockerCreateOptions(t, task, whitelist); err == nil || !strings.Contains(err.Error(), "/dev/null") {
+			t.Fatalf("expected device %s to be rejected, got: %v", path, err)
+		}
+	}
+
+	// Privileged containers have access to all the devices
+	task.Config["privileged"] = true
+	if _, err := dockerCreateOptions(t, task, map[string]string{"docker.privileged.enabled": "true"}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+// This test should always pass, even if docker daemon is not available
+func TestDockerDriver_CreateContainer_Sysctls(t *testing.T) {
+	task, _, _ := dockerTask()
+	task.Config["sysctl"] = []map[string]string{{"net.ipv4.ip_forward": "1", "kernel/shmmax": "1024"}}
+	if _, err := dockerCreateOptions(t, task, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Only the namespaced sysctls are allowed by default
+	for _, key := range []string{"kernel.panic", "vm.overcommit_memory", "kernel/core_pattern"} {
+		task.Config["sysctl"] = []map[string]string{{key: "1"}}
+		if _, err := dockerCreateOptions(t, task, nil); err == nil || !strings.Contains(err.Error(), "docker.sysctls.whitelist") {
+			t.Fatalf("expected sysctl %s to be rejected, got: %v", key, err)
+		}
+	}
+	if _, err := dockerCreateOptions(t, task, map[string]string{"docker.sysctls.whitelist": "kernel.core_pattern"}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	task.Config["sysctl"] = []map[string]string{{"net.core.somaxconn": "1024"}}
+	if _, err := dockerCreateOptions(t, task, map[string]string{"docker.sysctls.whitelist": "kernel.*"}); err == nil {
+		t.Fatalf("expected sysctl net.core.somaxconn to be rejected")
+	}
+}
+
+// This test should always pass, even if docker daemon is not available
+func TestDockerDriver_CreateContainer_SecurityOpts(t *testing.T) {
+	unconfined := []string{
+		"seccomp=unconfined",
+		"seccomp:unconfined",
+		"apparmor=unconfined",
+		"apparmor:Unconfined",
+		"label=disable",
+		"label:type:spc_t",
+		"systempaths=unconfined",
+	}
+	for _, opt := range unconfined {
+		task, _, _ := dockerTask()
+		task.Config["security_opt"] = []string{"no-new-privileges", opt}
+		if _, err := dockerCreateOptions(t, task, nil); err == nil || !strings.Contains(err.Error(), opt) {
+			t.Fatalf("expected security option %q to be rejected, got: %v", opt, err)
+		}
+		if _, err := dockerCreateOptions(t, task, map[string]string{"docker.privileged.enabled": "true"}); err != nil {
+			t.Fatalf("security option %q rejected with privileged mode enabled: %v", opt, err)
+		}
+	}
+
+	task, _, _ := dockerTask()
+	task.Config["security_opt"] = []string{"no-new-privileges", "apparmor=docker-default", "label=level:s0:c100,c200", "seccomp=/etc/docker/seccomp.json"}
+	if _, err := dockerCreateOptions(t, task, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+// This test should always pass, even if docker daemon is not available
+func TestDockerDriver_CreateContainer_InvalidOptions(t *testing.T) {
+	invalid := []map[string]interface{}{
+		{"devices": []map[string]interface{}{{"container_path": "/dev/fuse"}}},
+		{"devices": []map[string]interface{}{{"host_path": "/dev/fuse", "cgroup_permissions": "rx"}}},
+		{"mounts": []map[string]interface{}{{"source": "data"}}},
+		{"shm_size": -1},
+		{"extra_hosts": []string{"db"}},
+		{"extra_hosts": []string{"db:not-an-ip"}},
+		{"mac_address": "02:42"},
+	}
+	for _, config := range invalid {
+		task, _, _ := dockerTask()
+		for k, v := range config {
+			task.Config[k] = v
+		}
+		if _, err := dockerCreateOptions(t, task, nil); err == nil {
+			t.Fatalf("expected config %v to be rejected", config)
+		}
+	}
+}
+
+func TestDockerDriver_ForcePull(t *testing.T) {
+	t.Parallel()
+	task, _, _ := dockerTask()
+	task.Config["image"] = "redis:3.0"
+	task.Config["force_pull"] = true
+
+	client, handle, cleanup := dockerSetup(t, task)
+	defer cleanup()
+
+	container, err := client.InspectContainer(handle.(*DockerHandle).ContainerID())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if container.Config.Image != "redis:3.0" {
+		t.Fatalf("bad image: %q", container.Config.Image)
+	}
+}
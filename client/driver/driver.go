@@ -3,6 +3,7 @@ package driver
 import (
 	"fmt"
 	"log"
+	"os"
 	"path/filepath"
 	"sync"
 	"time"
@@ -24,6 +25,8 @@ var BuiltinDrivers = map[string]Factory{
 	"gypsy":    NewGypsyDriver,
 	"java":     NewJavaDriver,
 	"lxc":      NewLXCDriver,
+	"nspawn":   NewNspawnDriver,
+	"oci":      NewOCIDriver,
 	"qemu":     NewQemuDriver,
 	"raw_exec": NewRawExecDriver,
 	"rkt":      NewRktDriver,
@@ -61,6 +64,10 @@ type Driver interface {
 	Open(ctx *ExecContext, handleID string) (DriverHandle, error)
 }
 
+// EventEmitter records an event of the task, such as a step of its work the
+// driver reports while it runs.
+type EventEmitter func(event *structs.TaskEvent)
+
 // DriverContext is a means to inject dependencies such as loggers, configs, and
 // node attributes into a Driver without having to change the Driver interface
 // each time we do it. Used in conjection with Factory, above.
@@ -70,6 +77,8 @@ type DriverContext struct {
 	logger   *log.Logger
 	node     *structs.Node
 	taskEnv  *env.TaskEnvironment
+	mounts   []*cstructs.MountConfig
+	emitter  EventEmitter
 }
 
 // NewDriverContext initializes a new DriverContext with the specified fields.
@@ -77,13 +86,24 @@ type DriverContext struct {
 // private to the driver. If we want to change this later we can gorename all of
 // the fields in DriverContext.
 func NewDriverContext(taskName string, config *config.Config, node *structs.Node,
-	logger *log.Logger, taskEnv *env.TaskEnvironment) *DriverContext {
+	logger *log.Logger, taskEnv *env.TaskEnvironment, mounts []*cstructs.MountConfig,
+	emitter EventEmitter) *DriverContext {
 	return &DriverContext{
 		taskName: taskName,
 		config:   config,
 		node:     node,
 		logger:   logger,
 		taskEnv:  taskEnv,
+		mounts:   mounts,
+		emitter:  emitter,
+	}
+}
+
+// emitEvent records the event of the task if the driver context has an
+// emitter.
+func (d *DriverContext) emitEvent(event *structs.TaskEvent) {
+	if d.emitter != nil {
+		d.emitter(event)
 	}
 }
 
@@ -118,10 +138,29 @@ type DriverHandle interface {
 	// configurations.
 	Update(task *structs.Task) error
 
+	// Signal is used to send a signal to the task
+	Signal(s os.Signal) error
+
 	// Kill is used to stop the task
 	Kill() error
 }
 
+// ExecHandle is implemented by the driver handles that can run commands
+// inside the isolation context of their task.
+type ExecHandle interface {
+	// Exec runs the command and blocks until it exits, returning its exit
+	// code.
+	Exec(opts *cstructs.ExecOptions) (int, error)
+}
+
+// CleanupDriver is implemented by the drivers whose tasks create resources
+// outside of the alloc dir. Cleanup is called when the allocation is garbage
+// collected, so the resources are removed even if the task failed to start or
+// the client crashed while starting it.
+type CleanupDriver interface {
+	Cleanup(ctx *ExecContext, task *structs.Task) error
+}
+
 // ExecContext is shared between drivers within an allocation
 type ExecContext struct {
 	sync.Mutex
@@ -12,6 +12,7 @@ import (
 
 	"github.com/hashicorp/nomad/client/allocdir"
 	"github.com/hashicorp/nomad/client/config"
+	"github.com/hashicorp/nomad/client/getter"
 	"github.com/hashicorp/nomad/helper/testtask"
 	"github.com/hashicorp/nomad/nomad/structs"
 )
@@ -61,10 +62,24 @@ func testDriverContexts(task *structs.Task) (*DriverContext, *ExecContext) {
 		return nil, nil
 	}
 
-	driverCtx := NewDriverContext(task.Name, cfg, cfg.Node, testLogger(), taskEnv)
+	driverCtx := NewDriverContext(task.Name, cfg, cfg.Node, testLogger(), taskEnv, nil, nil)
 	return driverCtx, execCtx
 }
 
+// testDownloadArtifacts downloads the artifacts of the task into its task
+// directory, as the task runner does before starting the driver.
+func testDownloadArtifacts(t *testing.T, task *structs.Task, ctx *ExecContext) {
+	taskDir, ok := ctx.AllocDir.TaskDirs[task.Name]
+	if !ok {
+		t.Fatalf("missing task directory for task %q", task.Name)
+	}
+	for _, artifact := range task.Artifacts {
+		if _, err := getter.GetArtifact(artifact, taskDir, testLogger()); err != nil {
+			t.Fatalf("failed to download artifact: %v", err)
+		}
+	}
+}
+
 func TestDriver_KillTimeout(t *testing.T) {
 	expected := 1 * time.Second
 	task := &structs.Task{Name: "foo", KillTimeout: expected}
@@ -0,0 +1,128 @@
+package driver
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+
+	cstructs "github.com/hashicorp/nomad/client/driver/structs"
+	"github.com/hashicorp/nomad/helper/term"
+)
+
+const (
+	// execOutputDrainTimeout is the time to wait for the output of an exec
+	// session to be copied once its command exited. Processes the command
+	// left running in the background may keep the output open.
+	execOutputDrainTimeout = 1 * time.Second
+)
+
+// runExecSession attaches the streams of the exec options to the files passed
+// to run, either the slave end of a terminal or pipes, and calls run, which
+// must block until the command exits and return its exit code.
+func runExecSession(opts *cstructs.ExecOptions, run func(stdin, stdout, stderr *os.File) (int, error)) (int, error) {
+	if len(opts.Command) == 0 {
+		return -1, fmt.Errorf("missing command")
+	}
+
+	// parentFiles are closed once the session ends and childFiles once the
+	// command exited.
+	var parentFiles, childFiles []*os.File
+	defer func() {
+		for _, f := range append(parentFiles, childFiles...) {
+			f.Close()
+		}
+	}()
+
+	var stdin, stdout, stderr, input *os.File
+	var outputs []io.Writer
+	var readers []*os.File
+	if opts.Tty {
+		master, slave, err := term.OpenPty()
+		if err != nil {
+			return -1, fmt.Errorf("failed to allocate a terminal: %v", err)
+		}
+		parentFiles = append(parentFiles, master)
+		childFiles = append(childFiles, slave)
+		stdin, stdout, stderr, input = slave, slave, slave, master
+		readers, outputs = []*os.File{master}, []io.Writer{opts.Stdout}
+
+		doneCh := make(chan struct{})
+		defer close(doneCh)
+		go relayResize(master, opts.ResizeCh, doneCh)
+	} else {
+		pipes := make([]*os.File, 0, 6)
+		for i := 0; i < 3; i++ {
+			r, w, err := os.Pipe()
+			if err != nil {
+				for _, f := range pipes {
+					f.Close()
+				}
+				return -1, err
+			}
+			pipes = append(pipes, r, w)
+		}
+		stdin, input = pipes[0], pipes[1]
+		stdout, stderr = pipes[3], pipes[5]
+		parentFiles = append(parentFiles, pipes[1], pipes[2], pipes[4])
+		childFiles = append(childFiles, pipes[0], pipes[3], pipes[5])
+		readers, outputs = []*os.File{pipes[2], pipes[4]}, []io.Writer{opts.Stdout, opts.Stderr}
+	}
+
+	// Relay the input and close it on EOF. Closing a terminal hangs it up,
+	// which stops the command if the client of the session went away.
+	go func() {
+		if opts.Stdin != nil {
+			io.Copy(input, opts.Stdin)
+		} else if opts.Tty {
+			return
+		}
+		input.Close()
+	}()
+
+	// Relay the output
+	outputDoneCh := make(chan struct{}, len(readers))
+	for i, r := range readers {
+		go func(r *os.File, w io.Writer) {
+			if w == nil {
+				w = ioutil.Discard
+			}
+			io.Copy(w, r)
+			outputDoneCh <- struct{}{}
+		}(r, outputs[i])
+	}
+
+	code, err := run(stdin, stdout, stderr)
+
+	// Close the ends of the command so the output is drained to EOF
+	for _, f := range childFiles {
+		f.Close()
+	}
+	childFiles = nil
+	timeout := time.After(execOutputDrainTimeout)
+	for range readers {
+		select {
+		case <-outputDoneCh:
+		case <-timeout:
+			return code, err
+		}
+	}
+	return code, err
+}
+
+// relayResize sets the size of the terminal when it changes until doneCh is
+// closed.
+func relayResize(master *os.File, resizeCh <-chan term.Size, doneCh <-chan struct{}) {
+	for {
+		select {
+		case size, ok := <-resizeCh:
+			if !ok {
+				return
+			}
+			term.SetSize(master.Fd(), size)
+		case <-doneCh:
+			return
+		}
+	}
+}
@@ -0,0 +1,22 @@
+// +build !linux
+
+package driver
+
+import (
+	"fmt"
+	"os/exec"
+
+	cstructs "github.com/hashicorp/nomad/client/driver/structs"
+)
+
+func runExecCommand(cmd *exec.Cmd, opts *cstructs.ExecOptions, started func(pid int) error) (int, error) {
+	return -1, fmt.Errorf("exec sessions are not supported on this platform")
+}
+
+func execInTaskDir(pid int, taskDir string, opts *cstructs.ExecOptions) (int, error) {
+	return -1, fmt.Errorf("exec sessions are not supported on this platform")
+}
+
+func execInTaskContext(pid int, isolation *cstructs.IsolationConfig, opts *cstructs.ExecOptions) (int, error) {
+	return -1, fmt.Errorf("exec sessions are not supported on this platform")
+}
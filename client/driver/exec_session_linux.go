@@ -0,0 +1,154 @@
+package driver
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/hashicorp/nomad/client/driver/executor"
+	cstructs "github.com/hashicorp/nomad/client/driver/structs"
+)
+
+// runExecCommand runs the command of an exec session. started is called with
+// the pid of the command once it has started.
+func runExecCommand(cmd *exec.Cmd, opts *cstructs.ExecOptions, started func(pid int) error) (int, error) {
+	return runExecSession(opts, func(stdin, stdout, stderr *os.File) (int, error) {
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = stdin, stdout, stderr
+		if opts.Tty {
+			if cmd.SysProcAttr == nil {
+				cmd.SysProcAttr = &syscall.SysProcAttr{}
+			}
+			cmd.SysProcAttr.Setsid = true
+			cmd.SysProcAttr.Setctty = true
+		}
+
+		if err := cmd.Start(); err != nil {
+			return -1, fmt.Errorf("failed to start command: %v", err)
+		}
+		if started != nil {
+			if err := started(cmd.Process.Pid); err != nil {
+				cmd.Process.Kill()
+				cmd.Wait()
+				return -1, err
+			}
+		}
+
+		err := cmd.Wait()
+		if err == nil {
+			return 0, nil
+		}
+		exitErr, ok := err.(*exec.ExitError)
+		if !ok {
+			return -1, err
+		}
+		status, ok := exitErr.Sys().(syscall.WaitStatus)
+		if !ok {
+			return -1, err
+		}
+		if status.Signaled() {
+			return 128 + int(status.Signal()), nil
+		}
+		return status.ExitStatus(), nil
+	})
+}
+
+// execInTaskContext runs the command of an exec session in the chroot and the
+// cgroup of the task process, as the user the task runs as.
+func execInTaskContext(pid int, isolation *cstructs.IsolationConfig, opts *cstructs.ExecOptions) (int, error) {
+	if len(opts.Command) == 0 {
+		return -1, fmt.Errorf("missing command")
+	}
+
+	var st syscall.Stat_t
+	procDir := fmt.Sprintf("/proc/%d", pid)
+	if err := syscall.Stat(procDir, &st); err != nil {
+		return -1, fmt.Errorf("task process %d not found: %v", pid, err)
+	}
+
+	root := filepath.Join(procDir, "root")
+	env := processEnv(pid)
+	path, err := lookPathIn(root, opts.Command[0], env)
+	if err != nil {
+		return -1, err
+	}
+
+	cmd := &exec.Cmd{
+		Path: path,
+		Args: opts.Command,
+		Env:  env,
+		Dir:  "/",
+		SysProcAttr: &syscall.SysProcAttr{
+			Chroot: root,
+			Credential: &syscall.Credential{
+				Uid: st.Uid,
+				Gid: st.Gid,
+			},
+		},
+	}
+	return runExecCommand(cmd, opts, func(pid int) error {
+		if isolation == nil || isolation.Cgroup == nil {
+			return nil
+		}
+		if err := executor.JoinCgroup(isolation.Cgroup, pid); err != nil {
+			return fmt.Errorf("failed to join the cgroup of the task: %v", err)
+		}
+		return nil
+	})
+}
+
+// execInTaskDir runs the command of an exec session in the task directory
+// with the environment of the task process.
+func execInTaskDir(pid int, taskDir string, opts *cstructs.ExecOptions) (int, error) {
+	if len(opts.Command) == 0 {
+		return -1, fmt.Errorf("missing command")
+	}
+	cmd := exec.Command(opts.Command[0], opts.Command[1:]...)
+	cmd.Dir = taskDir
+	cmd.Env = processEnv(pid)
+	return runExecCommand(cmd, opts, nil)
+}
+
+// processEnv returns the environment of the process, or nil if it can't be
+// read.
+func processEnv(pid int) []string {
+	raw, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/environ", pid))
+	if err != nil {
+		return nil
+	}
+
+	var env []string
+	for _, kv := range bytes.Split(raw, []byte{0}) {
+		if len(kv) != 0 {
+			env = append(env, string(kv))
+		}
+	}
+	return env
+}
+
+// lookPathIn resolves the command within root using the PATH of the
+// environment and returns its path relative to root.
+func lookPathIn(root, name string, env []string) (string, error) {
+	if strings.Contains(name, "/") {
+		return name, nil
+	}
+
+	path := "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+	for _, kv := range env {
+		if strings.HasPrefix(kv, "PATH=") {
+			path = strings.TrimPrefix(kv, "PATH=")
+		}
+	}
+	for _, dir := range filepath.SplitList(path) {
+		candidate := filepath.Join("/", dir, name)
+		fi, err := os.Stat(filepath.Join(root, candidate))
+		if err == nil && !fi.IsDir() && fi.Mode()&0111 != 0 {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("command %q not found in the task", name)
+}
@@ -197,19 +197,27 @@ func TestExecDriver_Start_Artifact_basic(t *testing.T) {
 	task := &structs.Task{
 		Name: "sleep",
 		Config: map[string]interface{}{
-			"artifact_source": fmt.Sprintf("https://dl.dropboxusercontent.com/u/47675/jar_thing/%s?checksum=%s", file, checksum),
-			"command":         file,
+			"command": file,
 		},
 		LogConfig: &structs.LogConfig{
 			MaxFiles:      10,
 			MaxFileSizeMB: 10,
 		},
 		Resources: basicResources,
+		Artifacts: []*structs.TaskArtifact{
+			{
+				GetterSource: fmt.Sprintf("https://dl.dropboxusercontent.com/u/47675/jar_thing/%s", file),
+				GetterOptions: map[string]string{
+					"checksum": checksum,
+				},
+			},
+		},
 	}
 
 	driverCtx, execCtx := testDriverContexts(task)
 	defer execCtx.AllocDir.Destroy()
 	d := NewExecDriver(driverCtx)
+	testDownloadArtifacts(t, task, execCtx)
 
 	handle, err := d.Start(execCtx, task)
 	if err != nil {
@@ -0,0 +1,5 @@
+package executor
+
+// ContainerInitCommand is the nomad command run as the init of containers,
+// which sets up the container and executes the command in it.
+const ContainerInitCommand = "container-init"
@@ -0,0 +1,162 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/hashicorp/nomad/helper/discover"
+	"github.com/hashicorp/nomad/nomad/structs"
+	"github.com/opencontainers/runc/libcontainer"
+	"github.com/opencontainers/runc/libcontainer/cgroups/systemd"
+	cgroupConfig "github.com/opencontainers/runc/libcontainer/configs"
+
+	// nsenter joins the namespaces of the containers before the go runtime
+	// starts, so it must be linked in the nomad binary running their init.
+	_ "github.com/opencontainers/runc/libcontainer/nsenter"
+)
+
+// containerStateDir is the directory of the alloc dir in which libcontainer
+// keeps the state of the containers of the tasks.
+const containerStateDir = "containers"
+
+// containerDefaultPath is the PATH the command is looked up with in the
+// container if its environment has none.
+const containerDefaultPath = "PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+// containerInvalidIDChars matches the characters of task names which aren't
+// allowed in the ids of containers.
+var containerInvalidIDChars = regexp.MustCompile(`[^\w.-]`)
+
+// container is the libcontainer container the command runs in and the
+// process of the command in it.
+type container struct {
+	libcontainer.Container
+	process *libcontainer.Process
+}
+
+// ContainerInit is run by the init of a container. It initializes the
+// container from the configuration sent by libcontainer and executes the
+// command, so it only returns if the command couldn't be run.
+func ContainerInit() error {
+	runtime.GOMAXPROCS(1)
+	runtime.LockOSThread()
+	factory, err := libcontainer.New("")
+	if err != nil {
+		return err
+	}
+	if err := factory.StartInitialization(); err != nil {
+		return err
+	}
+	return fmt.Errorf("container init failed to execute the command")
+}
+
+// configureContainer sets the cgroups of the container from the resources of
+// the task if it has none. Only the devices of the container may be accessed.
+func (e *UniversalExecutor) configureContainer() error {
+	c := e.ctx.Container
+	if c.Rootfs == "" {
+		return fmt.Errorf("container has no rootfs")
+	}
+	if c.Cgroups != nil {
+		e.groups = c.Cgroups
+		return nil
+	}
+
+	if e.ctx.ResourceLimits {
+		if err := e.configureCgroups(e.ctx.TaskResources); err != nil {
+			return fmt.Errorf("error creating cgroups: %v", err)
+		}
+	} else {
+		// libcontainer always places the container in a cgroup
+		e.groups = &cgroupConfig.Cgroup{
+			Name:      structs.GenerateUUID(),
+			Resources: &cgroupConfig.Resources{AllowAllDevices: true},
+		}
+	}
+	if len(c.Devices) != 0 {
+		e.groups.Resources.AllowAllDevices = false
+		e.groups.Resources.AllowedDevices = c.Devices
+	}
+	c.Cgroups = e.groups
+	return nil
+}
+
+// startContainer creates the container of the task with libcontainer and
+// starts the command in it, run as the user of the task within the rootfs.
+// The container is run by the nomad binary as its init.
+func (e *UniversalExecutor) startContainer(command *ExecCommand) error {
+	bin, err := discover.NomadExecutable()
+	if err != nil {
+		return fmt.Errorf("unable to find the nomad binary: %v", err)
+	}
+	cgroupManager := libcontainer.Cgroupfs
+	if systemd.UseSystemd() {
+		cgroupManager = libcontainer.SystemdCgroups
+	}
+	root := filepath.Join(e.ctx.AllocDir.AllocDir, containerStateDir)
+	factory, err := libcontainer.New(root, cgroupManager, libcontainer.InitPath(bin, bin, ContainerInitCommand))
+	if err != nil {
+		return fmt.Errorf("error creating the container factory: %v", err)
+	}
+
+	id := containerInvalidIDChars.ReplaceAllString(e.ctx.TaskName, "_")
+	lc, err := factory.Create(id, e.ctx.Container)
+	if err != nil {
+		return fmt.Errorf("error creating the container: %v", err)
+	}
+	env := e.cmd.Env
+	if !containerHasPath(env) {
+		env = append(env, containerDefaultPath)
+	}
+	process := &libcontainer.Process{
+		Args:   e.cmd.Args,
+		Env:    env,
+		User:   e.ctx.User,
+		Cwd:    command.Dir,
+		Stdout: e.cmd.Stdout,
+		Stderr: e.cmd.Stderr,
+	}
+	if err := lc.Start(process); err != nil {
+		if er := lc.Destroy(); er != nil {
+			e.logger.Printf("[ERR] executor: error destroying container: %v", er)
+		}
+		return fmt.Errorf("error starting the container: %v", err)
+	}
+	pid, err := process.Pid()
+	if err != nil {
+		return err
+	}
+
+	// The command is signalled and killed like any other process
+	if e.cmd.Process, err = os.FindProcess(pid); err != nil {
+		return err
+	}
+	e.container = &container{Container: lc, process: process}
+	return nil
+}
+
+// containerHasPath returns whether the environment sets the PATH.
+func containerHasPath(env []string) bool {
+	for _, kv := range env {
+		if strings.HasPrefix(kv, "PATH=") {
+			return true
+		}
+	}
+	return false
+}
+
+// waitContainer waits for the command of the container to exit.
+func (e *UniversalExecutor) waitContainer() error {
+	_, err := e.container.process.Wait()
+	return err
+}
+
+// destroyContainer removes the cgroups and the state of the container once
+// its command exited.
+func (e *UniversalExecutor) destroyContainer() error {
+	return e.container.Destroy()
+}
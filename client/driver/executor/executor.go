@@ -52,6 +52,18 @@ type ExecutorContext struct {
 
 	// LogConfig provides the configuration related to log rotation
 	LogConfig *structs.LogConfig
+
+	// Mounts are the host volumes to bind mount into the task directory
+	Mounts []*cstructs.MountConfig
+
+	// Container is the libcontainer configuration of the container the
+	// command runs in, which is created and run by libcontainer. The cgroups
+	// of the container are derived from TaskResources if it has none.
+	Container *cgroupConfig.Config
+
+	// User is the user the command runs as in the container, either a name
+	// or a uid, optionally followed by a group as in "uid:gid".
+	User string
 }
 
 // ExecCommand holds the user command and args. It's a lightweight replacement
@@ -59,6 +71,9 @@ type ExecutorContext struct {
 type ExecCommand struct {
 	Cmd  string
 	Args []string
+
+	// Dir is the working directory of the command in a container.
+	Dir string
 }
 
 // ProcessState holds information about the state of a user process.
@@ -66,6 +81,7 @@ type ProcessState struct {
 	Pid             int
 	ExitCode        int
 	Signal          int
+	OOMKilled       bool
 	IsolationConfig *cstructs.IsolationConfig
 	Time            time.Time
 }
@@ -78,6 +94,7 @@ type Executor interface {
 	ShutDown() error
 	Exit() error
 	UpdateLogConfig(logConfig *structs.LogConfig) error
+	Signal(s os.Signal) error
 }
 
 // UniversalExecutor is an implementation of the Executor which launches and
@@ -94,6 +111,9 @@ type UniversalExecutor struct {
 	lre           *logging.FileRotator
 	lro           *logging.FileRotator
 
+	// container is the container the command runs in, if any
+	container *container
+
 	logger *log.Logger
 	lock   sync.Mutex
 }
@@ -115,17 +135,29 @@ func (e *UniversalExecutor) LaunchCmd(command *ExecCommand, ctx *ExecutorContext
 		return nil, err
 	}
 
-	// configuring the chroot, cgroup and enters the plugin process in the
-	// chroot
-	if err := e.configureIsolation(); err != nil {
-		return nil, err
-	}
+	// a container is isolated by libcontainer when it is started
+	if ctx.Container != nil {
+		if err := e.configureContainer(); err != nil {
+			return nil, err
+		}
+	} else {
+		// configuring the chroot, cgroup and enters the plugin process in the
+		// chroot
+		if err := e.configureIsolation(); err != nil {
+			return nil, err
+		}
 
-	// setting the user of the process
-	if e.ctx.UnprivilegedUser {
-		if err := e.runAs("nobody"); err != nil {
+		// mounting the host volumes
+		if err := e.configureMounts(); err != nil {
 			return nil, err
 		}
+
+		// setting the user of the process
+		if e.ctx.UnprivilegedUser {
+			if err := e.runAs("nobody"); err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	logFileSize := int64(ctx.LogConfig.MaxFileSizeMB * 1024 * 1024)
@@ -151,16 +183,23 @@ func (e *UniversalExecutor) LaunchCmd(command *ExecCommand, ctx *ExecutorContext
 	e.cmd.Env = ctx.TaskEnv.EnvList()
 	e.cmd.Path = ctx.TaskEnv.ReplaceEnv(command.Cmd)
 	e.cmd.Args = append([]string{e.cmd.Path}, ctx.TaskEnv.ParseAndReplace(command.Args)...)
-	if filepath.Base(command.Cmd) == command.Cmd {
-		if lp, err := exec.LookPath(command.Cmd); err != nil {
-		} else {
-			e.cmd.Path = lp
+	if ctx.Container != nil {
+		// starting the command in the container, where it is looked up
+		if err := e.startContainer(command); err != nil {
+			return nil, err
+		}
+	} else {
+		if filepath.Base(command.Cmd) == command.Cmd {
+			if lp, err := exec.LookPath(command.Cmd); err != nil {
+			} else {
+				e.cmd.Path = lp
+			}
 		}
-	}
 
-	// starting the process
-	if err := e.cmd.Start(); err != nil {
-		return nil, fmt.Errorf("error starting command: %v", err)
+		// starting the process
+		if err := e.cmd.Start(); err != nil {
+			return nil, fmt.Errorf("error starting command: %v", err)
+		}
 	}
 	go e.wait()
 	ic := &cstructs.IsolationConfig{Cgroup: e.groups}
@@ -192,7 +231,17 @@ func (e *UniversalExecutor) UpdateLogConfig(logConfig *structs.LogConfig) error
 
 func (e *UniversalExecutor) wait() {
 	defer close(e.processExited)
-	err := e.cmd.Wait()
+	var err error
+	if e.container != nil {
+		err = e.waitContainer()
+		defer func() {
+			if err := e.destroyContainer(); err != nil {
+				e.logger.Printf("[ERR] executor: error destroying container: %v", err)
+			}
+		}()
+	} else {
+		err = e.cmd.Wait()
+	}
 	e.lre.Close()
 	e.lro.Close()
 	if err == nil {
@@ -200,20 +249,25 @@ func (e *UniversalExecutor) wait() {
 		return
 	}
 	exitCode := 1
+	killed := false
 	if exitErr, ok := err.(*exec.ExitError); ok {
 		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
 			exitCode = status.ExitStatus()
+			killed = status.Signaled() && status.Signal() == syscall.SIGKILL
 		}
 	}
-	if e.ctx.FSIsolation {
+	if e.hasMounts() {
 		e.removeChrootMounts()
 	}
+	oomKilled := false
 	if e.ctx.ResourceLimits {
 		e.lock.Lock()
+		// The cgroup has to be inspected before it is destroyed
+		oomKilled = killed && memoryExceeded(e.groups)
 		DestroyCgroup(e.groups)
 		e.lock.Unlock()
 	}
-	e.exitState = &ProcessState{Pid: 0, ExitCode: exitCode, Time: time.Now()}
+	e.exitState = &ProcessState{Pid: 0, ExitCode: exitCode, OOMKilled: oomKilled, Time: time.Now()}
 }
 
 var (
@@ -237,7 +291,7 @@ func (e *UniversalExecutor) Exit() error {
 		}
 	}
 
-	if e.ctx.FSIsolation {
+	if e.hasMounts() {
 		if err := e.removeChrootMounts(); err != nil {
 			merr.Errors = append(merr.Errors, err)
 		}
@@ -270,6 +324,23 @@ func (e *UniversalExecutor) ShutDown() error {
 	return nil
 }
 
+// Signal sends a signal to the user process
+func (e *UniversalExecutor) Signal(s os.Signal) error {
+	if e.cmd.Process == nil {
+		return fmt.Errorf("executor.signal error: no process found")
+	}
+	if err := e.cmd.Process.Signal(s); err != nil {
+		return fmt.Errorf("executor.signal error: sending %v failed: %v", s, err)
+	}
+	return nil
+}
+
+// hasMounts returns whether anything was mounted in the task dir for the
+// command.
+func (e *UniversalExecutor) hasMounts() bool {
+	return e.ctx.FSIsolation || len(e.ctx.Mounts) > 0
+}
+
 // configureTaskDir sets the task dir in the executor
 func (e *UniversalExecutor) configureTaskDir() error {
 	taskDir, ok := e.ctx.AllocDir.TaskDirs[e.ctx.TaskName]
@@ -280,3 +351,21 @@ func (e *UniversalExecutor) configureTaskDir() error {
 	e.cmd.Dir = taskDir
 	return nil
 }
+
+// configureMounts bind mounts the host volumes into the task directory
+func (e *UniversalExecutor) configureMounts() error {
+	for _, m := range e.ctx.Mounts {
+		if err := e.ctx.AllocDir.MountHostVolume(e.ctx.TaskName, m.HostPath, m.TaskPath, m.ReadOnly); err != nil {
+			if er := e.removeChrootMounts(); er != nil {
+				e.logger.Printf("[ERR] executor: error removing mounts: %v", er)
+			}
+			if e.ctx.ResourceLimits {
+				if er := DestroyCgroup(e.groups); er != nil {
+					e.logger.Printf("[ERR] executor: error destroying cgroup: %v", er)
+				}
+			}
+			return err
+		}
+	}
+	return nil
+}
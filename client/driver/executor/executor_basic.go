@@ -3,6 +3,8 @@
 package executor
 
 import (
+	"fmt"
+
 	cgroupConfig "github.com/opencontainers/runc/libcontainer/configs"
 )
 
@@ -14,6 +16,14 @@ func DestroyCgroup(groups *cgroupConfig.Cgroup) error {
 	return nil
 }
 
+func JoinCgroup(groups *cgroupConfig.Cgroup, pid int) error {
+	return nil
+}
+
+func memoryExceeded(groups *cgroupConfig.Cgroup) bool {
+	return false
+}
+
 func (e *UniversalExecutor) removeChrootMounts() error {
 	return nil
 }
@@ -29,3 +39,25 @@ func (e *UniversalExecutor) applyLimits(pid int) error {
 func (e *UniversalExecutor) configureIsolation() error {
 	return nil
 }
+
+type container struct{}
+
+func (e *UniversalExecutor) configureContainer() error {
+	return fmt.Errorf("containers are only supported on linux")
+}
+
+func (e *UniversalExecutor) startContainer(command *ExecCommand) error {
+	return fmt.Errorf("containers are only supported on linux")
+}
+
+func (e *UniversalExecutor) waitContainer() error {
+	return nil
+}
+
+func (e *UniversalExecutor) destroyContainer() error {
+	return nil
+}
+
+func ContainerInit() error {
+	return fmt.Errorf("containers are only supported on linux")
+}
@@ -213,6 +213,27 @@ func DestroyCgroup(groups *cgroupConfig.Cgroup) error {
 	return nil
 }
 
+// JoinCgroup adds the process to the cgroup.
+func JoinCgroup(groups *cgroupConfig.Cgroup, pid int) error {
+	if groups == nil {
+		return fmt.Errorf("Can't join: cgroup configuration empty")
+	}
+	return getCgroupManager(groups).Apply(pid)
+}
+
+// memoryExceeded returns whether the processes of the cgroup hit its memory
+// limit.
+func memoryExceeded(groups *cgroupConfig.Cgroup) bool {
+	if groups == nil {
+		return false
+	}
+	stats, err := getCgroupManager(groups).GetStats()
+	if err != nil {
+		return false
+	}
+	return stats.MemoryStats.Usage.Failcnt > 0
+}
+
 // getCgroupManager returns the correct libcontainer cgroup manager.
 func getCgroupManager(groups *cgroupConfig.Cgroup) cgroups.Manager {
 	var manager cgroups.Manager
@@ -6,9 +6,12 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"syscall"
 	"testing"
 	"time"
 
+	cgroupConfig "github.com/opencontainers/runc/libcontainer/configs"
+
 	"github.com/hashicorp/nomad/client/allocdir"
 	"github.com/hashicorp/nomad/client/driver/env"
 	"github.com/hashicorp/nomad/client/testutil"
@@ -180,3 +183,189 @@ func TestExecutor_Start_Kill(t *testing.T) {
 		t.Fatalf("Command output incorrectly: want %v; got %v", expected, act)
 	}
 }
+
+func TestExecutor_Container(t *testing.T) {
+	testutil.ExecCompatible(t)
+
+	ctx := testExecutorContext(t)
+	defer ctx.AllocDir.Destroy()
+
+	// The container runs the shell of the host, mounted in its rootfs
+	rootfs := filepath.Join(ctx.AllocDir.TaskDirs[ctx.TaskName], "rootfs")
+	for _, dir := range []string{"etc", "work"} {
+		if err := os.MkdirAll(filepath.Join(rootfs, dir), 0755); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+	passwd := "root:x:0:0::/root:/bin/sh\napp:x:1000:1001::/work:/bin/sh\n"
+	if err := ioutil.WriteFile(filepath.Join(rootfs, "etc", "passwd"), []byte(passwd), 0644); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	ctx.Container = &cgroupConfig.Config{
+		Rootfs:     rootfs,
+		Namespaces: cgroupConfig.Namespaces{{Type: cgroupConfig.NEWNS}, {Type: cgroupConfig.NEWUTS}},
+		Mounts: []*cgroupConfig.Mount{
+			{Source: "proc", Destination: "/proc", Device: "proc"},
+			{Source: "tmpfs", Destination: "/dev", Device: "tmpfs", Data: "mode=755"},
+			{Source: ctx.AllocDir.SharedDir, Destination: "/alloc", Device: "bind", Flags: syscall.MS_BIND | syscall.MS_REC},
+		},
+		Devices: cgroupConfig.DefaultSimpleDevices,
+		Rlimits: []cgroupConfig.Rlimit{{Type: syscall.RLIMIT_NOFILE, Soft: 256, Hard: 512}},
+	}
+	for _, dir := range []string{"/bin", "/lib", "/lib64", "/usr"} {
+		if _, err := os.Stat(dir); err == nil {
+			ctx.Container.Mounts = append(ctx.Container.Mounts, &cgroupConfig.Mount{
+				Source: dir, Destination: dir, Device: "bind",
+				Flags: syscall.MS_BIND | syscall.MS_REC | syscall.MS_RDONLY})
+		}
+	}
+	ctx.ResourceLimits = true
+	ctx.User = "app"
+
+	execCmd := ExecCommand{
+		Cmd:  "sh",
+		Args: []string{"-c", "id -u; id -g; pwd; ulimit -n; ls /alloc"},
+		Dir:  "/work",
+	}
+	executor := NewExecutor(log.New(os.Stdout, "", log.LstdFlags))
+	ps, err := executor.LaunchCmd(&execCmd, ctx)
+	if err != nil {
+		t.Fatalf("error in launching command: %v", err)
+	}
+	if ps.Pid == 0 {
+		t.Fatalf("expected process to start and have non zero pid")
+	}
+	ps, err = executor.Wait()
+	if err != nil {
+		t.Fatalf("error in waiting for command: %v", err)
+	}
+	if ps.ExitCode != 0 {
+		t.Fatalf("expected exit code 0, actual: %v", ps.ExitCode)
+	}
+
+	file := filepath.Join(ctx.AllocDir.LogDir(), "web.stdout.0")
+	output, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatalf("Couldn't read file %v", file)
+	}
+	expected := "1000\n1001\n/work\n256\n" + "data\nlogs\ntmp"
+	if act := strings.TrimSpace(string(output)); act != expected {
+		t.Fatalf("Command output incorrectly: want %q; got %q", expected, act)
+	}
+
+	// Destroying the alloc dir unmounts the container without deleting the
+	// mounted host directories
+	if err := ctx.AllocDir.Destroy(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := os.Stat(rootfs); !os.IsNotExist(err) {
+		t.Fatalf("rootfs not removed: %v", err)
+	}
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Fatalf("host files removed: %v", err)
+	}
+}
+
+func TestExecutor_Container_Isolation(t *testing.T) {
+	testutil.ExecCompatible(t)
+
+	ctx := testExecutorContext(t)
+	defer ctx.AllocDir.Destroy()
+
+	// The container runs the shell of the host as root, with its own pid
+	// namespace, /proc and /dev
+	rootfs := filepath.Join(ctx.AllocDir.TaskDirs[ctx.TaskName], "rootfs")
+	if err := os.MkdirAll(rootfs, 0755); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	ctx.Container = &cgroupConfig.Config{
+		Rootfs: rootfs,
+		Namespaces: cgroupConfig.Namespaces{
+			{Type: cgroupConfig.NEWNS},
+			{Type: cgroupConfig.NEWPID},
+		},
+		Mounts: []*cgroupConfig.Mount{
+			{Source: "proc", Destination: "/proc", Device: "proc"},
+			{Source: "tmpfs", Destination: "/dev", Device: "tmpfs", Data: "mode=755"},
+		},
+		Devices:       cgroupConfig.DefaultSimpleDevices,
+		Capabilities:  []string{"CAP_CHOWN", "CAP_KILL"},
+		MaskPaths:     []string{"/proc/timer_list"},
+		ReadonlyPaths: []string{"/proc/sys"},
+	}
+	for _, dir := range []string{"/bin", "/lib", "/lib64", "/usr"} {
+		if _, err := os.Stat(dir); err == nil {
+			ctx.Container.Mounts = append(ctx.Container.Mounts, &cgroupConfig.Mount{
+				Source: dir, Destination: dir, Device: "bind",
+				Flags: syscall.MS_BIND | syscall.MS_REC | syscall.MS_RDONLY})
+		}
+	}
+	ctx.ResourceLimits = true
+
+	script := []string{
+		"echo $$",
+		"ls /dev | tr '\\n' ' '; echo",
+		"wc -c < /proc/timer_list",
+		"grep CapBnd /proc/self/status",
+		"touch /proc/sys/vm/drop_caches 2>/dev/null || echo read-only",
+		"test -e /proc/1/root" + ctx.AllocDir.AllocDir + " || echo contained",
+	}
+	execCmd := ExecCommand{Cmd: "sh", Args: []string{"-c", strings.Join(script, "; ")}}
+	executor := NewExecutor(log.New(os.Stdout, "", log.LstdFlags))
+	if _, err := executor.LaunchCmd(&execCmd, ctx); err != nil {
+		t.Fatalf("error in launching command: %v", err)
+	}
+	ps, err := executor.Wait()
+	if err != nil {
+		t.Fatalf("error in waiting for command: %v", err)
+	}
+	if ps.ExitCode != 0 {
+		t.Fatalf("expected exit code 0, actual: %v", ps.ExitCode)
+	}
+
+	file := filepath.Join(ctx.AllocDir.LogDir(), "web.stdout.0")
+	output, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatalf("Couldn't read file %v", file)
+	}
+	expected := []string{
+		"1",
+		"fd full null ptmx random stderr stdin stdout tty urandom zero ",
+		"0",
+		"CapBnd:\t0000000000000021",
+		"read-only",
+		"contained",
+	}
+	if act := strings.TrimSpace(string(output)); act != strings.Join(expected, "\n") {
+		t.Fatalf("Command output incorrectly: want %q; got %q", strings.Join(expected, "\n"), act)
+	}
+}
+
+func TestExecutor_Container_InitError(t *testing.T) {
+	testutil.ExecCompatible(t)
+
+	ctx := testExecutorContext(t)
+	defer ctx.AllocDir.Destroy()
+
+	// The errors of the init of the container fail the launch
+	rootfs := filepath.Join(ctx.AllocDir.TaskDirs[ctx.TaskName], "rootfs")
+	if err := os.MkdirAll(rootfs, 0755); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	ctx.Container = &cgroupConfig.Config{
+		Rootfs:     rootfs,
+		Namespaces: cgroupConfig.Namespaces{{Type: cgroupConfig.NEWNS}},
+		Mounts: []*cgroupConfig.Mount{
+			{Source: "proc", Destination: "/proc", Device: "proc"},
+			{Source: "tmpfs", Destination: "/dev", Device: "tmpfs", Data: "mode=755"},
+		},
+		Devices:      cgroupConfig.DefaultSimpleDevices,
+		Capabilities: []string{"CAP_BOGUS"},
+	}
+	execCmd := ExecCommand{Cmd: "/bin/sh"}
+	executor := NewExecutor(log.New(os.Stdout, "", log.LstdFlags))
+	_, err := executor.LaunchCmd(&execCmd, ctx)
+	if err == nil || !strings.Contains(err.Error(), "CAP_BOGUS") {
+		t.Fatalf("expected the init to fail, got: %v", err)
+	}
+}
@@ -7,26 +7,67 @@ import (
 	cstructs "github.com/hashicorp/nomad/client/driver/structs"
 	"github.com/hashicorp/nomad/nomad/structs"
 	"log"
-	"strings"
+	"os"
+	"strconv"
+	"syscall"
 	"time"
 )
 
+const (
+	// The codes of ExecMainCode telling how the main process of a service
+	// exited, as in waitid(2).
+	cldExited = 1
+	cldKilled = 2
+	cldDumped = 3
+)
+
 type SystemdExecutor struct {
 	Target     string
 	Properties []systemd.Property
 	logger     *log.Logger
 }
 
-func NewSystemdExecutor(id, command string, logger *log.Logger) *SystemdExecutor {
-	props := []systemd.Property{systemd.PropExecStart(strings.Fields(command), false)}
+// NewSystemdExecutor returns an executor of the transient service unit which
+// runs the command.
+func NewSystemdExecutor(unit string, command []string, logger *log.Logger) *SystemdExecutor {
+	var props []systemd.Property
+	if len(command) != 0 {
+		props = append(props, systemd.PropExecStart(command, false))
+	}
 	props = append(props, systemd.Property{Name: "DefaultDependencies", Value: dbus.MakeVariant(false)})
 	return &SystemdExecutor{
-		Target:     "nomad-" + id + ".service",
+		Target:     unit,
 		Properties: props,
 		logger:     logger,
 	}
 }
 
+// SetEnv sets the environment of the unit.
+func (e *SystemdExecutor) SetEnv(env []string) {
+	e.setProperty("Environment", env)
+}
+
+// SetWorkingDirectory sets the directory the command of the unit runs in.
+func (e *SystemdExecutor) SetWorkingDirectory(dir string) {
+	e.setProperty("WorkingDirectory", dir)
+}
+
+// SetUser sets the user the command of the unit runs as.
+func (e *SystemdExecutor) SetUser(user string) {
+	e.setProperty("User", user)
+}
+
+// SetKill sets the signal stopping the unit and how long systemd waits for it
+// to stop before killing it, which is TimeoutStopSec in unit files.
+func (e *SystemdExecutor) SetKill(signal syscall.Signal, timeout time.Duration) {
+	e.setProperty("KillSignal", int32(signal))
+	e.setProperty("TimeoutStopUSec", uint64(timeout/time.Microsecond))
+}
+
+func (e *SystemdExecutor) setProperty(name string, value interface{}) {
+	e.Properties = append(e.Properties, systemd.Property{Name: name, Value: dbus.MakeVariant(value)})
+}
+
 func (e *SystemdExecutor) Start() error {
 	conn, err := systemd.New()
 	if err != nil {
@@ -34,10 +75,14 @@ func (e *SystemdExecutor) Start() error {
 		return err
 	}
 	defer conn.Close()
+
+	// A failed unit of a previous run of the task would prevent starting it
+	conn.ResetFailedUnit(e.Target)
+
 	statusCh := make(chan string, 1)
 	_, dbusErr := conn.StartTransientUnit(e.Target, "replace", e.Properties, statusCh)
 	if dbusErr != nil {
-		e.logger.Printf("Failed to start transient unit %s. Error:\n", e.Target, dbusErr)
+		e.logger.Printf("[ERROR] Failed to start transient unit %s. Error: %s\n", e.Target, dbusErr)
 		return dbusErr
 	}
 	done := <-statusCh
@@ -48,57 +93,170 @@ func (e *SystemdExecutor) Start() error {
 	return nil
 }
 
-func (e *SystemdExecutor) Limit(resources *structs.Resources) error {
+// Limit sets the resource limits of the unit. The CPU of the task is both its
+// CPU shares and its CPU quota, given the frequency of a core of the node in
+// MHz.
+func (e *SystemdExecutor) Limit(resources *structs.Resources, cpuFrequency float64) error {
 	if resources.MemoryMB > 0 {
-		memoryLimit := systemd.Property{
-			Name:  "MemoryLimit",
-			Value: dbus.MakeVariant(uint64(resources.MemoryMB * 1024 * 1024)),
-		}
-		e.Properties = append(e.Properties, memoryLimit)
+		e.setProperty("MemoryLimit", uint64(resources.MemoryMB*1024*1024))
 	}
 	if resources.CPU > 2 {
-		cpuLimit := systemd.Property{
-			Name:  "CPUShares",
-			Value: dbus.MakeVariant(uint64(resources.CPU)),
+		e.setProperty("CPUShares", uint64(resources.CPU))
+		if cpuFrequency > 0 {
+			// CPUQuota in unit files is the percentage of a core, which is
+			// the CPU time per second over dbus
+			quota := float64(resources.CPU) / cpuFrequency
+			e.setProperty("CPUQuotaPerSecUSec", uint64(quota*float64(time.Second/time.Microsecond)))
 		}
-		e.Properties = append(e.Properties, cpuLimit)
 	}
 	if resources.IOPS > 0 {
-		iopsLimit := systemd.Property{
-			Name:  "BlkioWeight",
-			Value: dbus.MakeVariant(uint64(resources.IOPS)),
+		// BlockIOWeight ranges from 10 to 1000
+		weight := uint64(resources.IOPS)
+		if weight < 10 {
+			weight = 10
+		} else if weight > 1000 {
+			weight = 1000
 		}
-		e.Properties = append(e.Properties, iopsLimit)
+		e.setProperty("BlockIOWeight", weight)
 	}
 	return nil
 }
+
+// Wait waits for the unit to become inactive and returns the exit status of
+// its main process. Changes of the unit are received as dbus signals, so a
+// reopened executor waits for the unit started by another client process the
+// same way.
 func (e *SystemdExecutor) Wait() *cstructs.WaitResult {
-	conn, err := systemd.New()
+	conn, signals, err := e.subscribe()
 	if err != nil {
-		e.logger.Printf("[ERROR]Failed to connect to dbus. Error: %s\n", err)
+		e.logger.Printf("[ERROR] Failed to subscribe to unit %s. Error: %s\n", e.Target, err)
 		return cstructs.NewWaitResult(-1, 0, err)
 	}
 	defer conn.Close()
-	statusCh, errCh := conn.SubscribeUnits(5 * time.Second)
-	for {
-		select {
-		case units := <-statusCh:
-			for k, u := range units {
-				if k == e.Target {
-					e.logger.Printf("[DEBUG]Unit changed: %s\n", k)
-					if u == nil {
-						return cstructs.NewWaitResult(0, 0, nil)
-					} else {
-						e.logger.Printf("State changed for triggered unit: %#v\n", u)
-					}
-				}
-			}
-		case err := <-errCh:
-			return cstructs.NewWaitResult(-1, 0, err)
+	sysconn, err := systemd.New()
+	if err != nil {
+		e.logger.Printf("[ERROR]Failed to connect to dbus. Error: %s\n", err)
+		return cstructs.NewWaitResult(-1, 0, err)
+	}
+	defer sysconn.Close()
+
+	// The exit status is recorded as it changes since the unit may be
+	// unloaded as soon as it is inactive
+	code, status := -1, -1
+	if p, err := sysconn.GetUnitTypeProperties(e.Target, "Service"); err == nil {
+		code, status = mainExitStatus(p, code, status)
+	}
+
+	// The state is checked once subscribed so the unit exiting in between
+	// isn't missed
+	state := ""
+	if p, err := sysconn.GetUnitProperty(e.Target, "ActiveState"); err == nil {
+		state, _ = p.Value.Value().(string)
+	}
+	for state != "inactive" && state != "failed" {
+		signal, ok := <-signals
+		if !ok {
+			return cstructs.NewWaitResult(-1, 0, fmt.Errorf("dbus connection of unit %s closed", e.Target))
+		}
+		if len(signal.Body) < 2 {
+			continue
+		}
+		changed, ok := signal.Body[1].(map[string]dbus.Variant)
+		if !ok {
+			continue
+		}
+		props := make(map[string]interface{}, len(changed))
+		for k, v := range changed {
+			props[k] = v.Value()
 		}
+		code, status = mainExitStatus(props, code, status)
+		if s, ok := props["ActiveState"].(string); ok {
+			state = s
+		}
+	}
+	e.logger.Printf("[DEBUG] Unit %s is %s\n", e.Target, state)
+
+	// Failed units are kept until reset
+	if state == "failed" {
+		sysconn.ResetFailedUnit(e.Target)
+	}
+
+	switch code {
+	case cldExited:
+		return cstructs.NewWaitResult(status, 0, nil)
+	case cldKilled, cldDumped:
+		return cstructs.NewWaitResult(-1, status, nil)
+	}
+	if state == "failed" {
+		return cstructs.NewWaitResult(-1, 0, fmt.Errorf("unit %s failed", e.Target))
+	}
+
+	// The unit was unloaded before its exit status was seen, like when it
+	// exited while the client was down, so it can't be reported as successful
+	return cstructs.NewWaitResult(-1, 0, fmt.Errorf("exit status of unit %s is unknown", e.Target))
+}
+
+// subscribe returns a dbus connection receiving the signals of the changes of
+// the properties of the unit.
+func (e *SystemdExecutor) subscribe() (*dbus.Conn, chan *dbus.Signal, error) {
+	conn, err := dbus.SystemBusPrivate()
+	if err != nil {
+		return nil, nil, err
+	}
+	methods := []dbus.Auth{dbus.AuthExternal(strconv.Itoa(os.Getuid()))}
+	if err := conn.Auth(methods); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	if err := conn.Hello(); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	path := "/org/freedesktop/systemd1/unit/" + systemd.PathBusEscape(e.Target)
+	match := fmt.Sprintf("type='signal',interface='org.freedesktop.DBus.Properties',member='PropertiesChanged',path='%s'", path)
+	if err := conn.BusObject().Call("org.freedesktop.DBus.AddMatch", 0, match).Store(); err != nil {
+		conn.Close()
+		return nil, nil, err
 	}
+	manager := conn.Object("org.freedesktop.systemd1", dbus.ObjectPath("/org/freedesktop/systemd1"))
+	if err := manager.Call("org.freedesktop.systemd1.Manager.Subscribe", 0).Store(); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	signals := make(chan *dbus.Signal, 64)
+	conn.Signal(signals)
+	return conn, signals, nil
+}
+
+// mainExitStatus returns the ExecMainCode and ExecMainStatus of the service
+// properties, or the given ones if they aren't set.
+func mainExitStatus(props map[string]interface{}, code, status int) (int, int) {
+	if c, ok := props["ExecMainCode"].(int32); ok && c != 0 {
+		code = int(c)
+		if s, ok := props["ExecMainStatus"].(int32); ok {
+			status = int(s)
+		}
+	}
+	return code, status
+}
+
+// Signal sends the signal to the processes of the unit.
+func (e *SystemdExecutor) Signal(s os.Signal) error {
+	sig, ok := s.(syscall.Signal)
+	if !ok {
+		return fmt.Errorf("unsupported signal %v", s)
+	}
+	conn, err := systemd.New()
+	if err != nil {
+		e.logger.Printf("[ERROR]Failed to connect to dbus. Error: %s\n", err)
+		return err
+	}
+	defer conn.Close()
+	conn.KillUnit(e.Target, int32(sig))
 	return nil
 }
+
 func (e *SystemdExecutor) Shutdown() error {
 	conn, err := systemd.New()
 	if err != nil {
@@ -1,8 +1,11 @@
 package driver
 
 import (
+	"fmt"
 	"log"
 	"net/rpc"
+	"os"
+	"syscall"
 
 	"github.com/hashicorp/go-plugin"
 	"github.com/hashicorp/nomad/client/driver/executor"
@@ -43,6 +46,14 @@ func (e *ExecutorRPC) UpdateLogConfig(logConfig *structs.LogConfig) error {
 	return e.client.Call("Plugin.UpdateLogConfig", logConfig, new(interface{}))
 }
 
+func (e *ExecutorRPC) Signal(s os.Signal) error {
+	sig, ok := s.(syscall.Signal)
+	if !ok {
+		return fmt.Errorf("unsupported signal %v", s)
+	}
+	return e.client.Call("Plugin.Signal", int(sig), new(interface{}))
+}
+
 type ExecutorRPCServer struct {
 	Impl executor.Executor
 }
@@ -75,6 +86,10 @@ func (e *ExecutorRPCServer) UpdateLogConfig(args *structs.LogConfig, resp *inter
 	return e.Impl.UpdateLogConfig(args)
 }
 
+func (e *ExecutorRPCServer) Signal(args int, resp *interface{}) error {
+	return e.Impl.Signal(syscall.Signal(args))
+}
+
 type ExecutorPlugin struct {
 	logger *log.Logger
 	Impl   *ExecutorRPCServer
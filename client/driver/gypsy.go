@@ -3,16 +3,25 @@ package driver
 import (
 	"fmt"
 	"github.com/hashicorp/nomad/client/config"
+	"github.com/hashicorp/nomad/client/driver/logging"
 	cstructs "github.com/hashicorp/nomad/client/driver/structs"
 	"github.com/hashicorp/nomad/client/fingerprint"
 	"github.com/hashicorp/nomad/nomad/structs"
 	"github.com/mitchellh/mapstructure"
 	gypsy "github.com/ranjib/gypsy/build"
 	lxc "gopkg.in/lxc/go-lxc.v2"
+	"io"
 	"log"
+	"os"
+	"strings"
+	"sync"
 	"time"
 )
 
+// gypsyDefaultServerURL is the URL of the Gypsy server if the task doesn't
+// set one.
+const gypsyDefaultServerURL = "http://127.0.0.1:5678"
+
 type GypsyDriver struct {
 	DriverContext
 	fingerprint.StaticFingerprinter
@@ -25,15 +34,75 @@ type GypsyConfig struct {
 	RunId     int    `mapstructure:"run_id"`
 }
 
+// gypsyContainer is the container the steps of a pipeline run in.
+type gypsyContainer interface {
+	// RunScript runs the command and waits for it to exit, or for the
+	// container to be shut down.
+	RunScript(args []string, env []string, stdout, stderr io.Writer) *cstructs.WaitResult
+
+	// Shutdown stops and destroys the container.
+	Shutdown() error
+}
+
+// gypsyServer is the Gypsy server the pipeline is fetched from and its
+// results are posted to.
+type gypsyServer interface {
+	FetchPipeline(name string) (*gypsy.Pipeline, error)
+
+	// UploadArtifacts uploads the files of the container to the server.
+	UploadArtifacts(artifacts []string) error
+
+	// PostRun posts whether the run of the pipeline succeeded.
+	PostRun(success bool) error
+}
+
+// gypsyBuilderServer talks to the Gypsy server using the builder of the run
+// of the pipeline, which reads the artifacts from the container.
+type gypsyBuilderServer struct {
+	builder   *gypsy.Builder
+	container *lxc.Container
+}
+
+func (s *gypsyBuilderServer) FetchPipeline(name string) (*gypsy.Pipeline, error) {
+	return s.builder.FetchPipeline(name)
+}
+
+func (s *gypsyBuilderServer) UploadArtifacts(artifacts []string) error {
+	return s.builder.UploadArtifacts(s.container, artifacts)
+}
+
+func (s *gypsyBuilderServer) PostRun(success bool) error {
+	s.builder.Run.Success = success
+	return s.builder.PostRunData()
+}
+
 type gypsyHandle struct {
 	logger    *log.Logger
 	Id        string
 	waitCh    chan *cstructs.WaitResult
 	doneCh    chan struct{}
-	executor  *LXCExecutor
+	container gypsyContainer
+	server    gypsyServer
 	Pipeline  string
 	RunId     int
 	ServerURL string
+
+	// env is the environment of the steps and their output is written to
+	// the stdout and stderr logs of the task.
+	env    []string
+	stdout *logging.FileRotator
+	stderr *logging.FileRotator
+
+	// emitEvent records the events of the steps of the pipeline.
+	emitEvent EventEmitter
+
+	killCh   chan struct{}
+	killOnce sync.Once
+
+	// cleanupLock guards destroying the container, which is done once by
+	// either the build or Kill.
+	cleanupLock sync.Mutex
+	cleaned     bool
 }
 
 func NewGypsyDriver(ctx *DriverContext) Driver {
@@ -58,81 +127,172 @@ func (d *GypsyDriver) Start(ctx *ExecContext, task *structs.Task) (DriverHandle,
 	}
 	executor, e := NewLXCExecutor(lxcConfig, d.logger)
 	d.logger.Printf("[DEBUG] Using lxc name: %s", lxcConfig.Name)
-	//envVars := TaskEnvironmentVariables(ctx, task)
 	if e != nil {
 		d.logger.Printf("[ERROR] failed to create container: %s", e)
 		return nil, e
 	}
 	d.logger.Printf("[DEBUG] Successfully created container: %s", lxcConfig.Name)
-	var gypsyServerURL string
-	if config.ServerURL == "" {
-		gypsyServerURL = "http://127.0.0.1:5678"
-	} else {
-		gypsyServerURL = config.ServerURL
+	gypsyServerURL := config.ServerURL
+	if gypsyServerURL == "" {
+		gypsyServerURL = gypsyDefaultServerURL
+	}
+
+	if err := executor.Limit(task.Resources); err != nil {
+		d.logger.Printf("[WARN] Failed to set resource constraints %s", err)
+		executor.Shutdown()
+		return nil, err
+	}
+	if err := executor.Start(); err != nil {
+		d.logger.Printf("[WARN] Failed to start container %s", err)
+		executor.Shutdown()
+		return nil, err
 	}
+	if !executor.container.Wait(lxc.RUNNING, lxcStartTimeout) {
+		executor.Shutdown()
+		return nil, fmt.Errorf("container %s is not running after %v", lxcConfig.Name, lxcStartTimeout)
+	}
+	d.logger.Printf("[INFO] Waiting for ip allocation of container: %s\n", lxcConfig.Name)
+	if _, err := executor.IPv4Address(lxcIPTimeout); err != nil {
+		d.logger.Printf("[WARN] Container %s has no IP address, the pipeline may fail: %v", lxcConfig.Name, err)
+	}
+
+	logFileSize := int64(task.LogConfig.MaxFileSizeMB * 1024 * 1024)
+	lro, err := logging.NewFileRotator(ctx.AllocDir.LogDir(), fmt.Sprintf("%v.stdout", task.Name),
+		task.LogConfig.MaxFiles, logFileSize, d.logger)
+	if err != nil {
+		executor.Shutdown()
+		return nil, fmt.Errorf("error creating log rotator for stdout of task %v", err)
+	}
+	lre, err := logging.NewFileRotator(ctx.AllocDir.LogDir(), fmt.Sprintf("%v.stderr", task.Name),
+		task.LogConfig.MaxFiles, logFileSize, d.logger)
+	if err != nil {
+		lro.Close()
+		executor.Shutdown()
+		return nil, fmt.Errorf("error creating log rotator for stderr of task %v", err)
+	}
+
+	d.taskEnv.Build()
 	h := &gypsyHandle{
 		Id:        ctx.AllocID,
 		logger:    d.logger,
 		doneCh:    make(chan struct{}),
 		waitCh:    make(chan *cstructs.WaitResult, 1),
-		executor:  executor,
+		container: executor,
+		server: &gypsyBuilderServer{
+			builder:   gypsy.NewBuilder(gypsyServerURL, config.Pipeline, config.RunId),
+			container: executor.Container(),
+		},
 		Pipeline:  config.Pipeline,
 		RunId:     config.RunId,
 		ServerURL: gypsyServerURL,
+		env:       d.taskEnv.EnvList(),
+		stdout:    lro,
+		stderr:    lre,
+		emitEvent: d.emitEvent,
+		killCh:    make(chan struct{}),
 	}
+	go h.run()
+	return h, nil
+}
 
-	if err := h.executor.Limit(task.Resources); err != nil {
-		d.logger.Printf("[WARN] Failed to set resource constraints %s", err)
-		return nil, err
+// performBuild runs the pipeline, posts its result to the Gypsy server and
+// destroys the container. The result of the build is the one of the failed
+// step if a step failed.
+func (h *gypsyHandle) performBuild() *cstructs.WaitResult {
+	result := h.runPipeline()
+	if err := h.server.PostRun(result.Successful()); err != nil {
+		h.logger.Printf("[ERR] Failed to post run %d of pipeline %s: %v", h.RunId, h.Pipeline, err)
 	}
-
-	if err := h.executor.Start(); err != nil {
-		d.logger.Printf("[WARN] Failed to start container %s", err)
-		return nil, err
+	if err := h.cleanup(); err != nil {
+		h.logger.Printf("[ERR] Failed to destroy container of pipeline %s. Error: %v", h.Pipeline, err)
+		if result.Successful() {
+			result = cstructs.NewWaitResult(-1, 0, err)
+		}
 	}
-	go h.run()
-	return h, nil
+	return result
 }
 
-func (h *gypsyHandle) performBuild() error {
-	container := h.executor.Container()
-	h.logger.Printf("[INFO] Waiting for ip allocation of container: %s\n", container.Name())
-	container.WaitIPAddresses(30 * time.Second)
-	client := gypsy.NewBuilder(h.ServerURL, h.Pipeline, h.RunId)
-	pipeline, err := client.FetchPipeline(h.Pipeline)
+// runPipeline runs the steps of the pipeline and uploads its artifacts once
+// they all succeed.
+func (h *gypsyHandle) runPipeline() *cstructs.WaitResult {
+	pipeline, err := h.server.FetchPipeline(h.Pipeline)
 	if err != nil {
 		h.logger.Printf("[ERR] Failed to fetch pipeline %s", err)
-		return err
+		return cstructs.NewWaitResult(-1, 0, err)
 	}
-	err = client.PerformBuild(container, pipeline.Scripts)
-	if err != nil {
-		h.logger.Printf("[ERR] Failed to build pipeline %s. Error: %v", h.Pipeline, err)
-		return err
+
+	for i, script := range pipeline.Scripts {
+		if h.killed() {
+			return cstructs.NewWaitResult(-1, 0, fmt.Errorf("pipeline %s killed", h.Pipeline))
+		}
+		name := gypsyStepName(i, script)
+		start := time.Now()
+		result := h.container.RunScript([]string{"/bin/sh", "-c", script}, h.env, h.stdout, h.stderr)
+		h.emitEvent(structs.NewTaskEvent(structs.TaskPipelineStep).
+			SetStepName(name).
+			SetExitCode(result.ExitCode).
+			SetSignal(result.Signal).
+			SetStepDuration(time.Since(start)).
+			SetExitMessage(result.Err))
+
+		if h.killed() {
+			return cstructs.NewWaitResult(-1, 0, fmt.Errorf("pipeline %s killed during step %q", h.Pipeline, name))
+		}
+		if !result.Successful() {
+			h.logger.Printf("[ERR] Step %q of pipeline %s failed: %v", name, h.Pipeline, result)
+			return result
+		}
 	}
-	if len(pipeline.Artifacts) > 0 {
-		err = client.UploadArtifacts(container, pipeline.Artifacts)
+
+	// The artifacts are uploaded one at a time so each is reported
+	for _, artifact := range pipeline.Artifacts {
+		err := h.server.UploadArtifacts([]string{artifact})
+		h.emitEvent(structs.NewTaskEvent(structs.TaskPipelineArtifact).
+			SetArtifact(artifact).
+			SetExitMessage(err))
 		if err != nil {
 			h.logger.Printf("[ERR] Failed to upload pipeline %s artifact. Error: %v", h.Pipeline, err)
-			return err
+			return cstructs.NewWaitResult(-1, 0, err)
 		}
 	}
-	client.Run.Success = true
-	client.PostRunData()
-	err = client.DestroyContainer(container)
-	if err != nil {
-		h.logger.Printf("[ERR] Failed to build pipeline %s. Error: %v", h.Pipeline, err)
+	return cstructs.NewWaitResult(0, 0, nil)
+}
+
+// gypsyStepName returns the name of the step of the script, which is its
+// position in the pipeline and its first line.
+func gypsyStepName(i int, script string) string {
+	line := strings.TrimSpace(strings.SplitN(strings.TrimSpace(script), "\n", 2)[0])
+	return fmt.Sprintf("%d: %s", i+1, line)
+}
+
+// cleanup destroys the container of the pipeline, unless it already was.
+func (h *gypsyHandle) cleanup() error {
+	h.cleanupLock.Lock()
+	defer h.cleanupLock.Unlock()
+	if h.cleaned {
+		return nil
+	}
+	if err := h.container.Shutdown(); err != nil {
 		return err
 	}
+	h.cleaned = true
 	return nil
 }
-func (h *gypsyHandle) run() {
-	var waitResult *cstructs.WaitResult
-	err := h.performBuild()
-	if err != nil {
-		waitResult = cstructs.NewWaitResult(-1, -1, err)
-	} else {
-		waitResult = cstructs.NewWaitResult(0, 0, nil)
+
+// killed returns whether the build was killed.
+func (h *gypsyHandle) killed() bool {
+	select {
+	case <-h.killCh:
+		return true
+	default:
+		return false
 	}
+}
+
+func (h *gypsyHandle) run() {
+	waitResult := h.performBuild()
+	h.stdout.Close()
+	h.stderr.Close()
 	close(h.doneCh)
 	h.waitCh <- waitResult
 	close(h.waitCh)
@@ -150,10 +310,12 @@ func (d *GypsyDriver) Open(ctx *ExecContext, name string) (DriverHandle, error)
 		logger: d.logger,
 		doneCh: make(chan struct{}),
 		waitCh: make(chan *cstructs.WaitResult, 1),
-		executor: &LXCExecutor{
+		container: &LXCExecutor{
 			container: c,
 			logger:    d.logger,
 		},
+		emitEvent: d.emitEvent,
+		killCh:    make(chan struct{}),
 	}
 	return h, nil
 }
@@ -166,11 +328,18 @@ func (h *gypsyHandle) WaitCh() chan *cstructs.WaitResult {
 	return h.waitCh
 }
 
+func (h *gypsyHandle) Signal(s os.Signal) error {
+	return fmt.Errorf("Signal is not supported by gypsy driver")
+}
+
+// Kill stops the pipeline. The running step is interrupted by destroying the
+// container and the following ones aren't run.
 func (h *gypsyHandle) Kill() error {
-	return h.executor.Shutdown()
+	h.killOnce.Do(func() { close(h.killCh) })
+	return h.cleanup()
 }
 
 func (h *gypsyHandle) Update(task *structs.Task) error {
-	h.logger.Printf("[WARN] Update is not supported by lxc driver")
+	h.logger.Printf("[WARN] Update is not supported by gypsy driver")
 	return nil
 }
@@ -0,0 +1,428 @@
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/nomad/client/driver/logging"
+	cstructs "github.com/hashicorp/nomad/client/driver/structs"
+	"github.com/hashicorp/nomad/nomad/structs"
+	gypsy "github.com/ranjib/gypsy/build"
+	lxc "gopkg.in/lxc/go-lxc.v2"
+)
+
+// fakeGypsyServer is a stand-in for the Gypsy server which serves a single
+// pipeline and records the runs posted and the artifacts uploaded to it.
+type fakeGypsyServer struct {
+	pipeline *gypsy.Pipeline
+
+	// files are the artifacts which can be uploaded.
+	files map[string]bool
+
+	lock      sync.Mutex
+	runs      []bool
+	artifacts []string
+}
+
+func newFakeGypsyServer(pipeline *gypsy.Pipeline) *fakeGypsyServer {
+	return &fakeGypsyServer{pipeline: pipeline, files: make(map[string]bool)}
+}
+
+func (s *fakeGypsyServer) FetchPipeline(name string) (*gypsy.Pipeline, error) {
+	if name != s.pipeline.Name {
+		return nil, fmt.Errorf("pipeline %s not found", name)
+	}
+	return s.pipeline, nil
+}
+
+func (s *fakeGypsyServer) UploadArtifacts(artifacts []string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, artifact := range artifacts {
+		if !s.files[artifact] {
+			return fmt.Errorf("%s: No such file or directory", artifact)
+		}
+		s.artifacts = append(s.artifacts, artifact)
+	}
+	return nil
+}
+
+func (s *fakeGypsyServer) PostRun(success bool) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.runs = append(s.runs, success)
+	return nil
+}
+
+func (s *fakeGypsyServer) Runs() []bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.runs
+}
+
+// testGypsyHTTPServer serves the routes of the Gypsy server used by the
+// builder for a single pipeline and records the runs and artifacts posted.
+type testGypsyHTTPServer struct {
+	*httptest.Server
+
+	lock      sync.Mutex
+	runs      []*gypsy.Run
+	artifacts map[string]string
+}
+
+func newTestGypsyHTTPServer(pipeline *gypsy.Pipeline) *testGypsyHTTPServer {
+	s := &testGypsyHTTPServer{artifacts: make(map[string]string)}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/pipelines/"+pipeline.Name:
+			json.NewEncoder(w).Encode(pipeline)
+		case r.Method == "PUT" && strings.HasPrefix(r.URL.Path, "/pipelines/"+pipeline.Name+"/runs/"):
+			var run gypsy.Run
+			if err := json.NewDecoder(r.Body).Decode(&run); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			s.runs = append(s.runs, &run)
+		case r.Method == "POST" && strings.HasPrefix(r.URL.Path, "/artifacts/"+pipeline.Name+"/"):
+			data, _ := ioutil.ReadAll(r.Body)
+			s.artifacts[r.URL.Path] = string(data)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	return s
+}
+
+// fakeGypsyContainer runs the scripts of a pipeline by writing them to
+// stdout and exiting with their configured exit code.
+type fakeGypsyContainer struct {
+	exitCodes map[string]int
+
+	// block makes the script wait for the container to be shut down.
+	block   string
+	running chan struct{}
+
+	lock       sync.Mutex
+	scripts    []string
+	shutdowns  int
+	shutdownCh chan struct{}
+}
+
+func newFakeGypsyContainer() *fakeGypsyContainer {
+	return &fakeGypsyContainer{
+		exitCodes:  make(map[string]int),
+		running:    make(chan struct{}),
+		shutdownCh: make(chan struct{}),
+	}
+}
+
+func (c *fakeGypsyContainer) RunScript(args []string, env []string, stdout, stderr io.Writer) *cstructs.WaitResult {
+	script := args[len(args)-1]
+	c.lock.Lock()
+	c.scripts = append(c.scripts, script)
+	c.lock.Unlock()
+	fmt.Fprintf(stdout, "running %s\n", script)
+	if script == c.block {
+		close(c.running)
+		<-c.shutdownCh
+		return cstructs.NewWaitResult(-1, 9, nil)
+	}
+	return cstructs.NewWaitResult(c.exitCodes[script], 0, nil)
+}
+
+func (c *fakeGypsyContainer) Shutdown() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.shutdowns == 0 {
+		close(c.shutdownCh)
+	}
+	c.shutdowns++
+	return nil
+}
+
+func (c *fakeGypsyContainer) Scripts() []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.scripts
+}
+
+// testGypsyHandle returns a handle running the pipeline of the server in the
+// container, and the events it emits.
+func testGypsyHandle(t *testing.T, s *fakeGypsyServer, c gypsyContainer) (*gypsyHandle, func() []*structs.TaskEvent, string) {
+	logDir, err := ioutil.TempDir("", "gypsy")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	stdout, err := logging.NewFileRotator(logDir, "build.stdout", 1, 1024*1024, testLogger())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	stderr, err := logging.NewFileRotator(logDir, "build.stderr", 1, 1024*1024, testLogger())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	var lock sync.Mutex
+	var events []*structs.TaskEvent
+	h := &gypsyHandle{
+		Id:        "1234",
+		logger:    testLogger(),
+		doneCh:    make(chan struct{}),
+		waitCh:    make(chan *cstructs.WaitResult, 1),
+		container: c,
+		server:    s,
+		Pipeline:  s.pipeline.Name,
+		RunId:     7,
+		stdout:    stdout,
+		stderr:    stderr,
+		emitEvent: func(event *structs.TaskEvent) {
+			lock.Lock()
+			defer lock.Unlock()
+			events = append(events, event)
+		},
+		killCh: make(chan struct{}),
+	}
+	return h, func() []*structs.TaskEvent {
+		lock.Lock()
+		defer lock.Unlock()
+		return events
+	}, logDir
+}
+
+func waitGypsyResult(t *testing.T, h *gypsyHandle) *cstructs.WaitResult {
+	select {
+	case res := <-h.WaitCh():
+		return res
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout waiting for the pipeline")
+	}
+	return nil
+}
+
+func TestGypsyDriver_Pipeline_Failure(t *testing.T) {
+	t.Parallel()
+	s := newFakeGypsyServer(&gypsy.Pipeline{
+		Name:    "web",
+		Scripts: []string{"make deps", "make test\nmake lint", "make release"},
+	})
+	c := newFakeGypsyContainer()
+	c.exitCodes["make test\nmake lint"] = 2
+	h, events, logDir := testGypsyHandle(t, s, c)
+	defer os.RemoveAll(logDir)
+
+	go h.run()
+	res := waitGypsyResult(t, h)
+	if res.ExitCode != 2 {
+		t.Fatalf("got exit code %d; want 2: %v", res.ExitCode, res)
+	}
+
+	// The steps after the failed one aren't run
+	if scripts := c.Scripts(); len(scripts) != 2 {
+		t.Fatalf("bad scripts run: %v", scripts)
+	}
+	if c.shutdowns != 1 {
+		t.Fatalf("container destroyed %d times; want 1", c.shutdowns)
+	}
+
+	evs := events()
+	if len(evs) != 2 {
+		t.Fatalf("got %d events; want 2: %#v", len(evs), evs)
+	}
+	for i, name := range []string{"1: make deps", "2: make test"} {
+		if evs[i].Type != structs.TaskPipelineStep || evs[i].StepName != name {
+			t.Fatalf("bad event %d: %#v", i, evs[i])
+		}
+	}
+	if evs[0].ExitCode != 0 || evs[1].ExitCode != 2 {
+		t.Fatalf("bad exit codes: %d, %d", evs[0].ExitCode, evs[1].ExitCode)
+	}
+
+	// The failure is posted to the server
+	if runs := s.Runs(); len(runs) != 1 || runs[0] {
+		t.Fatalf("bad runs posted: %v", runs)
+	}
+
+	logs, err := ioutil.ReadFile(filepath.Join(logDir, "build.stdout.0"))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if string(logs) != "running make deps\nrunning make test\nmake lint\n" {
+		t.Fatalf("bad logs: %q", logs)
+	}
+}
+
+func TestGypsyDriver_Pipeline_Artifacts(t *testing.T) {
+	t.Parallel()
+	s := newFakeGypsyServer(&gypsy.Pipeline{
+		Name:      "web",
+		Scripts:   []string{"make"},
+		Artifacts: []string{"/build/web.tar.gz"},
+	})
+	s.files["/build/web.tar.gz"] = true
+	c := newFakeGypsyContainer()
+	h, events, logDir := testGypsyHandle(t, s, c)
+	defer os.RemoveAll(logDir)
+
+	go h.run()
+	if res := waitGypsyResult(t, h); !res.Successful() {
+		t.Fatalf("pipeline failed: %v", res)
+	}
+
+	if len(s.artifacts) != 1 || s.artifacts[0] != "/build/web.tar.gz" {
+		t.Fatalf("bad artifacts uploaded: %v", s.artifacts)
+	}
+	evs := events()
+	if len(evs) != 2 || evs[1].Type != structs.TaskPipelineArtifact || evs[1].Artifact != "/build/web.tar.gz" ||
+		evs[1].Message != "" {
+		t.Fatalf("bad events: %#v", evs)
+	}
+	if runs := s.Runs(); len(runs) != 1 || !runs[0] {
+		t.Fatalf("bad runs posted: %v", runs)
+	}
+
+	// A missing artifact fails the pipeline
+	delete(s.files, "/build/web.tar.gz")
+	c = newFakeGypsyContainer()
+	h, events, logDir = testGypsyHandle(t, s, c)
+	defer os.RemoveAll(logDir)
+	go h.run()
+	if res := waitGypsyResult(t, h); res.Successful() || res.Err == nil {
+		t.Fatalf("pipeline with a missing artifact succeeded: %v", res)
+	}
+	if evs := events(); len(evs) != 2 || evs[1].Message == "" {
+		t.Fatalf("bad events: %#v", evs)
+	}
+	if runs := s.Runs(); len(runs) != 2 || runs[1] {
+		t.Fatalf("bad runs posted: %v", runs)
+	}
+}
+
+func TestGypsyDriver_Kill(t *testing.T) {
+	t.Parallel()
+	s := newFakeGypsyServer(&gypsy.Pipeline{
+		Name:    "web",
+		Scripts: []string{"make deps", "make test", "make release"},
+	})
+	c := newFakeGypsyContainer()
+	c.block = "make test"
+	h, events, logDir := testGypsyHandle(t, s, c)
+	defer os.RemoveAll(logDir)
+
+	go h.run()
+	select {
+	case <-c.running:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout waiting for the step to run")
+	}
+	if err := h.Kill(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if res := waitGypsyResult(t, h); res.Successful() {
+		t.Fatalf("killed pipeline succeeded: %v", res)
+	}
+	if scripts := c.Scripts(); len(scripts) != 2 {
+		t.Fatalf("steps run after kill: %v", scripts)
+	}
+	if c.shutdowns != 1 {
+		t.Fatalf("container destroyed %d times; want 1", c.shutdowns)
+	}
+	if evs := events(); len(evs) != 2 || evs[1].Signal != 9 {
+		t.Fatalf("bad events: %#v", evs)
+	}
+	if runs := s.Runs(); len(runs) != 1 || runs[0] {
+		t.Fatalf("bad runs posted: %v", runs)
+	}
+}
+
+func TestGypsyBuilderServer(t *testing.T) {
+	t.Parallel()
+	pipeline := &gypsy.Pipeline{
+		Name:      "web",
+		Scripts:   []string{"make deps", "make release"},
+		Artifacts: []string{"/build/web.tar.gz"},
+	}
+	ts := newTestGypsyHTTPServer(pipeline)
+	defer ts.Close()
+	s := &gypsyBuilderServer{builder: gypsy.NewBuilder(ts.URL, "web", 7)}
+
+	p, err := s.FetchPipeline("web")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if p.Name != "web" || len(p.Scripts) != 2 || p.Scripts[1] != "make release" ||
+		len(p.Artifacts) != 1 || p.Artifacts[0] != "/build/web.tar.gz" {
+		t.Fatalf("bad pipeline: %#v", p)
+	}
+	if _, err := s.FetchPipeline("api"); err == nil {
+		t.Fatalf("fetched a missing pipeline")
+	}
+
+	if err := s.PostRun(false); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := s.PostRun(true); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	ts.lock.Lock()
+	runs := ts.runs
+	ts.lock.Unlock()
+	if len(runs) != 2 || runs[0].Success || !runs[1].Success {
+		t.Fatalf("bad runs posted: %#v", runs)
+	}
+}
+
+func TestGypsyBuilderServer_UploadArtifacts(t *testing.T) {
+	t.Parallel()
+	lxcPath, err := ioutil.TempDir("", "gypsy")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer os.RemoveAll(lxcPath)
+	c, err := lxc.NewContainer("gypsy-upload", lxcPath)
+	if err != nil {
+		t.Skipf("lxc not available: %v", err)
+	}
+	defer lxc.Release(c)
+
+	rootfs := filepath.Join(lxcPath, "gypsy-upload", "rootfs")
+	if err := os.MkdirAll(filepath.Join(rootfs, "build"), 0755); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(rootfs, "build", "web.tar.gz"), []byte("tarball"), 0644); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := c.SetConfigItem("lxc.rootfs", rootfs); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	ts := newTestGypsyHTTPServer(&gypsy.Pipeline{Name: "web"})
+	defer ts.Close()
+	s := &gypsyBuilderServer{builder: gypsy.NewBuilder(ts.URL, "web", 7), container: c}
+	if err := s.UploadArtifacts([]string{"/build/web.tar.gz"}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+	if len(ts.artifacts) != 1 {
+		t.Fatalf("bad artifacts uploaded: %v", ts.artifacts)
+	}
+	for path, content := range ts.artifacts {
+		if !strings.HasSuffix(path, "/web.tar.gz") || content != "tarball" {
+			t.Fatalf("bad artifact uploaded to %s: %q", path, content)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package driver
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -55,23 +57,31 @@ func TestJavaDriver_StartOpen_Wait(t *testing.T) {
 	}
 
 	ctestutils.JavaCompatible(t)
+	ts := httptest.NewServer(http.FileServer(http.Dir("./test-resources/java")))
+	defer ts.Close()
+
 	task := &structs.Task{
 		Name: "demo-app",
 		Config: map[string]interface{}{
-			"artifact_source": "https://dl.dropboxusercontent.com/u/47675/jar_thing/demoapp.jar",
-			"jvm_options":     []string{"-Xmx64m", "-Xms32m"},
-			"checksum":        "sha256:58d6e8130308d32e197c5108edd4f56ddf1417408f743097c2e662df0f0b17c8",
+			"jar_path":    "demoapp.jar",
+			"jvm_options": []string{"-Xmx64m", "-Xms32m"},
 		},
 		LogConfig: &structs.LogConfig{
 			MaxFiles:      10,
 			MaxFileSizeMB: 10,
 		},
 		Resources: basicResources,
+		Artifacts: []*structs.TaskArtifact{
+			{
+				GetterSource: ts.URL + "/demoapp.jar",
+			},
+		},
 	}
 
 	driverCtx, execCtx := testDriverContexts(task)
 	defer execCtx.AllocDir.Destroy()
 	d := NewJavaDriver(driverCtx)
+	testDownloadArtifacts(t, task, execCtx)
 
 	handle, err := d.Start(execCtx, task)
 	if err != nil {
@@ -105,22 +115,30 @@ func TestJavaDriver_Start_Wait(t *testing.T) {
 	}
 
 	ctestutils.JavaCompatible(t)
+	ts := httptest.NewServer(http.FileServer(http.Dir("./test-resources/java")))
+	defer ts.Close()
+
 	task := &structs.Task{
 		Name: "demo-app",
 		Config: map[string]interface{}{
-			"artifact_source": "https://dl.dropboxusercontent.com/u/47675/jar_thing/demoapp.jar",
-			"checksum":        "sha256:58d6e8130308d32e197c5108edd4f56ddf1417408f743097c2e662df0f0b17c8",
+			"jar_path": "demoapp.jar",
 		},
 		LogConfig: &structs.LogConfig{
 			MaxFiles:      10,
 			MaxFileSizeMB: 10,
 		},
 		Resources: basicResources,
+		Artifacts: []*structs.TaskArtifact{
+			{
+				GetterSource: ts.URL + "/demoapp.jar",
+			},
+		},
 	}
 
 	driverCtx, execCtx := testDriverContexts(task)
 	defer execCtx.AllocDir.Destroy()
 	d := NewJavaDriver(driverCtx)
+	testDownloadArtifacts(t, task, execCtx)
 
 	handle, err := d.Start(execCtx, task)
 	if err != nil {
@@ -165,21 +183,30 @@ func TestJavaDriver_Start_Kill_Wait(t *testing.T) {
 	}
 
 	ctestutils.JavaCompatible(t)
+	ts := httptest.NewServer(http.FileServer(http.Dir("./test-resources/java")))
+	defer ts.Close()
+
 	task := &structs.Task{
 		Name: "demo-app",
 		Config: map[string]interface{}{
-			"artifact_source": "https://dl.dropboxusercontent.com/u/47675/jar_thing/demoapp.jar",
+			"jar_path": "demoapp.jar",
 		},
 		LogConfig: &structs.LogConfig{
 			MaxFiles:      10,
 			MaxFileSizeMB: 10,
 		},
 		Resources: basicResources,
+		Artifacts: []*structs.TaskArtifact{
+			{
+				GetterSource: ts.URL + "/demoapp.jar",
+			},
+		},
 	}
 
 	driverCtx, execCtx := testDriverContexts(task)
 	defer execCtx.AllocDir.Destroy()
 	d := NewJavaDriver(driverCtx)
+	testDownloadArtifacts(t, task, execCtx)
 
 	handle, err := d.Start(execCtx, task)
 	if err != nil {
@@ -1,14 +1,23 @@
 package driver
 
 import (
+	"encoding/json"
 	"fmt"
+	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/nomad/client/allocdir"
 	"github.com/hashicorp/nomad/client/config"
+	"github.com/hashicorp/nomad/client/driver/logging"
 	cstructs "github.com/hashicorp/nomad/client/driver/structs"
 	"github.com/hashicorp/nomad/client/fingerprint"
 	"github.com/hashicorp/nomad/nomad/structs"
 	"github.com/mitchellh/mapstructure"
 	lxc "gopkg.in/lxc/go-lxc.v2"
+	"io/ioutil"
 	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
 )
 
 type LXCDriver struct {
@@ -16,12 +25,43 @@ type LXCDriver struct {
 	fingerprint.StaticFingerprinter
 }
 
+const (
+	// lxcStateFile is the file in the task directory recording the container
+	// of the task, so it can be cleaned up when the allocation is garbage
+	// collected even if the client crashed while starting it.
+	lxcStateFile = "lxc-container.json"
+
+	// lxcIPTimeout is how long to wait for a container to get an IP address
+	// when ports are forwarded to it.
+	lxcIPTimeout = 30 * time.Second
+)
+
 type lxcHandle struct {
-	logger   *log.Logger
-	Name     string
-	waitCh   chan *cstructs.WaitResult
-	doneCh   chan struct{}
-	executor *LXCExecutor
+	logger    *log.Logger
+	Name      string
+	id        *lxcId
+	statePath string
+	waitCh    chan *cstructs.WaitResult
+	doneCh    chan struct{}
+	executor  *LXCExecutor
+	baseCache *lxcBaseCache
+
+	cleanupLock sync.Mutex
+	cleaned     bool
+}
+
+// lxcId is the serialized handle of a task and the content of its state file.
+type lxcId struct {
+	Version      string
+	Name         string
+	LXCPath      string
+	CloneFrom    string
+	Pid          int
+	PortForwards []*lxcPortForward
+
+	// Base is the cached base container the container was cloned from
+	// instead of being built from a template.
+	Base string
 }
 
 func NewLXCDriver(ctx *DriverContext) Driver {
@@ -31,81 +71,338 @@ func NewLXCDriver(ctx *DriverContext) Driver {
 func (d *LXCDriver) Fingerprint(cfg *config.Config, node *structs.Node) (bool, error) {
 	node.Attributes["driver.lxc.version"] = lxc.Version()
 	node.Attributes["driver.lxc"] = "1"
+	lxcBaseAttributes(node, getLXCBaseCache(cfg, d.logger).Keys())
 	d.logger.Printf("[DEBUG] lxc.version: %s", node.Attributes["driver.lxc.version"])
 	return true, nil
 }
 
+// Periodic fingerprints the driver periodically so the node attributes of the
+// cached base containers stay up to date.
+func (d *LXCDriver) Periodic() (bool, time.Duration) {
+	return true, 30 * time.Second
+}
+
 func (d *LXCDriver) Start(ctx *ExecContext, task *structs.Task) (DriverHandle, error) {
 	var config LXCExecutorConfig
 	if err := mapstructure.WeakDecode(task.Config, &config); err != nil {
 		return nil, err
 	}
-	executor, e := NewLXCExecutor(&config, d.logger)
+	config.PortMap = mapMergeStrInt(config.PortMapRaw...)
+	if config.LXCPath == "" {
+		config.LXCPath = lxc.DefaultConfigPath()
+	}
+	taskDir, ok := ctx.AllocDir.TaskDirs[task.Name]
+	if !ok {
+		return nil, fmt.Errorf("Could not find task directory for task: %v", task.Name)
+	}
+
+	// Record the container before creating it so it is destroyed when the
+	// allocation is garbage collected even if the client crashes meanwhile
+	id := &lxcId{
+		Version:   d.config.Version,
+		Name:      config.Name,
+		LXCPath:   config.LXCPath,
+		CloneFrom: config.CloneFrom,
+	}
+	// Containers built from a template are cloned from a cached base
+	// container built from it once
+	baseCache := getLXCBaseCache(d.config, d.logger)
+	var baseKey lxcBaseKey
+	if config.CloneFrom == "" {
+		if err := validateTemplate(&config); err != nil {
+			return nil, err
+		}
+		baseKey = lxcBaseKey{
+			Template: config.Template,
+			Distro:   config.Distro,
+			Release:  config.Release,
+			Arch:     config.Arch,
+		}
+		id.Base = lxcBasePrefix + baseKey.String()
+	}
+	statePath := filepath.Join(taskDir, lxcStateFile)
+	if err := writeLXCState(statePath, id); err != nil {
+		return nil, err
+	}
+
+	var executor *LXCExecutor
+	var e error
+	if id.Base != "" {
+		var base *lxcBase
+		if base, e = baseCache.Acquire(baseKey, config.LXCPath, config.Name); e == nil {
+			config.CloneFrom = base.Name
+			config.cloneFromPath = base.LXCPath
+		}
+	}
+	if e == nil {
+		executor, e = NewLXCExecutor(&config, d.logger)
+	}
 	d.logger.Printf("[DEBUG] Using lxc name: %s", config.Name)
-	//envVars := TaskEnvironmentVariables(ctx, task)
 	if e != nil {
 		d.logger.Printf("[ERROR] failed to create container: %s", e)
+		if err := d.cleanup(statePath); err != nil {
+			d.logger.Printf("[ERROR] Failed to clean up container %s: %v", config.Name, err)
+		}
 		return nil, e
 	}
 	d.logger.Printf("[DEBUG] Successfully created container: %s", config.Name)
 	h := &lxcHandle{
-		Name:     config.Name,
-		logger:   d.logger,
-		doneCh:   make(chan struct{}),
-		waitCh:   make(chan *cstructs.WaitResult, 1),
-		executor: executor,
+		Name:      config.Name,
+		id:        id,
+		statePath: statePath,
+		logger:    d.logger,
+		doneCh:    make(chan struct{}),
+		waitCh:    make(chan *cstructs.WaitResult, 1),
+		executor:  executor,
+		baseCache: baseCache,
+	}
+	if err := d.startContainer(ctx, task, taskDir, &config, h); err != nil {
+		if e := h.cleanup(); e != nil {
+			d.logger.Printf("[ERROR] Failed to clean up container %s: %v", config.Name, e)
+		}
+		return nil, err
 	}
+	go h.run()
+	return h, nil
+}
 
+// startContainer configures and starts the created container of the handle,
+// forwards the ports of the task to it and runs the command of the task.
+func (d *LXCDriver) startContainer(ctx *ExecContext, task *structs.Task, taskDir string,
+	config *LXCExecutorConfig, h *lxcHandle) error {
 	if err := h.executor.Limit(task.Resources); err != nil {
 		d.logger.Printf("[WARN] Failed to set resource constraints %s", err)
-		return nil, err
+		return err
+	}
+
+	// The alloc dir and the local dir of the task are mounted like in the
+	// other drivers, along with the host volumes
+	mounts := []*cstructs.MountConfig{
+		{HostPath: ctx.AllocDir.SharedDir, TaskPath: allocdir.SharedAllocName},
+		{HostPath: filepath.Join(taskDir, allocdir.TaskLocal), TaskPath: allocdir.TaskLocal},
+	}
+	if err := h.executor.Mount(append(mounts, d.mounts...)); err != nil {
+		d.logger.Printf("[WARN] Failed to mount host volumes %s", err)
+		return err
 	}
 
 	if err := h.executor.Start(); err != nil {
 		d.logger.Printf("[WARN] Failed to start container %s", err)
-		return nil, err
+		return err
 	}
-	go h.run()
-	return h, nil
+
+	// Forward the ports allocated on the host to the container
+	if task.Resources != nil && len(task.Resources.Networks) != 0 {
+		ip, err := h.executor.IPv4Address(lxcIPTimeout)
+		if err != nil {
+			return err
+		}
+		forwards := lxcPortForwards(task.Resources.Networks, config.PortMap, ip)
+		if err := addPortForwards(forwards); err != nil {
+			return fmt.Errorf("failed to forward ports to container %s: %v", config.Name, err)
+		}
+		h.id.PortForwards = forwards
+	} else if len(config.PortMap) != 0 {
+		return fmt.Errorf("Trying to map ports but no network interface is available")
+	}
+
+	if config.Command != "" {
+		// The ports are exposed at the address of the container rather than
+		// the one of the host
+		d.taskEnv.SetAllocDir(filepath.Join("/", allocdir.SharedAllocName))
+		d.taskEnv.SetTaskLocalDir(filepath.Join("/", allocdir.TaskLocal))
+		d.taskEnv.SetNetworks(nil)
+		d.taskEnv.Build()
+		env := append(d.taskEnv.EnvList(), lxcPortEnv(h.id.PortForwards)...)
+		if err := d.runCommand(ctx, task, env, h.executor); err != nil {
+			d.logger.Printf("[WARN] Failed to run command in container %s", err)
+			return err
+		}
+		h.id.Pid = h.executor.pid
+	}
+	return writeLXCState(h.statePath, h.id)
+}
+
+// runCommand runs the command of the task in the container with the task
+// environment and writes its output to the logs of the task.
+func (d *LXCDriver) runCommand(ctx *ExecContext, task *structs.Task, env []string, executor *LXCExecutor) error {
+	logFileSize := int64(task.LogConfig.MaxFileSizeMB * 1024 * 1024)
+	lro, err := logging.NewFileRotator(ctx.AllocDir.LogDir(), fmt.Sprintf("%v.stdout", task.Name),
+		task.LogConfig.MaxFiles, logFileSize, d.logger)
+	if err != nil {
+		return fmt.Errorf("error creating log rotator for stdout of task %v", err)
+	}
+	lre, err := logging.NewFileRotator(ctx.AllocDir.LogDir(), fmt.Sprintf("%v.stderr", task.Name),
+		task.LogConfig.MaxFiles, logFileSize, d.logger)
+	if err != nil {
+		lro.Close()
+		return fmt.Errorf("error creating log rotator for stderr of task %v", err)
+	}
+	if err := executor.Run(env, lro, lre); err != nil {
+		lro.Close()
+		lre.Close()
+		return err
+	}
+	return nil
 }
 
 func (h *lxcHandle) run() {
 	waitResult := h.executor.Wait()
+
+	// A container is created each time the task is started, so it is
+	// destroyed once the task exits
+	if err := h.cleanup(); err != nil {
+		h.logger.Printf("[ERROR] Failed to destroy container %s: %v", h.Name, err)
+	}
 	close(h.doneCh)
 	h.waitCh <- waitResult
 	close(h.waitCh)
 }
 
-func (d *LXCDriver) Open(ctx *ExecContext, name string) (DriverHandle, error) {
-	lxcpath := lxc.DefaultConfigPath()
-	c, err := lxc.NewContainer(name, lxcpath)
+// cleanup removes the port forwards of the task and destroys its container.
+func (h *lxcHandle) cleanup() error {
+	h.cleanupLock.Lock()
+	defer h.cleanupLock.Unlock()
+	if h.cleaned {
+		return nil
+	}
+
+	var mErr multierror.Error
+	if err := removePortForwards(h.id.PortForwards); err != nil {
+		mErr.Errors = append(mErr.Errors, err)
+	}
+	if err := h.executor.Shutdown(); err != nil {
+		mErr.Errors = append(mErr.Errors, err)
+		return mErr.ErrorOrNil()
+	}
+	if h.id.Base != "" {
+		h.baseCache.Release(h.id.Name)
+	}
+	if err := os.Remove(h.statePath); err != nil && !os.IsNotExist(err) {
+		mErr.Errors = append(mErr.Errors, err)
+	}
+	h.cleaned = true
+	return mErr.ErrorOrNil()
+}
+
+func (d *LXCDriver) Open(ctx *ExecContext, handleID string) (DriverHandle, error) {
+	id := &lxcId{}
+	if err := json.Unmarshal([]byte(handleID), id); err != nil {
+		return nil, fmt.Errorf("Failed to parse handle '%s': %v", handleID, err)
+	}
+	c, err := lxc.NewContainer(id.Name, id.LXCPath)
 	if err != nil {
 		d.logger.Printf("[WARN] Failed to initialize container %s", err)
 		return nil, err
 	}
+	taskDir, ok := ctx.AllocDir.TaskDirs[d.DriverContext.taskName]
+	if !ok {
+		return nil, fmt.Errorf("Could not find task directory for task: %v", d.DriverContext.taskName)
+	}
+
 	h := &lxcHandle{
-		Name:   name,
-		logger: d.logger,
-		doneCh: make(chan struct{}),
-		waitCh: make(chan *cstructs.WaitResult, 1),
+		Name:      id.Name,
+		id:        id,
+		statePath: filepath.Join(taskDir, lxcStateFile),
+		logger:    d.logger,
+		doneCh:    make(chan struct{}),
+		waitCh:    make(chan *cstructs.WaitResult, 1),
 		executor: &LXCExecutor{
 			container: c,
-			logger:    d.logger,
+			config: &LXCExecutorConfig{
+				Name:      id.Name,
+				LXCPath:   id.LXCPath,
+				CloneFrom: id.CloneFrom,
+			},
+			pid:    id.Pid,
+			logger: d.logger,
 		},
+		baseCache: getLXCBaseCache(d.config, d.logger),
 	}
+	go h.run()
 	return h, nil
 }
 
+// Cleanup destroys the container recorded in the state file of the task and
+// removes its port forwards.
+func (d *LXCDriver) Cleanup(ctx *ExecContext, task *structs.Task) error {
+	taskDir, ok := ctx.AllocDir.TaskDirs[task.Name]
+	if !ok {
+		return nil
+	}
+	return d.cleanup(filepath.Join(taskDir, lxcStateFile))
+}
+
+func (d *LXCDriver) cleanup(statePath string) error {
+	data, err := ioutil.ReadFile(statePath)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	id := &lxcId{}
+	if err := json.Unmarshal(data, id); err != nil {
+		return fmt.Errorf("Failed to parse lxc state %q: %v", statePath, err)
+	}
+
+	var mErr multierror.Error
+	if err := removePortForwards(id.PortForwards); err != nil {
+		mErr.Errors = append(mErr.Errors, err)
+	}
+	c, err := lxc.NewContainer(id.Name, id.LXCPath)
+	if err != nil {
+		mErr.Errors = append(mErr.Errors, err)
+		return mErr.ErrorOrNil()
+	}
+	d.logger.Printf("[DEBUG] Cleaning up container %s", id.Name)
+	executor := &LXCExecutor{container: c, logger: d.logger}
+	if err := executor.Shutdown(); err != nil {
+		mErr.Errors = append(mErr.Errors, err)
+		return mErr.ErrorOrNil()
+	}
+	if id.Base != "" {
+		getLXCBaseCache(d.config, d.logger).Release(id.Name)
+	}
+	if err := os.Remove(statePath); err != nil && !os.IsNotExist(err) {
+		mErr.Errors = append(mErr.Errors, err)
+	}
+	return mErr.ErrorOrNil()
+}
+
+// writeLXCState records the container of a task in its state file.
+func writeLXCState(path string, id *lxcId) error {
+	data, err := json.Marshal(id)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("Failed to write lxc state %q: %v", path, err)
+	}
+	return nil
+}
+
 func (h *lxcHandle) ID() string {
-	return fmt.Sprintf("LXC:%s", h.Name)
+	data, err := json.Marshal(h.id)
+	if err != nil {
+		h.logger.Printf("[ERR] driver.lxc: failed to marshal ID to JSON: %s", err)
+	}
+	return string(data)
 }
 
 func (h *lxcHandle) WaitCh() chan *cstructs.WaitResult {
 	return h.waitCh
 }
 
+func (h *lxcHandle) Signal(s os.Signal) error {
+	return h.executor.Signal(s)
+}
+
+func (h *lxcHandle) Exec(opts *cstructs.ExecOptions) (int, error) {
+	return h.executor.Exec(opts)
+}
+
 func (h *lxcHandle) Kill() error {
-	return h.executor.Shutdown()
+	return h.cleanup()
 }
 
 func (h *lxcHandle) Update(task *structs.Task) error {
@@ -0,0 +1,382 @@
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/nomad/client/config"
+	"github.com/hashicorp/nomad/nomad/structs"
+	lxc "gopkg.in/lxc/go-lxc.v2"
+)
+
+const (
+	// lxcBasePrefix is the prefix of the names of the cached base containers.
+	lxcBasePrefix = "nomad-base-"
+
+	// lxcBaseCacheFile is the file in the state directory of the client
+	// recording the cached base containers and the containers cloned from
+	// them, so they are kept across client restarts.
+	lxcBaseCacheFile = "lxc-base-cache.json"
+
+	// lxcBaseCacheSizeOption is the client option setting how many unused
+	// base containers are kept.
+	lxcBaseCacheSizeOption = "driver.lxc.base_cache_size"
+
+	// lxcBaseCacheSizeDefault is how many unused base containers are kept by
+	// default.
+	lxcBaseCacheSizeDefault = 5
+)
+
+var (
+	// sharedLXCBaseCache is the base container cache of the client, which is
+	// shared by the lxc tasks.
+	sharedLXCBaseCache     *lxcBaseCache
+	sharedLXCBaseCacheLock sync.Mutex
+)
+
+// lxcBaseKey identifies the base containers built from a template.
+type lxcBaseKey struct {
+	Template string
+	Distro   string
+	Release  string
+	Arch     string
+}
+
+// String returns the key as used in the names of the base containers and the
+// node attributes.
+func (k lxcBaseKey) String() string {
+	return structs.LXCBaseKey(k.Template, k.Distro, k.Release, k.Arch)
+}
+
+// lxcBase is a base container built from a template. Task containers are
+// cloned from it instead of being built from the template each time.
+type lxcBase struct {
+	Key     lxcBaseKey
+	Name    string
+	LXCPath string
+
+	// Clones are the names of the containers cloned from the base. The base
+	// isn't destroyed while it has clones, which may be snapshots of it.
+	Clones map[string]struct{}
+
+	// LastUsed is when the base was last cloned or released by a clone.
+	LastUsed time.Time
+
+	// ready is closed once the base is built and err is set if building it
+	// failed.
+	ready chan struct{}
+	err   error
+}
+
+// isReady returns whether the base is built.
+func (b *lxcBase) isReady() bool {
+	select {
+	case <-b.ready:
+		return b.err == nil
+	default:
+		return false
+	}
+}
+
+// lxcBaseCache is a cache of the base containers built from templates. The
+// bases are reference counted by the containers cloned from them and the
+// least recently used unreferenced bases are destroyed once there are more
+// than the size of the cache.
+type lxcBaseCache struct {
+	logger *log.Logger
+
+	// path is the file the cache is persisted to. The cache isn't persisted
+	// if it is empty.
+	path string
+
+	// size is how many unreferenced bases are kept.
+	size int
+
+	// create builds a base, destroy destroys it and defined returns whether
+	// its container exists. They are variables so tests don't need lxc.
+	create  func(b *lxcBase) error
+	destroy func(b *lxcBase) error
+	defined func(b *lxcBase) bool
+
+	bases  map[lxcBaseKey]*lxcBase
+	loaded bool
+	lock   sync.Mutex
+}
+
+// newLXCBaseCache returns a cache persisted to the path which keeps size
+// unreferenced bases.
+func newLXCBaseCache(path string, size int, logger *log.Logger) *lxcBaseCache {
+	return &lxcBaseCache{
+		logger:  logger,
+		path:    path,
+		size:    size,
+		create:  createLXCBase,
+		destroy: destroyLXCBase,
+		defined: lxcBaseDefined,
+		bases:   make(map[lxcBaseKey]*lxcBase),
+	}
+}
+
+// getLXCBaseCache returns the base container cache of the client.
+func getLXCBaseCache(cfg *config.Config, logger *log.Logger) *lxcBaseCache {
+	sharedLXCBaseCacheLock.Lock()
+	defer sharedLXCBaseCacheLock.Unlock()
+	if sharedLXCBaseCache == nil {
+		var path string
+		if cfg.StateDir != "" {
+			path = filepath.Join(cfg.StateDir, lxcBaseCacheFile)
+		}
+		size := lxcBaseCacheSizeDefault
+		if raw := cfg.Read(lxcBaseCacheSizeOption); raw != "" {
+			parsed, err := strconv.Atoi(raw)
+			if err != nil || parsed < 0 {
+				logger.Printf("[WARN] driver.lxc: invalid %s %q, using %d", lxcBaseCacheSizeOption, raw, lxcBaseCacheSizeDefault)
+			} else {
+				size = parsed
+			}
+		}
+		sharedLXCBaseCache = newLXCBaseCache(path, size, logger)
+	}
+	return sharedLXCBaseCache
+}
+
+// Acquire returns the base built from the template of the key, building it in
+// the lxc path if it isn't cached, and references it by the clone. The
+// reference must be released once the clone is destroyed, or if cloning it
+// fails.
+func (c *lxcBaseCache) Acquire(key lxcBaseKey, lxcPath, clone string) (*lxcBase, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.load()
+
+	b, ok := c.bases[key]
+	if !ok {
+		b = &lxcBase{
+			Key:     key,
+			Name:    lxcBasePrefix + key.String(),
+			LXCPath: lxcPath,
+			Clones:  make(map[string]struct{}),
+			ready:   make(chan struct{}),
+		}
+		c.bases[key] = b
+
+		// Other bases can be used while the base is built
+		c.lock.Unlock()
+		c.logger.Printf("[DEBUG] driver.lxc: building base container %s", b.Name)
+		err := c.create(b)
+		c.lock.Lock()
+
+		b.err = err
+		close(b.ready)
+		if err != nil {
+			delete(c.bases, key)
+			return nil, fmt.Errorf("failed to build base container %s: %v", b.Name, err)
+		}
+	} else if !b.isReady() {
+		// Wait for the base to be built by another task
+		c.lock.Unlock()
+		<-b.ready
+		c.lock.Lock()
+		if b.err != nil {
+			return nil, fmt.Errorf("failed to build base container %s: %v", b.Name, b.err)
+		}
+	}
+
+	b.Clones[clone] = struct{}{}
+	b.LastUsed = time.Now()
+	c.gc()
+	c.persist()
+	return b, nil
+}
+
+// Release drops the reference of the clone to its base and destroys the least
+// recently used unreferenced bases beyond the size of the cache.
+func (c *lxcBaseCache) Release(clone string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.load()
+
+	for _, b := range c.bases {
+		if _, ok := b.Clones[clone]; ok {
+			delete(b.Clones, clone)
+			b.LastUsed = time.Now()
+		}
+	}
+	c.gc()
+	c.persist()
+}
+
+// Keys returns the keys of the built bases.
+func (c *lxcBaseCache) Keys() []lxcBaseKey {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.load()
+
+	var keys []lxcBaseKey
+	for key, b := range c.bases {
+		if b.isReady() {
+			keys = append(keys, key)
+		}
+	}
+	sort.Sort(lxcBaseKeys(keys))
+	return keys
+}
+
+// gc destroys the least recently used unreferenced bases until at most size
+// of them are left. It must be called with the lock held.
+func (c *lxcBaseCache) gc() {
+	var unused []*lxcBase
+	for _, b := range c.bases {
+		if b.isReady() && len(b.Clones) == 0 {
+			unused = append(unused, b)
+		}
+	}
+	if len(unused) <= c.size {
+		return
+	}
+	sort.Sort(lxcBasesByLastUsed(unused))
+	for _, b := range unused[:len(unused)-c.size] {
+		c.logger.Printf("[DEBUG] driver.lxc: destroying unused base container %s", b.Name)
+		if err := c.destroy(b); err != nil {
+			c.logger.Printf("[ERROR] driver.lxc: failed to destroy base container %s: %v", b.Name, err)
+			continue
+		}
+		delete(c.bases, b.Key)
+	}
+}
+
+// load reads the bases from the file of the cache the first time it is
+// called, dropping the ones whose container no longer exists. It must be
+// called with the lock held.
+func (c *lxcBaseCache) load() {
+	if c.loaded || c.path == "" {
+		return
+	}
+	c.loaded = true
+
+	data, err := ioutil.ReadFile(c.path)
+	if os.IsNotExist(err) {
+		return
+	} else if err != nil {
+		c.logger.Printf("[ERROR] driver.lxc: failed to read base container cache %q: %v", c.path, err)
+		return
+	}
+	var bases []*lxcBase
+	if err := json.Unmarshal(data, &bases); err != nil {
+		c.logger.Printf("[ERROR] driver.lxc: failed to parse base container cache %q: %v", c.path, err)
+		return
+	}
+	for _, b := range bases {
+		if !c.defined(b) {
+			continue
+		}
+		if b.Clones == nil {
+			b.Clones = make(map[string]struct{})
+		}
+		b.ready = make(chan struct{})
+		close(b.ready)
+		c.bases[b.Key] = b
+	}
+}
+
+// persist writes the built bases to the file of the cache. It must be called
+// with the lock held.
+func (c *lxcBaseCache) persist() {
+	if c.path == "" {
+		return
+	}
+	var bases []*lxcBase
+	for _, b := range c.bases {
+		if b.isReady() {
+			bases = append(bases, b)
+		}
+	}
+	data, err := json.Marshal(bases)
+	if err != nil {
+		c.logger.Printf("[ERROR] driver.lxc: failed to encode base container cache: %v", err)
+		return
+	}
+	if err := ioutil.WriteFile(c.path, data, 0600); err != nil {
+		c.logger.Printf("[ERROR] driver.lxc: failed to write base container cache %q: %v", c.path, err)
+	}
+}
+
+// lxcBaseAttributes sets the node attributes of the cached bases and removes
+// the ones of the bases no longer cached.
+func lxcBaseAttributes(node *structs.Node, keys []lxcBaseKey) {
+	for attr := range node.Attributes {
+		if strings.HasPrefix(attr, structs.LXCBaseAttrPrefix) {
+			delete(node.Attributes, attr)
+		}
+	}
+	for _, key := range keys {
+		node.Attributes[structs.LXCBaseAttrPrefix+key.String()] = "1"
+	}
+}
+
+// createLXCBase builds the container of the base from its template.
+func createLXCBase(b *lxcBase) error {
+	c, err := lxc.NewContainer(b.Name, b.LXCPath)
+	if err != nil {
+		return err
+	}
+	if c.Defined() {
+		// Left over by a previous client
+		return nil
+	}
+	options := lxc.TemplateOptions{
+		Template: b.Key.Template,
+		Distro:   b.Key.Distro,
+		Release:  b.Key.Release,
+		Arch:     b.Key.Arch,
+	}
+	if err := c.Create(options); err != nil {
+		c.Destroy()
+		return err
+	}
+	return nil
+}
+
+// destroyLXCBase destroys the container of the base.
+func destroyLXCBase(b *lxcBase) error {
+	c, err := lxc.NewContainer(b.Name, b.LXCPath)
+	if err != nil {
+		return err
+	}
+	if !c.Defined() {
+		return nil
+	}
+	return c.Destroy()
+}
+
+// lxcBaseDefined returns whether the container of the base exists.
+func lxcBaseDefined(b *lxcBase) bool {
+	c, err := lxc.NewContainer(b.Name, b.LXCPath)
+	if err != nil {
+		return false
+	}
+	return c.Defined()
+}
+
+// lxcBaseKeys sorts the keys of bases by their string form.
+type lxcBaseKeys []lxcBaseKey
+
+func (k lxcBaseKeys) Len() int           { return len(k) }
+func (k lxcBaseKeys) Less(i, j int) bool { return k[i].String() < k[j].String() }
+func (k lxcBaseKeys) Swap(i, j int)      { k[i], k[j] = k[j], k[i] }
+
+// lxcBasesByLastUsed sorts bases from the least recently used.
+type lxcBasesByLastUsed []*lxcBase
+
+func (b lxcBasesByLastUsed) Len() int           { return len(b) }
+func (b lxcBasesByLastUsed) Less(i, j int) bool { return b[i].LastUsed.Before(b[j].LastUsed) }
+func (b lxcBasesByLastUsed) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
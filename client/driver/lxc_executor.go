@@ -2,14 +2,36 @@ package driver
 
 import (
 	"fmt"
+	"github.com/hashicorp/nomad/client/driver/logging"
 	cstructs "github.com/hashicorp/nomad/client/driver/structs"
 	"github.com/hashicorp/nomad/nomad/structs"
 	lxc "gopkg.in/lxc/go-lxc.v2"
+	"io"
 	"log"
+	"os"
 	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 )
 
+const (
+	// lxcStartTimeout is how long to wait for a container to be running
+	// before attaching the command of the task to it.
+	lxcStartTimeout = 30 * time.Second
+
+	// lxcReattachPollInterval is how often the command of a task is checked
+	// for having exited when the handle was reopened by another client
+	// process, which can't wait for it.
+	lxcReattachPollInterval = 1 * time.Second
+
+	// lxcLogsTimeout is how long to wait for the output of a command to be
+	// copied to the logs once it exited. Processes it left running in the
+	// container may keep its output open until the container is destroyed.
+	lxcLogsTimeout = 5 * time.Second
+)
+
 type LXCExecutorConfig struct {
 	LXCPath     string            `mapstructure:"lxc_path"`
 	Name        string            `mapstructure:"name"`
@@ -20,12 +42,36 @@ type LXCExecutorConfig struct {
 	Arch        string            `mapstructure:"arch"`
 	CgroupItems map[string]string `mapstructure:"cgroup_items"`
 	ConfigItems map[string]string `mapstructure:"config_items"`
+	Command     string            `mapstructure:"command"`
+	Args        []string          `mapstructure:"args"`
+	PortMapRaw  []map[string]int  `mapstructure:"port_map"`
+	PortMap     map[string]int    `mapstructure:"-"`
+
+	// Snapshot clones the container as a copy-on-write snapshot of the
+	// container it is cloned from, using the backing store of Backend such
+	// as overlayfs or btrfs.
+	Snapshot bool   `mapstructure:"snapshot"`
+	Backend  string `mapstructure:"backend"`
+
+	// cloneFromPath is the lxc path of the container cloned from if it
+	// differs from LXCPath, like for cached base containers.
+	cloneFromPath string
 }
 
 type LXCExecutor struct {
 	logger    *log.Logger
 	container *lxc.Container
 	config    *LXCExecutorConfig
+
+	// pid is the pid of the command of the task attached to the container.
+	// It is zero if the init process of the container is the task.
+	pid int
+
+	// logs tracks the copying of the output of the command to the logs.
+	logs sync.WaitGroup
+
+	// logsTimeout overrides lxcLogsTimeout if set.
+	logsTimeout time.Duration
 }
 
 func (e *LXCExecutor) Container() *lxc.Container {
@@ -46,18 +92,27 @@ func NewLXCExecutor(config *LXCExecutorConfig, logger *log.Logger) (*LXCExecutor
 	return &executor, nil
 }
 
-func createFromTemplate(config *LXCExecutorConfig) (*lxc.Container, error) {
+// validateTemplate checks the config has the template options needed to
+// build a container.
+func validateTemplate(config *LXCExecutorConfig) error {
 	if config.Template == "" {
-		return nil, fmt.Errorf("Missing template name for lxc driver")
+		return fmt.Errorf("Missing template name for lxc driver")
 	}
 	if config.Distro == "" {
-		return nil, fmt.Errorf("Missing distro name for lxc driver")
+		return fmt.Errorf("Missing distro name for lxc driver")
 	}
 	if config.Release == "" {
-		return nil, fmt.Errorf("Missing release name for lxc driver")
+		return fmt.Errorf("Missing release name for lxc driver")
 	}
 	if config.Arch == "" {
-		return nil, fmt.Errorf("Missing arch name for lxc driver")
+		return fmt.Errorf("Missing arch name for lxc driver")
+	}
+	return nil
+}
+
+func createFromTemplate(config *LXCExecutorConfig) (*lxc.Container, error) {
+	if err := validateTemplate(config); err != nil {
+		return nil, err
 	}
 	options := lxc.TemplateOptions{
 		Template:             config.Template,
@@ -77,12 +132,33 @@ func createFromTemplate(config *LXCExecutorConfig) (*lxc.Container, error) {
 	return c, nil
 }
 
+// cloneOptions returns the options cloning the container of the config.
+func cloneOptions(config *LXCExecutorConfig) (lxc.CloneOptions, error) {
+	options := lxc.DefaultCloneOptions
+	options.ConfigPath = config.LXCPath
+	options.Snapshot = config.Snapshot
+	if config.Backend != "" {
+		if err := options.Backend.Set(config.Backend); err != nil {
+			return options, fmt.Errorf("Invalid backend %q for lxc driver: %v", config.Backend, err)
+		}
+	}
+	return options, nil
+}
+
 func createByCloning(config *LXCExecutorConfig) (*lxc.Container, error) {
-	c, err := lxc.NewContainer(config.CloneFrom, config.LXCPath)
+	options, err := cloneOptions(config)
 	if err != nil {
 		return nil, err
 	}
-	if err := c.Clone(config.Name, lxc.DefaultCloneOptions); err != nil {
+	cloneFromPath := config.cloneFromPath
+	if cloneFromPath == "" {
+		cloneFromPath = config.LXCPath
+	}
+	c, err := lxc.NewContainer(config.CloneFrom, cloneFromPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Clone(config.Name, options); err != nil {
 		return nil, err
 	}
 	c1, err1 := lxc.NewContainer(config.Name, config.LXCPath)
@@ -126,17 +202,174 @@ func CreateLXCContainer(config *LXCExecutorConfig) (*lxc.Container, error) {
 	return container, nil
 }
 
+// Wait waits for the task to exit. If the task is a command attached to the
+// container, its exit code and signal are returned. Otherwise it waits for the
+// container to stop, whose exit status isn't known.
 func (e *LXCExecutor) Wait() *cstructs.WaitResult {
+	if e.pid == 0 {
+		// A negative timeout waits forever
+		e.container.Wait(lxc.STOPPED, -1*time.Second)
+		return cstructs.NewWaitResult(0, 0, nil)
+	}
+
+	var status syscall.WaitStatus
 	for {
-		if e.container.Running() {
-			time.Sleep(5 * time.Second)
-		} else {
+		_, err := syscall.Wait4(e.pid, &status, 0, nil)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err == syscall.ECHILD {
+			return e.pollCommand()
+		}
+		if err != nil {
+			e.waitLogs()
+			return cstructs.NewWaitResult(-1, 0, fmt.Errorf("failed to wait for the command of container %s: %v",
+				e.container.Name(), err))
+		}
+		break
+	}
+	e.waitLogs()
+
+	signal := 0
+	if status.Signaled() {
+		signal = int(status.Signal())
+	}
+	return cstructs.NewWaitResult(status.ExitStatus(), signal, nil)
+}
+
+// waitLogs waits for the output of the exited command to be copied to the
+// logs, for at most the logs timeout so that processes it left running don't
+// keep the command from being reported as exited. Their output keeps being
+// copied until the container is destroyed.
+func (e *LXCExecutor) waitLogs() {
+	timeout := e.logsTimeout
+	if timeout == 0 {
+		timeout = lxcLogsTimeout
+	}
+	copied := make(chan struct{})
+	go func() {
+		e.logs.Wait()
+		close(copied)
+	}()
+	select {
+	case <-copied:
+	case <-time.After(timeout):
+		e.logger.Printf("[DEBUG] output of the command still open %v after it exited", timeout)
+	}
+}
+
+// pollCommand waits for the command of the task to exit when it isn't a child
+// of this process, in which case its exit status isn't known.
+func (e *LXCExecutor) pollCommand() *cstructs.WaitResult {
+	for {
+		if err := syscall.Kill(e.pid, 0); err == syscall.ESRCH || !e.container.Running() {
 			return cstructs.NewWaitResult(0, 0, nil)
 		}
+		time.Sleep(lxcReattachPollInterval)
+	}
+}
+
+// IPv4Address waits for the container to get an IPv4 address and returns it.
+func (e *LXCExecutor) IPv4Address(timeout time.Duration) (string, error) {
+	if _, err := e.container.WaitIPAddresses(timeout); err != nil {
+		return "", fmt.Errorf("container %s has no IP address: %v", e.container.Name(), err)
 	}
+	ips, err := e.container.IPv4Addresses()
+	if err != nil {
+		return "", err
+	}
+	if len(ips) == 0 {
+		return "", fmt.Errorf("container %s has no IPv4 address", e.container.Name())
+	}
+	return ips[0], nil
+}
+
+// Run attaches the command of the task to the running container with the
+// environment. Its output is written to the stdout and stderr log rotators,
+// which are closed once the command exits.
+func (e *LXCExecutor) Run(env []string, stdout, stderr *logging.FileRotator) error {
+	if !e.container.Wait(lxc.RUNNING, lxcStartTimeout) {
+		return fmt.Errorf("container %s is not running after %v", e.container.Name(), lxcStartTimeout)
+	}
+	args := append([]string{e.config.Command}, e.config.Args...)
+	pid, err := e.attach(args, env, stdout, stderr, func() {
+		stdout.Close()
+		stderr.Close()
+	})
+	if err != nil {
+		return err
+	}
+	e.pid = pid
 	return nil
 }
 
+// RunScript runs the command in the running container with the environment
+// and waits for it to exit. Its output is copied to stdout and stderr.
+func (e *LXCExecutor) RunScript(args []string, env []string, stdout, stderr io.Writer) *cstructs.WaitResult {
+	if e.container.State() != lxc.RUNNING {
+		return cstructs.NewWaitResult(-1, 0, fmt.Errorf("container %s is not running", e.container.Name()))
+	}
+	pid, err := e.attach(args, env, stdout, stderr, func() {})
+	if err != nil {
+		return cstructs.NewWaitResult(-1, 0, err)
+	}
+	e.pid = pid
+	return e.Wait()
+}
+
+// attach runs the command in the container with the environment and returns
+// its pid. Its output is copied to stdout and stderr until the command and
+// its children close it, after which copied is called.
+func (e *LXCExecutor) attach(args []string, env []string, stdout, stderr io.Writer, copied func()) (int, error) {
+	stdin, err := os.Open(os.DevNull)
+	if err != nil {
+		return 0, err
+	}
+	defer stdin.Close()
+	outR, outW, err := os.Pipe()
+	if err != nil {
+		return 0, err
+	}
+	defer outW.Close()
+	errR, errW, err := os.Pipe()
+	if err != nil {
+		outR.Close()
+		return 0, err
+	}
+	defer errW.Close()
+
+	attach := lxc.DefaultAttachOptions
+	attach.Cwd = "/"
+	attach.ClearEnv = true
+	attach.Env = env
+	attach.StdinFd = stdin.Fd()
+	attach.StdoutFd = outW.Fd()
+	attach.StderrFd = errW.Fd()
+	pid, err := e.container.RunCommandNoWait(args, attach)
+	if err != nil {
+		outR.Close()
+		errR.Close()
+		return 0, fmt.Errorf("failed to run %q in container %s: %v", args[0], e.container.Name(), err)
+	}
+
+	var copies sync.WaitGroup
+	copyOutput := func(dst io.Writer, src *os.File) {
+		defer copies.Done()
+		defer src.Close()
+		io.Copy(dst, src)
+	}
+	copies.Add(2)
+	go copyOutput(stdout, outR)
+	go copyOutput(stderr, errR)
+	e.logs.Add(1)
+	go func() {
+		defer e.logs.Done()
+		copies.Wait()
+		copied()
+	}()
+	return pid, nil
+}
+
 func (e *LXCExecutor) Limit(resources *structs.Resources) error {
 	if resources.MemoryMB > 0 {
 		limit := strconv.Itoa(resources.MemoryMB) + "M"
@@ -162,10 +395,60 @@ func (e *LXCExecutor) Limit(resources *structs.Resources) error {
 	return nil
 }
 
+// Mount bind mounts the host volumes into the container's root file system
+func (e *LXCExecutor) Mount(mounts []*cstructs.MountConfig) error {
+	for _, m := range mounts {
+		options := "bind,create=dir"
+		if m.ReadOnly {
+			options += ",ro"
+		}
+		// The mount target of an entry is relative to the container's rootfs
+		entry := fmt.Sprintf("%s %s none %s 0 0", m.HostPath, strings.TrimLeft(m.TaskPath, "/"), options)
+		if err := e.container.SetConfigItem("lxc.mount.entry", entry); err != nil {
+			e.logger.Printf("[ERROR] failed to mount host volume %s. Error: %v", m.HostPath, err)
+			return err
+		}
+	}
+	return nil
+}
+
 func (e *LXCExecutor) Start() error {
 	return e.container.Start()
 }
 
+// Exec runs the command of an exec session attached to the container and
+// returns its exit code.
+func (e *LXCExecutor) Exec(opts *cstructs.ExecOptions) (int, error) {
+	if e.container.State() != lxc.RUNNING {
+		return -1, fmt.Errorf("container %s is not running", e.container.Name())
+	}
+	return runExecSession(opts, func(stdin, stdout, stderr *os.File) (int, error) {
+		attach := lxc.DefaultAttachOptions
+		attach.Cwd = "/"
+		attach.StdinFd = stdin.Fd()
+		attach.StdoutFd = stdout.Fd()
+		attach.StderrFd = stderr.Fd()
+		return e.container.RunCommandStatus(opts.Command, attach)
+	})
+}
+
+// Signal sends the signal to the command of the task, or to the init process
+// of the container if it is the task.
+func (e *LXCExecutor) Signal(s os.Signal) error {
+	if e.container.State() != lxc.RUNNING {
+		return fmt.Errorf("container %s is not running", e.container.Name())
+	}
+	pid := e.pid
+	if pid == 0 {
+		pid = e.container.InitPid()
+	}
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return proc.Signal(s)
+}
+
 func (e *LXCExecutor) Shutdown() error {
 	if e.container.Defined() {
 		if e.container.State() == lxc.RUNNING {
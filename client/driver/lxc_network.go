@@ -0,0 +1,129 @@
+package driver
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/nomad/client/driver/env"
+	"github.com/hashicorp/nomad/nomad/structs"
+)
+
+// iptables runs iptables with the arguments. It is a variable so tests can
+// record the rules instead of changing the firewall of the host.
+var iptables = func(args ...string) error {
+	out, err := exec.Command("iptables", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("iptables %s failed: %v: %s", strings.Join(args, " "), err, out)
+	}
+	return nil
+}
+
+// lxcPortForward forwards a port allocated on the host to a port of the
+// container.
+type lxcPortForward struct {
+	Label         string
+	Proto         string
+	HostIP        string
+	HostPort      int
+	ContainerIP   string
+	ContainerPort int
+}
+
+// lxcPortForwards returns the port forwards of the ports allocated to the
+// task. Ports are forwarded to the same port of the container unless they
+// are mapped by the port map.
+func lxcPortForwards(networks []*structs.NetworkResource, portMap map[string]int, containerIP string) []*lxcPortForward {
+	var forwards []*lxcPortForward
+	for _, network := range networks {
+		ports := append(network.ReservedPorts, network.DynamicPorts...)
+		for _, port := range ports {
+			containerPort := port.Value
+			if mapped, ok := portMap[port.Label]; ok {
+				containerPort = mapped
+			}
+			for _, proto := range []string{"tcp", "udp"} {
+				forwards = append(forwards, &lxcPortForward{
+					Label:         port.Label,
+					Proto:         proto,
+					HostIP:        network.IP,
+					HostPort:      port.Value,
+					ContainerIP:   containerIP,
+					ContainerPort: containerPort,
+				})
+			}
+		}
+	}
+	return forwards
+}
+
+// rules returns the iptables arguments of the NAT rules of the forward for
+// the operation, which appends or deletes them. Traffic from other hosts goes
+// through the PREROUTING chain and traffic from the host itself through the
+// OUTPUT chain.
+func (f *lxcPortForward) rules(op string) [][]string {
+	var rules [][]string
+	for _, chain := range []string{"PREROUTING", "OUTPUT"} {
+		rules = append(rules, []string{
+			"-t", "nat", op, chain,
+			"-p", f.Proto,
+			"-d", f.HostIP,
+			"--dport", strconv.Itoa(f.HostPort),
+			"-j", "DNAT",
+			"--to-destination", fmt.Sprintf("%s:%d", f.ContainerIP, f.ContainerPort),
+		})
+	}
+	return rules
+}
+
+// addPortForwards adds the NAT rules of the forwards. The rules added so far
+// are removed if one fails.
+func addPortForwards(forwards []*lxcPortForward) error {
+	for i, f := range forwards {
+		for j, rule := range f.rules("-A") {
+			if err := iptables(rule...); err != nil {
+				// Remove the rules of the forward added so far and the
+				// previous forwards
+				for _, added := range f.rules("-D")[:j] {
+					iptables(added...)
+				}
+				removePortForwards(forwards[:i])
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// removePortForwards deletes the NAT rules of the forwards.
+func removePortForwards(forwards []*lxcPortForward) error {
+	var mErr multierror.Error
+	for _, f := range forwards {
+		for _, rule := range f.rules("-D") {
+			if err := iptables(rule...); err != nil {
+				mErr.Errors = append(mErr.Errors, err)
+			}
+		}
+	}
+	return mErr.ErrorOrNil()
+}
+
+// lxcPortEnv returns the environment variables of the forwarded ports as seen
+// from the container: NOMAD_ADDR_<label> is the address of the port in the
+// container and NOMAD_HOST_PORT_<label> the port allocated on the host.
+func lxcPortEnv(forwards []*lxcPortForward) []string {
+	var vars []string
+	seen := make(map[string]struct{})
+	for _, f := range forwards {
+		if _, ok := seen[f.Label]; ok {
+			continue
+		}
+		seen[f.Label] = struct{}{}
+		vars = append(vars,
+			fmt.Sprintf("%s%s=%s:%d", env.AddrPrefix, f.Label, f.ContainerIP, f.ContainerPort),
+			fmt.Sprintf("%s%s=%d", env.HostPortPrefix, f.Label, f.HostPort))
+	}
+	return vars
+}
@@ -1,17 +1,265 @@
 package driver
 
 import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
 	"testing"
+	"time"
+
+	cstructs "github.com/hashicorp/nomad/client/driver/structs"
+	"github.com/hashicorp/nomad/nomad/structs"
 )
 
 func TestLXCDriver_Handle(t *testing.T) {
 	t.Parallel()
+	id := &lxcId{
+		Name:      "foo",
+		LXCPath:   "/var/lib/lxc",
+		CloneFrom: "base",
+		Pid:       42,
+		PortForwards: []*lxcPortForward{
+			{Label: "http", Proto: "tcp", HostIP: "10.0.0.1", HostPort: 20000, ContainerIP: "10.0.3.10", ContainerPort: 80},
+		},
+	}
 	h := &lxcHandle{
 		Name: "foo",
+		id:   id,
+	}
+
+	actual := &lxcId{}
+	if err := json.Unmarshal([]byte(h.ID()), actual); err != nil {
+		t.Fatalf("failed to parse handle ID %q: %v", h.ID(), err)
+	}
+	if !reflect.DeepEqual(actual, id) {
+		t.Errorf("Expected: `%#v`, Found: `%#v`", id, actual)
+	}
+}
+
+func TestLXCDriver_PortForwards(t *testing.T) {
+	networks := []*structs.NetworkResource{
+		{
+			IP:            "10.0.0.1",
+			ReservedPorts: []structs.Port{{Label: "admin", Value: 8081}},
+			DynamicPorts:  []structs.Port{{Label: "http", Value: 20000}},
+		},
+	}
+	forwards := lxcPortForwards(networks, map[string]int{"http": 80}, "10.0.3.10")
+	if len(forwards) != 4 {
+		t.Fatalf("got %d forwards; want 4", len(forwards))
+	}
+
+	// Record the rules instead of running iptables
+	var rules []string
+	defer func(orig func(...string) error) { iptables = orig }(iptables)
+	iptables = func(args ...string) error {
+		rules = append(rules, strings.Join(args, " "))
+		return nil
+	}
+	if err := addPortForwards(forwards); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	expected := []string{
+		"-t nat -A PREROUTING -p tcp -d 10.0.0.1 --dport 8081 -j DNAT --to-destination 10.0.3.10:8081",
+		"-t nat -A OUTPUT -p tcp -d 10.0.0.1 --dport 8081 -j DNAT --to-destination 10.0.3.10:8081",
+		"-t nat -A PREROUTING -p udp -d 10.0.0.1 --dport 8081 -j DNAT --to-destination 10.0.3.10:8081",
+		"-t nat -A OUTPUT -p udp -d 10.0.0.1 --dport 8081 -j DNAT --to-destination 10.0.3.10:8081",
+		"-t nat -A PREROUTING -p tcp -d 10.0.0.1 --dport 20000 -j DNAT --to-destination 10.0.3.10:80",
+		"-t nat -A OUTPUT -p tcp -d 10.0.0.1 --dport 20000 -j DNAT --to-destination 10.0.3.10:80",
+		"-t nat -A PREROUTING -p udp -d 10.0.0.1 --dport 20000 -j DNAT --to-destination 10.0.3.10:80",
+		"-t nat -A OUTPUT -p udp -d 10.0.0.1 --dport 20000 -j DNAT --to-destination 10.0.3.10:80",
+	}
+	if !reflect.DeepEqual(rules, expected) {
+		t.Fatalf("got rules %#v; want %#v", rules, expected)
+	}
+
+	// The rules added so far are removed if one fails
+	rules = nil
+	added := make(map[string]struct{})
+	iptables = func(args ...string) error {
+		rule := strings.Join(args, " ")
+		if strings.Contains(rule, "-A OUTPUT -p tcp -d 10.0.0.1 --dport 20000") {
+			return fmt.Errorf("failed")
+		}
+		if strings.Contains(rule, " -A ") {
+			added[strings.Replace(rule, " -A ", " ", 1)] = struct{}{}
+		} else {
+			delete(added, strings.Replace(rule, " -D ", " ", 1))
+		}
+		return nil
+	}
+	if err := addPortForwards(forwards); err == nil {
+		t.Fatalf("expected an error")
+	}
+	if len(added) != 0 {
+		t.Fatalf("rules left behind: %v", added)
+	}
+}
+
+func TestLXCDriver_PortEnv(t *testing.T) {
+	forwards := []*lxcPortForward{
+		{Label: "http", Proto: "tcp", HostIP: "10.0.0.1", HostPort: 20000, ContainerIP: "10.0.3.10", ContainerPort: 80},
+		{Label: "http", Proto: "udp", HostIP: "10.0.0.1", HostPort: 20000, ContainerIP: "10.0.3.10", ContainerPort: 80},
+	}
+	actual := lxcPortEnv(forwards)
+	sort.Strings(actual)
+	expected := []string{
+		"NOMAD_ADDR_http=10.0.3.10:80",
+		"NOMAD_HOST_PORT_http=20000",
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("got %v; want %v", actual, expected)
+	}
+}
+
+// testLXCBaseCache returns a cache persisted to the path whose bases are
+// recorded in the built map instead of being built with lxc.
+func testLXCBaseCache(t *testing.T, path string, size int, built map[string]bool) *lxcBaseCache {
+	c := newLXCBaseCache(path, size, testLogger())
+	c.create = func(b *lxcBase) error {
+		built[b.Name] = true
+		return nil
+	}
+	c.destroy = func(b *lxcBase) error {
+		delete(built, b.Name)
+		return nil
+	}
+	c.defined = func(b *lxcBase) bool {
+		return built[b.Name]
+	}
+	return c
+}
+
+func TestLXCExecutor_Wait_LogsOpen(t *testing.T) {
+	cmd := exec.Command("/bin/sh", "-c", "exit 3")
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The output of the command is kept open, like by a daemon it started
+	e := &LXCExecutor{pid: cmd.Process.Pid, logger: testLogger(), logsTimeout: 100 * time.Millisecond}
+	e.logs.Add(1)
+	defer e.logs.Done()
+
+	resultCh := make(chan *cstructs.WaitResult, 1)
+	go func() {
+		resultCh <- e.Wait()
+	}()
+	select {
+	case res := <-resultCh:
+		if res.ExitCode != 3 {
+			t.Fatalf("got exit code %d; want 3", res.ExitCode)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("exit of the command not reported while its output is open")
+	}
+}
+
+func TestLXCBaseCache_RefCount(t *testing.T) {
+	built := make(map[string]bool)
+	c := testLXCBaseCache(t, "", 0, built)
+	key := lxcBaseKey{Template: "download", Distro: "ubuntu", Release: "14.04", Arch: "amd64"}
+
+	b1, err := c.Acquire(key, "/var/lib/lxc", "task1")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if b1.Name != "nomad-base-download-ubuntu-14.04-amd64" {
+		t.Fatalf("bad base name: %s", b1.Name)
+	}
+	b2, err := c.Acquire(key, "/var/lib/lxc", "task2")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if b1 != b2 || len(built) != 1 {
+		t.Fatalf("base built more than once: %v", built)
+	}
+
+	// The base is kept while a clone references it
+	c.Release("task1")
+	if !built[b1.Name] {
+		t.Fatalf("referenced base destroyed")
+	}
+	c.Release("task2")
+	if built[b1.Name] {
+		t.Fatalf("unreferenced base kept beyond the cache size")
+	}
+	if keys := c.Keys(); len(keys) != 0 {
+		t.Fatalf("bad keys: %v", keys)
+	}
+}
+
+func TestLXCBaseCache_LRU(t *testing.T) {
+	built := make(map[string]bool)
+	c := testLXCBaseCache(t, "", 2, built)
+	keys := []lxcBaseKey{
+		{Template: "download", Distro: "ubuntu", Release: "14.04", Arch: "amd64"},
+		{Template: "download", Distro: "ubuntu", Release: "16.04", Arch: "amd64"},
+		{Template: "download", Distro: "debian", Release: "jessie", Arch: "amd64"},
+	}
+	for i, key := range keys {
+		clone := fmt.Sprintf("task%d", i)
+		if _, err := c.Acquire(key, "/var/lib/lxc", clone); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		c.Release(clone)
+	}
+
+	// The least recently used base is destroyed
+	expected := []lxcBaseKey{keys[2], keys[1]}
+	if actual := c.Keys(); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("got keys %v; want %v", actual, expected)
+	}
+	if len(built) != 2 || built["nomad-base-download-ubuntu-14.04-amd64"] {
+		t.Fatalf("bad built bases: %v", built)
+	}
+}
+
+func TestLXCBaseCache_Persist(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lxc-cache")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, lxcBaseCacheFile)
+
+	built := make(map[string]bool)
+	c := testLXCBaseCache(t, path, 0, built)
+	key := lxcBaseKey{Template: "download", Distro: "ubuntu", Release: "14.04", Arch: "amd64"}
+	if _, err := c.Acquire(key, "/var/lib/lxc", "task1"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// A restarted client keeps the base and the reference of its clone
+	c = testLXCBaseCache(t, path, 0, built)
+	if keys := c.Keys(); !reflect.DeepEqual(keys, []lxcBaseKey{key}) {
+		t.Fatalf("bad keys: %v", keys)
+	}
+	c.Release("task1")
+	if built["nomad-base-download-ubuntu-14.04-amd64"] {
+		t.Fatalf("unreferenced base kept beyond the cache size")
+	}
+}
+
+func TestLXCBaseCache_Attributes(t *testing.T) {
+	node := &structs.Node{Attributes: map[string]string{
+		"driver.lxc":                            "1",
+		"unique.driver.lxc.base.old-ubuntu-x-y": "1",
+	}}
+	lxcBaseAttributes(node, []lxcBaseKey{
+		{Template: "download", Distro: "ubuntu", Release: "14.04", Arch: "amd64"},
+	})
+	expected := map[string]string{
+		"driver.lxc": "1",
+		"unique.driver.lxc.base.download-ubuntu-14.04-amd64": "1",
 	}
-	actual := h.ID()
-	expected := "LXC:foo"
-	if actual != expected {
-		t.Errorf("Expected: `%s`, Found: `%s`", actual, expected)
+	if !reflect.DeepEqual(node.Attributes, expected) {
+		t.Fatalf("got %v; want %v", node.Attributes, expected)
 	}
 }
@@ -0,0 +1,378 @@
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/coreos/go-systemd/util"
+	"github.com/godbus/dbus"
+	"github.com/hashicorp/nomad/client/allocdir"
+	"github.com/hashicorp/nomad/client/config"
+	"github.com/hashicorp/nomad/client/driver/executor"
+	cstructs "github.com/hashicorp/nomad/client/driver/structs"
+	"github.com/hashicorp/nomad/client/fingerprint"
+	"github.com/hashicorp/nomad/helper/signals"
+	"github.com/hashicorp/nomad/nomad/structs"
+	"github.com/mitchellh/mapstructure"
+)
+
+const (
+	// nspawnRootfsDir is the directory in the task directory a tarball image
+	// is extracted to.
+	nspawnRootfsDir = "rootfs"
+
+	// nspawnMaxMachineName is the maximum length of a machine name.
+	nspawnMaxMachineName = 64
+
+	// nspawnHostImagesOption is the client option allowing images outside of
+	// the task directory, which are host paths.
+	nspawnHostImagesOption = "driver.nspawn.host_images.enable"
+)
+
+var (
+	// reNspawnVersion matches the version of systemd-nspawn.
+	reNspawnVersion = regexp.MustCompile(`systemd (\d+)`)
+
+	// nspawnInvalidMachineChars matches the characters not allowed in
+	// machine names.
+	nspawnInvalidMachineChars = regexp.MustCompile(`[^a-zA-Z0-9-]`)
+)
+
+// NspawnDriver runs tasks in containers using systemd-nspawn. The containers
+// run in transient units registered with machined like the systemd driver.
+type NspawnDriver struct {
+	DriverContext
+	fingerprint.StaticFingerprinter
+}
+
+type NspawnDriverConfig struct {
+	Image      string           `mapstructure:"image"`
+	Boot       bool             `mapstructure:"boot"`
+	Command    string           `mapstructure:"command"`
+	Args       []string         `mapstructure:"args"`
+	PortMapRaw []map[string]int `mapstructure:"port_map"`
+	PortMap    map[string]int   `mapstructure:"-"`
+}
+
+type nspawnHandle struct {
+	logger   *log.Logger
+	id       *nspawnId
+	waitCh   chan *cstructs.WaitResult
+	doneCh   chan struct{}
+	executor *executor.SystemdExecutor
+	journal  *systemdJournal
+}
+
+// nspawnId is the serialized handle of a task.
+type nspawnId struct {
+	Version   string
+	Machine   string
+	Unit      string
+	StartedAt time.Time
+	LogConfig *structs.LogConfig
+}
+
+// NewNspawnDriver is used to create a new nspawn driver
+func NewNspawnDriver(ctx *DriverContext) Driver {
+	return &NspawnDriver{DriverContext: *ctx}
+}
+
+func (d *NspawnDriver) Fingerprint(cfg *config.Config, node *structs.Node) (bool, error) {
+	if !util.IsRunningSystemd() {
+		return false, nil
+	}
+	if syscall.Geteuid() != 0 {
+		d.logger.Printf("[DEBUG] driver.nspawn: must run as root user, disabling")
+		return false, nil
+	}
+	out, err := exec.Command("systemd-nspawn", "--version").Output()
+	if err != nil {
+		return false, nil
+	}
+	match := reNspawnVersion.FindStringSubmatch(string(out))
+	if len(match) != 2 {
+		return false, fmt.Errorf("Unable to parse systemd-nspawn version string: %s", out)
+	}
+	node.Attributes["driver.nspawn"] = "1"
+	node.Attributes["driver.nspawn.version"] = match[1]
+	d.logger.Printf("[DEBUG] driver.nspawn: version %s", match[1])
+	return true, nil
+}
+
+// nspawnMachineName returns the name of the machine of the task of the
+// allocation, which must be a valid host name.
+func nspawnMachineName(allocID, task string) string {
+	name := fmt.Sprintf("nomad-%s-%s", allocID, nspawnInvalidMachineChars.ReplaceAllString(task, "-"))
+	if len(name) > nspawnMaxMachineName {
+		name = name[:nspawnMaxMachineName]
+	}
+	return strings.TrimRight(name, "-")
+}
+
+func (d *NspawnDriver) Start(ctx *ExecContext, task *structs.Task) (DriverHandle, error) {
+	var driverConfig NspawnDriverConfig
+	if err := mapstructure.WeakDecode(task.Config, &driverConfig); err != nil {
+		return nil, err
+	}
+	driverConfig.PortMap = mapMergeStrInt(driverConfig.PortMapRaw...)
+	if driverConfig.Image == "" {
+		return nil, fmt.Errorf("missing image for nspawn driver")
+	}
+	if driverConfig.Boot == (driverConfig.Command != "") {
+		return nil, fmt.Errorf("nspawn driver needs exactly one of boot and command")
+	}
+	taskDir, ok := ctx.AllocDir.TaskDirs[task.Name]
+	if !ok {
+		return nil, fmt.Errorf("Could not find task directory for task: %v", task.Name)
+	}
+	hostImages := d.config.ReadBoolDefault(nspawnHostImagesOption, false)
+	rootfs, err := nspawnRootfs(driverConfig.Image, taskDir, hostImages)
+	if err != nil {
+		return nil, err
+	}
+
+	// The alloc dir and the local dir of the task are mounted like in the
+	// other drivers
+	d.taskEnv.SetAllocDir(filepath.Join("/", allocdir.SharedAllocName))
+	d.taskEnv.SetTaskLocalDir(filepath.Join("/", allocdir.TaskLocal))
+	d.taskEnv.SetPortMap(driverConfig.PortMap)
+	d.taskEnv.Build()
+
+	id := &nspawnId{
+		Version:   d.config.Version,
+		Machine:   nspawnMachineName(ctx.AllocID, task.Name),
+		Unit:      systemdUnitName(ctx.AllocID, task.Name),
+		StartedAt: time.Now(),
+		LogConfig: task.LogConfig,
+	}
+	mounts := append([]*cstructs.MountConfig{
+		{HostPath: ctx.AllocDir.SharedDir, TaskPath: allocdir.SharedAllocName},
+		{HostPath: filepath.Join(taskDir, allocdir.TaskLocal), TaskPath: allocdir.TaskLocal},
+	}, d.mounts...)
+	var networks []*structs.NetworkResource
+	if task.Resources != nil {
+		networks = task.Resources.Networks
+	}
+	args := nspawnArgs(id.Machine, rootfs, &driverConfig, mounts, networks, d.taskEnv.EnvList())
+
+	exec := executor.NewSystemdExecutor(id.Unit, args, d.logger)
+	killSignal := syscall.SIGTERM
+	if task.KillSignal != "" {
+		sig, err := signals.Parse(task.KillSignal)
+		if err != nil {
+			return nil, err
+		}
+		if s, ok := sig.(syscall.Signal); ok {
+			killSignal = s
+		}
+	}
+	exec.SetKill(killSignal, d.KillTimeout(task))
+	cpuFrequency, _ := strconv.ParseFloat(d.node.Attributes["cpu.frequency"], 64)
+	if err := exec.Limit(task.Resources, cpuFrequency); err != nil {
+		return nil, err
+	}
+
+	if err := removeSystemdCursor(filepath.Join(taskDir, systemdCursorFile)); err != nil {
+		return nil, err
+	}
+	journal, err := followUnitJournal(ctx, task.Name, id.Unit, id.StartedAt, id.LogConfig, d.logger)
+	if err != nil {
+		return nil, err
+	}
+	if err := exec.Start(); err != nil {
+		journal.Stop()
+		return nil, fmt.Errorf("failed to start machine %s: %v", id.Machine, err)
+	}
+	d.logger.Printf("[DEBUG] driver.nspawn: started machine %s in unit %s", id.Machine, id.Unit)
+
+	h := &nspawnHandle{
+		logger:   d.logger,
+		id:       id,
+		doneCh:   make(chan struct{}),
+		waitCh:   make(chan *cstructs.WaitResult, 1),
+		executor: exec,
+		journal:  journal,
+	}
+	go h.run()
+	return h, nil
+}
+
+// nspawnRootfs returns the root file system directory of the image. An image
+// which is a tarball, such as an artifact, is extracted into the task
+// directory. Images are relative to the task directory and may not escape it,
+// absolute images on the host are only allowed if hostImages is set.
+func nspawnRootfs(image, taskDir string, hostImages bool) (string, error) {
+	if filepath.IsAbs(image) {
+		if !hostImages {
+			return "", fmt.Errorf("image %s must be relative to the task directory, host images aren't enabled", image)
+		}
+	} else {
+		if clean := filepath.Clean(image); clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+			return "", fmt.Errorf("image %s escapes the task directory", image)
+		}
+		resolved, err := allocdir.SecurePath(taskDir, image)
+		if err != nil {
+			return "", fmt.Errorf("invalid image for nspawn driver: %v", err)
+		}
+		image = resolved
+	}
+	fi, err := os.Stat(image)
+	if err != nil {
+		return "", fmt.Errorf("invalid image for nspawn driver: %v", err)
+	}
+	if fi.IsDir() {
+		return image, nil
+	}
+
+	rootfs := filepath.Join(taskDir, nspawnRootfsDir)
+	if err := os.RemoveAll(rootfs); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(rootfs, 0755); err != nil {
+		return "", err
+	}
+	if out, err := exec.Command("tar", "-xf", image, "-C", rootfs).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("failed to extract image %s: %v: %s", image, err, out)
+	}
+	return rootfs, nil
+}
+
+// nspawnArgs returns the systemd-nspawn command running the machine. The
+// machine is registered with machined in the unit running it. It has a
+// private network and the ports of the task are forwarded to it.
+func nspawnArgs(machine, rootfs string, driverConfig *NspawnDriverConfig, mounts []*cstructs.MountConfig,
+	networks []*structs.NetworkResource, env []string) []string {
+	args := []string{
+		"systemd-nspawn",
+		"--quiet",
+		"--keep-unit",
+		"--register=yes",
+		"--machine=" + machine,
+		"--directory=" + rootfs,
+	}
+	for _, m := range mounts {
+		flag := "--bind="
+		if m.ReadOnly {
+			flag = "--bind-ro="
+		}
+		args = append(args, flag+m.HostPath+":"+filepath.Join("/", m.TaskPath))
+	}
+
+	args = append(args, "--network-veth")
+	for _, network := range networks {
+		for _, port := range append(network.ReservedPorts, network.DynamicPorts...) {
+			containerPort := port.Value
+			if mapped, ok := driverConfig.PortMap[port.Label]; ok {
+				containerPort = mapped
+			}
+			for _, proto := range []string{"tcp", "udp"} {
+				args = append(args, fmt.Sprintf("--port=%s:%d:%d", proto, port.Value, containerPort))
+			}
+		}
+	}
+
+	for _, e := range env {
+		args = append(args, "--setenv="+e)
+	}
+	if driverConfig.Boot {
+		return append(args, "--boot")
+	}
+	args = append(args, "--", driverConfig.Command)
+	return append(args, driverConfig.Args...)
+}
+
+func (h *nspawnHandle) run() {
+	waitResult := h.executor.Wait()
+	if err := h.journal.Stop(); err != nil {
+		h.logger.Printf("[ERR] driver.nspawn: failed to copy the journal of machine %s: %v", h.id.Machine, err)
+	}
+	close(h.doneCh)
+	h.waitCh <- waitResult
+	close(h.waitCh)
+}
+
+func (d *NspawnDriver) Open(ctx *ExecContext, handleID string) (DriverHandle, error) {
+	id := &nspawnId{}
+	if err := json.Unmarshal([]byte(handleID), id); err != nil {
+		return nil, fmt.Errorf("Failed to parse handle '%s': %v", handleID, err)
+	}
+
+	// The machine is looked up by name as it may have been registered in
+	// another unit. It isn't found once it stopped, in which case the unit
+	// of the handle is waited on.
+	if unit, err := machineUnit(id.Machine); err == nil {
+		id.Unit = unit
+	} else {
+		d.logger.Printf("[DEBUG] driver.nspawn: machine %s not found: %v", id.Machine, err)
+	}
+	journal, err := followUnitJournal(ctx, d.DriverContext.taskName, id.Unit, id.StartedAt, id.LogConfig, d.logger)
+	if err != nil {
+		return nil, err
+	}
+	h := &nspawnHandle{
+		logger:   d.logger,
+		id:       id,
+		doneCh:   make(chan struct{}),
+		waitCh:   make(chan *cstructs.WaitResult, 1),
+		executor: executor.NewSystemdExecutor(id.Unit, nil, d.logger),
+		journal:  journal,
+	}
+	go h.run()
+	return h, nil
+}
+
+// machineUnit returns the unit of the machine registered with machined.
+func machineUnit(machine string) (string, error) {
+	conn, err := dbus.SystemBus()
+	if err != nil {
+		return "", err
+	}
+	manager := conn.Object("org.freedesktop.machine1", dbus.ObjectPath("/org/freedesktop/machine1"))
+	var path dbus.ObjectPath
+	if err := manager.Call("org.freedesktop.machine1.Manager.GetMachine", 0, machine).Store(&path); err != nil {
+		return "", err
+	}
+	unit, err := conn.Object("org.freedesktop.machine1", path).GetProperty("org.freedesktop.machine1.Machine.Unit")
+	if err != nil {
+		return "", err
+	}
+	name, ok := unit.Value().(string)
+	if !ok {
+		return "", fmt.Errorf("invalid unit of machine %s: %v", machine, unit)
+	}
+	return name, nil
+}
+
+func (h *nspawnHandle) ID() string {
+	data, err := json.Marshal(h.id)
+	if err != nil {
+		h.logger.Printf("[ERR] driver.nspawn: failed to marshal ID to JSON: %s", err)
+	}
+	return string(data)
+}
+
+func (h *nspawnHandle) WaitCh() chan *cstructs.WaitResult {
+	return h.waitCh
+}
+
+func (h *nspawnHandle) Signal(s os.Signal) error {
+	return h.executor.Signal(s)
+}
+
+func (h *nspawnHandle) Kill() error {
+	return h.executor.Shutdown()
+}
+
+func (h *nspawnHandle) Update(task *structs.Task) error {
+	h.logger.Printf("[WARN] Update is not supported by nspawn driver")
+	return nil
+}
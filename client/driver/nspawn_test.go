@@ -0,0 +1,173 @@
+package driver
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	cstructs "github.com/hashicorp/nomad/client/driver/structs"
+	"github.com/hashicorp/nomad/nomad/structs"
+)
+
+func TestNspawnDriver_Handle(t *testing.T) {
+	t.Parallel()
+	id := &nspawnId{
+		Version:   "0.3.0",
+		Machine:   nspawnMachineName("1234", "web"),
+		Unit:      systemdUnitName("1234", "web"),
+		StartedAt: time.Unix(1456000000, 0).UTC(),
+		LogConfig: structs.DefaultLogConfig(),
+	}
+	h := &nspawnHandle{id: id, logger: testLogger()}
+
+	actual := &nspawnId{}
+	if err := json.Unmarshal([]byte(h.ID()), actual); err != nil {
+		t.Fatalf("failed to parse handle ID %q: %v", h.ID(), err)
+	}
+	if !reflect.DeepEqual(actual, id) {
+		t.Errorf("Expected: `%#v`, Found: `%#v`", id, actual)
+	}
+}
+
+func TestNspawnDriver_MachineName(t *testing.T) {
+	t.Parallel()
+	if name := nspawnMachineName("1234", "web_server"); name != "nomad-1234-web-server" {
+		t.Fatalf("bad machine name: %s", name)
+	}
+	name := nspawnMachineName("8d2c3a4e-7f1b-4d0c-9e5a-2b6f1c3d4e5f", strings.Repeat("a", 40))
+	if len(name) != nspawnMaxMachineName {
+		t.Fatalf("machine name %q longer than %d", name, nspawnMaxMachineName)
+	}
+}
+
+func TestNspawnDriver_Args(t *testing.T) {
+	t.Parallel()
+	config := &NspawnDriverConfig{
+		Command: "/bin/echo",
+		Args:    []string{"hello"},
+		PortMap: map[string]int{"http": 80},
+	}
+	mounts := []*cstructs.MountConfig{
+		{HostPath: "/var/nomad/alloc/1234/alloc", TaskPath: "alloc"},
+		{HostPath: "/opt/data", TaskPath: "/data", ReadOnly: true},
+	}
+	networks := []*structs.NetworkResource{
+		{
+			IP:           "10.0.0.1",
+			DynamicPorts: []structs.Port{{Label: "http", Value: 20000}},
+		},
+	}
+	actual := nspawnArgs("nomad-1234-web", "/var/nomad/alloc/1234/web/rootfs", config, mounts, networks,
+		[]string{"NOMAD_ALLOC_DIR=/alloc"})
+	expected := []string{
+		"systemd-nspawn",
+		"--quiet",
+		"--keep-unit",
+		"--register=yes",
+		"--machine=nomad-1234-web",
+		"--directory=/var/nomad/alloc/1234/web/rootfs",
+		"--bind=/var/nomad/alloc/1234/alloc:/alloc",
+		"--bind-ro=/opt/data:/data",
+		"--network-veth",
+		"--port=tcp:20000:80",
+		"--port=udp:20000:80",
+		"--setenv=NOMAD_ALLOC_DIR=/alloc",
+		"--",
+		"/bin/echo",
+		"hello",
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("got %#v; want %#v", actual, expected)
+	}
+
+	config = &NspawnDriverConfig{Boot: true}
+	actual = nspawnArgs("nomad-1234-web", "/rootfs", config, nil, nil, nil)
+	if actual[len(actual)-1] != "--boot" {
+		t.Fatalf("booted machine args don't end with --boot: %v", actual)
+	}
+}
+
+func TestNspawnDriver_Rootfs(t *testing.T) {
+	taskDir, err := ioutil.TempDir("", "nspawn")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer os.RemoveAll(taskDir)
+
+	// A directory image is used as is
+	image := filepath.Join(taskDir, "local", "image")
+	if err := os.MkdirAll(filepath.Join(image, "etc"), 0755); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(image, "etc", "hostname"), []byte("web"), 0644); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	rootfs, err := nspawnRootfs("local/image", taskDir, false)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if rootfs != image {
+		t.Fatalf("got rootfs %s; want %s", rootfs, image)
+	}
+
+	// A tarball image is extracted into the task directory
+	tarball := filepath.Join(taskDir, "local", "image.tar.gz")
+	if out, err := exec.Command("tar", "-czf", tarball, "-C", image, ".").CombinedOutput(); err != nil {
+		t.Skipf("tar unavailable: %v: %s", err, out)
+	}
+	rootfs, err = nspawnRootfs("local/image.tar.gz", taskDir, false)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if rootfs != filepath.Join(taskDir, nspawnRootfsDir) {
+		t.Fatalf("bad rootfs: %s", rootfs)
+	}
+	if data, err := ioutil.ReadFile(filepath.Join(rootfs, "etc", "hostname")); err != nil || string(data) != "web" {
+		t.Fatalf("image not extracted: %q, %v", data, err)
+	}
+
+	if _, err := nspawnRootfs("local/missing", taskDir, false); err == nil {
+		t.Fatalf("expected an error for a missing image")
+	}
+}
+
+func TestNspawnDriver_Rootfs_Escape(t *testing.T) {
+	taskDir, err := ioutil.TempDir("", "nspawn")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer os.RemoveAll(taskDir)
+	if err := os.MkdirAll(filepath.Join(taskDir, "local"), 0755); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Host images must be enabled on the client
+	if _, err := nspawnRootfs("/", taskDir, false); err == nil || !strings.Contains(err.Error(), "host images") {
+		t.Fatalf("expected host image to be rejected, got: %v", err)
+	}
+	if rootfs, err := nspawnRootfs("/", taskDir, true); err != nil || rootfs != "/" {
+		t.Fatalf("expected host image to be allowed, got: %s, %v", rootfs, err)
+	}
+
+	if _, err := nspawnRootfs("../..", taskDir, false); err == nil || !strings.Contains(err.Error(), "escapes") {
+		t.Fatalf("expected relative image to be rejected, got: %v", err)
+	}
+
+	// Symlinks are resolved within the task directory
+	if err := os.Symlink("/", filepath.Join(taskDir, "local", "host")); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	rootfs, err := nspawnRootfs("local/host", taskDir, false)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if rootfs != taskDir {
+		t.Fatalf("got rootfs %s; want %s", rootfs, taskDir)
+	}
+}
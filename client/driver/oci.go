@@ -0,0 +1,195 @@
+package driver
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/hashicorp/go-plugin"
+	"github.com/hashicorp/nomad/client/allocdir"
+	"github.com/hashicorp/nomad/client/config"
+	"github.com/hashicorp/nomad/client/driver/executor"
+	cstructs "github.com/hashicorp/nomad/client/driver/structs"
+	"github.com/hashicorp/nomad/helper/discover"
+	"github.com/hashicorp/nomad/nomad/structs"
+	"github.com/mitchellh/mapstructure"
+)
+
+// OCIDriver runs tasks in containers unpacked from OCI images, without a
+// container daemon. The containers are run by the executor plugin using
+// libcontainer configurations.
+type OCIDriver struct {
+	DriverContext
+}
+
+type OCIDriverConfig struct {
+	Image      string              `mapstructure:"image"`    // Path of the image, relative to the task dir
+	Command    string              `mapstructure:"command"`  // The command to run, instead of the entrypoint of the image
+	Args       []string            `mapstructure:"args"`     // The arguments of the command
+	User       string              `mapstructure:"user"`     // The user of the command, instead of the one of the image
+	WorkDir    string              `mapstructure:"work_dir"` // The working directory of the command in the container
+	RlimitsRaw []map[string]string `mapstructure:"rlimits"`  //
+	Rlimits    map[string]string   `mapstructure:"-"`        // Resource limits, as "soft:hard" or a single limit for both
+}
+
+// NewOCIDriver is used to create a new oci driver
+func NewOCIDriver(ctx *DriverContext) Driver {
+	return &OCIDriver{DriverContext: *ctx}
+}
+
+func (d *OCIDriver) Fingerprint(cfg *config.Config, node *structs.Node) (bool, error) {
+	// Only enable if cgroups are available and we are root on linux
+	if runtime.GOOS != "linux" {
+		return false, nil
+	}
+	if _, ok := node.Attributes["unique.cgroup.mountpoint"]; !ok {
+		d.logger.Printf("[DEBUG] driver.oci: cgroups unavailable, disabling")
+		return false, nil
+	} else if syscall.Geteuid() != 0 {
+		d.logger.Printf("[DEBUG] driver.oci: must run as root user, disabling")
+		return false, nil
+	}
+
+	node.Attributes["driver.oci"] = "1"
+	return true, nil
+}
+
+func (d *OCIDriver) Periodic() (bool, time.Duration) {
+	return true, 15 * time.Second
+}
+
+func (d *OCIDriver) Start(ctx *ExecContext, task *structs.Task) (DriverHandle, error) {
+	var driverConfig OCIDriverConfig
+	if err := mapstructure.WeakDecode(task.Config, &driverConfig); err != nil {
+		return nil, err
+	}
+	driverConfig.Rlimits = mapMergeStrStr(driverConfig.RlimitsRaw...)
+	if driverConfig.Image == "" {
+		return nil, fmt.Errorf("missing image for oci driver")
+	}
+	rlimits, err := ociRlimits(driverConfig.Rlimits)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the task directory for storing the executor logs and the rootfs.
+	taskDir, ok := ctx.AllocDir.TaskDirs[d.DriverContext.taskName]
+	if !ok {
+		return nil, fmt.Errorf("Could not find task directory for task: %v", d.DriverContext.taskName)
+	}
+
+	rootfs, image, err := ociUnpackImage(driverConfig.Image, taskDir)
+	if err != nil {
+		return nil, err
+	}
+	command, args := ociCommand(&driverConfig, image)
+	if command == "" {
+		return nil, fmt.Errorf("no command to run in image %s", driverConfig.Image)
+	}
+	user := driverConfig.User
+	if user == "" {
+		user = image.User
+	}
+	workDir := driverConfig.WorkDir
+	if workDir == "" {
+		workDir = image.WorkingDir
+	}
+
+	// The environment of the image is overridden by the one of the task and
+	// the directories are the ones mounted in the container.
+	d.taskEnv.SetEnvvars(mapMergeStrStr(ociEnvMap(image.Env), d.taskEnv.Env)).
+		SetAllocDir(filepath.Join("/", allocdir.SharedAllocName)).
+		SetTaskLocalDir(filepath.Join("/", allocdir.TaskLocal))
+
+	container, err := ociContainerConfig(rootfs, ctx.AllocDir.SharedDir, taskDir, d.mounts, rlimits)
+	if err != nil {
+		return nil, err
+	}
+
+	bin, err := discover.NomadExecutable()
+	if err != nil {
+		return nil, fmt.Errorf("unable to find the nomad binary: %v", err)
+	}
+	pluginLogFile := filepath.Join(taskDir, fmt.Sprintf("%s-executor.out", task.Name))
+	pluginConfig := &plugin.ClientConfig{
+		Cmd: exec.Command(bin, "executor", pluginLogFile),
+	}
+
+	exec, pluginClient, err := createExecutor(pluginConfig, d.config.LogOutput, d.config)
+	if err != nil {
+		return nil, err
+	}
+	executorCtx := &executor.ExecutorContext{
+		TaskEnv:        d.taskEnv,
+		AllocDir:       ctx.AllocDir,
+		TaskName:       task.Name,
+		TaskResources:  task.Resources,
+		LogConfig:      task.LogConfig,
+		ResourceLimits: true,
+		Container:      container,
+		User:           user,
+	}
+	ps, err := exec.LaunchCmd(&executor.ExecCommand{Cmd: command, Args: args, Dir: workDir}, executorCtx)
+	if err != nil {
+		pluginClient.Kill()
+		return nil, fmt.Errorf("error starting container via the plugin: %v", err)
+	}
+	d.logger.Printf("[DEBUG] driver.oci: started container via plugin with pid: %v", ps.Pid)
+
+	// The container is supervised by the executor plugin the same way as the
+	// processes of the exec driver
+	h := &execHandle{
+		pluginClient:    pluginClient,
+		userPid:         ps.Pid,
+		executor:        exec,
+		allocDir:        ctx.AllocDir,
+		isolationConfig: ps.IsolationConfig,
+		killTimeout:     d.DriverContext.KillTimeout(task),
+		logger:          d.logger,
+		version:         d.config.Version,
+		doneCh:          make(chan struct{}),
+		waitCh:          make(chan *cstructs.WaitResult, 1),
+	}
+	go h.run()
+	return h, nil
+}
+
+func (d *OCIDriver) Open(ctx *ExecContext, handleID string) (DriverHandle, error) {
+	// Containers are reattached through their executor plugin like the
+	// processes of the exec driver
+	return NewExecDriver(&d.DriverContext).Open(ctx, handleID)
+}
+
+// ociCommand returns the command of the task and its arguments. The command
+// defaults to the entrypoint of the image, and the arguments to its command.
+func ociCommand(config *OCIDriverConfig, image *ociImageConfig) (string, []string) {
+	if config.Command != "" {
+		return config.Command, config.Args
+	}
+	argv := append([]string{}, image.Entrypoint...)
+	if len(config.Args) != 0 {
+		argv = append(argv, config.Args...)
+	} else {
+		argv = append(argv, image.Cmd...)
+	}
+	if len(argv) == 0 {
+		return "", nil
+	}
+	return argv[0], argv[1:]
+}
+
+// ociEnvMap returns the environment of the image as a map.
+func ociEnvMap(env []string) map[string]string {
+	m := make(map[string]string, len(env))
+	for _, kv := range env {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) == 2 {
+			m[parts[0]] = parts[1]
+		}
+	}
+	return m
+}
@@ -0,0 +1,19 @@
+// +build !linux
+
+package driver
+
+import (
+	"fmt"
+
+	cstructs "github.com/hashicorp/nomad/client/driver/structs"
+	"github.com/opencontainers/runc/libcontainer/configs"
+)
+
+func ociContainerConfig(rootfs, sharedDir, taskDir string, mounts []*cstructs.MountConfig,
+	rlimits []configs.Rlimit) (*configs.Config, error) {
+	return nil, fmt.Errorf("oci driver is only supported on linux")
+}
+
+func ociRlimits(raw map[string]string) ([]configs.Rlimit, error) {
+	return nil, fmt.Errorf("oci driver is only supported on linux")
+}
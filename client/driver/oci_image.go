@@ -0,0 +1,442 @@
+package driver
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/hashicorp/nomad/client/allocdir"
+)
+
+const (
+	// ociRootfsDir is the directory of the task dir images are unpacked to.
+	ociRootfsDir = "rootfs"
+
+	// ociImageDir is the directory of the task dir image tarballs are
+	// extracted to before their layers are unpacked.
+	ociImageDir = "oci-image"
+
+	// ociLayoutFile marks the root of an OCI image layout.
+	ociLayoutFile = "oci-layout"
+
+	// ociArchiveManifest is the manifest of an image archive as saved by
+	// docker save.
+	ociArchiveManifest = "manifest.json"
+
+	// Whiteout files of a layer delete a file of the layers below, or all
+	// the files of a directory for the opaque whiteout.
+	ociWhiteoutPrefix = ".wh."
+	ociWhiteoutOpaque = ".wh..wh..opq"
+
+	ociMediaTypeIndex = "application/vnd.oci.image.index.v1+json"
+)
+
+// ociImageConfig is the runtime configuration of an image, used by default
+// for the tasks running it.
+type ociImageConfig struct {
+	User       string
+	Env        []string
+	Entrypoint []string
+	Cmd        []string
+	WorkingDir string
+}
+
+type ociDescriptor struct {
+	MediaType string       `json:"mediaType"`
+	Digest    string       `json:"digest"`
+	Platform  *ociPlatform `json:"platform,omitempty"`
+}
+
+type ociPlatform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+}
+
+type ociIndex struct {
+	Manifests []ociDescriptor `json:"manifests"`
+}
+
+type ociManifest struct {
+	Config ociDescriptor   `json:"config"`
+	Layers []ociDescriptor `json:"layers"`
+}
+
+type ociImage struct {
+	Config ociImageConfig `json:"config"`
+}
+
+type ociArchive struct {
+	Config string
+	Layers []string
+}
+
+// ociUnpackImage unpacks the image, relative to the task dir, into a rootfs
+// and returns the rootfs and the configuration of the image. The image is
+// either an OCI image layout, an image archive as saved by docker save or a
+// rootfs; as a directory or a tarball. A rootfs directory is used as is.
+func ociUnpackImage(image, taskDir string) (string, *ociImageConfig, error) {
+	path, err := ociImagePath(image, taskDir)
+	if err != nil {
+		return "", nil, err
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return "", nil, fmt.Errorf("image %s not found: %v", image, err)
+	}
+
+	layout := path
+	if !fi.IsDir() {
+		isLayout, err := ociIsImageTarball(path)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to read image %s: %v", image, err)
+		}
+		rootfs := filepath.Join(taskDir, ociRootfsDir)
+		if !isLayout {
+			if err := ociExtractLayer(path, rootfs); err != nil {
+				return "", nil, fmt.Errorf("failed to extract image %s: %v", image, err)
+			}
+			return rootfs, &ociImageConfig{}, nil
+		}
+		layout = filepath.Join(taskDir, ociImageDir)
+		defer os.RemoveAll(layout)
+		if err := ociExtractLayer(path, layout); err != nil {
+			return "", nil, fmt.Errorf("failed to extract image %s: %v", image, err)
+		}
+	} else if !ociIsImageDir(path) {
+		return path, &ociImageConfig{}, nil
+	}
+
+	layers, config, err := ociReadLayout(layout)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read image %s: %v", image, err)
+	}
+	rootfs := filepath.Join(taskDir, ociRootfsDir)
+	for _, layer := range layers {
+		if err := ociExtractLayer(layer, rootfs); err != nil {
+			return "", nil, fmt.Errorf("failed to unpack layer %s of image %s: %v",
+				filepath.Base(layer), image, err)
+		}
+	}
+	return rootfs, config, nil
+}
+
+// ociImagePath returns the host path of the image, which must be within the
+// task dir. Symlinks are resolved within the task dir so that the image, and
+// a rootfs directory mounted over, are never outside of it.
+func ociImagePath(image, taskDir string) (string, error) {
+	rel := image
+	if filepath.IsAbs(image) {
+		var err error
+		if rel, err = filepath.Rel(taskDir, image); err != nil {
+			return "", fmt.Errorf("image %s isn't in the task directory: %v", image, err)
+		}
+	}
+	if clean := filepath.Clean(rel); clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("image %s isn't in the task directory", image)
+	}
+	path, err := allocdir.SecurePath(taskDir, rel)
+	if err != nil {
+		return "", fmt.Errorf("invalid image %s: %v", image, err)
+	}
+	return path, nil
+}
+
+// ociIsImageDir returns whether the directory is an image layout or archive
+// rather than a rootfs.
+func ociIsImageDir(dir string) bool {
+	for _, name := range []string{ociLayoutFile, ociArchiveManifest} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// ociIsImageTarball returns whether the tarball contains an image layout or
+// archive rather than a rootfs.
+func ociIsImageTarball(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	r, err := ociDecompress(f)
+	if err != nil {
+		return false, err
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		switch filepath.Clean("/" + hdr.Name) {
+		case "/" + ociLayoutFile, "/" + ociArchiveManifest:
+			return true, nil
+		}
+	}
+}
+
+// ociReadLayout returns the paths of the layers and the configuration of the
+// image of the layout or archive directory.
+func ociReadLayout(dir string) ([]string, *ociImageConfig, error) {
+	var image ociImage
+	var layers []string
+	if _, err := os.Stat(filepath.Join(dir, ociArchiveManifest)); err == nil {
+		var archive []ociArchive
+		if err := ociReadJSON(filepath.Join(dir, ociArchiveManifest), &archive); err != nil {
+			return nil, nil, err
+		}
+		if len(archive) == 0 {
+			return nil, nil, fmt.Errorf("no image in %s", ociArchiveManifest)
+		}
+		if err := ociReadJSON(ociLayoutPath(dir, archive[0].Config), &image); err != nil {
+			return nil, nil, err
+		}
+		for _, layer := range archive[0].Layers {
+			layers = append(layers, ociLayoutPath(dir, layer))
+		}
+		return layers, &image.Config, nil
+	}
+
+	manifest, err := ociFindManifest(dir, filepath.Join(dir, "index.json"))
+	if err != nil {
+		return nil, nil, err
+	}
+	config, err := ociBlobPath(dir, manifest.Config.Digest)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := ociReadJSON(config, &image); err != nil {
+		return nil, nil, err
+	}
+	for _, layer := range manifest.Layers {
+		path, err := ociBlobPath(dir, layer.Digest)
+		if err != nil {
+			return nil, nil, err
+		}
+		layers = append(layers, path)
+	}
+	return layers, &image.Config, nil
+}
+
+// ociFindManifest returns the manifest of the index for the platform of the
+// client, following nested indexes.
+func ociFindManifest(dir, indexPath string) (*ociManifest, error) {
+	var index ociIndex
+	if err := ociReadJSON(indexPath, &index); err != nil {
+		return nil, err
+	}
+	if len(index.Manifests) == 0 {
+		return nil, fmt.Errorf("no manifest in the image index")
+	}
+
+	desc := index.Manifests[0]
+	for _, m := range index.Manifests {
+		if m.Platform != nil && m.Platform.OS == runtime.GOOS && m.Platform.Architecture == runtime.GOARCH {
+			desc = m
+			break
+		}
+	}
+	path, err := ociBlobPath(dir, desc.Digest)
+	if err != nil {
+		return nil, err
+	}
+	if desc.MediaType == ociMediaTypeIndex {
+		return ociFindManifest(dir, path)
+	}
+	var manifest ociManifest
+	if err := ociReadJSON(path, &manifest); err != nil {
+		return nil, err
+	}
+	return &manifest, nil
+}
+
+// ociBlobPath returns the path of the blob with the digest in the layout.
+func ociBlobPath(dir, digest string) (string, error) {
+	parts := strings.SplitN(digest, ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" || strings.ContainsAny(digest, "/\\") {
+		return "", fmt.Errorf("invalid digest %q", digest)
+	}
+	return filepath.Join(dir, "blobs", parts[0], parts[1]), nil
+}
+
+// ociLayoutPath returns the path of the file of an image archive, which can't
+// be outside of the archive.
+func ociLayoutPath(dir, path string) string {
+	return filepath.Join(dir, filepath.Clean("/"+path))
+}
+
+func ociReadJSON(path string, out interface{}) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to decode %s: %v", filepath.Base(path), err)
+	}
+	return nil
+}
+
+// ociDecompress returns a reader of the content of the gzip compressed or
+// uncompressed stream.
+func ociDecompress(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(2)
+	if err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		return gzip.NewReader(br)
+	}
+	return br, nil
+}
+
+// ociExtractLayer extracts the layer tarball over the dest directory,
+// applying its whiteouts. Entries are never extracted outside of dest, even
+// through the symlinks of the layers below.
+func ociExtractLayer(path, dest string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r, err := ociDecompress(f)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return err
+	}
+
+	root := os.Geteuid() == 0
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		name := filepath.Clean("/" + hdr.Name)
+		if name == "/" {
+			continue
+		}
+		parent, err := allocdir.SecurePath(dest, filepath.Dir(name))
+		if err != nil {
+			return err
+		}
+		base := filepath.Base(name)
+		target := filepath.Join(parent, base)
+
+		// Whiteouts remove the files of the layers below
+		if base == ociWhiteoutOpaque {
+			entries, err := ioutil.ReadDir(parent)
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			for _, entry := range entries {
+				if err := os.RemoveAll(filepath.Join(parent, entry.Name())); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if strings.HasPrefix(base, ociWhiteoutPrefix) {
+			if err := os.RemoveAll(filepath.Join(parent, strings.TrimPrefix(base, ociWhiteoutPrefix))); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := os.MkdirAll(parent, 0755); err != nil {
+			return err
+		}
+		mode := hdr.FileInfo().Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if fi, err := os.Lstat(target); err == nil && !fi.IsDir() {
+				if err := os.RemoveAll(target); err != nil {
+					return err
+				}
+			}
+			if err := os.MkdirAll(target, mode); err != nil {
+				return err
+			}
+		case tar.TypeReg, tar.TypeRegA:
+			if err := ociRemoveFile(target); err != nil {
+				return err
+			}
+			out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_EXCL, mode)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(out, tr)
+			out.Close()
+			if err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := ociRemoveFile(target); err != nil {
+				return err
+			}
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return err
+			}
+		case tar.TypeLink:
+			source, err := allocdir.SecurePath(dest, hdr.Linkname)
+			if err != nil {
+				return err
+			}
+			if err := ociRemoveFile(target); err != nil {
+				return err
+			}
+			if err := os.Link(source, target); err != nil {
+				return err
+			}
+		default:
+			// Devices and fifos are provided by the mounts of the container
+			continue
+		}
+
+		// The ownership of the files is kept when unpacking as root, before
+		// setting their mode as changing the owner clears the setuid bit
+		if root {
+			if err := os.Lchown(target, hdr.Uid, hdr.Gid); err != nil {
+				return err
+			}
+		}
+		if hdr.Typeflag != tar.TypeSymlink {
+			if err := os.Chmod(target, mode); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// ociRemoveFile removes the file at the path so that it can be replaced,
+// unless it is a directory.
+func ociRemoveFile(path string) error {
+	fi, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		return fmt.Errorf("can't replace directory %s with a file", path)
+	}
+	return os.Remove(path)
+}
@@ -0,0 +1,201 @@
+package driver
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/hashicorp/nomad/client/allocdir"
+	cstructs "github.com/hashicorp/nomad/client/driver/structs"
+	"github.com/opencontainers/runc/libcontainer/configs"
+)
+
+// ociRlimitTypes maps the names of the rlimits to their resource.
+var ociRlimitTypes = map[string]int{
+	"cpu":        0,
+	"fsize":      1,
+	"data":       2,
+	"stack":      3,
+	"core":       4,
+	"rss":        5,
+	"nproc":      6,
+	"nofile":     7,
+	"memlock":    8,
+	"as":         9,
+	"locks":      10,
+	"sigpending": 11,
+	"msgqueue":   12,
+	"nice":       13,
+	"rtprio":     14,
+	"rttime":     15,
+}
+
+// ociCapabilities are the capabilities kept by the processes of the
+// containers running as root, the default ones of docker but CAP_MKNOD.
+var ociCapabilities = []string{
+	"CAP_AUDIT_WRITE",
+	"CAP_CHOWN",
+	"CAP_DAC_OVERRIDE",
+	"CAP_FOWNER",
+	"CAP_FSETID",
+	"CAP_KILL",
+	"CAP_NET_BIND_SERVICE",
+	"CAP_NET_RAW",
+	"CAP_SETFCAP",
+	"CAP_SETGID",
+	"CAP_SETPCAP",
+	"CAP_SETUID",
+	"CAP_SYS_CHROOT",
+}
+
+// ociMaskPaths are the files of /proc hidden from the containers and
+// ociReadonlyPaths the paths they can only read. libcontainer can only mask
+// files, directories are made read only instead.
+var (
+	ociMaskPaths = []string{
+		"/proc/kcore",
+		"/proc/keys",
+		"/proc/latency_stats",
+		"/proc/sched_debug",
+		"/proc/timer_list",
+		"/proc/timer_stats",
+	}
+	ociReadonlyPaths = []string{
+		"/proc/acpi",
+		"/proc/asound",
+		"/proc/bus",
+		"/proc/fs",
+		"/proc/irq",
+		"/proc/scsi",
+		"/proc/sys",
+		"/proc/sysrq-trigger",
+	}
+)
+
+// ociHostFiles are the files of the host bind mounted read only into the
+// containers, which share the network of the host.
+var ociHostFiles = []string{"/etc/resolv.conf", "/etc/hosts"}
+
+// ociContainerConfig returns the libcontainer configuration of the container
+// of the task. The container has its own mount, pid, uts and ipc namespaces
+// but shares the network namespace of the host like the exec driver, so that
+// the ports of the task are reachable. Its /dev only has the default devices,
+// which are the only ones it may access, the sensitive paths of /proc are
+// masked and it keeps the default capabilities of docker but CAP_MKNOD. The
+// shared alloc dir, the local dir of the task and the host volumes, relative
+// to the root of the container, are mounted in the container. Its cgroups
+// are derived from the resources of the task by the executor.
+func ociContainerConfig(rootfs, sharedDir, taskDir string, mounts []*cstructs.MountConfig,
+	rlimits []configs.Rlimit) (*configs.Config, error) {
+	c := &configs.Config{
+		Rootfs: rootfs,
+		Namespaces: configs.Namespaces{
+			{Type: configs.NEWNS},
+			{Type: configs.NEWPID},
+			{Type: configs.NEWUTS},
+			{Type: configs.NEWIPC},
+		},
+		Mounts: []*configs.Mount{
+			{
+				Source:      "proc",
+				Destination: "/proc",
+				Device:      "proc",
+				Flags:       syscall.MS_NOSUID | syscall.MS_NOEXEC | syscall.MS_NODEV,
+			},
+			{
+				Source:      "tmpfs",
+				Destination: "/dev",
+				Device:      "tmpfs",
+				Flags:       syscall.MS_NOSUID | syscall.MS_STRICTATIME,
+				Data:        "mode=755",
+			},
+			{
+				Source:      "shm",
+				Destination: "/dev/shm",
+				Device:      "tmpfs",
+				Flags:       syscall.MS_NOSUID | syscall.MS_NOEXEC | syscall.MS_NODEV,
+				Data:        "mode=1777,size=65536k",
+			},
+			{
+				Source:      sharedDir,
+				Destination: filepath.Join("/", allocdir.SharedAllocName),
+				Device:      "bind",
+				Flags:       syscall.MS_BIND | syscall.MS_REC,
+			},
+			{
+				Source:      filepath.Join(taskDir, allocdir.TaskLocal),
+				Destination: filepath.Join("/", allocdir.TaskLocal),
+				Device:      "bind",
+				Flags:       syscall.MS_BIND | syscall.MS_REC,
+			},
+		},
+		Devices:       configs.DefaultSimpleDevices,
+		Capabilities:  ociCapabilities,
+		MaskPaths:     ociMaskPaths,
+		ReadonlyPaths: ociReadonlyPaths,
+		Rlimits:       rlimits,
+	}
+	for _, file := range ociHostFiles {
+		if _, err := os.Stat(file); err == nil {
+			c.Mounts = append(c.Mounts, &configs.Mount{
+				Source:      file,
+				Destination: file,
+				Device:      "bind",
+				Flags:       syscall.MS_BIND | syscall.MS_REC | syscall.MS_RDONLY,
+			})
+		}
+	}
+	for _, m := range mounts {
+		mount := &configs.Mount{
+			Source:      m.HostPath,
+			Destination: filepath.Join("/", m.TaskPath),
+			Device:      "bind",
+			Flags:       syscall.MS_BIND | syscall.MS_REC,
+		}
+		if m.ReadOnly {
+			mount.Flags |= syscall.MS_RDONLY
+		}
+		c.Mounts = append(c.Mounts, mount)
+	}
+	return c, nil
+}
+
+// ociRlimits parses the rlimits of the task, given either as a single limit
+// for both the soft and hard limits or as "soft:hard". A limit is a number or
+// "unlimited".
+func ociRlimits(raw map[string]string) ([]configs.Rlimit, error) {
+	var rlimits []configs.Rlimit
+	for name, value := range raw {
+		typ, ok := ociRlimitTypes[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown rlimit %q", name)
+		}
+		parts := strings.SplitN(value, ":", 2)
+		soft, err := ociParseRlimit(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid rlimit %s %q: %v", name, value, err)
+		}
+		hard := soft
+		if len(parts) == 2 {
+			if hard, err = ociParseRlimit(parts[1]); err != nil {
+				return nil, fmt.Errorf("invalid rlimit %s %q: %v", name, value, err)
+			}
+		}
+		if soft > hard {
+			return nil, fmt.Errorf("invalid rlimit %s %q: soft limit exceeds hard limit", name, value)
+		}
+		rlimits = append(rlimits, configs.Rlimit{Type: typ, Soft: soft, Hard: hard})
+	}
+	return rlimits, nil
+}
+
+func ociParseRlimit(value string) (uint64, error) {
+	value = strings.TrimSpace(value)
+	if value == "unlimited" {
+		return ^uint64(0), nil
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
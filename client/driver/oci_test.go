@@ -0,0 +1,497 @@
+package driver
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	cstructs "github.com/hashicorp/nomad/client/driver/structs"
+	"github.com/hashicorp/nomad/nomad/structs"
+	"github.com/hashicorp/nomad/testutil"
+	"github.com/opencontainers/runc/libcontainer/configs"
+
+	ctestutils "github.com/hashicorp/nomad/client/testutil"
+)
+
+// testOCIEntry is an entry of a test layer tarball.
+type testOCIEntry struct {
+	Name     string
+	Type     byte
+	Mode     int64
+	Content  string
+	Linkname string
+}
+
+func testOCILayer(t *testing.T, compress bool, entries ...testOCIEntry) []byte {
+	var buf bytes.Buffer
+	var w io.Writer = &buf
+	var gz *gzip.Writer
+	if compress {
+		gz = gzip.NewWriter(&buf)
+		w = gz
+	}
+	tw := tar.NewWriter(w)
+	for _, e := range entries {
+		hdr := &tar.Header{
+			Name:     e.Name,
+			Typeflag: e.Type,
+			Mode:     e.Mode,
+			Size:     int64(len(e.Content)),
+			Linkname: e.Linkname,
+		}
+		if e.Type != tar.TypeReg {
+			hdr.Size = 0
+		}
+		if hdr.Mode == 0 {
+			hdr.Mode = 0644
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if e.Type == tar.TypeReg {
+			if _, err := tw.Write([]byte(e.Content)); err != nil {
+				t.Fatalf("err: %v", err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+	return buf.Bytes()
+}
+
+// testOCILayout writes an OCI image layout of the layers and the image config
+// to the directory.
+func testOCILayout(t *testing.T, dir string, config *ociImageConfig, layers ...[]byte) {
+	writeBlob := func(data []byte) string {
+		digest := fmt.Sprintf("%x", sha256.Sum256(data))
+		path := filepath.Join(dir, "blobs", "sha256", digest)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if err := ioutil.WriteFile(path, data, 0644); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		return "sha256:" + digest
+	}
+	writeJSON := func(v interface{}) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		return writeBlob(data)
+	}
+
+	manifest := ociManifest{
+		Config: ociDescriptor{Digest: writeJSON(&ociImage{Config: *config})},
+	}
+	for _, layer := range layers {
+		manifest.Layers = append(manifest.Layers, ociDescriptor{Digest: writeBlob(layer)})
+	}
+	index := ociIndex{
+		Manifests: []ociDescriptor{{
+			Digest:   writeJSON(&manifest),
+			Platform: &ociPlatform{OS: "linux", Architecture: "amd64"},
+		}},
+	}
+	data, _ := json.Marshal(&index)
+	if err := ioutil.WriteFile(filepath.Join(dir, "index.json"), data, 0644); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, ociLayoutFile), []byte(`{"imageLayoutVersion":"1.0.0"}`), 0644); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+// testTarDir returns a tarball of the files of the directory.
+func testTarDir(t *testing.T, dir string) []byte {
+	var entries []testOCIEntry
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || path == dir {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		if fi.IsDir() {
+			entries = append(entries, testOCIEntry{Name: rel + "/", Type: tar.TypeDir, Mode: 0755})
+			return nil
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, testOCIEntry{Name: rel, Type: tar.TypeReg, Content: string(data)})
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return testOCILayer(t, false, entries...)
+}
+
+func testOCILayers(t *testing.T) [][]byte {
+	base := testOCILayer(t, true,
+		testOCIEntry{Name: "etc/", Type: tar.TypeDir, Mode: 0755},
+		testOCIEntry{Name: "etc/passwd", Type: tar.TypeReg, Content: "root:x:0:0::/root:/bin/sh\n"},
+		testOCIEntry{Name: "usr/lib/", Type: tar.TypeDir, Mode: 0755},
+		testOCIEntry{Name: "lib", Type: tar.TypeSymlink, Linkname: "usr/lib"},
+		testOCIEntry{Name: "escape", Type: tar.TypeSymlink, Linkname: "/"},
+		testOCIEntry{Name: "bin/app", Type: tar.TypeReg, Mode: 0755, Content: "#!/bin/sh\n"},
+		testOCIEntry{Name: "var/cache/old", Type: tar.TypeReg, Content: "old"},
+		testOCIEntry{Name: "opt/data/a", Type: tar.TypeReg, Content: "a"},
+	)
+	top := testOCILayer(t, false,
+		testOCIEntry{Name: "lib/libapp.so", Type: tar.TypeReg, Content: "lib"},
+		testOCIEntry{Name: "escape/escaped", Type: tar.TypeReg, Content: "contained"},
+		testOCIEntry{Name: "../../outside", Type: tar.TypeReg, Content: "contained"},
+		testOCIEntry{Name: "var/cache/.wh.old", Type: tar.TypeReg},
+		testOCIEntry{Name: "opt/data/.wh..wh..opq", Type: tar.TypeReg},
+		testOCIEntry{Name: "opt/data/b", Type: tar.TypeReg, Content: "b"},
+		testOCIEntry{Name: "etc/passwd.link", Type: tar.TypeLink, Linkname: "/etc/passwd"},
+	)
+	return [][]byte{base, top}
+}
+
+func testCheckOCIRootfs(t *testing.T, rootfs string) {
+	files := map[string]string{
+		"usr/lib/libapp.so": "lib",
+		"escaped":           "contained",
+		"outside":           "contained",
+		"opt/data/b":        "b",
+		"etc/passwd.link":   "root:x:0:0::/root:/bin/sh\n",
+	}
+	for path, expected := range files {
+		data, err := ioutil.ReadFile(filepath.Join(rootfs, path))
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if string(data) != expected {
+			t.Fatalf("bad content of %s: %q", path, data)
+		}
+	}
+	for _, path := range []string{"var/cache/old", "opt/data/a"} {
+		if _, err := os.Stat(filepath.Join(rootfs, path)); !os.IsNotExist(err) {
+			t.Fatalf("whiteout %s not applied: %v", path, err)
+		}
+	}
+	if fi, err := os.Stat(filepath.Join(rootfs, "bin", "app")); err != nil || fi.Mode().Perm() != 0755 {
+		t.Fatalf("bad app: %v, %v", fi, err)
+	}
+	if _, err := os.Stat("/escaped"); err == nil {
+		t.Fatalf("layer extracted outside of the rootfs")
+	}
+}
+
+func TestOCIDriver_UnpackLayout(t *testing.T) {
+	t.Parallel()
+	taskDir, err := ioutil.TempDir("", "oci")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer os.RemoveAll(taskDir)
+
+	config := &ociImageConfig{
+		User:       "app",
+		Env:        []string{"PATH=/bin", "GREETING=hello"},
+		Entrypoint: []string{"/bin/app"},
+		Cmd:        []string{"--serve"},
+		WorkingDir: "/srv",
+	}
+	layout := filepath.Join(taskDir, "local", "image")
+	if err := os.MkdirAll(layout, 0755); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	testOCILayout(t, layout, config, testOCILayers(t)...)
+
+	rootfs, actual, err := ociUnpackImage("local/image", taskDir)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if rootfs != filepath.Join(taskDir, ociRootfsDir) {
+		t.Fatalf("bad rootfs: %s", rootfs)
+	}
+	if !reflect.DeepEqual(actual, config) {
+		t.Fatalf("got config %#v; want %#v", actual, config)
+	}
+	testCheckOCIRootfs(t, rootfs)
+
+	// The layout can be a tarball too
+	tarball := filepath.Join(taskDir, "local", "image.tar")
+	if err := ioutil.WriteFile(tarball, testTarDir(t, layout), 0644); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := os.RemoveAll(rootfs); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if rootfs, _, err = ociUnpackImage(tarball, taskDir); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	testCheckOCIRootfs(t, rootfs)
+	if _, err := os.Stat(filepath.Join(taskDir, ociImageDir)); !os.IsNotExist(err) {
+		t.Fatalf("extracted image not removed: %v", err)
+	}
+}
+
+func TestOCIDriver_UnpackArchive(t *testing.T) {
+	t.Parallel()
+	taskDir, err := ioutil.TempDir("", "oci")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer os.RemoveAll(taskDir)
+
+	// An image archive as saved by docker save
+	layers := testOCILayers(t)
+	image, _ := json.Marshal(&ociImage{Config: ociImageConfig{Cmd: []string{"/bin/app"}}})
+	manifest, _ := json.Marshal([]ociArchive{{Config: "config.json", Layers: []string{"base/layer.tar", "top/layer.tar"}}})
+	archive := testOCILayer(t, true,
+		testOCIEntry{Name: ociArchiveManifest, Type: tar.TypeReg, Content: string(manifest)},
+		testOCIEntry{Name: "config.json", Type: tar.TypeReg, Content: string(image)},
+		testOCIEntry{Name: "base/layer.tar", Type: tar.TypeReg, Content: string(layers[0])},
+		testOCIEntry{Name: "top/layer.tar", Type: tar.TypeReg, Content: string(layers[1])},
+	)
+	if err := ioutil.WriteFile(filepath.Join(taskDir, "image.tar.gz"), archive, 0644); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	rootfs, config, err := ociUnpackImage("image.tar.gz", taskDir)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(config.Cmd, []string{"/bin/app"}) {
+		t.Fatalf("bad config: %#v", config)
+	}
+	testCheckOCIRootfs(t, rootfs)
+}
+
+func TestOCIDriver_UnpackRootfs(t *testing.T) {
+	t.Parallel()
+	taskDir, err := ioutil.TempDir("", "oci")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer os.RemoveAll(taskDir)
+
+	// A rootfs tarball is extracted as a single layer
+	tarball := testOCILayer(t, true, testOCIEntry{Name: "etc/hostname", Type: tar.TypeReg, Content: "web"})
+	if err := ioutil.WriteFile(filepath.Join(taskDir, "rootfs.tar.gz"), tarball, 0644); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	rootfs, config, err := ociUnpackImage("rootfs.tar.gz", taskDir)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if data, err := ioutil.ReadFile(filepath.Join(rootfs, "etc", "hostname")); err != nil || string(data) != "web" {
+		t.Fatalf("rootfs not extracted: %q, %v", data, err)
+	}
+	if !reflect.DeepEqual(config, &ociImageConfig{}) {
+		t.Fatalf("bad config: %#v", config)
+	}
+
+	// A rootfs directory is used as is
+	if actual, _, err := ociUnpackImage(rootfs, taskDir); err != nil || actual != rootfs {
+		t.Fatalf("bad rootfs %s: %v", actual, err)
+	}
+
+	if _, _, err := ociUnpackImage("missing", taskDir); err == nil {
+		t.Fatalf("expected an error for a missing image")
+	}
+}
+
+func TestOCIDriver_UnpackImage_Escape(t *testing.T) {
+	t.Parallel()
+	taskDir, err := ioutil.TempDir("", "oci")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer os.RemoveAll(taskDir)
+
+	// Symlinks are resolved within the task dir
+	if err := os.MkdirAll(filepath.Join(taskDir, "local"), 0755); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := os.Symlink("/etc", filepath.Join(taskDir, "local", "etc")); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for _, image := range []string{"/", "/etc", "..", "../rootfs", "local/../../rootfs", "local/etc"} {
+		if rootfs, _, err := ociUnpackImage(image, taskDir); err == nil {
+			t.Fatalf("image %s outside of the task dir used as rootfs %s", image, rootfs)
+		}
+	}
+}
+
+func TestOCIDriver_Command(t *testing.T) {
+	t.Parallel()
+	image := &ociImageConfig{Entrypoint: []string{"/bin/app", "-v"}, Cmd: []string{"serve"}}
+	cases := []struct {
+		Config  *OCIDriverConfig
+		Command string
+		Args    []string
+	}{
+		{&OCIDriverConfig{}, "/bin/app", []string{"-v", "serve"}},
+		{&OCIDriverConfig{Args: []string{"migrate"}}, "/bin/app", []string{"-v", "migrate"}},
+		{&OCIDriverConfig{Command: "/bin/sh", Args: []string{"-c", "true"}}, "/bin/sh", []string{"-c", "true"}},
+	}
+	for _, c := range cases {
+		command, args := ociCommand(c.Config, image)
+		if command != c.Command || !reflect.DeepEqual(args, c.Args) {
+			t.Fatalf("ociCommand(%#v) = %s %v; want %s %v", c.Config, command, args, c.Command, c.Args)
+		}
+	}
+	if command, _ := ociCommand(&OCIDriverConfig{}, &ociImageConfig{}); command != "" {
+		t.Fatalf("expected no command, got %s", command)
+	}
+}
+
+func TestOCIDriver_Rlimits(t *testing.T) {
+	t.Parallel()
+	rlimits, err := ociRlimits(map[string]string{"nofile": "1024:4096"})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	expected := []configs.Rlimit{{Type: 7, Soft: 1024, Hard: 4096}}
+	if !reflect.DeepEqual(rlimits, expected) {
+		t.Fatalf("got %#v; want %#v", rlimits, expected)
+	}
+	if rlimits, err = ociRlimits(map[string]string{"core": "unlimited"}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if rlimits[0].Soft != ^uint64(0) || rlimits[0].Hard != ^uint64(0) {
+		t.Fatalf("bad unlimited rlimit: %#v", rlimits)
+	}
+
+	for _, raw := range []map[string]string{{"bogus": "1"}, {"nofile": "many"}, {"nofile": "10:5"}} {
+		if _, err := ociRlimits(raw); err == nil {
+			t.Fatalf("expected an error for %v", raw)
+		}
+	}
+}
+
+func TestOCIDriver_ContainerConfig(t *testing.T) {
+	t.Parallel()
+	mounts := []*cstructs.MountConfig{{HostPath: "/opt/data", TaskPath: "data", ReadOnly: true}}
+	c, err := ociContainerConfig("/task/rootfs", "/alloc/1234/alloc", "/alloc/1234/web", mounts, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if c.Rootfs != "/task/rootfs" || c.Namespaces.Contains(configs.NEWNET) || !c.Namespaces.Contains(configs.NEWNS) || !c.Namespaces.Contains(configs.NEWPID) {
+		t.Fatalf("bad config: %#v", c)
+	}
+	for _, capability := range c.Capabilities {
+		if capability == "CAP_SYS_ADMIN" || capability == "CAP_MKNOD" {
+			t.Fatalf("container keeps %s", capability)
+		}
+	}
+	if len(c.Devices) == 0 || len(c.MaskPaths) == 0 || len(c.ReadonlyPaths) == 0 {
+		t.Fatalf("container isn't restricted: %#v", c)
+	}
+	destinations := make(map[string]*configs.Mount)
+	for _, m := range c.Mounts {
+		destinations[m.Destination] = m
+	}
+	if m := destinations["/alloc"]; m == nil || m.Source != "/alloc/1234/alloc" || m.Device != "bind" {
+		t.Fatalf("bad alloc mount: %#v", m)
+	}
+	if m := destinations["/local"]; m == nil || m.Source != "/alloc/1234/web/local" {
+		t.Fatalf("bad local mount: %#v", m)
+	}
+	if m := destinations["/data"]; m == nil || m.Source != "/opt/data" || m.Flags&syscall.MS_RDONLY == 0 {
+		t.Fatalf("bad volume mount: %#v", m)
+	}
+	if destinations["/proc"] == nil || destinations["/dev"] == nil {
+		t.Fatalf("missing special mounts: %#v", c.Mounts)
+	}
+	if m := destinations["/dev"]; m.Device != "tmpfs" {
+		t.Fatalf("the devices of the host are mounted: %#v", m)
+	}
+}
+
+func TestOCIDriver_StartOpen_Wait(t *testing.T) {
+	t.Parallel()
+	ctestutils.ExecCompatible(t)
+	task := &structs.Task{
+		Name: "web",
+		Config: map[string]interface{}{
+			"image": "local/image",
+		},
+		Env: map[string]string{
+			"GREETING": "hi",
+		},
+		LogConfig: &structs.LogConfig{
+			MaxFiles:      10,
+			MaxFileSizeMB: 10,
+		},
+		Resources: basicResources,
+	}
+
+	driverCtx, execCtx := testDriverContexts(task)
+	defer execCtx.AllocDir.Destroy()
+
+	// The image runs the shell of the host, mounted as volumes
+	for _, dir := range []string{"/bin", "/lib", "/lib64", "/usr"} {
+		if _, err := os.Stat(dir); err == nil {
+			driverCtx.mounts = append(driverCtx.mounts, &cstructs.MountConfig{HostPath: dir, TaskPath: dir, ReadOnly: true})
+		}
+	}
+	layout := filepath.Join(execCtx.AllocDir.TaskDirs[task.Name], "local", "image")
+	if err := os.MkdirAll(layout, 0755); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	config := &ociImageConfig{
+		Env:        []string{"GREETING=hello", "NAME=oci"},
+		Cmd:        []string{"sh", "-c", `echo "$GREETING $NAME $(pwd)" > $NOMAD_ALLOC_DIR/data/out; sleep 1`},
+		WorkingDir: "/srv",
+	}
+	layer := testOCILayer(t, true, testOCIEntry{Name: "srv/", Type: tar.TypeDir, Mode: 0755})
+	testOCILayout(t, layout, config, layer)
+
+	d := NewOCIDriver(driverCtx)
+	handle, err := d.Start(execCtx, task)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Attempt to open
+	handle2, err := d.Open(execCtx, handle.ID())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if handle2 == nil {
+		t.Fatalf("missing handle")
+	}
+
+	select {
+	case res := <-handle.WaitCh():
+		if !res.Successful() {
+			t.Fatalf("err: %v", res)
+		}
+	case <-time.After(time.Duration(testutil.TestMultiplier()*5) * time.Second):
+		t.Fatalf("timeout")
+	}
+
+	out, err := ioutil.ReadFile(filepath.Join(execCtx.AllocDir.SharedDir, "data", "out"))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if actual := strings.TrimSpace(string(out)); actual != "hi oci /srv" {
+		t.Fatalf("bad output: %q", actual)
+	}
+	handle2.Kill()
+}
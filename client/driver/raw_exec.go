@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"os"
 	"os/exec"
 	"path/filepath"
 	"time"
@@ -14,7 +15,6 @@ import (
 	"github.com/hashicorp/nomad/client/driver/executor"
 	cstructs "github.com/hashicorp/nomad/client/driver/structs"
 	"github.com/hashicorp/nomad/client/fingerprint"
-	"github.com/hashicorp/nomad/client/getter"
 	"github.com/hashicorp/nomad/helper/discover"
 	"github.com/hashicorp/nomad/nomad/structs"
 	"github.com/mitchellh/mapstructure"
@@ -41,6 +41,7 @@ type rawExecHandle struct {
 	executor     executor.Executor
 	killTimeout  time.Duration
 	allocDir     *allocdir.AllocDir
+	taskDir      string
 	logger       *log.Logger
 	waitCh       chan *cstructs.WaitResult
 	doneCh       chan struct{}
@@ -82,21 +83,6 @@ func (d *RawExecDriver) Start(ctx *ExecContext, task *structs.Task) (DriverHandl
 		return nil, err
 	}
 
-	// Check if an artificat is specified and attempt to download it
-	source, ok := task.Config["artifact_source"]
-	if ok && source != "" {
-		// Proceed to download an artifact to be executed.
-		_, err := getter.GetArtifact(
-			taskDir,
-			driverConfig.ArtifactSource,
-			driverConfig.Checksum,
-			d.logger,
-		)
-		if err != nil {
-			return nil, err
-		}
-	}
-
 	bin, err := discover.NomadExecutable()
 	if err != nil {
 		return nil, fmt.Errorf("unable to find the nomad binary: %v", err)
@@ -116,6 +102,7 @@ func (d *RawExecDriver) Start(ctx *ExecContext, task *structs.Task) (DriverHandl
 		TaskName:      task.Name,
 		TaskResources: task.Resources,
 		LogConfig:     task.LogConfig,
+		Mounts:        d.mounts,
 	}
 	ps, err := exec.LaunchCmd(&executor.ExecCommand{Cmd: command, Args: driverConfig.Args}, executorCtx)
 	if err != nil {
@@ -131,6 +118,7 @@ func (d *RawExecDriver) Start(ctx *ExecContext, task *structs.Task) (DriverHandl
 		userPid:      ps.Pid,
 		killTimeout:  d.DriverContext.KillTimeout(task),
 		allocDir:     ctx.AllocDir,
+		taskDir:      taskDir,
 		version:      d.config.Version,
 		logger:       d.logger,
 		doneCh:       make(chan struct{}),
@@ -146,6 +134,7 @@ type rawExecId struct {
 	UserPid      int
 	PluginConfig *PluginReattachConfig
 	AllocDir     *allocdir.AllocDir
+	TaskDir      string
 }
 
 func (d *RawExecDriver) Open(ctx *ExecContext, handleID string) (DriverHandle, error) {
@@ -174,6 +163,7 @@ func (d *RawExecDriver) Open(ctx *ExecContext, handleID string) (DriverHandle, e
 		logger:       d.logger,
 		killTimeout:  id.KillTimeout,
 		allocDir:     id.AllocDir,
+		taskDir:      id.TaskDir,
 		version:      id.Version,
 		doneCh:       make(chan struct{}),
 		waitCh:       make(chan *cstructs.WaitResult, 1),
@@ -189,6 +179,7 @@ func (h *rawExecHandle) ID() string {
 		PluginConfig: NewPluginReattachConfig(h.pluginClient.ReattachConfig()),
 		UserPid:      h.userPid,
 		AllocDir:     h.allocDir,
+		TaskDir:      h.taskDir,
 	}
 
 	data, err := json.Marshal(id)
@@ -211,6 +202,14 @@ func (h *rawExecHandle) Update(task *structs.Task) error {
 	return nil
 }
 
+func (h *rawExecHandle) Signal(s os.Signal) error {
+	return h.executor.Signal(s)
+}
+
+func (h *rawExecHandle) Exec(opts *cstructs.ExecOptions) (int, error) {
+	return execInTaskDir(h.userPid, h.taskDir, opts)
+}
+
 func (h *rawExecHandle) Kill() error {
 	if err := h.executor.ShutDown(); err != nil {
 		if h.pluginClient.Exited() {
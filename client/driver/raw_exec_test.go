@@ -1,17 +1,22 @@
 package driver
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"path/filepath"
 	"reflect"
+	"strings"
+	"syscall"
 	"testing"
 	"time"
 
 	"github.com/hashicorp/nomad/client/config"
 	"github.com/hashicorp/nomad/client/driver/env"
+	cstructs "github.com/hashicorp/nomad/client/driver/structs"
+	"github.com/hashicorp/nomad/helper/term"
 	"github.com/hashicorp/nomad/helper/testtask"
 	"github.com/hashicorp/nomad/nomad/structs"
 	"github.com/hashicorp/nomad/testutil"
@@ -109,21 +114,26 @@ func TestRawExecDriver_Start_Artifact_basic(t *testing.T) {
 	task := &structs.Task{
 		Name: "sleep",
 		Config: map[string]interface{}{
-			"artifact_source": fmt.Sprintf("%s/%s", ts.URL, file),
-			"command":         file,
-			"args":            []string{"sleep", "1s"},
+			"command": file,
+			"args":    []string{"sleep", "1s"},
 		},
 		LogConfig: &structs.LogConfig{
 			MaxFiles:      10,
 			MaxFileSizeMB: 10,
 		},
 		Resources: basicResources,
+		Artifacts: []*structs.TaskArtifact{
+			{
+				GetterSource: fmt.Sprintf("%s/%s", ts.URL, file),
+			},
+		},
 	}
 	testtask.SetTaskEnv(task)
 
 	driverCtx, execCtx := testDriverContexts(task)
 	defer execCtx.AllocDir.Destroy()
 	d := NewRawExecDriver(driverCtx)
+	testDownloadArtifacts(t, task, execCtx)
 
 	handle, err := d.Start(execCtx, task)
 	if err != nil {
@@ -298,3 +308,124 @@ func TestRawExecDriver_Start_Kill_Wait(t *testing.T) {
 		t.Fatalf("timeout")
 	}
 }
+
+func TestRawExecDriver_Start_Signal_Wait(t *testing.T) {
+	t.Parallel()
+	task := &structs.Task{
+		Name: "sleep",
+		Config: map[string]interface{}{
+			"command": testtask.Path(),
+			"args":    []string{"sleep", "45s"},
+		},
+		LogConfig: &structs.LogConfig{
+			MaxFiles:      10,
+			MaxFileSizeMB: 10,
+		},
+		Resources: basicResources,
+	}
+	testtask.SetTaskEnv(task)
+
+	driverCtx, execCtx := testDriverContexts(task)
+	defer execCtx.AllocDir.Destroy()
+	d := NewRawExecDriver(driverCtx)
+
+	handle, err := d.Start(execCtx, task)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if handle == nil {
+		t.Fatalf("missing handle")
+	}
+
+	if err := handle.Signal(syscall.SIGTERM); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Task should terminate quickly
+	select {
+	case res := <-handle.WaitCh():
+		if res.Successful() {
+			t.Fatal("should err")
+		}
+	case <-time.After(time.Duration(testutil.TestMultiplier()*5) * time.Second):
+		handle.Kill()
+		t.Fatalf("timeout")
+	}
+}
+
+func TestRawExecDriver_Exec(t *testing.T) {
+	t.Parallel()
+	task := &structs.Task{
+		Name: "sleep",
+		Config: map[string]interface{}{
+			"command": testtask.Path(),
+			"args":    []string{"sleep", "45s"},
+		},
+		LogConfig: &structs.LogConfig{
+			MaxFiles:      10,
+			MaxFileSizeMB: 10,
+		},
+		Resources: basicResources,
+	}
+	testtask.SetTaskEnv(task)
+
+	driverCtx, execCtx := testDriverContexts(task)
+	defer execCtx.AllocDir.Destroy()
+	d := NewRawExecDriver(driverCtx)
+
+	handle, err := d.Start(execCtx, task)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer handle.Kill()
+	execer, ok := handle.(ExecHandle)
+	if !ok {
+		t.Fatalf("raw_exec handle should support exec")
+	}
+
+	// The command runs in the task dir, reads the input and reports its exit
+	// code
+	var stdout, stderr bytes.Buffer
+	code, err := execer.Exec(&cstructs.ExecOptions{
+		Command: []string{"/bin/sh", "-c", "read line; echo $line; pwd; echo oops >&2; exit 3"},
+		Stdin:   strings.NewReader("hello\n"),
+		Stdout:  &stdout,
+		Stderr:  &stderr,
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if code != 3 {
+		t.Fatalf("got exit code %d; want 3", code)
+	}
+	taskDir := execCtx.AllocDir.TaskDirs[task.Name]
+	if exp := "hello\n" + taskDir + "\n"; stdout.String() != exp {
+		t.Fatalf("got stdout %q; want %q", stdout.String(), exp)
+	}
+	if stderr.String() != "oops\n" {
+		t.Fatalf("got stderr %q", stderr.String())
+	}
+
+	// A terminal is allocated on request and sized
+	resizeCh := make(chan term.Size, 1)
+	resizeCh <- term.Size{Height: 40, Width: 120}
+	stdout.Reset()
+	code, err = execer.Exec(&cstructs.ExecOptions{
+		Command:  []string{"/bin/sh", "-c", "sleep 1; test -t 0 && stty size"},
+		Tty:      true,
+		Stdout:   &stdout,
+		ResizeCh: resizeCh,
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "failed to allocate a terminal") {
+			t.Skipf("unable to allocate a terminal: %v", err)
+		}
+		t.Fatalf("err: %v", err)
+	}
+	if code != 0 {
+		t.Fatalf("got exit code %d; output %q", code, stdout.String())
+	}
+	if !strings.Contains(stdout.String(), "40 120") {
+		t.Fatalf("got stdout %q", stdout.String())
+	}
+}
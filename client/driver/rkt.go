@@ -297,6 +297,11 @@ func (h *rktHandle) Kill() error {
 	}
 }
 
+// Signal sends the signal to the rkt process.
+func (h *rktHandle) Signal(s os.Signal) error {
+	return h.proc.Signal(s)
+}
+
 func (h *rktHandle) run() {
 	ps, err := h.proc.Wait()
 	close(h.doneCh)
@@ -2,6 +2,9 @@ package structs
 
 import (
 	"fmt"
+	"io"
+
+	"github.com/hashicorp/nomad/helper/term"
 	cgroupConfig "github.com/opencontainers/runc/libcontainer/configs"
 )
 
@@ -10,6 +13,10 @@ type WaitResult struct {
 	ExitCode int
 	Signal   int
 	Err      error
+
+	// OOMKilled is set if the task was killed for exceeding its memory
+	// limit.
+	OOMKilled bool
 }
 
 func NewWaitResult(code, signal int, err error) *WaitResult {
@@ -34,3 +41,29 @@ func (r *WaitResult) String() string {
 type IsolationConfig struct {
 	Cgroup *cgroupConfig.Cgroup
 }
+
+// MountConfig is a host directory to bind mount into a task. The TaskPath is
+// relative to the root of the task's file system.
+type MountConfig struct {
+	HostPath string
+	TaskPath string
+	ReadOnly bool
+}
+
+// ExecOptions describes a command to run inside the isolation context of a
+// running task and the streams it is attached to.
+type ExecOptions struct {
+	// Command is the command and its arguments.
+	Command []string
+
+	// Tty allocates a terminal for the command. Its output is then written to
+	// Stdout only.
+	Tty bool
+
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// ResizeCh receives the new size of the terminal when it changes.
+	ResizeCh <-chan term.Size
+}
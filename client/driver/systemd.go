@@ -1,6 +1,7 @@
 package driver
 
 import (
+	"encoding/json"
 	"fmt"
 	systemd "github.com/coreos/go-systemd/dbus"
 	"github.com/coreos/go-systemd/util"
@@ -8,13 +9,26 @@ import (
 	"github.com/hashicorp/nomad/client/driver/executor"
 	cstructs "github.com/hashicorp/nomad/client/driver/structs"
 	"github.com/hashicorp/nomad/client/fingerprint"
+	"github.com/hashicorp/nomad/helper/signals"
 	"github.com/hashicorp/nomad/nomad/structs"
 	"github.com/mitchellh/mapstructure"
 	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 )
 
+// systemdInvalidUnitChars matches the characters not allowed in unit names.
+var systemdInvalidUnitChars = regexp.MustCompile(`[^a-zA-Z0-9:_.-]`)
+
 type SystemdDriverConfig struct {
-	Command string `mapstructure:"command"`
+	Command string   `mapstructure:"command"`
+	Args    []string `mapstructure:"args"`
+	User    string   `mapstructure:"user"`
 }
 
 type SystemdDriver struct {
@@ -24,10 +38,19 @@ type SystemdDriver struct {
 
 type systemdHandle struct {
 	logger   *log.Logger
-	Conn     *systemd.Conn
+	id       *systemdId
 	waitCh   chan *cstructs.WaitResult
 	doneCh   chan struct{}
 	executor *executor.SystemdExecutor
+	journal  *systemdJournal
+}
+
+// systemdId is the serialized handle of a task.
+type systemdId struct {
+	Version   string
+	Unit      string
+	StartedAt time.Time
+	LogConfig *structs.LogConfig
 }
 
 func NewSystemdDriver(ctx *DriverContext) Driver {
@@ -53,62 +76,143 @@ func (d *SystemdDriver) Fingerprint(cfg *config.Config, node *structs.Node) (boo
 	return true, nil
 }
 
+// systemdUnitName returns the name of the transient unit of the task of the
+// allocation.
+func systemdUnitName(allocID, task string) string {
+	return fmt.Sprintf("nomad-%s-%s.service", allocID, systemdInvalidUnitChars.ReplaceAllString(task, "_"))
+}
+
 func (d *SystemdDriver) Start(ctx *ExecContext, task *structs.Task) (DriverHandle, error) {
 	var config SystemdDriverConfig
 	if err := mapstructure.WeakDecode(task.Config, &config); err != nil {
 		d.logger.Printf("[ERROR] Failed to decode systemd driver config. Error: %s\n", err)
 		return nil, err
 	}
-	h := &systemdHandle{
-		logger:   d.logger,
-		doneCh:   make(chan struct{}),
-		waitCh:   make(chan *cstructs.WaitResult, 1),
-		executor: executor.NewSystemdExecutor(ctx.AllocID, config.Command, d.logger),
+	if config.Command == "" {
+		return nil, fmt.Errorf("missing command for systemd driver")
+	}
+	taskDir, ok := ctx.AllocDir.TaskDirs[task.Name]
+	if !ok {
+		return nil, fmt.Errorf("Could not find task directory for task: %v", task.Name)
 	}
 
-	if err := h.executor.Limit(task.Resources); err != nil {
+	// The command used to be split on spaces and still is without args
+	d.taskEnv.Build()
+	command := strings.Fields(d.taskEnv.ReplaceEnv(config.Command))
+	if len(config.Args) != 0 {
+		command = append([]string{d.taskEnv.ReplaceEnv(config.Command)}, d.taskEnv.ParseAndReplace(config.Args)...)
+	}
+
+	id := &systemdId{
+		Version:   d.config.Version,
+		Unit:      systemdUnitName(ctx.AllocID, task.Name),
+		StartedAt: time.Now(),
+		LogConfig: task.LogConfig,
+	}
+	exec := executor.NewSystemdExecutor(id.Unit, command, d.logger)
+	exec.SetEnv(d.taskEnv.EnvList())
+	exec.SetWorkingDirectory(taskDir)
+	if config.User != "" {
+		exec.SetUser(config.User)
+	}
+	killSignal := syscall.SIGTERM
+	if task.KillSignal != "" {
+		sig, err := signals.Parse(task.KillSignal)
+		if err != nil {
+			return nil, err
+		}
+		if s, ok := sig.(syscall.Signal); ok {
+			killSignal = s
+		}
+	}
+	exec.SetKill(killSignal, d.KillTimeout(task))
+
+	cpuFrequency, _ := strconv.ParseFloat(d.node.Attributes["cpu.frequency"], 64)
+	if err := exec.Limit(task.Resources, cpuFrequency); err != nil {
 		d.logger.Printf("[WARN] Failed to set resource constraints %s", err)
 		return nil, err
 	}
 
-	if err := h.executor.Start(); err != nil {
+	// The journal is copied from when the unit starts
+	cursorPath := filepath.Join(taskDir, systemdCursorFile)
+	if err := removeSystemdCursor(cursorPath); err != nil {
+		return nil, err
+	}
+	journal, err := followUnitJournal(ctx, task.Name, id.Unit, id.StartedAt, id.LogConfig, d.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := exec.Start(); err != nil {
 		d.logger.Printf("[WARN] Failed to start systemd executor %s", err)
+		journal.Stop()
 		return nil, err
 	}
+	h := &systemdHandle{
+		logger:   d.logger,
+		id:       id,
+		doneCh:   make(chan struct{}),
+		waitCh:   make(chan *cstructs.WaitResult, 1),
+		executor: exec,
+		journal:  journal,
+	}
 	go h.run()
 	return h, nil
 }
 
 func (h *systemdHandle) run() {
 	waitResult := h.executor.Wait()
+	if err := h.journal.Stop(); err != nil {
+		h.logger.Printf("[ERR] driver.systemd: failed to copy the journal of unit %s: %v", h.id.Unit, err)
+	}
 	close(h.doneCh)
 	h.waitCh <- waitResult
 	close(h.waitCh)
 }
 
-func (d *SystemdDriver) Open(ctx *ExecContext, _ string) (DriverHandle, error) {
+func (d *SystemdDriver) Open(ctx *ExecContext, handleID string) (DriverHandle, error) {
+	id := &systemdId{}
+	if err := json.Unmarshal([]byte(handleID), id); err != nil {
+		return nil, fmt.Errorf("Failed to parse handle '%s': %v", handleID, err)
+	}
+	d.logger.Printf("[DEBUG] driver.systemd: reattaching to unit %s", id.Unit)
+	journal, err := followUnitJournal(ctx, d.DriverContext.taskName, id.Unit, id.StartedAt, id.LogConfig, d.logger)
+	if err != nil {
+		return nil, err
+	}
 	h := &systemdHandle{
 		logger:   d.logger,
+		id:       id,
 		doneCh:   make(chan struct{}),
 		waitCh:   make(chan *cstructs.WaitResult, 1),
-		executor: executor.NewSystemdExecutor(ctx.AllocID, "", d.logger),
+		executor: executor.NewSystemdExecutor(id.Unit, nil, d.logger),
+		journal:  journal,
 	}
+	go h.run()
 	return h, nil
 }
 
 func (h *systemdHandle) ID() string {
-	return fmt.Sprintf("systemd:%s", 1)
+	data, err := json.Marshal(h.id)
+	if err != nil {
+		h.logger.Printf("[ERR] driver.systemd: failed to marshal ID to JSON: %s", err)
+	}
+	return string(data)
 }
 
 func (h *systemdHandle) WaitCh() chan *cstructs.WaitResult {
 	return h.waitCh
 }
 
+func (h *systemdHandle) Signal(s os.Signal) error {
+	return h.executor.Signal(s)
+}
+
 func (h *systemdHandle) Kill() error {
 	return h.executor.Shutdown()
 }
 
 func (h *systemdHandle) Update(task *structs.Task) error {
-	h.logger.Printf("[WARN] Update is not supported by lxc driver")
+	h.logger.Printf("[WARN] Update is not supported by systemd driver")
 	return nil
 }
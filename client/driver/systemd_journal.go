@@ -0,0 +1,235 @@
+package driver
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/nomad/client/driver/logging"
+	"github.com/hashicorp/nomad/nomad/structs"
+)
+
+const (
+	// systemdCursorFile is the file in the task directory recording the
+	// cursor of the last journal entry of the unit copied to the logs, so a
+	// restarted client resumes copying after it.
+	systemdCursorFile = "systemd-journal.cursor"
+
+	// systemdCursorSaveInterval is how often the cursor is saved while the
+	// journal is copied.
+	systemdCursorSaveInterval = 1 * time.Second
+)
+
+// followUnitJournal starts copying the journal of the unit of the task to the
+// stdout log of the task, from the saved cursor or since the unit started.
+func followUnitJournal(ctx *ExecContext, taskName, unit string, since time.Time,
+	logConfig *structs.LogConfig, logger *log.Logger) (*systemdJournal, error) {
+	taskDir, ok := ctx.AllocDir.TaskDirs[taskName]
+	if !ok {
+		return nil, fmt.Errorf("Could not find task directory for task: %v", taskName)
+	}
+	if logConfig == nil {
+		logConfig = structs.DefaultLogConfig()
+	}
+	logFileSize := int64(logConfig.MaxFileSizeMB * 1024 * 1024)
+	out, err := logging.NewFileRotator(ctx.AllocDir.LogDir(), fmt.Sprintf("%v.stdout", taskName),
+		logConfig.MaxFiles, logFileSize, logger)
+	if err != nil {
+		return nil, fmt.Errorf("error creating log rotator for stdout of task %v", err)
+	}
+	journal := newSystemdJournal(unit, filepath.Join(taskDir, systemdCursorFile), out, logger)
+	if err := journal.Follow(since); err != nil {
+		out.Close()
+		return nil, err
+	}
+	return journal, nil
+}
+
+// journalctl returns the command reading the journal with the arguments. It is
+// a variable so tests can read a journal without journald.
+var journalctl = func(args ...string) *exec.Cmd {
+	return exec.Command("journalctl", args...)
+}
+
+// systemdJournal copies the journal entries of a unit to the logs of the
+// task. journald doesn't tell stdout and stderr apart so both are written to
+// the stdout log.
+type systemdJournal struct {
+	unit       string
+	cursorPath string
+	out        *logging.FileRotator
+	logger     *log.Logger
+
+	since     time.Time
+	cursor    string
+	lastSaved time.Time
+
+	cmd    *exec.Cmd
+	doneCh chan struct{}
+	lock   sync.Mutex
+}
+
+// newSystemdJournal returns a copier of the journal of the unit to the log.
+func newSystemdJournal(unit, cursorPath string, out *logging.FileRotator, logger *log.Logger) *systemdJournal {
+	return &systemdJournal{
+		unit:       unit,
+		cursorPath: cursorPath,
+		out:        out,
+		logger:     logger,
+	}
+}
+
+// Follow starts copying the entries of the journal as they are written. They
+// are copied from the saved cursor if there is one, or from the time since.
+func (j *systemdJournal) Follow(since time.Time) error {
+	if data, err := ioutil.ReadFile(j.cursorPath); err == nil {
+		j.cursor = string(data)
+	}
+	j.since = since
+	cmd := journalctl(j.args(true)...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to read the journal of unit %s: %v", j.unit, err)
+	}
+	j.cmd = cmd
+	j.doneCh = make(chan struct{})
+	go func() {
+		defer close(j.doneCh)
+		if err := j.copy(stdout); err != nil {
+			j.logger.Printf("[ERR] driver.systemd: failed to copy the journal of unit %s: %v", j.unit, err)
+		}
+		cmd.Wait()
+	}()
+	return nil
+}
+
+// Stop stops following the journal, copies the entries written since the last
+// one copied and closes the log.
+func (j *systemdJournal) Stop() error {
+	defer j.out.Close()
+	if j.cmd != nil {
+		j.cmd.Process.Kill()
+		<-j.doneCh
+	}
+
+	cmd := journalctl(j.args(false)...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to read the journal of unit %s: %v", j.unit, err)
+	}
+	err = j.copy(stdout)
+	cmd.Wait()
+	j.saveCursor()
+	return err
+}
+
+// args returns the arguments of journalctl reading the entries of the unit
+// after the cursor, or since the time it is followed from if there is no
+// cursor.
+func (j *systemdJournal) args(follow bool) []string {
+	args := []string{"--unit", j.unit, "--output", "json", "--lines", "all", "--no-pager"}
+	if j.cursor != "" {
+		args = append(args, "--after-cursor", j.cursor)
+	} else if !j.since.IsZero() {
+		args = append(args, "--since", j.since.Local().Format("2006-01-02 15:04:05"))
+	}
+	if follow {
+		args = append(args, "--follow")
+	}
+	return args
+}
+
+// journalEntry is an entry of the journal as output by journalctl. MESSAGE is
+// a string, or an array of bytes if it isn't valid UTF-8.
+type journalEntry struct {
+	Cursor  string          `json:"__CURSOR"`
+	Message json.RawMessage `json:"MESSAGE"`
+}
+
+// copy writes the messages of the entries read from r to the log, recording
+// the cursor of the last one.
+func (j *systemdJournal) copy(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry journalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return fmt.Errorf("failed to parse journal entry: %v", err)
+		}
+		msg, err := entry.message()
+		if err != nil {
+			return err
+		}
+		if _, err := j.out.Write(append(msg, '\n')); err != nil {
+			return err
+		}
+
+		j.lock.Lock()
+		j.cursor = entry.Cursor
+		if time.Since(j.lastSaved) > systemdCursorSaveInterval {
+			j.saveCursorLocked()
+		}
+		j.lock.Unlock()
+	}
+	return scanner.Err()
+}
+
+// message returns the message of the entry.
+func (e *journalEntry) message() ([]byte, error) {
+	if len(e.Message) == 0 || string(e.Message) == "null" {
+		return nil, nil
+	}
+	var s string
+	if err := json.Unmarshal(e.Message, &s); err == nil {
+		return []byte(s), nil
+	}
+	var ints []int
+	if err := json.Unmarshal(e.Message, &ints); err != nil {
+		return nil, fmt.Errorf("failed to parse journal message %s: %v", e.Message, err)
+	}
+	b := make([]byte, 0, len(ints))
+	for _, i := range ints {
+		b = append(b, byte(i))
+	}
+	return b, nil
+}
+
+// saveCursor writes the cursor of the last entry copied to the cursor file.
+func (j *systemdJournal) saveCursor() {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	j.saveCursorLocked()
+}
+
+func (j *systemdJournal) saveCursorLocked() {
+	j.lastSaved = time.Now()
+	if j.cursor == "" {
+		return
+	}
+	if err := ioutil.WriteFile(j.cursorPath, []byte(j.cursor), 0600); err != nil {
+		j.logger.Printf("[ERR] driver.systemd: failed to save the journal cursor of unit %s: %v", j.unit, err)
+	}
+}
+
+// removeSystemdCursor removes the cursor file so a new run of the task copies
+// its journal from when it starts.
+func removeSystemdCursor(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
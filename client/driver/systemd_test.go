@@ -0,0 +1,108 @@
+package driver
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/nomad/client/driver/logging"
+	"github.com/hashicorp/nomad/nomad/structs"
+)
+
+func TestSystemdDriver_Handle(t *testing.T) {
+	t.Parallel()
+	id := &systemdId{
+		Version:   "0.3.0",
+		Unit:      systemdUnitName("1234", "web"),
+		StartedAt: time.Unix(1456000000, 0).UTC(),
+		LogConfig: structs.DefaultLogConfig(),
+	}
+	h := &systemdHandle{id: id, logger: testLogger()}
+
+	actual := &systemdId{}
+	if err := json.Unmarshal([]byte(h.ID()), actual); err != nil {
+		t.Fatalf("failed to parse handle ID %q: %v", h.ID(), err)
+	}
+	if !reflect.DeepEqual(actual, id) {
+		t.Errorf("Expected: `%#v`, Found: `%#v`", id, actual)
+	}
+}
+
+func TestSystemdDriver_UnitName(t *testing.T) {
+	t.Parallel()
+	if name := systemdUnitName("1234", "web server/1"); name != "nomad-1234-web_server_1.service" {
+		t.Fatalf("bad unit name: %s", name)
+	}
+}
+
+func TestSystemdJournal_Copy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "systemd-journal")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The journal is followed from the start of the unit and read after the
+	// last copied entry once it is stopped
+	var calls [][]string
+	defer func(orig func(...string) *exec.Cmd) { journalctl = orig }(journalctl)
+	journalctl = func(args ...string) *exec.Cmd {
+		calls = append(calls, args)
+		entries := `{"__CURSOR":"c1","MESSAGE":"hello"}
+{"__CURSOR":"c2","MESSAGE":[119,111,114,108,100]}
+`
+		if len(calls) > 1 {
+			entries = `{"__CURSOR":"c3","MESSAGE":"bye"}
+`
+		}
+		return exec.Command("printf", "%s", entries)
+	}
+
+	out, err := logging.NewFileRotator(dir, "web.stdout", 1, 1024*1024, testLogger())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	cursorPath := filepath.Join(dir, systemdCursorFile)
+	j := newSystemdJournal("nomad-1234-web.service", cursorPath, out, testLogger())
+	if err := j.Follow(time.Now()); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	<-j.doneCh
+	if err := j.Stop(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if len(calls) != 2 {
+		t.Fatalf("got %d journalctl calls; want 2", len(calls))
+	}
+	follow := strings.Join(calls[0], " ")
+	if !strings.Contains(follow, "--unit nomad-1234-web.service") || !strings.Contains(follow, "--since") ||
+		!strings.Contains(follow, "--follow") {
+		t.Fatalf("bad follow args: %s", follow)
+	}
+	if drain := strings.Join(calls[1], " "); !strings.Contains(drain, "--after-cursor c2") ||
+		strings.Contains(drain, "--follow") {
+		t.Fatalf("bad drain args: %s", drain)
+	}
+
+	logs, err := ioutil.ReadFile(filepath.Join(dir, "web.stdout.0"))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if string(logs) != "hello\nworld\nbye\n" {
+		t.Fatalf("bad logs: %q", logs)
+	}
+	cursor, err := ioutil.ReadFile(cursorPath)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if string(cursor) != "c3" {
+		t.Fatalf("bad cursor: %q", cursor)
+	}
+}
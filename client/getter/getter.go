@@ -2,16 +2,21 @@ package getter
 
 import (
 	"fmt"
+	"io"
 	"log"
+	"net/http"
 	"net/url"
+	"os"
 	"path"
 	"path/filepath"
 	"runtime"
-	"strings"
 	"sync"
 	"syscall"
+	"time"
 
+	"github.com/hashicorp/go-cleanhttp"
 	gg "github.com/hashicorp/go-getter"
+	"github.com/hashicorp/nomad/nomad/structs"
 )
 
 var (
@@ -22,10 +27,15 @@ var (
 
 	// supported is the set of download schemes supported by Nomad
 	supported = []string{"http", "https", "s3"}
+
+	// headerGetterTimeout bounds file downloads with headers so a stalled
+	// server fails the artifact instead of blocking the task forever.
+	headerGetterTimeout = 30 * time.Minute
 )
 
-// getClient returns a client that is suitable for Nomad.
-func getClient(src, dst string) *gg.Client {
+// getClient returns a client that is suitable for Nomad. If headers are
+// given, they are sent with HTTP file downloads.
+func getClient(src, dst string, dir bool, headers map[string]string) *gg.Client {
 	lock.Lock()
 	defer lock.Unlock()
 
@@ -39,39 +49,115 @@ func getClient(src, dst string) *gg.Client {
 		}
 	}
 
+	clientGetters := getters
+	if len(headers) != 0 {
+		clientGetters = make(map[string]gg.Getter, len(getters))
+		for scheme, impl := range getters {
+			clientGetters[scheme] = impl
+		}
+		hg := &headerGetter{headers: headers}
+		clientGetters["http"] = hg
+		clientGetters["https"] = hg
+	}
+
 	return &gg.Client{
 		Src:     src,
 		Dst:     dst,
-		Dir:     false, // Only support a single file for now.
-		Getters: getters,
+		Dir:     dir,
+		Getters: clientGetters,
 	}
 }
 
-func GetArtifact(destDir, source, checksum string, logger *log.Logger) (string, error) {
-	if source == "" {
+// getGetterUrl returns the go-getter URL to download the artifact.
+func getGetterUrl(artifact *structs.TaskArtifact) (*url.URL, error) {
+	u, err := url.Parse(artifact.GetterSource)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse source URL %q: %v", artifact.GetterSource, err)
+	}
+
+	// Add the options to the query
+	q := u.Query()
+	for k, v := range artifact.GetterOptions {
+		q.Set(k, v)
+	}
+	u.RawQuery = q.Encode()
+	return u, nil
+}
+
+// GetArtifact downloads an artifact into the task directory and returns the
+// path it was downloaded to. In file mode the artifact is stored in the
+// destination directory under its base name, in dir mode the destination
+// directory is the download target.
+func GetArtifact(artifact *structs.TaskArtifact, taskDir string, logger *log.Logger) (string, error) {
+	if artifact.GetterSource == "" {
 		return "", fmt.Errorf("Source url is empty in Artifact Getter")
 	}
-	u, err := url.Parse(source)
+
+	u, err := getGetterUrl(artifact)
 	if err != nil {
 		return "", err
 	}
 
-	// if checksum is seperate, apply to source
-	if checksum != "" {
-		source = strings.Join([]string{source, fmt.Sprintf("checksum=%s", checksum)}, "?")
-		logger.Printf("[DEBUG] client.getter: Applying checksum to Artifact Source URL, new url: %s", source)
+	dest := filepath.Join(taskDir, artifact.RelativeDest)
+	dir := artifact.GetterMode == structs.ArtifactModeDir
+	if !dir {
+		dest = filepath.Join(dest, path.Base(u.Path))
 	}
 
-	artifactFile := filepath.Join(destDir, path.Base(u.Path))
-	if err := getClient(source, artifactFile).Get(); err != nil {
-		return "", fmt.Errorf("Error downloading artifact: %s", err)
+	logger.Printf("[DEBUG] client.getter: downloading artifact %q to %q", artifact.GetterSource, dest)
+	if err := getClient(u.String(), dest, dir, artifact.GetterHeaders).Get(); err != nil {
+		return "", fmt.Errorf("Error downloading artifact %q: %s", artifact.GetterSource, err)
 	}
 
-	// Add execution permissions to the newly downloaded artifact
-	if runtime.GOOS != "windows" {
-		if err := syscall.Chmod(artifactFile, 0755); err != nil {
-			logger.Printf("[ERR] driver.raw_exec: Error making artifact executable: %s", err)
+	// Add execution permissions to the newly downloaded file
+	if !dir && runtime.GOOS != "windows" {
+		if fi, err := os.Stat(dest); err == nil && fi.Mode().IsRegular() {
+			if err := syscall.Chmod(dest, 0755); err != nil {
+				logger.Printf("[ERR] client.getter: Error making artifact executable: %s", err)
+			}
 		}
 	}
-	return artifactFile, nil
+	return dest, nil
+}
+
+// headerGetter is an HTTP getter that sends the configured headers with file
+// downloads. Directory downloads are delegated to the default HTTP getter.
+type headerGetter struct {
+	gg.HttpGetter
+	headers map[string]string
+}
+
+func (g *headerGetter) GetFile(dst string, u *url.URL) error {
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return err
+	}
+	for k, v := range g.headers {
+		req.Header.Set(k, v)
+	}
+
+	client := cleanhttp.DefaultClient()
+	client.Timeout = headerGetterTimeout
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("bad response code: %d", resp.StatusCode)
+	}
+
+	// Create all the parent directories
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(f, resp.Body)
+	return err
 }
@@ -1,111 +1,220 @@
 package getter
 
 import (
-	"fmt"
 	"io/ioutil"
 	"log"
+	"net/http"
+	"net/http/httptest"
 	"os"
-	"strings"
+	"path/filepath"
 	"testing"
+	"time"
+
+	"github.com/hashicorp/nomad/nomad/structs"
 )
 
-func TestGetArtifact_basic(t *testing.T) {
+func testLogger() *log.Logger {
+	return log.New(os.Stderr, "", log.LstdFlags)
+}
 
-	logger := log.New(os.Stderr, "", log.LstdFlags)
+func TestGetArtifact_FileAndChecksum(t *testing.T) {
+	// Create the test server hosting the file to download
+	ts := httptest.NewServer(http.FileServer(http.Dir("test-fixtures")))
+	defer ts.Close()
 
-	// TODO: Use http.TestServer to serve these files from fixtures dir
-	passing := []struct {
-		Source, Checksum string
-	}{
-		{
-			"https://dl.dropboxusercontent.com/u/47675/jar_thing/hi_darwin_amd64",
-			"sha256:66aa0f05fc0cfcf1e5ed8cc5307b5df51e33871d6b295a60e0f9f6dd573da977",
-		},
-		{
-			"https://dl.dropboxusercontent.com/u/47675/jar_thing/hi_linux_amd64",
-			"sha256:6f99b4c5184726e601ecb062500aeb9537862434dfe1898dbe5c68d9f50c179c",
-		},
-		{
-			"https://dl.dropboxusercontent.com/u/47675/jar_thing/hi_linux_amd64",
-			"md5:a9b14903a8942748e4f8474e11f795d3",
-		},
-		{
-			"https://dl.dropboxusercontent.com/u/47675/jar_thing/hi_linux_amd64?checksum=sha256:6f99b4c5184726e601ecb062500aeb9537862434dfe1898dbe5c68d9f50c179c",
-			"",
-		},
-		{
-			"https://dl.dropboxusercontent.com/u/47675/jar_thing/hi_linux_amd64",
-			"",
+	// Create a temp directory to download into
+	taskDir, err := ioutil.TempDir("", "nomad-test")
+	if err != nil {
+		t.Fatalf("failed to make temp directory: %v", err)
+	}
+	defer os.RemoveAll(taskDir)
+
+	// Create the artifact
+	file := "test.sh"
+	artifact := &structs.TaskArtifact{
+		GetterSource: ts.URL + "/" + file,
+		GetterOptions: map[string]string{
+			"checksum": "md5:326644a17e488ff910c8d15ab14f1714",
 		},
+		RelativeDest: "local",
 	}
 
-	for i, p := range passing {
-		destDir, err := ioutil.TempDir("", fmt.Sprintf("nomad-test-%d", i))
-		if err != nil {
-			t.Fatalf("Error in TestGetArtifact_basic makeing TempDir: %s", err)
-		}
+	// Download the artifact
+	path, err := GetArtifact(artifact, taskDir, testLogger())
+	if err != nil {
+		t.Fatalf("GetArtifact failed: %v", err)
+	}
+
+	// Verify the artifact exists
+	if exp := filepath.Join(taskDir, "local", file); path != exp {
+		t.Fatalf("bad path: got %q; want %q", path, exp)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("file not found: %v", err)
+	}
+	if fi.Mode().Perm() != 0755 {
+		t.Fatalf("artifact not executable: %v", fi.Mode())
+	}
+}
 
-		path, err := GetArtifact(destDir, p.Source, p.Checksum, logger)
-		if err != nil {
-			t.Fatalf("TestGetArtifact_basic unexpected failure here: %s", err)
+func TestGetArtifact_Headers(t *testing.T) {
+	// Create the test server requiring a header
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Nomad-Token") != "secret" {
+			w.WriteHeader(http.StatusForbidden)
+			return
 		}
+		w.Write([]byte("hello"))
+	}))
+	defer ts.Close()
+
+	taskDir, err := ioutil.TempDir("", "nomad-test")
+	if err != nil {
+		t.Fatalf("failed to make temp directory: %v", err)
+	}
+	defer os.RemoveAll(taskDir)
+
+	// Fails without the header
+	artifact := &structs.TaskArtifact{
+		GetterSource: ts.URL + "/file",
+	}
+	if _, err := GetArtifact(artifact, taskDir, testLogger()); err == nil {
+		t.Fatalf("GetArtifact should have failed")
+	}
+
+	// Succeeds with the header
+	artifact.GetterHeaders = map[string]string{"X-Nomad-Token": "secret"}
+	path, err := GetArtifact(artifact, taskDir, testLogger())
+	if err != nil {
+		t.Fatalf("GetArtifact failed: %v", err)
+	}
+	out, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if string(out) != "hello" {
+		t.Fatalf("bad: %q", out)
+	}
+}
+
+func TestGetArtifact_HeadersTimeout(t *testing.T) {
+	// Create the test server that never responds
+	stall := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-stall
+	}))
+	defer ts.Close()
+	defer close(stall)
+
+	taskDir, err := ioutil.TempDir("", "nomad-test")
+	if err != nil {
+		t.Fatalf("failed to make temp directory: %v", err)
+	}
+	defer os.RemoveAll(taskDir)
+
+	timeout := headerGetterTimeout
+	headerGetterTimeout = 100 * time.Millisecond
+	defer func() { headerGetterTimeout = timeout }()
 
-		if p.Checksum != "" {
-			if ok := strings.Contains(path, p.Checksum); ok {
-				t.Fatalf("path result should not contain the checksum, got: %s", path)
-			}
+	artifact := &structs.TaskArtifact{
+		GetterSource:  ts.URL + "/file",
+		GetterHeaders: map[string]string{"X-Nomad-Token": "secret"},
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := GetArtifact(artifact, taskDir, testLogger())
+		errCh <- err
+	}()
+	select {
+	case err := <-errCh:
+		if err == nil {
+			t.Fatalf("GetArtifact should have failed")
 		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("GetArtifact did not time out")
+	}
+}
 
-		// verify artifact exists
-		if _, err := os.Stat(path); err != nil {
-			t.Fatalf("source path error: %s", err)
+func TestGetArtifact_Archive(t *testing.T) {
+	// Create the test server hosting the archive to download
+	ts := httptest.NewServer(http.FileServer(http.Dir("test-fixtures")))
+	defer ts.Close()
+
+	taskDir, err := ioutil.TempDir("", "nomad-test")
+	if err != nil {
+		t.Fatalf("failed to make temp directory: %v", err)
+	}
+	defer os.RemoveAll(taskDir)
+
+	// Create the artifact which is unpacked into the destination
+	artifact := &structs.TaskArtifact{
+		GetterSource: ts.URL + "/archive.tar.gz",
+		GetterMode:   structs.ArtifactModeDir,
+		RelativeDest: "local/bin",
+	}
+
+	path, err := GetArtifact(artifact, taskDir, testLogger())
+	if err != nil {
+		t.Fatalf("GetArtifact failed: %v", err)
+	}
+	if exp := filepath.Join(taskDir, "local", "bin"); path != exp {
+		t.Fatalf("bad path: got %q; want %q", path, exp)
+	}
+
+	// Verify the unpacked files exist
+	for _, file := range []string{"exit.sh", "hi.sh"} {
+		if _, err := os.Stat(filepath.Join(path, file)); err != nil {
+			t.Fatalf("file %q not found: %v", file, err)
 		}
 	}
 }
 
-func TestGetArtifact_fails(t *testing.T) {
+func TestGetArtifact_InvalidChecksum(t *testing.T) {
+	// Create the test server hosting the file to download
+	ts := httptest.NewServer(http.FileServer(http.Dir("test-fixtures")))
+	defer ts.Close()
 
-	logger := log.New(os.Stderr, "", log.LstdFlags)
+	taskDir, err := ioutil.TempDir("", "nomad-test")
+	if err != nil {
+		t.Fatalf("failed to make temp directory: %v", err)
+	}
+	defer os.RemoveAll(taskDir)
 
-	failing := []struct {
-		Source, Checksum string
-	}{
-		{
-			"",
-			"sha256:66aa0f05fc0cfcf1e5ed8cc5307b5d",
-		},
-		{
-			"/u/47675/jar_thing/hi_darwin_amd64",
-			"sha256:66aa0f05fc0cfcf1e5ed8cc5307b5d",
-		},
-		{
-			"https://dl.dropboxusercontent.com/u/47675/jar_thing/hi_darwin_amd64",
-			"sha256:66aa0f05fc0cfcf1e5ed8cc5307b5d",
-		},
-		{
-			"https://dl.dropboxusercontent.com/u/47675/jar_thing/hi_linux_amd64",
-			"sha257:6f99b4c5184726e601ecb062500aeb9537862434dfe1898dbe5c68d9f50c179c",
-		},
-		// malformed checksum
-		{
-			"https://dl.dropboxusercontent.com/u/47675/jar_thing/hi_linux_amd64",
-			"6f99b4c5184726e601ecb062500aeb9537862434dfe1898dbe5c68d9f50c179c",
-		},
-		// 404
-		{
-			"https://dl.dropboxusercontent.com/u/47675/jar_thing/hi_linux_amd86",
-			"",
+	artifact := &structs.TaskArtifact{
+		GetterSource: ts.URL + "/test.sh",
+		GetterOptions: map[string]string{
+			"checksum": "md5:00000000000000000000000000000000",
 		},
 	}
-	for i, p := range failing {
-		destDir, err := ioutil.TempDir("", fmt.Sprintf("nomad-test-%d", i))
-		if err != nil {
-			t.Fatalf("Error in TestGetArtifact_basic makeing TempDir: %s", err)
-		}
 
-		_, err = GetArtifact(destDir, p.Source, p.Checksum, logger)
-		if err == nil {
-			t.Fatalf("TestGetArtifact_basic expected failure, but got none")
+	if _, err := GetArtifact(artifact, taskDir, testLogger()); err == nil {
+		t.Fatalf("GetArtifact should have failed")
+	}
+}
+
+func TestGetArtifact_Fails(t *testing.T) {
+	// Create the test server
+	ts := httptest.NewServer(http.FileServer(http.Dir("test-fixtures")))
+	defer ts.Close()
+
+	taskDir, err := ioutil.TempDir("", "nomad-test")
+	if err != nil {
+		t.Fatalf("failed to make temp directory: %v", err)
+	}
+	defer os.RemoveAll(taskDir)
+
+	failing := []*structs.TaskArtifact{
+		// Missing source
+		{},
+		// Unsupported scheme
+		{GetterSource: "/test-fixtures/test.sh"},
+		// 404
+		{GetterSource: ts.URL + "/missing.sh"},
+	}
+	for i, artifact := range failing {
+		if _, err := GetArtifact(artifact, taskDir, testLogger()); err == nil {
+			t.Fatalf("case %d: GetArtifact should have failed", i)
 		}
 	}
 }
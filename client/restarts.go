@@ -11,6 +11,14 @@ import (
 // jitter is the percent of jitter added to restart delays.
 const jitter = 0.25
 
+const (
+	ReasonNoRestartsAllowed   = "Policy allows no restarts"
+	ReasonUnnecessary         = "Restart unnecessary as task terminated successfully"
+	ReasonWithinPolicy        = "Restart within policy"
+	ReasonDelay               = "Exceeded allowed attempts, applying a delay"
+	ReasonFailedAfterAttempts = "Exceeded allowed attempts"
+)
+
 func newRestartTracker(policy *structs.RestartPolicy, jobType string) *RestartTracker {
 	onSuccess := true
 	if jobType == structs.JobTypeBatch {
@@ -24,11 +32,23 @@ func newRestartTracker(policy *structs.RestartPolicy, jobType string) *RestartTr
 	}
 }
 
+// newTaskRestartTracker returns the restart tracker of a task. Lifecycle tasks
+// that aren't sidecars run to completion, so like the tasks of batch jobs they
+// aren't restarted once they exit successfully.
+func newTaskRestartTracker(policy *structs.RestartPolicy, jobType string,
+	lifecycle *structs.TaskLifecycleConfig) *RestartTracker {
+	if lifecycle != nil && !lifecycle.Sidecar {
+		jobType = structs.JobTypeBatch
+	}
+	return newRestartTracker(policy, jobType)
+}
+
 type RestartTracker struct {
 	count     int       // Current number of attempts.
 	onSuccess bool      // Whether to restart on successful exit code.
 	startTime time.Time // When the interval began
 	policy    *structs.RestartPolicy
+	reason    string // The reason for the last restart decision.
 	rand      *rand.Rand
 	lock      sync.Mutex
 }
@@ -41,13 +61,15 @@ func (r *RestartTracker) SetPolicy(policy *structs.RestartPolicy) {
 }
 
 // NextRestart takes the exit code from the last attempt and returns whether the
-// task should be restarted and the duration to wait.
+// task should be restarted and the duration to wait. The reason for the
+// decision is available through GetReason.
 func (r *RestartTracker) NextRestart(exitCode int) (bool, time.Duration) {
 	r.lock.Lock()
 	defer r.lock.Unlock()
 
 	// Hot path if no attempts are expected
 	if r.policy.Attempts == 0 {
+		r.reason = ReasonNoRestartsAllowed
 		return false, 0
 	}
 
@@ -57,29 +79,42 @@ func (r *RestartTracker) NextRestart(exitCode int) (bool, time.Duration) {
 	if now.After(end) {
 		r.count = 0
 		r.startTime = now
-		return r.shouldRestart(exitCode), r.jitter()
+		return r.shouldRestart(exitCode, ReasonWithinPolicy), r.jitter()
 	}
 
 	r.count++
 
 	// If we are under the attempts, restart with delay.
 	if r.count <= r.policy.Attempts {
-		return r.shouldRestart(exitCode), r.jitter()
+		return r.shouldRestart(exitCode, ReasonWithinPolicy), r.jitter()
 	}
 
 	// Don't restart since mode is "fail"
 	if r.policy.Mode == structs.RestartPolicyModeFail {
+		r.reason = ReasonFailedAfterAttempts
 		return false, 0
 	}
 
 	// Apply an artifical wait to enter the next interval
-	return r.shouldRestart(exitCode), end.Sub(now)
+	return r.shouldRestart(exitCode, ReasonDelay), end.Sub(now)
+}
+
+// GetReason returns a human-readable reason for the last restart decision.
+func (r *RestartTracker) GetReason() string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.reason
 }
 
 // shouldRestart returns whether a restart should occur based on the exit code
-// and job type.
-func (r *RestartTracker) shouldRestart(exitCode int) bool {
-	return exitCode != 0 || r.onSuccess
+// and job type, and records the reason for it.
+func (r *RestartTracker) shouldRestart(exitCode int, reason string) bool {
+	if exitCode != 0 || r.onSuccess {
+		r.reason = reason
+		return true
+	}
+	r.reason = ReasonUnnecessary
+	return false
 }
 
 // jitter returns the delay time plus a jitter.
@@ -46,6 +46,9 @@ func TestClient_RestartTracker_ModeDelay(t *testing.T) {
 		if !(when > p.Delay && when <= p.Interval) {
 			t.Fatalf("NextRestart() returned %v; want > %v and <= %v", when, p.Delay, p.Interval)
 		}
+		if reason := rt.GetReason(); reason != ReasonDelay {
+			t.Fatalf("GetReason() returned %q; want %q", reason, ReasonDelay)
+		}
 	}
 }
 
@@ -67,6 +70,9 @@ func TestClient_RestartTracker_ModeFail(t *testing.T) {
 	if actual, _ := rt.NextRestart(127); actual {
 		t.Fail()
 	}
+	if reason := rt.GetReason(); reason != ReasonFailedAfterAttempts {
+		t.Fatalf("GetReason() returned %q; want %q", reason, ReasonFailedAfterAttempts)
+	}
 }
 
 func TestClient_RestartTracker_NoRestartOnSuccess(t *testing.T) {
@@ -76,6 +82,9 @@ func TestClient_RestartTracker_NoRestartOnSuccess(t *testing.T) {
 	if shouldRestart, _ := rt.NextRestart(0); shouldRestart {
 		t.Fatalf("NextRestart() returned %v, expected: %v", shouldRestart, false)
 	}
+	if reason := rt.GetReason(); reason != ReasonUnnecessary {
+		t.Fatalf("GetReason() returned %q; want %q", reason, ReasonUnnecessary)
+	}
 }
 
 func TestClient_RestartTracker_ZeroAttempts(t *testing.T) {
@@ -86,4 +95,29 @@ func TestClient_RestartTracker_ZeroAttempts(t *testing.T) {
 	if actual, when := rt.NextRestart(1); actual {
 		t.Fatalf("expect no restart, got restart/delay: %v", when)
 	}
+	if reason := rt.GetReason(); reason != ReasonNoRestartsAllowed {
+		t.Fatalf("GetReason() returned %q; want %q", reason, ReasonNoRestartsAllowed)
+	}
+}
+
+func TestClient_RestartTracker_Lifecycle(t *testing.T) {
+	t.Parallel()
+	p := testPolicy(true, structs.RestartPolicyModeDelay)
+
+	// Tasks running to completion aren't restarted on success
+	prestart := &structs.TaskLifecycleConfig{Hook: structs.TaskLifecycleHookPrestart}
+	rt := newTaskRestartTracker(p, structs.JobTypeService, prestart)
+	if shouldRestart, _ := rt.NextRestart(0); shouldRestart {
+		t.Fatalf("NextRestart() returned %v, expected: %v", shouldRestart, false)
+	}
+	if shouldRestart, _ := rt.NextRestart(1); !shouldRestart {
+		t.Fatalf("NextRestart() returned %v, expected: %v", shouldRestart, true)
+	}
+
+	// Sidecars are restarted like the main tasks
+	sidecar := &structs.TaskLifecycleConfig{Hook: structs.TaskLifecycleHookPrestart, Sidecar: true}
+	rt = newTaskRestartTracker(p, structs.JobTypeService, sidecar)
+	if shouldRestart, _ := rt.NextRestart(0); !shouldRestart {
+		t.Fatalf("NextRestart() returned %v, expected: %v", shouldRestart, true)
+	}
 }
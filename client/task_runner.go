@@ -13,6 +13,8 @@ import (
 	"github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/nomad/client/config"
 	"github.com/hashicorp/nomad/client/driver"
+	"github.com/hashicorp/nomad/client/getter"
+	"github.com/hashicorp/nomad/helper/signals"
 	"github.com/hashicorp/nomad/nomad/structs"
 	"github.com/mitchellh/hashstructure"
 
@@ -48,6 +50,18 @@ type TaskRunner struct {
 	handle     driver.DriverHandle
 	handleLock sync.Mutex
 
+	// artifactsDownloaded tracks whether the tasks artifacts have been
+	// downloaded
+	artifactsDownloaded bool
+
+	// templateManager renders the task's templates and re-renders them when
+	// the keys they read change
+	templateManager *TaskTemplateManager
+
+	// restartCh is used to restart the task without counting the restart
+	// against the restart policy
+	restartCh chan *structs.TaskEvent
+
 	destroy     bool
 	destroyCh   chan struct{}
 	destroyLock sync.Mutex
@@ -56,9 +70,10 @@ type TaskRunner struct {
 
 // taskRunnerState is used to snapshot the state of the task runner
 type taskRunnerState struct {
-	Version  string
-	Task     *structs.Task
-	HandleID string
+	Version             string
+	Task                *structs.Task
+	HandleID            string
+	ArtifactsDownloaded bool
 }
 
 // TaskStateUpdater is used to signal that tasks state has changed.
@@ -79,7 +94,11 @@ func NewTaskRunner(logger *log.Logger, config *config.Config,
 		logger.Printf("[ERR] client: alloc '%s' for missing task group '%s'", alloc.ID, alloc.TaskGroup)
 		return nil
 	}
-	restartTracker := newRestartTracker(tg.RestartPolicy, alloc.Job.Type)
+	var lifecycle *structs.TaskLifecycleConfig
+	if t := tg.LookupTask(task.Name); t != nil {
+		lifecycle = t.Lifecycle
+	}
+	restartTracker := newTaskRestartTracker(tg.RestartPolicy, alloc.Job.Type, lifecycle)
 
 	tc := &TaskRunner{
 		config:         config,
@@ -91,6 +110,7 @@ func NewTaskRunner(logger *log.Logger, config *config.Config,
 		alloc:          alloc,
 		task:           task,
 		updateCh:       make(chan *structs.Allocation, 8),
+		restartCh:      make(chan *structs.TaskEvent, 1),
 		destroyCh:      make(chan struct{}),
 		waitCh:         make(chan struct{}),
 	}
@@ -107,15 +127,18 @@ func (r *TaskRunner) WaitCh() <-chan struct{} {
 
 // stateFilePath returns the path to our state file
 func (r *TaskRunner) stateFilePath() string {
+	return taskRunnerStateFilePath(r.config.StateDir, r.alloc.ID, r.task.Name)
+}
+
+// taskRunnerStateFilePath returns the path to the state file of a task.
+func taskRunnerStateFilePath(stateDir, allocID, taskName string) string {
 	// Get the MD5 of the task name
-	hashVal := md5.Sum([]byte(r.task.Name))
+	hashVal := md5.Sum([]byte(taskName))
 	hashHex := hex.EncodeToString(hashVal[:])
 	dirName := fmt.Sprintf("task-%s", hashHex)
 
 	// Generate the path
-	path := filepath.Join(r.config.StateDir, "alloc", r.alloc.ID,
-		dirName, "state.json")
-	return path
+	return filepath.Join(stateDir, "alloc", allocID, dirName, "state.json")
 }
 
 // RestoreState is used to restore our state
@@ -128,6 +151,7 @@ func (r *TaskRunner) RestoreState() error {
 
 	// Restore fields
 	r.task = snap.Task
+	r.artifactsDownloaded = snap.ArtifactsDownloaded
 
 	// Restore the driver
 	if snap.HandleID != "" {
@@ -154,8 +178,9 @@ func (r *TaskRunner) RestoreState() error {
 // SaveState is used to snapshot our state
 func (r *TaskRunner) SaveState() error {
 	snap := taskRunnerState{
-		Task:    r.task,
-		Version: r.config.Version,
+		Task:                r.task,
+		Version:             r.config.Version,
+		ArtifactsDownloaded: r.artifactsDownloaded,
 	}
 	r.handleLock.Lock()
 	if r.handle != nil {
@@ -192,7 +217,15 @@ func (r *TaskRunner) createDriver() (driver.Driver, error) {
 
 	}
 
-	driverCtx := driver.NewDriverContext(r.task.Name, r.config, r.config.Node, r.logger, taskEnv)
+	mounts, err := r.hostVolumeMounts()
+	if err != nil {
+		err = fmt.Errorf("failed to create driver '%s' for alloc %s: %v",
+			r.task.Driver, r.alloc.ID, err)
+		r.logger.Printf("[ERR] client: %s", err)
+		return nil, err
+	}
+
+	driverCtx := driver.NewDriverContext(r.task.Name, r.config, r.config.Node, r.logger, taskEnv, mounts, r.emitDriverEvent)
 	driver, err := driver.NewDriver(r.task.Driver, driverCtx)
 	if err != nil {
 		err = fmt.Errorf("failed to create driver '%s' for alloc %s: %v",
@@ -203,8 +236,123 @@ func (r *TaskRunner) createDriver() (driver.Driver, error) {
 	return driver, err
 }
 
+// emitDriverEvent records an event the driver reports while the task runs.
+func (r *TaskRunner) emitDriverEvent(event *structs.TaskEvent) {
+	r.setState(structs.TaskStateRunning, event)
+}
+
+// hostVolumeMounts resolves the volume mounts of the task to the host volumes
+// of the node. A mount is read only if the host volume, the group's volume
+// request or the mount itself is read only.
+func (r *TaskRunner) hostVolumeMounts() ([]*cstructs.MountConfig, error) {
+	if len(r.task.VolumeMounts) == 0 {
+		return nil, nil
+	}
+
+	tg := r.alloc.Job.LookupTaskGroup(r.alloc.TaskGroup)
+	if tg == nil {
+		return nil, fmt.Errorf("task group %q not found", r.alloc.TaskGroup)
+	}
+
+	mounts := make([]*cstructs.MountConfig, 0, len(r.task.VolumeMounts))
+	for _, m := range r.task.VolumeMounts {
+		req, ok := tg.Volumes[m.Volume]
+		if !ok {
+			return nil, fmt.Errorf("volume %q is not defined by the task group", m.Volume)
+		}
+		hv, ok := r.config.Node.HostVolumes[req.Source]
+		if !ok {
+			return nil, fmt.Errorf("host volume %q is not available on the node", req.Source)
+		}
+		mounts = append(mounts, &cstructs.MountConfig{
+			HostPath: hv.Path,
+			TaskPath: m.Destination,
+			ReadOnly: hv.ReadOnly || req.ReadOnly || m.ReadOnly,
+		})
+	}
+	return mounts, nil
+}
+
+// downloadArtifacts downloads the artifacts of the task into the task
+// directory. The artifacts are only downloaded once, so restarts of the task
+// reuse them.
+func (r *TaskRunner) downloadArtifacts() error {
+	if r.artifactsDownloaded || len(r.task.Artifacts) == 0 {
+		return nil
+	}
+
+	r.setState(structs.TaskStatePending, structs.NewTaskEvent(structs.TaskDownloadingArtifacts))
+	taskDir, ok := r.ctx.AllocDir.TaskDirs[r.task.Name]
+	if !ok {
+		return fmt.Errorf("could not find task directory for task: %v", r.task.Name)
+	}
+
+	for _, artifact := range r.task.Artifacts {
+		if _, err := getter.GetArtifact(artifact, taskDir, r.logger); err != nil {
+			return err
+		}
+	}
+
+	r.artifactsDownloaded = true
+	return nil
+}
+
+// renderTemplates renders the templates of the task into the task directory
+// and starts watching them for changes. The templates are only set up once,
+// so restarts of the task reuse them.
+func (r *TaskRunner) renderTemplates() error {
+	if r.templateManager != nil || len(r.task.Templates) == 0 {
+		return nil
+	}
+
+	taskDir, ok := r.ctx.AllocDir.TaskDirs[r.task.Name]
+	if !ok {
+		return fmt.Errorf("could not find task directory for task: %v", r.task.Name)
+	}
+
+	taskEnv, err := driver.GetTaskEnv(r.ctx.AllocDir, r.config.Node, r.task)
+	if err != nil {
+		return err
+	}
+
+	var kv KVSource
+	if r.consulService != nil {
+		kv = r.consulService
+	}
+
+	tm, err := NewTaskTemplateManager(r, r.task.Templates, taskDir, taskEnv, kv, r.logger)
+	if err != nil {
+		return err
+	}
+	if err := tm.Render(); err != nil {
+		return err
+	}
+
+	r.templateManager = tm
+	go tm.Run()
+	return nil
+}
+
 // startTask is used to start the task if there is no handle
 func (r *TaskRunner) startTask() error {
+	// Download the task's artifacts before starting the driver
+	if err := r.downloadArtifacts(); err != nil {
+		r.logger.Printf("[ERR] client: failed to download artifacts of task '%s' for alloc '%s': %v",
+			r.task.Name, r.alloc.ID, err)
+		e := structs.NewTaskEvent(structs.TaskArtifactDownloadFailed).SetDownloadError(err)
+		r.setState(structs.TaskStateDead, e)
+		return err
+	}
+
+	// Render the task's templates before starting the driver
+	if err := r.renderTemplates(); err != nil {
+		r.logger.Printf("[ERR] client: failed to render templates of task '%s' for alloc '%s': %v",
+			r.task.Name, r.alloc.ID, err)
+		e := structs.NewTaskEvent(structs.TaskSetupFailure).SetSetupError(err)
+		r.setState(structs.TaskStateDead, e)
+		return err
+	}
+
 	// Create a driver
 	driver, err := r.createDriver()
 	if err != nil {
@@ -236,7 +384,23 @@ func (r *TaskRunner) Run() {
 	r.logger.Printf("[DEBUG] client: starting task context for '%s' (alloc '%s')",
 		r.task.Name, r.alloc.ID)
 
+	// Watch the templates of a task whose handle was restored
+	r.handleLock.Lock()
+	restored := r.handle != nil
+	r.handleLock.Unlock()
+	if restored {
+		if err := r.renderTemplates(); err != nil {
+			r.logger.Printf("[ERR] client: failed to render templates of task '%s' for alloc '%s': %v",
+				r.task.Name, r.alloc.ID, err)
+		}
+	}
+
 	r.run()
+
+	// Stop watching the templates once the task is done
+	if r.templateManager != nil {
+		r.templateManager.Stop()
+	}
 	return
 }
 
@@ -258,6 +422,7 @@ func (r *TaskRunner) run() {
 		var waitRes *cstructs.WaitResult
 		var destroyErr error
 		destroyed := false
+		restarting := false
 
 		// Register the services defined by the task with Consil
 		r.consulService.Register(r.task, r.alloc)
@@ -272,7 +437,38 @@ func (r *TaskRunner) run() {
 				if err := r.handleUpdate(update); err != nil {
 					r.logger.Printf("[ERR] client: update to task %q failed: %v", r.task.Name, err)
 				}
+			case event := <-r.restartCh:
+				r.logger.Printf("[DEBUG] client: restarting task %q for alloc %q: %s",
+					r.task.Name, r.alloc.ID, event.RestartReason)
+				r.setState(structs.TaskStateRunning, event)
+
+				// Stop the task gracefully and kill it if it doesn't exit
+				// before starting it again.
+				if waitRes = r.shutdownTask(); waitRes != nil {
+					restarting = true
+					break OUTER
+				}
+				restartSuccess, err := r.handleDestroy()
+				if !restartSuccess {
+					r.logger.Printf("[ERR] client: failed to kill task %q for restart. Resources may have been leaked: %v", r.task.Name, err)
+					destroyed = true
+					destroyErr = err
+				} else {
+					select {
+					case waitRes = <-r.handle.WaitCh():
+					case <-time.After(3 * time.Second):
+					}
+				}
+
+				restarting = true
+				break OUTER
 			case <-r.destroyCh:
+				// Stop the task gracefully
+				if waitRes = r.shutdownTask(); waitRes != nil {
+					destroyed = true
+					break OUTER
+				}
+
 				// Kill the task using an exponential backoff in-case of failures.
 				destroySuccess, err := r.handleDestroy()
 				if !destroySuccess {
@@ -293,8 +489,11 @@ func (r *TaskRunner) run() {
 			}
 		}
 
-		// De-Register the services belonging to the task from consul
-		r.consulService.Deregister(r.task, r.alloc)
+		// De-Register the services belonging to the task from consul if it
+		// exited on its own. Stopped tasks were deregistered by shutdownTask.
+		if !destroyed && !restarting {
+			r.consulService.Deregister(r.task, r.alloc)
+		}
 
 		// If the user destroyed the task, we do not attempt to do any restarts.
 		if destroyed {
@@ -302,6 +501,22 @@ func (r *TaskRunner) run() {
 			return
 		}
 
+		// Restart requests bypass the restart policy and start the task
+		// again immediately.
+		if restarting {
+			forceStart = true
+			continue
+		}
+
+		// Record that the task was killed for exceeding its memory limit.
+		if waitRes.OOMKilled {
+			e := structs.NewTaskEvent(structs.TaskMemoryExceeded)
+			if r.task.Resources != nil {
+				e.SetMemoryLimit(int64(r.task.Resources.MemoryMB))
+			}
+			r.setState(structs.TaskStateRunning, e)
+		}
+
 		// Log whether the task was successful or not.
 		if !waitRes.Successful() {
 			r.logger.Printf("[ERR] client: failed to complete task '%s' for alloc '%s': %v", r.task.Name, r.alloc.ID, waitRes)
@@ -311,16 +526,21 @@ func (r *TaskRunner) run() {
 
 		// Check if we should restart. If not mark task as dead and exit.
 		shouldRestart, when := r.restartTracker.NextRestart(waitRes.ExitCode)
+		reason := r.restartTracker.GetReason()
 		waitEvent := r.waitErrorToEvent(waitRes)
 		if !shouldRestart {
-			r.logger.Printf("[INFO] client: Not restarting task: %v for alloc: %v ", r.task.Name, r.alloc.ID)
+			r.logger.Printf("[INFO] client: Not restarting task: %v for alloc: %v: %s", r.task.Name, r.alloc.ID, reason)
 			r.setState(structs.TaskStateDead, waitEvent)
+			r.setState(structs.TaskStateDead,
+				structs.NewTaskEvent(structs.TaskNotRestarting).SetRestartReason(reason))
 			return
 		}
 
 		r.logger.Printf("[INFO] client: Restarting Task: %v", r.task.Name)
 		r.logger.Printf("[DEBUG] client: Sleeping for %v before restarting Task %v", when, r.task.Name)
 		r.setState(structs.TaskStatePending, waitEvent)
+		r.setState(structs.TaskStatePending,
+			structs.NewTaskEvent(structs.TaskRestarting).SetRestartDelay(when).SetRestartReason(reason))
 
 		// Sleep but watch for destroy events.
 		select {
@@ -403,6 +623,69 @@ func (r *TaskRunner) handleUpdate(update *structs.Allocation) error {
 	return mErr.ErrorOrNil()
 }
 
+// shutdownTask deregisters the services of the task so that no new requests
+// are routed to it, waits for the shutdown delay of the task and sends it its
+// kill signal if it has one, killing it if it doesn't exit within the kill
+// timeout. It returns the wait result of the task if it exited in the
+// meantime, or nil if its handle needs to be killed.
+func (r *TaskRunner) shutdownTask() *cstructs.WaitResult {
+	// Give the task the kill timeout to exit
+	timeout := r.task.KillTimeout
+	if max := r.config.MaxKillTimeout; max > 0 && timeout > max {
+		timeout = max
+	}
+	r.setState(structs.TaskStateRunning, structs.NewTaskEvent(structs.TaskKilling).SetKillTimeout(timeout))
+
+	r.consulService.Deregister(r.task, r.alloc)
+
+	if delay := r.task.ShutdownDelay; delay > 0 {
+		r.logger.Printf("[DEBUG] client: waiting %v before stopping task %q (alloc %q)",
+			delay, r.task.Name, r.alloc.ID)
+		select {
+		case res := <-r.handle.WaitCh():
+			return res
+		case <-time.After(delay):
+		}
+	}
+
+	if r.task.KillSignal == "" {
+		return nil
+	}
+	sig, err := signals.Parse(r.task.KillSignal)
+	if err != nil {
+		r.logger.Printf("[ERR] client: invalid kill signal of task %q (alloc %q): %v",
+			r.task.Name, r.alloc.ID, err)
+		return nil
+	}
+	if err := r.handle.Signal(sig); err != nil {
+		r.logger.Printf("[ERR] client: failed to send %v to task %q (alloc %q): %v",
+			sig, r.task.Name, r.alloc.ID, err)
+		return nil
+	}
+
+	select {
+	case res := <-r.handle.WaitCh():
+		return res
+	case <-time.After(timeout):
+	}
+
+	// The task ignored its kill signal, kill it right away rather than giving
+	// it the kill timeout again. Killing the handle is left as a fallback.
+	r.logger.Printf("[DEBUG] client: task %q (alloc %q) didn't exit on %v within %v, killing it",
+		r.task.Name, r.alloc.ID, sig, timeout)
+	if err := r.handle.Signal(os.Kill); err != nil {
+		r.logger.Printf("[ERR] client: failed to kill task %q (alloc %q): %v",
+			r.task.Name, r.alloc.ID, err)
+		return nil
+	}
+	select {
+	case res := <-r.handle.WaitCh():
+		return res
+	case <-time.After(3 * time.Second):
+		return nil
+	}
+}
+
 // handleDestroy kills the task handle. In the case that killing fails,
 // handleDestroy will retry with an exponential backoff and will give up at a
 // given limit. It returns whether the task was destroyed and the error
@@ -436,6 +719,57 @@ func (r *TaskRunner) waitErrorToEvent(res *cstructs.WaitResult) *structs.TaskEve
 		SetExitMessage(res.Err)
 }
 
+// Restart requests the task to be restarted. The restart is not counted
+// against the restart policy of the task.
+func (r *TaskRunner) Restart(source, reason string) {
+	reasonStr := fmt.Sprintf("%s: %s", source, reason)
+	event := structs.NewTaskEvent(structs.TaskRestartSignal).SetRestartReason(reasonStr)
+
+	select {
+	case r.restartCh <- event:
+	default:
+		r.logger.Printf("[DEBUG] client: restart of task %q (alloc %q) already pending, dropping: %s",
+			r.task.Name, r.alloc.ID, reasonStr)
+	}
+}
+
+// Signal sends a signal to the task.
+func (r *TaskRunner) Signal(source, reason string, s os.Signal) error {
+	reasonStr := fmt.Sprintf("%s: %s", source, reason)
+
+	r.handleLock.Lock()
+	handle := r.handle
+	r.handleLock.Unlock()
+	if handle == nil {
+		return fmt.Errorf("task %q is not running", r.task.Name)
+	}
+
+	r.logger.Printf("[DEBUG] client: sending %v to task %q (alloc %q): %s", s, r.task.Name, r.alloc.ID, reasonStr)
+	event := structs.NewTaskEvent(structs.TaskSignaling).SetTaskSignal(s).SetTaskSignalReason(reasonStr)
+	r.setState(structs.TaskStateRunning, event)
+	return handle.Signal(s)
+}
+
+// Exec runs a command inside the isolation context of the task and returns
+// its exit code.
+func (r *TaskRunner) Exec(opts *cstructs.ExecOptions) (int, error) {
+	r.handleLock.Lock()
+	handle := r.handle
+	r.handleLock.Unlock()
+	if handle == nil {
+		return -1, fmt.Errorf("task %q is not running", r.task.Name)
+	}
+
+	execer, ok := handle.(driver.ExecHandle)
+	if !ok {
+		return -1, fmt.Errorf("driver %q does not support exec", r.task.Driver)
+	}
+
+	r.logger.Printf("[DEBUG] client: running exec command %q in task %q (alloc %q)",
+		opts.Command, r.task.Name, r.alloc.ID)
+	return execer.Exec(opts)
+}
+
 // Update is used to update the task of the context
 func (r *TaskRunner) Update(update *structs.Allocation) {
 	select {
@@ -3,8 +3,12 @@ package client
 import (
 	"fmt"
 	"log"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -14,6 +18,7 @@ import (
 	"github.com/hashicorp/nomad/nomad/structs"
 	"github.com/hashicorp/nomad/testutil"
 
+	cstructs "github.com/hashicorp/nomad/client/driver/structs"
 	ctestutil "github.com/hashicorp/nomad/client/testutil"
 )
 
@@ -68,8 +73,8 @@ func TestTaskRunner_SimpleRun(t *testing.T) {
 		t.Fatalf("timeout")
 	}
 
-	if len(upd.events) != 3 {
-		t.Fatalf("should have 3 updates: %#v", upd.events)
+	if len(upd.events) != 4 {
+		t.Fatalf("should have 4 updates: %#v", upd.events)
 	}
 
 	if upd.state != structs.TaskStateDead {
@@ -87,6 +92,14 @@ func TestTaskRunner_SimpleRun(t *testing.T) {
 	if upd.events[2].Type != structs.TaskTerminated {
 		t.Fatalf("Third Event was %v; want %v", upd.events[2].Type, structs.TaskTerminated)
 	}
+
+	if upd.events[3].Type != structs.TaskNotRestarting {
+		t.Fatalf("Fourth Event was %v; want %v", upd.events[3].Type, structs.TaskNotRestarting)
+	}
+
+	if upd.events[3].RestartReason != ReasonNoRestartsAllowed {
+		t.Fatalf("Fourth Event has reason %q; want %q", upd.events[3].RestartReason, ReasonNoRestartsAllowed)
+	}
 }
 
 func TestTaskRunner_Destroy(t *testing.T) {
@@ -111,8 +124,8 @@ func TestTaskRunner_Destroy(t *testing.T) {
 		t.Fatalf("timeout")
 	}
 
-	if len(upd.events) != 3 {
-		t.Fatalf("should have 3 updates: %#v", upd.events)
+	if len(upd.events) != 4 {
+		t.Fatalf("should have 4 updates: %#v", upd.events)
 	}
 
 	if upd.state != structs.TaskStateDead {
@@ -127,12 +140,54 @@ func TestTaskRunner_Destroy(t *testing.T) {
 		t.Fatalf("Second Event was %v; want %v", upd.events[1].Type, structs.TaskStarted)
 	}
 
-	if upd.events[2].Type != structs.TaskKilled {
-		t.Fatalf("Third Event was %v; want %v", upd.events[2].Type, structs.TaskKilled)
+	if upd.events[2].Type != structs.TaskKilling {
+		t.Fatalf("Third Event was %v; want %v", upd.events[2].Type, structs.TaskKilling)
+	}
+
+	if upd.events[3].Type != structs.TaskKilled {
+		t.Fatalf("Fourth Event was %v; want %v", upd.events[3].Type, structs.TaskKilled)
 	}
 
 }
 
+func TestTaskRunner_Restarting(t *testing.T) {
+	ctestutil.ExecCompatible(t)
+	upd, tr := testTaskRunner(true)
+	defer tr.ctx.AllocDir.Destroy()
+
+	// The task fails and is restarted after the delay of the policy
+	tr.task.Config["command"] = "/bin/sh"
+	tr.task.Config["args"] = []string{"-c", "exit 1"}
+	go tr.Run()
+	defer tr.Destroy()
+
+	testutil.WaitForResult(func() (bool, error) {
+		if l := len(upd.events); l != 4 {
+			return false, fmt.Errorf("Expect four events; got %v", l)
+		}
+		return true, nil
+	}, func(err error) {
+		t.Fatalf("err: %v", err)
+	})
+
+	if upd.state != structs.TaskStatePending {
+		t.Fatalf("TaskState %v; want %v", upd.state, structs.TaskStatePending)
+	}
+	if upd.events[2].Type != structs.TaskTerminated || upd.events[2].ExitCode != 1 {
+		t.Fatalf("Third Event was %#v; want %v", upd.events[2], structs.TaskTerminated)
+	}
+	event := upd.events[3]
+	if event.Type != structs.TaskRestarting {
+		t.Fatalf("Fourth Event was %v; want %v", event.Type, structs.TaskRestarting)
+	}
+	if event.StartDelay < int64(tr.alloc.Job.TaskGroups[0].RestartPolicy.Delay) {
+		t.Fatalf("bad restart delay: %v", time.Duration(event.StartDelay))
+	}
+	if event.RestartReason != ReasonWithinPolicy {
+		t.Fatalf("bad restart reason: %q", event.RestartReason)
+	}
+}
+
 func TestTaskRunner_Update(t *testing.T) {
 	ctestutil.ExecCompatible(t)
 	_, tr := testTaskRunner(false)
@@ -220,3 +275,313 @@ func TestTaskRunner_SaveRestoreState(t *testing.T) {
 		t.Fatalf("err: %v", err)
 	})
 }
+
+func TestTaskRunner_DownloadArtifacts(t *testing.T) {
+	ctestutil.ExecCompatible(t)
+
+	// Create the test server hosting the artifacts
+	ts := httptest.NewServer(http.FileServer(http.Dir("./getter/test-fixtures")))
+	defer ts.Close()
+
+	upd, tr := testTaskRunner(false)
+	tr.task.Artifacts = []*structs.TaskArtifact{
+		{
+			GetterSource: ts.URL + "/test.sh",
+			RelativeDest: "local",
+		},
+		{
+			GetterSource: ts.URL + "/archive.tar.gz",
+			GetterMode:   structs.ArtifactModeDir,
+			RelativeDest: "local/bin",
+		},
+	}
+	go tr.Run()
+	defer tr.Destroy()
+	defer tr.ctx.AllocDir.Destroy()
+
+	select {
+	case <-tr.WaitCh():
+	case <-time.After(time.Duration(testutil.TestMultiplier()*15) * time.Second):
+		t.Fatalf("timeout")
+	}
+
+	if len(upd.events) != 5 {
+		t.Fatalf("should have 5 updates: %#v", upd.events)
+	}
+
+	if upd.state != structs.TaskStateDead {
+		t.Fatalf("TaskState %v; want %v", upd.state, structs.TaskStateDead)
+	}
+
+	if upd.events[1].Type != structs.TaskDownloadingArtifacts {
+		t.Fatalf("Second Event was %v; want %v", upd.events[1].Type, structs.TaskDownloadingArtifacts)
+	}
+
+	if upd.events[2].Type != structs.TaskStarted {
+		t.Fatalf("Third Event was %v; want %v", upd.events[2].Type, structs.TaskStarted)
+	}
+
+	// Check that the artifacts were downloaded into the task directory
+	taskDir := tr.ctx.AllocDir.TaskDirs[tr.task.Name]
+	for _, path := range []string{"local/test.sh", "local/bin/exit.sh", "local/bin/hi.sh"} {
+		if _, err := os.Stat(filepath.Join(taskDir, path)); err != nil {
+			t.Fatalf("artifact %q not downloaded: %v", path, err)
+		}
+	}
+}
+
+func TestTaskRunner_DownloadArtifacts_Failure(t *testing.T) {
+	// Create the test server without the artifact
+	ts := httptest.NewServer(http.FileServer(http.Dir("./getter/test-fixtures")))
+	defer ts.Close()
+
+	upd, tr := testTaskRunner(false)
+	tr.task.Artifacts = []*structs.TaskArtifact{
+		{
+			GetterSource: ts.URL + "/missing.sh",
+		},
+	}
+	go tr.Run()
+	defer tr.Destroy()
+	defer tr.ctx.AllocDir.Destroy()
+
+	select {
+	case <-tr.WaitCh():
+	case <-time.After(time.Duration(testutil.TestMultiplier()*15) * time.Second):
+		t.Fatalf("timeout")
+	}
+
+	if len(upd.events) != 3 {
+		t.Fatalf("should have 3 updates: %#v", upd.events)
+	}
+
+	if upd.state != structs.TaskStateDead {
+		t.Fatalf("TaskState %v; want %v", upd.state, structs.TaskStateDead)
+	}
+
+	if upd.events[1].Type != structs.TaskDownloadingArtifacts {
+		t.Fatalf("Second Event was %v; want %v", upd.events[1].Type, structs.TaskDownloadingArtifacts)
+	}
+
+	if upd.events[2].Type != structs.TaskArtifactDownloadFailed {
+		t.Fatalf("Third Event was %v; want %v", upd.events[2].Type, structs.TaskArtifactDownloadFailed)
+	}
+
+	if upd.events[2].DownloadError == "" {
+		t.Fatalf("missing download error: %#v", upd.events[2])
+	}
+}
+
+func TestTaskRunner_Template_Failure(t *testing.T) {
+	// Create a Consul stand-in without the key the template reads
+	_, ts := newConsulKVStandIn()
+	defer ts.Close()
+
+	upd, tr := testTaskRunner(false)
+	tr.consulService = testKVService(t, ts.Listener.Addr().String())
+	tr.task.Templates = []*structs.Template{
+		{
+			EmbeddedTmpl: `{{ key "missing" }}`,
+			DestPath:     "local/foo.conf",
+		},
+	}
+	go tr.Run()
+	defer tr.Destroy()
+	defer tr.ctx.AllocDir.Destroy()
+
+	select {
+	case <-tr.WaitCh():
+	case <-time.After(time.Duration(testutil.TestMultiplier()*15) * time.Second):
+		t.Fatalf("timeout")
+	}
+
+	if len(upd.events) != 2 {
+		t.Fatalf("should have 2 updates: %#v", upd.events)
+	}
+
+	if upd.state != structs.TaskStateDead {
+		t.Fatalf("TaskState %v; want %v", upd.state, structs.TaskStateDead)
+	}
+
+	if upd.events[1].Type != structs.TaskSetupFailure {
+		t.Fatalf("Second Event was %v; want %v", upd.events[1].Type, structs.TaskSetupFailure)
+	}
+
+	if !strings.Contains(upd.events[1].SetupError, "does not exist") {
+		t.Fatalf("bad setup error: %#v", upd.events[1])
+	}
+}
+
+func TestTaskRunner_HostVolumeMounts(t *testing.T) {
+	_, tr := testTaskRunner(false)
+	defer tr.ctx.AllocDir.Destroy()
+
+	tr.config.Node = mock.Node()
+	tr.config.Node.HostVolumes = map[string]*structs.ClientHostVolumeConfig{
+		"certs": &structs.ClientHostVolumeConfig{Name: "certs", Path: "/etc/ssl/certs", ReadOnly: true},
+		"data":  &structs.ClientHostVolumeConfig{Name: "data", Path: "/srv/data"},
+	}
+	tg := tr.alloc.Job.LookupTaskGroup(tr.alloc.TaskGroup)
+	tg.Volumes = map[string]*structs.VolumeRequest{
+		"certs": &structs.VolumeRequest{Name: "certs", Type: structs.VolumeTypeHost, Source: "certs"},
+		"data":  &structs.VolumeRequest{Name: "data", Type: structs.VolumeTypeHost, Source: "data"},
+	}
+	tr.task.VolumeMounts = []*structs.VolumeMount{
+		{Volume: "certs", Destination: "/etc/ssl/certs"},
+		{Volume: "data", Destination: "/data"},
+	}
+
+	mounts, err := tr.hostVolumeMounts()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	expected := []*cstructs.MountConfig{
+		{HostPath: "/etc/ssl/certs", TaskPath: "/etc/ssl/certs", ReadOnly: true},
+		{HostPath: "/srv/data", TaskPath: "/data"},
+	}
+	if !reflect.DeepEqual(mounts, expected) {
+		t.Fatalf("got %#v; want %#v", mounts, expected)
+	}
+
+	// Volumes missing on the node fail the task
+	delete(tr.config.Node.HostVolumes, "data")
+	if _, err := tr.hostVolumeMounts(); err == nil || !strings.Contains(err.Error(), "not available") {
+		t.Fatalf("expected missing host volume error, got: %v", err)
+	}
+}
+
+func TestTaskRunner_KillSignal(t *testing.T) {
+	ctestutil.ExecCompatible(t)
+	upd, tr := testTaskRunner(false)
+	defer tr.ctx.AllocDir.Destroy()
+
+	// The task ignores the default interrupt and exits on the kill signal
+	tr.task.Config["command"] = "/bin/sh"
+	tr.task.Config["args"] = []string{"-c", "trap '' INT; trap 'exit 0' TERM; while true; do sleep 0.1; done"}
+	tr.task.KillSignal = "SIGTERM"
+	tr.task.KillTimeout = 10 * time.Second
+	go tr.Run()
+
+	testutil.WaitForResult(func() (bool, error) {
+		if l := len(upd.events); l != 2 {
+			return false, fmt.Errorf("Expect two events; got %v", l)
+		}
+		return true, nil
+	}, func(err error) {
+		t.Fatalf("err: %v", err)
+	})
+
+	start := time.Now()
+	tr.Destroy()
+	select {
+	case <-tr.WaitCh():
+	case <-time.After(time.Duration(testutil.TestMultiplier()*15) * time.Second):
+		t.Fatalf("timeout")
+	}
+
+	// The task exited on the signal rather than being killed after the kill
+	// timeout
+	if elapsed := time.Since(start); elapsed >= tr.task.KillTimeout {
+		t.Fatalf("task took %v to stop", elapsed)
+	}
+	if upd.state != structs.TaskStateDead {
+		t.Fatalf("TaskState %v; want %v", upd.state, structs.TaskStateDead)
+	}
+	if last := upd.events[len(upd.events)-1]; last.Type != structs.TaskKilled {
+		t.Fatalf("Last event was %v; want %v", last.Type, structs.TaskKilled)
+	}
+}
+
+func TestTaskRunner_KillSignal_Ignored(t *testing.T) {
+	ctestutil.ExecCompatible(t)
+	upd, tr := testTaskRunner(false)
+	defer tr.ctx.AllocDir.Destroy()
+
+	// The task ignores both the default interrupt and the kill signal
+	tr.task.Config["command"] = "/bin/sh"
+	tr.task.Config["args"] = []string{"-c", "trap '' INT TERM; while true; do sleep 0.1; done"}
+	tr.task.KillSignal = "SIGTERM"
+	tr.task.KillTimeout = 2 * time.Second
+	tr.config.MaxKillTimeout = 10 * time.Second
+	go tr.Run()
+
+	testutil.WaitForResult(func() (bool, error) {
+		if l := len(upd.events); l != 2 {
+			return false, fmt.Errorf("Expect two events; got %v", l)
+		}
+		return true, nil
+	}, func(err error) {
+		t.Fatalf("err: %v", err)
+	})
+
+	start := time.Now()
+	tr.Destroy()
+	select {
+	case <-tr.WaitCh():
+	case <-time.After(time.Duration(testutil.TestMultiplier()*15) * time.Second):
+		t.Fatalf("timeout")
+	}
+
+	// The task is killed once the kill timeout passes, rather than being
+	// sent the default interrupt and given the kill timeout again
+	if elapsed := time.Since(start); elapsed < tr.task.KillTimeout || elapsed >= 2*tr.task.KillTimeout {
+		t.Fatalf("task took %v to stop", elapsed)
+	}
+	if upd.state != structs.TaskStateDead {
+		t.Fatalf("TaskState %v; want %v", upd.state, structs.TaskStateDead)
+	}
+	if last := upd.events[len(upd.events)-1]; last.Type != structs.TaskKilled {
+		t.Fatalf("Last event was %v; want %v", last.Type, structs.TaskKilled)
+	}
+}
+
+func TestTaskRunner_ShutdownDelay(t *testing.T) {
+	ctestutil.ExecCompatible(t)
+	upd, tr := testTaskRunner(false)
+	defer tr.ctx.AllocDir.Destroy()
+
+	tr.task.Config["command"] = "/bin/sleep"
+	tr.task.Config["args"] = []string{"10"}
+	tr.task.ShutdownDelay = 1 * time.Second
+	go tr.Run()
+
+	testutil.WaitForResult(func() (bool, error) {
+		if l := len(upd.events); l != 2 {
+			return false, fmt.Errorf("Expect two events; got %v", l)
+		}
+		return true, nil
+	}, func(err error) {
+		t.Fatalf("err: %v", err)
+	})
+
+	start := time.Now()
+	tr.Destroy()
+
+	// The services are deregistered before the task is stopped
+	key := fmt.Sprintf("%s-%s", tr.alloc.ID, tr.task.Name)
+	testutil.WaitForResult(func() (bool, error) {
+		tr.consulService.trackedTskLock.Lock()
+		_, ok := tr.consulService.trackedTasks[key]
+		tr.consulService.trackedTskLock.Unlock()
+		if ok {
+			return false, fmt.Errorf("task is still registered")
+		}
+		return true, nil
+	}, func(err error) {
+		t.Fatalf("err: %v", err)
+	})
+	select {
+	case <-tr.WaitCh():
+		t.Fatalf("task stopped before the shutdown delay")
+	default:
+	}
+
+	select {
+	case <-tr.WaitCh():
+	case <-time.After(time.Duration(testutil.TestMultiplier()*15) * time.Second):
+		t.Fatalf("timeout")
+	}
+	if elapsed := time.Since(start); elapsed < tr.task.ShutdownDelay {
+		t.Fatalf("task stopped after %v; want at least %v", elapsed, tr.task.ShutdownDelay)
+	}
+}
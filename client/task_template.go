@@ -0,0 +1,394 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/hashicorp/nomad/client/driver/env"
+	"github.com/hashicorp/nomad/helper/signals"
+	"github.com/hashicorp/nomad/nomad/structs"
+)
+
+const (
+	// kvRetryInterval is the time to wait before retrying a failed watch of
+	// a key.
+	kvRetryInterval = 5 * time.Second
+)
+
+// TemplateHooks is the set of hooks the TaskTemplateManager uses to act on
+// the task when a re-rendered template requires it.
+type TemplateHooks interface {
+	// Restart is used to restart the task.
+	Restart(source, reason string)
+
+	// Signal is used to send a signal to the task.
+	Signal(source, reason string, s os.Signal) error
+}
+
+// KVSource is a key/value store templates can read keys from. Keys are
+// watched for changes using blocking queries.
+type KVSource interface {
+	// KVGet returns the value of the key, whether it exists and the index it
+	// was read at. If waitIndex is non-zero the call blocks until the index
+	// moves past waitIndex or the wait time of the source elapses.
+	KVGet(key string, waitIndex uint64) (string, bool, uint64, error)
+}
+
+// kvValue is a cached value of a key.
+type kvValue struct {
+	value  string
+	exists bool
+	index  uint64
+}
+
+// TaskTemplateManager renders the templates of a task into the task directory
+// and re-renders them when the keys they read from the KVSource change.
+type TaskTemplateManager struct {
+	hooks     TemplateHooks
+	templates []*structs.Template
+	taskDir   string
+	taskEnv   *env.TaskEnvironment
+	kv        KVSource
+	logger    *log.Logger
+
+	// deps is the set of keys each template read while rendering.
+	deps map[*structs.Template]map[string]struct{}
+
+	// watched is the set of keys being watched.
+	watched map[string]struct{}
+
+	// values is the cache of the keys read from the KVSource.
+	values     map[string]*kvValue
+	valuesLock sync.Mutex
+
+	changeCh chan string
+
+	shutdown     bool
+	shutdownCh   chan struct{}
+	shutdownLock sync.Mutex
+}
+
+// NewTaskTemplateManager returns a TaskTemplateManager for the given
+// templates. The KVSource may be nil if no template reads keys.
+func NewTaskTemplateManager(hooks TemplateHooks, templates []*structs.Template,
+	taskDir string, taskEnv *env.TaskEnvironment, kv KVSource,
+	logger *log.Logger) (*TaskTemplateManager, error) {
+
+	if hooks == nil {
+		return nil, fmt.Errorf("Invalid task hooks given")
+	}
+	if taskDir == "" {
+		return nil, fmt.Errorf("Invalid task directory given")
+	}
+	if taskEnv == nil {
+		return nil, fmt.Errorf("Invalid task environment given")
+	}
+
+	return &TaskTemplateManager{
+		hooks:      hooks,
+		templates:  templates,
+		taskDir:    taskDir,
+		taskEnv:    taskEnv,
+		kv:         kv,
+		logger:     logger,
+		deps:       make(map[*structs.Template]map[string]struct{}),
+		watched:    make(map[string]struct{}),
+		values:     make(map[string]*kvValue),
+		changeCh:   make(chan string, 1),
+		shutdownCh: make(chan struct{}),
+	}, nil
+}
+
+// Render renders all the templates. It must be called before Run.
+func (tm *TaskTemplateManager) Render() error {
+	for _, tmpl := range tm.templates {
+		if _, err := tm.render(tmpl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run watches the keys read by the templates and re-renders the templates
+// when they change. It blocks until Stop is called.
+func (tm *TaskTemplateManager) Run() {
+	tm.watchDeps()
+
+	for {
+		select {
+		case <-tm.shutdownCh:
+			return
+		case key := <-tm.changeCh:
+			tm.handleChange(key)
+			tm.watchDeps()
+		}
+	}
+}
+
+// Stop stops watching the keys of the templates.
+func (tm *TaskTemplateManager) Stop() {
+	tm.shutdownLock.Lock()
+	defer tm.shutdownLock.Unlock()
+
+	if tm.shutdown {
+		return
+	}
+	tm.shutdown = true
+	close(tm.shutdownCh)
+}
+
+// handleChange re-renders the templates that read the changed key and
+// applies the change mode of the templates whose output changed.
+func (tm *TaskTemplateManager) handleChange(key string) {
+	var restart bool
+	var splay time.Duration
+	sigs := make(map[string]struct{})
+	for _, tmpl := range tm.templates {
+		if _, ok := tm.deps[tmpl][key]; !ok {
+			continue
+		}
+
+		changed, err := tm.render(tmpl)
+		if err != nil {
+			tm.logger.Printf("[ERR] client: failed to re-render template %q: %v", tmpl.DestPath, err)
+			continue
+		}
+		if !changed {
+			continue
+		}
+
+		switch tmpl.ChangeMode {
+		case structs.TemplateChangeModeRestart:
+			restart = true
+		case structs.TemplateChangeModeSignal:
+			sigs[tmpl.ChangeSignal] = struct{}{}
+		default:
+			continue
+		}
+		if tmpl.Splay > splay {
+			splay = tmpl.Splay
+		}
+	}
+
+	if !restart && len(sigs) == 0 {
+		return
+	}
+
+	// Wait a random time within the splay so that not all tasks rendering
+	// the key act on the change at once.
+	if splay > 0 {
+		select {
+		case <-time.After(randomStagger(splay)):
+		case <-tm.shutdownCh:
+			return
+		}
+	}
+
+	reason := fmt.Sprintf("template re-rendered after key %q changed", key)
+	if restart {
+		tm.hooks.Restart("template", reason)
+		return
+	}
+
+	for name := range sigs {
+		s, err := signals.Parse(name)
+		if err != nil {
+			tm.logger.Printf("[ERR] client: failed to signal task for template change: %v", err)
+			continue
+		}
+		if err := tm.hooks.Signal("template", reason, s); err != nil {
+			tm.logger.Printf("[ERR] client: failed to signal task for template change: %v", err)
+		}
+	}
+}
+
+// watchDeps starts watching the keys read by the templates that aren't
+// watched yet.
+func (tm *TaskTemplateManager) watchDeps() {
+	for _, deps := range tm.deps {
+		for key := range deps {
+			if _, ok := tm.watched[key]; ok {
+				continue
+			}
+			tm.watched[key] = struct{}{}
+
+			tm.valuesLock.Lock()
+			index := tm.values[key].index
+			tm.valuesLock.Unlock()
+			go tm.watchKey(key, index)
+		}
+	}
+}
+
+// watchKey watches a key using blocking queries and notifies the run loop
+// when its value changes.
+func (tm *TaskTemplateManager) watchKey(key string, index uint64) {
+	for {
+		select {
+		case <-tm.shutdownCh:
+			return
+		default:
+		}
+
+		value, exists, newIndex, err := tm.kv.KVGet(key, index)
+		if err != nil {
+			tm.logger.Printf("[ERR] client: failed to watch key %q: %v", key, err)
+			select {
+			case <-time.After(kvRetryInterval):
+				continue
+			case <-tm.shutdownCh:
+				return
+			}
+		}
+
+		// Reset the index if it went backwards, e.g. because the store was
+		// restored from a snapshot.
+		if newIndex < index {
+			index = 0
+			continue
+		}
+		index = newIndex
+
+		tm.valuesLock.Lock()
+		cached := tm.values[key]
+		changed := cached.exists != exists || cached.value != value
+		tm.values[key] = &kvValue{value: value, exists: exists, index: index}
+		tm.valuesLock.Unlock()
+		if !changed {
+			continue
+		}
+
+		select {
+		case tm.changeCh <- key:
+		case <-tm.shutdownCh:
+			return
+		}
+	}
+}
+
+// render renders the template into its destination and returns whether the
+// content of the destination changed.
+func (tm *TaskTemplateManager) render(tmpl *structs.Template) (bool, error) {
+	contents := tmpl.EmbeddedTmpl
+	if tmpl.SourcePath != "" {
+		raw, err := ioutil.ReadFile(filepath.Join(tm.taskDir, tmpl.SourcePath))
+		if err != nil {
+			return false, fmt.Errorf("failed to read template source: %v", err)
+		}
+		contents = string(raw)
+	}
+
+	deps := make(map[string]struct{})
+	t, err := template.New(tmpl.DestPath).Funcs(tm.funcs(deps)).Parse(contents)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse template %q: %v", tmpl.DestPath, err)
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, nil); err != nil {
+		return false, fmt.Errorf("failed to render template %q: %v", tmpl.DestPath, err)
+	}
+	tm.deps[tmpl] = deps
+
+	// Skip writing the destination if it is unchanged
+	dest := filepath.Join(tm.taskDir, tmpl.DestPath)
+	if existing, err := ioutil.ReadFile(dest); err == nil && bytes.Equal(existing, buf.Bytes()) {
+		return false, nil
+	}
+
+	perms := structs.DefaultTemplatePerms
+	if tmpl.Perms != "" {
+		perms = tmpl.Perms
+	}
+	mode, err := strconv.ParseUint(perms, 8, 12)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse permissions %q: %v", perms, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return false, fmt.Errorf("failed to create template destination directory: %v", err)
+	}
+	if err := ioutil.WriteFile(dest, buf.Bytes(), os.FileMode(mode)); err != nil {
+		return false, fmt.Errorf("failed to write template %q: %v", tmpl.DestPath, err)
+	}
+
+	// WriteFile doesn't change the mode of an existing file
+	if err := os.Chmod(dest, os.FileMode(mode)); err != nil {
+		return false, fmt.Errorf("failed to set permissions of template %q: %v", tmpl.DestPath, err)
+	}
+	return true, nil
+}
+
+// funcs returns the functions available to the templates. The keys read by
+// the template are added to deps.
+func (tm *TaskTemplateManager) funcs(deps map[string]struct{}) template.FuncMap {
+	key := func(key string) (string, bool, error) {
+		if tm.kv == nil {
+			return "", false, fmt.Errorf("no key/value source to read key %q from", key)
+		}
+		deps[key] = struct{}{}
+
+		tm.valuesLock.Lock()
+		cached, ok := tm.values[key]
+		tm.valuesLock.Unlock()
+		if ok {
+			return cached.value, cached.exists, nil
+		}
+
+		value, exists, index, err := tm.kv.KVGet(key, 0)
+		if err != nil {
+			return "", false, fmt.Errorf("failed to read key %q: %v", key, err)
+		}
+
+		tm.valuesLock.Lock()
+		tm.values[key] = &kvValue{value: value, exists: exists, index: index}
+		tm.valuesLock.Unlock()
+		return value, exists, nil
+	}
+
+	return template.FuncMap{
+		// env returns the value of an environment variable of the task.
+		"env": func(name string) string {
+			return tm.taskEnv.TaskEnv[name]
+		},
+
+		// node returns a node value, e.g. "node.datacenter",
+		// "attr.kernel.name" or "meta.rack".
+		"node": func(name string) string {
+			return tm.taskEnv.NodeValues[name]
+		},
+
+		// key returns the value of a key and fails if it doesn't exist.
+		"key": func(name string) (string, error) {
+			value, exists, err := key(name)
+			if err != nil {
+				return "", err
+			}
+			if !exists {
+				return "", fmt.Errorf("key %q does not exist", name)
+			}
+			return value, nil
+		},
+
+		// keyOrDefault returns the value of a key or the default if it
+		// doesn't exist.
+		"keyOrDefault": func(name, def string) (string, error) {
+			value, exists, err := key(name)
+			if err != nil {
+				return "", err
+			}
+			if !exists {
+				return def, nil
+			}
+			return value, nil
+		},
+	}
+}
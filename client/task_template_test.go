@@ -0,0 +1,347 @@
+package client
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/nomad/client/driver/env"
+	"github.com/hashicorp/nomad/nomad/mock"
+	"github.com/hashicorp/nomad/nomad/structs"
+)
+
+// mockTemplateHooks records the actions taken by the TaskTemplateManager.
+type mockTemplateHooks struct {
+	restartCh chan string
+	signalCh  chan os.Signal
+}
+
+func newMockTemplateHooks() *mockTemplateHooks {
+	return &mockTemplateHooks{
+		restartCh: make(chan string, 10),
+		signalCh:  make(chan os.Signal, 10),
+	}
+}
+
+func (m *mockTemplateHooks) Restart(source, reason string) {
+	m.restartCh <- reason
+}
+
+func (m *mockTemplateHooks) Signal(source, reason string, s os.Signal) error {
+	m.signalCh <- s
+	return nil
+}
+
+// consulKVStandIn is a local stand-in for the KV endpoint of a Consul agent
+// supporting blocking queries.
+type consulKVStandIn struct {
+	index    uint64
+	values   map[string]string
+	modified map[string]uint64
+	changeCh chan struct{}
+	lock     sync.Mutex
+}
+
+func newConsulKVStandIn() (*consulKVStandIn, *httptest.Server) {
+	kv := &consulKVStandIn{
+		index:    1,
+		values:   make(map[string]string),
+		modified: make(map[string]uint64),
+		changeCh: make(chan struct{}),
+	}
+	return kv, httptest.NewServer(kv)
+}
+
+// Put sets a key and wakes up the blocking queries.
+func (c *consulKVStandIn) Put(key, value string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.index++
+	c.values[key] = value
+	c.modified[key] = c.index
+	close(c.changeCh)
+	c.changeCh = make(chan struct{})
+}
+
+func (c *consulKVStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, "/v1/kv/") {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, "/v1/kv/")
+
+	// Block until the key changes past the index
+	var waitIndex uint64
+	if index := r.URL.Query().Get("index"); index != "" {
+		waitIndex, _ = strconv.ParseUint(index, 10, 64)
+	}
+	timeout := time.After(1 * time.Second)
+	for {
+		c.lock.Lock()
+		modified, changeCh := c.modified[key], c.changeCh
+		c.lock.Unlock()
+		if waitIndex == 0 || modified > waitIndex {
+			break
+		}
+		select {
+		case <-changeCh:
+			continue
+		case <-timeout:
+		}
+		break
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	w.Header().Set("X-Consul-Index", strconv.FormatUint(c.index, 10))
+	value, ok := c.values[key]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode([]map[string]interface{}{{
+		"Key":         key,
+		"Value":       []byte(value),
+		"ModifyIndex": c.modified[key],
+	}})
+}
+
+func testTaskTemplateManager(t *testing.T, templates []*structs.Template, kv KVSource) (*TaskTemplateManager, *mockTemplateHooks, string) {
+	taskDir, err := ioutil.TempDir("", "nomad-test")
+	if err != nil {
+		t.Fatalf("failed to make temp directory: %v", err)
+	}
+
+	node := mock.Node()
+	taskEnv := env.NewTaskEnvironment(node).
+		SetEnvvars(map[string]string{"FOO": "bar"}).
+		Build()
+
+	hooks := newMockTemplateHooks()
+	tm, err := NewTaskTemplateManager(hooks, templates, taskDir, taskEnv, kv, testLogger())
+	if err != nil {
+		t.Fatalf("failed to create template manager: %v", err)
+	}
+	return tm, hooks, taskDir
+}
+
+func testKVService(t *testing.T, addr string) *ConsulService {
+	c, err := NewConsulService(&consulServiceConfig{testLogger(), addr, "", "", false, false, &structs.Node{}})
+	if err != nil {
+		t.Fatalf("failed to create consul service: %v", err)
+	}
+	return c
+}
+
+func waitForContents(t *testing.T, path, contents string) {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		out, err := ioutil.ReadFile(path)
+		if err == nil && string(out) == contents {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("contents of %q: got %q; want %q (err: %v)", path, out, contents, err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestTaskTemplateManager_Render_Embedded(t *testing.T) {
+	tmpl := &structs.Template{
+		EmbeddedTmpl: `foo={{ env "FOO" }} dc={{ node "node.datacenter" }} kernel={{ node "attr.kernel.name" }}`,
+		DestPath:     "local/foo.conf",
+		Perms:        "0600",
+	}
+	tm, _, taskDir := testTaskTemplateManager(t, []*structs.Template{tmpl}, nil)
+	defer os.RemoveAll(taskDir)
+
+	if err := tm.Render(); err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+
+	dest := filepath.Join(taskDir, "local", "foo.conf")
+	out, err := ioutil.ReadFile(dest)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if exp := "foo=bar dc=dc1 kernel=linux"; string(out) != exp {
+		t.Fatalf("got %q; want %q", out, exp)
+	}
+
+	fi, err := os.Stat(dest)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Fatalf("bad permissions: %v", fi.Mode())
+	}
+}
+
+func TestTaskTemplateManager_Render_Source(t *testing.T) {
+	tmpl := &structs.Template{
+		SourcePath: "local/foo.tmpl",
+		DestPath:   "local/foo.conf",
+	}
+	tm, _, taskDir := testTaskTemplateManager(t, []*structs.Template{tmpl}, nil)
+	defer os.RemoveAll(taskDir)
+
+	os.MkdirAll(filepath.Join(taskDir, "local"), 0755)
+	if err := ioutil.WriteFile(filepath.Join(taskDir, tmpl.SourcePath), []byte(`foo={{ env "FOO" }}`), 0644); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if err := tm.Render(); err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	waitForContents(t, filepath.Join(taskDir, tmpl.DestPath), "foo=bar")
+}
+
+func TestTaskTemplateManager_Render_NoKVSource(t *testing.T) {
+	tmpl := &structs.Template{
+		EmbeddedTmpl: `{{ key "foo" }}`,
+		DestPath:     "local/foo.conf",
+	}
+	tm, _, taskDir := testTaskTemplateManager(t, []*structs.Template{tmpl}, nil)
+	defer os.RemoveAll(taskDir)
+
+	if err := tm.Render(); err == nil || !strings.Contains(err.Error(), "no key/value source") {
+		t.Fatalf("expected key/value source error, got: %v", err)
+	}
+}
+
+func TestTaskTemplateManager_Render_MissingKey(t *testing.T) {
+	_, ts := newConsulKVStandIn()
+	defer ts.Close()
+
+	templates := []*structs.Template{
+		{
+			EmbeddedTmpl: `{{ keyOrDefault "foo" "default" }}`,
+			DestPath:     "local/default.conf",
+		},
+		{
+			EmbeddedTmpl: `{{ key "foo" }}`,
+			DestPath:     "local/foo.conf",
+		},
+	}
+	tm, _, taskDir := testTaskTemplateManager(t, templates, testKVService(t, ts.Listener.Addr().String()))
+	defer os.RemoveAll(taskDir)
+
+	if err := tm.Render(); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Fatalf("expected missing key error, got: %v", err)
+	}
+	waitForContents(t, filepath.Join(taskDir, "local", "default.conf"), "default")
+}
+
+func TestTaskTemplateManager_KV_ChangeModes(t *testing.T) {
+	kv, ts := newConsulKVStandIn()
+	defer ts.Close()
+	kv.Put("service/restart", "1")
+	kv.Put("service/signal", "1")
+	kv.Put("service/noop", "1")
+
+	templates := []*structs.Template{
+		{
+			EmbeddedTmpl: `restart={{ key "service/restart" }}`,
+			DestPath:     "local/restart.conf",
+			ChangeMode:   structs.TemplateChangeModeRestart,
+		},
+		{
+			EmbeddedTmpl: `signal={{ key "service/signal" }}`,
+			DestPath:     "local/signal.conf",
+			ChangeMode:   structs.TemplateChangeModeSignal,
+			ChangeSignal: "SIGHUP",
+		},
+		{
+			EmbeddedTmpl: `noop={{ key "service/noop" }}`,
+			DestPath:     "local/noop.conf",
+			ChangeMode:   structs.TemplateChangeModeNoop,
+		},
+	}
+	tm, hooks, taskDir := testTaskTemplateManager(t, templates, testKVService(t, ts.Listener.Addr().String()))
+	defer os.RemoveAll(taskDir)
+
+	if err := tm.Render(); err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	for _, name := range []string{"restart", "signal", "noop"} {
+		waitForContents(t, filepath.Join(taskDir, "local", name+".conf"), name+"=1")
+	}
+
+	go tm.Run()
+	defer tm.Stop()
+
+	// A noop template is re-rendered without acting on the task
+	kv.Put("service/noop", "2")
+	waitForContents(t, filepath.Join(taskDir, "local", "noop.conf"), "noop=2")
+
+	// A signal template signals the task
+	kv.Put("service/signal", "2")
+	waitForContents(t, filepath.Join(taskDir, "local", "signal.conf"), "signal=2")
+	select {
+	case s := <-hooks.signalCh:
+		if s != syscall.SIGHUP {
+			t.Fatalf("bad signal: %v", s)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("task should have been signalled")
+	}
+
+	// A restart template restarts the task
+	kv.Put("service/restart", "2")
+	waitForContents(t, filepath.Join(taskDir, "local", "restart.conf"), "restart=2")
+	select {
+	case reason := <-hooks.restartCh:
+		if !strings.Contains(reason, "service/restart") {
+			t.Fatalf("bad reason: %q", reason)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("task should have been restarted")
+	}
+
+	// Nothing else acted on the task
+	select {
+	case reason := <-hooks.restartCh:
+		t.Fatalf("unexpected restart: %v", reason)
+	case s := <-hooks.signalCh:
+		t.Fatalf("unexpected signal: %v", s)
+	default:
+	}
+}
+
+func TestTaskTemplateManager_KV_Unchanged(t *testing.T) {
+	kv, ts := newConsulKVStandIn()
+	defer ts.Close()
+	kv.Put("foo", "bar")
+
+	tmpl := &structs.Template{
+		EmbeddedTmpl: `{{ key "foo" }}`,
+		DestPath:     "local/foo.conf",
+		ChangeMode:   structs.TemplateChangeModeRestart,
+	}
+	tm, hooks, taskDir := testTaskTemplateManager(t, []*structs.Template{tmpl}, testKVService(t, ts.Listener.Addr().String()))
+	defer os.RemoveAll(taskDir)
+
+	if err := tm.Render(); err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	go tm.Run()
+	defer tm.Stop()
+
+	// Writing the same value doesn't restart the task
+	kv.Put("foo", "bar")
+	select {
+	case reason := <-hooks.restartCh:
+		t.Fatalf("unexpected restart: %v", reason)
+	case <-time.After(500 * time.Millisecond):
+	}
+}
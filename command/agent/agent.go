@@ -101,6 +101,10 @@ func (a *Agent) serverConfig() (*nomad.Config, error) {
 	if len(a.config.Server.EnabledSchedulers) != 0 {
 		conf.EnabledSchedulers = a.config.Server.EnabledSchedulers
 	}
+	if a.config.Server.EvalFairShareKey != "" {
+		conf.EvalFairShareMetaKey = a.config.Server.EvalFairShareKey
+		conf.EvalFairShareWeights = a.config.Server.EvalFairShareWeights
+	}
 
 	// Set up the advertise addrs
 	if addr := a.config.AdvertiseAddrs.Serf; addr != "" {
@@ -194,6 +198,24 @@ func (a *Agent) clientConfig() (*clientconfig.Config, error) {
 	conf.ClientMaxPort = a.config.Client.ClientMaxPort
 	conf.ClientMinPort = a.config.Client.ClientMinPort
 
+	// Setup the host volumes
+	if len(a.config.Client.HostVolumes) > 0 {
+		conf.HostVolumes = make(map[string]*structs.ClientHostVolumeConfig, len(a.config.Client.HostVolumes))
+		for _, hv := range a.config.Client.HostVolumes {
+			if hv.Name == "" || hv.Path == "" {
+				return nil, fmt.Errorf("host volumes must specify a name and a path")
+			}
+			if _, ok := conf.HostVolumes[hv.Name]; ok {
+				return nil, fmt.Errorf("duplicate host volume %q", hv.Name)
+			}
+			conf.HostVolumes[hv.Name] = &structs.ClientHostVolumeConfig{
+				Name:     hv.Name,
+				Path:     hv.Path,
+				ReadOnly: hv.ReadOnly,
+			}
+		}
+	}
+
 	// Setup the node
 	conf.Node = new(structs.Node)
 	conf.Node.Datacenter = a.config.Datacenter
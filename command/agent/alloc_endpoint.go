@@ -1,9 +1,17 @@
 package agent
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
 
+	cstructs "github.com/hashicorp/nomad/client/driver/structs"
+	"github.com/hashicorp/nomad/helper/signals"
+	"github.com/hashicorp/nomad/helper/term"
 	"github.com/hashicorp/nomad/nomad/structs"
 )
 
@@ -53,3 +61,227 @@ func (s *HTTPServer) AllocSpecificRequest(resp http.ResponseWriter, req *http.Re
 	}
 	return out.Alloc, nil
 }
+
+func (s *HTTPServer) ClientAllocRequest(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	path := strings.TrimPrefix(req.URL.Path, "/v1/client/allocation/")
+	switch {
+	case strings.HasSuffix(path, "/snapshot"):
+		allocID := strings.TrimSuffix(path, "/snapshot")
+		return s.allocSnapshot(resp, req, allocID)
+	case strings.HasSuffix(path, "/signal"):
+		allocID := strings.TrimSuffix(path, "/signal")
+		return s.allocSignal(resp, req, allocID)
+	case strings.HasSuffix(path, "/exec"):
+		allocID := strings.TrimSuffix(path, "/exec")
+		return s.allocExec(resp, req, allocID)
+	case strings.HasSuffix(path, "/restart"):
+		allocID := strings.TrimSuffix(path, "/restart")
+		return s.allocRestart(resp, req, allocID)
+	default:
+		return nil, CodedError(404, "resource not found")
+	}
+}
+
+const (
+	// execUpgradeProtocol is the protocol the connection of an exec request
+	// is upgraded to. Both ends then send a stream of JSON encoded
+	// execFrames.
+	execUpgradeProtocol = "nomad-exec"
+)
+
+// execFrame is a message of an exec session. The client sends the input and
+// the terminal size changes and the agent the output and the exit code.
+type execFrame struct {
+	Stdin       []byte     `json:",omitempty"`
+	StdinClosed bool       `json:",omitempty"`
+	TTYSize     *term.Size `json:",omitempty"`
+	Stdout      []byte     `json:",omitempty"`
+	Stderr      []byte     `json:",omitempty"`
+	Exited      bool       `json:",omitempty"`
+	ExitCode    int        `json:",omitempty"`
+	Error       string     `json:",omitempty"`
+}
+
+// execFrameWriter writes the output of an exec session as frames.
+type execFrameWriter struct {
+	enc    *json.Encoder
+	lock   *sync.Mutex
+	stderr bool
+}
+
+func (w *execFrameWriter) Write(p []byte) (int, error) {
+	frame := &execFrame{}
+	data := make([]byte, len(p))
+	copy(data, p)
+	if w.stderr {
+		frame.Stderr = data
+	} else {
+		frame.Stdout = data
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if err := w.enc.Encode(frame); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (s *HTTPServer) allocExec(resp http.ResponseWriter, req *http.Request,
+	allocID string) (interface{}, error) {
+	if req.Method != "PUT" && req.Method != "POST" {
+		return nil, CodedError(405, ErrInvalidMethod)
+	}
+	if s.agent.client == nil {
+		return nil, CodedError(501, ErrInvalidMethod)
+	}
+
+	query := req.URL.Query()
+	task := query.Get("task")
+	if task == "" {
+		return nil, CodedError(400, "missing task")
+	}
+	var command []string
+	if err := json.Unmarshal([]byte(query.Get("command")), &command); err != nil || len(command) == 0 {
+		return nil, CodedError(400, "missing or invalid command")
+	}
+	var tty bool
+	if raw := query.Get("tty"); raw != "" {
+		var err error
+		if tty, err = strconv.ParseBool(raw); err != nil {
+			return nil, CodedError(400, "invalid tty value")
+		}
+	}
+
+	hijacker, ok := resp.(http.Hijacker)
+	if !ok {
+		return nil, CodedError(500, "connection can't be upgraded")
+	}
+	conn, buf, err := hijacker.Hijack()
+	if err != nil {
+		return nil, CodedError(500, err.Error())
+	}
+	defer conn.Close()
+
+	fmt.Fprintf(buf, "HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: %s\r\n\r\n", execUpgradeProtocol)
+	if err := buf.Flush(); err != nil {
+		return nil, nil
+	}
+
+	lock := new(sync.Mutex)
+	enc := json.NewEncoder(conn)
+	stdinR, stdinW := io.Pipe()
+	defer stdinR.Close()
+	resizeCh := make(chan term.Size, 1)
+	doneCh := make(chan struct{})
+	defer close(doneCh)
+
+	// Relay the input and the terminal size changes. The input is closed
+	// when the client goes away.
+	go func() {
+		defer stdinW.Close()
+		dec := json.NewDecoder(buf.Reader)
+		for {
+			var frame execFrame
+			if err := dec.Decode(&frame); err != nil {
+				return
+			}
+			if len(frame.Stdin) != 0 {
+				if _, err := stdinW.Write(frame.Stdin); err != nil {
+					return
+				}
+			}
+			if frame.StdinClosed {
+				stdinW.Close()
+			}
+			if frame.TTYSize != nil {
+				select {
+				case resizeCh <- *frame.TTYSize:
+				case <-doneCh:
+					return
+				}
+			}
+		}
+	}()
+
+	opts := &cstructs.ExecOptions{
+		Command:  command,
+		Tty:      tty,
+		Stdin:    stdinR,
+		Stdout:   &execFrameWriter{enc: enc, lock: lock},
+		Stderr:   &execFrameWriter{enc: enc, lock: lock, stderr: true},
+		ResizeCh: resizeCh,
+	}
+	code, err := s.agent.client.ExecAlloc(allocID, task, opts)
+	result := &execFrame{Exited: true, ExitCode: code}
+	if err != nil {
+		s.logger.Printf("[ERR] http: exec in task %q of alloc %q failed: %v", task, allocID, err)
+		result.Error = err.Error()
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	enc.Encode(result)
+	return nil, nil
+}
+
+func (s *HTTPServer) allocSnapshot(resp http.ResponseWriter, req *http.Request,
+	allocID string) (interface{}, error) {
+	if req.Method != "GET" {
+		return nil, CodedError(405, ErrInvalidMethod)
+	}
+	if s.agent.client == nil {
+		return nil, CodedError(501, ErrInvalidMethod)
+	}
+
+	fs, err := s.agent.client.GetAllocFS(allocID)
+	if err != nil {
+		return nil, CodedError(404, err.Error())
+	}
+
+	// Stream the snapshot of the data dir
+	resp.Header().Set("Content-Type", "application/x-tar")
+	if err := fs.Snapshot(resp); err != nil {
+		s.logger.Printf("[ERR] http: failed to snapshot alloc %q: %v", allocID, err)
+	}
+	return nil, nil
+}
+
+func (s *HTTPServer) allocSignal(resp http.ResponseWriter, req *http.Request,
+	allocID string) (interface{}, error) {
+	if req.Method != "PUT" && req.Method != "POST" {
+		return nil, CodedError(405, ErrInvalidMethod)
+	}
+	if s.agent.client == nil {
+		return nil, CodedError(501, ErrInvalidMethod)
+	}
+
+	name := req.URL.Query().Get("signal")
+	if name == "" {
+		return nil, CodedError(400, "missing signal")
+	}
+	sig, err := signals.Parse(name)
+	if err != nil {
+		return nil, CodedError(400, err.Error())
+	}
+
+	if err := s.agent.client.SignalAlloc(allocID, req.URL.Query().Get("task"), sig); err != nil {
+		return nil, CodedError(400, err.Error())
+	}
+	return nil, nil
+}
+
+func (s *HTTPServer) allocRestart(resp http.ResponseWriter, req *http.Request,
+	allocID string) (interface{}, error) {
+	if req.Method != "PUT" && req.Method != "POST" {
+		return nil, CodedError(405, ErrInvalidMethod)
+	}
+	if s.agent.client == nil {
+		return nil, CodedError(501, ErrInvalidMethod)
+	}
+
+	if err := s.agent.client.RestartAlloc(allocID, req.URL.Query().Get("task")); err != nil {
+		return nil, CodedError(400, err.Error())
+	}
+	return nil, nil
+}
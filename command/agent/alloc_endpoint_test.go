@@ -1,8 +1,12 @@
 package agent
 
 import (
+	"bufio"
+	"encoding/json"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/hashicorp/nomad/nomad/mock"
@@ -146,3 +150,163 @@ func TestHTTP_AllocQuery(t *testing.T) {
 		}
 	})
 }
+
+func TestHTTP_ClientAllocSnapshot(t *testing.T) {
+	httpTest(t, nil, func(s *TestServer) {
+		// Only GET is allowed
+		req, err := http.NewRequest("POST", "/v1/client/allocation/foo/snapshot", nil)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		respW := httptest.NewRecorder()
+		_, err = s.Server.ClientAllocRequest(respW, req)
+		if err == nil || err.(HTTPCodedError).Code() != 405 {
+			t.Fatalf("expected 405 error, got: %v", err)
+		}
+
+		// An unknown allocation isn't found
+		req, err = http.NewRequest("GET", "/v1/client/allocation/foo/snapshot", nil)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		respW = httptest.NewRecorder()
+		_, err = s.Server.ClientAllocRequest(respW, req)
+		if err == nil || err.(HTTPCodedError).Code() != 404 {
+			t.Fatalf("expected 404 error, got: %v", err)
+		}
+	})
+}
+
+func TestHTTP_ClientAllocSignal(t *testing.T) {
+	httpTest(t, nil, func(s *TestServer) {
+		// Only PUT and POST are allowed
+		req, err := http.NewRequest("GET", "/v1/client/allocation/foo/signal?signal=SIGHUP", nil)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		respW := httptest.NewRecorder()
+		_, err = s.Server.ClientAllocRequest(respW, req)
+		if err == nil || err.(HTTPCodedError).Code() != 405 {
+			t.Fatalf("expected 405 error, got: %v", err)
+		}
+
+		// The signal must be valid
+		for _, path := range []string{"/v1/client/allocation/foo/signal", "/v1/client/allocation/foo/signal?signal=SIGFOO"} {
+			req, err = http.NewRequest("POST", path, nil)
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			respW = httptest.NewRecorder()
+			_, err = s.Server.ClientAllocRequest(respW, req)
+			if err == nil || err.(HTTPCodedError).Code() != 400 {
+				t.Fatalf("expected 400 error for %q, got: %v", path, err)
+			}
+		}
+
+		// An unknown allocation can't be signalled
+		req, err = http.NewRequest("POST", "/v1/client/allocation/foo/signal?signal=SIGHUP", nil)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		respW = httptest.NewRecorder()
+		_, err = s.Server.ClientAllocRequest(respW, req)
+		if err == nil || !strings.Contains(err.Error(), "alloc not found") {
+			t.Fatalf("expected alloc not found error, got: %v", err)
+		}
+	})
+}
+
+func TestHTTP_ClientAllocRestart(t *testing.T) {
+	httpTest(t, nil, func(s *TestServer) {
+		// Only PUT and POST are allowed
+		req, err := http.NewRequest("GET", "/v1/client/allocation/foo/restart", nil)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		respW := httptest.NewRecorder()
+		_, err = s.Server.ClientAllocRequest(respW, req)
+		if err == nil || err.(HTTPCodedError).Code() != 405 {
+			t.Fatalf("expected 405 error, got: %v", err)
+		}
+
+		// An unknown allocation can't be restarted
+		req, err = http.NewRequest("PUT", "/v1/client/allocation/foo/restart?task=web", nil)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		respW = httptest.NewRecorder()
+		_, err = s.Server.ClientAllocRequest(respW, req)
+		if err == nil || !strings.Contains(err.Error(), "alloc not found") {
+			t.Fatalf("expected alloc not found error, got: %v", err)
+		}
+	})
+}
+
+func TestHTTP_ClientAllocExec(t *testing.T) {
+	httpTest(t, nil, func(s *TestServer) {
+		// Only PUT and POST are allowed
+		req, err := http.NewRequest("GET", "/v1/client/allocation/foo/exec", nil)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		respW := httptest.NewRecorder()
+		_, err = s.Server.ClientAllocRequest(respW, req)
+		if err == nil || err.(HTTPCodedError).Code() != 405 {
+			t.Fatalf("expected 405 error, got: %v", err)
+		}
+
+		// The task and the command are required
+		for _, path := range []string{
+			"/v1/client/allocation/foo/exec?command=%5B%22ls%22%5D",
+			"/v1/client/allocation/foo/exec?task=web",
+			"/v1/client/allocation/foo/exec?task=web&command=ls",
+			"/v1/client/allocation/foo/exec?task=web&command=%5B%22ls%22%5D&tty=maybe",
+		} {
+			req, err = http.NewRequest("POST", path, nil)
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			respW = httptest.NewRecorder()
+			_, err = s.Server.ClientAllocRequest(respW, req)
+			if err == nil || err.(HTTPCodedError).Code() != 400 {
+				t.Fatalf("expected 400 error for %q, got: %v", path, err)
+			}
+		}
+
+		// The connection is upgraded and the error of an unknown allocation
+		// is sent as the result of the session
+		ts := httptest.NewServer(http.HandlerFunc(s.Server.wrap(s.Server.ClientAllocRequest)))
+		defer ts.Close()
+		conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		defer conn.Close()
+
+		req, err = http.NewRequest("POST", ts.URL+"/v1/client/allocation/foo/exec?task=web&command=%5B%22ls%22%5D", nil)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Upgrade", execUpgradeProtocol)
+		if err := req.Write(conn); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		reader := bufio.NewReader(conn)
+		resp, err := http.ReadResponse(reader, req)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if resp.StatusCode != http.StatusSwitchingProtocols {
+			t.Fatalf("got status %d", resp.StatusCode)
+		}
+
+		var frame execFrame
+		if err := json.NewDecoder(reader).Decode(&frame); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if !frame.Exited || !strings.Contains(frame.Error, "alloc not found") {
+			t.Fatalf("bad frame: %#v", frame)
+		}
+	})
+}
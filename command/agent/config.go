@@ -167,6 +167,21 @@ type ClientConfig struct {
 	// ClientMinPort is the lower range of the ports that the client uses for
 	// communicating with plugin subsystems
 	ClientMinPort uint `hcl:"client_min_port"`
+
+	// HostVolumes are the host directories the client exposes to tasks
+	HostVolumes []*HostVolumeConfig `hcl:"host_volume"`
+}
+
+// HostVolumeConfig is a host directory that can be mounted into tasks
+type HostVolumeConfig struct {
+	// Name is the name tasks request the volume by
+	Name string `hcl:",key"`
+
+	// Path is the path of the directory on the host
+	Path string `hcl:"path"`
+
+	// ReadOnly only allows the volume to be mounted read only
+	ReadOnly bool `hcl:"read_only"`
 }
 
 // ServerConfig is configuration specific to the server mode
@@ -198,6 +213,14 @@ type ServerConfig struct {
 	// NodeGCThreshold contros how "old" a node must be to be collected by GC.
 	NodeGCThreshold string `hcl:"node_gc_threshold"`
 
+	// EvalFairShareKey is the job meta key used to partition evaluations
+	// into tenants that are dequeued fairly by the scheduler workers.
+	EvalFairShareKey string `hcl:"eval_fair_share_key"`
+
+	// EvalFairShareWeights is the relative share of scheduling given to
+	// each tenant. Tenants that are not listed have a weight of one.
+	EvalFairShareWeights map[string]int `hcl:"eval_fair_share_weights"`
+
 	// StartJoin is a list of addresses to attempt to join when the
 	// agent starts. If Serf is unable to communicate with any of these
 	// addresses, then the agent will error and exit.
@@ -463,6 +486,9 @@ func (a *ServerConfig) Merge(b *ServerConfig) *ServerConfig {
 	if b.NodeGCThreshold != "" {
 		result.NodeGCThreshold = b.NodeGCThreshold
 	}
+	if b.EvalFairShareKey != "" {
+		result.EvalFairShareKey = b.EvalFairShareKey
+	}
 	if b.RetryMaxAttempts != 0 {
 		result.RetryMaxAttempts = b.RetryMaxAttempts
 	}
@@ -477,6 +503,18 @@ func (a *ServerConfig) Merge(b *ServerConfig) *ServerConfig {
 	// Add the schedulers
 	result.EnabledSchedulers = append(result.EnabledSchedulers, b.EnabledSchedulers...)
 
+	// Merge the fair share weights
+	if len(b.EvalFairShareWeights) != 0 {
+		weights := make(map[string]int, len(a.EvalFairShareWeights)+len(b.EvalFairShareWeights))
+		for k, v := range a.EvalFairShareWeights {
+			weights[k] = v
+		}
+		for k, v := range b.EvalFairShareWeights {
+			weights[k] = v
+		}
+		result.EvalFairShareWeights = weights
+	}
+
 	// Copy the start join addresses
 	result.StartJoin = make([]string, 0, len(a.StartJoin)+len(b.StartJoin))
 	result.StartJoin = append(result.StartJoin, a.StartJoin...)
@@ -525,6 +563,24 @@ func (a *ClientConfig) Merge(b *ClientConfig) *ClientConfig {
 	// Add the servers
 	result.Servers = append(result.Servers, b.Servers...)
 
+	// Add the host volumes, replacing the ones with the same name
+	if len(b.HostVolumes) > 0 {
+		volumes := make([]*HostVolumeConfig, 0, len(a.HostVolumes)+len(b.HostVolumes))
+		for _, hv := range a.HostVolumes {
+			replaced := false
+			for _, bhv := range b.HostVolumes {
+				if bhv.Name == hv.Name {
+					replaced = true
+					break
+				}
+			}
+			if !replaced {
+				volumes = append(volumes, hv)
+			}
+		}
+		result.HostVolumes = append(volumes, b.HostVolumes...)
+	}
+
 	// Add the options map values
 	if result.Options == nil {
 		result.Options = make(map[string]string)
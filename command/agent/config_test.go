@@ -41,6 +41,9 @@ func TestConfig_Merge(t *testing.T) {
 			},
 			NetworkSpeed:   100,
 			MaxKillTimeout: "20s",
+			HostVolumes: []*HostVolumeConfig{
+				{Name: "certs", Path: "/etc/ssl/certs"},
+			},
 		},
 		Server: &ServerConfig{
 			Enabled:         false,
@@ -108,6 +111,9 @@ func TestConfig_Merge(t *testing.T) {
 			ClientMinPort:  22000,
 			NetworkSpeed:   105,
 			MaxKillTimeout: "50s",
+			HostVolumes: []*HostVolumeConfig{
+				{Name: "certs", Path: "/etc/ssl/certs", ReadOnly: true},
+			},
 		},
 		Server: &ServerConfig{
 			Enabled:           true,
@@ -117,11 +123,15 @@ func TestConfig_Merge(t *testing.T) {
 			NumSchedulers:     2,
 			EnabledSchedulers: []string{structs.JobTypeBatch},
 			NodeGCThreshold:   "12h",
-			RejoinAfterLeave:  true,
-			StartJoin:         []string{"1.1.1.1"},
-			RetryJoin:         []string{"1.1.1.1"},
-			RetryInterval:     "10s",
-			retryInterval:     time.Second * 10,
+			EvalFairShareKey:  "team",
+			EvalFairShareWeights: map[string]int{
+				"web": 2,
+			},
+			RejoinAfterLeave: true,
+			StartJoin:        []string{"1.1.1.1"},
+			RetryJoin:        []string{"1.1.1.1"},
+			RetryInterval:    "10s",
+			retryInterval:    time.Second * 10,
 		},
 		Ports: &Ports{
 			HTTP: 20000,
@@ -423,6 +433,13 @@ func TestConfig_LoadConfigString(t *testing.T) {
 				"baz": "zip",
 			},
 			NetworkSpeed: 100,
+			HostVolumes: []*HostVolumeConfig{
+				{
+					Name:     "certs",
+					Path:     "/etc/ssl/certs",
+					ReadOnly: true,
+				},
+			},
 		},
 		Server: &ServerConfig{
 			Enabled:           true,
@@ -432,11 +449,15 @@ func TestConfig_LoadConfigString(t *testing.T) {
 			NumSchedulers:     2,
 			EnabledSchedulers: []string{"test"},
 			NodeGCThreshold:   "12h",
-			RetryJoin:         []string{"1.1.1.1", "2.2.2.2"},
-			StartJoin:         []string{"1.1.1.1", "2.2.2.2"},
-			RetryInterval:     "15s",
-			RejoinAfterLeave:  true,
-			RetryMaxAttempts:  3,
+			EvalFairShareKey:  "team",
+			EvalFairShareWeights: map[string]int{
+				"web": 3,
+			},
+			RetryJoin:        []string{"1.1.1.1", "2.2.2.2"},
+			StartJoin:        []string{"1.1.1.1", "2.2.2.2"},
+			RetryInterval:    "15s",
+			RejoinAfterLeave: true,
+			RetryMaxAttempts: 3,
 		},
 		Telemetry: &Telemetry{
 			StatsiteAddr:    "127.0.0.1:1234",
@@ -504,6 +525,10 @@ client {
 		baz = "zip"
 	}
 	network_speed = 100
+	host_volume "certs" {
+		path = "/etc/ssl/certs"
+		read_only = true
+	}
 }
 server {
 	enabled = true
@@ -513,6 +538,10 @@ server {
 	num_schedulers = 2
 	enabled_schedulers = ["test"]
 	node_gc_threshold = "12h"
+	eval_fair_share_key = "team"
+	eval_fair_share_weights {
+		web = 3
+	}
 	retry_join = [ "1.1.1.1", "2.2.2.2" ]
 	start_join = [ "1.1.1.1", "2.2.2.2" ]
 	retry_max = 3
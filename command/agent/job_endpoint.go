@@ -48,9 +48,15 @@ func (s *HTTPServer) JobSpecificRequest(resp http.ResponseWriter, req *http.Requ
 	case strings.HasSuffix(path, "/evaluations"):
 		jobName := strings.TrimSuffix(path, "/evaluations")
 		return s.jobEvaluations(resp, req, jobName)
+	case strings.HasSuffix(path, "/scale"):
+		jobName := strings.TrimSuffix(path, "/scale")
+		return s.jobScale(resp, req, jobName)
 	case strings.HasSuffix(path, "/periodic/force"):
 		jobName := strings.TrimSuffix(path, "/periodic/force")
 		return s.periodicForceRequest(resp, req, jobName)
+	case strings.HasSuffix(path, "/periodic/history"):
+		jobName := strings.TrimSuffix(path, "/periodic/history")
+		return s.periodicHistoryRequest(resp, req, jobName)
 	default:
 		return s.jobCRUD(resp, req, path)
 	}
@@ -93,6 +99,87 @@ func (s *HTTPServer) periodicForceRequest(resp http.ResponseWriter, req *http.Re
 	return out, nil
 }
 
+func (s *HTTPServer) periodicHistoryRequest(resp http.ResponseWriter, req *http.Request,
+	jobName string) (interface{}, error) {
+	if req.Method != "GET" {
+		return nil, CodedError(405, ErrInvalidMethod)
+	}
+	args := structs.JobSpecificRequest{
+		JobID: jobName,
+	}
+	if s.parse(resp, req, &args.Region, &args.QueryOptions) {
+		return nil, nil
+	}
+
+	var out structs.PeriodicHistoryResponse
+	if err := s.agent.RPC("Periodic.History", &args, &out); err != nil {
+		return nil, err
+	}
+
+	setMeta(resp, &out.QueryMeta)
+	if out.Launches == nil {
+		out.Launches = make([]*structs.PeriodicLaunchRecord, 0)
+	}
+	return out.Launches, nil
+}
+
+func (s *HTTPServer) jobScale(resp http.ResponseWriter, req *http.Request,
+	jobName string) (interface{}, error) {
+	switch req.Method {
+	case "GET":
+		return s.jobScalingEvents(resp, req, jobName)
+	case "PUT", "POST":
+		return s.jobScaleUpdate(resp, req, jobName)
+	default:
+		return nil, CodedError(405, ErrInvalidMethod)
+	}
+}
+
+func (s *HTTPServer) jobScaleUpdate(resp http.ResponseWriter, req *http.Request,
+	jobName string) (interface{}, error) {
+	var args structs.JobScaleRequest
+	if err := decodeBody(req, &args); err != nil {
+		return nil, CodedError(400, err.Error())
+	}
+	if args.JobID == "" {
+		args.JobID = jobName
+	} else if args.JobID != jobName {
+		return nil, CodedError(400, "Job ID does not match")
+	}
+	if args.TaskGroup == "" {
+		return nil, CodedError(400, "Task group must be specified")
+	}
+	s.parseRegion(req, &args.Region)
+
+	var out structs.JobScaleResponse
+	if err := s.agent.RPC("Job.Scale", &args, &out); err != nil {
+		return nil, err
+	}
+	setIndex(resp, out.Index)
+	return out, nil
+}
+
+func (s *HTTPServer) jobScalingEvents(resp http.ResponseWriter, req *http.Request,
+	jobName string) (interface{}, error) {
+	args := structs.JobSpecificRequest{
+		JobID: jobName,
+	}
+	if s.parse(resp, req, &args.Region, &args.QueryOptions) {
+		return nil, nil
+	}
+
+	var out structs.JobScalingEventsResponse
+	if err := s.agent.RPC("Job.ScalingEvents", &args, &out); err != nil {
+		return nil, err
+	}
+
+	setMeta(resp, &out.QueryMeta)
+	if out.ScalingEvents == nil {
+		out.ScalingEvents = make([]*structs.ScalingEvent, 0)
+	}
+	return out.ScalingEvents, nil
+}
+
 func (s *HTTPServer) jobAllocations(resp http.ResponseWriter, req *http.Request,
 	jobName string) (interface{}, error) {
 	if req.Method != "GET" {
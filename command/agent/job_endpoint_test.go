@@ -390,6 +390,68 @@ func TestHTTP_JobEvaluations(t *testing.T) {
 	})
 }
 
+func TestHTTP_JobScale(t *testing.T) {
+	httpTest(t, nil, func(s *TestServer) {
+		// Create the job
+		job := mock.Job()
+		args := structs.JobRegisterRequest{
+			Job:          job,
+			WriteRequest: structs.WriteRequest{Region: "global"},
+		}
+		var resp structs.JobRegisterResponse
+		if err := s.Agent.RPC("Job.Register", &args, &resp); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+
+		// Make the HTTP request to scale the job
+		scale := structs.JobScaleRequest{
+			TaskGroup: "web",
+			Count:     3,
+			Message:   "manual",
+		}
+		req, err := http.NewRequest("PUT", "/v1/job/"+job.ID+"/scale", encodeReq(scale))
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		respW := httptest.NewRecorder()
+
+		// Make the request
+		obj, err := s.Server.JobSpecificRequest(respW, req)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+
+		// Check the response
+		scaleResp := obj.(structs.JobScaleResponse)
+		if scaleResp.EvalID == "" {
+			t.Fatalf("bad: %v", scaleResp)
+		}
+		if respW.HeaderMap.Get("X-Nomad-Index") == "" {
+			t.Fatalf("missing index")
+		}
+
+		// Query the scaling events
+		req, err = http.NewRequest("GET", "/v1/job/"+job.ID+"/scale", nil)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		respW = httptest.NewRecorder()
+
+		obj, err = s.Server.JobSpecificRequest(respW, req)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+
+		events := obj.([]*structs.ScalingEvent)
+		if len(events) != 1 || events[0].Count != 3 || events[0].Message != "manual" {
+			t.Fatalf("bad: %v", events)
+		}
+		if respW.HeaderMap.Get("X-Nomad-Index") == "" {
+			t.Fatalf("missing index")
+		}
+	})
+}
+
 func TestHTTP_JobAllocations(t *testing.T) {
 	httpTest(t, nil, func(s *TestServer) {
 		// Create the job
@@ -482,3 +544,52 @@ func TestHTTP_PeriodicForce(t *testing.T) {
 		}
 	})
 }
+
+func TestHTTP_PeriodicHistory(t *testing.T) {
+	httpTest(t, nil, func(s *TestServer) {
+		// Create and register a periodic job.
+		job := mock.PeriodicJob()
+		args := structs.JobRegisterRequest{
+			Job:          job,
+			WriteRequest: structs.WriteRequest{Region: "global"},
+		}
+		var resp structs.JobRegisterResponse
+		if err := s.Agent.RPC("Job.Register", &args, &resp); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+
+		// Force launch it
+		force := structs.PeriodicForceRequest{
+			JobID:        job.ID,
+			WriteRequest: structs.WriteRequest{Region: "global"},
+		}
+		var forceResp structs.PeriodicForceResponse
+		if err := s.Agent.RPC("Periodic.Force", &force, &forceResp); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+
+		// Make the HTTP request
+		req, err := http.NewRequest("GET", "/v1/job/"+job.ID+"/periodic/history", nil)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		respW := httptest.NewRecorder()
+
+		// Make the request
+		obj, err := s.Server.JobSpecificRequest(respW, req)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+
+		// Check for the index
+		if respW.HeaderMap.Get("X-Nomad-Index") == "" {
+			t.Fatalf("missing index")
+		}
+
+		// Check the response
+		launches := obj.([]*structs.PeriodicLaunchRecord)
+		if len(launches) != 1 || launches[0].EvalID != forceResp.EvalID {
+			t.Fatalf("bad: %#v", launches)
+		}
+	})
+}
@@ -0,0 +1,90 @@
+package command
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/nomad/api"
+	"github.com/mitchellh/cli"
+)
+
+type AllocCommand struct {
+	Meta
+}
+
+func (f *AllocCommand) Help() string {
+	return "This command is accessed by using one of the subcommands below."
+}
+
+func (f *AllocCommand) Synopsis() string {
+	return "Interact with existing allocations"
+}
+
+func (f *AllocCommand) Run(args []string) int {
+	return cli.RunResultHelp
+}
+
+// lookupAlloc returns the allocation with the given ID or unique ID prefix.
+func lookupAlloc(client *api.Client, allocID string, length int) (*api.Allocation, error) {
+	alloc, _, err := client.Allocations().Info(allocID, nil)
+	if err == nil {
+		return alloc, nil
+	}
+
+	if len(allocID) == 1 {
+		return nil, fmt.Errorf("Alloc ID must contain at least two characters.")
+	}
+	if len(allocID)%2 == 1 {
+		// Identifiers must be of even length, so we strip off the last byte
+		// to provide a consistent user experience.
+		allocID = allocID[:len(allocID)-1]
+	}
+
+	allocs, _, err := client.Allocations().PrefixList(allocID)
+	if err != nil {
+		return nil, fmt.Errorf("Error querying allocation: %v", err)
+	}
+	if len(allocs) == 0 {
+		return nil, fmt.Errorf("No allocation(s) with prefix or id %q found", allocID)
+	}
+	if len(allocs) > 1 {
+		// Format the allocs
+		out := make([]string, len(allocs)+1)
+		out[0] = "ID|Eval ID|Job ID|Task Group|Desired Status|Client Status"
+		for i, alloc := range allocs {
+			out[i+1] = fmt.Sprintf("%s|%s|%s|%s|%s|%s",
+				limit(alloc.ID, length),
+				limit(alloc.EvalID, length),
+				alloc.JobID,
+				alloc.TaskGroup,
+				alloc.DesiredStatus,
+				alloc.ClientStatus,
+			)
+		}
+		return nil, fmt.Errorf("Prefix matched multiple allocations\n\n%s", formatList(out))
+	}
+
+	// Prefix lookup matched a single allocation
+	alloc, _, err = client.Allocations().Info(allocs[0].ID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Error querying allocation: %s", err)
+	}
+	return alloc, nil
+}
+
+// allocHasTask returns whether the task group of the allocation has the task.
+func allocHasTask(alloc *api.Allocation, task string) bool {
+	if alloc.Job == nil {
+		return false
+	}
+	for _, tg := range alloc.Job.TaskGroups {
+		if tg.Name != alloc.TaskGroup {
+			continue
+		}
+		for _, t := range tg.Tasks {
+			if t.Name == task {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,168 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/nomad/api"
+	"github.com/hashicorp/nomad/helper/term"
+)
+
+type AllocExecCommand struct {
+	Meta
+
+	// The streams of the command, which default to the standard streams of
+	// the process.
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+func (c *AllocExecCommand) Help() string {
+	helpText := `
+Usage: nomad alloc exec [options] <allocation> <task> <command> [<args>...]
+
+  Run a command inside the isolation context of a running task: inside the
+  container for docker and lxc tasks, in the chroot and cgroup of the task
+  for exec and java tasks and in the task directory for raw_exec tasks.
+  The exit code of the command is returned.
+
+General Options:
+
+  ` + generalOptionsUsage() + `
+
+Exec Options:
+
+  -i
+    Pass the standard input to the command.
+
+  -t
+    Allocate a terminal for the command. Requires the standard input to
+    be a terminal.
+
+  -verbose
+    Display full information.
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *AllocExecCommand) Synopsis() string {
+	return "Run a command inside a running task"
+}
+
+func (c *AllocExecCommand) Run(args []string) int {
+	var stdinOpt, ttyOpt, verbose bool
+
+	flags := c.Meta.FlagSet("alloc exec", FlagSetClient)
+	flags.Usage = func() { c.Ui.Output(c.Help()) }
+	flags.BoolVar(&stdinOpt, "i", false, "")
+	flags.BoolVar(&ttyOpt, "t", false, "")
+	flags.BoolVar(&verbose, "verbose", false, "")
+
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	// Check that we got the allocation, the task and the command
+	args = flags.Args()
+	if len(args) < 3 {
+		c.Ui.Error(c.Help())
+		return 1
+	}
+	allocID, task, command := args[0], args[1], args[2:]
+
+	// Truncate the id unless full length is requested
+	length := shortId
+	if verbose {
+		length = fullId
+	}
+
+	stdin, stdout, stderr := c.Stdin, c.Stdout, c.Stderr
+	if stdin == nil {
+		stdin = os.Stdin
+	}
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	if stderr == nil {
+		stderr = os.Stderr
+	}
+
+	// A terminal requires the input to be one as well
+	var stdinFd uintptr
+	if ttyOpt {
+		f, ok := stdin.(*os.File)
+		if !ok || !term.IsTerminal(f.Fd()) {
+			c.Ui.Error("The -t flag requires the standard input to be a terminal")
+			return 1
+		}
+		stdinFd = f.Fd()
+	}
+
+	// Get the HTTP client
+	client, err := c.Meta.Client()
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error initializing client: %s", err))
+		return 1
+	}
+
+	alloc, err := lookupAlloc(client, allocID, length)
+	if err != nil {
+		c.Ui.Error(err.Error())
+		return 1
+	}
+
+	if !allocHasTask(alloc, task) {
+		c.Ui.Error(fmt.Sprintf("Allocation %q has no task %q", limit(alloc.ID, length), task))
+		return 1
+	}
+
+	opts := &api.ExecOptions{
+		Command: command,
+		Tty:     ttyOpt,
+		Stdout:  stdout,
+		Stderr:  stderr,
+	}
+	if stdinOpt {
+		opts.Stdin = stdin
+	}
+
+	// Switch the terminal to raw mode and relay its size changes
+	if ttyOpt {
+		state, err := term.MakeRaw(stdinFd)
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Error configuring terminal: %s", err))
+			return 1
+		}
+		defer term.Restore(stdinFd, state)
+
+		resizeCh := make(chan api.TerminalSize, 1)
+		opts.ResizeCh = resizeCh
+		sendSize := func() {
+			if size, err := term.GetSize(stdinFd); err == nil {
+				select {
+				case resizeCh <- api.TerminalSize{Height: size.Height, Width: size.Width}:
+				default:
+				}
+			}
+		}
+		sendSize()
+
+		sigCh := make(chan os.Signal, 1)
+		term.NotifyResize(sigCh)
+		go func() {
+			for range sigCh {
+				sendSize()
+			}
+		}()
+	}
+
+	code, err := client.Allocations().Exec(alloc, task, opts)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error running command: %s", err))
+		return 1
+	}
+	return code
+}
@@ -0,0 +1,89 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+)
+
+type AllocRestartCommand struct {
+	Meta
+}
+
+func (c *AllocRestartCommand) Help() string {
+	helpText := `
+Usage: nomad alloc restart [options] <allocation> [<task>]
+
+  Restart the tasks of an allocation in place. If a task name is given
+  only that task is restarted, otherwise all the running tasks of the
+  allocation are. The restarts are not counted against the restart
+  policy of the tasks.
+
+General Options:
+
+  ` + generalOptionsUsage() + `
+
+Restart Options:
+
+  -verbose
+    Display full information.
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *AllocRestartCommand) Synopsis() string {
+	return "Restart the tasks of an allocation"
+}
+
+func (c *AllocRestartCommand) Run(args []string) int {
+	var verbose bool
+
+	flags := c.Meta.FlagSet("alloc restart", FlagSetClient)
+	flags.Usage = func() { c.Ui.Output(c.Help()) }
+	flags.BoolVar(&verbose, "verbose", false, "")
+
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	// Check that we got the allocation and optionally a task
+	args = flags.Args()
+	if len(args) < 1 || len(args) > 2 {
+		c.Ui.Error(c.Help())
+		return 1
+	}
+	allocID := args[0]
+	var task string
+	if len(args) == 2 {
+		task = args[1]
+	}
+
+	// Truncate the id unless full length is requested
+	length := shortId
+	if verbose {
+		length = fullId
+	}
+
+	// Get the HTTP client
+	client, err := c.Meta.Client()
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error initializing client: %s", err))
+		return 1
+	}
+
+	alloc, err := lookupAlloc(client, allocID, length)
+	if err != nil {
+		c.Ui.Error(err.Error())
+		return 1
+	}
+
+	if task != "" && !allocHasTask(alloc, task) {
+		c.Ui.Error(fmt.Sprintf("Allocation %q has no task %q", limit(alloc.ID, length), task))
+		return 1
+	}
+
+	if err := client.Allocations().Restart(alloc, task); err != nil {
+		c.Ui.Error(fmt.Sprintf("Error restarting allocation: %s", err))
+		return 1
+	}
+	return 0
+}
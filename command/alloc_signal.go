@@ -0,0 +1,93 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+)
+
+type AllocSignalCommand struct {
+	Meta
+}
+
+func (c *AllocSignalCommand) Help() string {
+	helpText := `
+Usage: nomad alloc signal [options] <allocation> [<task>]
+
+  Send a signal to the tasks of an allocation. If a task name is given
+  only that task is signalled, otherwise all the running tasks of the
+  allocation are. The signal is recorded as an event of each task.
+
+General Options:
+
+  ` + generalOptionsUsage() + `
+
+Signal Options:
+
+  -s
+    The signal to send. Defaults to SIGKILL.
+
+  -verbose
+    Display full information.
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *AllocSignalCommand) Synopsis() string {
+	return "Send a signal to the tasks of an allocation"
+}
+
+func (c *AllocSignalCommand) Run(args []string) int {
+	var verbose bool
+	var signal string
+
+	flags := c.Meta.FlagSet("alloc signal", FlagSetClient)
+	flags.Usage = func() { c.Ui.Output(c.Help()) }
+	flags.BoolVar(&verbose, "verbose", false, "")
+	flags.StringVar(&signal, "s", "SIGKILL", "")
+
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	// Check that we got the allocation and optionally a task
+	args = flags.Args()
+	if len(args) < 1 || len(args) > 2 {
+		c.Ui.Error(c.Help())
+		return 1
+	}
+	allocID := args[0]
+	var task string
+	if len(args) == 2 {
+		task = args[1]
+	}
+
+	// Truncate the id unless full length is requested
+	length := shortId
+	if verbose {
+		length = fullId
+	}
+
+	// Get the HTTP client
+	client, err := c.Meta.Client()
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error initializing client: %s", err))
+		return 1
+	}
+
+	alloc, err := lookupAlloc(client, allocID, length)
+	if err != nil {
+		c.Ui.Error(err.Error())
+		return 1
+	}
+
+	if task != "" && !allocHasTask(alloc, task) {
+		c.Ui.Error(fmt.Sprintf("Allocation %q has no task %q", limit(alloc.ID, length), task))
+		return 1
+	}
+
+	if err := client.Allocations().Signal(alloc, task, signal); err != nil {
+		c.Ui.Error(fmt.Sprintf("Error signalling allocation: %s", err))
+		return 1
+	}
+	return 0
+}
@@ -154,8 +154,9 @@ func (c *AllocStatusCommand) Run(args []string) int {
 // shortTaskStatus prints out the current state of each task.
 func (c *AllocStatusCommand) shortTaskStatus(alloc *api.Allocation) {
 	tasks := make([]string, 0, len(alloc.TaskStates)+1)
-	tasks = append(tasks, "Name|State|Last Event|Time")
-	for task := range c.sortedTaskStateIterator(alloc.TaskStates) {
+	tasks = append(tasks, "Name|Lifecycle|State|Last Event|Time")
+	hooks := taskLifecycleHooks(alloc)
+	for task := range c.sortedTaskStateIterator(alloc) {
 		fmt.Println(task)
 		state := alloc.TaskStates[task]
 		lastState := state.State
@@ -168,8 +169,13 @@ func (c *AllocStatusCommand) shortTaskStatus(alloc *api.Allocation) {
 			lastTime = c.formatUnixNanoTime(last.Time)
 		}
 
-		tasks = append(tasks, fmt.Sprintf("%s|%s|%s|%s",
-			task, lastState, lastEvent, lastTime))
+		lifecycle := hooks[task]
+		if lifecycle == "" {
+			lifecycle = "main"
+		}
+
+		tasks = append(tasks, fmt.Sprintf("%s|%s|%s|%s|%s",
+			task, lifecycle, lastState, lastEvent, lastTime))
 	}
 
 	c.Ui.Output("\n==> Tasks")
@@ -178,7 +184,8 @@ func (c *AllocStatusCommand) shortTaskStatus(alloc *api.Allocation) {
 
 // taskStatus prints out the most recent events for each task.
 func (c *AllocStatusCommand) taskStatus(alloc *api.Allocation) {
-	for task := range c.sortedTaskStateIterator(alloc.TaskStates) {
+	hooks := taskLifecycleHooks(alloc)
+	for task := range c.sortedTaskStateIterator(alloc) {
 		state := alloc.TaskStates[task]
 		events := make([]string, len(state.Events)+1)
 		events[0] = "Time|Type|Description"
@@ -194,6 +201,48 @@ func (c *AllocStatusCommand) taskStatus(alloc *api.Allocation) {
 				desc = event.DriverError
 			case api.TaskKilled:
 				desc = event.KillError
+			case api.TaskArtifactDownloadFailed:
+				desc = event.DownloadError
+			case api.TaskSetupFailure:
+				desc = event.SetupError
+			case api.TaskRestartSignal:
+				desc = event.RestartReason
+			case api.TaskSignaling:
+				desc = fmt.Sprintf("Signal: %s, Reason: %s", event.TaskSignal, event.TaskSignalReason)
+			case api.TaskSiblingFailed:
+				desc = fmt.Sprintf("Task's sibling %q failed", event.FailedSibling)
+			case api.TaskSiblingExited:
+				desc = fmt.Sprintf("Leader task %q exited", event.ExitedSibling)
+			case api.TaskRestarting:
+				in := fmt.Sprintf("Task restarting in %v", time.Duration(event.StartDelay))
+				if event.RestartReason != "" {
+					desc = fmt.Sprintf("%s - %s", event.RestartReason, in)
+				} else {
+					desc = in
+				}
+			case api.TaskNotRestarting:
+				desc = event.RestartReason
+			case api.TaskKilling:
+				desc = fmt.Sprintf("Killing task with %v timeout", event.KillTimeout)
+			case api.TaskDiskExceeded:
+				desc = fmt.Sprintf("Disk usage of %d MB exceeded the %d MB limit", event.DiskSize, event.DiskLimit)
+			case api.TaskMemoryExceeded:
+				desc = fmt.Sprintf("Memory usage exceeded the %d MB limit", event.MemoryLimit)
+			case api.TaskPipelineStep:
+				desc = fmt.Sprintf("Step %q exited with code %d after %v", event.StepName, event.ExitCode, event.StepDuration)
+				if event.Message != "" {
+					desc = fmt.Sprintf("%s: %s", desc, event.Message)
+				}
+			case api.TaskPipelineArtifact:
+				if event.Message != "" {
+					desc = fmt.Sprintf("Failed to upload artifact %q: %s", event.Artifact, event.Message)
+				} else {
+					desc = fmt.Sprintf("Uploaded artifact %q", event.Artifact)
+				}
+			case api.TaskPullingImage:
+				desc = fmt.Sprintf("Pulling image %q: %s", event.Image, event.PullProgress)
+			case api.TaskImagePullStalled:
+				desc = fmt.Sprintf("Pull of image %q made no progress for %v", event.Image, event.PullTimeout)
 			case api.TaskTerminated:
 				var parts []string
 				parts = append(parts, fmt.Sprintf("Exit Code: %d", event.ExitCode))
@@ -212,7 +261,11 @@ func (c *AllocStatusCommand) taskStatus(alloc *api.Allocation) {
 			events[size-i] = fmt.Sprintf("%s|%s|%s", formatedTime, event.Type, desc)
 		}
 
-		c.Ui.Output(fmt.Sprintf("\n==> Task %q is %q\nRecent Events:", task, state.State))
+		name := fmt.Sprintf("%q", task)
+		if hook := hooks[task]; hook != "" {
+			name = fmt.Sprintf("%s (%s)", name, hook)
+		}
+		c.Ui.Output(fmt.Sprintf("\n==> Task %s is %q\nRecent Events:", name, state.State))
 		c.Ui.Output(formatList(events))
 	}
 }
@@ -223,9 +276,11 @@ func (c *AllocStatusCommand) formatUnixNanoTime(nano int64) string {
 	return formatTime(t)
 }
 
-// sortedTaskStateIterator is a helper that takes the task state map and returns a
-// channel that returns the keys in a sorted order.
-func (c *AllocStatusCommand) sortedTaskStateIterator(m map[string]*api.TaskState) <-chan string {
+// sortedTaskStateIterator is a helper that takes the allocation and returns a
+// channel that returns the names of its tasks in lifecycle order, sorted by
+// name within each lifecycle hook.
+func (c *AllocStatusCommand) sortedTaskStateIterator(alloc *api.Allocation) <-chan string {
+	m := alloc.TaskStates
 	output := make(chan string, len(m))
 	keys := make([]string, len(m))
 	i := 0
@@ -233,7 +288,9 @@ func (c *AllocStatusCommand) sortedTaskStateIterator(m map[string]*api.TaskState
 		keys[i] = k
 		i++
 	}
-	sort.Strings(keys)
+
+	hooks := taskLifecycleHooks(alloc)
+	sort.Sort(lifecycleOrder{keys, hooks})
 
 	for _, key := range keys {
 		output <- key
@@ -242,3 +299,55 @@ func (c *AllocStatusCommand) sortedTaskStateIterator(m map[string]*api.TaskState
 	close(output)
 	return output
 }
+
+// taskLifecycleHooks returns the lifecycle hook of each task of the
+// allocation. Main tasks have no hook.
+func taskLifecycleHooks(alloc *api.Allocation) map[string]string {
+	hooks := make(map[string]string)
+	if alloc.Job == nil {
+		return hooks
+	}
+	for _, tg := range alloc.Job.TaskGroups {
+		if tg.Name != alloc.TaskGroup {
+			continue
+		}
+		for _, task := range tg.Tasks {
+			if task.Lifecycle != nil {
+				hooks[task.Name] = task.Lifecycle.Hook
+			}
+		}
+	}
+	return hooks
+}
+
+// lifecycleOrder sorts task names in the order their lifecycle hooks run in,
+// and by name within each hook.
+type lifecycleOrder struct {
+	names []string
+	hooks map[string]string
+}
+
+func (l lifecycleOrder) Len() int      { return len(l.names) }
+func (l lifecycleOrder) Swap(i, j int) { l.names[i], l.names[j] = l.names[j], l.names[i] }
+func (l lifecycleOrder) Less(i, j int) bool {
+	ri, rj := lifecycleRank(l.hooks[l.names[i]]), lifecycleRank(l.hooks[l.names[j]])
+	if ri != rj {
+		return ri < rj
+	}
+	return l.names[i] < l.names[j]
+}
+
+// lifecycleRank returns the position of the lifecycle hook in the order the
+// tasks of an allocation are run in.
+func lifecycleRank(hook string) int {
+	switch hook {
+	case api.TaskLifecycleHookPrestart:
+		return 0
+	case api.TaskLifecycleHookPoststart:
+		return 2
+	case api.TaskLifecycleHookPoststop:
+		return 3
+	default:
+		return 1
+	}
+}
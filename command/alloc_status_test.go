@@ -1,9 +1,12 @@
 package command
 
 import (
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/hashicorp/nomad/api"
 	"github.com/mitchellh/cli"
 )
 
@@ -63,3 +66,91 @@ func TestAllocStatusCommand_Fails(t *testing.T) {
 	}
 
 }
+
+func TestAllocStatusCommand_LifecycleOrder(t *testing.T) {
+	ui := new(cli.MockUi)
+	cmd := &AllocStatusCommand{Meta: Meta{Ui: ui}}
+
+	task := func(name, hook string) *api.Task {
+		t := api.NewTask(name, "exec")
+		if hook != "" {
+			t.SetLifecycle(&api.TaskLifecycle{Hook: hook})
+		}
+		return t
+	}
+	job := &api.Job{
+		TaskGroups: []*api.TaskGroup{
+			api.NewTaskGroup("web", 1).
+				AddTask(task("cleanup", api.TaskLifecycleHookPoststop)).
+				AddTask(task("web", "")).
+				AddTask(task("proxy", api.TaskLifecycleHookPoststart)).
+				AddTask(task("init", api.TaskLifecycleHookPrestart)).
+				AddTask(task("api", "")),
+		},
+	}
+	alloc := &api.Allocation{
+		TaskGroup:  "web",
+		Job:        job,
+		TaskStates: make(map[string]*api.TaskState),
+	}
+	for _, tg := range job.TaskGroups {
+		for _, t := range tg.Tasks {
+			alloc.TaskStates[t.Name] = &api.TaskState{State: "pending"}
+		}
+	}
+
+	var order []string
+	for task := range cmd.sortedTaskStateIterator(alloc) {
+		order = append(order, task)
+	}
+	expected := []string{"init", "api", "web", "proxy", "cleanup"}
+	if !reflect.DeepEqual(order, expected) {
+		t.Fatalf("got %v; want %v", order, expected)
+	}
+
+	cmd.taskStatus(alloc)
+	if out := ui.OutputWriter.String(); !strings.Contains(out, `Task "init" (prestart) is "pending"`) {
+		t.Fatalf("expected lifecycle in output, got: %s", out)
+	}
+}
+
+func TestAllocStatusCommand_TaskEvents(t *testing.T) {
+	ui := new(cli.MockUi)
+	cmd := &AllocStatusCommand{Meta: Meta{Ui: ui}}
+
+	job := &api.Job{
+		TaskGroups: []*api.TaskGroup{
+			api.NewTaskGroup("web", 1).AddTask(api.NewTask("web", "exec")),
+		},
+	}
+	alloc := &api.Allocation{
+		TaskGroup: "web",
+		Job:       job,
+		TaskStates: map[string]*api.TaskState{
+			"web": &api.TaskState{
+				State: "dead",
+				Events: []*api.TaskEvent{
+					{Type: api.TaskKilling, KillTimeout: 5 * time.Second},
+					{Type: api.TaskRestarting, StartDelay: int64(10 * time.Second), RestartReason: "Restart within policy"},
+					{Type: api.TaskNotRestarting, RestartReason: "Exceeded allowed attempts"},
+					{Type: api.TaskDiskExceeded, DiskLimit: 150, DiskSize: 200},
+					{Type: api.TaskMemoryExceeded, MemoryLimit: 256},
+				},
+			},
+		},
+	}
+
+	cmd.taskStatus(alloc)
+	out := ui.OutputWriter.String()
+	for _, expected := range []string{
+		"Killing task with 5s timeout",
+		"Restart within policy - Task restarting in 10s",
+		"Exceeded allowed attempts",
+		"Disk usage of 200 MB exceeded the 150 MB limit",
+		"Memory usage exceeded the 256 MB limit",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Fatalf("expected %q in output, got: %s", expected, out)
+		}
+	}
+}
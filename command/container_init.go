@@ -0,0 +1,30 @@
+package command
+
+import (
+	"strings"
+
+	"github.com/hashicorp/nomad/client/driver/executor"
+)
+
+type ContainerInitCommand struct {
+	Meta
+}
+
+func (c *ContainerInitCommand) Help() string {
+	helpText := `
+	This is a command used by Nomad internally to run the init of a container
+	`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *ContainerInitCommand) Synopsis() string {
+	return "internal - run the init of a container"
+}
+
+func (c *ContainerInitCommand) Run(args []string) int {
+	if err := executor.ContainerInit(); err != nil {
+		c.Ui.Error(err.Error())
+		return 1
+	}
+	return 0
+}
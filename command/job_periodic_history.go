@@ -0,0 +1,122 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/nomad/api"
+)
+
+type JobPeriodicHistoryCommand struct {
+	Meta
+}
+
+func (c *JobPeriodicHistoryCommand) Help() string {
+	helpText := `
+Usage: nomad job periodic history [options] <job>
+
+  Display the launch history of a periodic job, newest first. For
+  each launch the launch time, the ID of the launched child job, the
+  evaluation created for it and the status of the child job are
+  displayed. The status is retained after the child job is garbage
+  collected. Only the most recent launches are retained.
+
+General Options:
+
+  ` + generalOptionsUsage() + `
+
+History Options:
+
+  -verbose
+    Display full information.
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *JobPeriodicHistoryCommand) Synopsis() string {
+	return "Display the launch history of a periodic job"
+}
+
+func (c *JobPeriodicHistoryCommand) Run(args []string) int {
+	var verbose bool
+
+	flags := c.Meta.FlagSet("job periodic history", FlagSetClient)
+	flags.Usage = func() { c.Ui.Output(c.Help()) }
+	flags.BoolVar(&verbose, "verbose", false, "")
+
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	// Truncate the id unless full length is requested
+	length := shortId
+	if verbose {
+		length = fullId
+	}
+
+	// Check that we got exactly one job
+	args = flags.Args()
+	if len(args) != 1 {
+		c.Ui.Error(c.Help())
+		return 1
+	}
+	jobID := args[0]
+
+	// Get the HTTP client
+	client, err := c.Meta.Client()
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error initializing client: %s", err))
+		return 1
+	}
+
+	// Resolve the job ID, allowing a unique prefix
+	job, _, err := client.Jobs().Info(jobID, nil)
+	if err != nil {
+		jobs, _, err := client.Jobs().PrefixList(jobID)
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Error querying job: %s", err))
+			return 1
+		}
+		if len(jobs) == 0 {
+			c.Ui.Error(fmt.Sprintf("No job(s) with prefix or id %q found", jobID))
+			return 1
+		}
+		if len(jobs) > 1 {
+			out := make([]string, len(jobs)+1)
+			out[0] = "ID|Type|Priority|Status"
+			for i, job := range jobs {
+				out[i+1] = fmt.Sprintf("%s|%s|%d|%s",
+					job.ID,
+					job.Type,
+					job.Priority,
+					job.Status)
+			}
+			c.Ui.Output(fmt.Sprintf("Prefix matched multiple jobs\n\n%s", formatList(out)))
+			return 0
+		}
+		job = &api.Job{ID: jobs[0].ID}
+	}
+
+	launches, _, err := client.Jobs().PeriodicHistory(job.ID, nil)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error querying periodic history: %s", err))
+		return 1
+	}
+
+	if len(launches) == 0 {
+		c.Ui.Output(fmt.Sprintf("No launches found for job %q", job.ID))
+		return 0
+	}
+
+	out := make([]string, len(launches)+1)
+	out[0] = "Launch Time|Child ID|Eval ID|Status"
+	for i, launch := range launches {
+		out[i+1] = fmt.Sprintf("%s|%s|%s|%s",
+			formatTime(launch.Launch),
+			launch.ChildID,
+			limit(launch.EvalID, length),
+			launch.Status)
+	}
+	c.Ui.Output(formatList(out))
+	return 0
+}
@@ -0,0 +1,174 @@
+package command
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/nomad/api"
+	"github.com/hashicorp/nomad/helper/flag-slice"
+)
+
+type JobScaleCommand struct {
+	Meta
+}
+
+func (c *JobScaleCommand) Help() string {
+	helpText := `
+Usage: nomad job scale [options] <job> <group> <count>
+
+  Change the count of a task group of an existing job without
+  resubmitting the job. The count must be within the bounds of the
+  scaling stanza of the task group, if any. Upon success, an
+  interactive monitor session will start to display log lines as the
+  job is rescheduled. It is safe to exit the monitor early using
+  ctrl+c.
+
+General Options:
+
+  ` + generalOptionsUsage() + `
+
+Scale Options:
+
+  -check-index
+    If set, the job is only scaled if the passed job modify index
+    matches the server side version of the job.
+
+  -message
+    A message describing the reason for scaling, recorded in the
+    scaling event.
+
+  -meta
+    Metadata to record in the scaling event. Each instance of -meta
+    parses a single KEY=VALUE pair.
+
+  -detach
+    Return immediately instead of entering monitor mode. After the
+    scaling request is submitted, a new evaluation ID is printed to
+    the screen, which can be used to call up a monitor later if needed
+    using the eval-monitor command.
+
+  -verbose
+    Display full information.
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *JobScaleCommand) Synopsis() string {
+	return "Change the count of a task group"
+}
+
+func (c *JobScaleCommand) Run(args []string) int {
+	var detach, verbose bool
+	var checkIndex uint64
+	var message string
+	var meta []string
+
+	flags := c.Meta.FlagSet("job scale", FlagSetClient)
+	flags.Usage = func() { c.Ui.Output(c.Help()) }
+	flags.BoolVar(&detach, "detach", false, "")
+	flags.BoolVar(&verbose, "verbose", false, "")
+	flags.Uint64Var(&checkIndex, "check-index", 0, "")
+	flags.StringVar(&message, "message", "", "")
+	flags.Var((*sliceflag.StringFlag)(&meta), "meta", "")
+
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	// Truncate the id unless full length is requested
+	length := shortId
+	if verbose {
+		length = fullId
+	}
+
+	// Check that we got the job, group and count
+	args = flags.Args()
+	if len(args) != 3 {
+		c.Ui.Error(c.Help())
+		return 1
+	}
+	jobID, group := args[0], args[1]
+	count, err := strconv.Atoi(args[2])
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error parsing count %q: %s", args[2], err))
+		return 1
+	}
+
+	req := &api.ScaleRequest{
+		TaskGroup:      group,
+		Count:          count,
+		EnforceIndex:   checkIndex != 0,
+		JobModifyIndex: checkIndex,
+		Message:        message,
+	}
+
+	// Parse the meta flags
+	if len(meta) != 0 {
+		req.Meta = make(map[string]string, len(meta))
+		for _, kv := range meta {
+			parts := strings.SplitN(kv, "=", 2)
+			if len(parts) != 2 {
+				c.Ui.Error(fmt.Sprintf("Error parsing meta value: %v", kv))
+				return 1
+			}
+			req.Meta[parts[0]] = parts[1]
+		}
+	}
+
+	// Get the HTTP client
+	client, err := c.Meta.Client()
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error initializing client: %s", err))
+		return 1
+	}
+
+	// Resolve the job ID, allowing a unique prefix
+	job, _, err := client.Jobs().Info(jobID, nil)
+	if err != nil {
+		jobs, _, err := client.Jobs().PrefixList(jobID)
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Error scaling job: %s", err))
+			return 1
+		}
+		if len(jobs) == 0 {
+			c.Ui.Error(fmt.Sprintf("No job(s) with prefix or id %q found", jobID))
+			return 1
+		}
+		if len(jobs) > 1 {
+			out := make([]string, len(jobs)+1)
+			out[0] = "ID|Type|Priority|Status"
+			for i, job := range jobs {
+				out[i+1] = fmt.Sprintf("%s|%s|%d|%s",
+					job.ID,
+					job.Type,
+					job.Priority,
+					job.Status)
+			}
+			c.Ui.Output(fmt.Sprintf("Prefix matched multiple jobs\n\n%s", formatList(out)))
+			return 0
+		}
+		job = &api.Job{ID: jobs[0].ID}
+	}
+
+	// Invoke the scaling
+	evalID, _, err := client.Jobs().Scale(job.ID, req, nil)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error scaling job: %s", err))
+		return 1
+	}
+
+	// Scaling a periodic job does not create an evaluation
+	if evalID == "" {
+		return 0
+	}
+
+	if detach {
+		c.Ui.Output(evalID)
+		return 0
+	}
+
+	// Start monitoring the scaling eval
+	mon := newMonitor(c.Ui, client, length)
+	return mon.monitor(evalID, false)
+}
@@ -0,0 +1,64 @@
+package command
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mitchellh/cli"
+)
+
+func TestJobScaleCommand_Implements(t *testing.T) {
+	var _ cli.Command = &JobScaleCommand{}
+}
+
+func TestJobScaleCommand_Fails(t *testing.T) {
+	srv, _, url := testServer(t, nil)
+	defer srv.Stop()
+
+	ui := new(cli.MockUi)
+	cmd := &JobScaleCommand{Meta: Meta{Ui: ui}}
+
+	// Fails on misuse
+	if code := cmd.Run([]string{"some", "bad"}); code != 1 {
+		t.Fatalf("expected exit code 1, got: %d", code)
+	}
+	if out := ui.ErrorWriter.String(); !strings.Contains(out, cmd.Help()) {
+		t.Fatalf("expected help output, got: %s", out)
+	}
+	ui.ErrorWriter.Reset()
+
+	// Fails on an invalid count
+	if code := cmd.Run([]string{"-address=" + url, "job", "group", "many"}); code != 1 {
+		t.Fatalf("expected exit code 1, got: %d", code)
+	}
+	if out := ui.ErrorWriter.String(); !strings.Contains(out, "Error parsing count") {
+		t.Fatalf("expected count error, got: %s", out)
+	}
+	ui.ErrorWriter.Reset()
+
+	// Fails on invalid meta
+	if code := cmd.Run([]string{"-address=" + url, "-meta=foo", "job", "group", "2"}); code != 1 {
+		t.Fatalf("expected exit code 1, got: %d", code)
+	}
+	if out := ui.ErrorWriter.String(); !strings.Contains(out, "Error parsing meta") {
+		t.Fatalf("expected meta error, got: %s", out)
+	}
+	ui.ErrorWriter.Reset()
+
+	// Fails on non-existent job ID
+	if code := cmd.Run([]string{"-address=" + url, "nope", "group", "2"}); code != 1 {
+		t.Fatalf("expect exit 1, got: %d", code)
+	}
+	if out := ui.ErrorWriter.String(); !strings.Contains(out, "No job(s) with prefix or id") {
+		t.Fatalf("expect not found error, got: %s", out)
+	}
+	ui.ErrorWriter.Reset()
+
+	// Fails on connection failure
+	if code := cmd.Run([]string{"-address=nope", "nope", "group", "2"}); code != 1 {
+		t.Fatalf("expected exit code 1, got: %d", code)
+	}
+	if out := ui.ErrorWriter.String(); !strings.Contains(out, "Error scaling job") {
+		t.Fatalf("expected failed query error, got: %s", out)
+	}
+}
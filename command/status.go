@@ -34,6 +34,11 @@ Status Options:
     queried, and drops verbose information about allocations
     and evaluations.
 
+  -children
+    Display the jobs launched by periodic jobs. Used only when
+    listing jobs; each child job is listed below its parent.
+    By default child jobs are omitted from the list.
+
   -verbose
     Display full information.
 `
@@ -45,11 +50,12 @@ func (c *StatusCommand) Synopsis() string {
 }
 
 func (c *StatusCommand) Run(args []string) int {
-	var short, verbose bool
+	var short, children, verbose bool
 
 	flags := c.Meta.FlagSet("status", FlagSetClient)
 	flags.Usage = func() { c.Ui.Output(c.Help()) }
 	flags.BoolVar(&short, "short", false, "")
+	flags.BoolVar(&children, "children", false, "")
 	flags.BoolVar(&verbose, "verbose", false, "")
 
 	if err := flags.Parse(args); err != nil {
@@ -90,16 +96,7 @@ func (c *StatusCommand) Run(args []string) int {
 			return 0
 		}
 
-		out := make([]string, len(jobs)+1)
-		out[0] = "ID|Type|Priority|Status"
-		for i, job := range jobs {
-			out[i+1] = fmt.Sprintf("%s|%s|%d|%s",
-				job.ID,
-				job.Type,
-				job.Priority,
-				job.Status)
-		}
-		c.Ui.Output(formatList(out))
+		c.Ui.Output(formatJobList(jobs, children))
 		return 0
 	}
 
@@ -186,6 +183,47 @@ func (c *StatusCommand) Run(args []string) int {
 	return 0
 }
 
+// formatJobList formats the job list. Jobs launched by another job are
+// omitted unless children is set, in which case they are listed below their
+// parent. Children whose parent is no longer known are listed as top level
+// jobs.
+func formatJobList(jobs []*api.JobListStub, children bool) string {
+	known := make(map[string]struct{}, len(jobs))
+	for _, job := range jobs {
+		known[job.ID] = struct{}{}
+	}
+
+	byParent := make(map[string][]*api.JobListStub)
+	var top []*api.JobListStub
+	for _, job := range jobs {
+		if _, ok := known[job.ParentID]; job.ParentID != "" && ok {
+			byParent[job.ParentID] = append(byParent[job.ParentID], job)
+			continue
+		}
+		top = append(top, job)
+	}
+
+	out := []string{"ID|Type|Priority|Status"}
+	for _, job := range top {
+		out = append(out, fmt.Sprintf("%s|%s|%d|%s",
+			job.ID,
+			job.Type,
+			job.Priority,
+			job.Status))
+		if !children {
+			continue
+		}
+		for _, child := range byParent[job.ID] {
+			out = append(out, fmt.Sprintf("- %s|%s|%d|%s",
+				child.ID,
+				child.Type,
+				child.Priority,
+				child.Status))
+		}
+	}
+	return formatList(out)
+}
+
 // outputPeriodicInfo prints information about the passed periodic job. If a
 // request fails, an error is returned.
 func (c *StatusCommand) outputPeriodicInfo(client *api.Client, job *api.Job) error {
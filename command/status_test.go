@@ -4,6 +4,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/hashicorp/nomad/api"
 	"github.com/mitchellh/cli"
 )
 
@@ -138,3 +139,30 @@ func TestStatusCommand_Fails(t *testing.T) {
 		t.Fatalf("expected failed query error, got: %s", out)
 	}
 }
+
+func TestStatusCommand_FormatJobList_Children(t *testing.T) {
+	jobs := []*api.JobListStub{
+		{ID: "batch", Type: "batch", Priority: 50, Status: "running"},
+		{ID: "batch/periodic-1", ParentID: "batch", Type: "batch", Priority: 50, Status: "dead"},
+		{ID: "orphan/periodic-1", ParentID: "orphan", Type: "batch", Priority: 50, Status: "dead"},
+		{ID: "service", Type: "service", Priority: 50, Status: "running"},
+	}
+
+	// Children of known jobs are omitted by default
+	out := formatJobList(jobs, false)
+	if strings.Contains(out, "batch/periodic-1") {
+		t.Fatalf("expected child to be omitted, got: %s", out)
+	}
+	if !strings.Contains(out, "orphan/periodic-1") || !strings.Contains(out, "service") {
+		t.Fatalf("expected top level jobs, got: %s", out)
+	}
+
+	// Children are listed below their parent
+	lines := strings.Split(strings.TrimSpace(formatJobList(jobs, true)), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("bad: %#v", lines)
+	}
+	if !strings.HasPrefix(lines[1], "batch ") || !strings.HasPrefix(lines[2], "- batch/periodic-1") {
+		t.Fatalf("expected child below parent, got: %#v", lines)
+	}
+}
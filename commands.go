@@ -24,6 +24,26 @@ func Commands(metaPtr *command.Meta) map[string]cli.CommandFactory {
 	}
 
 	return map[string]cli.CommandFactory{
+		"alloc": func() (cli.Command, error) {
+			return &command.AllocCommand{
+				Meta: meta,
+			}, nil
+		},
+		"alloc exec": func() (cli.Command, error) {
+			return &command.AllocExecCommand{
+				Meta: meta,
+			}, nil
+		},
+		"alloc restart": func() (cli.Command, error) {
+			return &command.AllocRestartCommand{
+				Meta: meta,
+			}, nil
+		},
+		"alloc signal": func() (cli.Command, error) {
+			return &command.AllocSignalCommand{
+				Meta: meta,
+			}, nil
+		},
 		"alloc-status": func() (cli.Command, error) {
 			return &command.AllocStatusCommand{
 				Meta: meta,
@@ -52,6 +72,11 @@ func Commands(metaPtr *command.Meta) map[string]cli.CommandFactory {
 			}, nil
 		},
 
+		"container-init": func() (cli.Command, error) {
+			return &command.ContainerInitCommand{
+				Meta: meta,
+			}, nil
+		},
 		"eval-monitor": func() (cli.Command, error) {
 			return &command.EvalMonitorCommand{
 				Meta: meta,
@@ -88,6 +113,26 @@ func Commands(metaPtr *command.Meta) map[string]cli.CommandFactory {
 			}, nil
 		},
 
+		"job": func() (cli.Command, error) {
+			return &command.JobCommand{
+				Meta: meta,
+			}, nil
+		},
+		"job periodic": func() (cli.Command, error) {
+			return &command.JobPeriodicCommand{
+				Meta: meta,
+			}, nil
+		},
+		"job periodic history": func() (cli.Command, error) {
+			return &command.JobPeriodicHistoryCommand{
+				Meta: meta,
+			}, nil
+		},
+		"job scale": func() (cli.Command, error) {
+			return &command.JobScaleCommand{
+				Meta: meta,
+			}, nil
+		},
 		"node-drain": func() (cli.Command, error) {
 			return &command.NodeDrainCommand{
 				Meta: meta,
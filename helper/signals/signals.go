@@ -0,0 +1,20 @@
+package signals
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Parse returns the signal with the given name, e.g. "SIGHUP". The lookup is
+// case insensitive and the "SIG" prefix may be omitted.
+func Parse(name string) (os.Signal, error) {
+	upper := strings.ToUpper(strings.TrimSpace(name))
+	if !strings.HasPrefix(upper, "SIG") {
+		upper = "SIG" + upper
+	}
+	if s, ok := signalLookup[upper]; ok {
+		return s, nil
+	}
+	return nil, fmt.Errorf("unknown signal %q", name)
+}
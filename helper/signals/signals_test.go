@@ -0,0 +1,22 @@
+package signals
+
+import (
+	"syscall"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for _, name := range []string{"SIGHUP", "sighup", "HUP", " SIGHUP "} {
+		s, err := Parse(name)
+		if err != nil {
+			t.Fatalf("Parse(%q) failed: %v", name, err)
+		}
+		if s != syscall.SIGHUP {
+			t.Fatalf("Parse(%q) = %v; want SIGHUP", name, s)
+		}
+	}
+
+	if _, err := Parse("SIGFOO"); err == nil {
+		t.Fatalf("Parse should have failed")
+	}
+}
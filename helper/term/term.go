@@ -0,0 +1,16 @@
+// Package term provides the terminal handling used by interactive exec
+// sessions: switching a terminal to raw mode, reading and setting its size
+// and allocating pseudo-terminals.
+package term
+
+import "fmt"
+
+// ErrUnsupported is returned by the functions of the package on platforms
+// without terminal support.
+var ErrUnsupported = fmt.Errorf("terminals are not supported on this platform")
+
+// Size is the size of a terminal in characters.
+type Size struct {
+	Height uint16
+	Width  uint16
+}
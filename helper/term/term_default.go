@@ -0,0 +1,35 @@
+// +build !linux
+
+package term
+
+import "os"
+
+// State is the state of a terminal.
+type State struct{}
+
+func IsTerminal(fd uintptr) bool {
+	return false
+}
+
+func MakeRaw(fd uintptr) (*State, error) {
+	return nil, ErrUnsupported
+}
+
+func Restore(fd uintptr, state *State) error {
+	return ErrUnsupported
+}
+
+func GetSize(fd uintptr) (Size, error) {
+	return Size{}, ErrUnsupported
+}
+
+func SetSize(fd uintptr, size Size) error {
+	return ErrUnsupported
+}
+
+func NotifyResize(ch chan<- os.Signal) {
+}
+
+func OpenPty() (*os.File, *os.File, error) {
+	return nil, nil, ErrUnsupported
+}
@@ -0,0 +1,110 @@
+package term
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"unsafe"
+)
+
+// State is the state of a terminal, used to restore it after switching it to
+// raw mode.
+type State struct {
+	termios syscall.Termios
+}
+
+// winsize mirrors the struct of the TIOCGWINSZ and TIOCSWINSZ ioctls.
+type winsize struct {
+	Row    uint16
+	Col    uint16
+	Xpixel uint16
+	Ypixel uint16
+}
+
+func ioctl(fd, req uintptr, arg unsafe.Pointer) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(arg)); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// IsTerminal returns whether the file descriptor is a terminal.
+func IsTerminal(fd uintptr) bool {
+	var termios syscall.Termios
+	return ioctl(fd, syscall.TCGETS, unsafe.Pointer(&termios)) == nil
+}
+
+// MakeRaw switches the terminal to raw mode and returns its previous state.
+func MakeRaw(fd uintptr) (*State, error) {
+	var old State
+	if err := ioctl(fd, syscall.TCGETS, unsafe.Pointer(&old.termios)); err != nil {
+		return nil, err
+	}
+
+	raw := old.termios
+	raw.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP |
+		syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	raw.Oflag &^= syscall.OPOST
+	raw.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	raw.Cflag &^= syscall.CSIZE | syscall.PARENB
+	raw.Cflag |= syscall.CS8
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	if err := ioctl(fd, syscall.TCSETS, unsafe.Pointer(&raw)); err != nil {
+		return nil, err
+	}
+	return &old, nil
+}
+
+// Restore restores the terminal to a state returned by MakeRaw.
+func Restore(fd uintptr, state *State) error {
+	return ioctl(fd, syscall.TCSETS, unsafe.Pointer(&state.termios))
+}
+
+// GetSize returns the size of the terminal.
+func GetSize(fd uintptr) (Size, error) {
+	var ws winsize
+	if err := ioctl(fd, syscall.TIOCGWINSZ, unsafe.Pointer(&ws)); err != nil {
+		return Size{}, err
+	}
+	return Size{Height: ws.Row, Width: ws.Col}, nil
+}
+
+// SetSize sets the size of the terminal.
+func SetSize(fd uintptr, size Size) error {
+	ws := winsize{Row: size.Height, Col: size.Width}
+	return ioctl(fd, syscall.TIOCSWINSZ, unsafe.Pointer(&ws))
+}
+
+// NotifyResize relays the signals sent when the size of the controlling
+// terminal changes to ch.
+func NotifyResize(ch chan<- os.Signal) {
+	signal.Notify(ch, syscall.SIGWINCH)
+}
+
+// OpenPty allocates a pseudo-terminal and returns its master and slave ends.
+func OpenPty() (*os.File, *os.File, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var unlock int32
+	if err := ioctl(master.Fd(), syscall.TIOCSPTLCK, unsafe.Pointer(&unlock)); err != nil {
+		master.Close()
+		return nil, nil, fmt.Errorf("failed to unlock pty: %v", err)
+	}
+	var n uint32
+	if err := ioctl(master.Fd(), syscall.TIOCGPTN, unsafe.Pointer(&n)); err != nil {
+		master.Close()
+		return nil, nil, fmt.Errorf("failed to get pty number: %v", err)
+	}
+
+	slave, err := os.OpenFile(fmt.Sprintf("/dev/pts/%d", n), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, nil, err
+	}
+	return master, slave, nil
+}
@@ -0,0 +1,48 @@
+package term
+
+import (
+	"testing"
+)
+
+func TestOpenPty(t *testing.T) {
+	master, slave, err := OpenPty()
+	if err != nil {
+		t.Skipf("unable to allocate a pty: %v", err)
+	}
+	defer master.Close()
+	defer slave.Close()
+
+	if !IsTerminal(slave.Fd()) {
+		t.Fatalf("slave should be a terminal")
+	}
+
+	size := Size{Height: 40, Width: 120}
+	if err := SetSize(master.Fd(), size); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	got, err := GetSize(slave.Fd())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if got != size {
+		t.Fatalf("got size %v; want %v", got, size)
+	}
+
+	// Input written to the master in raw mode is read back unchanged
+	state, err := MakeRaw(slave.Fd())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer Restore(slave.Fd(), state)
+	if _, err := master.Write([]byte("foo\r")); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	buf := make([]byte, 4)
+	n, err := slave.Read(buf)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if string(buf[:n]) != "foo\r" {
+		t.Fatalf("got %q", buf[:n])
+	}
+}
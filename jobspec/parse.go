@@ -207,9 +207,14 @@ func parseGroups(result *structs.Job, list *ast.ObjectList) error {
 			return err
 		}
 		delete(m, "constraint")
+		delete(m, "colocate")
+		delete(m, "avoid")
 		delete(m, "meta")
 		delete(m, "task")
 		delete(m, "restart")
+		delete(m, "scaling")
+		delete(m, "volume")
+		delete(m, "ephemeral_disk")
 
 		// Default count to 1 if not specified
 		if _, ok := m["count"]; !ok {
@@ -230,6 +235,18 @@ func parseGroups(result *structs.Job, list *ast.ObjectList) error {
 			}
 		}
 
+		// Parse allocation affinities
+		if o := listVal.Filter(structs.AllocAffinityColocate); len(o.Items) > 0 {
+			if err := parseAllocAffinities(structs.AllocAffinityColocate, &g.Affinities, o); err != nil {
+				return err
+			}
+		}
+		if o := listVal.Filter(structs.AllocAffinityAvoid); len(o.Items) > 0 {
+			if err := parseAllocAffinities(structs.AllocAffinityAvoid, &g.Affinities, o); err != nil {
+				return err
+			}
+		}
+
 		// Parse restart policy
 		if o := listVal.Filter("restart"); len(o.Items) > 0 {
 			if err := parseRestartPolicy(&g.RestartPolicy, o); err != nil {
@@ -237,6 +254,27 @@ func parseGroups(result *structs.Job, list *ast.ObjectList) error {
 			}
 		}
 
+		// Parse scaling policy
+		if o := listVal.Filter("scaling"); len(o.Items) > 0 {
+			if err := parseScalingPolicy(&g.Scaling, o); err != nil {
+				return err
+			}
+		}
+
+		// Parse ephemeral disk
+		if o := listVal.Filter("ephemeral_disk"); len(o.Items) > 0 {
+			if err := parseEphemeralDisk(&g.EphemeralDisk, o); err != nil {
+				return fmt.Errorf("group '%s': %s", n, err)
+			}
+		}
+
+		// Parse volumes
+		if o := listVal.Filter("volume"); len(o.Items) > 0 {
+			if err := parseVolumes(&g.Volumes, o); err != nil {
+				return fmt.Errorf("group '%s': volume: %s", n, err)
+			}
+		}
+
 		// Parse out meta fields. These are in HCL as a list so we need
 		// to iterate over them and merge them.
 		if metaO := listVal.Filter("meta"); len(metaO.Items) > 0 {
@@ -296,6 +334,66 @@ func parseRestartPolicy(final **structs.RestartPolicy, list *ast.ObjectList) err
 	return nil
 }
 
+func parseEphemeralDisk(final **structs.EphemeralDisk, list *ast.ObjectList) error {
+	list = list.Elem()
+	if len(list.Items) > 1 {
+		return fmt.Errorf("only one 'ephemeral_disk' block allowed")
+	}
+
+	var m map[string]interface{}
+	if err := hcl.DecodeObject(&m, list.Items[0].Val); err != nil {
+		return err
+	}
+
+	result := structs.DefaultEphemeralDisk()
+	if err := mapstructure.WeakDecode(m, result); err != nil {
+		return err
+	}
+
+	*final = result
+	return nil
+}
+
+func parseLifecycle(final **structs.TaskLifecycleConfig, list *ast.ObjectList) error {
+	list = list.Elem()
+	if len(list.Items) > 1 {
+		return fmt.Errorf("only one 'lifecycle' block allowed")
+	}
+
+	var m map[string]interface{}
+	if err := hcl.DecodeObject(&m, list.Items[0].Val); err != nil {
+		return err
+	}
+
+	var result structs.TaskLifecycleConfig
+	if err := mapstructure.WeakDecode(m, &result); err != nil {
+		return err
+	}
+
+	*final = &result
+	return nil
+}
+
+func parseScalingPolicy(final **structs.ScalingPolicy, list *ast.ObjectList) error {
+	list = list.Elem()
+	if len(list.Items) > 1 {
+		return fmt.Errorf("only one 'scaling' block allowed")
+	}
+
+	var m map[string]interface{}
+	if err := hcl.DecodeObject(&m, list.Items[0].Val); err != nil {
+		return err
+	}
+
+	var result structs.ScalingPolicy
+	if err := mapstructure.WeakDecode(m, &result); err != nil {
+		return err
+	}
+
+	*final = &result
+	return nil
+}
+
 func parseConstraints(result *[]*structs.Constraint, list *ast.ObjectList) error {
 	for _, o := range list.Elem().Items {
 		var m map[string]interface{}
@@ -349,6 +447,45 @@ func parseConstraints(result *[]*structs.Constraint, list *ast.ObjectList) error
 	return nil
 }
 
+func parseAllocAffinities(affinityType string, result *[]*structs.AllocAffinity, list *ast.ObjectList) error {
+	for _, o := range list.Elem().Items {
+		var m map[string]interface{}
+		if err := hcl.DecodeObject(&m, o.Val); err != nil {
+			return err
+		}
+		delete(m, "meta")
+
+		// Build the affinity
+		a := structs.AllocAffinity{Type: affinityType}
+		if err := mapstructure.WeakDecode(m, &a); err != nil {
+			return err
+		}
+		if a.Mode == "" {
+			a.Mode = structs.AllocAffinityModeHard
+		}
+
+		// Parse out the meta selector. These are in HCL as a list so we
+		// need to iterate over them and merge them.
+		if ot, ok := o.Val.(*ast.ObjectType); ok {
+			if metaO := ot.List.Filter("meta"); len(metaO.Items) > 0 {
+				for _, mo := range metaO.Elem().Items {
+					var meta map[string]interface{}
+					if err := hcl.DecodeObject(&meta, mo.Val); err != nil {
+						return err
+					}
+					if err := mapstructure.WeakDecode(meta, &a.Meta); err != nil {
+						return err
+					}
+				}
+			}
+		}
+
+		*result = append(*result, &a)
+	}
+
+	return nil
+}
+
 // parseBool takes an interface value and tries to convert it to a boolean and
 // returns an error if the type can't be converted.
 func parseBool(value interface{}) (bool, error) {
@@ -402,6 +539,10 @@ func parseTasks(jobName string, taskGroupName string, result *[]*structs.Task, l
 		delete(m, "meta")
 		delete(m, "resources")
 		delete(m, "logs")
+		delete(m, "artifact")
+		delete(m, "template")
+		delete(m, "volume_mount")
+		delete(m, "lifecycle")
 
 		// Build the task
 		var t structs.Task
@@ -503,12 +644,162 @@ func parseTasks(jobName string, taskGroupName string, result *[]*structs.Task, l
 		}
 		t.LogConfig = logConfig
 
+		// Parse artifacts
+		if o := listVal.Filter("artifact"); len(o.Items) > 0 {
+			if err := parseArtifacts(&t.Artifacts, o); err != nil {
+				return fmt.Errorf("task '%s': artifact: %s", n, err)
+			}
+		}
+
+		// Parse templates
+		if o := listVal.Filter("template"); len(o.Items) > 0 {
+			if err := parseTemplates(&t.Templates, o); err != nil {
+				return fmt.Errorf("task '%s': template: %s", n, err)
+			}
+		}
+
+		// Parse volume mounts
+		if o := listVal.Filter("volume_mount"); len(o.Items) > 0 {
+			if err := parseVolumeMounts(&t.VolumeMounts, o); err != nil {
+				return fmt.Errorf("task '%s': volume_mount: %s", n, err)
+			}
+		}
+
+		// Parse the lifecycle
+		if o := listVal.Filter("lifecycle"); len(o.Items) > 0 {
+			if err := parseLifecycle(&t.Lifecycle, o); err != nil {
+				return fmt.Errorf("task '%s': %s", n, err)
+			}
+		}
+
 		*result = append(*result, &t)
 	}
 
 	return nil
 }
 
+func parseArtifacts(result *[]*structs.TaskArtifact, list *ast.ObjectList) error {
+	for _, o := range list.Elem().Items {
+		var m map[string]interface{}
+		if err := hcl.DecodeObject(&m, o.Val); err != nil {
+			return err
+		}
+
+		delete(m, "options")
+		delete(m, "headers")
+
+		var ta structs.TaskArtifact
+		if err := mapstructure.WeakDecode(m, &ta); err != nil {
+			return err
+		}
+
+		var optionList, headerList *ast.ObjectList
+		if ot, ok := o.Val.(*ast.ObjectType); ok {
+			optionList = ot.List.Filter("options")
+			headerList = ot.List.Filter("headers")
+		} else {
+			return fmt.Errorf("artifact should be an object")
+		}
+
+		// Parse the options and headers, which are in HCL as a list so we
+		// need to iterate over them and merge them.
+		for _, o := range optionList.Elem().Items {
+			var m map[string]interface{}
+			if err := hcl.DecodeObject(&m, o.Val); err != nil {
+				return err
+			}
+			if err := mapstructure.WeakDecode(m, &ta.GetterOptions); err != nil {
+				return err
+			}
+		}
+		for _, o := range headerList.Elem().Items {
+			var m map[string]interface{}
+			if err := hcl.DecodeObject(&m, o.Val); err != nil {
+				return err
+			}
+			if err := mapstructure.WeakDecode(m, &ta.GetterHeaders); err != nil {
+				return err
+			}
+		}
+
+		*result = append(*result, &ta)
+	}
+
+	return nil
+}
+
+func parseTemplates(result *[]*structs.Template, list *ast.ObjectList) error {
+	for _, o := range list.Elem().Items {
+		var m map[string]interface{}
+		if err := hcl.DecodeObject(&m, o.Val); err != nil {
+			return err
+		}
+
+		var tmpl structs.Template
+		dec, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+			DecodeHook:       mapstructure.StringToTimeDurationHookFunc(),
+			WeaklyTypedInput: true,
+			Result:           &tmpl,
+		})
+		if err != nil {
+			return err
+		}
+		if err := dec.Decode(m); err != nil {
+			return err
+		}
+
+		*result = append(*result, &tmpl)
+	}
+
+	return nil
+}
+
+func parseVolumes(result *map[string]*structs.VolumeRequest, list *ast.ObjectList) error {
+	list = list.Children()
+	if len(list.Items) == 0 {
+		return nil
+	}
+
+	volumes := make(map[string]*structs.VolumeRequest, len(list.Items))
+	for _, item := range list.Items {
+		n := item.Keys[0].Token.Value().(string)
+		if _, ok := volumes[n]; ok {
+			return fmt.Errorf("volume '%s' defined more than once", n)
+		}
+
+		var m map[string]interface{}
+		if err := hcl.DecodeObject(&m, item.Val); err != nil {
+			return err
+		}
+
+		v := structs.VolumeRequest{Name: n}
+		if err := mapstructure.WeakDecode(m, &v); err != nil {
+			return err
+		}
+		volumes[n] = &v
+	}
+
+	*result = volumes
+	return nil
+}
+
+func parseVolumeMounts(result *[]*structs.VolumeMount, list *ast.ObjectList) error {
+	for _, o := range list.Elem().Items {
+		var m map[string]interface{}
+		if err := hcl.DecodeObject(&m, o.Val); err != nil {
+			return err
+		}
+
+		var vm structs.VolumeMount
+		if err := mapstructure.WeakDecode(m, &vm); err != nil {
+			return err
+		}
+		*result = append(*result, &vm)
+	}
+
+	return nil
+}
+
 func parseServices(jobName string, taskGroupName string, task *structs.Task, serviceObjs *ast.ObjectList) error {
 	task.Services = make([]*structs.Service, len(serviceObjs.Items))
 	var defaultServiceName bool
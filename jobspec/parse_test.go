@@ -84,10 +84,24 @@ func TestParse(t *testing.T) {
 							Delay:    15 * time.Second,
 							Mode:     "delay",
 						},
+						EphemeralDisk: &structs.EphemeralDisk{
+							SizeMB:  150,
+							Sticky:  true,
+							Migrate: true,
+						},
+						Volumes: map[string]*structs.VolumeRequest{
+							"certs": &structs.VolumeRequest{
+								Name:     "certs",
+								Type:     "host",
+								Source:   "ca-certificates",
+								ReadOnly: true,
+							},
+						},
 						Tasks: []*structs.Task{
 							&structs.Task{
 								Name:   "binstore",
 								Driver: "docker",
+								Leader: true,
 								Config: map[string]interface{}{
 									"image": "hashicorp/binstore",
 								},
@@ -113,7 +127,6 @@ func TestParse(t *testing.T) {
 								Resources: &structs.Resources{
 									CPU:      500,
 									MemoryMB: 128,
-									DiskMB:   300,
 									IOPS:     0,
 									Networks: []*structs.NetworkResource{
 										&structs.NetworkResource{
@@ -123,11 +136,49 @@ func TestParse(t *testing.T) {
 										},
 									},
 								},
-								KillTimeout: 22 * time.Second,
+								KillTimeout:   22 * time.Second,
+								KillSignal:    "SIGQUIT",
+								ShutdownDelay: 5 * time.Second,
 								LogConfig: &structs.LogConfig{
 									MaxFiles:      10,
 									MaxFileSizeMB: 100,
 								},
+								Artifacts: []*structs.TaskArtifact{
+									{
+										GetterSource: "http://foo.com/artifact",
+										GetterOptions: map[string]string{
+											"checksum": "md5:b8a4f3f72ecab0510a6a31e997461c5f",
+										},
+										GetterHeaders: map[string]string{
+											"Authorization": "Bearer token",
+										},
+									},
+									{
+										GetterSource: "http://bar.com/artifact.tar.gz",
+										GetterMode:   "dir",
+										RelativeDest: "local/bin",
+									},
+								},
+								Templates: []*structs.Template{
+									{
+										SourcePath:   "local/app.conf.tmpl",
+										DestPath:     "local/app.conf",
+										ChangeMode:   "signal",
+										ChangeSignal: "SIGHUP",
+										Splay:        10 * time.Second,
+										Perms:        "0600",
+									},
+									{
+										EmbeddedTmpl: "addr={{ key \"service/addr\" }}",
+										DestPath:     "local/addr.conf",
+									},
+								},
+								VolumeMounts: []*structs.VolumeMount{
+									&structs.VolumeMount{
+										Volume:      "certs",
+										Destination: "/etc/ssl/certs",
+									},
+								},
 							},
 							&structs.Task{
 								Name:   "storagelocker",
@@ -138,7 +189,6 @@ func TestParse(t *testing.T) {
 								Resources: &structs.Resources{
 									CPU:      500,
 									MemoryMB: 128,
-									DiskMB:   300,
 									IOPS:     30,
 								},
 								Constraints: []*structs.Constraint{
@@ -236,6 +286,103 @@ func TestParse(t *testing.T) {
 			false,
 		},
 
+		{
+			"alloc-affinity.hcl",
+			&structs.Job{
+				ID:       "foo",
+				Name:     "foo",
+				Priority: 50,
+				Region:   "global",
+				Type:     "service",
+				TaskGroups: []*structs.TaskGroup{
+					&structs.TaskGroup{
+						Name:  "cache",
+						Count: 1,
+						Affinities: []*structs.AllocAffinity{
+							&structs.AllocAffinity{
+								Type:  structs.AllocAffinityColocate,
+								Mode:  structs.AllocAffinityModeHard,
+								Job:   "app",
+								Group: "web",
+							},
+							&structs.AllocAffinity{
+								Type: structs.AllocAffinityAvoid,
+								Mode: structs.AllocAffinityModeSoft,
+								Meta: map[string]string{
+									"role": "db",
+								},
+							},
+						},
+					},
+				},
+			},
+			false,
+		},
+
+		{
+			"scaling.hcl",
+			&structs.Job{
+				ID:       "foo",
+				Name:     "foo",
+				Priority: 50,
+				Region:   "global",
+				Type:     "service",
+				TaskGroups: []*structs.TaskGroup{
+					&structs.TaskGroup{
+						Name:  "web",
+						Count: 3,
+						Scaling: &structs.ScalingPolicy{
+							Min: 1,
+							Max: 10,
+						},
+					},
+				},
+			},
+			false,
+		},
+
+		{
+			"lifecycle.hcl",
+			&structs.Job{
+				ID:       "foo",
+				Name:     "foo",
+				Priority: 50,
+				Region:   "global",
+				Type:     "service",
+				TaskGroups: []*structs.TaskGroup{
+					&structs.TaskGroup{
+						Name:  "web",
+						Count: 1,
+						Tasks: []*structs.Task{
+							&structs.Task{
+								Name:      "init",
+								Driver:    "exec",
+								LogConfig: structs.DefaultLogConfig(),
+								Lifecycle: &structs.TaskLifecycleConfig{
+									Hook: structs.TaskLifecycleHookPrestart,
+								},
+							},
+							&structs.Task{
+								Name:      "proxy",
+								Driver:    "exec",
+								LogConfig: structs.DefaultLogConfig(),
+								Lifecycle: &structs.TaskLifecycleConfig{
+									Hook:    structs.TaskLifecycleHookPoststart,
+									Sidecar: true,
+								},
+							},
+							&structs.Task{
+								Name:      "web",
+								Driver:    "exec",
+								LogConfig: structs.DefaultLogConfig(),
+							},
+						},
+					},
+				},
+			},
+			false,
+		},
+
 		{
 			"periodic-cron.hcl",
 			&structs.Job{
@@ -249,6 +396,8 @@ func TestParse(t *testing.T) {
 					SpecType:        structs.PeriodicSpecCron,
 					Spec:            "*/5 * * *",
 					ProhibitOverlap: true,
+					TimeZone:        "Europe/Berlin",
+					CatchUp:         structs.PeriodicCatchUpSkip,
 				},
 			},
 			false,
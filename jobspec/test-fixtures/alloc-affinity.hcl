@@ -0,0 +1,15 @@
+job "foo" {
+    group "cache" {
+        colocate {
+            job = "app"
+            group = "web"
+        }
+
+        avoid {
+            mode = "soft"
+            meta {
+                role = "db"
+            }
+        }
+    }
+}
@@ -37,8 +37,19 @@ job "binstore-storagelocker" {
             delay = "15s"
             mode = "delay"
         }
+        ephemeral_disk {
+            size = 150
+            sticky = true
+            migrate = true
+        }
+        volume "certs" {
+            type = "host"
+            source = "ca-certificates"
+            read_only = true
+        }
         task "binstore" {
             driver = "docker"
+            leader = true
             config {
                 image = "hashicorp/binstore"
             }
@@ -46,6 +57,36 @@ job "binstore-storagelocker" {
                 max_files = 10
                 max_file_size = 100
             }
+            artifact {
+                source = "http://foo.com/artifact"
+                options {
+                    checksum = "md5:b8a4f3f72ecab0510a6a31e997461c5f"
+                }
+                headers {
+                    Authorization = "Bearer token"
+                }
+            }
+            artifact {
+                source = "http://bar.com/artifact.tar.gz"
+                mode = "dir"
+                destination = "local/bin"
+            }
+            template {
+                source = "local/app.conf.tmpl"
+                destination = "local/app.conf"
+                change_mode = "signal"
+                change_signal = "SIGHUP"
+                splay = "10s"
+                perms = "0600"
+            }
+            template {
+                data = "addr={{ key \"service/addr\" }}"
+                destination = "local/addr.conf"
+            }
+            volume_mount {
+                volume = "certs"
+                destination = "/etc/ssl/certs"
+            }
             env {
               HELLO = "world"
               LOREM = "ipsum"
@@ -82,6 +123,8 @@ job "binstore-storagelocker" {
             }
 
             kill_timeout = "22s"
+            kill_signal = "SIGQUIT"
+            shutdown_delay = "5s"
         }
 
         task "storagelocker" {
@@ -0,0 +1,22 @@
+job "foo" {
+    group "web" {
+        task "init" {
+            driver = "exec"
+            lifecycle {
+                hook = "prestart"
+            }
+        }
+
+        task "proxy" {
+            driver = "exec"
+            lifecycle {
+                hook = "poststart"
+                sidecar = true
+            }
+        }
+
+        task "web" {
+            driver = "exec"
+        }
+    }
+}
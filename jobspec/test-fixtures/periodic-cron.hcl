@@ -2,5 +2,7 @@ job "foo" {
     periodic {
         cron = "*/5 * * *"
         prohibit_overlap = true
+        time_zone = "Europe/Berlin"
+        catch_up = "skip"
     }
 }
@@ -0,0 +1,10 @@
+job "foo" {
+    group "web" {
+        count = 3
+
+        scaling {
+            min = 1
+            max = 10
+        }
+    }
+}
@@ -32,7 +32,7 @@ func RunCustom(args []string, commands map[string]cli.CommandFactory) int {
 	commandsInclude := make([]string, 0, len(commands))
 	for k, _ := range commands {
 		switch k {
-		case "executor":
+		case "executor", "container-init":
 		case "syslog":
 		default:
 			commandsInclude = append(commandsInclude, k)
@@ -158,6 +158,16 @@ type Config struct {
 	// complete eventually fails out of the system.
 	EvalDeliveryLimit int
 
+	// EvalFairShareMetaKey is the job meta key that partitions evaluations
+	// into tenants. Evaluations of the same priority are dequeued fairly
+	// across tenants instead of in FIFO order. Fair sharing is disabled if
+	// it is empty.
+	EvalFairShareMetaKey string
+
+	// EvalFairShareWeights is the relative share of evaluations dequeued
+	// for each tenant. Tenants that are not listed have a weight of one.
+	EvalFairShareWeights map[string]int
+
 	// MinHeartbeatTTL is the minimum time between heartbeats.
 	// This is used as a floor to prevent excessive updates.
 	MinHeartbeatTTL time.Duration
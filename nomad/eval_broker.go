@@ -58,8 +58,13 @@ type EvalBroker struct {
 	// blocked tracks the blocked evaluations by JobID in a priority queue
 	blocked map[string]PendingEvaluations
 
-	// ready tracks the ready jobs by scheduler in a priority queue
-	ready map[string]PendingEvaluations
+	// ready tracks the ready jobs by scheduler in a fair queue
+	ready map[string]*fairQueue
+
+	// tenantFn returns the tenant of an evaluation. Evaluations are
+	// dequeued fairly across tenants according to their weights.
+	tenantFn func(*structs.Evaluation) string
+	weights  map[string]int
 
 	// unack is a map of evalID to an un-acknowledged evaluation
 	unack map[string]*unackEval
@@ -76,6 +81,7 @@ type EvalBroker struct {
 // unackEval tracks an unacknowledged evaluation along with the Nack timer
 type unackEval struct {
 	Eval      *structs.Evaluation
+	Tenant    string
 	Token     string
 	NackTimer *time.Timer
 }
@@ -101,15 +107,38 @@ func NewEvalBroker(timeout time.Duration, deliveryLimit int) (*EvalBroker, error
 		evals:         make(map[string]int),
 		jobEvals:      make(map[string]string),
 		blocked:       make(map[string]PendingEvaluations),
-		ready:         make(map[string]PendingEvaluations),
+		ready:         make(map[string]*fairQueue),
 		unack:         make(map[string]*unackEval),
 		waiting:       make(map[string]chan struct{}),
 		timeWait:      make(map[string]*time.Timer),
 	}
 	b.stats.ByScheduler = make(map[string]*SchedulerStats)
+	b.stats.ByTenant = make(map[string]*TenantStats)
 	return b, nil
 }
 
+// SetFairShare is used to dequeue evaluations fairly across tenants. The
+// tenant of an evaluation is determined by tenantFn and tenants are served
+// proportionally to their weight, defaulting to one. This must be called
+// before the broker is enabled.
+func (b *EvalBroker) SetFairShare(tenantFn func(*structs.Evaluation) string, weights map[string]int) {
+	b.l.Lock()
+	defer b.l.Unlock()
+	b.tenantFn = tenantFn
+	b.weights = weights
+}
+
+// tenant returns the tenant of an evaluation
+func (b *EvalBroker) tenant(eval *structs.Evaluation) string {
+	if b.tenantFn == nil {
+		return defaultEvalTenant
+	}
+	if tenant := b.tenantFn(eval); tenant != "" {
+		return tenant
+	}
+	return defaultEvalTenant
+}
+
 // Enabled is used to check if the broker is enabled.
 func (b *EvalBroker) Enabled() bool {
 	b.l.RLock()
@@ -197,15 +226,16 @@ func (b *EvalBroker) enqueueLocked(eval *structs.Evaluation, queue string) {
 	// Find the pending by scheduler class
 	pending, ok := b.ready[queue]
 	if !ok {
-		pending = make([]*structs.Evaluation, 0, 16)
+		pending = newFairQueue(b.weights)
+		b.ready[queue] = pending
 		if _, ok := b.waiting[queue]; !ok {
 			b.waiting[queue] = make(chan struct{}, 1)
 		}
 	}
 
-	// Push onto the heap
-	heap.Push(&pending, eval)
-	b.ready[queue] = pending
+	// Push onto the tenant's queue
+	tenant := b.tenant(eval)
+	pending.Push(tenant, eval)
 
 	// Update the stats
 	b.stats.TotalReady += 1
@@ -215,6 +245,12 @@ func (b *EvalBroker) enqueueLocked(eval *structs.Evaluation, queue string) {
 		b.stats.ByScheduler[queue] = bySched
 	}
 	bySched.Ready += 1
+	byTenant, ok := b.stats.ByTenant[tenant]
+	if !ok {
+		byTenant = &TenantStats{Weight: pending.weight(tenant)}
+		b.stats.ByTenant[tenant] = byTenant
+	}
+	byTenant.Ready += 1
 
 	// Unblock any blocked dequeues
 	select {
@@ -321,10 +357,7 @@ func (b *EvalBroker) scanForSchedulers(schedulers []string) (*structs.Evaluation
 // This assumes locks are held and that this scheduler has work
 func (b *EvalBroker) dequeueForSched(sched string) (*structs.Evaluation, string, error) {
 	// Get the pending queue
-	pending := b.ready[sched]
-	raw := heap.Pop(&pending)
-	b.ready[sched] = pending
-	eval := raw.(*structs.Evaluation)
+	eval, tenant := b.ready[sched].Pop()
 
 	// Generate a UUID for the token
 	token := structs.GenerateUUID()
@@ -337,6 +370,7 @@ func (b *EvalBroker) dequeueForSched(sched string) (*structs.Evaluation, string,
 	// Add to the unack queue
 	b.unack[eval.ID] = &unackEval{
 		Eval:      eval,
+		Tenant:    tenant,
 		Token:     token,
 		NackTimer: nackTimer,
 	}
@@ -350,6 +384,9 @@ func (b *EvalBroker) dequeueForSched(sched string) (*structs.Evaluation, string,
 	bySched := b.stats.ByScheduler[sched]
 	bySched.Ready -= 1
 	bySched.Unacked += 1
+	byTenant := b.stats.ByTenant[tenant]
+	byTenant.Ready -= 1
+	byTenant.Unacked += 1
 
 	return eval, token, nil
 }
@@ -453,6 +490,7 @@ func (b *EvalBroker) Ack(evalID, token string) error {
 	}
 	bySched := b.stats.ByScheduler[queue]
 	bySched.Unacked -= 1
+	b.releaseTenantLocked(unack.Tenant)
 
 	// Cleanup
 	delete(b.unack, evalID)
@@ -499,6 +537,7 @@ func (b *EvalBroker) Nack(evalID, token string) error {
 	b.stats.TotalUnacked -= 1
 	bySched := b.stats.ByScheduler[unack.Eval.Type]
 	bySched.Unacked -= 1
+	b.releaseTenantLocked(unack.Tenant)
 
 	// Check if we've hit the delivery limit, and re-enqueue
 	// in the failedQueue
@@ -510,6 +549,16 @@ func (b *EvalBroker) Nack(evalID, token string) error {
 	return nil
 }
 
+// releaseTenantLocked is used to update the stats of a tenant once one of its
+// evaluations is no longer outstanding. This assumes the lock is held.
+func (b *EvalBroker) releaseTenantLocked(tenant string) {
+	byTenant := b.stats.ByTenant[tenant]
+	byTenant.Unacked -= 1
+	if byTenant.Ready == 0 && byTenant.Unacked == 0 {
+		delete(b.stats.ByTenant, tenant)
+	}
+}
+
 // Flush is used to clear the state of the broker
 func (b *EvalBroker) Flush() {
 	b.l.Lock()
@@ -537,10 +586,11 @@ func (b *EvalBroker) Flush() {
 	b.stats.TotalBlocked = 0
 	b.stats.TotalWaiting = 0
 	b.stats.ByScheduler = make(map[string]*SchedulerStats)
+	b.stats.ByTenant = make(map[string]*TenantStats)
 	b.evals = make(map[string]int)
 	b.jobEvals = make(map[string]string)
 	b.blocked = make(map[string]PendingEvaluations)
-	b.ready = make(map[string]PendingEvaluations)
+	b.ready = make(map[string]*fairQueue)
 	b.unack = make(map[string]*unackEval)
 	b.timeWait = make(map[string]*time.Timer)
 }
@@ -550,6 +600,7 @@ func (b *EvalBroker) Stats() *BrokerStats {
 	// Allocate a new stats struct
 	stats := new(BrokerStats)
 	stats.ByScheduler = make(map[string]*SchedulerStats)
+	stats.ByTenant = make(map[string]*TenantStats)
 
 	b.l.RLock()
 	defer b.l.RUnlock()
@@ -564,6 +615,11 @@ func (b *EvalBroker) Stats() *BrokerStats {
 		*subStatCopy = *subStat
 		stats.ByScheduler[sched] = subStatCopy
 	}
+	for tenant, subStat := range b.stats.ByTenant {
+		subStatCopy := new(TenantStats)
+		*subStatCopy = *subStat
+		stats.ByTenant[tenant] = subStatCopy
+	}
 	return stats
 }
 
@@ -581,6 +637,10 @@ func (b *EvalBroker) EmitStats(period time.Duration, stopCh chan struct{}) {
 				metrics.SetGauge([]string{"nomad", "broker", sched, "ready"}, float32(schedStats.Ready))
 				metrics.SetGauge([]string{"nomad", "broker", sched, "unacked"}, float32(schedStats.Unacked))
 			}
+			for tenant, tenantStats := range stats.ByTenant {
+				metrics.SetGauge([]string{"nomad", "broker", "tenant", tenant, "ready"}, float32(tenantStats.Ready))
+				metrics.SetGauge([]string{"nomad", "broker", "tenant", tenant, "unacked"}, float32(tenantStats.Unacked))
+			}
 
 		case <-stopCh:
 			return
@@ -595,6 +655,7 @@ type BrokerStats struct {
 	TotalBlocked int
 	TotalWaiting int
 	ByScheduler  map[string]*SchedulerStats
+	ByTenant     map[string]*TenantStats
 }
 
 // SchedulerStats returns the stats per scheduler
@@ -603,6 +664,13 @@ type SchedulerStats struct {
 	Unacked int
 }
 
+// TenantStats returns the stats per tenant
+type TenantStats struct {
+	Ready   int
+	Unacked int
+	Weight  int
+}
+
 // Len is for the sorting interface
 func (p PendingEvaluations) Len() int {
 	return len(p)
@@ -639,9 +707,8 @@ func (p *PendingEvaluations) Pop() interface{} {
 
 // Peek is used to peek at the next element that would be popped
 func (p PendingEvaluations) Peek() *structs.Evaluation {
-	n := len(p)
-	if n == 0 {
+	if len(p) == 0 {
 		return nil
 	}
-	return p[n-1]
+	return p[0]
 }
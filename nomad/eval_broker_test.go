@@ -1,6 +1,7 @@
 package nomad
 
 import (
+	"container/heap"
 	"testing"
 	"time"
 
@@ -533,6 +534,128 @@ func TestEvalBroker_Dequeue_Fairness(t *testing.T) {
 	}
 }
 
+// Ensure the highest priority evaluations of the schedulers are compared
+func TestEvalBroker_Dequeue_PriorityAcrossSchedulers(t *testing.T) {
+	b := testBroker(t, 0)
+	b.SetEnabled(true)
+
+	// The service evaluation of the highest priority is enqueued first, so it
+	// isn't the last element of the heap of its scheduler
+	high := mock.Eval()
+	high.Priority = 90
+	b.Enqueue(high)
+	for i := 0; i < 3; i++ {
+		low := mock.Eval()
+		low.Priority = 10
+		b.Enqueue(low)
+	}
+
+	medium := mock.Eval()
+	medium.Type = structs.JobTypeBatch
+	medium.Priority = 50
+	b.Enqueue(medium)
+
+	out, _, err := b.Dequeue(defaultSched, time.Second)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if out != high {
+		t.Fatalf("got eval with priority %d; want %d", out.Priority, high.Priority)
+	}
+}
+
+func TestPendingEvaluations_Peek(t *testing.T) {
+	var pending PendingEvaluations
+	if pending.Peek() != nil {
+		t.Fatalf("expected nothing to peek at")
+	}
+
+	priorities := []int{50, 90, 10, 70, 30}
+	for i, priority := range priorities {
+		eval := mock.Eval()
+		eval.Priority = priority
+		eval.CreateIndex = uint64(i)
+		heap.Push(&pending, eval)
+	}
+
+	// Peek returns the evaluation Pop would, the root of the heap
+	for _, expected := range []int{90, 70, 50, 30, 10} {
+		peeked := pending.Peek()
+		if peeked.Priority != expected {
+			t.Fatalf("peeked priority %d; want %d", peeked.Priority, expected)
+		}
+		if popped := heap.Pop(&pending).(*structs.Evaluation); popped != peeked {
+			t.Fatalf("popped %#v; peeked %#v", popped, peeked)
+		}
+	}
+}
+
+// Ensure fairness between tenants at fixed priority
+func TestEvalBroker_Dequeue_FairShare(t *testing.T) {
+	b := testBroker(t, 0)
+	tenants := map[string]string{}
+	b.SetFairShare(func(eval *structs.Evaluation) string {
+		return tenants[eval.JobID]
+	}, map[string]int{"heavy": 2})
+	b.SetEnabled(true)
+
+	// A single tenant floods the broker before the others enqueue
+	for i := 0; i < 50; i++ {
+		eval := mock.Eval()
+		eval.CreateIndex = uint64(i)
+		tenants[eval.JobID] = "flood"
+		b.Enqueue(eval)
+	}
+	for i := 0; i < 10; i++ {
+		eval := mock.Eval()
+		eval.CreateIndex = uint64(100 + i)
+		tenants[eval.JobID] = "heavy"
+		b.Enqueue(eval)
+
+		eval = mock.Eval()
+		eval.CreateIndex = uint64(200 + i)
+		b.Enqueue(eval)
+	}
+
+	stats := b.Stats()
+	if stats.ByTenant["flood"].Ready != 50 || stats.ByTenant["heavy"].Ready != 10 {
+		t.Fatalf("bad: %#v", stats.ByTenant)
+	}
+	if stats.ByTenant[defaultEvalTenant].Ready != 10 {
+		t.Fatalf("bad: %#v", stats.ByTenant)
+	}
+	if stats.ByTenant["heavy"].Weight != 2 || stats.ByTenant["flood"].Weight != 1 {
+		t.Fatalf("bad: %#v", stats.ByTenant)
+	}
+
+	// The heavy tenant gets twice the share of the others
+	counts := make(map[string]int)
+	for i := 0; i < 20; i++ {
+		out, token, err := b.Dequeue(defaultSched, time.Second)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		tenant := b.tenant(out)
+		counts[tenant] += 1
+		if err := b.Ack(out.ID, token); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+	if counts["heavy"] != 10 || counts["flood"] != 5 || counts[defaultEvalTenant] != 5 {
+		t.Fatalf("bad: %#v", counts)
+	}
+
+	// Higher priority work is still dequeued first
+	eval := mock.Eval()
+	eval.Priority = 80
+	tenants[eval.JobID] = "flood"
+	b.Enqueue(eval)
+	out, _, _ := b.Dequeue(defaultSched, time.Second)
+	if out != eval {
+		t.Fatalf("bad: %#v", out)
+	}
+}
+
 // Ensure we get unblocked
 func TestEvalBroker_Dequeue_Blocked(t *testing.T) {
 	b := testBroker(t, 0)
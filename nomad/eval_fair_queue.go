@@ -0,0 +1,131 @@
+package nomad
+
+import (
+	"container/heap"
+
+	"github.com/hashicorp/nomad/nomad/structs"
+)
+
+const (
+	// defaultEvalTenant is the tenant evaluations are queued under when fair
+	// sharing is disabled or their job does not define the tenant.
+	defaultEvalTenant = "_default"
+)
+
+// fairQueue is a queue of ready evaluations for a single scheduler type that
+// is partitioned by tenant. Within a tenant, evaluations are ordered by
+// priority and then FIFO. Across tenants, the highest priority evaluation is
+// still dequeued first but among tenants at the same priority the tenant that
+// has been served the least relative to its weight goes first. This is
+// implemented using stride scheduling: every dequeue advances the pass of the
+// tenant by the inverse of its weight and the tenant with the lowest pass wins.
+type fairQueue struct {
+	// tenants tracks the pending evaluations of each tenant with work
+	tenants map[string]*tenantQueue
+
+	// weights is the weight of each tenant. Unlisted tenants have a
+	// weight of one.
+	weights map[string]int
+
+	// vtime is the pass of the tenant that was last dequeued from. Tenants
+	// that become active start from it so that they can not bank credit
+	// while idle.
+	vtime float64
+
+	// size is the total number of pending evaluations
+	size int
+}
+
+// tenantQueue is the pending evaluations of a single tenant
+type tenantQueue struct {
+	pending PendingEvaluations
+	pass    float64
+}
+
+// newFairQueue returns an empty fair queue using the given tenant weights.
+func newFairQueue(weights map[string]int) *fairQueue {
+	return &fairQueue{
+		tenants: make(map[string]*tenantQueue),
+		weights: weights,
+	}
+}
+
+// weight returns the weight of the tenant
+func (q *fairQueue) weight(tenant string) int {
+	if w, ok := q.weights[tenant]; ok && w > 0 {
+		return w
+	}
+	return 1
+}
+
+// Len returns the number of pending evaluations
+func (q *fairQueue) Len() int {
+	return q.size
+}
+
+// Push adds an evaluation to the queue of the tenant
+func (q *fairQueue) Push(tenant string, eval *structs.Evaluation) {
+	tq, ok := q.tenants[tenant]
+	if !ok {
+		tq = &tenantQueue{
+			pending: make([]*structs.Evaluation, 0, 16),
+			pass:    q.vtime,
+		}
+		q.tenants[tenant] = tq
+	}
+	heap.Push(&tq.pending, eval)
+	q.size += 1
+}
+
+// next returns the tenant that should be dequeued from next
+func (q *fairQueue) next() (string, *tenantQueue) {
+	var bestTenant string
+	var best *tenantQueue
+	for tenant, tq := range q.tenants {
+		if best == nil {
+			bestTenant, best = tenant, tq
+			continue
+		}
+
+		head, bestHead := tq.pending.Peek(), best.pending.Peek()
+		switch {
+		case head.Priority > bestHead.Priority:
+		case head.Priority < bestHead.Priority:
+			continue
+		case tq.pass < best.pass:
+		case tq.pass > best.pass:
+			continue
+		case tenant > bestTenant:
+			// Break ties deterministically
+			continue
+		}
+		bestTenant, best = tenant, tq
+	}
+	return bestTenant, best
+}
+
+// Peek returns the evaluation that would be dequeued next
+func (q *fairQueue) Peek() *structs.Evaluation {
+	_, tq := q.next()
+	if tq == nil {
+		return nil
+	}
+	return tq.pending.Peek()
+}
+
+// Pop removes the next evaluation and returns it along with its tenant
+func (q *fairQueue) Pop() (*structs.Evaluation, string) {
+	tenant, tq := q.next()
+	if tq == nil {
+		return nil, ""
+	}
+
+	raw := heap.Pop(&tq.pending)
+	q.size -= 1
+	q.vtime = tq.pass
+	tq.pass += 1 / float64(q.weight(tenant))
+	if len(tq.pending) == 0 {
+		delete(q.tenants, tenant)
+	}
+	return raw.(*structs.Evaluation), tenant
+}
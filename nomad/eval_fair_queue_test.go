@@ -0,0 +1,73 @@
+package nomad
+
+import (
+	"testing"
+
+	"github.com/hashicorp/nomad/nomad/mock"
+)
+
+func TestFairQueue_Weights(t *testing.T) {
+	q := newFairQueue(map[string]int{"a": 3})
+	for i := 0; i < 8; i++ {
+		q.Push("a", mock.Eval())
+		q.Push("b", mock.Eval())
+	}
+	if q.Len() != 16 {
+		t.Fatalf("bad: %d", q.Len())
+	}
+
+	counts := make(map[string]int)
+	for i := 0; i < 8; i++ {
+		_, tenant := q.Pop()
+		counts[tenant] += 1
+	}
+	if counts["a"] != 6 || counts["b"] != 2 {
+		t.Fatalf("bad: %#v", counts)
+	}
+}
+
+func TestFairQueue_Priority(t *testing.T) {
+	q := newFairQueue(nil)
+	low := mock.Eval()
+	low.Priority = 10
+	high := mock.Eval()
+	high.Priority = 90
+	q.Push("a", low)
+	q.Push("b", high)
+
+	if out := q.Peek(); out != high {
+		t.Fatalf("bad: %#v", out)
+	}
+	if out, tenant := q.Pop(); out != high || tenant != "b" {
+		t.Fatalf("bad: %#v %s", out, tenant)
+	}
+	if out, tenant := q.Pop(); out != low || tenant != "a" {
+		t.Fatalf("bad: %#v %s", out, tenant)
+	}
+	if out, _ := q.Pop(); out != nil {
+		t.Fatalf("bad: %#v", out)
+	}
+}
+
+func TestFairQueue_NoBankedCredit(t *testing.T) {
+	q := newFairQueue(nil)
+	for i := 0; i < 10; i++ {
+		q.Push("a", mock.Eval())
+	}
+	for i := 0; i < 5; i++ {
+		q.Pop()
+	}
+
+	// A tenant becoming active must not starve the existing one
+	for i := 0; i < 10; i++ {
+		q.Push("b", mock.Eval())
+	}
+	counts := make(map[string]int)
+	for i := 0; i < 4; i++ {
+		_, tenant := q.Pop()
+		counts[tenant] += 1
+	}
+	if counts["a"] != 2 || counts["b"] != 2 {
+		t.Fatalf("bad: %#v", counts)
+	}
+}
@@ -33,6 +33,8 @@ const (
 	AllocSnapshot
 	TimeTableSnapshot
 	PeriodicLaunchSnapshot
+	ScalingEventSnapshot
+	PeriodicLaunchRecordSnapshot
 )
 
 // nomadFSM implements a finite state machine that is used
@@ -125,6 +127,8 @@ func (n *nomadFSM) Apply(log *raft.Log) interface{} {
 		return n.applyUpsertJob(buf[1:], log.Index)
 	case structs.JobDeregisterRequestType:
 		return n.applyDeregisterJob(buf[1:], log.Index)
+	case structs.JobScaleRequestType:
+		return n.applyScaleJob(buf[1:], log.Index)
 	case structs.EvalUpdateRequestType:
 		return n.applyUpdateEval(buf[1:], log.Index)
 	case structs.EvalDeleteRequestType:
@@ -279,12 +283,50 @@ func (n *nomadFSM) applyUpsertJob(buf []byte, index uint64) interface{} {
 				n.logger.Printf("[ERR] nomad.fsm: UpsertPeriodicLaunch failed: %v", err)
 				return err
 			}
+
+			// Record the launch in the history of the parent.
+			record := &structs.PeriodicLaunchRecord{
+				ParentID: parentID,
+				ChildID:  req.Job.ID,
+				Launch:   t,
+				Status:   req.Job.Status,
+			}
+			if err := n.state.UpsertPeriodicLaunchRecord(index, record); err != nil {
+				n.logger.Printf("[ERR] nomad.fsm: UpsertPeriodicLaunchRecord failed: %v", err)
+				return err
+			}
 		}
 	}
 
 	return nil
 }
 
+func (n *nomadFSM) applyScaleJob(buf []byte, index uint64) interface{} {
+	defer metrics.MeasureSince([]string{"nomad", "fsm", "scale_job"}, time.Now())
+	var req structs.JobScaleRequest
+	if err := structs.Decode(buf, &req); err != nil {
+		panic(fmt.Errorf("failed to decode request: %v", err))
+	}
+
+	if err := n.state.ScaleTaskGroup(index, req.Event, req.EnforceIndex, req.JobModifyIndex); err != nil {
+		n.logger.Printf("[ERR] nomad.fsm: ScaleTaskGroup failed: %v", err)
+		return err
+	}
+
+	// Update the periodic dispatcher so that future launches use the new
+	// count.
+	job, err := n.state.JobByID(req.Event.JobID)
+	if err != nil {
+		n.logger.Printf("[ERR] nomad.fsm: JobByID(%v) lookup failed: %v", req.Event.JobID, err)
+		return err
+	}
+	if err := n.periodicDispatcher.Add(job); err != nil {
+		n.logger.Printf("[ERR] nomad.fsm: periodicDispatcher.Add failed: %v", err)
+		return err
+	}
+	return nil
+}
+
 func (n *nomadFSM) applyDeregisterJob(buf []byte, index uint64) interface{} {
 	defer metrics.MeasureSince([]string{"nomad", "fsm", "deregister_job"}, time.Now())
 	var req structs.JobDeregisterRequest
@@ -521,6 +563,24 @@ func (n *nomadFSM) Restore(old io.ReadCloser) error {
 				return err
 			}
 
+		case ScalingEventSnapshot:
+			event := new(structs.ScalingEvent)
+			if err := dec.Decode(event); err != nil {
+				return err
+			}
+			if err := restore.ScalingEventRestore(event); err != nil {
+				return err
+			}
+
+		case PeriodicLaunchRecordSnapshot:
+			record := new(structs.PeriodicLaunchRecord)
+			if err := dec.Decode(record); err != nil {
+				return err
+			}
+			if err := restore.PeriodicLaunchRecordRestore(record); err != nil {
+				return err
+			}
+
 		default:
 			return fmt.Errorf("Unrecognized snapshot type: %v", msgType)
 		}
@@ -575,6 +635,14 @@ func (s *nomadSnapshot) Persist(sink raft.SnapshotSink) error {
 		sink.Cancel()
 		return err
 	}
+	if err := s.persistScalingEvents(sink, encoder); err != nil {
+		sink.Cancel()
+		return err
+	}
+	if err := s.persistPeriodicHistory(sink, encoder); err != nil {
+		sink.Cancel()
+		return err
+	}
 	return nil
 }
 
@@ -740,6 +808,60 @@ func (s *nomadSnapshot) persistPeriodicLaunches(sink raft.SnapshotSink,
 	return nil
 }
 
+func (s *nomadSnapshot) persistScalingEvents(sink raft.SnapshotSink,
+	encoder *codec.Encoder) error {
+	// Get all the scaling events
+	events, err := s.snap.ScalingEvents()
+	if err != nil {
+		return err
+	}
+
+	for {
+		// Get the next item
+		raw := events.Next()
+		if raw == nil {
+			break
+		}
+
+		// Prepare the request struct
+		event := raw.(*structs.ScalingEvent)
+
+		// Write out a scaling event
+		sink.Write([]byte{byte(ScalingEventSnapshot)})
+		if err := encoder.Encode(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *nomadSnapshot) persistPeriodicHistory(sink raft.SnapshotSink,
+	encoder *codec.Encoder) error {
+	// Get all the periodic launch records
+	records, err := s.snap.PeriodicHistory()
+	if err != nil {
+		return err
+	}
+
+	for {
+		// Get the next item
+		raw := records.Next()
+		if raw == nil {
+			break
+		}
+
+		// Prepare the request struct
+		record := raw.(*structs.PeriodicLaunchRecord)
+
+		// Write out a periodic launch record
+		sink.Write([]byte{byte(PeriodicLaunchRecordSnapshot)})
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Release is a no-op, as we just need to GC the pointer
 // to the state store snapshot. There is nothing to explicitly
 // cleanup.
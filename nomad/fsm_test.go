@@ -288,6 +288,102 @@ func TestFSM_RegisterJob(t *testing.T) {
 	}
 }
 
+func TestFSM_RegisterJob_PeriodicChild(t *testing.T) {
+	fsm := testFSM(t)
+
+	parent := mock.PeriodicJob()
+	if err := fsm.State().UpsertJob(1000, parent); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	launch := time.Unix(1000, 0)
+	child := mock.Job()
+	child.ID = fmt.Sprintf("%s%s%d", parent.ID, structs.PeriodicLaunchSuffix, launch.Unix())
+	child.ParentID = parent.ID
+	req := structs.JobRegisterRequest{
+		Job: child,
+	}
+	buf, err := structs.Encode(structs.JobRegisterRequestType, req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	resp := fsm.Apply(makeLog(buf))
+	if resp != nil {
+		t.Fatalf("resp: %v", resp)
+	}
+
+	// Verify the launch was recorded in the history of the parent.
+	out, err := fsm.State().PeriodicHistoryByParent(parent.ID)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(out) != 1 {
+		t.Fatalf("bad: %#v", out)
+	}
+	if out[0].ChildID != child.ID || !out[0].Launch.Equal(launch) ||
+		out[0].Status != structs.JobStatusPending {
+		t.Fatalf("bad: %#v", out[0])
+	}
+}
+
+func TestFSM_ScaleJob(t *testing.T) {
+	fsm := testFSM(t)
+
+	job := mock.Job()
+	if err := fsm.State().UpsertJob(1000, job); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	req := structs.JobScaleRequest{
+		JobID:          job.ID,
+		TaskGroup:      "web",
+		Count:          4,
+		EnforceIndex:   true,
+		JobModifyIndex: 1000,
+		Event: &structs.ScalingEvent{
+			ID:        structs.GenerateUUID(),
+			JobID:     job.ID,
+			TaskGroup: "web",
+			Count:     4,
+			Message:   "scale down",
+		},
+	}
+	buf, err := structs.Encode(structs.JobScaleRequestType, req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	resp := fsm.Apply(makeLog(buf))
+	if resp != nil {
+		t.Fatalf("resp: %v", resp)
+	}
+
+	// Verify the count was updated
+	jobOut, err := fsm.State().JobByID(job.ID)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if jobOut.TaskGroups[0].Count != 4 {
+		t.Fatalf("bad count: %d", jobOut.TaskGroups[0].Count)
+	}
+
+	// Verify the event was recorded
+	events, err := fsm.State().ScalingEventsByJob(job.ID)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(events) != 1 || events[0].Message != "scale down" {
+		t.Fatalf("bad: %#v", events)
+	}
+
+	// Applying with the now stale index returns an error
+	resp = fsm.Apply(makeLog(buf))
+	if _, ok := resp.(error); !ok {
+		t.Fatalf("expected error, got: %v", resp)
+	}
+}
+
 func TestFSM_DeregisterJob(t *testing.T) {
 	fsm := testFSM(t)
 
@@ -881,3 +977,50 @@ func TestFSM_SnapshotRestore_PeriodicLaunches(t *testing.T) {
 		t.Fatalf("bad: \n%#v\n%#v", out2, job2)
 	}
 }
+
+func TestFSM_SnapshotRestore_PeriodicHistory(t *testing.T) {
+	// Add some state
+	fsm := testFSM(t)
+	state := fsm.State()
+	job := mock.PeriodicJob()
+	record := &structs.PeriodicLaunchRecord{
+		ParentID: job.ID,
+		ChildID:  job.ID + structs.PeriodicLaunchSuffix + "1000",
+		EvalID:   structs.GenerateUUID(),
+		Launch:   time.Unix(1000, 0),
+		Status:   structs.JobStatusDead,
+	}
+	state.UpsertPeriodicLaunchRecord(1000, record)
+
+	// Verify the contents
+	fsm2 := testSnapshotRestore(t, fsm)
+	state2 := fsm2.State()
+	out, _ := state2.PeriodicHistoryByParent(job.ID)
+	if len(out) != 1 || !reflect.DeepEqual(record, out[0]) {
+		t.Fatalf("bad: \n%#v\n%#v", out, record)
+	}
+}
+
+func TestFSM_SnapshotRestore_ScalingEvents(t *testing.T) {
+	// Add some state
+	fsm := testFSM(t)
+	state := fsm.State()
+	job := mock.Job()
+	state.UpsertJob(1000, job)
+	event := &structs.ScalingEvent{
+		ID:        structs.GenerateUUID(),
+		JobID:     job.ID,
+		TaskGroup: "web",
+		Count:     5,
+		Meta:      map[string]string{"foo": "bar"},
+	}
+	state.ScaleTaskGroup(1001, event, false, 0)
+
+	// Verify the contents
+	fsm2 := testSnapshotRestore(t, fsm)
+	state2 := fsm2.State()
+	out, _ := state2.ScalingEventsByJob(job.ID)
+	if len(out) != 1 || !reflect.DeepEqual(event, out[0]) {
+		t.Fatalf("bad: \n%#v\n%#v", out, event)
+	}
+}
@@ -89,6 +89,114 @@ func (j *Job) Register(args *structs.JobRegisterRequest, reply *structs.JobRegis
 	return nil
 }
 
+// Scale is used to change the count of a single task group of a job
+func (j *Job) Scale(args *structs.JobScaleRequest, reply *structs.JobScaleResponse) error {
+	if done, err := j.srv.forward("Job.Scale", args, args, reply); done {
+		return err
+	}
+	defer metrics.MeasureSince([]string{"nomad", "job", "scale"}, time.Now())
+
+	// Validate the arguments
+	if args.JobID == "" {
+		return fmt.Errorf("missing job ID for scaling")
+	}
+	if args.TaskGroup == "" {
+		return fmt.Errorf("missing task group for scaling")
+	}
+	if args.Count <= 0 {
+		return fmt.Errorf("task group count must be positive")
+	}
+
+	// Lookup the job
+	snap, err := j.srv.fsm.State().Snapshot()
+	if err != nil {
+		return err
+	}
+	job, err := snap.JobByID(args.JobID)
+	if err != nil {
+		return err
+	}
+	if job == nil {
+		return fmt.Errorf("job not found")
+	}
+	if args.EnforceIndex && job.JobModifyIndex != args.JobModifyIndex {
+		return fmt.Errorf("Enforcing job modify index %d: job exists with conflicting job modify index: %d",
+			args.JobModifyIndex, job.JobModifyIndex)
+	}
+	tg := job.LookupTaskGroup(args.TaskGroup)
+	if tg == nil {
+		return fmt.Errorf("task group %q not found in job %q", args.TaskGroup, args.JobID)
+	}
+	if err := tg.Scaling.ValidateCount(args.Count); err != nil {
+		return err
+	}
+
+	// Periodic jobs are not evaluated directly, their children pick up the new
+	// count on their next launch.
+	var eval *structs.Evaluation
+	if !job.IsPeriodic() {
+		eval = &structs.Evaluation{
+			ID:          structs.GenerateUUID(),
+			Priority:    job.Priority,
+			Type:        job.Type,
+			TriggeredBy: structs.EvalTriggerJobScaling,
+			JobID:       job.ID,
+			Status:      structs.EvalStatusPending,
+		}
+	}
+
+	// Record the scaling event along with the update
+	args.Event = &structs.ScalingEvent{
+		ID:        structs.GenerateUUID(),
+		JobID:     job.ID,
+		TaskGroup: args.TaskGroup,
+		Count:     args.Count,
+		Message:   args.Message,
+		Meta:      args.Meta,
+		Time:      time.Now().UTC(),
+	}
+	if eval != nil {
+		args.Event.EvalID = eval.ID
+	}
+
+	// Commit this update via Raft
+	resp, index, err := j.srv.raftApply(structs.JobScaleRequestType, args)
+	if err == nil {
+		// The index check is repeated when applying the update, surface it
+		// if the job changed in the meantime.
+		err, _ = resp.(error)
+	}
+	if err != nil {
+		j.srv.logger.Printf("[ERR] nomad.job: Scale failed: %v", err)
+		return err
+	}
+
+	// Populate the reply with job information
+	reply.JobModifyIndex = index
+	reply.Index = index
+	if eval == nil {
+		return nil
+	}
+
+	// Commit the evaluation via Raft
+	eval.JobModifyIndex = index
+	update := &structs.EvalUpdateRequest{
+		Evals:        []*structs.Evaluation{eval},
+		WriteRequest: structs.WriteRequest{Region: args.Region},
+	}
+	_, evalIndex, err := j.srv.raftApply(structs.EvalUpdateRequestType, update)
+	if err != nil {
+		j.srv.logger.Printf("[ERR] nomad.job: Eval create failed: %v", err)
+		return err
+	}
+
+	// Populate the reply with eval information
+	reply.EvalID = eval.ID
+	reply.EvalCreateIndex = evalIndex
+	reply.Index = evalIndex
+	return nil
+}
+
 // checkBlacklist returns an error if the user has set any blacklisted field in
 // the job.
 func (j *Job) checkBlacklist(job *structs.Job) error {
@@ -405,3 +513,41 @@ func (j *Job) Evaluations(args *structs.JobSpecificRequest,
 	j.srv.setQueryMeta(&reply.QueryMeta)
 	return nil
 }
+
+// ScalingEvents is used to list the scaling events of a job
+func (j *Job) ScalingEvents(args *structs.JobSpecificRequest,
+	reply *structs.JobScalingEventsResponse) error {
+	if done, err := j.srv.forward("Job.ScalingEvents", args, args, reply); done {
+		return err
+	}
+	defer metrics.MeasureSince([]string{"nomad", "job", "scaling_events"}, time.Now())
+
+	// Setup the blocking query
+	opts := blockingOptions{
+		queryOpts: &args.QueryOptions,
+		queryMeta: &reply.QueryMeta,
+		watch:     watch.NewItems(watch.Item{Job: args.JobID}),
+		run: func() error {
+			// Capture the scaling events
+			snap, err := j.srv.fsm.State().Snapshot()
+			if err != nil {
+				return err
+			}
+			reply.ScalingEvents, err = snap.ScalingEventsByJob(args.JobID)
+			if err != nil {
+				return err
+			}
+
+			// Use the last index that affected the scaling event table
+			index, err := snap.Index("scaling_event")
+			if err != nil {
+				return err
+			}
+			reply.Index = index
+
+			// Set the query response
+			j.srv.setQueryMeta(&reply.QueryMeta)
+			return nil
+		}}
+	return j.srv.blockingRPC(&opts)
+}
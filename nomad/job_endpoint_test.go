@@ -891,3 +891,129 @@ func TestJobEndpoint_Evaluations(t *testing.T) {
 		t.Fatalf("bad: %#v", resp2.Evaluations)
 	}
 }
+
+func TestJobEndpoint_Scale(t *testing.T) {
+	s1 := testServer(t, func(c *Config) {
+		c.NumSchedulers = 0 // Prevent automatic dequeue
+	})
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	testutil.WaitForLeader(t, s1.RPC)
+
+	// Create the register request
+	job := mock.Job()
+	job.TaskGroups[0].Scaling = &structs.ScalingPolicy{Min: 1, Max: 20}
+	reg := &structs.JobRegisterRequest{
+		Job:          job,
+		WriteRequest: structs.WriteRequest{Region: "global"},
+	}
+	var regResp structs.JobRegisterResponse
+	if err := msgpackrpc.CallWithCodec(codec, "Job.Register", reg, &regResp); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Scaling outside of the bounds fails
+	scale := &structs.JobScaleRequest{
+		JobID:        job.ID,
+		TaskGroup:    "web",
+		Count:        21,
+		WriteRequest: structs.WriteRequest{Region: "global"},
+	}
+	var resp structs.JobScaleResponse
+	if err := msgpackrpc.CallWithCodec(codec, "Job.Scale", scale, &resp); err == nil {
+		t.Fatalf("expected error scaling beyond max")
+	}
+
+	// Scaling with a stale index fails
+	scale.Count = 15
+	scale.EnforceIndex = true
+	scale.JobModifyIndex = regResp.JobModifyIndex - 1
+	if err := msgpackrpc.CallWithCodec(codec, "Job.Scale", scale, &resp); err == nil {
+		t.Fatalf("expected error with stale index")
+	}
+
+	// Scale with the current index
+	scale.JobModifyIndex = regResp.JobModifyIndex
+	scale.Message = "requests per second above target"
+	scale.Meta = map[string]string{"rps": "1200"}
+	if err := msgpackrpc.CallWithCodec(codec, "Job.Scale", scale, &resp); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp.Index == 0 || resp.JobModifyIndex == regResp.JobModifyIndex {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	// Check the job was updated
+	state := s1.fsm.State()
+	out, err := state.JobByID(job.ID)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if out.TaskGroups[0].Count != 15 {
+		t.Fatalf("bad count: %d", out.TaskGroups[0].Count)
+	}
+	if out.JobModifyIndex != resp.JobModifyIndex {
+		t.Fatalf("index mis-match")
+	}
+
+	// Lookup the evaluation
+	eval, err := state.EvalByID(resp.EvalID)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if eval == nil {
+		t.Fatalf("expected eval")
+	}
+	if eval.TriggeredBy != structs.EvalTriggerJobScaling {
+		t.Fatalf("bad: %#v", eval)
+	}
+	if eval.JobModifyIndex != resp.JobModifyIndex {
+		t.Fatalf("bad: %#v", eval)
+	}
+
+	// Lookup the scaling events
+	get := &structs.JobSpecificRequest{
+		JobID:        job.ID,
+		QueryOptions: structs.QueryOptions{Region: "global"},
+	}
+	var events structs.JobScalingEventsResponse
+	if err := msgpackrpc.CallWithCodec(codec, "Job.ScalingEvents", get, &events); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(events.ScalingEvents) != 1 {
+		t.Fatalf("bad: %#v", events.ScalingEvents)
+	}
+	event := events.ScalingEvents[0]
+	if event.PreviousCount != 10 || event.Count != 15 || event.TaskGroup != "web" {
+		t.Fatalf("bad: %#v", event)
+	}
+	if event.Message != scale.Message || event.Meta["rps"] != "1200" || event.EvalID != resp.EvalID {
+		t.Fatalf("bad: %#v", event)
+	}
+	if events.Index != resp.JobModifyIndex {
+		t.Fatalf("bad index: %d", events.Index)
+	}
+}
+
+func TestJobEndpoint_Scale_UnknownGroup(t *testing.T) {
+	s1 := testServer(t, nil)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	testutil.WaitForLeader(t, s1.RPC)
+
+	job := mock.Job()
+	if err := s1.fsm.State().UpsertJob(1000, job); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	scale := &structs.JobScaleRequest{
+		JobID:        job.ID,
+		TaskGroup:    "foo",
+		Count:        2,
+		WriteRequest: structs.WriteRequest{Region: "global"},
+	}
+	var resp structs.JobScaleResponse
+	if err := msgpackrpc.CallWithCodec(codec, "Job.Scale", scale, &resp); err == nil {
+		t.Fatalf("expected error scaling unknown task group")
+	}
+}
@@ -190,16 +190,16 @@ func (s *Server) restoreEvals() error {
 
 // restorePeriodicDispatcher is used to restore all periodic jobs into the
 // periodic dispatcher. It also determines if a periodic job should have been
-// created during the leadership transition and force runs them. The periodic
-// dispatcher is maintained only by the leader, so it must be restored anytime a
-// leadership transition takes place.
+// created during the leadership transition and launches the missed instances
+// based on the job's catch up policy. The periodic dispatcher is maintained
+// only by the leader, so it must be restored anytime a leadership transition
+// takes place.
 func (s *Server) restorePeriodicDispatcher() error {
 	iter, err := s.fsm.State().JobsByPeriodic(true)
 	if err != nil {
 		return fmt.Errorf("failed to get periodic jobs: %v", err)
 	}
 
-	now := time.Now()
 	for i := iter.Next(); i != nil; i = iter.Next() {
 		job := i.(*structs.Job)
 		s.periodicDispatcher.Add(job)
@@ -212,23 +212,19 @@ func (s *Server) restorePeriodicDispatcher() error {
 			return fmt.Errorf("failed to get periodic launch time: %v", err)
 		}
 
-		// nextLaunch is the next launch that should occur.
-		nextLaunch := job.Periodic.Next(launch.Launch)
-
-		// We skip force launching the job if  there should be no next launch
-		// (the zero case) or if the next launch time is in the future. If it is
-		// in the future, it will be handled by the periodic dispatcher.
-		if nextLaunch.IsZero() || !nextLaunch.Before(now) {
-			continue
-		}
-
-		if _, err := s.periodicDispatcher.ForceRun(job.ID); err != nil {
-			msg := fmt.Sprintf("force run of periodic job %q failed: %v", job.ID, err)
+		// Launch the job for the launches missed while there was no leader
+		// according to its catch up policy. Launches in the future are handled
+		// by the periodic dispatcher.
+		evals, err := s.periodicDispatcher.CatchUp(job.ID, launch.Launch)
+		if err != nil {
+			msg := fmt.Sprintf("catching up periodic job %q failed: %v", job.ID, err)
 			s.logger.Printf("[ERR] nomad.periodic: %s", msg)
 			return errors.New(msg)
 		}
-		s.logger.Printf("[DEBUG] nomad.periodic: periodic job %q force"+
-			" run during leadership establishment", job.ID)
+		if len(evals) != 0 {
+			s.logger.Printf("[DEBUG] nomad.periodic: periodic job %q launched %d"+
+				" time(s) during leadership establishment", job.ID, len(evals))
+		}
 	}
 
 	return nil
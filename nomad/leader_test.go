@@ -3,6 +3,7 @@ package nomad
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"testing"
 	"time"
 
@@ -466,6 +467,87 @@ func TestLeader_PeriodicDispatcher_Restore_Evals(t *testing.T) {
 	}
 }
 
+func TestLeader_PeriodicDispatcher_Restore_CatchUp(t *testing.T) {
+	s1 := testServer(t, func(c *Config) {
+		c.NumSchedulers = 0
+	})
+	defer s1.Shutdown()
+	testutil.WaitForLeader(t, s1.RPC)
+
+	// Stop the periodic dispatcher and fake its clock such that the next
+	// launch is an hour away.
+	s1.periodicDispatcher.SetEnabled(false)
+	clock := newFakeClock(time.Now().UTC().Truncate(time.Hour).Add(time.Second))
+	s1.periodicDispatcher.now = clock.Now
+
+	// Inject an hourly periodic job that launches all missed launches.
+	job := mock.PeriodicJob()
+	job.Periodic.Spec = "0 * * * *"
+	job.Periodic.TimeZone = "Asia/Kolkata"
+	job.Periodic.CatchUp = structs.PeriodicCatchUpRunAll
+	req := structs.JobRegisterRequest{
+		Job: job,
+	}
+	_, _, err := s1.raftApply(structs.JobRegisterRequestType, req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The job was last launched three hours ago. In Kolkata launches happen
+	// at half past the hour UTC.
+	last := clock.Now().Add(-3 * time.Hour)
+	launch := &structs.PeriodicLaunch{ID: job.ID, Launch: last}
+	if err := s1.fsm.State().UpsertPeriodicLaunch(1000, launch); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Restore the periodic dispatcher.
+	s1.periodicDispatcher.SetEnabled(true)
+	s1.periodicDispatcher.Start()
+	if err := s1.restorePeriodicDispatcher(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Check that a child job was launched for every missed launch.
+	iter, err := s1.fsm.State().JobsByIDPrefix(job.ID + structs.PeriodicLaunchSuffix)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var launches []time.Time
+	for i := iter.Next(); i != nil; i = iter.Next() {
+		child := i.(*structs.Job)
+		l, err := s1.periodicDispatcher.LaunchTime(child.ID)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		launches = append(launches, l)
+	}
+	sort.Sort(times(launches))
+
+	expected := []time.Time{
+		last.Add(29*time.Minute + 59*time.Second),
+		last.Add(89*time.Minute + 59*time.Second),
+		last.Add(149*time.Minute + 59*time.Second),
+	}
+	if len(launches) != len(expected) {
+		t.Fatalf("got launches %v; want %v", launches, expected)
+	}
+	for i, launch := range launches {
+		if !launch.Equal(expected[i]) {
+			t.Fatalf("got launches %v; want %v", launches, expected)
+		}
+	}
+
+	// The launch record tracks the most recent launch.
+	out, err := s1.fsm.State().PeriodicLaunchByID(job.ID)
+	if err != nil || out == nil {
+		t.Fatalf("failed to get periodic launch time: %v", err)
+	}
+	if !out.Launch.Equal(expected[2]) {
+		t.Fatalf("got last launch %v; want %v", out.Launch, expected[2])
+	}
+}
+
 func TestLeader_PeriodicDispatch(t *testing.T) {
 	s1 := testServer(t, func(c *Config) {
 		c.NumSchedulers = 0
@@ -0,0 +1,46 @@
+package nomad
+
+import (
+	"time"
+
+	"github.com/armon/go-metrics"
+	"github.com/hashicorp/nomad/nomad/structs"
+)
+
+// Operator endpoint is used to inspect the internals of the cluster.
+type Operator struct {
+	srv *Server
+}
+
+// SchedulerQueue is used to return the depth of the evaluation queues of the
+// leader by scheduler type and by tenant.
+func (o *Operator) SchedulerQueue(args *structs.GenericRequest, reply *structs.SchedulerQueueResponse) error {
+	if done, err := o.srv.forward("Operator.SchedulerQueue", args, args, reply); done {
+		return err
+	}
+	defer metrics.MeasureSince([]string{"nomad", "operator", "scheduler_queue"}, time.Now())
+
+	stats := o.srv.evalBroker.Stats()
+	reply.TotalReady = stats.TotalReady
+	reply.TotalUnacked = stats.TotalUnacked
+	reply.TotalBlocked = stats.TotalBlocked
+	reply.TotalWaiting = stats.TotalWaiting
+	reply.ByScheduler = make(map[string]*structs.EvalQueueStats, len(stats.ByScheduler))
+	for sched, subStat := range stats.ByScheduler {
+		reply.ByScheduler[sched] = &structs.EvalQueueStats{
+			Ready:   subStat.Ready,
+			Unacked: subStat.Unacked,
+		}
+	}
+	reply.ByTenant = make(map[string]*structs.EvalQueueStats, len(stats.ByTenant))
+	for tenant, subStat := range stats.ByTenant {
+		reply.ByTenant[tenant] = &structs.EvalQueueStats{
+			Ready:   subStat.Ready,
+			Unacked: subStat.Unacked,
+			Weight:  subStat.Weight,
+		}
+	}
+
+	o.srv.setQueryMeta(&reply.QueryMeta)
+	return nil
+}
@@ -0,0 +1,52 @@
+package nomad
+
+import (
+	"testing"
+
+	"github.com/hashicorp/net-rpc-msgpackrpc"
+	"github.com/hashicorp/nomad/nomad/mock"
+	"github.com/hashicorp/nomad/nomad/structs"
+	"github.com/hashicorp/nomad/testutil"
+)
+
+func TestOperatorEndpoint_SchedulerQueue(t *testing.T) {
+	s1 := testServer(t, func(c *Config) {
+		c.NumSchedulers = 0 // Prevent automatic dequeue
+		c.EvalFairShareMetaKey = "team"
+		c.EvalFairShareWeights = map[string]int{"web": 3}
+	})
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	testutil.WaitForLeader(t, s1.RPC)
+
+	// Register a job belonging to a tenant
+	job := mock.Job()
+	job.Meta["team"] = "web"
+	reg := &structs.JobRegisterRequest{
+		Job:          job,
+		WriteRequest: structs.WriteRequest{Region: "global"},
+	}
+	var regResp structs.JobRegisterResponse
+	if err := msgpackrpc.CallWithCodec(codec, "Job.Register", reg, &regResp); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Query the queues
+	req := &structs.GenericRequest{
+		QueryOptions: structs.QueryOptions{Region: "global"},
+	}
+	var resp structs.SchedulerQueueResponse
+	if err := msgpackrpc.CallWithCodec(codec, "Operator.SchedulerQueue", req, &resp); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if resp.TotalReady != 1 {
+		t.Fatalf("bad: %#v", resp)
+	}
+	if stats := resp.ByScheduler[job.Type]; stats == nil || stats.Ready != 1 {
+		t.Fatalf("bad: %#v", resp.ByScheduler)
+	}
+	if stats := resp.ByTenant["web"]; stats == nil || stats.Ready != 1 || stats.Weight != 3 {
+		t.Fatalf("bad: %#v", resp.ByTenant)
+	}
+}
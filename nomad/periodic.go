@@ -12,6 +12,12 @@ import (
 	"github.com/hashicorp/nomad/nomad/structs"
 )
 
+const (
+	// maxPeriodicCatchUpLaunches is the maximum number of missed launches of a
+	// periodic job that are launched when catching up.
+	maxPeriodicCatchUpLaunches = 100
+)
+
 // PeriodicDispatch is used to track and launch periodic jobs. It maintains the
 // set of periodic jobs and creates derived jobs and evaluations per
 // instantiation which is determined by the periodic spec.
@@ -23,6 +29,9 @@ type PeriodicDispatch struct {
 	tracked map[string]*structs.Job
 	heap    *periodicHeap
 
+	// now returns the current time. It is overridden in tests.
+	now func() time.Time
+
 	updateCh chan struct{}
 	stopCh   chan struct{}
 	waitCh   chan struct{}
@@ -133,6 +142,7 @@ func NewPeriodicDispatch(logger *log.Logger, dispatcher JobEvalDispatcher) *Peri
 		dispatcher: dispatcher,
 		tracked:    make(map[string]*structs.Job),
 		heap:       NewPeriodicHeap(),
+		now:        time.Now,
 		updateCh:   make(chan struct{}, 1),
 		stopCh:     make(chan struct{}),
 		waitCh:     make(chan struct{}),
@@ -203,7 +213,7 @@ func (p *PeriodicDispatch) Add(job *structs.Job) error {
 
 	// Add or update the job.
 	p.tracked[job.ID] = job
-	next := job.Periodic.Next(time.Now())
+	next := job.Periodic.Next(p.now())
 	if tracked {
 		if err := p.heap.Update(job, next); err != nil {
 			return fmt.Errorf("failed to update job %v launch time: %v", job.ID, err)
@@ -280,8 +290,71 @@ func (p *PeriodicDispatch) ForceRun(jobID string) (*structs.Evaluation, error) {
 		return nil, fmt.Errorf("can't force run non-tracked job %v", jobID)
 	}
 
+	now := p.now()
 	p.l.Unlock()
-	return p.createEval(job, time.Now())
+	return p.createEval(job, now)
+}
+
+// CatchUp launches the periodic job for the launches missed since the passed
+// last launch time, as determined by the job's catch up policy. It returns the
+// created evaluations.
+func (p *PeriodicDispatch) CatchUp(jobID string, lastLaunch time.Time) ([]*structs.Evaluation, error) {
+	p.l.RLock()
+
+	// Do nothing if not enabled
+	if !p.enabled {
+		p.l.RUnlock()
+		return nil, fmt.Errorf("periodic dispatch disabled")
+	}
+
+	job, tracked := p.tracked[jobID]
+	if !tracked {
+		p.l.RUnlock()
+		return nil, fmt.Errorf("can't catch up non-tracked job %v", jobID)
+	}
+
+	now := p.now()
+	p.l.RUnlock()
+
+	missed := missedLaunches(job.Periodic, lastLaunch, now)
+	if len(missed) == 0 {
+		return nil, nil
+	}
+
+	switch job.Periodic.CatchUp {
+	case structs.PeriodicCatchUpSkip:
+		p.logger.Printf("[DEBUG] nomad.periodic: skipping %d missed launches of periodic job %q",
+			len(missed), job.ID)
+		return nil, nil
+	case structs.PeriodicCatchUpRunAll:
+	default:
+		missed = missed[len(missed)-1:]
+	}
+
+	evals := make([]*structs.Evaluation, 0, len(missed))
+	for _, launch := range missed {
+		p.logger.Printf("[DEBUG] nomad.periodic: launching missed launch of job %v at %v", job.ID, launch)
+		eval, err := p.createEval(job, launch)
+		if err != nil {
+			return evals, err
+		}
+		evals = append(evals, eval)
+	}
+	return evals, nil
+}
+
+// missedLaunches returns the launch times of the periodic config after the last
+// launch and before now, oldest first. At most maxPeriodicCatchUpLaunches of
+// the most recent launches are returned.
+func missedLaunches(periodic *structs.PeriodicConfig, lastLaunch, now time.Time) []time.Time {
+	var missed []time.Time
+	for next := periodic.Next(lastLaunch); !next.IsZero() && next.Before(now); next = periodic.Next(next) {
+		missed = append(missed, next)
+		if len(missed) > maxPeriodicCatchUpLaunches {
+			missed = missed[1:]
+		}
+	}
+	return missed
 }
 
 // shouldRun returns whether the long lived run function should run.
@@ -301,7 +374,7 @@ func (p *PeriodicDispatch) run() {
 		if launch.IsZero() {
 			launchCh = nil
 		} else {
-			launchDur := launch.Sub(time.Now())
+			launchDur := launch.Sub(p.now())
 			launchCh = time.After(launchDur)
 			p.logger.Printf("[DEBUG] nomad.periodic: launching job %q in %s", job.ID, launchDur)
 		}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/armon/go-metrics"
 	"github.com/hashicorp/nomad/nomad/structs"
+	"github.com/hashicorp/nomad/nomad/watch"
 )
 
 // Periodic endpoint is used for periodic job interactions
@@ -53,3 +54,50 @@ func (p *Periodic) Force(args *structs.PeriodicForceRequest, reply *structs.Peri
 	reply.Index = eval.CreateIndex
 	return nil
 }
+
+// History is used to list the launch history of a periodic job, newest first
+func (p *Periodic) History(args *structs.JobSpecificRequest,
+	reply *structs.PeriodicHistoryResponse) error {
+	if done, err := p.srv.forward("Periodic.History", args, args, reply); done {
+		return err
+	}
+	defer metrics.MeasureSince([]string{"nomad", "periodic", "history"}, time.Now())
+
+	// Validate the arguments
+	if args.JobID == "" {
+		return fmt.Errorf("missing job ID")
+	}
+
+	// Setup the blocking query
+	opts := blockingOptions{
+		queryOpts: &args.QueryOptions,
+		queryMeta: &reply.QueryMeta,
+		watch:     watch.NewItems(watch.Item{Job: args.JobID}),
+		run: func() error {
+			// Capture the launch history
+			snap, err := p.srv.fsm.State().Snapshot()
+			if err != nil {
+				return err
+			}
+			launches, err := snap.PeriodicHistoryByParent(args.JobID)
+			if err != nil {
+				return err
+			}
+			reply.Launches = make([]*structs.PeriodicLaunchRecord, 0, len(launches))
+			for i := len(launches) - 1; i >= 0; i-- {
+				reply.Launches = append(reply.Launches, launches[i])
+			}
+
+			// Use the last index that affected the periodic history table
+			index, err := snap.Index("periodic_history")
+			if err != nil {
+				return err
+			}
+			reply.Index = index
+
+			// Set the query response
+			p.srv.setQueryMeta(&reply.QueryMeta)
+			return nil
+		}}
+	return p.srv.blockingRPC(&opts)
+}
@@ -81,3 +81,53 @@ func TestPeriodicEndpoint_Force_NonPeriodic(t *testing.T) {
 		t.Fatalf("Force on non-perodic job should err")
 	}
 }
+
+func TestPeriodicEndpoint_History(t *testing.T) {
+	s1 := testServer(t, func(c *Config) {
+		c.NumSchedulers = 0 // Prevent automatic dequeue
+	})
+	state := s1.fsm.State()
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	testutil.WaitForLeader(t, s1.RPC)
+
+	// Create and insert a periodic job.
+	job := mock.PeriodicJob()
+	if err := state.UpsertJob(100, job); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	s1.periodicDispatcher.Add(job)
+
+	// Force launch it.
+	force := &structs.PeriodicForceRequest{
+		JobID:        job.ID,
+		WriteRequest: structs.WriteRequest{Region: "global"},
+	}
+	var forceResp structs.PeriodicForceResponse
+	if err := msgpackrpc.CallWithCodec(codec, "Periodic.Force", force, &forceResp); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Lookup the history
+	req := &structs.JobSpecificRequest{
+		JobID:        job.ID,
+		QueryOptions: structs.QueryOptions{Region: "global"},
+	}
+	var resp structs.PeriodicHistoryResponse
+	if err := msgpackrpc.CallWithCodec(codec, "Periodic.History", req, &resp); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp.Index == 0 {
+		t.Fatalf("bad index: %d", resp.Index)
+	}
+	if len(resp.Launches) != 1 {
+		t.Fatalf("bad: %#v", resp.Launches)
+	}
+	launch := resp.Launches[0]
+	if launch.ParentID != job.ID || launch.EvalID != forceResp.EvalID {
+		t.Fatalf("bad: %#v", launch)
+	}
+	if launch.Status != structs.JobStatusPending {
+		t.Fatalf("bad: %#v", launch)
+	}
+}
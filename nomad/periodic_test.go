@@ -97,6 +97,39 @@ func testPeriodicJob(times ...time.Time) *structs.Job {
 	return job
 }
 
+// fakeClock is a clock whose time only changes when advanced.
+type fakeClock struct {
+	now time.Time
+	l   sync.Mutex
+}
+
+func newFakeClock(now time.Time) *fakeClock {
+	return &fakeClock{now: now}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.l.Lock()
+	defer c.l.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.l.Lock()
+	defer c.l.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// testCatchUpDispatcher returns an enabled PeriodicDispatcher that uses the
+// passed clock and is not running, so that only catching up launches jobs.
+func testCatchUpDispatcher(clock *fakeClock) (*PeriodicDispatch, *MockJobEvalDispatcher) {
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+	m := NewMockJobEvalDispatcher()
+	d := NewPeriodicDispatch(logger, m)
+	d.now = clock.Now
+	d.SetEnabled(true)
+	return d, m
+}
+
 func TestPeriodicDispatch_Add_NonPeriodic(t *testing.T) {
 	t.Parallel()
 	p, _ := testPeriodicDispatcher()
@@ -300,6 +333,118 @@ func TestPeriodicDispatch_ForceRun_Tracked(t *testing.T) {
 	}
 }
 
+func TestPeriodicDispatch_CatchUp_Untracked(t *testing.T) {
+	t.Parallel()
+	p, _ := testCatchUpDispatcher(newFakeClock(time.Now()))
+
+	if _, err := p.CatchUp("foo", time.Now()); err == nil {
+		t.Fatal("CatchUp of untracked job should fail")
+	}
+}
+
+func TestPeriodicDispatch_CatchUp_Policies(t *testing.T) {
+	t.Parallel()
+	// Five minutes past midnight in New York on the day DST starts.
+	start := time.Date(2016, time.March, 13, 5, 5, 0, 0, time.UTC)
+
+	cases := []struct {
+		policy   string
+		expected []time.Time
+	}{
+		{
+			policy: structs.PeriodicCatchUpSkip,
+		},
+		{
+			policy: "",
+			expected: []time.Time{
+				time.Date(2016, time.March, 13, 8, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			policy: structs.PeriodicCatchUpRunOnce,
+			expected: []time.Time{
+				time.Date(2016, time.March, 13, 8, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			// The launches follow the wall clock of the time zone and the
+			// launch at two, skipped by the DST transition, runs at three.
+			policy: structs.PeriodicCatchUpRunAll,
+			expected: []time.Time{
+				time.Date(2016, time.March, 13, 6, 0, 0, 0, time.UTC),
+				time.Date(2016, time.March, 13, 7, 0, 0, 0, time.UTC),
+				time.Date(2016, time.March, 13, 8, 0, 0, 0, time.UTC),
+			},
+		},
+	}
+
+	for _, c := range cases {
+		clock := newFakeClock(start)
+		p, m := testCatchUpDispatcher(clock)
+
+		// Launch every hour between midnight and four in New York.
+		job := mock.PeriodicJob()
+		job.Periodic.Spec = "0 0-4 * * *"
+		job.Periodic.TimeZone = "America/New_York"
+		job.Periodic.CatchUp = c.policy
+		if err := p.Add(job); err != nil {
+			t.Fatalf("Add failed %v", err)
+		}
+
+		// The job was last launched at midnight and the leader was lost for
+		// three hours.
+		lastLaunch := time.Date(2016, time.March, 13, 5, 0, 0, 0, time.UTC)
+		clock.Advance(3 * time.Hour)
+
+		evals, err := p.CatchUp(job.ID, lastLaunch)
+		if err != nil {
+			t.Fatalf("CatchUp failed %v", err)
+		}
+		if len(evals) != len(c.expected) {
+			t.Fatalf("policy %q: got %d evals; want %d", c.policy, len(evals), len(c.expected))
+		}
+
+		launches, err := m.LaunchTimes(p, job.ID)
+		if err != nil {
+			t.Fatalf("failed to get launch times for job %q: %v", job.ID, err)
+		}
+		if len(launches) != len(c.expected) {
+			t.Fatalf("policy %q: got launches %v; want %v", c.policy, launches, c.expected)
+		}
+		for i, launch := range launches {
+			if !launch.Equal(c.expected[i]) {
+				t.Fatalf("policy %q: got launches %v; want %v", c.policy, launches, c.expected)
+			}
+		}
+	}
+}
+
+func TestPeriodicDispatch_CatchUp_NoMissed(t *testing.T) {
+	t.Parallel()
+	clock := newFakeClock(time.Date(2016, time.June, 1, 12, 10, 0, 0, time.UTC))
+	p, m := testCatchUpDispatcher(clock)
+
+	job := mock.PeriodicJob()
+	job.Periodic.CatchUp = structs.PeriodicCatchUpRunAll
+	if err := p.Add(job); err != nil {
+		t.Fatalf("Add failed %v", err)
+	}
+
+	// The next launch is still in the future.
+	if _, err := p.CatchUp(job.ID, clock.Now().Add(-5*time.Minute)); err != nil {
+		t.Fatalf("CatchUp failed %v", err)
+	}
+	if len(m.Jobs) != 0 {
+		t.Fatalf("unexpected launches: %v", m.Jobs)
+	}
+
+	// The dispatcher schedules the next launch using the clock.
+	_, next := p.nextLaunch()
+	if expected := time.Date(2016, time.June, 1, 12, 30, 0, 0, time.UTC); !next.Equal(expected) {
+		t.Fatalf("next launch %v; want %v", next, expected)
+	}
+}
+
 func TestPeriodicDispatch_Run_DisallowOverlaps(t *testing.T) {
 	t.Parallel()
 	p, m := testPeriodicDispatcher()
@@ -17,6 +17,7 @@ import (
 
 	"github.com/hashicorp/consul/tlsutil"
 	"github.com/hashicorp/nomad/nomad/state"
+	"github.com/hashicorp/nomad/nomad/structs"
 	"github.com/hashicorp/raft"
 	"github.com/hashicorp/raft-boltdb"
 	"github.com/hashicorp/serf/serf"
@@ -145,6 +146,7 @@ type endpoints struct {
 	Region   *Region
 	Periodic *Periodic
 	System   *System
+	Operator *Operator
 }
 
 // NewServer is used to construct a new Nomad server from the
@@ -194,6 +196,11 @@ func NewServer(config *Config) (*Server, error) {
 		shutdownCh:   make(chan struct{}),
 	}
 
+	// Dequeue evaluations fairly across tenants if configured
+	if config.EvalFairShareMetaKey != "" {
+		evalBroker.SetFairShare(s.evalTenant, config.EvalFairShareWeights)
+	}
+
 	// Create the periodic dispatcher for launching periodic jobs.
 	s.periodicDispatcher = NewPeriodicDispatch(s.logger, s)
 
@@ -382,6 +389,7 @@ func (s *Server) setupRPC(tlsWrap tlsutil.DCWrapper) error {
 	s.endpoints.Region = &Region{s}
 	s.endpoints.Periodic = &Periodic{s}
 	s.endpoints.System = &System{s}
+	s.endpoints.Operator = &Operator{s}
 
 	// Register the handlers
 	s.rpcServer.Register(s.endpoints.Status)
@@ -393,6 +401,7 @@ func (s *Server) setupRPC(tlsWrap tlsutil.DCWrapper) error {
 	s.rpcServer.Register(s.endpoints.Region)
 	s.rpcServer.Register(s.endpoints.Periodic)
 	s.rpcServer.Register(s.endpoints.System)
+	s.rpcServer.Register(s.endpoints.Operator)
 
 	list, err := net.ListenTCP("tcp", s.config.RPCAddr)
 	if err != nil {
@@ -425,6 +434,19 @@ func (s *Server) setupRPC(tlsWrap tlsutil.DCWrapper) error {
 	return nil
 }
 
+// evalTenant returns the tenant of an evaluation, which is the value of the
+// configured fair share meta key of its job.
+func (s *Server) evalTenant(eval *structs.Evaluation) string {
+	if s.fsm == nil {
+		return ""
+	}
+	job, err := s.fsm.State().JobByID(eval.JobID)
+	if err != nil || job == nil {
+		return ""
+	}
+	return job.Meta[s.config.EvalFairShareMetaKey]
+}
+
 // setupRaft is used to setup and initialize Raft
 func (s *Server) setupRaft() error {
 	// If we are in bootstrap mode, enable a single node cluster
@@ -20,6 +20,8 @@ func stateStoreSchema() *memdb.DBSchema {
 		nodeTableSchema,
 		jobTableSchema,
 		periodicLaunchTableSchema,
+		periodicHistoryTableSchema,
+		scalingEventTableSchema,
 		evalTableSchema,
 		allocTableSchema,
 	}
@@ -169,6 +171,67 @@ func periodicLaunchTableSchema() *memdb.TableSchema {
 	}
 }
 
+// periodicHistoryTableSchema returns the MemDB schema tracking the launch
+// history of periodic jobs.
+func periodicHistoryTableSchema() *memdb.TableSchema {
+	return &memdb.TableSchema{
+		Name: "periodic_history",
+		Indexes: map[string]*memdb.IndexSchema{
+			// Primary index is the ID of the launched child job.
+			"id": &memdb.IndexSchema{
+				Name:         "id",
+				AllowMissing: false,
+				Unique:       true,
+				Indexer: &memdb.StringFieldIndex{
+					Field:     "ChildID",
+					Lowercase: true,
+				},
+			},
+
+			// Parent index is used to lookup the launches of a periodic job
+			"parent": &memdb.IndexSchema{
+				Name:         "parent",
+				AllowMissing: false,
+				Unique:       false,
+				Indexer: &memdb.StringFieldIndex{
+					Field:     "ParentID",
+					Lowercase: true,
+				},
+			},
+		},
+	}
+}
+
+// scalingEventTableSchema returns the MemDB schema tracking the scaling events
+// of jobs.
+func scalingEventTableSchema() *memdb.TableSchema {
+	return &memdb.TableSchema{
+		Name: "scaling_event",
+		Indexes: map[string]*memdb.IndexSchema{
+			// Primary index is used for direct lookup.
+			"id": &memdb.IndexSchema{
+				Name:         "id",
+				AllowMissing: false,
+				Unique:       true,
+				Indexer: &memdb.UUIDFieldIndex{
+					Field: "ID",
+				},
+			},
+
+			// Job index is used to lookup scaling events by job
+			"job": &memdb.IndexSchema{
+				Name:         "job",
+				AllowMissing: false,
+				Unique:       false,
+				Indexer: &memdb.StringFieldIndex{
+					Field:     "JobID",
+					Lowercase: true,
+				},
+			},
+		},
+	}
+}
+
 // evalTableSchema returns the MemDB schema for the eval table.
 // This table is used to store all the evaluations that are pending
 // or recently completed.
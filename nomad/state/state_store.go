@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"sort"
 	"sync"
 
 	"github.com/hashicorp/go-memdb"
@@ -346,6 +347,8 @@ func (s *StateStore) DeleteJob(index uint64, jobID string) error {
 
 	watcher := watch.NewItems()
 	watcher.Add(watch.Item{Table: "jobs"})
+	watcher.Add(watch.Item{Table: "scaling_event"})
+	watcher.Add(watch.Item{Table: "periodic_history"})
 	watcher.Add(watch.Item{Job: jobID})
 
 	// Delete the node
@@ -356,6 +359,22 @@ func (s *StateStore) DeleteJob(index uint64, jobID string) error {
 		return fmt.Errorf("index update failed: %v", err)
 	}
 
+	// Delete the scaling events of the job
+	if _, err := txn.DeleteAll("scaling_event", "job", jobID); err != nil {
+		return fmt.Errorf("scaling event delete failed: %v", err)
+	}
+	if err := txn.Insert("index", &IndexEntry{"scaling_event", index}); err != nil {
+		return fmt.Errorf("index update failed: %v", err)
+	}
+
+	// Delete the launch history of the job
+	if _, err := txn.DeleteAll("periodic_history", "parent", jobID); err != nil {
+		return fmt.Errorf("periodic history delete failed: %v", err)
+	}
+	if err := txn.Insert("index", &IndexEntry{"periodic_history", index}); err != nil {
+		return fmt.Errorf("index update failed: %v", err)
+	}
+
 	txn.Defer(func() { s.watch.notify(watcher) })
 	txn.Commit()
 	return nil
@@ -532,6 +551,267 @@ func (s *StateStore) PeriodicLaunches() (memdb.ResultIterator, error) {
 	return iter, nil
 }
 
+// UpsertPeriodicLaunchRecord is used to record a launch of a periodic job in
+// its launch history. The oldest launches of the parent beyond the history
+// limit are discarded.
+func (s *StateStore) UpsertPeriodicLaunchRecord(index uint64, record *structs.PeriodicLaunchRecord) error {
+	txn := s.db.Txn(true)
+	defer txn.Abort()
+
+	watcher := watch.NewItems()
+	watcher.Add(watch.Item{Table: "periodic_history"})
+	watcher.Add(watch.Item{Job: record.ParentID})
+
+	// Check if the launch already exists
+	existing, err := txn.First("periodic_history", "id", record.ChildID)
+	if err != nil {
+		return fmt.Errorf("periodic launch record lookup failed: %v", err)
+	}
+
+	// Setup the indexes correctly
+	if existing != nil {
+		record.CreateIndex = existing.(*structs.PeriodicLaunchRecord).CreateIndex
+		record.ModifyIndex = index
+	} else {
+		record.CreateIndex = index
+		record.ModifyIndex = index
+	}
+
+	if err := txn.Insert("periodic_history", record); err != nil {
+		return fmt.Errorf("periodic launch record insert failed: %v", err)
+	}
+
+	// Discard the oldest launches beyond the history limit
+	iter, err := txn.Get("periodic_history", "parent", record.ParentID)
+	if err != nil {
+		return fmt.Errorf("periodic history lookup failed: %v", err)
+	}
+	var records []*structs.PeriodicLaunchRecord
+	for {
+		raw := iter.Next()
+		if raw == nil {
+			break
+		}
+		records = append(records, raw.(*structs.PeriodicLaunchRecord))
+	}
+	if excess := len(records) - structs.PeriodicLaunchHistoryLimit; excess > 0 {
+		sort.Sort(PeriodicLaunchRecordsByIndex(records))
+		for _, r := range records[:excess] {
+			if err := txn.Delete("periodic_history", r); err != nil {
+				return fmt.Errorf("periodic launch record delete failed: %v", err)
+			}
+		}
+	}
+	if err := txn.Insert("index", &IndexEntry{"periodic_history", index}); err != nil {
+		return fmt.Errorf("index update failed: %v", err)
+	}
+
+	txn.Defer(func() { s.watch.notify(watcher) })
+	txn.Commit()
+	return nil
+}
+
+// PeriodicHistoryByParent returns the launch history of a periodic job,
+// oldest first.
+func (s *StateStore) PeriodicHistoryByParent(parentID string) ([]*structs.PeriodicLaunchRecord, error) {
+	txn := s.db.Txn(false)
+
+	iter, err := txn.Get("periodic_history", "parent", parentID)
+	if err != nil {
+		return nil, fmt.Errorf("periodic history lookup failed: %v", err)
+	}
+
+	var out []*structs.PeriodicLaunchRecord
+	for {
+		raw := iter.Next()
+		if raw == nil {
+			break
+		}
+		out = append(out, raw.(*structs.PeriodicLaunchRecord))
+	}
+	sort.Sort(PeriodicLaunchRecordsByIndex(out))
+	return out, nil
+}
+
+// PeriodicHistory returns an iterator over all the periodic launch records
+func (s *StateStore) PeriodicHistory() (memdb.ResultIterator, error) {
+	txn := s.db.Txn(false)
+
+	// Walk the entire table
+	iter, err := txn.Get("periodic_history", "id")
+	if err != nil {
+		return nil, err
+	}
+	return iter, nil
+}
+
+// updatePeriodicLaunchRecord is used to update the launch record of a child
+// of a periodic job within a transaction. Only the non-empty fields of the
+// update are applied; it is a no-op if the job has no launch record.
+func (s *StateStore) updatePeriodicLaunchRecord(txn *memdb.Txn, index uint64, watcher watch.Items,
+	childID, evalID, status string) error {
+	existing, err := txn.First("periodic_history", "id", childID)
+	if err != nil {
+		return fmt.Errorf("periodic launch record lookup failed: %v", err)
+	}
+	if existing == nil {
+		return nil
+	}
+
+	record := existing.(*structs.PeriodicLaunchRecord).Copy()
+	if evalID != "" && record.EvalID == "" {
+		record.EvalID = evalID
+	}
+	if status != "" {
+		record.Status = status
+	}
+	record.ModifyIndex = index
+
+	watcher.Add(watch.Item{Table: "periodic_history"})
+	watcher.Add(watch.Item{Job: record.ParentID})
+	if err := txn.Insert("periodic_history", record); err != nil {
+		return fmt.Errorf("periodic launch record insert failed: %v", err)
+	}
+	if err := txn.Insert("index", &IndexEntry{"periodic_history", index}); err != nil {
+		return fmt.Errorf("index update failed: %v", err)
+	}
+	return nil
+}
+
+// PeriodicLaunchRecordsByIndex sorts periodic launch records by their create
+// index.
+type PeriodicLaunchRecordsByIndex []*structs.PeriodicLaunchRecord
+
+func (s PeriodicLaunchRecordsByIndex) Len() int           { return len(s) }
+func (s PeriodicLaunchRecordsByIndex) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s PeriodicLaunchRecordsByIndex) Less(i, j int) bool { return s[i].CreateIndex < s[j].CreateIndex }
+
+// ScaleTaskGroup is used to change the count of a task group of an existing
+// job and record the scaling event. If enforceIndex is set, the job is only
+// scaled if its JobModifyIndex matches jobModifyIndex.
+func (s *StateStore) ScaleTaskGroup(index uint64, event *structs.ScalingEvent,
+	enforceIndex bool, jobModifyIndex uint64) error {
+	txn := s.db.Txn(true)
+	defer txn.Abort()
+
+	watcher := watch.NewItems()
+	watcher.Add(watch.Item{Table: "jobs"})
+	watcher.Add(watch.Item{Table: "scaling_event"})
+	watcher.Add(watch.Item{Job: event.JobID})
+
+	// Lookup the job
+	existing, err := txn.First("jobs", "id", event.JobID)
+	if err != nil {
+		return fmt.Errorf("job lookup failed: %v", err)
+	}
+	if existing == nil {
+		return fmt.Errorf("job not found")
+	}
+	job := existing.(*structs.Job)
+
+	if enforceIndex && job.JobModifyIndex != jobModifyIndex {
+		return fmt.Errorf("job modify index %d does not match the current index %d",
+			jobModifyIndex, job.JobModifyIndex)
+	}
+
+	// Update a copy of the job with the new count
+	job = job.Copy()
+	tg := job.LookupTaskGroup(event.TaskGroup)
+	if tg == nil {
+		return fmt.Errorf("task group %q not found in job %q", event.TaskGroup, event.JobID)
+	}
+	if err := tg.Scaling.ValidateCount(event.Count); err != nil {
+		return err
+	}
+	event.PreviousCount = tg.Count
+	event.CreateIndex = index
+	tg.Count = event.Count
+	job.ModifyIndex = index
+	job.JobModifyIndex = index
+
+	if err := txn.Insert("jobs", job); err != nil {
+		return fmt.Errorf("job insert failed: %v", err)
+	}
+	if err := txn.Insert("index", &IndexEntry{"jobs", index}); err != nil {
+		return fmt.Errorf("index update failed: %v", err)
+	}
+
+	// Record the event and discard the oldest events of the task group beyond
+	// the tracked limit.
+	if err := txn.Insert("scaling_event", event); err != nil {
+		return fmt.Errorf("scaling event insert failed: %v", err)
+	}
+	iter, err := txn.Get("scaling_event", "job", event.JobID)
+	if err != nil {
+		return fmt.Errorf("scaling event lookup failed: %v", err)
+	}
+	var events []*structs.ScalingEvent
+	for {
+		raw := iter.Next()
+		if raw == nil {
+			break
+		}
+		if e := raw.(*structs.ScalingEvent); e.TaskGroup == event.TaskGroup {
+			events = append(events, e)
+		}
+	}
+	if excess := len(events) - structs.JobTrackedScalingEvents; excess > 0 {
+		sort.Sort(ScalingEventsByIndex(events))
+		for _, e := range events[:excess] {
+			if err := txn.Delete("scaling_event", e); err != nil {
+				return fmt.Errorf("scaling event delete failed: %v", err)
+			}
+		}
+	}
+	if err := txn.Insert("index", &IndexEntry{"scaling_event", index}); err != nil {
+		return fmt.Errorf("index update failed: %v", err)
+	}
+
+	txn.Defer(func() { s.watch.notify(watcher) })
+	txn.Commit()
+	return nil
+}
+
+// ScalingEventsByJob returns the scaling events of a job, oldest first.
+func (s *StateStore) ScalingEventsByJob(jobID string) ([]*structs.ScalingEvent, error) {
+	txn := s.db.Txn(false)
+
+	iter, err := txn.Get("scaling_event", "job", jobID)
+	if err != nil {
+		return nil, fmt.Errorf("scaling event lookup failed: %v", err)
+	}
+
+	var out []*structs.ScalingEvent
+	for {
+		raw := iter.Next()
+		if raw == nil {
+			break
+		}
+		out = append(out, raw.(*structs.ScalingEvent))
+	}
+	sort.Sort(ScalingEventsByIndex(out))
+	return out, nil
+}
+
+// ScalingEvents returns an iterator over all the scaling events
+func (s *StateStore) ScalingEvents() (memdb.ResultIterator, error) {
+	txn := s.db.Txn(false)
+
+	// Walk the entire table
+	iter, err := txn.Get("scaling_event", "id")
+	if err != nil {
+		return nil, err
+	}
+	return iter, nil
+}
+
+// ScalingEventsByIndex sorts scaling events by their create index.
+type ScalingEventsByIndex []*structs.ScalingEvent
+
+func (s ScalingEventsByIndex) Len() int           { return len(s) }
+func (s ScalingEventsByIndex) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s ScalingEventsByIndex) Less(i, j int) bool { return s[i].CreateIndex < s[j].CreateIndex }
+
 // UpsertEvaluation is used to upsert an evaluation
 func (s *StateStore) UpsertEvals(index uint64, evals []*structs.Evaluation) error {
 	txn := s.db.Txn(true)
@@ -548,6 +828,11 @@ func (s *StateStore) UpsertEvals(index uint64, evals []*structs.Evaluation) erro
 			return err
 		}
 
+		// Record the evaluation of a periodic launch
+		if err := s.updatePeriodicLaunchRecord(txn, index, watcher, eval.JobID, eval.ID, ""); err != nil {
+			return err
+		}
+
 		jobs[eval.JobID] = ""
 	}
 
@@ -1064,6 +1349,11 @@ func (s *StateStore) setJobStatus(index uint64, watcher watch.Items, txn *memdb.
 	if err := txn.Insert("index", &IndexEntry{"jobs", index}); err != nil {
 		return fmt.Errorf("index update failed: %v", err)
 	}
+
+	// Keep the launch history of periodic jobs in sync
+	if err := s.updatePeriodicLaunchRecord(txn, index, watcher, job.ID, "", newStatus); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -1196,6 +1486,26 @@ func (r *StateRestore) PeriodicLaunchRestore(launch *structs.PeriodicLaunch) err
 	return nil
 }
 
+// PeriodicLaunchRecordRestore is used to restore a periodic launch record.
+func (r *StateRestore) PeriodicLaunchRecordRestore(record *structs.PeriodicLaunchRecord) error {
+	r.items.Add(watch.Item{Table: "periodic_history"})
+	r.items.Add(watch.Item{Job: record.ParentID})
+	if err := r.txn.Insert("periodic_history", record); err != nil {
+		return fmt.Errorf("periodic launch record insert failed: %v", err)
+	}
+	return nil
+}
+
+// ScalingEventRestore is used to restore a scaling event.
+func (r *StateRestore) ScalingEventRestore(event *structs.ScalingEvent) error {
+	r.items.Add(watch.Item{Table: "scaling_event"})
+	r.items.Add(watch.Item{Job: event.JobID})
+	if err := r.txn.Insert("scaling_event", event); err != nil {
+		return fmt.Errorf("scaling event insert failed: %v", err)
+	}
+	return nil
+}
+
 // stateWatch holds shared state for watching updates. This is
 // outside of StateStore so it can be shared with snapshots.
 type stateWatch struct {
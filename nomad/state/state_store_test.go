@@ -1,6 +1,7 @@
 package state
 
 import (
+	"fmt"
 	"os"
 	"reflect"
 	"sort"
@@ -982,6 +983,325 @@ func TestStateStore_RestorePeriodicLaunch(t *testing.T) {
 	notify.verify(t)
 }
 
+func TestStateStore_ScaleTaskGroup(t *testing.T) {
+	state := testStateStore(t)
+	job := mock.Job()
+	job.TaskGroups[0].Scaling = &structs.ScalingPolicy{Min: 1, Max: 12}
+
+	if err := state.UpsertJob(1000, job); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	notify := setupNotifyTest(
+		state,
+		watch.Item{Table: "jobs"},
+		watch.Item{Table: "scaling_event"},
+		watch.Item{Job: job.ID})
+
+	// A stale index is rejected
+	event := &structs.ScalingEvent{
+		ID:        structs.GenerateUUID(),
+		JobID:     job.ID,
+		TaskGroup: "web",
+		Count:     12,
+	}
+	if err := state.ScaleTaskGroup(1001, event, true, 999); err == nil {
+		t.Fatalf("expected index mismatch")
+	}
+
+	// Counts outside of the scaling bounds are rejected
+	event.Count = 13
+	if err := state.ScaleTaskGroup(1001, event, false, 0); err == nil {
+		t.Fatalf("expected bounds error")
+	}
+
+	event.Count = 12
+	if err := state.ScaleTaskGroup(1001, event, true, 1000); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	out, err := state.JobByID(job.ID)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if out.TaskGroups[0].Count != 12 || out.JobModifyIndex != 1001 || out.CreateIndex != 1000 {
+		t.Fatalf("bad: %#v", out)
+	}
+
+	// The job in the original snapshot is unchanged
+	if job.TaskGroups[0].Count != 10 {
+		t.Fatalf("job modified in place: %#v", job.TaskGroups[0])
+	}
+
+	events, err := state.ScalingEventsByJob(job.ID)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(events) != 1 || events[0].PreviousCount != 10 || events[0].CreateIndex != 1001 {
+		t.Fatalf("bad: %#v", events)
+	}
+
+	index, err := state.Index("scaling_event")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if index != 1001 {
+		t.Fatalf("bad: %d", index)
+	}
+
+	notify.verify(t)
+}
+
+func TestStateStore_ScaleTaskGroup_Prune(t *testing.T) {
+	state := testStateStore(t)
+	job := mock.Job()
+
+	if err := state.UpsertJob(1000, job); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	total := structs.JobTrackedScalingEvents + 5
+	for i := 0; i < total; i++ {
+		event := &structs.ScalingEvent{
+			ID:        structs.GenerateUUID(),
+			JobID:     job.ID,
+			TaskGroup: "web",
+			Count:     i + 1,
+		}
+		if err := state.ScaleTaskGroup(uint64(1001+i), event, false, 0); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	events, err := state.ScalingEventsByJob(job.ID)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(events) != structs.JobTrackedScalingEvents {
+		t.Fatalf("bad: %d", len(events))
+	}
+	if events[0].Count != 6 || events[len(events)-1].Count != total {
+		t.Fatalf("oldest events not pruned: %#v %#v", events[0], events[len(events)-1])
+	}
+
+	// Deleting the job removes its events
+	if err := state.DeleteJob(2000, job.ID); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	events, err = state.ScalingEventsByJob(job.ID)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(events) != 0 {
+		t.Fatalf("bad: %#v", events)
+	}
+}
+
+func TestStateStore_RestoreScalingEvent(t *testing.T) {
+	state := testStateStore(t)
+	job := mock.Job()
+	event := &structs.ScalingEvent{
+		ID:          structs.GenerateUUID(),
+		JobID:       job.ID,
+		TaskGroup:   "web",
+		Count:       3,
+		CreateIndex: 1000,
+	}
+
+	notify := setupNotifyTest(
+		state,
+		watch.Item{Table: "scaling_event"},
+		watch.Item{Job: job.ID})
+
+	restore, err := state.Restore()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	err = restore.ScalingEventRestore(event)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	restore.Commit()
+
+	out, err := state.ScalingEventsByJob(job.ID)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if len(out) != 1 || !reflect.DeepEqual(out[0], event) {
+		t.Fatalf("Bad: %#v %#v", out, event)
+	}
+
+	notify.verify(t)
+}
+
+func TestStateStore_UpsertPeriodicLaunchRecord(t *testing.T) {
+	state := testStateStore(t)
+	parent := mock.PeriodicJob()
+	if err := state.UpsertJob(1000, parent); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	child := parent.Copy()
+	child.ID = parent.ID + structs.PeriodicLaunchSuffix + "1"
+	child.ParentID = parent.ID
+	child.Periodic = nil
+	if err := state.UpsertJob(1001, child); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	record := &structs.PeriodicLaunchRecord{
+		ParentID: parent.ID,
+		ChildID:  child.ID,
+		Launch:   time.Now(),
+		Status:   structs.JobStatusPending,
+	}
+
+	notify := setupNotifyTest(
+		state,
+		watch.Item{Table: "periodic_history"},
+		watch.Item{Job: parent.ID})
+
+	if err := state.UpsertPeriodicLaunchRecord(1001, record); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Creating the evaluation of the child records its ID
+	eval := mock.Eval()
+	eval.JobID = child.ID
+	if err := state.UpsertEvals(1002, []*structs.Evaluation{eval}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Completing the evaluation updates the status of the child
+	eval = eval.Copy()
+	eval.Status = structs.EvalStatusComplete
+	if err := state.UpsertEvals(1003, []*structs.Evaluation{eval}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	out, err := state.PeriodicHistoryByParent(parent.ID)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(out) != 1 {
+		t.Fatalf("bad: %#v", out)
+	}
+	if out[0].EvalID != eval.ID || out[0].Status != structs.JobStatusDead {
+		t.Fatalf("bad: %#v", out[0])
+	}
+	if out[0].CreateIndex != 1001 || out[0].ModifyIndex != 1003 {
+		t.Fatalf("bad: %#v", out[0])
+	}
+
+	// The record survives the garbage collection of the child
+	if err := state.DeleteJob(1004, child.ID); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	out, err = state.PeriodicHistoryByParent(parent.ID)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(out) != 1 || out[0].Status != structs.JobStatusDead {
+		t.Fatalf("bad: %#v", out)
+	}
+
+	index, err := state.Index("periodic_history")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if index != 1004 {
+		t.Fatalf("bad: %d", index)
+	}
+
+	notify.verify(t)
+}
+
+func TestStateStore_UpsertPeriodicLaunchRecord_Prune(t *testing.T) {
+	state := testStateStore(t)
+	parent := mock.PeriodicJob()
+	if err := state.UpsertJob(1000, parent); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	total := structs.PeriodicLaunchHistoryLimit + 5
+	for i := 0; i < total; i++ {
+		record := &structs.PeriodicLaunchRecord{
+			ParentID: parent.ID,
+			ChildID:  fmt.Sprintf("%s%s%d", parent.ID, structs.PeriodicLaunchSuffix, i),
+			Launch:   time.Unix(int64(i), 0),
+		}
+		if err := state.UpsertPeriodicLaunchRecord(uint64(1001+i), record); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	out, err := state.PeriodicHistoryByParent(parent.ID)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(out) != structs.PeriodicLaunchHistoryLimit {
+		t.Fatalf("bad: %d", len(out))
+	}
+	if out[0].Launch.Unix() != 5 || out[len(out)-1].Launch.Unix() != int64(total-1) {
+		t.Fatalf("oldest launches not pruned: %#v %#v", out[0], out[len(out)-1])
+	}
+
+	// Deleting the parent removes its history
+	if err := state.DeleteJob(2000, parent.ID); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	out, err = state.PeriodicHistoryByParent(parent.ID)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(out) != 0 {
+		t.Fatalf("bad: %#v", out)
+	}
+}
+
+func TestStateStore_RestorePeriodicLaunchRecord(t *testing.T) {
+	state := testStateStore(t)
+	job := mock.PeriodicJob()
+	record := &structs.PeriodicLaunchRecord{
+		ParentID:    job.ID,
+		ChildID:     job.ID + structs.PeriodicLaunchSuffix + "1",
+		EvalID:      structs.GenerateUUID(),
+		Launch:      time.Now(),
+		Status:      structs.JobStatusDead,
+		CreateIndex: 1000,
+		ModifyIndex: 1001,
+	}
+
+	notify := setupNotifyTest(
+		state,
+		watch.Item{Table: "periodic_history"},
+		watch.Item{Job: job.ID})
+
+	restore, err := state.Restore()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	err = restore.PeriodicLaunchRecordRestore(record)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	restore.Commit()
+
+	out, err := state.PeriodicHistoryByParent(job.ID)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if len(out) != 1 || !reflect.DeepEqual(out[0], record) {
+		t.Fatalf("Bad: %#v %#v", out, record)
+	}
+
+	notify.verify(t)
+}
+
 func TestStateStore_Indexes(t *testing.T) {
 	state := testStateStore(t)
 	node := mock.Node()
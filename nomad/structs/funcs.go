@@ -192,6 +192,19 @@ func CopySliceString(s []string) []string {
 	return c
 }
 
+func CopySliceAllocAffinities(s []*AllocAffinity) []*AllocAffinity {
+	l := len(s)
+	if l == 0 {
+		return nil
+	}
+
+	c := make([]*AllocAffinity, l)
+	for i, v := range s {
+		c[i] = v.Copy()
+	}
+	return c
+}
+
 func CopySliceConstraints(s []*Constraint) []*Constraint {
 	l := len(s)
 	if l == 0 {
@@ -2,6 +2,7 @@ package structs
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
 
 	"github.com/mitchellh/hashstructure"
@@ -11,8 +12,27 @@ const (
 	// NodeUniqueNamespace is a prefix that can be appended to node meta or
 	// attribute keys to mark them for exclusion in computed node class.
 	NodeUniqueNamespace = "unique."
+
+	// LXCBaseAttrPrefix is the prefix of the node attributes advertising the
+	// base containers cached by the lxc driver. They change as the cache
+	// fills and evicts so they are excluded from the computed node class.
+	LXCBaseAttrPrefix = NodeUniqueNamespace + "driver.lxc.base."
 )
 
+// lxcInvalidNameChars matches the characters not allowed in container names.
+var lxcInvalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9._-]`)
+
+// LXCBaseKey returns the key of the lxc base container built from the
+// template, as used in the names of the base containers and the
+// LXCBaseAttrPrefix node attributes.
+func LXCBaseKey(template, distro, release, arch string) string {
+	parts := []string{template, distro, release, arch}
+	for i, part := range parts {
+		parts[i] = lxcInvalidNameChars.ReplaceAllString(part, "_")
+	}
+	return strings.Join(parts, "-")
+}
+
 // UniqueNamespace takes a key and returns the key marked under the unique
 // namespace.
 func UniqueNamespace(key string) string {
@@ -42,7 +62,7 @@ func (n *Node) ComputeClass() error {
 // included in the computed node class.
 func (n Node) HashInclude(field string, v interface{}) (bool, error) {
 	switch field {
-	case "Datacenter", "Attributes", "Meta", "NodeClass":
+	case "Datacenter", "Attributes", "Meta", "NodeClass", "HostVolumes":
 		return true, nil
 	default:
 		return false, nil
@@ -60,6 +80,8 @@ func (n Node) HashIncludeMap(field string, k, v interface{}) (bool, error) {
 	switch field {
 	case "Meta", "Attributes":
 		return !IsUniqueNamespace(key), nil
+	case "HostVolumes":
+		return true, nil
 	default:
 		return false, fmt.Errorf("unexpected map field: %v", field)
 	}
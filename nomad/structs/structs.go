@@ -2,12 +2,19 @@ package structs
 
 import (
 	"bytes"
+	"crypto/md5"
 	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
 	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -16,6 +23,7 @@ import (
 	"github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/go-version"
 	"github.com/hashicorp/nomad/helper/args"
+	"github.com/hashicorp/nomad/helper/signals"
 	"github.com/mitchellh/copystructure"
 	"github.com/ugorji/go/codec"
 
@@ -40,6 +48,7 @@ const (
 	EvalDeleteRequestType
 	AllocUpdateRequestType
 	AllocClientUpdateRequestType
+	JobScaleRequestType
 )
 
 const (
@@ -193,6 +202,29 @@ type JobEvaluateRequest struct {
 	WriteRequest
 }
 
+// JobScaleRequest is used for the Job.Scale endpoint to change the count of
+// a single task group without resubmitting the job.
+type JobScaleRequest struct {
+	JobID     string
+	TaskGroup string
+	Count     int
+
+	// If EnforceIndex is set then the job will only be scaled if the passed
+	// JobModifyIndex matches the current Jobs index.
+	EnforceIndex   bool
+	JobModifyIndex uint64
+
+	// Message and Meta describe the reason for the scaling action and are
+	// recorded in the scaling event.
+	Message string
+	Meta    map[string]string
+
+	// Event is populated by the server and is the scaling event to record.
+	Event *ScalingEvent
+
+	WriteRequest
+}
+
 // JobSpecificRequest is used when we just need to specify a target job
 type JobSpecificRequest struct {
 	JobID string
@@ -332,6 +364,27 @@ type JobDeregisterResponse struct {
 	QueryMeta
 }
 
+// JobScaleResponse is used to respond to a job scaling request
+type JobScaleResponse struct {
+	EvalID          string
+	EvalCreateIndex uint64
+	JobModifyIndex  uint64
+	QueryMeta
+}
+
+// JobScalingEventsResponse is used to return the scaling events of a job
+type JobScalingEventsResponse struct {
+	ScalingEvents []*ScalingEvent
+	QueryMeta
+}
+
+// PeriodicHistoryResponse is used to return the launch history of a
+// periodic job
+type PeriodicHistoryResponse struct {
+	Launches []*PeriodicLaunchRecord
+	QueryMeta
+}
+
 // NodeUpdateResponse is used to respond to a node update
 type NodeUpdateResponse struct {
 	HeartbeatTTL    time.Duration
@@ -453,6 +506,27 @@ type PeriodicForceResponse struct {
 	WriteMeta
 }
 
+// EvalQueueStats is the depth of a queue of evaluations
+type EvalQueueStats struct {
+	Ready   int
+	Unacked int
+
+	// Weight is the fair share weight of a tenant's queue
+	Weight int `json:",omitempty"`
+}
+
+// SchedulerQueueResponse is used to return the depth of the evaluation
+// queues of the leader by scheduler type and by tenant
+type SchedulerQueueResponse struct {
+	TotalReady   int
+	TotalUnacked int
+	TotalBlocked int
+	TotalWaiting int
+	ByScheduler  map[string]*EvalQueueStats
+	ByTenant     map[string]*EvalQueueStats
+	QueryMeta
+}
+
 const (
 	NodeStatusInit  = "initializing"
 	NodeStatusReady = "ready"
@@ -525,6 +599,10 @@ type Node struct {
 	// client. This is opaque to Nomad.
 	Meta map[string]string
 
+	// HostVolumes is the set of host directories the client exposes to
+	// tasks, keyed by the volume name.
+	HostVolumes map[string]*ClientHostVolumeConfig
+
 	// NodeClass is an opaque identifier used to group nodes
 	// together for the purpose of determining scheduling pressure.
 	NodeClass string
@@ -560,9 +638,32 @@ func (n *Node) Copy() *Node {
 	nn.Reserved = nn.Reserved.Copy()
 	nn.Links = CopyMapStringString(nn.Links)
 	nn.Meta = CopyMapStringString(nn.Meta)
+	if n.HostVolumes != nil {
+		nn.HostVolumes = make(map[string]*ClientHostVolumeConfig, len(n.HostVolumes))
+		for name, hv := range n.HostVolumes {
+			nn.HostVolumes[name] = hv.Copy()
+		}
+	}
 	return nn
 }
 
+// ClientHostVolumeConfig is a directory on the client that can be mounted
+// into tasks.
+type ClientHostVolumeConfig struct {
+	Name     string
+	Path     string
+	ReadOnly bool
+}
+
+func (hv *ClientHostVolumeConfig) Copy() *ClientHostVolumeConfig {
+	if hv == nil {
+		return nil
+	}
+	nhv := new(ClientHostVolumeConfig)
+	*nhv = *hv
+	return nhv
+}
+
 // TerminalStatus returns if the current status is terminal and
 // will no longer transition.
 func (n *Node) TerminalStatus() bool {
@@ -618,7 +719,6 @@ func DefaultResources() *Resources {
 	return &Resources{
 		CPU:      100,
 		MemoryMB: 10,
-		DiskMB:   300,
 		IOPS:     0,
 	}
 }
@@ -652,9 +752,6 @@ func (r *Resources) MeetsMinResources() error {
 	if r.MemoryMB < 10 {
 		mErr.Errors = append(mErr.Errors, fmt.Errorf("minimum MemoryMB value is 10; got %d", r.MemoryMB))
 	}
-	if r.DiskMB < 10 {
-		mErr.Errors = append(mErr.Errors, fmt.Errorf("minimum DiskMB value is 10; got %d", r.DiskMB))
-	}
 	if r.IOPS < 0 {
 		mErr.Errors = append(mErr.Errors, fmt.Errorf("minimum IOPS value is 0; got %d", r.IOPS))
 	}
@@ -1088,6 +1185,19 @@ const (
 	PeriodicSpecTest = "_internal_test"
 )
 
+const (
+	// PeriodicCatchUpSkip does not launch the job for launches missed while
+	// there was no leader.
+	PeriodicCatchUpSkip = "skip"
+
+	// PeriodicCatchUpRunOnce launches the job once for the most recent missed
+	// launch. This is the default.
+	PeriodicCatchUpRunOnce = "run-once"
+
+	// PeriodicCatchUpRunAll launches the job for every missed launch.
+	PeriodicCatchUpRunAll = "run-all"
+)
+
 // Periodic defines the interval a job should be run at.
 type PeriodicConfig struct {
 	// Enabled determines if the job should be run periodically.
@@ -1102,6 +1212,14 @@ type PeriodicConfig struct {
 
 	// ProhibitOverlap enforces that spawned jobs do not run in parallel.
 	ProhibitOverlap bool `mapstructure:"prohibit_overlap"`
+
+	// TimeZone is the IANA name of the time zone the spec is evaluated in.
+	// If unset the spec is evaluated in UTC.
+	TimeZone string `mapstructure:"time_zone"`
+
+	// CatchUp is the policy applied to launches missed while there was no
+	// leader to launch the job.
+	CatchUp string `mapstructure:"catch_up"`
 }
 
 func (p *PeriodicConfig) Copy() *PeriodicConfig {
@@ -1134,19 +1252,48 @@ func (p *PeriodicConfig) Validate() error {
 		return fmt.Errorf("Unknown periodic specification type %q", p.SpecType)
 	}
 
+	if _, err := p.GetLocation(); err != nil {
+		return fmt.Errorf("Invalid time zone %q: %v", p.TimeZone, err)
+	}
+
+	switch p.CatchUp {
+	case "", PeriodicCatchUpSkip, PeriodicCatchUpRunOnce:
+	case PeriodicCatchUpRunAll:
+		if p.ProhibitOverlap {
+			return fmt.Errorf("Catch up policy %q can not be used when prohibiting overlap", p.CatchUp)
+		}
+	default:
+		return fmt.Errorf("Unknown catch up policy %q", p.CatchUp)
+	}
+
 	return nil
 }
 
+// GetLocation returns the location the spec is evaluated in.
+func (p *PeriodicConfig) GetLocation() (*time.Location, error) {
+	if p.TimeZone == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(p.TimeZone)
+}
+
 // Next returns the closest time instant matching the spec that is after the
-// passed time. If no matching instance exists, the zero value of time.Time is
+// passed time. Cron specs are evaluated on the wall clock of the configured
+// time zone. If no matching instance exists, the zero value of time.Time is
 // returned. The `time.Location` of the returned value matches that of the
 // passed time.
 func (p *PeriodicConfig) Next(fromTime time.Time) time.Time {
 	switch p.SpecType {
 	case PeriodicSpecCron:
-		if e, err := cronexpr.Parse(p.Spec); err == nil {
-			return e.Next(fromTime)
+		e, err := cronexpr.Parse(p.Spec)
+		if err != nil {
+			break
+		}
+		loc, err := p.GetLocation()
+		if err != nil {
+			break
 		}
+		return cronNext(e, fromTime, loc)
 	case PeriodicSpecTest:
 		split := strings.Split(p.Spec, ",")
 		if len(split) == 1 && split[0] == "" {
@@ -1175,6 +1322,44 @@ func (p *PeriodicConfig) Next(fromTime time.Time) time.Time {
 	return time.Time{}
 }
 
+// cronNext returns the next time after fromTime matching the cron expression
+// on the wall clock of the location. cronexpr does not handle daylight saving
+// transitions, so the expression is evaluated against the wall clock expressed
+// in UTC and the result converted back. Wall clock times skipped by a
+// transition launch at the equivalent time after it and wall clock times that
+// repeat only launch once.
+func cronNext(e *cronexpr.Expression, fromTime time.Time, loc *time.Location) time.Time {
+	wall := wallClock(fromTime.In(loc))
+	for {
+		next := e.Next(wall)
+		if next.IsZero() {
+			return next
+		}
+
+		// A wall clock time skipped by a transition is normalized to a time
+		// before it, move it past the transition.
+		t := time.Date(next.Year(), next.Month(), next.Day(), next.Hour(),
+			next.Minute(), next.Second(), 0, loc)
+		if skipped := next.Sub(wallClock(t)); skipped > 0 {
+			t = t.Add(skipped)
+		}
+
+		if t.After(fromTime) {
+			return t.In(fromTime.Location())
+		}
+
+		// The wall clock time maps to an instant that is not after fromTime,
+		// which happens when the wall clock is set back.
+		wall = next
+	}
+}
+
+// wallClock returns the wall clock of the time expressed in UTC.
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(),
+		t.Second(), t.Nanosecond(), time.UTC)
+}
+
 const (
 	// PeriodicLaunchSuffix is the string appended to the periodic jobs ID
 	// when launching derived instances of it.
@@ -1191,6 +1376,37 @@ type PeriodicLaunch struct {
 	ModifyIndex uint64
 }
 
+const (
+	// PeriodicLaunchHistoryLimit is the number of launches retained in the
+	// history of a periodic job. Older launches are discarded.
+	PeriodicLaunchHistoryLimit = 25
+)
+
+// PeriodicLaunchRecord records a single launch of a periodic job.
+type PeriodicLaunchRecord struct {
+	ParentID string    // ID of the periodic job.
+	ChildID  string    // ID of the launched child job.
+	EvalID   string    // ID of the evaluation created for the child.
+	Launch   time.Time // The launch time.
+
+	// Status is the status of the child job. It is retained once the child
+	// job is garbage collected.
+	Status string
+
+	// Raft Indexes
+	CreateIndex uint64
+	ModifyIndex uint64
+}
+
+func (p *PeriodicLaunchRecord) Copy() *PeriodicLaunchRecord {
+	if p == nil {
+		return nil
+	}
+	np := new(PeriodicLaunchRecord)
+	*np = *p
+	return np
+}
+
 var (
 	defaultServiceJobRestartPolicy = RestartPolicy{
 		Delay:    15 * time.Second,
@@ -1216,6 +1432,84 @@ const (
 	RestartPolicyModeFail = "fail"
 )
 
+// ScalingPolicy bounds the count a task group can be scaled to.
+type ScalingPolicy struct {
+	// Min is the minimum count of the task group. Task groups can't be
+	// scaled to zero, so it must be at least one.
+	Min int
+
+	// Max is the maximum count of the task group
+	Max int
+}
+
+func (s *ScalingPolicy) Copy() *ScalingPolicy {
+	if s == nil {
+		return nil
+	}
+	ns := new(ScalingPolicy)
+	*ns = *s
+	return ns
+}
+
+func (s *ScalingPolicy) Validate() error {
+	var mErr multierror.Error
+	if s.Min <= 0 {
+		mErr.Errors = append(mErr.Errors, fmt.Errorf("Minimum count must be positive: %d", s.Min))
+	}
+	if s.Max <= 0 {
+		mErr.Errors = append(mErr.Errors, fmt.Errorf("Maximum count must be positive: %d", s.Max))
+	}
+	if s.Min > s.Max {
+		mErr.Errors = append(mErr.Errors, fmt.Errorf("Minimum count %d is greater than maximum count %d", s.Min, s.Max))
+	}
+	return mErr.ErrorOrNil()
+}
+
+// ValidateCount returns an error if the count is outside of the bounds of the
+// policy.
+func (s *ScalingPolicy) ValidateCount(count int) error {
+	if s == nil {
+		return nil
+	}
+	if count < s.Min || count > s.Max {
+		return fmt.Errorf("Task group count %d is outside of the scaling bounds [%d, %d]", count, s.Min, s.Max)
+	}
+	return nil
+}
+
+const (
+	// JobTrackedScalingEvents is the number of scaling events retained per
+	// task group. Older events are discarded.
+	JobTrackedScalingEvents = 20
+)
+
+// ScalingEvent records a change to the count of a task group made through
+// the Job.Scale endpoint.
+type ScalingEvent struct {
+	ID        string
+	JobID     string
+	TaskGroup string
+
+	// PreviousCount is the count of the task group before the scaling action
+	// and Count the count it was scaled to.
+	PreviousCount int
+	Count         int
+
+	// Message and Meta are provided by the caller to describe the scaling
+	// action.
+	Message string
+	Meta    map[string]string
+
+	// EvalID is the evaluation created by the scaling action, if any.
+	EvalID string
+
+	// Time is the time of the scaling action.
+	Time time.Time
+
+	// Raft Indexes
+	CreateIndex uint64
+}
+
 // RestartPolicy configures how Tasks are restarted when they crash or fail.
 type RestartPolicy struct {
 	// Attempts is the number of restart that will occur in an interval.
@@ -1290,6 +1584,13 @@ type TaskGroup struct {
 	// all the tasks contained.
 	Constraints []*Constraint
 
+	// Affinities express co-location with or avoidance of the allocations of
+	// other jobs and task groups.
+	Affinities []*AllocAffinity
+
+	// Scaling optionally bounds the count the task group can be scaled to.
+	Scaling *ScalingPolicy
+
 	//RestartPolicy of a TaskGroup
 	RestartPolicy *RestartPolicy
 
@@ -1299,6 +1600,14 @@ type TaskGroup struct {
 	// Meta is used to associate arbitrary metadata with this
 	// task group. This is opaque to Nomad.
 	Meta map[string]string
+
+	// Volumes is the set of volumes the tasks of the group can mount, keyed
+	// by the volume name.
+	Volumes map[string]*VolumeRequest
+
+	// EphemeralDisk is the disk shared by the tasks of the group in the
+	// allocation directory.
+	EphemeralDisk *EphemeralDisk
 }
 
 func (tg *TaskGroup) Copy() *TaskGroup {
@@ -1308,8 +1617,11 @@ func (tg *TaskGroup) Copy() *TaskGroup {
 	ntg := new(TaskGroup)
 	*ntg = *tg
 	ntg.Constraints = CopySliceConstraints(ntg.Constraints)
+	ntg.Affinities = CopySliceAllocAffinities(ntg.Affinities)
+	ntg.Scaling = ntg.Scaling.Copy()
 
 	ntg.RestartPolicy = ntg.RestartPolicy.Copy()
+	ntg.EphemeralDisk = ntg.EphemeralDisk.Copy()
 
 	tasks := make([]*Task, len(ntg.Tasks))
 	for i, t := range ntg.Tasks {
@@ -1318,6 +1630,13 @@ func (tg *TaskGroup) Copy() *TaskGroup {
 	ntg.Tasks = tasks
 
 	ntg.Meta = CopyMapStringString(ntg.Meta)
+
+	if tg.Volumes != nil {
+		ntg.Volumes = make(map[string]*VolumeRequest, len(tg.Volumes))
+		for name, v := range tg.Volumes {
+			ntg.Volumes[name] = v.Copy()
+		}
+	}
 	return ntg
 }
 
@@ -1328,6 +1647,29 @@ func (tg *TaskGroup) InitFields(job *Job) {
 		tg.RestartPolicy = NewRestartPolicy(job.Type)
 	}
 
+	// Affinities are enforced unless explicitly marked as soft.
+	for _, aff := range tg.Affinities {
+		if aff.Mode == "" {
+			aff.Mode = AllocAffinityModeHard
+		}
+	}
+
+	// The disk is accounted for by the ephemeral disk of the group, so move
+	// the disk requested by the tasks to it.
+	var diskMB int
+	for _, task := range tg.Tasks {
+		if task.Resources != nil {
+			diskMB += task.Resources.DiskMB
+			task.Resources.DiskMB = 0
+		}
+	}
+	if tg.EphemeralDisk == nil {
+		tg.EphemeralDisk = DefaultEphemeralDisk()
+		if diskMB > 0 {
+			tg.EphemeralDisk.SizeMB = diskMB
+		}
+	}
+
 	for _, task := range tg.Tasks {
 		task.InitFields(job, tg)
 	}
@@ -1351,6 +1693,20 @@ func (tg *TaskGroup) Validate() error {
 			mErr.Errors = append(mErr.Errors, outer)
 		}
 	}
+	for idx, aff := range tg.Affinities {
+		if err := aff.Validate(); err != nil {
+			outer := fmt.Errorf("Affinity %d validation failed: %s", idx+1, err)
+			mErr.Errors = append(mErr.Errors, outer)
+		}
+	}
+	if tg.Scaling != nil {
+		if err := tg.Scaling.Validate(); err != nil {
+			outer := fmt.Errorf("Scaling policy validation failed: %s", err)
+			mErr.Errors = append(mErr.Errors, outer)
+		} else if err := tg.Scaling.ValidateCount(tg.Count); err != nil {
+			mErr.Errors = append(mErr.Errors, err)
+		}
+	}
 
 	if tg.RestartPolicy != nil {
 		if err := tg.RestartPolicy.Validate(); err != nil {
@@ -1372,12 +1728,212 @@ func (tg *TaskGroup) Validate() error {
 		}
 	}
 
+	// Validate the volumes
+	for name, v := range tg.Volumes {
+		if err := v.Validate(); err != nil {
+			outer := fmt.Errorf("Volume %q validation failed: %s", name, err)
+			mErr.Errors = append(mErr.Errors, outer)
+		}
+	}
+
+	// Validate the group has a main task for the lifecycle tasks to run
+	// around
+	if len(tg.Tasks) != 0 {
+		main := false
+		for _, task := range tg.Tasks {
+			if task.Lifecycle == nil {
+				main = true
+				break
+			}
+		}
+		if !main {
+			mErr.Errors = append(mErr.Errors, fmt.Errorf("Task Group %v should have a task without a lifecycle", tg.Name))
+		}
+	}
+
+	// Validate there is at most one leader
+	leaders := 0
+	for _, task := range tg.Tasks {
+		if task.Leader {
+			leaders++
+		}
+	}
+	if leaders > 1 {
+		mErr.Errors = append(mErr.Errors, fmt.Errorf("Task Group %v should have at most one leader task", tg.Name))
+	}
+
 	// Validate the tasks
 	for idx, task := range tg.Tasks {
 		if err := task.Validate(); err != nil {
 			outer := fmt.Errorf("Task %d validation failed: %s", idx+1, err)
 			mErr.Errors = append(mErr.Errors, outer)
 		}
+
+		// Validate the task only mounts volumes of the group
+		for _, vm := range task.VolumeMounts {
+			if _, ok := tg.Volumes[vm.Volume]; !ok {
+				mErr.Errors = append(mErr.Errors, fmt.Errorf("Task %d mounts volume %q which is not defined by the task group", idx+1, vm.Volume))
+			}
+		}
+
+		// Validate the logs of the task fit on the ephemeral disk
+		if task.LogConfig != nil && tg.EphemeralDisk != nil {
+			logUsage := (task.LogConfig.MaxFiles * task.LogConfig.MaxFileSizeMB)
+			if tg.EphemeralDisk.SizeMB <= logUsage {
+				mErr.Errors = append(mErr.Errors,
+					fmt.Errorf("Task %d log storage (%d MB) exceeds requested disk capacity (%d MB)",
+						idx+1, logUsage, tg.EphemeralDisk.SizeMB))
+			}
+		}
+	}
+
+	if tg.EphemeralDisk != nil {
+		if err := tg.EphemeralDisk.Validate(); err != nil {
+			mErr.Errors = append(mErr.Errors, err)
+		}
+	} else {
+		mErr.Errors = append(mErr.Errors, fmt.Errorf("Task Group %v should have an ephemeral disk object", tg.Name))
+	}
+	return mErr.ErrorOrNil()
+}
+
+const (
+	// DefaultEphemeralDiskMB is the default size of the ephemeral disk.
+	DefaultEphemeralDiskMB = 300
+)
+
+// EphemeralDisk is the disk of a task group in the allocation directory. Its
+// data is lost when the allocation is replaced unless it's sticky or migrated.
+type EphemeralDisk struct {
+	// Sticky prefers placing the replacement of an allocation on the node of
+	// the allocation it replaces and keeps the data when it does.
+	Sticky bool
+
+	// SizeMB is the size of the disk.
+	SizeMB int `mapstructure:"size"`
+
+	// Migrate moves the data to the replacement of an allocation even if it
+	// is placed on another node.
+	Migrate bool
+}
+
+// DefaultEphemeralDisk returns the ephemeral disk used if the task group
+// doesn't specify one.
+func DefaultEphemeralDisk() *EphemeralDisk {
+	return &EphemeralDisk{
+		SizeMB: DefaultEphemeralDiskMB,
+	}
+}
+
+func (d *EphemeralDisk) Copy() *EphemeralDisk {
+	if d == nil {
+		return nil
+	}
+	nd := new(EphemeralDisk)
+	*nd = *d
+	return nd
+}
+
+// Validate validates the ephemeral disk.
+func (d *EphemeralDisk) Validate() error {
+	if d.SizeMB < 10 {
+		return fmt.Errorf("minimum ephemeral disk size is 10 MB; got %d", d.SizeMB)
+	}
+	return nil
+}
+
+// HostVolumes returns the host volume requests of the task group keyed by the
+// name of the client host volume. Requests of the same host volume are merged
+// and the merged request is read only only if all of them are.
+func (tg *TaskGroup) HostVolumes() map[string]*VolumeRequest {
+	volumes := make(map[string]*VolumeRequest)
+	for _, v := range tg.Volumes {
+		if v.Type != VolumeTypeHost {
+			continue
+		}
+		if existing, ok := volumes[v.Source]; ok {
+			existing.ReadOnly = existing.ReadOnly && v.ReadOnly
+			continue
+		}
+		volumes[v.Source] = v.Copy()
+	}
+	return volumes
+}
+
+const (
+	// VolumeTypeHost is a volume backed by a host volume of the client.
+	VolumeTypeHost = "host"
+)
+
+// VolumeRequest is a volume requested by a task group.
+type VolumeRequest struct {
+	// Name is the name the tasks mount the volume by.
+	Name string
+
+	// Type is the type of the volume. Only host volumes are supported.
+	Type string
+
+	// Source is the name of the host volume on the client.
+	Source string
+
+	// ReadOnly mounts the volume read only in all tasks.
+	ReadOnly bool `mapstructure:"read_only"`
+}
+
+func (v *VolumeRequest) Copy() *VolumeRequest {
+	if v == nil {
+		return nil
+	}
+	nv := new(VolumeRequest)
+	*nv = *v
+	return nv
+}
+
+// Validate is used to sanity check a volume request
+func (v *VolumeRequest) Validate() error {
+	var mErr multierror.Error
+	if v.Type != VolumeTypeHost {
+		mErr.Errors = append(mErr.Errors, fmt.Errorf("Unsupported volume type %q", v.Type))
+	}
+	if v.Source == "" {
+		mErr.Errors = append(mErr.Errors, fmt.Errorf("Volume must specify a source"))
+	}
+	return mErr.ErrorOrNil()
+}
+
+// VolumeMount mounts a volume of the task group into the task.
+type VolumeMount struct {
+	// Volume is the name of the task group volume to mount.
+	Volume string
+
+	// Destination is the path the volume is mounted at in the task's file
+	// system. Drivers without file system isolation mount it relative to the
+	// task directory.
+	Destination string
+
+	// ReadOnly mounts the volume read only.
+	ReadOnly bool `mapstructure:"read_only"`
+}
+
+func (vm *VolumeMount) Copy() *VolumeMount {
+	if vm == nil {
+		return nil
+	}
+	nvm := new(VolumeMount)
+	*nvm = *vm
+	return nvm
+}
+
+// Validate is used to sanity check a volume mount
+func (vm *VolumeMount) Validate() error {
+	var mErr multierror.Error
+	if vm.Volume == "" {
+		mErr.Errors = append(mErr.Errors, fmt.Errorf("Volume mount must specify a volume"))
+	}
+	if vm.Destination == "" {
+		mErr.Errors = append(mErr.Errors, fmt.Errorf("Volume mount must specify a destination"))
+	} else if dest := filepath.Clean(strings.TrimPrefix(vm.Destination, "/")); dest == "." || dest == ".." || strings.HasPrefix(dest, ".."+string(filepath.Separator)) {
+		mErr.Errors = append(mErr.Errors, fmt.Errorf("Volume mount destination %q escapes the task directory", vm.Destination))
 	}
 	return mErr.ErrorOrNil()
 }
@@ -1392,6 +1948,17 @@ func (tg *TaskGroup) LookupTask(name string) *Task {
 	return nil
 }
 
+// LookupLeader returns the leader task of the task group or nil if it has
+// none.
+func (tg *TaskGroup) LookupLeader() *Task {
+	for _, t := range tg.Tasks {
+		if t.Leader {
+			return t
+		}
+	}
+	return nil
+}
+
 func (tg *TaskGroup) GoString() string {
 	return fmt.Sprintf("*%#v", *tg)
 }
@@ -1598,8 +2165,36 @@ type Task struct {
 	// killed and killing it.
 	KillTimeout time.Duration `mapstructure:"kill_timeout"`
 
+	// KillSignal is the signal sent to the task to stop it, e.g. "SIGTERM".
+	// If empty the driver stops the task the way it does by default.
+	KillSignal string `mapstructure:"kill_signal"`
+
+	// ShutdownDelay is the time to wait between deregistering the services
+	// of the task and signalling it to stop, letting in-flight requests
+	// drain.
+	ShutdownDelay time.Duration `mapstructure:"shutdown_delay"`
+
 	// LogConfig provides configuration for log rotation
 	LogConfig *LogConfig `mapstructure:"logs"`
+
+	// Artifacts is a list of artifacts to download and extract before running
+	// the task.
+	Artifacts []*TaskArtifact
+
+	// Templates are the set of templates to be rendered for the task.
+	Templates []*Template
+
+	// VolumeMounts are the volumes of the task group mounted into the task.
+	VolumeMounts []*VolumeMount
+
+	// Lifecycle determines when the task is run relative to the main tasks
+	// of the task group. A nil lifecycle marks a main task.
+	Lifecycle *TaskLifecycleConfig
+
+	// Leader marks the task as the leader of the task group. The sibling
+	// tasks are stopped once the leader exits and the status of the
+	// allocation is determined by the leader alone.
+	Leader bool
 }
 
 func (t *Task) Copy() *Task {
@@ -1620,6 +2215,32 @@ func (t *Task) Copy() *Task {
 	nt.Resources = nt.Resources.Copy()
 	nt.Meta = CopyMapStringString(nt.Meta)
 
+	if t.Artifacts != nil {
+		artifacts := make([]*TaskArtifact, 0, len(t.Artifacts))
+		for _, a := range nt.Artifacts {
+			artifacts = append(artifacts, a.Copy())
+		}
+		nt.Artifacts = artifacts
+	}
+
+	if t.Templates != nil {
+		templates := make([]*Template, len(t.Templates))
+		for i, tmpl := range nt.Templates {
+			templates[i] = tmpl.Copy()
+		}
+		nt.Templates = templates
+	}
+
+	if t.VolumeMounts != nil {
+		mounts := make([]*VolumeMount, len(t.VolumeMounts))
+		for i, vm := range nt.VolumeMounts {
+			mounts[i] = vm.Copy()
+		}
+		nt.VolumeMounts = mounts
+	}
+
+	nt.Lifecycle = nt.Lifecycle.Copy()
+
 	if i, err := copystructure.Copy(nt.Config); err != nil {
 		nt.Config = i.(map[string]interface{})
 	}
@@ -1635,6 +2256,10 @@ func (t *Task) InitFields(job *Job, tg *TaskGroup) {
 	if t.KillTimeout == 0 {
 		t.KillTimeout = DefaultKillTimeout
 	}
+
+	for _, tmpl := range t.Templates {
+		tmpl.InitFields()
+	}
 }
 
 // InitServiceFields interpolates values of Job, Task Group
@@ -1707,11 +2332,74 @@ const (
 
 	// Task Killed indicates a user has killed the task.
 	TaskKilled = "Killed"
-)
 
-// TaskEvent is an event that effects the state of a task and contains meta-data
-// appropriate to the events type.
-type TaskEvent struct {
+	// TaskDownloadingArtifacts means the task is downloading the artifacts
+	// specified in the task.
+	TaskDownloadingArtifacts = "Downloading Artifacts"
+
+	// TaskArtifactDownloadFailed indicates that downloading the artifacts
+	// failed.
+	TaskArtifactDownloadFailed = "Failed Artifact Download"
+
+	// TaskSetupFailure indicates that the task could not be started due to a
+	// failure while setting up its environment, e.g. rendering its templates.
+	TaskSetupFailure = "Setup Failure"
+
+	// TaskRestartSignal indicates that the task has been signalled to be
+	// restarted.
+	TaskRestartSignal = "Restart Signaled"
+
+	// TaskSignaling indicates that the task is being signalled.
+	TaskSignaling = "Signaling"
+
+	// TaskSiblingFailed indicates that the task wasn't run or was stopped
+	// because a sibling task it depends on failed.
+	TaskSiblingFailed = "Sibling Task Failed"
+
+	// TaskSiblingExited indicates that the task is being stopped because the
+	// leader task of its task group exited.
+	TaskSiblingExited = "Sibling Task Exited"
+
+	// TaskRestarting indicates that the task exited and will be restarted by
+	// its restart policy after a delay.
+	TaskRestarting = "Restarting"
+
+	// TaskNotRestarting indicates that the task exited and its restart policy
+	// doesn't allow it to be restarted.
+	TaskNotRestarting = "Not Restarting"
+
+	// TaskKilling indicates that the task is being stopped and will be killed
+	// if it doesn't exit within its kill timeout.
+	TaskKilling = "Killing"
+
+	// TaskDiskExceeded indicates that the task is being killed because the
+	// allocation used more disk than its ephemeral disk allows.
+	TaskDiskExceeded = "Disk Resources Exceeded"
+
+	// TaskMemoryExceeded indicates that the task was killed because it used
+	// more memory than its resources allow.
+	TaskMemoryExceeded = "Memory Resources Exceeded"
+
+	// TaskPipelineStep indicates that a step of the pipeline run by the task
+	// exited.
+	TaskPipelineStep = "Pipeline Step"
+
+	// TaskPipelineArtifact indicates that an artifact of the pipeline run by
+	// the task was uploaded, or failed to be.
+	TaskPipelineArtifact = "Pipeline Artifact"
+
+	// TaskPullingImage indicates the progress of the pull of the image of the
+	// task.
+	TaskPullingImage = "Pulling Image"
+
+	// TaskImagePullStalled indicates that the pull of the image of the task
+	// was abandoned because it made no progress.
+	TaskImagePullStalled = "Image Pull Stalled"
+)
+
+// TaskEvent is an event that effects the state of a task and contains meta-data
+// appropriate to the events type.
+type TaskEvent struct {
 	Type string
 	Time int64 // Unix Nanosecond timestamp
 
@@ -1725,6 +2413,49 @@ type TaskEvent struct {
 
 	// Task Killed Fields.
 	KillError string // Error killing the task.
+
+	// Artifact Download fields
+	DownloadError string // Error downloading artifacts
+
+	// Setup Failure fields.
+	SetupError string // An error occured while setting up the task.
+
+	// Restart fields.
+	RestartReason string // The reason the task was restarted or not restarted.
+	StartDelay    int64  // The delay before the task is restarted, in nanoseconds.
+
+	// Killing fields.
+	KillTimeout time.Duration // The time given to the task to exit before it is killed.
+
+	// Signal fields.
+	TaskSignal       string // The signal sent to the task.
+	TaskSignalReason string // The reason the task was signalled.
+
+	// Sibling Failed fields.
+	FailedSibling string // The name of the sibling task that failed.
+
+	// Sibling Exited fields.
+	ExitedSibling string // The name of the leader task that exited.
+
+	// Disk Resources Exceeded fields.
+	DiskLimit int64 // The size of the ephemeral disk in MB.
+	DiskSize  int64 // The disk usage of the allocation in MB.
+
+	// Memory Resources Exceeded fields.
+	MemoryLimit int64 // The memory limit of the task in MB.
+
+	// Pipeline Step fields. The exit code and error of the step are the
+	// ExitCode and Message.
+	StepName     string        // The name of the step.
+	StepDuration time.Duration // How long the step ran.
+
+	// Pipeline Artifact fields. The upload error is the Message.
+	Artifact string // The path of the artifact.
+
+	// Image pull fields.
+	Image        string        // The image being pulled.
+	PullProgress string        // The progress of the pull.
+	PullTimeout  time.Duration // How long the pull may make no progress.
 }
 
 func (te *TaskEvent) Copy() *TaskEvent {
@@ -1774,6 +2505,100 @@ func (e *TaskEvent) SetKillError(err error) *TaskEvent {
 	return e
 }
 
+func (e *TaskEvent) SetDownloadError(err error) *TaskEvent {
+	if err != nil {
+		e.DownloadError = err.Error()
+	}
+	return e
+}
+
+func (e *TaskEvent) SetSetupError(err error) *TaskEvent {
+	if err != nil {
+		e.SetupError = err.Error()
+	}
+	return e
+}
+
+func (e *TaskEvent) SetRestartReason(r string) *TaskEvent {
+	e.RestartReason = r
+	return e
+}
+
+func (e *TaskEvent) SetRestartDelay(delay time.Duration) *TaskEvent {
+	e.StartDelay = int64(delay)
+	return e
+}
+
+func (e *TaskEvent) SetKillTimeout(timeout time.Duration) *TaskEvent {
+	e.KillTimeout = timeout
+	return e
+}
+
+func (e *TaskEvent) SetDiskLimit(limit int64) *TaskEvent {
+	e.DiskLimit = limit
+	return e
+}
+
+func (e *TaskEvent) SetDiskSize(size int64) *TaskEvent {
+	e.DiskSize = size
+	return e
+}
+
+func (e *TaskEvent) SetMemoryLimit(limit int64) *TaskEvent {
+	e.MemoryLimit = limit
+	return e
+}
+
+func (e *TaskEvent) SetStepName(name string) *TaskEvent {
+	e.StepName = name
+	return e
+}
+
+func (e *TaskEvent) SetStepDuration(d time.Duration) *TaskEvent {
+	e.StepDuration = d
+	return e
+}
+
+func (e *TaskEvent) SetArtifact(path string) *TaskEvent {
+	e.Artifact = path
+	return e
+}
+
+func (e *TaskEvent) SetImage(image string) *TaskEvent {
+	e.Image = image
+	return e
+}
+
+func (e *TaskEvent) SetPullProgress(progress string) *TaskEvent {
+	e.PullProgress = progress
+	return e
+}
+
+func (e *TaskEvent) SetPullTimeout(timeout time.Duration) *TaskEvent {
+	e.PullTimeout = timeout
+	return e
+}
+
+func (e *TaskEvent) SetTaskSignal(s os.Signal) *TaskEvent {
+	e.TaskSignal = s.String()
+	return e
+}
+
+func (e *TaskEvent) SetTaskSignalReason(r string) *TaskEvent {
+	e.TaskSignalReason = r
+	return e
+}
+
+func (e *TaskEvent) SetFailedSibling(sibling string) *TaskEvent {
+	e.FailedSibling = sibling
+	return e
+}
+
+func (e *TaskEvent) SetExitedSibling(sibling string) *TaskEvent {
+	e.ExitedSibling = sibling
+	return e
+}
+
 // Validate is used to sanity check a task group
 func (t *Task) Validate() error {
 	var mErr multierror.Error
@@ -1786,6 +2611,14 @@ func (t *Task) Validate() error {
 	if t.KillTimeout.Nanoseconds() < 0 {
 		mErr.Errors = append(mErr.Errors, errors.New("KillTimeout must be a positive value"))
 	}
+	if t.KillSignal != "" {
+		if _, err := signals.Parse(t.KillSignal); err != nil {
+			mErr.Errors = append(mErr.Errors, fmt.Errorf("Invalid kill signal: %v", err))
+		}
+	}
+	if t.ShutdownDelay.Nanoseconds() < 0 {
+		mErr.Errors = append(mErr.Errors, errors.New("ShutdownDelay must be a positive value"))
+	}
 
 	// Validate the resources.
 	if t.Resources == nil {
@@ -1814,14 +2647,360 @@ func (t *Task) Validate() error {
 		}
 	}
 
-	if t.LogConfig != nil && t.Resources != nil {
-		logUsage := (t.LogConfig.MaxFiles * t.LogConfig.MaxFileSizeMB)
-		if t.Resources.DiskMB <= logUsage {
-			mErr.Errors = append(mErr.Errors,
-				fmt.Errorf("log storage (%d MB) exceeds requested disk capacity (%d MB)",
-					logUsage, t.Resources.DiskMB))
+	for idx, artifact := range t.Artifacts {
+		if err := artifact.Validate(); err != nil {
+			outer := fmt.Errorf("Artifact %d validation failed: %v", idx+1, err)
+			mErr.Errors = append(mErr.Errors, outer)
+		}
+	}
+
+	for idx, vm := range t.VolumeMounts {
+		if err := vm.Validate(); err != nil {
+			outer := fmt.Errorf("Volume mount %d validation failed: %v", idx+1, err)
+			mErr.Errors = append(mErr.Errors, outer)
+		}
+	}
+
+	destinations := make(map[string]int, len(t.Templates))
+	for idx, tmpl := range t.Templates {
+		if err := tmpl.Validate(); err != nil {
+			outer := fmt.Errorf("Template %d validation failed: %v", idx+1, err)
+			mErr.Errors = append(mErr.Errors, outer)
+		}
+
+		if tmpl.DestPath == "" {
+			continue
+		}
+		if other, ok := destinations[tmpl.DestPath]; ok {
+			outer := fmt.Errorf("Template %d has same destination as %d", idx+1, other)
+			mErr.Errors = append(mErr.Errors, outer)
+		} else {
+			destinations[tmpl.DestPath] = idx + 1
+		}
+	}
+
+	if t.Lifecycle != nil {
+		if err := t.Lifecycle.Validate(); err != nil {
+			outer := fmt.Errorf("Lifecycle validation failed: %v", err)
+			mErr.Errors = append(mErr.Errors, outer)
+		}
+		if t.Leader {
+			mErr.Errors = append(mErr.Errors, errors.New("Leader task can't have a lifecycle"))
+		}
+	}
+	return mErr.ErrorOrNil()
+}
+
+const (
+	// TaskLifecycleHookPrestart runs the task before the main tasks are
+	// started.
+	TaskLifecycleHookPrestart = "prestart"
+
+	// TaskLifecycleHookPoststart runs the task once the main tasks are
+	// running.
+	TaskLifecycleHookPoststart = "poststart"
+
+	// TaskLifecycleHookPoststop runs the task after the main tasks have
+	// exited.
+	TaskLifecycleHookPoststop = "poststop"
+)
+
+// TaskLifecycleConfig determines when a task is run relative to the main
+// tasks of its task group.
+type TaskLifecycleConfig struct {
+	// Hook is the point in the lifecycle of the main tasks the task is run
+	// at.
+	Hook string
+
+	// Sidecar marks a task that keeps running alongside the main tasks
+	// instead of running to completion. Sidecars are stopped once the main
+	// tasks have exited.
+	Sidecar bool
+}
+
+func (l *TaskLifecycleConfig) Copy() *TaskLifecycleConfig {
+	if l == nil {
+		return nil
+	}
+	nl := new(TaskLifecycleConfig)
+	*nl = *l
+	return nl
+}
+
+func (l *TaskLifecycleConfig) Validate() error {
+	switch l.Hook {
+	case TaskLifecycleHookPrestart, TaskLifecycleHookPoststart:
+	case TaskLifecycleHookPoststop:
+		if l.Sidecar {
+			return fmt.Errorf("%s tasks can't be sidecars", l.Hook)
+		}
+	case "":
+		return fmt.Errorf("Missing lifecycle hook")
+	default:
+		return fmt.Errorf("Invalid lifecycle hook %q", l.Hook)
+	}
+	return nil
+}
+
+// LifecycleHook returns the lifecycle hook of the task, or an empty string
+// for a main task.
+func (t *Task) LifecycleHook() string {
+	if t.Lifecycle == nil {
+		return ""
+	}
+	return t.Lifecycle.Hook
+}
+
+// IsSidecar returns whether the task keeps running alongside the main tasks.
+func (t *Task) IsSidecar() bool {
+	return t.Lifecycle != nil && t.Lifecycle.Sidecar
+}
+
+const (
+	// ArtifactModeFile downloads the artifact as a single file into the
+	// destination directory.
+	ArtifactModeFile = "file"
+
+	// ArtifactModeDir downloads the artifact, or unpacks the archive, into
+	// the destination directory.
+	ArtifactModeDir = "dir"
+)
+
+// TaskArtifact is an artifact to download before running the task.
+type TaskArtifact struct {
+	// GetterSource is the source to download the artifact from using
+	// go-getter.
+	GetterSource string `mapstructure:"source"`
+
+	// GetterOptions are the options used when downloading the artifact.
+	// The supported options are "checksum" and "archive".
+	GetterOptions map[string]string `mapstructure:"options"`
+
+	// GetterHeaders are the HTTP headers sent when downloading the artifact
+	// over HTTP.
+	GetterHeaders map[string]string `mapstructure:"headers"`
+
+	// GetterMode is either ArtifactModeFile or ArtifactModeDir. It defaults to
+	// ArtifactModeFile.
+	GetterMode string `mapstructure:"mode"`
+
+	// RelativeDest is the download destination given relative to the task's
+	// directory.
+	RelativeDest string `mapstructure:"destination"`
+}
+
+func (ta *TaskArtifact) Copy() *TaskArtifact {
+	if ta == nil {
+		return nil
+	}
+	nta := new(TaskArtifact)
+	*nta = *ta
+	nta.GetterOptions = CopyMapStringString(ta.GetterOptions)
+	nta.GetterHeaders = CopyMapStringString(ta.GetterHeaders)
+	return nta
+}
+
+func (ta *TaskArtifact) GoString() string {
+	return fmt.Sprintf("%+v", ta)
+}
+
+// Validate is used to sanity check an artifact
+func (ta *TaskArtifact) Validate() error {
+	var mErr multierror.Error
+	if ta.GetterSource == "" {
+		mErr.Errors = append(mErr.Errors, fmt.Errorf("source must be specified"))
+	}
+
+	switch ta.GetterMode {
+	case "", ArtifactModeFile, ArtifactModeDir:
+	default:
+		mErr.Errors = append(mErr.Errors, fmt.Errorf("invalid artifact mode %q; must be %q or %q",
+			ta.GetterMode, ArtifactModeFile, ArtifactModeDir))
+	}
+
+	for k, v := range ta.GetterOptions {
+		switch k {
+		case "checksum":
+			if ta.GetterMode == ArtifactModeDir {
+				mErr.Errors = append(mErr.Errors, fmt.Errorf("checksum can not be used in %q mode", ArtifactModeDir))
+			}
+			if err := validateArtifactChecksum(v); err != nil {
+				mErr.Errors = append(mErr.Errors, err)
+			}
+		case "archive":
+			if v == "" {
+				mErr.Errors = append(mErr.Errors, fmt.Errorf("archive option must not be empty"))
+			}
+		default:
+			mErr.Errors = append(mErr.Errors, fmt.Errorf("unsupported artifact option %q", k))
+		}
+	}
+
+	// Verify the destination doesn't escape the task's directory
+	if filepath.IsAbs(ta.RelativeDest) {
+		mErr.Errors = append(mErr.Errors, fmt.Errorf("destination must be relative to the task directory: %q", ta.RelativeDest))
+	} else if dest := filepath.Clean(ta.RelativeDest); dest == ".." || strings.HasPrefix(dest, ".."+string(filepath.Separator)) {
+		mErr.Errors = append(mErr.Errors, fmt.Errorf("destination escapes the task directory: %q", ta.RelativeDest))
+	}
+
+	return mErr.ErrorOrNil()
+}
+
+// validateArtifactChecksum checks that the checksum is of the form
+// "type:hex-value" with a supported type and a value of the right length.
+func validateArtifactChecksum(checksum string) error {
+	parts := strings.SplitN(checksum, ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return fmt.Errorf("checksum must be given as type:value, got %q", checksum)
+	}
+
+	var size int
+	switch parts[0] {
+	case "md5":
+		size = md5.Size
+	case "sha1":
+		size = sha1.Size
+	case "sha256":
+		size = sha256.Size
+	case "sha512":
+		size = sha512.Size
+	default:
+		return fmt.Errorf("unsupported checksum type %q", parts[0])
+	}
+
+	value, err := hex.DecodeString(parts[1])
+	if err != nil {
+		return fmt.Errorf("invalid checksum value %q: %v", parts[1], err)
+	}
+	if len(value) != size {
+		return fmt.Errorf("invalid %s checksum length: got %d bytes, want %d", parts[0], len(value), size)
+	}
+	return nil
+}
+
+const (
+	// TemplateChangeModeNoop marks that no action should be taken if the
+	// template is re-rendered
+	TemplateChangeModeNoop = "noop"
+
+	// TemplateChangeModeSignal marks that the task should be signaled if the
+	// template is re-rendered
+	TemplateChangeModeSignal = "signal"
+
+	// TemplateChangeModeRestart marks that the task should be restarted if the
+	// template is re-rendered
+	TemplateChangeModeRestart = "restart"
+
+	// DefaultTemplateSplay is the default window used to randomize the change
+	// action of a re-rendered template.
+	DefaultTemplateSplay = 5 * time.Second
+
+	// DefaultTemplatePerms are the default file permissions of a rendered
+	// template.
+	DefaultTemplatePerms = "0644"
+)
+
+// Template represents a template configuration to be rendered for a given
+// task.
+type Template struct {
+	// SourcePath is the path to the template to be rendered, relative to the
+	// task directory.
+	SourcePath string `mapstructure:"source"`
+
+	// DestPath is the path to where the template should be rendered, relative
+	// to the task directory.
+	DestPath string `mapstructure:"destination"`
+
+	// EmbeddedTmpl store the raw template. This is useful for smaller templates
+	// where they are embedded in the job file rather than sent as an artifact.
+	EmbeddedTmpl string `mapstructure:"data"`
+
+	// ChangeMode indicates what should be done if the template is re-rendered
+	ChangeMode string `mapstructure:"change_mode"`
+
+	// ChangeSignal is the signal that should be sent if the change mode
+	// requires it.
+	ChangeSignal string `mapstructure:"change_signal"`
+
+	// Splay is used to avoid coordinated restarts of processes by applying a
+	// random wait between 0 and the given splay value before signalling the
+	// application of a change
+	Splay time.Duration `mapstructure:"splay"`
+
+	// Perms is the permission the file should be written out with.
+	Perms string `mapstructure:"perms"`
+}
+
+func (t *Template) Copy() *Template {
+	if t == nil {
+		return nil
+	}
+	copy := new(Template)
+	*copy = *t
+	return copy
+}
+
+// InitFields sets the defaults of the unset template fields.
+func (t *Template) InitFields() {
+	if t.ChangeMode == "" {
+		t.ChangeMode = TemplateChangeModeRestart
+	}
+	if t.Splay == 0 {
+		t.Splay = DefaultTemplateSplay
+	}
+	if t.Perms == "" {
+		t.Perms = DefaultTemplatePerms
+	}
+}
+
+// Validate is used to sanity check a template
+func (t *Template) Validate() error {
+	var mErr multierror.Error
+
+	// Verify we have something to render
+	if t.SourcePath == "" && t.EmbeddedTmpl == "" {
+		mErr.Errors = append(mErr.Errors, fmt.Errorf("Must specify a source path or have an embedded template"))
+	}
+
+	// Verify we can render somewhere
+	if t.DestPath == "" {
+		mErr.Errors = append(mErr.Errors, fmt.Errorf("Must specify a destination for the template"))
+	}
+
+	// Verify the paths don't escape the task's directory
+	for _, path := range []string{t.SourcePath, t.DestPath} {
+		if filepath.IsAbs(path) {
+			mErr.Errors = append(mErr.Errors, fmt.Errorf("path must be relative to the task directory: %q", path))
+		} else if clean := filepath.Clean(path); clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+			mErr.Errors = append(mErr.Errors, fmt.Errorf("path escapes the task directory: %q", path))
+		}
+	}
+
+	// Verify a proper change mode
+	switch t.ChangeMode {
+	case TemplateChangeModeNoop, TemplateChangeModeRestart:
+	case TemplateChangeModeSignal:
+		if t.ChangeSignal == "" {
+			mErr.Errors = append(mErr.Errors, fmt.Errorf("Must specify signal value when change mode is signal"))
+		} else if _, err := signals.Parse(t.ChangeSignal); err != nil {
+			mErr.Errors = append(mErr.Errors, fmt.Errorf("Invalid change signal: %v", err))
 		}
+	default:
+		mErr.Errors = append(mErr.Errors, fmt.Errorf("Invalid change mode %q", t.ChangeMode))
+	}
+
+	// Verify the splay is positive
+	if t.Splay < 0 {
+		mErr.Errors = append(mErr.Errors, fmt.Errorf("Must specify positive splay value"))
 	}
+
+	// Verify the permissions
+	if t.Perms != "" {
+		if _, err := strconv.ParseUint(t.Perms, 8, 12); err != nil {
+			mErr.Errors = append(mErr.Errors, fmt.Errorf("Failed to parse %q as octal: %v", t.Perms, err))
+		}
+	}
+
 	return mErr.ErrorOrNil()
 }
 
@@ -1876,6 +3055,112 @@ func (c *Constraint) Validate() error {
 	return mErr.ErrorOrNil()
 }
 
+const (
+	AllocAffinityColocate = "colocate"
+	AllocAffinityAvoid    = "avoid"
+
+	AllocAffinityModeHard = "hard"
+	AllocAffinityModeSoft = "soft"
+)
+
+// AllocAffinity is used to place the allocations of a task group relative to
+// the allocations of other jobs. A colocate affinity prefers nodes running a
+// matching allocation while an avoid affinity prefers nodes that do not. Hard
+// affinities filter nodes while soft affinities only influence their score.
+type AllocAffinity struct {
+	Type  string            // Affinity type (colocate, avoid)
+	Mode  string            // Enforcement mode (hard, soft)
+	Job   string            // ID of the targeted job
+	Group string            // Optional task group of the targeted job
+	Meta  map[string]string // Selects jobs whose meta contains all pairs
+	str   string            // Memoized string
+}
+
+func (a *AllocAffinity) Copy() *AllocAffinity {
+	if a == nil {
+		return nil
+	}
+	na := new(AllocAffinity)
+	*na = *a
+	na.Meta = CopyMapStringString(na.Meta)
+	return na
+}
+
+func (a *AllocAffinity) String() string {
+	if a.str != "" {
+		return a.str
+	}
+	target := a.Job
+	if a.Group != "" {
+		target = fmt.Sprintf("%s.%s", a.Job, a.Group)
+	}
+	if len(a.Meta) != 0 {
+		keys := make([]string, 0, len(a.Meta))
+		for k := range a.Meta {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		pairs := make([]string, len(keys))
+		for i, k := range keys {
+			pairs[i] = fmt.Sprintf("%s=%s", k, a.Meta[k])
+		}
+		selector := fmt.Sprintf("meta(%s)", strings.Join(pairs, ","))
+		if target == "" {
+			target = selector
+		} else {
+			target = fmt.Sprintf("%s %s", target, selector)
+		}
+	}
+	a.str = fmt.Sprintf("%s %s", a.Type, target)
+	return a.str
+}
+
+// MatchesAlloc returns whether an allocation, belonging to the passed job, is
+// targeted by the affinity. The job is only consulted for meta selectors and
+// may be nil if it is unknown.
+func (a *AllocAffinity) MatchesAlloc(alloc *Allocation, job *Job) bool {
+	if a.Job != "" {
+		if alloc.JobID != a.Job {
+			return false
+		}
+		if a.Group != "" && alloc.TaskGroup != a.Group {
+			return false
+		}
+	}
+	if len(a.Meta) != 0 {
+		if job == nil {
+			return false
+		}
+		for k, v := range a.Meta {
+			if actual, ok := job.Meta[k]; !ok || actual != v {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (a *AllocAffinity) Validate() error {
+	var mErr multierror.Error
+	switch a.Type {
+	case AllocAffinityColocate, AllocAffinityAvoid:
+	default:
+		mErr.Errors = append(mErr.Errors, fmt.Errorf("Invalid affinity type %q", a.Type))
+	}
+	switch a.Mode {
+	case AllocAffinityModeHard, AllocAffinityModeSoft:
+	default:
+		mErr.Errors = append(mErr.Errors, fmt.Errorf("Invalid affinity mode %q", a.Mode))
+	}
+	if a.Job == "" && len(a.Meta) == 0 {
+		mErr.Errors = append(mErr.Errors, errors.New("Affinity must target a job or a job meta selector"))
+	}
+	if a.Group != "" && a.Job == "" {
+		mErr.Errors = append(mErr.Errors, errors.New("Affinity targeting a task group must specify its job"))
+	}
+	return mErr.ErrorOrNil()
+}
+
 const (
 	AllocDesiredStatusRun    = "run"    // Allocation should run
 	AllocDesiredStatusStop   = "stop"   // Allocation should stop
@@ -1942,6 +3227,9 @@ type Allocation struct {
 	// TaskStates stores the state of each task,
 	TaskStates map[string]*TaskState
 
+	// PreviousAllocation is the allocation that this allocation replaces.
+	PreviousAllocation string
+
 	// Raft Indexes
 	CreateIndex uint64
 	ModifyIndex uint64
@@ -2188,6 +3476,7 @@ const (
 	EvalTriggerScheduled     = "scheduled"
 	EvalTriggerForceGC       = "force-gc"
 	EvalTriggerRollingUpdate = "rolling-update"
+	EvalTriggerJobScaling    = "job-scaling"
 )
 
 const (
@@ -269,19 +269,407 @@ func TestTask_Validate(t *testing.T) {
 	}
 }
 
-func TestTask_Validate_LogConfig(t *testing.T) {
+func TestTask_Validate_KillSignal_ShutdownDelay(t *testing.T) {
 	task := &Task{
-		LogConfig: DefaultLogConfig(),
+		Name:   "web",
+		Driver: "docker",
 		Resources: &Resources{
-			DiskMB: 1,
+			CPU:      100,
+			DiskMB:   200,
+			MemoryMB: 100,
+			IOPS:     10,
 		},
+		LogConfig:     DefaultLogConfig(),
+		KillSignal:    "SIGFOO",
+		ShutdownDelay: -1 * time.Second,
 	}
-
 	err := task.Validate()
 	mErr := err.(*multierror.Error)
-	if !strings.Contains(mErr.Errors[3].Error(), "log storage") {
+	if !strings.Contains(mErr.Errors[0].Error(), "kill signal") {
+		t.Fatalf("err: %s", err)
+	}
+	if !strings.Contains(mErr.Errors[1].Error(), "ShutdownDelay") {
 		t.Fatalf("err: %s", err)
 	}
+
+	task.KillSignal = "SIGQUIT"
+	task.ShutdownDelay = 5 * time.Second
+	if err := task.Validate(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestTaskGroup_Validate_Lifecycle(t *testing.T) {
+	tg := &TaskGroup{
+		Name:          "web",
+		Count:         1,
+		RestartPolicy: NewRestartPolicy(JobTypeService),
+		EphemeralDisk: DefaultEphemeralDisk(),
+		Tasks: []*Task{
+			&Task{
+				Name:      "init",
+				Driver:    "docker",
+				Resources: DefaultResources(),
+				LogConfig: DefaultLogConfig(),
+				Lifecycle: &TaskLifecycleConfig{Hook: TaskLifecycleHookPrestart},
+			},
+		},
+	}
+
+	err := tg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "without a lifecycle") {
+		t.Fatalf("err: %v", err)
+	}
+
+	tg.Tasks = append(tg.Tasks, &Task{
+		Name:      "web",
+		Driver:    "docker",
+		Resources: DefaultResources(),
+		LogConfig: DefaultLogConfig(),
+	})
+	if err := tg.Validate(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestTaskGroup_Validate_Leader(t *testing.T) {
+	task := func(name string, leader bool) *Task {
+		return &Task{
+			Name:      name,
+			Driver:    "docker",
+			Resources: DefaultResources(),
+			LogConfig: DefaultLogConfig(),
+			Leader:    leader,
+		}
+	}
+	tg := &TaskGroup{
+		Name:          "web",
+		Count:         1,
+		RestartPolicy: NewRestartPolicy(JobTypeService),
+		EphemeralDisk: DefaultEphemeralDisk(),
+		Tasks:         []*Task{task("web", true), task("logs", true)},
+	}
+
+	err := tg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "at most one leader") {
+		t.Fatalf("err: %v", err)
+	}
+
+	tg.Tasks[1].Leader = false
+	if err := tg.Validate(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if leader := tg.LookupLeader(); leader == nil || leader.Name != "web" {
+		t.Fatalf("bad leader: %#v", leader)
+	}
+
+	// Lifecycle tasks can't lead
+	tg.Tasks[1].Lifecycle = &TaskLifecycleConfig{Hook: TaskLifecycleHookPoststop}
+	tg.Tasks[1].Leader = true
+	tg.Tasks[0].Leader = false
+	err = tg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "can't have a lifecycle") {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestTaskLifecycleConfig_Validate(t *testing.T) {
+	cases := []struct {
+		Lifecycle *TaskLifecycleConfig
+		Err       string
+	}{
+		{&TaskLifecycleConfig{Hook: TaskLifecycleHookPrestart}, ""},
+		{&TaskLifecycleConfig{Hook: TaskLifecycleHookPrestart, Sidecar: true}, ""},
+		{&TaskLifecycleConfig{Hook: TaskLifecycleHookPoststart, Sidecar: true}, ""},
+		{&TaskLifecycleConfig{Hook: TaskLifecycleHookPoststop}, ""},
+		{&TaskLifecycleConfig{Hook: TaskLifecycleHookPoststop, Sidecar: true}, "can't be sidecars"},
+		{&TaskLifecycleConfig{}, "Missing lifecycle hook"},
+		{&TaskLifecycleConfig{Hook: "prerun"}, "Invalid lifecycle hook"},
+	}
+
+	for i, c := range cases {
+		err := c.Lifecycle.Validate()
+		if c.Err == "" {
+			if err != nil {
+				t.Fatalf("case %d: err: %v", i, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), c.Err) {
+			t.Fatalf("case %d: got %v; want error containing %q", i, err, c.Err)
+		}
+	}
+}
+
+func TestTaskGroup_Validate_LogConfig(t *testing.T) {
+	tg := &TaskGroup{
+		Name:          "web",
+		Count:         1,
+		RestartPolicy: NewRestartPolicy(JobTypeService),
+		EphemeralDisk: &EphemeralDisk{SizeMB: 50},
+		Tasks: []*Task{
+			&Task{
+				Name:      "web",
+				Driver:    "docker",
+				Resources: DefaultResources(),
+				LogConfig: DefaultLogConfig(),
+			},
+		},
+	}
+
+	err := tg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "log storage") {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestTaskGroup_Validate_EphemeralDisk(t *testing.T) {
+	tg := &TaskGroup{
+		Name:          "web",
+		Count:         1,
+		RestartPolicy: NewRestartPolicy(JobTypeService),
+		Tasks: []*Task{
+			&Task{
+				Name:      "web",
+				Driver:    "docker",
+				Resources: DefaultResources(),
+				LogConfig: DefaultLogConfig(),
+			},
+		},
+	}
+	err := tg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "ephemeral disk object") {
+		t.Fatalf("err: %v", err)
+	}
+
+	tg.EphemeralDisk = &EphemeralDisk{SizeMB: 5}
+	err = tg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "minimum ephemeral disk size") {
+		t.Fatalf("err: %v", err)
+	}
+
+	tg.EphemeralDisk = &EphemeralDisk{SizeMB: 500, Sticky: true, Migrate: true}
+	if err := tg.Validate(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestTaskGroup_InitFields_EphemeralDisk(t *testing.T) {
+	job := &Job{Type: JobTypeService}
+
+	// The disk requested by the tasks moves to the ephemeral disk
+	tg := &TaskGroup{
+		Name: "web",
+		Tasks: []*Task{
+			&Task{Name: "web", Resources: &Resources{DiskMB: 200}},
+			&Task{Name: "sidecar", Resources: &Resources{DiskMB: 100}},
+		},
+	}
+	tg.InitFields(job)
+	if tg.EphemeralDisk == nil || tg.EphemeralDisk.SizeMB != 300 {
+		t.Fatalf("bad: %#v", tg.EphemeralDisk)
+	}
+	for _, task := range tg.Tasks {
+		if task.Resources.DiskMB != 0 {
+			t.Fatalf("task %q still requests disk: %d", task.Name, task.Resources.DiskMB)
+		}
+	}
+
+	// An explicit ephemeral disk is kept
+	tg = &TaskGroup{
+		Name:          "web",
+		EphemeralDisk: &EphemeralDisk{SizeMB: 1024, Sticky: true},
+		Tasks: []*Task{
+			&Task{Name: "web", Resources: &Resources{DiskMB: 200}},
+		},
+	}
+	tg.InitFields(job)
+	if !reflect.DeepEqual(tg.EphemeralDisk, &EphemeralDisk{SizeMB: 1024, Sticky: true}) {
+		t.Fatalf("bad: %#v", tg.EphemeralDisk)
+	}
+
+	// The default is used otherwise
+	tg = &TaskGroup{
+		Name:  "web",
+		Tasks: []*Task{&Task{Name: "web", Resources: &Resources{}}},
+	}
+	tg.InitFields(job)
+	if !reflect.DeepEqual(tg.EphemeralDisk, DefaultEphemeralDisk()) {
+		t.Fatalf("bad: %#v", tg.EphemeralDisk)
+	}
+}
+
+func TestTaskGroup_Validate_Volumes(t *testing.T) {
+	tg := &TaskGroup{
+		Name:          "web",
+		Count:         1,
+		RestartPolicy: NewRestartPolicy(JobTypeService),
+		Volumes: map[string]*VolumeRequest{
+			"data":  &VolumeRequest{Name: "data", Type: VolumeTypeHost, Source: "shared"},
+			"other": &VolumeRequest{Name: "other", Type: "csi"},
+		},
+		Tasks: []*Task{
+			&Task{
+				Name: "web",
+				VolumeMounts: []*VolumeMount{
+					{Volume: "data", Destination: "/srv/data"},
+					{Volume: "missing", Destination: "/srv/missing"},
+					{Volume: "data", Destination: "../../etc"},
+				},
+			},
+		},
+	}
+
+	err := tg.Validate()
+	if err == nil {
+		t.Fatalf("expected validation errors")
+	}
+	for _, exp := range []string{
+		`Unsupported volume type "csi"`,
+		"Volume must specify a source",
+		`mounts volume "missing" which is not defined`,
+		"escapes the task directory",
+	} {
+		if !strings.Contains(err.Error(), exp) {
+			t.Fatalf("expected error containing %q, got: %v", exp, err)
+		}
+	}
+	if strings.Contains(err.Error(), `mounts volume "data"`) {
+		t.Fatalf("unexpected error for defined volume: %v", err)
+	}
+}
+
+func TestTaskGroup_HostVolumes(t *testing.T) {
+	tg := &TaskGroup{
+		Volumes: map[string]*VolumeRequest{
+			"logs":    &VolumeRequest{Name: "logs", Type: VolumeTypeHost, Source: "shared", ReadOnly: true},
+			"data":    &VolumeRequest{Name: "data", Type: VolumeTypeHost, Source: "shared"},
+			"certs":   &VolumeRequest{Name: "certs", Type: VolumeTypeHost, Source: "certs", ReadOnly: true},
+			"certs-2": &VolumeRequest{Name: "certs-2", Type: VolumeTypeHost, Source: "certs", ReadOnly: true},
+			"other":   &VolumeRequest{Name: "other", Type: "csi", Source: "other"},
+		},
+	}
+
+	// Run it several times as the merge must not depend on map ordering
+	for i := 0; i < 20; i++ {
+		volumes := tg.HostVolumes()
+		if len(volumes) != 2 {
+			t.Fatalf("bad: %#v", volumes)
+		}
+		if volumes["shared"].ReadOnly {
+			t.Fatalf("host volume with a writable request should be writable")
+		}
+		if !volumes["certs"].ReadOnly {
+			t.Fatalf("host volume with only read only requests should be read only")
+		}
+	}
+	if !tg.Volumes["logs"].ReadOnly {
+		t.Fatalf("merging modified the volume request")
+	}
+}
+
+func TestTaskArtifact_Validate(t *testing.T) {
+	valid := []*TaskArtifact{
+		{
+			GetterSource: "http://foo.com/bar",
+		},
+		{
+			GetterSource: "http://foo.com/bar",
+			GetterOptions: map[string]string{
+				"checksum": "md5:b8a4f3f72ecab0510a6a31e997461c5f",
+			},
+			RelativeDest: "local/bin",
+		},
+		{
+			GetterSource: "http://foo.com/bar.tar.gz",
+			GetterOptions: map[string]string{
+				"archive": "tar.gz",
+			},
+			GetterMode: ArtifactModeDir,
+		},
+	}
+	for i, artifact := range valid {
+		if err := artifact.Validate(); err != nil {
+			t.Fatalf("case %d: unexpected error: %v", i, err)
+		}
+	}
+
+	invalid := []struct {
+		artifact *TaskArtifact
+		err      string
+	}{
+		{&TaskArtifact{}, "source must be specified"},
+		{&TaskArtifact{GetterSource: "http://foo.com/bar", GetterMode: "zip"}, "invalid artifact mode"},
+		{&TaskArtifact{GetterSource: "http://foo.com/bar", RelativeDest: "/etc"}, "must be relative"},
+		{&TaskArtifact{GetterSource: "http://foo.com/bar", RelativeDest: "local/../../foo"}, "escapes the task directory"},
+		{&TaskArtifact{
+			GetterSource:  "http://foo.com/bar",
+			GetterOptions: map[string]string{"foo": "bar"},
+		}, "unsupported artifact option"},
+		{&TaskArtifact{
+			GetterSource:  "http://foo.com/bar",
+			GetterOptions: map[string]string{"checksum": "md5:b8a4f3"},
+		}, "invalid md5 checksum length"},
+		{&TaskArtifact{
+			GetterSource:  "http://foo.com/bar",
+			GetterOptions: map[string]string{"checksum": "crc:b8a4f3"},
+		}, "unsupported checksum type"},
+		{&TaskArtifact{
+			GetterSource:  "http://foo.com/bar",
+			GetterOptions: map[string]string{"checksum": "md5:b8a4f3f72ecab0510a6a31e997461c5f"},
+			GetterMode:    ArtifactModeDir,
+		}, "checksum can not be used"},
+	}
+	for i, c := range invalid {
+		err := c.artifact.Validate()
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Fatalf("case %d: expected error containing %q, got: %v", i, c.err, err)
+		}
+	}
+}
+
+func TestTemplate_Validate(t *testing.T) {
+	valid := []*Template{
+		{
+			EmbeddedTmpl: "{{ key \"foo\" }}",
+			DestPath:     "local/foo.conf",
+		},
+		{
+			SourcePath:   "local/foo.tmpl",
+			DestPath:     "local/foo.conf",
+			ChangeMode:   TemplateChangeModeSignal,
+			ChangeSignal: "SIGHUP",
+			Perms:        "0600",
+		},
+	}
+	for i, tmpl := range valid {
+		tmpl.InitFields()
+		if err := tmpl.Validate(); err != nil {
+			t.Fatalf("case %d: unexpected error: %v", i, err)
+		}
+	}
+
+	invalid := []struct {
+		tmpl *Template
+		err  string
+	}{
+		{&Template{DestPath: "local/foo"}, "Must specify a source path"},
+		{&Template{EmbeddedTmpl: "foo"}, "Must specify a destination"},
+		{&Template{EmbeddedTmpl: "foo", DestPath: "/etc/foo"}, "must be relative"},
+		{&Template{SourcePath: "../../foo", DestPath: "local/foo"}, "escapes the task directory"},
+		{&Template{EmbeddedTmpl: "foo", DestPath: "local/foo", ChangeMode: "bar"}, "Invalid change mode"},
+		{&Template{EmbeddedTmpl: "foo", DestPath: "local/foo", ChangeMode: TemplateChangeModeSignal}, "Must specify signal"},
+		{&Template{EmbeddedTmpl: "foo", DestPath: "local/foo", ChangeMode: TemplateChangeModeSignal, ChangeSignal: "SIGFOO"}, "Invalid change signal"},
+		{&Template{EmbeddedTmpl: "foo", DestPath: "local/foo", Splay: -1}, "positive splay"},
+		{&Template{EmbeddedTmpl: "foo", DestPath: "local/foo", Perms: "0999"}, "as octal"},
+	}
+	for i, c := range invalid {
+		c.tmpl.InitFields()
+		err := c.tmpl.Validate()
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Fatalf("case %d: expected error containing %q, got: %v", i, c.err, err)
+		}
+	}
 }
 
 func TestConstraint_Validate(t *testing.T) {
@@ -321,6 +709,98 @@ func TestConstraint_Validate(t *testing.T) {
 	}
 }
 
+func TestAllocAffinity_Validate(t *testing.T) {
+	a := &AllocAffinity{}
+	err := a.Validate()
+	mErr := err.(*multierror.Error)
+	if !strings.Contains(mErr.Errors[0].Error(), "Invalid affinity type") {
+		t.Fatalf("err: %s", err)
+	}
+	if !strings.Contains(mErr.Errors[1].Error(), "Invalid affinity mode") {
+		t.Fatalf("err: %s", err)
+	}
+	if !strings.Contains(mErr.Errors[2].Error(), "must target a job") {
+		t.Fatalf("err: %s", err)
+	}
+
+	a = &AllocAffinity{
+		Type:  AllocAffinityColocate,
+		Mode:  AllocAffinityModeHard,
+		Group: "web",
+		Meta:  map[string]string{"role": "app"},
+	}
+	err = a.Validate()
+	mErr = err.(*multierror.Error)
+	if !strings.Contains(mErr.Errors[0].Error(), "must specify its job") {
+		t.Fatalf("err: %s", err)
+	}
+
+	a.Job = "app"
+	if err := a.Validate(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestScalingPolicy_Validate(t *testing.T) {
+	s := &ScalingPolicy{Min: -1, Max: 0}
+	err := s.Validate()
+	mErr := err.(*multierror.Error)
+	if !strings.Contains(mErr.Errors[0].Error(), "Minimum count must be positive") {
+		t.Fatalf("err: %s", err)
+	}
+	if !strings.Contains(mErr.Errors[1].Error(), "Maximum count must be positive") {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Task groups can't be scaled to zero
+	s = &ScalingPolicy{Min: 0, Max: 3}
+	if err := s.Validate(); err == nil || !strings.Contains(err.Error(), "Minimum count must be positive") {
+		t.Fatalf("err: %v", err)
+	}
+
+	s = &ScalingPolicy{Min: 5, Max: 3}
+	if err := s.Validate(); err == nil || !strings.Contains(err.Error(), "greater than maximum") {
+		t.Fatalf("err: %v", err)
+	}
+
+	s = &ScalingPolicy{Min: 1, Max: 3}
+	if err := s.Validate(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := s.ValidateCount(4); err == nil {
+		t.Fatalf("expected count outside of bounds")
+	}
+	if err := s.ValidateCount(3); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestAllocAffinity_MatchesAlloc(t *testing.T) {
+	job := &Job{ID: "app", Meta: map[string]string{"role": "db", "tier": "1"}}
+	alloc := &Allocation{JobID: "app", TaskGroup: "web"}
+
+	cases := []struct {
+		aff    *AllocAffinity
+		job    *Job
+		result bool
+	}{
+		{&AllocAffinity{Job: "app"}, nil, true},
+		{&AllocAffinity{Job: "other"}, nil, false},
+		{&AllocAffinity{Job: "app", Group: "web"}, nil, true},
+		{&AllocAffinity{Job: "app", Group: "cache"}, nil, false},
+		{&AllocAffinity{Meta: map[string]string{"role": "db"}}, job, true},
+		{&AllocAffinity{Meta: map[string]string{"role": "db"}}, nil, false},
+		{&AllocAffinity{Meta: map[string]string{"role": "web"}}, job, false},
+		{&AllocAffinity{Job: "app", Meta: map[string]string{"tier": "1"}}, job, true},
+	}
+
+	for i, c := range cases {
+		if res := c.aff.MatchesAlloc(alloc, c.job); res != c.result {
+			t.Fatalf("case %d: %v, expected %v", i, res, c.result)
+		}
+	}
+}
+
 func TestResource_NetIndex(t *testing.T) {
 	r := &Resources{
 		Networks: []*NetworkResource{
@@ -697,6 +1177,112 @@ func TestPeriodicConfig_NextCron(t *testing.T) {
 	}
 }
 
+func TestPeriodicConfig_ValidateTimeZoneCatchUp(t *testing.T) {
+	p := &PeriodicConfig{Enabled: true, SpecType: PeriodicSpecCron, Spec: "@hourly", TimeZone: "Mars/Olympus_Mons"}
+	if err := p.Validate(); err == nil || !strings.Contains(err.Error(), "Invalid time zone") {
+		t.Fatalf("expected time zone error, got: %v", err)
+	}
+
+	p.TimeZone = "Europe/Berlin"
+	p.CatchUp = "sometimes"
+	if err := p.Validate(); err == nil || !strings.Contains(err.Error(), "Unknown catch up policy") {
+		t.Fatalf("expected catch up error, got: %v", err)
+	}
+
+	p.CatchUp = PeriodicCatchUpRunAll
+	p.ProhibitOverlap = true
+	if err := p.Validate(); err == nil || !strings.Contains(err.Error(), "prohibiting overlap") {
+		t.Fatalf("expected overlap error, got: %v", err)
+	}
+
+	p.ProhibitOverlap = false
+	if err := p.Validate(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestPeriodicConfig_NextCron_TimeZone(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	cases := []struct {
+		spec     string
+		from     time.Time
+		expected []time.Time
+	}{
+		{
+			// Evaluated on the wall clock of the time zone
+			spec: "0 9 * * *",
+			from: time.Date(2016, time.June, 1, 12, 0, 0, 0, time.UTC),
+			expected: []time.Time{
+				time.Date(2016, time.June, 1, 13, 0, 0, 0, time.UTC),
+				time.Date(2016, time.June, 2, 13, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			// Wall clock times skipped when springing forward launch after
+			// the transition
+			spec: "30 2 * * *",
+			from: time.Date(2016, time.March, 12, 12, 0, 0, 0, ny),
+			expected: []time.Time{
+				time.Date(2016, time.March, 13, 3, 30, 0, 0, ny),
+				time.Date(2016, time.March, 14, 2, 30, 0, 0, ny),
+			},
+		},
+		{
+			spec: "*/30 * * * *",
+			from: time.Date(2016, time.March, 13, 1, 10, 0, 0, ny),
+			expected: []time.Time{
+				time.Date(2016, time.March, 13, 1, 30, 0, 0, ny),
+				time.Date(2016, time.March, 13, 3, 0, 0, 0, ny),
+				time.Date(2016, time.March, 13, 3, 30, 0, 0, ny),
+			},
+		},
+		{
+			// Wall clock times repeated when falling back launch once
+			spec: "30 1 * * *",
+			from: time.Date(2016, time.November, 5, 12, 0, 0, 0, ny),
+			expected: []time.Time{
+				time.Date(2016, time.November, 6, 5, 30, 0, 0, time.UTC),
+				time.Date(2016, time.November, 7, 6, 30, 0, 0, time.UTC),
+			},
+		},
+		{
+			spec: "*/30 * * * *",
+			from: time.Date(2016, time.November, 6, 5, 10, 0, 0, time.UTC),
+			expected: []time.Time{
+				time.Date(2016, time.November, 6, 5, 30, 0, 0, time.UTC),
+				time.Date(2016, time.November, 6, 7, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			// Starting within the repeated hour does not go back in time
+			spec: "*/30 * * * *",
+			from: time.Date(2016, time.November, 6, 6, 10, 0, 0, time.UTC),
+			expected: []time.Time{
+				time.Date(2016, time.November, 6, 7, 0, 0, 0, time.UTC),
+			},
+		},
+	}
+
+	for i, c := range cases {
+		p := &PeriodicConfig{Enabled: true, SpecType: PeriodicSpecCron, Spec: c.spec, TimeZone: "America/New_York"}
+		from := c.from
+		for _, expected := range c.expected {
+			next := p.Next(from)
+			if !next.Equal(expected) {
+				t.Fatalf("case %d: Next(%v) returned %v; want %v", i, from, next, expected)
+			}
+			if next.Location() != from.Location() {
+				t.Fatalf("case %d: Next(%v) returned location %v", i, from, next.Location())
+			}
+			from = next
+		}
+	}
+}
+
 func TestRestartPolicy_Validate(t *testing.T) {
 	// Policy with acceptable restart options passes
 	p := &RestartPolicy{
@@ -142,11 +142,55 @@ func (c *DriverChecker) hasDrivers(option *structs.Node) bool {
 	return true
 }
 
+// HostVolumeChecker is a FeasibilityChecker which returns whether a node has
+// the host volumes necessary to schedule a task group.
+type HostVolumeChecker struct {
+	ctx     Context
+	volumes map[string]*structs.VolumeRequest
+}
+
+// NewHostVolumeChecker creates a HostVolumeChecker
+func NewHostVolumeChecker(ctx Context) *HostVolumeChecker {
+	return &HostVolumeChecker{
+		ctx: ctx,
+	}
+}
+
+// SetVolumes takes the host volume requests of a task group keyed by the
+// name of the client host volume.
+func (c *HostVolumeChecker) SetVolumes(volumes map[string]*structs.VolumeRequest) {
+	c.volumes = volumes
+}
+
+func (c *HostVolumeChecker) Feasible(option *structs.Node) bool {
+	if c.hasVolumes(option) {
+		return true
+	}
+	c.ctx.Metrics().FilterNode(option, "missing compatible host volumes")
+	return false
+}
+
+// hasVolumes is used to check if the node has all the host volumes requested
+// by the task group. A volume requested as writable can not be satisfied by a
+// read only host volume.
+func (c *HostVolumeChecker) hasVolumes(option *structs.Node) bool {
+	for source, req := range c.volumes {
+		hv, ok := option.HostVolumes[source]
+		if !ok {
+			return false
+		}
+		if hv.ReadOnly && !req.ReadOnly {
+			return false
+		}
+	}
+	return true
+}
+
 // ProposedAllocConstraintIterator is a FeasibleIterator which returns nodes that
 // match constraints that are not static such as Node attributes but are
-// effected by proposed alloc placements. Examples are distinct_hosts and
-// tenancy constraints. This is used to filter on job and task group
-// constraints.
+// effected by proposed alloc placements. Examples are distinct_hosts, hard
+// allocation affinities and tenancy constraints. This is used to filter on job
+// and task group constraints.
 type ProposedAllocConstraintIterator struct {
 	ctx    Context
 	source FeasibleIterator
@@ -157,6 +201,9 @@ type ProposedAllocConstraintIterator struct {
 	// they don't have to be calculated every time Next() is called.
 	tgDistinctHosts  bool
 	jobDistinctHosts bool
+
+	// tgAffinities are the hard allocation affinities of the TaskGroup.
+	tgAffinities []*structs.AllocAffinity
 }
 
 // NewProposedAllocConstraintIterator creates a ProposedAllocConstraintIterator
@@ -171,6 +218,7 @@ func NewProposedAllocConstraintIterator(ctx Context, source FeasibleIterator) *P
 func (iter *ProposedAllocConstraintIterator) SetTaskGroup(tg *structs.TaskGroup) {
 	iter.tg = tg
 	iter.tgDistinctHosts = iter.hasDistinctHostsConstraint(tg.Constraints)
+	iter.tgAffinities = filterAllocAffinities(tg.Affinities, structs.AllocAffinityModeHard)
 }
 
 func (iter *ProposedAllocConstraintIterator) SetJob(job *structs.Job) {
@@ -192,8 +240,9 @@ func (iter *ProposedAllocConstraintIterator) Next() *structs.Node {
 		// Get the next option from the source
 		option := iter.source.Next()
 
-		// Hot-path if the option is nil or there are no distinct_hosts constraints.
-		if option == nil || !(iter.jobDistinctHosts || iter.tgDistinctHosts) {
+		// Hot-path if the option is nil or there are no distinct_hosts
+		// constraints or hard affinities.
+		if option == nil || !(iter.jobDistinctHosts || iter.tgDistinctHosts || len(iter.tgAffinities) != 0) {
 			return option
 		}
 
@@ -202,6 +251,10 @@ func (iter *ProposedAllocConstraintIterator) Next() *structs.Node {
 			continue
 		}
 
+		if !iter.satisfiesAllocAffinities(option) {
+			continue
+		}
+
 		return option
 	}
 }
@@ -237,6 +290,34 @@ func (iter *ProposedAllocConstraintIterator) satisfiesDistinctHosts(option *stru
 	return true
 }
 
+// satisfiesAllocAffinities checks if the node satisfies the hard allocation
+// affinities of the TaskGroup. A colocate affinity requires a matching
+// allocation on the node while an avoid affinity requires there be none.
+func (iter *ProposedAllocConstraintIterator) satisfiesAllocAffinities(option *structs.Node) bool {
+	if len(iter.tgAffinities) == 0 {
+		return true
+	}
+
+	// Get the proposed allocations
+	proposed, err := iter.ctx.ProposedAllocs(option.ID)
+	if err != nil {
+		iter.ctx.Logger().Printf(
+			"[ERR] scheduler.dynamic-constraint: failed to get proposed allocations: %v", err)
+		return false
+	}
+
+	for _, aff := range iter.tgAffinities {
+		matches := countAffinityMatches(iter.ctx, aff, proposed)
+		colocate := aff.Type == structs.AllocAffinityColocate
+		if colocate && matches == 0 || !colocate && matches != 0 {
+			iter.ctx.Metrics().FilterNode(option, aff.String())
+			return false
+		}
+	}
+
+	return true
+}
+
 func (iter *ProposedAllocConstraintIterator) Reset() {
 	iter.source.Reset()
 }
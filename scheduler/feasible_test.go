@@ -123,6 +123,90 @@ func TestDriverChecker(t *testing.T) {
 	}
 }
 
+func TestHostVolumeChecker(t *testing.T) {
+	_, ctx := testContext(t)
+	nodes := []*structs.Node{
+		mock.Node(),
+		mock.Node(),
+		mock.Node(),
+		mock.Node(),
+	}
+	nodes[1].HostVolumes = map[string]*structs.ClientHostVolumeConfig{
+		"foo": &structs.ClientHostVolumeConfig{Name: "foo", Path: "/foo"},
+	}
+	nodes[2].HostVolumes = map[string]*structs.ClientHostVolumeConfig{
+		"foo": &structs.ClientHostVolumeConfig{Name: "foo", Path: "/foo"},
+		"bar": &structs.ClientHostVolumeConfig{Name: "bar", Path: "/bar", ReadOnly: true},
+	}
+	nodes[3].HostVolumes = map[string]*structs.ClientHostVolumeConfig{
+		"foo": &structs.ClientHostVolumeConfig{Name: "foo", Path: "/foo"},
+		"bar": &structs.ClientHostVolumeConfig{Name: "bar", Path: "/bar"},
+	}
+
+	checker := NewHostVolumeChecker(ctx)
+	cases := []struct {
+		Node    *structs.Node
+		Volumes map[string]*structs.VolumeRequest
+		Result  bool
+	}{
+		{
+			Node:   nodes[0],
+			Result: true,
+		},
+		{
+			Node: nodes[0],
+			Volumes: map[string]*structs.VolumeRequest{
+				"foo": &structs.VolumeRequest{Type: structs.VolumeTypeHost, Source: "foo"},
+			},
+			Result: false,
+		},
+		{
+			Node: nodes[1],
+			Volumes: map[string]*structs.VolumeRequest{
+				"foo": &structs.VolumeRequest{Type: structs.VolumeTypeHost, Source: "foo"},
+			},
+			Result: true,
+		},
+		{
+			Node: nodes[1],
+			Volumes: map[string]*structs.VolumeRequest{
+				"foo": &structs.VolumeRequest{Type: structs.VolumeTypeHost, Source: "foo"},
+				"bar": &structs.VolumeRequest{Type: structs.VolumeTypeHost, Source: "bar", ReadOnly: true},
+			},
+			Result: false,
+		},
+		{
+			Node: nodes[2],
+			Volumes: map[string]*structs.VolumeRequest{
+				"bar": &structs.VolumeRequest{Type: structs.VolumeTypeHost, Source: "bar", ReadOnly: true},
+			},
+			Result: true,
+		},
+		{
+			Node: nodes[2],
+			Volumes: map[string]*structs.VolumeRequest{
+				"bar": &structs.VolumeRequest{Type: structs.VolumeTypeHost, Source: "bar"},
+			},
+			Result: false,
+		},
+		{
+			Node: nodes[3],
+			Volumes: map[string]*structs.VolumeRequest{
+				"foo": &structs.VolumeRequest{Type: structs.VolumeTypeHost, Source: "foo"},
+				"bar": &structs.VolumeRequest{Type: structs.VolumeTypeHost, Source: "bar"},
+			},
+			Result: true,
+		},
+	}
+
+	for i, c := range cases {
+		checker.SetVolumes(c.Volumes)
+		if act := checker.Feasible(c.Node); act != c.Result {
+			t.Fatalf("case(%d) failed: got %v; want %v", i, act, c.Result)
+		}
+	}
+}
+
 func TestConstraintChecker(t *testing.T) {
 	_, ctx := testContext(t)
 	nodes := []*structs.Node{
@@ -634,6 +718,89 @@ func (c *mockFeasibilityChecker) Feasible(*structs.Node) bool {
 // calls returns how many times the checker was called.
 func (c *mockFeasibilityChecker) calls() int { return c.i }
 
+func TestProposedAllocConstraint_HardColocate(t *testing.T) {
+	_, ctx := testContext(t)
+	nodes := []*structs.Node{
+		mock.Node(),
+		mock.Node(),
+	}
+	static := NewStaticIterator(ctx, nodes)
+
+	// Place the target on the second node only.
+	plan := ctx.Plan()
+	plan.NodeAllocation[nodes[1].ID] = []*structs.Allocation{
+		&structs.Allocation{
+			TaskGroup: "web",
+			JobID:     "app",
+		},
+	}
+
+	tg := &structs.TaskGroup{
+		Name: "cache",
+		Affinities: []*structs.AllocAffinity{
+			&structs.AllocAffinity{
+				Type:  structs.AllocAffinityColocate,
+				Mode:  structs.AllocAffinityModeHard,
+				Job:   "app",
+				Group: "web",
+			},
+		},
+	}
+	job := &structs.Job{ID: "foo", TaskGroups: []*structs.TaskGroup{tg}}
+
+	propsed := NewProposedAllocConstraintIterator(ctx, static)
+	propsed.SetTaskGroup(tg)
+	propsed.SetJob(job)
+
+	out := collectFeasible(propsed)
+	if len(out) != 1 || out[0].ID != nodes[1].ID {
+		t.Fatalf("Bad: %#v", out)
+	}
+}
+
+func TestProposedAllocConstraint_HardAvoid_Meta(t *testing.T) {
+	state, ctx := testContext(t)
+	nodes := []*structs.Node{
+		mock.Node(),
+		mock.Node(),
+	}
+	static := NewStaticIterator(ctx, nodes)
+
+	// Register a database job and place it on the first node.
+	db := mock.Job()
+	db.Meta = map[string]string{"role": "db"}
+	noErr(t, state.UpsertJob(1000, db))
+
+	plan := ctx.Plan()
+	plan.NodeAllocation[nodes[0].ID] = []*structs.Allocation{
+		&structs.Allocation{
+			TaskGroup: db.TaskGroups[0].Name,
+			JobID:     db.ID,
+		},
+	}
+
+	tg := &structs.TaskGroup{
+		Name: "replica",
+		Affinities: []*structs.AllocAffinity{
+			&structs.AllocAffinity{
+				Type: structs.AllocAffinityAvoid,
+				Mode: structs.AllocAffinityModeHard,
+				Meta: map[string]string{"role": "db"},
+			},
+		},
+	}
+	job := &structs.Job{ID: "foo", TaskGroups: []*structs.TaskGroup{tg}}
+
+	propsed := NewProposedAllocConstraintIterator(ctx, static)
+	propsed.SetTaskGroup(tg)
+	propsed.SetJob(job)
+
+	out := collectFeasible(propsed)
+	if len(out) != 1 || out[0].ID != nodes[1].ID {
+		t.Fatalf("Bad: %#v", out)
+	}
+}
+
 func TestFeasibilityWrapper_JobIneligible(t *testing.T) {
 	_, ctx := testContext(t)
 	nodes := []*structs.Node{mock.Node()}
@@ -323,6 +323,12 @@ func (s *GenericScheduler) computePlacements(place []allocTuple) error {
 	// Update the set of placement ndoes
 	s.stack.SetNodes(nodes)
 
+	// Index the nodes to find the nodes of the allocations being replaced
+	nodesByID := make(map[string]*structs.Node, len(nodes))
+	for _, node := range nodes {
+		nodesByID[node.ID] = node
+	}
+
 	// Track the failed task groups so that we can coalesce
 	// the failures together to avoid creating many failed allocs.
 	failedTG := make(map[*structs.TaskGroup]*structs.Allocation)
@@ -334,8 +340,15 @@ func (s *GenericScheduler) computePlacements(place []allocTuple) error {
 			continue
 		}
 
-		// Attempt to match the task group
-		option, size := s.stack.Select(missing.TaskGroup)
+		// Attempt to match the task group, preferring the node of the
+		// allocation being replaced if its ephemeral disk is sticky
+		var option *RankedNode
+		var size *structs.Resources
+		if preferred := s.findPreferredNode(missing, nodesByID); preferred != nil {
+			option, size = s.stack.SelectPreferringNodes(missing.TaskGroup, []*structs.Node{preferred})
+		} else {
+			option, size = s.stack.Select(missing.TaskGroup)
+		}
 
 		// Create an allocation for this
 		alloc := &structs.Allocation{
@@ -348,6 +361,11 @@ func (s *GenericScheduler) computePlacements(place []allocTuple) error {
 			Metrics:   s.ctx.Metrics(),
 		}
 
+		// Link the allocation to the one it replaces
+		if missing.Alloc != nil {
+			alloc.PreviousAllocation = missing.Alloc.ID
+		}
+
 		// Store the available nodes by datacenter
 		s.ctx.Metrics().NodesAvailable = byDC
 
@@ -374,3 +392,16 @@ func (s *GenericScheduler) computePlacements(place []allocTuple) error {
 
 	return nil
 }
+
+// findPreferredNode returns the node of the allocation being replaced if the
+// ephemeral disk of the task group is sticky and the node is still eligible.
+func (s *GenericScheduler) findPreferredNode(missing allocTuple, nodesByID map[string]*structs.Node) *structs.Node {
+	if missing.Alloc == nil {
+		return nil
+	}
+	disk := missing.TaskGroup.EphemeralDisk
+	if disk == nil || !disk.Sticky {
+		return nil
+	}
+	return nodesByID[missing.Alloc.NodeID]
+}
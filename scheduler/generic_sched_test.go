@@ -391,6 +391,87 @@ func TestServiceSched_JobModify(t *testing.T) {
 	h.AssertEvalStatus(t, structs.EvalStatusComplete)
 }
 
+func TestServiceSched_JobModify_StickyDisk(t *testing.T) {
+	h := NewHarness(t)
+
+	// Create some nodes
+	var nodes []*structs.Node
+	for i := 0; i < 10; i++ {
+		node := mock.Node()
+		nodes = append(nodes, node)
+		noErr(t, h.State.UpsertNode(h.NextIndex(), node))
+	}
+
+	// Generate a fake job with a sticky ephemeral disk and allocations
+	job := mock.Job()
+	job.TaskGroups[0].EphemeralDisk.Sticky = true
+	noErr(t, h.State.UpsertJob(h.NextIndex(), job))
+
+	allocsByID := make(map[string]*structs.Allocation)
+	var allocs []*structs.Allocation
+	for i := 0; i < 10; i++ {
+		alloc := mock.Alloc()
+		alloc.Job = job
+		alloc.JobID = job.ID
+		alloc.NodeID = nodes[i].ID
+		alloc.Name = fmt.Sprintf("my-job.web[%d]", i)
+		allocs = append(allocs, alloc)
+		allocsByID[alloc.ID] = alloc
+	}
+	noErr(t, h.State.UpsertAllocs(h.NextIndex(), allocs))
+
+	// Update the task, such that it cannot be done in-place
+	job2 := mock.Job()
+	job2.ID = job.ID
+	job2.TaskGroups[0].EphemeralDisk.Sticky = true
+	job2.TaskGroups[0].Tasks[0].Config["command"] = "/bin/other"
+	noErr(t, h.State.UpsertJob(h.NextIndex(), job2))
+
+	// Create a mock evaluation to deal with the update
+	eval := &structs.Evaluation{
+		ID:          structs.GenerateUUID(),
+		Priority:    50,
+		TriggeredBy: structs.EvalTriggerJobRegister,
+		JobID:       job.ID,
+	}
+
+	// Process the evaluation
+	err := h.Process(NewServiceScheduler, eval)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Ensure a single plan
+	if len(h.Plans) != 1 {
+		t.Fatalf("bad: %#v", h.Plans)
+	}
+	plan := h.Plans[0]
+
+	// Ensure every replacement is linked to the allocation it replaces and
+	// placed on its node
+	var planned []*structs.Allocation
+	for _, allocList := range plan.NodeAllocation {
+		planned = append(planned, allocList...)
+	}
+	if len(planned) != 10 {
+		t.Fatalf("bad: %#v", plan)
+	}
+	for _, alloc := range planned {
+		prev, ok := allocsByID[alloc.PreviousAllocation]
+		if !ok {
+			t.Fatalf("alloc %q not linked to a previous allocation: %q", alloc.ID, alloc.PreviousAllocation)
+		}
+		if alloc.NodeID != prev.NodeID {
+			t.Fatalf("alloc %q placed on %q; want previous node %q", alloc.ID, alloc.NodeID, prev.NodeID)
+		}
+		if alloc.Resources.DiskMB != job2.TaskGroups[0].EphemeralDisk.SizeMB {
+			t.Fatalf("bad disk: %#v", alloc.Resources)
+		}
+	}
+
+	h.AssertEvalStatus(t, structs.EvalStatusComplete)
+}
+
 func TestServiceSched_JobModify_Rolling(t *testing.T) {
 	h := NewHarness(t)
 
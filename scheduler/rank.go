@@ -131,11 +131,11 @@ func (iter *StaticRankIterator) Reset() {
 // BinPackIterator is a RankIterator that scores potential options
 // based on a bin-packing algorithm.
 type BinPackIterator struct {
-	ctx      Context
-	source   RankIterator
-	evict    bool
-	priority int
-	tasks    []*structs.Task
+	ctx       Context
+	source    RankIterator
+	evict     bool
+	priority  int
+	taskGroup *structs.TaskGroup
 }
 
 // NewBinPackIterator returns a BinPackIterator which tries to fit tasks
@@ -154,8 +154,8 @@ func (iter *BinPackIterator) SetPriority(p int) {
 	iter.priority = p
 }
 
-func (iter *BinPackIterator) SetTasks(tasks []*structs.Task) {
-	iter.tasks = tasks
+func (iter *BinPackIterator) SetTaskGroup(taskGroup *structs.TaskGroup) {
+	iter.taskGroup = taskGroup
 }
 
 func (iter *BinPackIterator) Next() *RankedNode {
@@ -183,7 +183,7 @@ OUTER:
 
 		// Assign the resources for each task
 		total := new(structs.Resources)
-		for _, task := range iter.tasks {
+		for _, task := range iter.taskGroup.Tasks {
 			taskResources := task.Resources.Copy()
 
 			// Check if we need a network resource
@@ -211,6 +211,11 @@ OUTER:
 			total.Add(taskResources)
 		}
 
+		// Add the ephemeral disk shared by the tasks
+		if iter.taskGroup.EphemeralDisk != nil {
+			total.DiskMB += iter.taskGroup.EphemeralDisk.SizeMB
+		}
+
 		// Add the resources we are trying to fit
 		proposed = append(proposed, &structs.Allocation{Resources: total})
 
@@ -302,3 +307,115 @@ func (iter *JobAntiAffinityIterator) Next() *RankedNode {
 func (iter *JobAntiAffinityIterator) Reset() {
 	iter.source.Reset()
 }
+
+// AllocAffinityIterator is used to apply the soft allocation affinities of a
+// task group. Nodes running allocations targeted by a colocate affinity are
+// boosted while nodes running allocations targeted by an avoid affinity are
+// penalized for each collision.
+type AllocAffinityIterator struct {
+	ctx        Context
+	source     RankIterator
+	weight     float64
+	affinities []*structs.AllocAffinity
+}
+
+// NewAllocAffinityIterator is used to create an AllocAffinityIterator that
+// applies the given weight for each satisfied or violated affinity.
+func NewAllocAffinityIterator(ctx Context, source RankIterator, weight float64) *AllocAffinityIterator {
+	iter := &AllocAffinityIterator{
+		ctx:    ctx,
+		source: source,
+		weight: weight,
+	}
+	return iter
+}
+
+func (iter *AllocAffinityIterator) SetTaskGroup(tg *structs.TaskGroup) {
+	iter.affinities = filterAllocAffinities(tg.Affinities, structs.AllocAffinityModeSoft)
+}
+
+func (iter *AllocAffinityIterator) Next() *RankedNode {
+	for {
+		option := iter.source.Next()
+		if option == nil || len(iter.affinities) == 0 {
+			return option
+		}
+
+		// Get the proposed allocations
+		proposed, err := option.ProposedAllocs(iter.ctx)
+		if err != nil {
+			iter.ctx.Logger().Printf(
+				"[ERR] sched.alloc-aff: failed to get proposed allocations: %v",
+				err)
+			continue
+		}
+
+		for _, aff := range iter.affinities {
+			matches := countAffinityMatches(iter.ctx, aff, proposed)
+			if matches == 0 {
+				continue
+			}
+
+			switch aff.Type {
+			case structs.AllocAffinityColocate:
+				option.Score += iter.weight
+				iter.ctx.Metrics().ScoreNode(option.Node, "alloc-affinity", iter.weight)
+			case structs.AllocAffinityAvoid:
+				scorePenalty := -1 * float64(matches) * iter.weight
+				option.Score += scorePenalty
+				iter.ctx.Metrics().ScoreNode(option.Node, "alloc-anti-affinity", scorePenalty)
+			}
+		}
+		return option
+	}
+}
+
+func (iter *AllocAffinityIterator) Reset() {
+	iter.source.Reset()
+}
+
+// LXCBaseIterator is used to prefer the nodes that have cached the base
+// containers the lxc tasks of a task group are cloned from, so the tasks
+// start without building their containers from their templates. Nodes are
+// boosted for each task whose base container they have cached.
+type LXCBaseIterator struct {
+	ctx    Context
+	source RankIterator
+	weight float64
+	attrs  []string
+}
+
+// NewLXCBaseIterator is used to create an LXCBaseIterator that applies the
+// given weight for each task whose base container is cached on a node.
+func NewLXCBaseIterator(ctx Context, source RankIterator, weight float64) *LXCBaseIterator {
+	iter := &LXCBaseIterator{
+		ctx:    ctx,
+		source: source,
+		weight: weight,
+	}
+	return iter
+}
+
+func (iter *LXCBaseIterator) SetTaskGroup(tg *structs.TaskGroup) {
+	iter.attrs = lxcBaseAttributes(tg)
+}
+
+func (iter *LXCBaseIterator) Next() *RankedNode {
+	option := iter.source.Next()
+	if option == nil || len(iter.attrs) == 0 {
+		return option
+	}
+
+	for _, attr := range iter.attrs {
+		if _, ok := option.Node.Attributes[attr]; !ok {
+			continue
+		}
+		option.Score += iter.weight
+		iter.ctx.Metrics().ScoreNode(option.Node, "lxc-base", iter.weight)
+	}
+	return option
+}
+
+func (iter *LXCBaseIterator) Reset() {
+	iter.source.Reset()
+}
@@ -77,7 +77,7 @@ func TestBinPackIterator_NoExistingAlloc(t *testing.T) {
 	}
 
 	binp := NewBinPackIterator(ctx, static, false, 0)
-	binp.SetTasks([]*structs.Task{task})
+	binp.SetTaskGroup(&structs.TaskGroup{Tasks: []*structs.Task{task}})
 
 	out := collectRanked(binp)
 	if len(out) != 2 {
@@ -95,6 +95,54 @@ func TestBinPackIterator_NoExistingAlloc(t *testing.T) {
 	}
 }
 
+func TestBinPackIterator_EphemeralDisk(t *testing.T) {
+	_, ctx := testContext(t)
+	nodes := []*RankedNode{
+		&RankedNode{
+			Node: &structs.Node{
+				// Enough disk
+				Resources: &structs.Resources{
+					CPU:      2048,
+					MemoryMB: 2048,
+					DiskMB:   1024,
+				},
+			},
+		},
+		&RankedNode{
+			Node: &structs.Node{
+				// Not enough disk
+				Resources: &structs.Resources{
+					CPU:      2048,
+					MemoryMB: 2048,
+					DiskMB:   256,
+				},
+			},
+		},
+	}
+	static := NewStaticRankIterator(ctx, nodes)
+
+	tg := &structs.TaskGroup{
+		EphemeralDisk: &structs.EphemeralDisk{SizeMB: 512},
+		Tasks: []*structs.Task{
+			&structs.Task{
+				Name: "web",
+				Resources: &structs.Resources{
+					CPU:      1024,
+					MemoryMB: 1024,
+				},
+			},
+		},
+	}
+
+	binp := NewBinPackIterator(ctx, static, false, 0)
+	binp.SetTaskGroup(tg)
+
+	out := collectRanked(binp)
+	if len(out) != 1 || out[0] != nodes[0] {
+		t.Fatalf("Bad: %v", out)
+	}
+}
+
 func TestBinPackIterator_PlannedAlloc(t *testing.T) {
 	_, ctx := testContext(t)
 	nodes := []*RankedNode{
@@ -151,7 +199,7 @@ func TestBinPackIterator_PlannedAlloc(t *testing.T) {
 	}
 
 	binp := NewBinPackIterator(ctx, static, false, 0)
-	binp.SetTasks([]*structs.Task{task})
+	binp.SetTaskGroup(&structs.TaskGroup{Tasks: []*structs.Task{task}})
 
 	out := collectRanked(binp)
 	if len(out) != 1 {
@@ -228,7 +276,7 @@ func TestBinPackIterator_ExistingAlloc(t *testing.T) {
 	}
 
 	binp := NewBinPackIterator(ctx, static, false, 0)
-	binp.SetTasks([]*structs.Task{task})
+	binp.SetTaskGroup(&structs.TaskGroup{Tasks: []*structs.Task{task}})
 
 	out := collectRanked(binp)
 	if len(out) != 1 {
@@ -308,7 +356,7 @@ func TestBinPackIterator_ExistingAlloc_PlannedEvict(t *testing.T) {
 	}
 
 	binp := NewBinPackIterator(ctx, static, false, 0)
-	binp.SetTasks([]*structs.Task{task})
+	binp.SetTaskGroup(&structs.TaskGroup{Tasks: []*structs.Task{task}})
 
 	out := collectRanked(binp)
 	if len(out) != 2 {
@@ -380,6 +428,136 @@ func TestJobAntiAffinity_PlannedAlloc(t *testing.T) {
 	}
 }
 
+func TestAllocAffinity_Soft(t *testing.T) {
+	_, ctx := testContext(t)
+	nodes := []*RankedNode{
+		&RankedNode{
+			Node: &structs.Node{
+				ID: structs.GenerateUUID(),
+			},
+		},
+		&RankedNode{
+			Node: &structs.Node{
+				ID: structs.GenerateUUID(),
+			},
+		},
+	}
+	static := NewStaticRankIterator(ctx, nodes)
+
+	// Place the colocation target on node1 and two avoided allocs on node2
+	plan := ctx.Plan()
+	plan.NodeAllocation[nodes[0].Node.ID] = []*structs.Allocation{
+		&structs.Allocation{
+			JobID: "app",
+		},
+	}
+	plan.NodeAllocation[nodes[1].Node.ID] = []*structs.Allocation{
+		&structs.Allocation{
+			JobID: "batch",
+		},
+		&structs.Allocation{
+			JobID: "batch",
+		},
+	}
+
+	tg := &structs.TaskGroup{
+		Affinities: []*structs.AllocAffinity{
+			&structs.AllocAffinity{
+				Type: structs.AllocAffinityColocate,
+				Mode: structs.AllocAffinityModeSoft,
+				Job:  "app",
+			},
+			&structs.AllocAffinity{
+				Type: structs.AllocAffinityAvoid,
+				Mode: structs.AllocAffinityModeSoft,
+				Job:  "batch",
+			},
+			// Hard affinities are not scored
+			&structs.AllocAffinity{
+				Type: structs.AllocAffinityColocate,
+				Mode: structs.AllocAffinityModeHard,
+				Job:  "batch",
+			},
+		},
+	}
+
+	affIter := NewAllocAffinityIterator(ctx, static, 5.0)
+	affIter.SetTaskGroup(tg)
+
+	out := collectRanked(affIter)
+	if len(out) != 2 {
+		t.Fatalf("Bad: %#v", out)
+	}
+	if out[0] != nodes[0] || out[0].Score != 5.0 {
+		t.Fatalf("Bad: %v", out[0])
+	}
+	if out[1] != nodes[1] || out[1].Score != -10.0 {
+		t.Fatalf("Bad: %v", out[1])
+	}
+}
+
+func TestLXCBaseIterator(t *testing.T) {
+	_, ctx := testContext(t)
+	base := structs.LXCBaseAttrPrefix + "download-ubuntu-trusty-amd64"
+	nodes := []*RankedNode{
+		&RankedNode{
+			Node: &structs.Node{
+				ID:         structs.GenerateUUID(),
+				Attributes: map[string]string{base: "1"},
+			},
+		},
+		&RankedNode{
+			Node: &structs.Node{
+				ID:         structs.GenerateUUID(),
+				Attributes: map[string]string{},
+			},
+		},
+	}
+	static := NewStaticRankIterator(ctx, nodes)
+
+	tg := &structs.TaskGroup{
+		Tasks: []*structs.Task{
+			&structs.Task{
+				Driver: "lxc",
+				Config: map[string]interface{}{
+					"template": "download",
+					"distro":   "ubuntu",
+					"release":  "trusty",
+					"arch":     "amd64",
+				},
+			},
+			// Tasks cloned from existing containers have no base
+			&structs.Task{
+				Driver: "lxc",
+				Config: map[string]interface{}{
+					"clone_from": "app",
+					"template":   "download",
+					"distro":     "ubuntu",
+					"release":    "trusty",
+					"arch":       "amd64",
+				},
+			},
+			&structs.Task{
+				Driver: "exec",
+			},
+		},
+	}
+
+	baseIter := NewLXCBaseIterator(ctx, static, 5.0)
+	baseIter.SetTaskGroup(tg)
+
+	out := collectRanked(baseIter)
+	if len(out) != 2 {
+		t.Fatalf("Bad: %#v", out)
+	}
+	if out[0] != nodes[0] || out[0].Score != 5.0 {
+		t.Fatalf("Bad: %v", out[0])
+	}
+	if out[1] != nodes[1] || out[1].Score != 0.0 {
+		t.Fatalf("Bad: %v", out[1])
+	}
+}
+
 func collectRanked(iter RankIterator) (out []*RankedNode) {
 	for {
 		next := iter.Next()
@@ -16,6 +16,14 @@ const (
 	// batchJobAntiAffinityPenalty is the same as the
 	// serviceJobAntiAffinityPenalty but for batch type jobs.
 	batchJobAntiAffinityPenalty = 5.0
+
+	// allocAffinityWeight is the score applied for placing an alloc
+	// on a node that satisfies or violates a soft allocation affinity.
+	allocAffinityWeight = 20.0
+
+	// lxcBaseWeight is the score applied for placing an alloc on a node
+	// that has cached the base container of one of its lxc tasks.
+	lxcBaseWeight = 5.0
 )
 
 // Stack is a chained collection of iterators. The stack is used to
@@ -43,10 +51,13 @@ type GenericStack struct {
 	jobConstraint       *ConstraintChecker
 	taskGroupDrivers    *DriverChecker
 	taskGroupConstraint *ConstraintChecker
+	taskGroupVolumes    *HostVolumeChecker
 
 	proposedAllocConstraint *ProposedAllocConstraintIterator
 	binPack                 *BinPackIterator
 	jobAntiAff              *JobAntiAffinityIterator
+	allocAff                *AllocAffinityIterator
+	lxcBase                 *LXCBaseIterator
 	limit                   *LimitIterator
 	maxScore                *MaxScoreIterator
 }
@@ -73,12 +84,15 @@ func NewGenericStack(batch bool, ctx Context) *GenericStack {
 	// Filter on task group constraints second
 	s.taskGroupConstraint = NewConstraintChecker(ctx, nil)
 
+	// Filter on the host volumes of the task group
+	s.taskGroupVolumes = NewHostVolumeChecker(ctx)
+
 	// Create the feasibility wrapper which wraps all feasibility checks in
 	// which feasibility checking can be skipped if the computed node class has
 	// previously been marked as eligible or ineligible. Generally this will be
 	// checks that only needs to examine the single node to determine feasibility.
 	jobs := []FeasibilityChecker{s.jobConstraint}
-	tgs := []FeasibilityChecker{s.taskGroupDrivers, s.taskGroupConstraint}
+	tgs := []FeasibilityChecker{s.taskGroupDrivers, s.taskGroupConstraint, s.taskGroupVolumes}
 	s.wrappedChecks = NewFeasibilityWrapper(ctx, s.source, jobs, tgs)
 
 	// Filter on constraints that are affected by propsed allocations.
@@ -102,8 +116,16 @@ func NewGenericStack(batch bool, ctx Context) *GenericStack {
 	}
 	s.jobAntiAff = NewJobAntiAffinityIterator(ctx, s.binPack, penalty, "")
 
+	// Apply the soft allocation affinities of the task group. This is
+	// to co-locate with or spread away from the allocations of other jobs.
+	s.allocAff = NewAllocAffinityIterator(ctx, s.jobAntiAff, allocAffinityWeight)
+
+	// Prefer the nodes that have cached the base containers of the lxc
+	// tasks of the task group, as the tasks start faster on them.
+	s.lxcBase = NewLXCBaseIterator(ctx, s.allocAff, lxcBaseWeight)
+
 	// Apply a limit function. This is to avoid scanning *every* possible node.
-	s.limit = NewLimitIterator(ctx, s.jobAntiAff, 2)
+	s.limit = NewLimitIterator(ctx, s.lxcBase, 2)
 
 	// Select the node with the maximum score for placement
 	s.maxScore = NewMaxScoreIterator(ctx, s.limit)
@@ -152,9 +174,12 @@ func (s *GenericStack) Select(tg *structs.TaskGroup) (*RankedNode, *structs.Reso
 	// Update the parameters of iterators
 	s.taskGroupDrivers.SetDrivers(tgConstr.drivers)
 	s.taskGroupConstraint.SetConstraints(tgConstr.constraints)
+	s.taskGroupVolumes.SetVolumes(tg.HostVolumes())
 	s.proposedAllocConstraint.SetTaskGroup(tg)
 	s.wrappedChecks.SetTaskGroup(tg.Name)
-	s.binPack.SetTasks(tg.Tasks)
+	s.binPack.SetTaskGroup(tg)
+	s.allocAff.SetTaskGroup(tg)
+	s.lxcBase.SetTaskGroup(tg)
 
 	// Find the node with the max score
 	option := s.maxScore.Next()
@@ -171,6 +196,19 @@ func (s *GenericStack) Select(tg *structs.TaskGroup) (*RankedNode, *structs.Reso
 	return option, tgConstr.size
 }
 
+// SelectPreferringNodes selects a node for the task group among the preferred
+// nodes first, falling back to the base set of nodes if none of them fit.
+func (s *GenericStack) SelectPreferringNodes(tg *structs.TaskGroup, nodes []*structs.Node) (*RankedNode, *structs.Resources) {
+	originalNodes := s.source.nodes
+	s.source.SetNodes(nodes)
+	option, size := s.Select(tg)
+	s.source.SetNodes(originalNodes)
+	if option != nil {
+		return option, size
+	}
+	return s.Select(tg)
+}
+
 // SystemStack is the Stack used for the System scheduler. It is designed to
 // attempt to make placements on all nodes.
 type SystemStack struct {
@@ -180,6 +218,7 @@ type SystemStack struct {
 	jobConstraint       *ConstraintChecker
 	taskGroupDrivers    *DriverChecker
 	taskGroupConstraint *ConstraintChecker
+	taskGroupVolumes    *HostVolumeChecker
 	binPack             *BinPackIterator
 }
 
@@ -201,12 +240,15 @@ func NewSystemStack(ctx Context) *SystemStack {
 	// Filter on task group constraints second
 	s.taskGroupConstraint = NewConstraintChecker(ctx, nil)
 
+	// Filter on the host volumes of the task group
+	s.taskGroupVolumes = NewHostVolumeChecker(ctx)
+
 	// Create the feasibility wrapper which wraps all feasibility checks in
 	// which feasibility checking can be skipped if the computed node class has
 	// previously been marked as eligible or ineligible. Generally this will be
 	// checks that only needs to examine the single node to determine feasibility.
 	jobs := []FeasibilityChecker{s.jobConstraint}
-	tgs := []FeasibilityChecker{s.taskGroupDrivers, s.taskGroupConstraint}
+	tgs := []FeasibilityChecker{s.taskGroupDrivers, s.taskGroupConstraint, s.taskGroupVolumes}
 	s.wrappedChecks = NewFeasibilityWrapper(ctx, s.source, jobs, tgs)
 
 	// Upgrade from feasible to rank iterator
@@ -242,7 +284,8 @@ func (s *SystemStack) Select(tg *structs.TaskGroup) (*RankedNode, *structs.Resou
 	// Update the parameters of iterators
 	s.taskGroupDrivers.SetDrivers(tgConstr.drivers)
 	s.taskGroupConstraint.SetConstraints(tgConstr.constraints)
-	s.binPack.SetTasks(tg.Tasks)
+	s.taskGroupVolumes.SetVolumes(tg.HostVolumes())
+	s.binPack.SetTaskGroup(tg)
 	s.wrappedChecks.SetTaskGroup(tg.Name)
 
 	// Get the next option that satisfies the constraints.
@@ -284,6 +284,11 @@ func tasksUpdated(a, b *structs.TaskGroup) bool {
 		return true
 	}
 
+	// Changing the ephemeral disk requires a new allocation directory
+	if !reflect.DeepEqual(a.EphemeralDisk, b.EphemeralDisk) {
+		return true
+	}
+
 	// Check each task
 	for _, at := range a.Tasks {
 		bt := b.LookupTask(at.Name)
@@ -457,6 +462,9 @@ func taskGroupConstraints(tg *structs.TaskGroup) tgConstrainTuple {
 		c.constraints = append(c.constraints, task.Constraints...)
 		c.size.Add(task.Resources)
 	}
+	if tg.EphemeralDisk != nil {
+		c.size.DiskMB += tg.EphemeralDisk.SizeMB
+	}
 
 	return c
 }
@@ -468,3 +476,73 @@ func initTaskState(tg *structs.TaskGroup, state string) map[string]*structs.Task
 	}
 	return states
 }
+
+// filterAllocAffinities returns the allocation affinities with the given
+// enforcement mode.
+func filterAllocAffinities(affinities []*structs.AllocAffinity, mode string) []*structs.AllocAffinity {
+	var out []*structs.AllocAffinity
+	for _, aff := range affinities {
+		if aff.Mode == mode {
+			out = append(out, aff)
+		}
+	}
+	return out
+}
+
+// countAffinityMatches returns the number of allocations targeted by the
+// affinity.
+func countAffinityMatches(ctx Context, aff *structs.AllocAffinity, allocs []*structs.Allocation) int {
+	matches := 0
+	for _, alloc := range allocs {
+		var job *structs.Job
+		if len(aff.Meta) != 0 {
+			job = allocJob(ctx, alloc)
+		}
+		if aff.MatchesAlloc(alloc, job) {
+			matches += 1
+		}
+	}
+	return matches
+}
+
+// allocJob returns the job of an allocation. Allocations that are only
+// planned do not have their job set, so the job of the plan is used for them
+// and the state store is consulted as a last resort.
+func allocJob(ctx Context, alloc *structs.Allocation) *structs.Job {
+	if alloc.Job != nil {
+		return alloc.Job
+	}
+	if job := ctx.Plan().Job; job != nil && job.ID == alloc.JobID {
+		return job
+	}
+	job, err := ctx.State().JobByID(alloc.JobID)
+	if err != nil {
+		ctx.Logger().Printf("[ERR] sched: failed to lookup job %q: %v", alloc.JobID, err)
+		return nil
+	}
+	return job
+}
+
+// lxcBaseAttributes returns the node attributes advertising the cached base
+// containers the lxc tasks of the task group are cloned from. Tasks cloned
+// from an existing container or missing part of their template are skipped.
+func lxcBaseAttributes(tg *structs.TaskGroup) []string {
+	var attrs []string
+	for _, task := range tg.Tasks {
+		if task.Driver != "lxc" {
+			continue
+		}
+		if cloneFrom, _ := task.Config["clone_from"].(string); cloneFrom != "" {
+			continue
+		}
+		template, _ := task.Config["template"].(string)
+		distro, _ := task.Config["distro"].(string)
+		release, _ := task.Config["release"].(string)
+		arch, _ := task.Config["arch"].(string)
+		if template == "" || distro == "" || release == "" || arch == "" {
+			continue
+		}
+		attrs = append(attrs, structs.LXCBaseAttrPrefix+structs.LXCBaseKey(template, distro, release, arch))
+	}
+	return attrs
+}